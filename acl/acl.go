@@ -15,13 +15,14 @@ var ErrPermissionDenied = errors.New("acl permission denied")
 var ErrBadInput = errors.New("bad input")
 
 // collection is a container for the three different permission types,
-// users and group. Provides utilities for checking if the collection
+// users, groups and flags. Provides utilities for checking if the collection
 // contains a provided entity
 type collection struct {
 	all bool
 
 	users  []string
 	groups []string
+	flags  []string
 }
 
 // ACL provides utilities for checking if a subject has permission to perform
@@ -35,19 +36,22 @@ type ACL struct {
 // a method for checking permissions. An entity is a user with the following attributes:
 // - name
 // - list of groups
+// - list of flags
 //
 // When describing permissions use the following (glftpd) syntax:
 // - `-` prefix describes a user, i.e. `-userName`
 // - `=` prefix describes a group, i.e. `=groupName`
-// - no prefix describes a flag, i.e. `1` (currently no restrictions on legnth)
+// - no prefix describes a flag, i.e. `1` or `siteop` (currently no restrictions on legnth)
 // - `!` prefix denotes that the preceding permission is blocked, i.e. `!-userName` would
 // not be allowed
 //
 // Currently the order of checking is:
 // - blocked users
 // - blocked groups
+// - blocked flags
 // - allowed users
 // - allowed groups
+// - allowed flags
 // - blocked all (!*)
 // - allowed all (*)
 //
@@ -114,11 +118,17 @@ func NewFromString(s string) (*ACL, error) {
 			c.groups = append(c.groups, f)
 
 		default:
-			if f != "*" {
-				return nil, errors.Errorf("unexpected string in acl input: '%s'", f)
+			if f == "*" {
+				c.all = true
+				continue
+			}
+
+			// flag specific acl
+			if !AllowedUserAndGroupCharsRE.MatchString(f) {
+				return nil, errors.Errorf("flag contains invalid characters: '%s'", f)
 			}
 
-			c.all = true
+			c.flags = append(c.flags, f)
 		}
 
 	}
@@ -147,6 +157,11 @@ func (c *collection) hasGroup(g string) bool {
 	return c.has(c.groups, g)
 }
 
+// hasFlag checks to see if the flags slice contains given flag
+func (c *collection) hasFlag(f string) bool {
+	return c.has(c.flags, f)
+}
+
 // UserMatch checks to see if given User is allowed or blocked. Default is to
 // block access
 func (a *ACL) Match(u *User) bool {
@@ -161,6 +176,12 @@ func (a *ACL) Match(u *User) bool {
 		}
 	}
 
+	for flag := range u.Flags {
+		if a.blocked.hasFlag(flag) {
+			return false
+		}
+	}
+
 	// check allowed lists
 	if a.allowed.hasUser(u.Name) {
 		return true
@@ -172,6 +193,12 @@ func (a *ACL) Match(u *User) bool {
 		}
 	}
 
+	for flag := range u.Flags {
+		if a.allowed.hasFlag(flag) {
+			return true
+		}
+	}
+
 	// fall back to catchalls '*' '!*'
 	if a.blocked.all {
 		return false
@@ -36,8 +36,12 @@ func TestNewFromString(t *testing.T) {
 			nil,
 		},
 		{
-			"something",
-			errors.New("unexpected string in acl input: 'something'"),
+			"siteop",
+			nil,
+		},
+		{
+			"_",
+			errors.New("flag contains invalid characters: '_'"),
 		},
 		{
 			"-*",
@@ -109,6 +113,24 @@ func TestAllowed(t *testing.T) {
 			newTestUser("testUser", "testGroup"),
 			false,
 		},
+		// check flag
+		{
+			"siteop !*",
+			newTestUserWithFlags("testUser", "siteop"),
+			true,
+		},
+		// check banned flag overrides allowing all
+		{
+			"!leech *",
+			newTestUserWithFlags("testUser", "LEECH"),
+			false,
+		},
+		// check missing flag
+		{
+			"siteop",
+			newTestUserWithFlags("testUser", "nuker"),
+			false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,99 @@
+package acl
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+// FlagAnonymous is given to anonymous users. When anonymous access is
+// enabled users with the flag are only checked against the anonymous rules
+const FlagAnonymous = "anonymous"
+
+// AnonymousName is the name anonymous users are given once logged in
+const AnonymousName = "anonymous"
+
+// AnonymousLogins are the login names accepted for anonymous access
+var AnonymousLogins = []string{"anonymous", "ftp"}
+
+// IsAnonymousLogin checks to see if name is one of the AnonymousLogins
+func IsAnonymousLogin(name string) bool {
+	for _, l := range AnonymousLogins {
+		if strings.EqualFold(l, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAnonymousUser returns the User used by anonymous sessions, it does not
+// exist in any Authenticator
+func NewAnonymousUser() *User {
+	return &User{
+		Name: AnonymousName,
+		Flags: map[string]struct{}{
+			FlagAnonymous: struct{}{},
+		},
+	}
+}
+
+// AnonymousRules generates the rules that confine anonymous users to the
+// given paths. Paths and their parents can be listed and files under them
+// downloaded, everything else is private. Owners are always hidden and
+// downloads are ratio free as anonymous users have no credits
+func AnonymousRules(paths []string) ([]Rule, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("anonymous access requires at least one path")
+	}
+
+	var lines []string
+
+	for _, p := range paths {
+		if strings.ContainsAny(p, " \t") {
+			return nil, errors.Errorf("anonymous path '%s' can't contain spaces", p)
+		}
+
+		p = path.Clean("/" + p)
+		if p == "/" {
+			return nil, errors.New("anonymous path can't be '/'")
+		}
+
+		// the root and every parent need to be visible to get to p
+		globs := []string{"/"}
+
+		parts := strings.Split(p[1:], "/")
+		for i := range parts {
+			globs = append(globs, "/"+glob.QuoteMeta(strings.Join(parts[:i+1], "/")))
+		}
+
+		globs = append(globs, globs[len(globs)-1]+"/**")
+
+		visible := "{" + strings.Join(globs, ",") + "}"
+
+		lines = append(lines,
+			"download "+visible+" *",
+			"private "+visible+" *",
+		)
+	}
+
+	lines = append(lines,
+		"private /** !*",
+		"hide_user /** *",
+		"hide_group /** *",
+		"ratio_free /** *",
+	)
+
+	var rules []Rule
+
+	for _, l := range lines {
+		r, err := NewRule(l)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error generating anonymous rule")
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,106 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAnonymousRules(t *testing.T) {
+	var rules []Rule
+	for _, l := range []string{
+		"download /** *",
+		"upload /** *",
+		"private /pub/secret -admin",
+	} {
+		r, err := NewRule(l)
+		checkErr(t, err, nil)
+		rules = append(rules, r)
+	}
+
+	perms, err := NewPermissions(rules)
+	checkErr(t, err, nil)
+
+	anonymousRules, err := AnonymousRules([]string{"/pub/files", "incoming/../a"})
+	checkErr(t, err, nil)
+
+	anonymous, err := NewPermissions(anonymousRules)
+	checkErr(t, err, nil)
+
+	perms.SetAnonymous(anonymous)
+
+	anon := NewAnonymousUser()
+	user := newTestUser("user")
+
+	var tests = []struct {
+		scope    PermissionScope
+		path     string
+		user     *User
+		expected bool
+	}{
+		{PermissionScopeDownload, "/pub/files/file.zip", anon, true},
+		{PermissionScopeDownload, "/pub/files/dir/file.zip", anon, true},
+		{PermissionScopeDownload, "/a/file.zip", anon, true},
+		{PermissionScopeDownload, "/pub/other.zip", anon, false},
+		{PermissionScopeDownload, "/other.zip", anon, false},
+		{PermissionScopeUpload, "/pub/files/file.zip", anon, false},
+		{PermissionScopeMakeDir, "/pub/files/dir", anon, false},
+		{PermissionScopeHideUser, "/pub/files/file.zip", anon, true},
+		{PermissionScopeRatioFree, "/pub/files/file.zip", anon, true},
+		{PermissionScopeDownload, "/other.zip", user, true},
+		{PermissionScopeUpload, "/pub/files/file.zip", user, true},
+		{PermissionScopeHideUser, "/pub/files/file.zip", user, false},
+	}
+
+	for _, tt := range tests {
+		if got := perms.Match(tt.scope, tt.path, tt.user); got != tt.expected {
+			t.Errorf("expected %s '%s' for '%s' to be %t", tt.scope, tt.path, tt.user.Name, tt.expected)
+		}
+	}
+
+	var private = []struct {
+		path     string
+		expected bool
+	}{
+		{"/", true},
+		{"/pub", true},
+		{"/pub/files", true},
+		{"/pub/files/dir", true},
+		{"/pub/other", false},
+		{"/pub/secret", false},
+		{"/other", false},
+	}
+
+	for _, tt := range private {
+		match, found := perms.MatchNoDefault(PermissionScopePrivate, tt.path, anon)
+		if found && match != tt.expected {
+			t.Errorf("expected private '%s' for anonymous to be %t", tt.path, tt.expected)
+		}
+		if !found && !tt.expected {
+			t.Errorf("expected private '%s' for anonymous to be found", tt.path)
+		}
+	}
+
+	// users keep the normal private rules
+	if match, found := perms.MatchNoDefault(PermissionScopePrivate, "/pub/secret", user); !found || match {
+		t.Error("expected /pub/secret to be private for user")
+	}
+
+	_, err = AnonymousRules(nil)
+	checkErr(t, err, errors.New("anonymous access requires at least one path"))
+
+	_, err = AnonymousRules([]string{"/"})
+	checkErr(t, err, errors.New("anonymous path can't be '/'"))
+}
+
+func TestIsAnonymousLogin(t *testing.T) {
+	for _, name := range []string{"anonymous", "FTP"} {
+		if !IsAnonymousLogin(name) {
+			t.Errorf("expected '%s' to be an anonymous login", name)
+		}
+	}
+
+	if IsAnonymousLogin("user") {
+		t.Error("expected 'user' not to be an anonymous login")
+	}
+}
@@ -2,9 +2,12 @@ package acl
 
 import (
 	"bytes"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/logging"
 	"github.com/pkg/errors"
 	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -13,14 +16,94 @@ import (
 var (
 	ErrUserExists       = errors.New("user exists")
 	ErrUserDoesntExist  = errors.New("user does not exist")
+	ErrUserDeleted      = errors.New("user is deleted")
+	ErrUserNotDeleted   = errors.New("user is not deleted")
 	ErrGroupExists      = errors.New("group exists")
 	ErrGroupDoesntExist = errors.New("group does not exist")
+	ErrFlagExists       = errors.New("user already has flag")
+	ErrFlagDoesntExist  = errors.New("user does not have flag")
+	ErrIPExists         = errors.New("user already has ip mask")
+	ErrIPDoesntExist    = errors.New("user does not have ip mask")
+	ErrNotEnoughCredits = errors.New("not enough credits")
+	ErrUserInGroup      = errors.New("user is already in group")
+	ErrUserNotInGroup   = errors.New("user is not in group")
+	ErrNotGroupAdmin    = errors.New("user is not an admin of group")
+	ErrGroupFull        = errors.New("group has no free slots")
+	ErrNoGadminSlots    = errors.New("group has no gadmin slots")
+	ErrNoLeechSlots     = errors.New("group has no leech slots")
 )
 
+// maxRetries is the number of times a transaction is retried on conflict
+const maxRetries = 10
+
 type AuthenticatorOpts struct {
+	// name of a registered backend, defaults to DefaultAuthenticator
+	Backend string `goftpd:"backend"`
+
 	DB string `goftpd:"db"`
+
+	// used by the sql backend, one of postgres, mysql or sqlite
+	// with a driver specific dsn
+	SQLDriver string `goftpd:"sql_driver"`
+	SQLDSN    string `goftpd:"sql_dsn"`
+
+	// when a user or group is deleted any files they own are
+	// reassigned to these
+	OrphanUser  string `goftpd:"orphan_user"`
+	OrphanGroup string `goftpd:"orphan_group"`
+
+	// what to do with expired users when ExpireUsers is called,
+	// one of ExpiredAction*
+	ExpiredAction string `goftpd:"expired_action"`
+
+	// failed logins from a user or ip are counted, once LockoutAttempts
+	// is reached the user or ip is locked for LockoutTime seconds. Each
+	// failed attempt delays the reply by a further TarpitDelay seconds
+	LockoutAttempts int `goftpd:"lockout_attempts"`
+	LockoutTime     int `goftpd:"lockout_time"`
+	TarpitDelay     int `goftpd:"tarpit_delay"`
+
+	// passwords shorter than PasswordMinLength are refused, with
+	// PasswordMixed they must also contain both letters and digits
+	PasswordMinLength int  `goftpd:"password_min_length"`
+	PasswordMixed     bool `goftpd:"password_mixed"`
+
+	// users adding their own ident@ip masks can have at most MaxIPs,
+	// each needing at least MinIPOctets octets before any wildcard, or
+	// labels after it for hostnames
+	MaxIPs      int `goftpd:"max_ips"`
+	MinIPOctets int `goftpd:"min_ip_octets"`
+
+	logger logging.Logger
+}
+
+// SetLogger sets where the Authenticator logs, defaults to logging.Stderr
+func (o *AuthenticatorOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where the Authenticator logs
+func (o *AuthenticatorOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
 }
 
+const (
+	// ExpiredActionNone leaves expired users alone, they are still
+	// unable to login
+	ExpiredActionNone = "none"
+	// ExpiredActionFlag adds the FlagExpired to expired users
+	ExpiredActionFlag = "flag"
+	// ExpiredActionPurge deletes expired users
+	ExpiredActionPurge = "purge"
+)
+
+// FlagExpired is added to users that have expired when using ExpiredActionFlag
+const FlagExpired = "expired"
+
+// FlagLeech marks a user as a leech, gadmins need a free leech slot to add them
+const FlagLeech = "leech"
+
 type Authenticator interface {
 	// create
 	AddUser(string, string) (*User, error)
@@ -30,17 +113,65 @@ type Authenticator interface {
 	GetUser(string) (*User, error)
 	GetGroup(string) (*Group, error)
 
+	// enumerate
+	GetAllUsers() ([]*User, error)
+	GetAllGroups() ([]*Group, error)
+	GetUsersWithPrefix(string) ([]*User, error)
+	GetGroupsWithPrefix(string) ([]*Group, error)
+
 	// save
 	SaveUser(*User) error
 	SaveGroup(*Group) error
+	UpdateUser(string, func(*User) error) error
+	UpdateGroup(string, func(*Group) error) error
 
-	// delete
+	// delete, deleted users are kept until purged and can be readded
 	DeleteUser(user string) error
+	ReaddUser(user string) error
+	PurgeUser(user string) error
 	DeleteGroup(group string) error
 
+	// group membership
+	AddUserToGroup(string, string) error
+	GadminAddUserToGroup(string, string, string) error
+	RemoveUserFromGroup(string, string) error
+	SetPrimaryGroup(string, string) error
+
+	// flags
+	AddFlag(string, string) error
+	RemoveFlag(string, string) error
+
+	// ident@ip masks
+	AddIP(string, string) error
+	RemoveIP(string, string) error
+
+	// credits
+	IncrCredits(string, int64) (int64, error)
+	DecrCredits(string, int64, bool) (int64, error)
+
+	// logins
+	RecordLogin(string, string, string) error
+	LoginHistory(string) ([]Login, error)
+
 	// utilities
 	CheckPassword(string, string) bool
+	CheckPasswordPolicy(string) error
+	CheckMaskPolicy(string, int) error
 	ChangePassword(string, string) error
+	CheckTOTP(string, string) bool
+
+	// lockout
+	FailedLogin(string, string) (time.Duration, error)
+	Locked(string, string) bool
+	ClearFailedLogins(string) error
+	Unlock(string) (bool, error)
+
+	// maintenance
+	ExpireUsers() ([]string, error)
+
+	// backups
+	Export(io.Writer, bool) error
+	Import(io.Reader) error
 }
 
 // Entry describes an Authenticator Entry
@@ -48,90 +179,213 @@ type Entry interface {
 	Key() []byte
 }
 
+// Reconciler is implemented by stores that reference Users and Groups by
+// name, i.e. the shadow filesystem. It is used when a User or Group is
+// deleted so that ownership never references something that doesn't exist
+type Reconciler interface {
+	ReassignUser(string, string) error
+	ReassignGroup(string, string) error
+}
+
 // BadgerAuthenticator implements an Authenticator using a badge key/value store
 type BadgerAuthenticator struct {
+	*AuthenticatorOpts
 	db         *badger.DB
 	bufferPool sync.Pool
+	reconciler Reconciler
+	cache      *entryCache
+
+	// serialises read-modify-write updates
+	mtx sync.Mutex
 }
 
 // NewBadgerAuthenticator takes in options and a badger DB and returns a new BadgerAuthenticator
 // which implements the Authenticator interface
-func NewBadgerAuthenticator(db *badger.DB) *BadgerAuthenticator {
+func NewBadgerAuthenticator(opts *AuthenticatorOpts, db *badger.DB) *BadgerAuthenticator {
 	return &BadgerAuthenticator{
-		db: db,
+		AuthenticatorOpts: opts,
+		db:                db,
 		bufferPool: sync.Pool{
 			New: func() interface{} {
 				return &bytes.Buffer{}
 			},
 		},
+		cache: newEntryCache(),
 	}
 }
 
-func (a *BadgerAuthenticator) encodeAndUpdate(e Entry) error {
-	return a.db.Update(func(tx *badger.Txn) error {
-		enc := msgpack.GetEncoder()
-		defer msgpack.PutEncoder(enc)
+func init() {
+	RegisterAuthenticator("badger", func(opts *AuthenticatorOpts, params map[string]string) (Authenticator, error) {
+		opt := badger.DefaultOptions(opts.DB)
+		// badger's info lines are only logged at debug
+		opt.Logger = logging.Badger(opts.Logger())
+
+		db, err := badger.Open(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBadgerAuthenticator(opts, db), nil
+	})
+}
+
+// SetReconciler sets the Reconciler used to reassign ownership on deletes
+func (a *BadgerAuthenticator) SetReconciler(r Reconciler) { a.reconciler = r }
+
+// DB returns the badger DB the users and groups are kept in
+func (a *BadgerAuthenticator) DB() *badger.DB { return a.db }
+
+// encode msgpack encodes the Entry and sets it using the given transaction
+func (a *BadgerAuthenticator) encode(tx *badger.Txn, e Entry) error {
+	val, err := a.marshal(e)
+	if err != nil {
+		return err
+	}
+
+	a.cache.touch(tx, e.Key())
+
+	return tx.Set(e.Key(), val)
+}
+
+// encodeWithTTL msgpack encodes the Entry and sets it using the given transaction,
+// the Entry is removed by badger after the ttl
+func (a *BadgerAuthenticator) encodeWithTTL(tx *badger.Txn, e Entry, ttl time.Duration) error {
+	val, err := a.marshal(e)
+	if err != nil {
+		return err
+	}
+
+	a.cache.touch(tx, e.Key())
+
+	return tx.SetEntry(badger.NewEntry(e.Key(), val).WithTTL(ttl))
+}
+
+// marshal msgpack encodes the Entry
+func (a *BadgerAuthenticator) marshal(e Entry) ([]byte, error) {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	b := a.bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer a.bufferPool.Put(b)
+
+	enc.Reset(b)
+
+	if err := enc.Encode(e); err != nil {
+		return nil, err
+	}
+
+	// badger requires the value to be valid until the transaction
+	// is committed and the buffer is going back in to the pool
+	val := make([]byte, b.Len())
+	copy(val, b.Bytes())
+
+	return val, nil
+}
+
+// decode retrieves the key using the given transaction and msgpack decodes
+// it in to the Entry
+func (a *BadgerAuthenticator) decode(tx *badger.Txn, key []byte, e Entry) error {
+	item, err := tx.Get(key)
+	if err != nil {
+		return err
+	}
+
+	return a.decodeItem(item, e)
+}
 
-		b := a.bufferPool.Get().(*bytes.Buffer)
-		b.Reset()
-		defer a.bufferPool.Put(b)
+// decodeItem msgpack decodes the value of the item in to the Entry
+func (a *BadgerAuthenticator) decodeItem(item *badger.Item, e Entry) error {
+	return item.Value(func(val []byte) error {
+		dec := msgpack.GetDecoder()
+		defer msgpack.PutDecoder(dec)
 
-		enc.Reset(b)
+		dec.ResetBytes(val)
 
-		if err := enc.Encode(e); err != nil {
+		if err := dec.Decode(e); err != nil {
 			return err
 		}
 
-		return tx.Set(e.Key(), b.Bytes())
+		return nil
 	})
 }
 
-func (a *BadgerAuthenticator) getAndDecode(key []byte, e Entry) error {
-	return a.db.View(func(tx *badger.Txn) error {
-		item, err := tx.Get(key)
-		if err != nil {
-			return err
-		}
+// remove deletes the key using the given transaction
+func (a *BadgerAuthenticator) remove(tx *badger.Txn, key []byte) error {
+	a.cache.touch(tx, key)
 
-		return item.Value(func(val []byte) error {
-			dec := msgpack.GetDecoder()
-			defer msgpack.PutDecoder(dec)
+	return tx.Delete(key)
+}
 
-			dec.ResetBytes(val)
+// write runs fn in a read-write transaction, any cached Entries written by
+// fn are invalidated once the transaction is finished
+func (a *BadgerAuthenticator) write(fn func(*badger.Txn) error) error {
+	var txn *badger.Txn
 
-			if err := dec.Decode(e); err != nil {
-				return err
-			}
+	err := a.db.Update(func(tx *badger.Txn) error {
+		txn = tx
+		return fn(tx)
+	})
 
-			return nil
-		})
+	if txn != nil {
+		a.cache.done(txn)
+	}
+
+	return err
+}
+
+func (a *BadgerAuthenticator) encodeAndUpdate(e Entry) error {
+	return a.write(func(tx *badger.Txn) error {
+		return a.encode(tx, e)
 	})
 }
 
-// AddUser creates a user setting the password
-func (a *BadgerAuthenticator) AddUser(name, pass string) (*User, error) {
-	// check if we have a user by that name
-	u, err := a.GetUser(name)
-	if err == nil {
-		return nil, ErrUserExists
-	}
+func (a *BadgerAuthenticator) getAndDecode(key []byte, e Entry) error {
+	return a.db.View(func(tx *badger.Txn) error {
+		return a.decode(tx, key, e)
+	})
+}
 
-	if err != ErrUserDoesntExist {
-		return nil, err
+// exists checks to see if the key is in the store using the given transaction
+func (a *BadgerAuthenticator) exists(tx *badger.Txn, key []byte) (bool, error) {
+	if _, err := tx.Get(key); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, err
 	}
+	return true, nil
+}
 
+// AddUser creates a user setting the password
+func (a *BadgerAuthenticator) AddUser(name, pass string) (*User, error) {
 	// hash password
 	hashed, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
-	u = &User{}
+	u := &User{}
 
 	u.Name = name
 	u.Password = hashed
+	u.CreatedAt = time.Now()
+
+	err = a.write(func(tx *badger.Txn) error {
+		// check if we have a user by that name
+		ok, err := a.exists(tx, u.Key())
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return ErrUserExists
+		}
 
-	if err := a.encodeAndUpdate(u); err != nil {
+		return a.encode(tx, u)
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -140,21 +394,26 @@ func (a *BadgerAuthenticator) AddUser(name, pass string) (*User, error) {
 
 // AddGroup creates a Group
 func (a *BadgerAuthenticator) AddGroup(name string) (*Group, error) {
-	// check if we have a user by that name
-	g, err := a.GetGroup(name)
-	if err == nil {
-		return nil, ErrGroupExists
-	}
+	g := &Group{}
 
-	if err != ErrGroupDoesntExist {
-		return nil, err
-	}
+	g.Name = name
+	g.AddedAt = time.Now()
+
+	err := a.write(func(tx *badger.Txn) error {
+		// check if we have a group by that name
+		ok, err := a.exists(tx, g.Key())
+		if err != nil {
+			return err
+		}
 
-	g = &Group{}
+		if ok {
+			return ErrGroupExists
+		}
 
-	g.Name = name
+		return a.encode(tx, g)
+	})
 
-	if err := a.encodeAndUpdate(g); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
@@ -165,6 +424,12 @@ func (a *BadgerAuthenticator) AddGroup(name string) (*Group, error) {
 func (a *BadgerAuthenticator) GetUser(name string) (*User, error) {
 	u := User{Name: name}
 
+	if e, ok := a.cache.get(u.Key()); ok {
+		return e.(*User), nil
+	}
+
+	gen := a.cache.generation()
+
 	if err := a.getAndDecode(u.Key(), &u); err != nil {
 		if err == badger.ErrKeyNotFound {
 			return nil, ErrUserDoesntExist
@@ -172,53 +437,523 @@ func (a *BadgerAuthenticator) GetUser(name string) (*User, error) {
 		return nil, err
 	}
 
+	a.cache.put(&u, gen)
+
 	return &u, nil
 }
 
 // GetGroup attempts to retrieve a Group from the store using the name
 func (a *BadgerAuthenticator) GetGroup(name string) (*Group, error) {
-	return nil, errors.New("stub")
+	g := Group{Name: name}
+
+	if e, ok := a.cache.get(g.Key()); ok {
+		return e.(*Group), nil
+	}
+
+	gen := a.cache.generation()
+
+	if err := a.getAndDecode(g.Key(), &g); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrGroupDoesntExist
+		}
+		return nil, err
+	}
+
+	a.cache.put(&g, gen)
+
+	return &g, nil
+}
+
+// GetAllUsers returns every User ordered by name
+func (a *BadgerAuthenticator) GetAllUsers() ([]*User, error) {
+	return a.GetUsersWithPrefix("")
+}
+
+// GetAllGroups returns every Group ordered by name
+func (a *BadgerAuthenticator) GetAllGroups() ([]*Group, error) {
+	return a.GetGroupsWithPrefix("")
+}
+
+// GetUsersWithPrefix returns all Users whose name starts with prefix (case
+// insensitive) ordered by name
+func (a *BadgerAuthenticator) GetUsersWithPrefix(prefix string) ([]*User, error) {
+	var users []*User
+
+	err := a.db.View(func(tx *badger.Txn) error {
+		return a.iterate(tx, User{Name: prefix}.Key(), func(item *badger.Item) error {
+			var u User
+
+			if err := a.decodeItem(item, &u); err != nil {
+				return err
+			}
+
+			users = append(users, &u)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetGroupsWithPrefix returns all Groups whose name starts with prefix (case
+// insensitive) ordered by name
+func (a *BadgerAuthenticator) GetGroupsWithPrefix(prefix string) ([]*Group, error) {
+	var groups []*Group
+
+	err := a.db.View(func(tx *badger.Txn) error {
+		return a.iterate(tx, Group{Name: prefix}.Key(), func(item *badger.Item) error {
+			var g Group
+
+			if err := a.decodeItem(item, &g); err != nil {
+				return err
+			}
+
+			groups = append(groups, &g)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// iterate calls fn for every item with a key starting with prefix
+func (a *BadgerAuthenticator) iterate(tx *badger.Txn, prefix []byte, fn func(*badger.Item) error) error {
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := fn(it.Item()); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// SaveUser overwrites the User in the store
+// SaveUser overwrites the User in the store. The User must already exist
 func (a *BadgerAuthenticator) SaveUser(user *User) error {
-	return errors.New("stub")
+	return a.write(func(tx *badger.Txn) error {
+		ok, err := a.exists(tx, user.Key())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrUserDoesntExist
+		}
+
+		return a.encode(tx, user)
+	})
 }
 
-// SaveGroup overwrites the Group in the store
-func (a *BadgerAuthenticator) SaveGroup(user *Group) error {
-	return errors.New("stub")
+// SaveGroup overwrites the Group in the store. The Group must already exist
+func (a *BadgerAuthenticator) SaveGroup(group *Group) error {
+	return a.write(func(tx *badger.Txn) error {
+		ok, err := a.exists(tx, group.Key())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrGroupDoesntExist
+		}
+
+		return a.encode(tx, group)
+	})
 }
 
-// DeleteUser removes the User from the store.
-// TODO: how to handle shadow fs
-func (a *BadgerAuthenticator) DeleteUser(name string) error {
-	return errors.New("stub")
+// UpdateUser performs a read-modify-write on the User in a single transaction,
+// if fn returns an error the User is not saved. Conflicting transactions are
+// retried, so fn may be called more than once
+func (a *BadgerAuthenticator) UpdateUser(name string, fn func(*User) error) error {
+	return a.update(func(tx *badger.Txn) error {
+		u, err := a.decodeUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+
+		return a.encode(tx, u)
+	})
+}
+
+// UpdateGroup performs a read-modify-write on the Group in a single transaction,
+// if fn returns an error the Group is not saved
+func (a *BadgerAuthenticator) UpdateGroup(name string, fn func(*Group) error) error {
+	return a.update(func(tx *badger.Txn) error {
+		g, err := a.decodeGroup(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(g); err != nil {
+			return err
+		}
+
+		return a.encode(tx, g)
+	})
+}
+
+// update runs fn in a read-write transaction, retrying if the transaction conflicts
+func (a *BadgerAuthenticator) update(fn func(*badger.Txn) error) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var err error
+
+	for i := 0; i < maxRetries; i++ {
+		err = a.write(fn)
+
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+
+	return err
+}
+
+// decodeUser gets a User in the transaction returning ErrUserDoesntExist if not found
+func (a *BadgerAuthenticator) decodeUser(tx *badger.Txn, name string) (*User, error) {
+	u := User{Name: name}
+
+	if err := a.decode(tx, u.Key(), &u); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrUserDoesntExist
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// decodeGroup gets a Group in the transaction returning ErrGroupDoesntExist if not found
+func (a *BadgerAuthenticator) decodeGroup(tx *badger.Txn, name string) (*Group, error) {
+	g := Group{Name: name}
+
+	if err := a.decode(tx, g.Key(), &g); err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrGroupDoesntExist
+		}
+		return nil, err
+	}
+
+	return &g, nil
+}
+
+// PurgeUser removes the User from the store and from the members of
+// their Groups. Any files owned by the User are reassigned to the OrphanUser
+func (a *BadgerAuthenticator) PurgeUser(name string) error {
+	err := a.update(func(tx *badger.Txn) error {
+		u, err := a.decodeUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		for group := range u.Groups {
+			g, err := a.decodeGroup(tx, group)
+			if err != nil {
+				if err == ErrGroupDoesntExist {
+					continue
+				}
+				return err
+			}
+
+			if g.removeMember(name) {
+				if err := a.encode(tx, g); err != nil {
+					return err
+				}
+			}
+		}
+
+		return a.remove(tx, u.Key())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if a.reconciler != nil {
+		if err := a.reconciler.ReassignUser(name, a.OrphanUser); err != nil {
+			return errors.WithMessage(err, "unable to reassign user ownership")
+		}
+	}
+
+	return nil
 }
 
 // DeleteGroup removes the Group from the store and removes it from
-// any Users.
-// TODO: how to handle shadow fs
+// any Users. Any files owned by the Group are reassigned to the OrphanGroup
 func (a *BadgerAuthenticator) DeleteGroup(name string) error {
-	return errors.New("stub")
-}
+	g := Group{Name: name}
+
+	err := a.write(func(tx *badger.Txn) error {
+		ok, err := a.exists(tx, g.Key())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrGroupDoesntExist
+		}
+
+		if err := a.remove(tx, g.Key()); err != nil {
+			return err
+		}
+
+		// remove the group from any users
+		users, err := a.usersWithGroup(tx, name)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			if err := a.encode(tx, u); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 
-// CheckPassword checks to see if the password is the correct one for
-// the user. Any failure (i.e. user doesn't exist) returns false.
-func (a *BadgerAuthenticator) CheckPassword(name, pass string) bool {
-	u, err := a.GetUser(name)
 	if err != nil {
-		return false
+		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword(u.Password, []byte(pass)); err != nil {
-		return false
+	if a.reconciler != nil {
+		if err := a.reconciler.ReassignGroup(name, a.OrphanGroup); err != nil {
+			return errors.WithMessage(err, "unable to reassign group ownership")
+		}
 	}
 
-	return true
+	return nil
+}
+
+// AddUserToGroup adds the User to the Group, updating both in the same
+// transaction. If the User has no PrimaryGroup then it is set to the Group
+func (a *BadgerAuthenticator) AddUserToGroup(user, group string) error {
+	return a.update(func(tx *badger.Txn) error {
+		u, err := a.decodeUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		g, err := a.decodeGroup(tx, group)
+		if err != nil {
+			return err
+		}
+
+		return a.addUserToGroup(tx, u, g)
+	})
 }
 
-// ChangePassword changes the password for the User
-func (a *BadgerAuthenticator) ChangePassword(user, pass string) error {
-	return errors.New("stub")
+// GadminAddUserToGroup adds the User to the Group on behalf of a group admin,
+// using up one of the Group's gadmin slots, or a leech slot if the User is a leech
+func (a *BadgerAuthenticator) GadminAddUserToGroup(gadmin, user, group string) error {
+	return a.update(func(tx *badger.Txn) error {
+		admin, err := a.decodeUser(tx, gadmin)
+		if err != nil {
+			return err
+		}
+
+		u, err := a.decodeUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		g, err := a.decodeGroup(tx, group)
+		if err != nil {
+			return err
+		}
+
+		if err := useGadminSlot(admin, u, g); err != nil {
+			return err
+		}
+
+		return a.addUserToGroup(tx, u, g)
+	})
+}
+
+// addUserToGroup adds the User to the Group checking the Group has room
+func (a *BadgerAuthenticator) addUserToGroup(tx *badger.Txn, u *User, g *Group) error {
+	if err := joinGroup(u, g); err != nil {
+		return err
+	}
+
+	if err := a.encode(tx, u); err != nil {
+		return err
+	}
+
+	return a.encode(tx, g)
+}
+
+// RemoveUserFromGroup removes the User from the Group, updating both in the same
+// transaction. If it was the User's PrimaryGroup then the PrimaryGroup is cleared
+func (a *BadgerAuthenticator) RemoveUserFromGroup(user, group string) error {
+	return a.update(func(tx *badger.Txn) error {
+		u, err := a.decodeUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		g, err := a.decodeGroup(tx, group)
+		if err != nil {
+			return err
+		}
+
+		if err := leaveGroup(u, g); err != nil {
+			return err
+		}
+
+		if err := a.encode(tx, u); err != nil {
+			return err
+		}
+
+		return a.encode(tx, g)
+	})
+}
+
+// SetPrimaryGroup sets the User's PrimaryGroup, the User must already be a
+// member of the Group
+func (a *BadgerAuthenticator) SetPrimaryGroup(user, group string) error {
+	return a.update(func(tx *badger.Txn) error {
+		u, err := a.decodeUser(tx, user)
+		if err != nil {
+			return err
+		}
+
+		g, err := a.decodeGroup(tx, group)
+		if err != nil {
+			return err
+		}
+
+		if !u.InGroup(g.Name) {
+			return ErrUserNotInGroup
+		}
+
+		u.PrimaryGroup = g.Name
+
+		return a.encode(tx, u)
+	})
+}
+
+// usersWithGroup iterates over all Users and returns those that were a member of
+// the group, with the group removed. They are collected as badger doesn't allow
+// writes while iterating
+func (a *BadgerAuthenticator) usersWithGroup(tx *badger.Txn, name string) ([]*User, error) {
+	var users []*User
+
+	err := a.iterate(tx, User{}.Key(), func(item *badger.Item) error {
+		var u User
+
+		if err := a.decodeItem(item, &u); err != nil {
+			return err
+		}
+
+		if u.removeGroup(name) {
+			users = append(users, &u)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// shared read-modify-write operations, see user_updates.go
+func (a *BadgerAuthenticator) CheckPassword(name, pass string) bool {
+	return checkPassword(a, name, pass)
+}
+func (a *BadgerAuthenticator) ChangePassword(name, pass string) error {
+	return changePassword(a, a.AuthenticatorOpts, name, pass)
+}
+func (a *BadgerAuthenticator) CheckTOTP(name, code string) bool   { return checkTOTP(a, name, code) }
+func (a *BadgerAuthenticator) AddFlag(name, flag string) error    { return addFlag(a, name, flag) }
+func (a *BadgerAuthenticator) RemoveFlag(name, flag string) error { return removeFlag(a, name, flag) }
+func (a *BadgerAuthenticator) AddIP(name, mask string) error      { return addIP(a, name, mask) }
+func (a *BadgerAuthenticator) RemoveIP(name, mask string) error   { return removeIP(a, name, mask) }
+func (a *BadgerAuthenticator) DeleteUser(name string) error       { return deleteUser(a, name) }
+func (a *BadgerAuthenticator) ReaddUser(name string) error        { return readdUser(a, name) }
+func (a *BadgerAuthenticator) IncrCredits(name string, n int64) (int64, error) {
+	return incrCredits(a, name, n)
+}
+func (a *BadgerAuthenticator) DecrCredits(name string, n int64, force bool) (int64, error) {
+	return decrCredits(a, name, n, force)
+}
+func (a *BadgerAuthenticator) RecordLogin(name, ident, ip string) error {
+	return recordLogin(a, name, ident, ip)
+}
+func (a *BadgerAuthenticator) LoginHistory(name string) ([]Login, error) {
+	return loginHistory(a, name)
+}
+func (a *BadgerAuthenticator) Export(w io.Writer, credentials bool) error {
+	return exportBackup(a, w, credentials)
+}
+func (a *BadgerAuthenticator) Import(r io.Reader) error { return importBackup(a, r) }
+
+// ExpireUsers finds all expired Users and performs the configured ExpiredAction
+// on them. Returns the names of any Users that were acted on
+func (a *BadgerAuthenticator) ExpireUsers() ([]string, error) {
+	if a.ExpiredAction != ExpiredActionFlag && a.ExpiredAction != ExpiredActionPurge {
+		return nil, nil
+	}
+
+	var expired []string
+
+	err := a.db.View(func(tx *badger.Txn) error {
+		return a.iterate(tx, User{}.Key(), func(item *badger.Item) error {
+			var u User
+
+			if err := a.decodeItem(item, &u); err != nil {
+				return err
+			}
+
+			if !u.Expired() {
+				return nil
+			}
+
+			if a.ExpiredAction == ExpiredActionFlag && u.HasFlag(FlagExpired) {
+				return nil
+			}
+
+			expired = append(expired, u.Name)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range expired {
+		switch a.ExpiredAction {
+		case ExpiredActionFlag:
+			err = a.AddFlag(name, FlagExpired)
+		case ExpiredActionPurge:
+			err = a.PurgeUser(name)
+		}
+
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to expire '%s'", name)
+		}
+	}
+
+	return expired, nil
 }
@@ -0,0 +1,639 @@
+package acl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestAddUser(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	u, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	if u.CreatedAt.IsZero() {
+		t.Error("expected CreatedAt to be set")
+	}
+
+	if _, err := auth.AddUser("USER", "pass"); err != ErrUserExists {
+		t.Fatalf("expected ErrUserExists got: %s", err)
+	}
+
+	if !auth.CheckPassword("user", "pass") {
+		t.Error("expected CheckPassword to be true")
+	}
+
+	if auth.CheckPassword("user", "bad") {
+		t.Error("expected CheckPassword to be false")
+	}
+}
+
+func TestSaveUser(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.SaveUser(newTestUser("user")); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	u, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	u.Credits = 1024
+
+	checkErr(t, auth.SaveUser(u), nil)
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.Credits != 1024 {
+		t.Errorf("expected credits to be 1024 got %d", u.Credits)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.ChangePassword("user", "new"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.ChangePassword("user", "new"), nil)
+
+	if auth.CheckPassword("user", "pass") {
+		t.Error("expected old password to fail")
+	}
+
+	if !auth.CheckPassword("user", "new") {
+		t.Error("expected new password to succeed")
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	auth.PasswordMinLength = 8
+	auth.PasswordMixed = true
+
+	_, err := AddUserFromTemplate(auth, &Template{}, "user", "short1", nil)
+	if errors.Cause(err) != ErrPasswordPolicy {
+		t.Fatalf("expected ErrPasswordPolicy got: %v", err)
+	}
+
+	_, err = AddUserFromTemplate(auth, &Template{}, "user", "password1", nil)
+	checkErr(t, err, nil)
+
+	var tests = []struct {
+		pass string
+		ok   bool
+	}{
+		{"abc1", false},
+		{"onlyletters", false},
+		{"123456789", false},
+		{"letters123", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pass, func(t *testing.T) {
+			err := auth.ChangePassword("user", tt.pass)
+			if tt.ok != (err == nil) {
+				t.Fatalf("expected ok %t got %v", tt.ok, err)
+			}
+		})
+	}
+
+	if !auth.CheckPassword("user", "letters123") {
+		t.Error("expected changed password to succeed")
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	auth, r := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.DeleteUser("user"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	if err := auth.ReaddUser("user"); err != ErrUserNotDeleted {
+		t.Fatalf("expected ErrUserNotDeleted got: %s", err)
+	}
+
+	checkErr(t, auth.DeleteUser("user"), nil)
+
+	if err := auth.DeleteUser("user"); err != ErrUserDeleted {
+		t.Fatalf("expected ErrUserDeleted got: %s", err)
+	}
+
+	// deleted users are kept along with their files
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.Deleted() || u.DeletedAt.IsZero() {
+		t.Fatalf("expected user to be deleted: %+v", u)
+	}
+
+	if _, ok := r.users["user"]; ok {
+		t.Error("expected deleted user to keep their files")
+	}
+
+	checkErr(t, auth.ReaddUser("user"), nil)
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.Deleted() || !u.DeletedAt.IsZero() {
+		t.Fatalf("expected user to be readded: %+v", u)
+	}
+
+	checkErr(t, auth.PurgeUser("user"), nil)
+
+	if _, err := auth.GetUser("user"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	if r.users["user"] != "nobody" {
+		t.Errorf("expected user to be reassigned to nobody got '%s'", r.users["user"])
+	}
+}
+
+func TestGroups(t *testing.T) {
+	auth, r := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if _, err := auth.GetGroup("group"); err != ErrGroupDoesntExist {
+		t.Fatalf("expected ErrGroupDoesntExist got: %s", err)
+	}
+
+	g, err := auth.AddGroup("group")
+	checkErr(t, err, nil)
+
+	if _, err := auth.AddGroup("group"); err != ErrGroupExists {
+		t.Fatalf("expected ErrGroupExists got: %s", err)
+	}
+
+	checkErr(t, auth.SaveGroup(g), nil)
+
+	_, err = auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.SaveUser(newTestUser("user", "group", "other")), nil)
+
+	checkErr(t, auth.DeleteGroup("group"), nil)
+
+	if _, err := auth.GetGroup("group"); err != ErrGroupDoesntExist {
+		t.Fatalf("expected ErrGroupDoesntExist got: %s", err)
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if _, ok := u.Groups["group"]; ok {
+		t.Error("expected group to be removed from user")
+	}
+
+	if _, ok := u.Groups["other"]; !ok {
+		t.Error("expected other group to remain on user")
+	}
+
+	if len(u.PrimaryGroup) > 0 {
+		t.Errorf("expected primary group to be cleared got '%s'", u.PrimaryGroup)
+	}
+
+	if r.groups["group"] != "nogroup" {
+		t.Errorf("expected group to be reassigned to nogroup got '%s'", r.groups["group"])
+	}
+}
+
+func TestFlags(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.AddFlag("user", "siteop"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.AddFlag("user", "SiteOp"), nil)
+
+	if err := auth.AddFlag("user", "siteop"); err != ErrFlagExists {
+		t.Fatalf("expected ErrFlagExists got: %s", err)
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.HasFlag("siteop") {
+		t.Fatal("expected user to have siteop flag")
+	}
+
+	checkErr(t, auth.RemoveFlag("user", "siteop"), nil)
+
+	if err := auth.RemoveFlag("user", "siteop"); err != ErrFlagDoesntExist {
+		t.Fatalf("expected ErrFlagDoesntExist got: %s", err)
+	}
+}
+
+func TestIPs(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.AddIP("user", "*@127.0.0.1"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.AddIP("user", "127.0.0.1"), nil)
+
+	if err := auth.AddIP("user", "*@127.0.0.1"); err != ErrIPExists {
+		t.Fatalf("expected ErrIPExists got: %s", err)
+	}
+
+	if err := auth.AddIP("user", "@127.0.0.1"); err == nil {
+		t.Fatal("expected error adding bad mask")
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.MatchIP("", "127.0.0.1", nil) {
+		t.Error("expected user to match 127.0.0.1")
+	}
+
+	if u.MatchIP("", "127.0.0.2", nil) {
+		t.Error("expected user to not match 127.0.0.2")
+	}
+
+	checkErr(t, auth.RemoveIP("user", "*@127.0.0.1"), nil)
+
+	if err := auth.RemoveIP("user", "*@127.0.0.1"); err != ErrIPDoesntExist {
+		t.Fatalf("expected ErrIPDoesntExist got: %s", err)
+	}
+}
+
+func TestExpireUsers(t *testing.T) {
+	var tests = []struct {
+		action  string
+		flagged bool
+		deleted bool
+	}{
+		{ExpiredActionNone, false, false},
+		{ExpiredActionFlag, true, false},
+		{ExpiredActionPurge, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.action,
+			func(t *testing.T) {
+				auth, _ := newMemoryAuthenticator(t)
+				defer closeMemoryAuthenticator(t, auth)
+
+				auth.ExpiredAction = tt.action
+
+				for _, name := range []string{"expired", "active"} {
+					_, err := auth.AddUser(name, "pass")
+					checkErr(t, err, nil)
+				}
+
+				err := auth.UpdateUser("expired", func(u *User) error {
+					u.ExpiresAt = time.Now().Add(-time.Hour)
+					return nil
+				})
+				checkErr(t, err, nil)
+
+				err = auth.UpdateUser("active", func(u *User) error {
+					u.ExpiresAt = time.Now().Add(time.Hour)
+					return nil
+				})
+				checkErr(t, err, nil)
+
+				_, err = auth.ExpireUsers()
+				checkErr(t, err, nil)
+
+				u, err := auth.GetUser("expired")
+				if tt.deleted {
+					if err != ErrUserDoesntExist {
+						t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+					}
+				} else {
+					checkErr(t, err, nil)
+
+					if !u.Expired() {
+						t.Error("expected user to be expired")
+					}
+
+					if u.HasFlag(FlagExpired) != tt.flagged {
+						t.Errorf("expected flagged to be %t", tt.flagged)
+					}
+				}
+
+				u, err = auth.GetUser("active")
+				checkErr(t, err, nil)
+
+				if u.Expired() || u.HasFlag(FlagExpired) {
+					t.Error("expected active user to not be expired")
+				}
+			},
+		)
+	}
+}
+
+func TestRecordLogin(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if err := auth.RecordLogin("user", "", "127.0.0.1"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	for i := 0; i < MaxLoginHistory+5; i++ {
+		checkErr(t, auth.RecordLogin("user", "ident", fmt.Sprintf("127.0.0.%d", i)), nil)
+	}
+
+	history, err := auth.LoginHistory("user")
+	checkErr(t, err, nil)
+
+	if len(history) != MaxLoginHistory {
+		t.Fatalf("expected %d logins got %d", MaxLoginHistory, len(history))
+	}
+
+	if history[0].IP != fmt.Sprintf("127.0.0.%d", MaxLoginHistory+4) {
+		t.Errorf("expected newest login first got '%s'", history[0].IP)
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.Logins != MaxLoginHistory+5 {
+		t.Errorf("expected %d logins got %d", MaxLoginHistory+5, u.Logins)
+	}
+
+	if !u.LastLoginAt.Equal(history[0].At) {
+		t.Error("expected LastLoginAt to match newest login")
+	}
+}
+
+func TestCredits(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	if _, err := auth.IncrCredits("user", 1); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := auth.IncrCredits("user", 10); err != nil {
+				t.Errorf("unexpected error in IncrCredits: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	credits, err := auth.DecrCredits("user", 50, false)
+	checkErr(t, err, nil)
+
+	if credits != 150 {
+		t.Fatalf("expected 150 credits got %d", credits)
+	}
+
+	credits, err = auth.DecrCredits("user", 151, false)
+	if err != ErrNotEnoughCredits {
+		t.Fatalf("expected ErrNotEnoughCredits got: %s", err)
+	}
+
+	if credits != 150 {
+		t.Fatalf("expected credits to be unchanged got %d", credits)
+	}
+
+	credits, err = auth.DecrCredits("user", 151, true)
+	checkErr(t, err, nil)
+
+	if credits != -1 {
+		t.Fatalf("expected -1 credits got %d", credits)
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	users, err := auth.GetAllUsers()
+	checkErr(t, err, nil)
+
+	if len(users) != 0 {
+		t.Fatalf("expected no users got %d", len(users))
+	}
+
+	for _, name := range []string{"bob", "alice", "Bobby", "carol"} {
+		_, err := auth.AddUser(name, "pass")
+		checkErr(t, err, nil)
+	}
+
+	for _, name := range []string{"siteops", "friends", "site"} {
+		_, err := auth.AddGroup(name)
+		checkErr(t, err, nil)
+	}
+
+	var tests = []struct {
+		prefix   string
+		expected []string
+	}{
+		{"", []string{"alice", "bob", "Bobby", "carol"}},
+		{"bob", []string{"bob", "Bobby"}},
+		{"BOBB", []string{"Bobby"}},
+		{"dave", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			users, err := auth.GetUsersWithPrefix(tt.prefix)
+			checkErr(t, err, nil)
+
+			var names []string
+			for _, u := range users {
+				names = append(names, u.Name)
+			}
+
+			if fmt.Sprint(names) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected %v got %v", tt.expected, names)
+			}
+		})
+	}
+
+	groups, err := auth.GetAllGroups()
+	checkErr(t, err, nil)
+
+	if len(groups) != 3 || groups[0].Name != "friends" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+
+	groups, err = auth.GetGroupsWithPrefix("site")
+	checkErr(t, err, nil)
+
+	if len(groups) != 2 || groups[0].Name != "site" || groups[1].Name != "siteops" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+}
+
+func TestGroupMembership(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	if err := auth.AddUserToGroup("user", "group"); err != ErrGroupDoesntExist {
+		t.Fatalf("expected ErrGroupDoesntExist got: %s", err)
+	}
+
+	_, err = auth.AddGroup("group")
+	checkErr(t, err, nil)
+
+	_, err = auth.AddGroup("other")
+	checkErr(t, err, nil)
+
+	if err := auth.AddUserToGroup("nobody", "group"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	checkErr(t, auth.AddUserToGroup("user", "group"), nil)
+	checkErr(t, auth.AddUserToGroup("user", "other"), nil)
+
+	if err := auth.AddUserToGroup("user", "GROUP"); err != ErrUserInGroup {
+		t.Fatalf("expected ErrUserInGroup got: %s", err)
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.PrimaryGroup != "group" {
+		t.Fatalf("expected primary group to be 'group' got '%s'", u.PrimaryGroup)
+	}
+
+	g, err := auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if !g.HasMember("user") {
+		t.Fatal("expected user to be a member of group")
+	}
+
+	checkErr(t, auth.SetPrimaryGroup("user", "other"), nil)
+
+	checkErr(t, auth.RemoveUserFromGroup("user", "group"), nil)
+
+	if err := auth.RemoveUserFromGroup("user", "group"); err != ErrUserNotInGroup {
+		t.Fatalf("expected ErrUserNotInGroup got: %s", err)
+	}
+
+	if err := auth.SetPrimaryGroup("user", "group"); err != ErrUserNotInGroup {
+		t.Fatalf("expected ErrUserNotInGroup got: %s", err)
+	}
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.InGroup("group") || u.PrimaryGroup != "other" {
+		t.Fatalf("unexpected groups after removal: %v primary '%s'", u.Groups, u.PrimaryGroup)
+	}
+
+	g, err = auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if g.HasMember("user") {
+		t.Fatal("expected user to be removed from group members")
+	}
+
+	checkErr(t, auth.PurgeUser("user"), nil)
+
+	g, err = auth.GetGroup("other")
+	checkErr(t, err, nil)
+
+	if len(g.Members) != 0 {
+		t.Fatalf("expected deleted user to be removed from members got %v", g.MemberNames())
+	}
+}
+
+func TestGroupSlots(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	for _, name := range []string{"gadmin", "user", "leech", "other"} {
+		_, err := auth.AddUser(name, "pass")
+		checkErr(t, err, nil)
+	}
+
+	checkErr(t, auth.AddFlag("leech", FlagLeech), nil)
+
+	_, err := auth.AddGroup("group")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.AddUserToGroup("gadmin", "group"), nil)
+
+	if err := auth.GadminAddUserToGroup("gadmin", "user", "group"); err != ErrNotGroupAdmin {
+		t.Fatalf("expected ErrNotGroupAdmin got: %s", err)
+	}
+
+	checkErr(t, auth.UpdateUser("gadmin", func(u *User) error {
+		u.Groups["group"] = GroupSettings{IsAdmin: true}
+		return nil
+	}), nil)
+
+	if err := auth.GadminAddUserToGroup("gadmin", "user", "group"); err != ErrNoGadminSlots {
+		t.Fatalf("expected ErrNoGadminSlots got: %s", err)
+	}
+
+	if err := auth.GadminAddUserToGroup("gadmin", "leech", "group"); err != ErrNoLeechSlots {
+		t.Fatalf("expected ErrNoLeechSlots got: %s", err)
+	}
+
+	checkErr(t, auth.UpdateGroup("group", func(g *Group) error {
+		g.MaxMembers = 3
+		g.GadminSlots = 1
+		g.LeechSlots = 1
+		return nil
+	}), nil)
+
+	checkErr(t, auth.GadminAddUserToGroup("gadmin", "user", "group"), nil)
+	checkErr(t, auth.GadminAddUserToGroup("gadmin", "leech", "group"), nil)
+
+	g, err := auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if g.GadminSlots != 0 || g.LeechSlots != 0 {
+		t.Fatalf("expected slots to be used got %d gadmin %d leech", g.GadminSlots, g.LeechSlots)
+	}
+
+	if err := auth.AddUserToGroup("other", "group"); err != ErrGroupFull {
+		t.Fatalf("expected ErrGroupFull got: %s", err)
+	}
+}
@@ -0,0 +1,121 @@
+package acl
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BackupVersion is the version of the Backup format written by Export
+const BackupVersion = 1
+
+// Backup is every User and Group in an Authenticator
+type Backup struct {
+	Version   int
+	CreatedAt time.Time
+
+	// whether passwords and TOTP secrets were included
+	Credentials bool
+
+	Groups []*Group
+	Users  []*User
+}
+
+// exportBackup writes every User and Group to w as JSON. Unless credentials
+// is true, passwords and TOTP secrets are removed
+func exportBackup(a Authenticator, w io.Writer, credentials bool) error {
+	groups, err := a.GetAllGroups()
+	if err != nil {
+		return err
+	}
+
+	users, err := a.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	if !credentials {
+		for _, u := range users {
+			u.Password = nil
+			u.TOTPSecret = ""
+			u.TOTPLastStep = 0
+		}
+	}
+
+	backup := Backup{
+		Version:     BackupVersion,
+		CreatedAt:   time.Now(),
+		Credentials: credentials,
+		Groups:      groups,
+		Users:       users,
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(&backup)
+}
+
+// importBackup reads a Backup written by exportBackup from r, creating any
+// Users and Groups that do not exist and overwriting those that do. Users
+// in a Backup without credentials keep their existing password and TOTP
+// secret, new Users are unable to login until their password is changed
+func importBackup(a Authenticator, r io.Reader) error {
+	var backup Backup
+
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return errors.WithMessage(err, "error decoding backup")
+	}
+
+	if backup.Version != BackupVersion {
+		return errors.Errorf("unsupported backup version %d", backup.Version)
+	}
+
+	for _, g := range backup.Groups {
+		if _, err := a.AddGroup(g.Name); err != nil && err != ErrGroupExists {
+			return errors.WithMessagef(err, "group '%s'", g.Name)
+		}
+
+		err := a.UpdateGroup(g.Name, func(existing *Group) error {
+			*existing = *g
+			return nil
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "group '%s'", g.Name)
+		}
+	}
+
+	for _, u := range backup.Users {
+		var created bool
+
+		if _, err := a.AddUser(u.Name, ""); err == nil {
+			created = true
+		} else if err != ErrUserExists {
+			return errors.WithMessagef(err, "user '%s'", u.Name)
+		}
+
+		err := a.UpdateUser(u.Name, func(existing *User) error {
+			// new users are left with no password rather than the empty
+			// one given to AddUser
+			if !backup.Credentials {
+				if created {
+					u.RemoveFlag(FlagTOTP)
+				} else {
+					u.Password = existing.Password
+					u.TOTPSecret = existing.TOTPSecret
+					u.TOTPLastStep = existing.TOTPLastStep
+				}
+			}
+
+			*existing = *u
+			return nil
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "user '%s'", u.Name)
+		}
+	}
+
+	return nil
+}
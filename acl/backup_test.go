@@ -0,0 +1,85 @@
+package acl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestExportImport(t *testing.T) {
+	src, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, src)
+
+	_, err := src.AddGroup("group")
+	checkErr(t, err, nil)
+
+	_, err = src.AddUser("user", "password")
+	checkErr(t, err, nil)
+
+	checkErr(t, src.AddUserToGroup("user", "group"), nil)
+	checkErr(t, src.AddFlag("user", "siteop"), nil)
+	checkErr(t, src.AddIP("user", "*@127.0.0.1"), nil)
+
+	_, err = src.IncrCredits("user", 1024)
+	checkErr(t, err, nil)
+
+	var buf bytes.Buffer
+	checkErr(t, src.Export(&buf, true), nil)
+
+	dst, _ := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, dst)
+
+	checkErr(t, dst.Import(bytes.NewReader(buf.Bytes())), nil)
+
+	u, err := dst.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.HasFlag("siteop") || !u.InGroup("group") || u.PrimaryGroup != "group" || u.Credits != 1024 {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	if !u.MatchIP("ident", "127.0.0.1", nil) {
+		t.Errorf("unexpected ips: %+v", u.IPs)
+	}
+
+	if !dst.CheckPassword("user", "password") {
+		t.Error("expected password to be imported")
+	}
+
+	g, err := dst.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if !g.HasMember("user") {
+		t.Errorf("unexpected group: %+v", g)
+	}
+
+	// without credentials existing users keep their password
+	checkErr(t, src.ChangePassword("user", "changed"), nil)
+
+	_, err = src.AddUser("new", "password")
+	checkErr(t, err, nil)
+
+	buf.Reset()
+	checkErr(t, src.Export(&buf, false), nil)
+
+	if strings.Contains(buf.String(), "$2a$") {
+		t.Error("expected no password hashes in export")
+	}
+
+	checkErr(t, dst.Import(&buf), nil)
+
+	if !dst.CheckPassword("user", "password") {
+		t.Error("expected existing password to be kept")
+	}
+
+	for _, pass := range []string{"", "password"} {
+		if dst.CheckPassword("new", pass) {
+			t.Errorf("expected new user to have no password, '%s' accepted", pass)
+		}
+	}
+
+	err = dst.Import(strings.NewReader(`{"Version": 2}`))
+	checkErr(t, err, errors.New("unsupported backup version 2"))
+}
@@ -0,0 +1,156 @@
+package acl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+// entryCache is a read-through cache of decoded Users and Groups used by the
+// BadgerAuthenticator. Entries are copied in and out so callers can't modify
+// what is cached. Keys written in a transaction are invalidated once it is
+// committed
+type entryCache struct {
+	mtx     sync.Mutex
+	entries map[string]Entry
+
+	// incremented on every invalidation, an Entry read from the store is
+	// only cached if nothing was invalidated while it was being read
+	gen uint64
+
+	// keys written by each open transaction
+	pending map[*badger.Txn][]string
+}
+
+func newEntryCache() *entryCache {
+	return &entryCache{
+		entries: make(map[string]Entry, 0),
+		pending: make(map[*badger.Txn][]string, 0),
+	}
+}
+
+// generation returns the current generation, it should be read before
+// reading an Entry from the store and then passed to put
+func (c *entryCache) generation() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.gen
+}
+
+// get returns a copy of the cached Entry
+func (c *entryCache) get(key []byte) (Entry, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[string(key)]
+	if !ok {
+		return nil, false
+	}
+
+	return copyEntry(e), true
+}
+
+// put caches a copy of the Entry as long as nothing has been invalidated
+// since gen
+func (c *entryCache) put(e Entry, gen uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+
+	if e = copyEntry(e); e != nil {
+		c.entries[string(e.Key())] = e
+	}
+}
+
+// touch records that the key is written by the transaction
+func (c *entryCache) touch(tx *badger.Txn, key []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.pending[tx] = append(c.pending[tx], string(key))
+}
+
+// done invalidates the keys written by the transaction, it is called once
+// the transaction has been committed or discarded
+func (c *entryCache) done(tx *badger.Txn) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	keys, ok := c.pending[tx]
+	if !ok {
+		return
+	}
+
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+
+	delete(c.pending, tx)
+
+	c.gen++
+}
+
+// copyEntry deep copies Users and Groups, any other Entry isn't cached
+// and returns nil
+func copyEntry(e Entry) Entry {
+	switch v := e.(type) {
+	case *User:
+		return copyUser(v)
+	case *Group:
+		return copyGroup(v)
+	}
+	return nil
+}
+
+func copyUser(u *User) *User {
+	c := *u
+
+	if u.Password != nil {
+		c.Password = append([]byte(nil), u.Password...)
+	}
+
+	if u.Groups != nil {
+		c.Groups = make(map[string]GroupSettings, len(u.Groups))
+		for k, v := range u.Groups {
+			c.Groups[k] = v
+		}
+	}
+
+	if u.Flags != nil {
+		c.Flags = make(map[string]struct{}, len(u.Flags))
+		for k := range u.Flags {
+			c.Flags[k] = struct{}{}
+		}
+	}
+
+	if u.LoginHistory != nil {
+		c.LoginHistory = append([]Login(nil), u.LoginHistory...)
+	}
+
+	if u.IPs != nil {
+		c.IPs = make(map[string]time.Time, len(u.IPs))
+		for k, v := range u.IPs {
+			c.IPs[k] = v
+		}
+	}
+
+	return &c
+}
+
+func copyGroup(g *Group) *Group {
+	c := *g
+
+	if g.Members != nil {
+		c.Members = make(map[string]struct{}, len(g.Members))
+		for k := range g.Members {
+			c.Members[k] = struct{}{}
+		}
+	}
+
+	return &c
+}
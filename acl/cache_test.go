@@ -0,0 +1,80 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if _, ok := auth.cache.get(u.Key()); !ok {
+		t.Fatal("expected user to be cached")
+	}
+
+	// modifying a returned user doesn't change the cache
+	u.AddFlag("siteop")
+	u.Credits = 100
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.HasFlag("siteop") || u.Credits != 0 {
+		t.Fatalf("expected cached user to be unchanged: %+v", u)
+	}
+
+	// writes invalidate the cache
+	checkErr(t, auth.AddFlag("user", "siteop"), nil)
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.HasFlag("siteop") {
+		t.Fatal("expected flag after update")
+	}
+
+	_, err = auth.AddGroup("group")
+	checkErr(t, err, nil)
+
+	_, err = auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.AddUserToGroup("user", "group"), nil)
+
+	g, err := auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if !g.HasMember("user") {
+		t.Fatal("expected member after adding user to group")
+	}
+
+	checkErr(t, auth.DeleteGroup("group"), nil)
+
+	if _, err := auth.GetGroup("group"); err != ErrGroupDoesntExist {
+		t.Fatalf("expected ErrGroupDoesntExist got: %v", err)
+	}
+
+	checkErr(t, auth.PurgeUser("user"), nil)
+
+	if _, err := auth.GetUser("user"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %v", err)
+	}
+
+	// nothing is cached if a write happened during the read
+	gen := auth.cache.generation()
+
+	_, err = auth.AddUser("other", "pass")
+	checkErr(t, err, nil)
+
+	auth.cache.put(&User{Name: "other"}, gen)
+
+	if _, ok := auth.cache.get(User{Name: "other"}.Key()); ok {
+		t.Fatal("expected stale user not to be cached")
+	}
+}
@@ -0,0 +1,70 @@
+package acl
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CommandRule represents a permission for a command parsed from a config file
+type CommandRule struct {
+	command string
+	acl     *ACL
+}
+
+// NewCommandRule takes a line of text (i.e. from a config file) in the form
+// of `<command> <acl>` and performs some validation
+func NewCommandRule(line string) (CommandRule, error) {
+	var rule CommandRule
+
+	fields := strings.Fields(strings.ToLower(line))
+
+	if len(fields) < 2 {
+		return rule, errors.New("command rule requires minimum of 2 fields")
+	}
+
+	rule.command = fields[0]
+
+	acl, err := NewFromString(strings.Join(fields[1:], " "))
+	if err != nil {
+		return rule, err
+	}
+	rule.acl = acl
+
+	return rule, nil
+}
+
+// CommandPermissions is a snapshot of the current command permissions, these
+// are used for commands that aren't path based, i.e. SITE commands
+type CommandPermissions struct {
+	current map[string]*ACL
+}
+
+// NewCommandPermissions takes a slice of CommandRules and creates a way for callers
+// to check ACL for a given command. Later rules for the same command override earlier
+// ones
+func NewCommandPermissions(rules []CommandRule) (*CommandPermissions, error) {
+	p := CommandPermissions{
+		current: make(map[string]*ACL, 0),
+	}
+
+	for _, r := range rules {
+		p.current[r.command] = r.acl
+	}
+
+	return &p, nil
+}
+
+// Match checks to see if the User is allowed to use the command, defaults to no match
+func (p *CommandPermissions) Match(command string, user *User) bool {
+	if p == nil {
+		return false
+	}
+
+	a, ok := p.current[strings.ToLower(command)]
+	if !ok {
+		return false
+	}
+
+	return a.Match(user)
+}
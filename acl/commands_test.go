@@ -0,0 +1,67 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewCommandRule(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"change siteop", nil},
+		{"change", errors.New("command rule requires minimum of 2 fields")},
+		{"change -*", errors.New("bad user '*'")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewCommandRule(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestCommandPermissionsMatch(t *testing.T) {
+	var tests = []struct {
+		lines    []string
+		command  string
+		user     *User
+		expected bool
+	}{
+		{[]string{"change siteop"}, "CHANGE", newTestUserWithFlags("user", "siteop"), true},
+		{[]string{"change siteop"}, "change", newTestUser("user"), false},
+		{[]string{"change siteop"}, "wipe", newTestUserWithFlags("user", "siteop"), false},
+		{[]string{"change *", "change !*"}, "change", newTestUser("user"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.command,
+			func(t *testing.T) {
+				var rules []CommandRule
+				for _, l := range tt.lines {
+					r, err := NewCommandRule(l)
+					if err != nil {
+						t.Fatalf("unable to parse rule '%s': %s", l, err)
+					}
+					rules = append(rules, r)
+				}
+
+				p, err := NewCommandPermissions(rules)
+				if err != nil {
+					t.Fatalf("unable to create CommandPermissions: %s", err)
+				}
+
+				if got := p.Match(tt.command, tt.user); got != tt.expected {
+					t.Errorf("expected %t got %t", tt.expected, got)
+				}
+			},
+		)
+	}
+}
@@ -1,6 +1,10 @@
 package acl
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
 
 func newTestUser(name string, groups ...string) *User {
 	u := &User{
@@ -19,6 +23,16 @@ func newTestUser(name string, groups ...string) *User {
 	return u
 }
 
+func newTestUserWithFlags(name string, flags ...string) *User {
+	u := newTestUser(name)
+
+	for _, f := range flags {
+		u.AddFlag(f)
+	}
+
+	return u
+}
+
 func checkErr(t *testing.T, got, expected error) {
 	t.Helper()
 
@@ -69,3 +83,57 @@ func compareSlices(a, b []string) bool {
 
 	return true
 }
+
+// testReconciler records any reassignments made by the Authenticator
+type testReconciler struct {
+	users  map[string]string
+	groups map[string]string
+}
+
+func newTestReconciler() *testReconciler {
+	return &testReconciler{
+		users:  make(map[string]string, 0),
+		groups: make(map[string]string, 0),
+	}
+}
+
+func (r *testReconciler) ReassignUser(from, to string) error {
+	r.users[from] = to
+	return nil
+}
+
+func (r *testReconciler) ReassignGroup(from, to string) error {
+	r.groups[from] = to
+	return nil
+}
+
+func newMemoryAuthenticator(t *testing.T) (*BadgerAuthenticator, *testReconciler) {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	opts := AuthenticatorOpts{
+		OrphanUser:  "nobody",
+		OrphanGroup: "nogroup",
+	}
+
+	r := newTestReconciler()
+
+	auth := NewBadgerAuthenticator(&opts, db)
+	auth.SetReconciler(r)
+
+	return auth, r
+}
+
+func closeMemoryAuthenticator(t *testing.T, auth *BadgerAuthenticator) {
+	t.Helper()
+	if err := auth.db.Close(); err != nil {
+		t.Fatalf("error closing authenticator: %s", err)
+	}
+}
@@ -0,0 +1,153 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+const (
+	failuresKindUser = "user"
+	failuresKindIP   = "ip"
+)
+
+// Failures records the failed logins for a user or ip
+type Failures struct {
+	Kind string
+	Name string
+
+	Count       int
+	LastAt      time.Time
+	LockedUntil time.Time
+}
+
+// Used to satisfy the authenticator Entry interface
+func (f Failures) Key() []byte {
+	return []byte(fmt.Sprintf(
+		"failures:%s:%s",
+		f.Kind,
+		strings.ToLower(f.Name),
+	))
+}
+
+// Locked checks to see if the Failures are currently locked
+func (f *Failures) Locked() bool {
+	return time.Now().Before(f.LockedUntil)
+}
+
+// FailedLogin records a failed login for both the user and the ip, locking
+// either once they reach LockoutAttempts. Returns how long the reply to the
+// client should be delayed for
+func (a *BadgerAuthenticator) FailedLogin(name, ip string) (time.Duration, error) {
+	var count int
+
+	lockout := time.Duration(a.LockoutTime) * time.Second
+
+	err := a.update(func(tx *badger.Txn) error {
+		for _, f := range []*Failures{
+			{Kind: failuresKindUser, Name: name},
+			{Kind: failuresKindIP, Name: ip},
+		} {
+			if err := a.decode(tx, f.Key(), f); err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			f.Count++
+			f.LastAt = time.Now()
+
+			if a.LockoutAttempts > 0 && f.Count >= a.LockoutAttempts {
+				f.LockedUntil = f.LastAt.Add(lockout)
+			}
+
+			if f.Count > count {
+				count = f.Count
+			}
+
+			// failures are forgotten once there hasn't been one for
+			// the lockout time
+			if lockout > 0 {
+				if err := a.encodeWithTTL(tx, f, lockout); err != nil {
+					return err
+				}
+			} else {
+				if err := a.encode(tx, f); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(a.TarpitDelay*count) * time.Second, nil
+}
+
+// Locked checks to see if either the user or the ip are locked out
+func (a *BadgerAuthenticator) Locked(name, ip string) bool {
+	var locked bool
+
+	a.db.View(func(tx *badger.Txn) error {
+		for _, f := range []*Failures{
+			{Kind: failuresKindUser, Name: name},
+			{Kind: failuresKindIP, Name: ip},
+		} {
+			if err := a.decode(tx, f.Key(), f); err != nil {
+				continue
+			}
+
+			if f.Locked() {
+				locked = true
+			}
+		}
+
+		return nil
+	})
+
+	return locked
+}
+
+// ClearFailedLogins removes the failed logins of the user, the failed logins of
+// the ip are kept so that a valid account can't be used to reset them
+func (a *BadgerAuthenticator) ClearFailedLogins(name string) error {
+	return a.update(func(tx *badger.Txn) error {
+		return a.remove(tx, Failures{Kind: failuresKindUser, Name: name}.Key())
+	})
+}
+
+// Unlock removes any failed logins for the user or ip called name. Returns
+// true if anything was removed
+func (a *BadgerAuthenticator) Unlock(name string) (bool, error) {
+	var found bool
+
+	err := a.update(func(tx *badger.Txn) error {
+		for _, f := range []Failures{
+			{Kind: failuresKindUser, Name: name},
+			{Kind: failuresKindIP, Name: name},
+		} {
+			ok, err := a.exists(tx, f.Key())
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				continue
+			}
+
+			found = true
+
+			if err := a.remove(tx, f.Key()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return found, err
+}
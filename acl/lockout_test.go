@@ -0,0 +1,82 @@
+package acl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	auth.LockoutAttempts = 3
+	auth.LockoutTime = 60
+	auth.TarpitDelay = 1
+
+	for i := 1; i <= 3; i++ {
+		if auth.Locked("user", "1.2.3.4") {
+			t.Fatalf("expected not to be locked after %d attempts", i-1)
+		}
+
+		delay, err := auth.FailedLogin("user", "1.2.3.4")
+		checkErr(t, err, nil)
+
+		if delay != time.Duration(i)*time.Second {
+			t.Fatalf("expected delay of %ds got %s", i, delay)
+		}
+	}
+
+	if !auth.Locked("user", "1.2.3.4") {
+		t.Fatal("expected user to be locked")
+	}
+
+	if !auth.Locked("other", "1.2.3.4") {
+		t.Fatal("expected ip to be locked")
+	}
+
+	if !auth.Locked("user", "5.6.7.8") {
+		t.Fatal("expected user to be locked from another ip")
+	}
+
+	ok, err := auth.Unlock("user")
+	checkErr(t, err, nil)
+
+	if !ok {
+		t.Fatal("expected user to be unlocked")
+	}
+
+	if auth.Locked("user", "5.6.7.8") {
+		t.Fatal("expected user to no longer be locked")
+	}
+
+	ok, err = auth.Unlock("1.2.3.4")
+	checkErr(t, err, nil)
+
+	if !ok {
+		t.Fatal("expected ip to be unlocked")
+	}
+
+	if auth.Locked("user", "1.2.3.4") {
+		t.Fatal("expected ip to no longer be locked")
+	}
+
+	ok, err = auth.Unlock("1.2.3.4")
+	checkErr(t, err, nil)
+
+	if ok {
+		t.Fatal("expected nothing to unlock")
+	}
+
+	_, err = auth.FailedLogin("user", "1.2.3.4")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.ClearFailedLogins("user"), nil)
+
+	delay, err := auth.FailedLogin("user", "1.2.3.4")
+	checkErr(t, err, nil)
+
+	// the ip failures remain after clearing the user
+	if delay != 2*time.Second {
+		t.Fatalf("expected delay of 2s got %s", delay)
+	}
+}
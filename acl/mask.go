@@ -0,0 +1,268 @@
+package acl
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+// ErrMaskPolicy is returned when a user adding their own ident@ip mask
+// would break the configured mask policy
+var ErrMaskPolicy = errors.New("mask does not meet policy")
+
+// CheckMaskPolicy checks a user with existing masks can add the mask, that
+// they have fewer than MaxIPs and the ip isn't too broad. An ip needs at
+// least MinIPOctets octets before any wildcard, or a CIDR prefix of at
+// least as many bits. IPv6 is counted in groups of 16 bits. A hostname
+// needs as many labels after any wildcard, i.e. `*.example.com` has 2
+func (o *AuthenticatorOpts) CheckMaskPolicy(mask string, existing int) error {
+	if o == nil {
+		return nil
+	}
+
+	mask, err := ParseMask(mask)
+	if err != nil {
+		return err
+	}
+
+	if o.MaxIPs > 0 && existing >= o.MaxIPs {
+		return errors.WithMessagef(ErrMaskPolicy, "no more than %d masks", o.MaxIPs)
+	}
+
+	if o.MinIPOctets == 0 {
+		return nil
+	}
+
+	ip := strings.SplitN(mask, "@", 2)[1]
+
+	if strings.Contains(ip, "/") {
+		addr, network, err := net.ParseCIDR(ip)
+		if err != nil {
+			return err
+		}
+
+		ones, _ := network.Mask.Size()
+
+		bits := 8
+		if addr.To4() == nil {
+			bits = 16
+		}
+
+		if ones < o.MinIPOctets*bits {
+			return errors.WithMessagef(ErrMaskPolicy, "cidr needs a prefix of at least /%d", o.MinIPOctets*bits)
+		}
+
+		return nil
+	}
+
+	sep := "."
+	if strings.Contains(ip, ":") {
+		sep = ":"
+	}
+
+	parts := strings.Split(ip, sep)
+
+	// hostnames get broader to the left rather than the right
+	if hostnameMask(ip) {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+
+	var literal int
+
+	for _, part := range parts {
+		if len(part) == 0 || strings.ContainsAny(part, "*?[]{}") {
+			break
+		}
+		literal++
+	}
+
+	if literal < o.MinIPOctets {
+		if hostnameMask(ip) {
+			return errors.WithMessagef(ErrMaskPolicy, "needs at least %d labels after any wildcard", o.MinIPOctets)
+		}
+		return errors.WithMessagef(ErrMaskPolicy, "needs at least %d octets before any wildcard", o.MinIPOctets)
+	}
+
+	return nil
+}
+
+// ParseMask validates and normalises an ident@ip mask. If no ident is given
+// it is assumed to be any ident, i.e. `1.2.3.*` becomes `*@1.2.3.*`. The ip
+// part can be a glob or in CIDR notation, i.e. `*@10.0.0.0/8`. Wildcards
+// match across octets the same as glftpd, so `*@1.2.*` matches 1.2.3.4.
+// The ip part can also be a hostname glob, i.e. `*@*.example.com`, see
+// MatchMask
+func ParseMask(mask string) (string, error) {
+	mask = strings.ToLower(strings.TrimSpace(mask))
+
+	if len(mask) == 0 {
+		return "", errors.New("empty mask")
+	}
+
+	if !strings.Contains(mask, "@") {
+		mask = "*@" + mask
+	}
+
+	parts := strings.SplitN(mask, "@", 2)
+
+	if len(parts[0]) == 0 {
+		return "", errors.New("expected ident before '@'")
+	}
+
+	if len(parts[1]) == 0 {
+		return "", errors.New("expected ip after '@'")
+	}
+
+	if strings.Contains(parts[1], "/") {
+		if _, _, err := net.ParseCIDR(parts[1]); err != nil {
+			return "", errors.Errorf("bad cidr in mask: '%s'", parts[1])
+		}
+	} else if hostnameMask(parts[1]) {
+		invalid := strings.IndexFunc(parts[1], func(r rune) bool {
+			return !strings.ContainsRune(hostnameChars, r)
+		})
+
+		if _, err := glob.Compile(parts[1]); err != nil || invalid >= 0 {
+			return "", errors.Errorf("bad hostname in mask: '%s'", parts[1])
+		}
+	} else if _, err := glob.Compile(parts[1]); err != nil {
+		return "", errors.Errorf("bad ip in mask: '%s'", parts[1])
+	}
+
+	if _, err := glob.Compile(parts[0]); err != nil {
+		return "", errors.Errorf("bad ident in mask: '%s'", parts[0])
+	}
+
+	return mask, nil
+}
+
+// Hosts returns the confirmed hostnames of the address being matched, see
+// LookupHosts. It is only called for hostname masks so addresses are never
+// looked up for users who don't have any
+type Hosts func() []string
+
+// MatchMask checks to see if the ident and ip match the mask. An empty
+// ident only matches masks that accept any ident. Hostname masks match any
+// of the hosts, they never match when hosts is nil
+func MatchMask(mask, ident, ip string, hosts Hosts) bool {
+	mask, err := ParseMask(mask)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.SplitN(mask, "@", 2)
+
+	if parts[0] != "*" {
+		if len(ident) == 0 {
+			return false
+		}
+
+		g, err := glob.Compile(parts[0])
+		if err != nil || !g.Match(strings.ToLower(ident)) {
+			return false
+		}
+	}
+
+	if strings.Contains(parts[1], "/") {
+		_, network, err := net.ParseCIDR(parts[1])
+		if err != nil {
+			return false
+		}
+
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return false
+		}
+
+		return network.Contains(addr)
+	}
+
+	g, err := glob.Compile(parts[1])
+	if err != nil {
+		return false
+	}
+
+	if hostnameMask(parts[1]) {
+		if hosts == nil {
+			return false
+		}
+
+		for _, host := range hosts() {
+			if g.Match(strings.ToLower(host)) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return g.Match(strings.ToLower(ip))
+}
+
+// Resolver looks up hostnames, *net.Resolver implements it
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// LookupHosts returns the hostnames of the ip that resolve back to it.
+// Whoever controls the reverse zone of an ip can give it any name, so a
+// name from the reverse lookup is only kept once a forward lookup of it
+// confirms the ip, otherwise anyone could match `*@*.example.com`
+func LookupHosts(ctx context.Context, r Resolver, ip string) []string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+
+	names, err := r.LookupAddr(ctx, ip)
+	if err != nil {
+		return nil
+	}
+
+	var hosts []string
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+		ips, err := r.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+
+		for _, i := range ips {
+			if confirmed := net.ParseIP(i); confirmed != nil && confirmed.Equal(addr) {
+				hosts = append(hosts, name)
+				break
+			}
+		}
+	}
+
+	return hosts
+}
+
+// hostnameChars are those allowed in the hostname of a mask, along with
+// the glob syntax
+const hostnameChars = "abcdefghijklmnopqrstuvwxyz0123456789-._*?[]{},!"
+
+// hostnameMask checks to see if the ip part of a mask is a hostname rather
+// than an ip, i.e. `*.example.com`. IPv6 can have the letters a to f so only
+// ips without a ':' are checked
+func hostnameMask(ip string) bool {
+	if strings.Contains(ip, ":") {
+		return false
+	}
+
+	for _, r := range ip {
+		if r >= 'a' && r <= 'z' {
+			return true
+		}
+	}
+
+	return false
+}
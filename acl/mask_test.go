@@ -0,0 +1,229 @@
+package acl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseMask(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{"*@127.0.0.1", "*@127.0.0.1", nil},
+		{"1.2.3.*", "*@1.2.3.*", nil},
+		{"Ident@10.0.0.0/8", "ident@10.0.0.0/8", nil},
+		{"", "", errors.New("empty mask")},
+		{"@1.2.3.4", "", errors.New("expected ident before '@'")},
+		{"ident@", "", errors.New("expected ip after '@'")},
+		{"*@10.0.0.0/99", "", errors.New("bad cidr in mask: '10.0.0.0/99'")},
+		{"*@*.Example.com", "*@*.example.com", nil},
+		{"*@bad+host.com", "", errors.New("bad hostname in mask: 'bad+host.com'")},
+		{"*@*:ab::1", "*@*:ab::1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				mask, err := ParseMask(tt.input)
+				checkErr(t, err, tt.err)
+
+				if mask != tt.expected {
+					t.Errorf("expected '%s' got '%s'", tt.expected, mask)
+				}
+			},
+		)
+	}
+}
+
+func TestMatchMask(t *testing.T) {
+	var tests = []struct {
+		mask     string
+		ident    string
+		ip       string
+		expected bool
+	}{
+		{"*@127.0.0.1", "", "127.0.0.1", true},
+		{"*@127.0.0.1", "ident", "127.0.0.2", false},
+		{"*@1.2.*.4", "", "1.2.3.4", true},
+		{"*@1.2.*", "", "1.2.3.4", true},
+		{"*@1.2.*", "", "1.3.3.4", false},
+		{"*@*", "", "1.2.3.4", true},
+		{"*", "", "::1", true},
+		{"*@1.2.*.*", "", "1.2.3.4", true},
+		{"ident@1.2.3.4", "", "1.2.3.4", false},
+		{"ident@1.2.3.4", "IDENT", "1.2.3.4", true},
+		{"id*@1.2.3.4", "ident", "1.2.3.4", true},
+		{"*@10.0.0.0/8", "", "10.1.2.3", true},
+		{"*@10.0.0.0/8", "", "11.1.2.3", false},
+		{"*@::1", "", "::1", true},
+		{"*@*.example.com", "", "1.2.3.4", true},
+		{"*@*.example.com", "", "1.2.3.5", false},
+		{"*@host.example.org", "", "1.2.3.4", false},
+	}
+
+	hosts := func() []string { return []string{"host.example.com"} }
+
+	for _, tt := range tests {
+		t.Run(
+			tt.mask,
+			func(t *testing.T) {
+				matched := hosts
+				if tt.ip != "1.2.3.4" {
+					matched = nil
+				}
+
+				if got := MatchMask(tt.mask, tt.ident, tt.ip, matched); got != tt.expected {
+					t.Errorf("expected %t got %t for %s@%s", tt.expected, got, tt.ident, tt.ip)
+				}
+			},
+		)
+	}
+}
+
+func TestMaskPolicy(t *testing.T) {
+	opts := &AuthenticatorOpts{MaxIPs: 2, MinIPOctets: 2}
+
+	var tests = []struct {
+		mask     string
+		existing int
+		err      error
+	}{
+		{"*@1.2.3.*", 0, nil},
+		{"ident@1.2.*", 1, nil},
+		{"*@1.*", 0, ErrMaskPolicy},
+		{"*", 0, ErrMaskPolicy},
+		{"*@1.2.3.4", 2, ErrMaskPolicy},
+		{"*@10.1.0.0/16", 0, nil},
+		{"*@10.0.0.0/8", 0, ErrMaskPolicy},
+		{"*@2001:db8::/32", 0, nil},
+		{"*@2001:*", 0, ErrMaskPolicy},
+		{"*@10.0.0.0/99", 0, errors.New("bad cidr in mask: '10.0.0.0/99'")},
+		{"*@host.example.com", 0, nil},
+		{"*@*.example.com", 0, nil},
+		{"*@*.com", 0, ErrMaskPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.mask,
+			func(t *testing.T) {
+				err := opts.CheckMaskPolicy(tt.mask, tt.existing)
+				checkErr(t, err, tt.err)
+
+				if tt.err == ErrMaskPolicy && !errors.Is(err, ErrMaskPolicy) {
+					t.Errorf("expected ErrMaskPolicy got '%s'", err)
+				}
+			},
+		)
+	}
+
+	var none *AuthenticatorOpts
+	checkErr(t, none.CheckMaskPolicy("*", 100), nil)
+}
+
+func TestMatchIPHosts(t *testing.T) {
+	var lookups int
+
+	hosts := func() []string {
+		lookups++
+		return []string{"host.example.com"}
+	}
+
+	u := User{IPs: map[string]time.Time{"*@10.0.0.1": {}}}
+
+	if u.MatchIP("", "1.2.3.4", hosts) || lookups != 0 {
+		t.Fatalf("expected no match and no lookups without hostname masks, got %d lookups", lookups)
+	}
+
+	u.IPs["*@*.example.org"] = time.Time{}
+	u.IPs["*@*.example.net"] = time.Time{}
+
+	if u.MatchIP("", "1.2.3.4", hosts) || lookups != 1 {
+		t.Fatalf("expected no match and 1 lookup, got %d lookups", lookups)
+	}
+
+	u.IPs["*@*.example.com"] = time.Time{}
+
+	if !u.MatchIP("", "1.2.3.4", hosts) {
+		t.Fatal("expected the hostname mask to match")
+	}
+
+	if u.MatchIP("", "1.2.3.4", nil) {
+		t.Fatal("expected hostname masks not to match without hosts")
+	}
+}
+
+// testResolver resolves from maps, ips to names and names to ips
+type testResolver struct {
+	names map[string][]string
+	ips   map[string][]string
+}
+
+func (r testResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	names, ok := r.names[addr]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return names, nil
+}
+
+func (r testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+func TestLookupHosts(t *testing.T) {
+	r := testResolver{
+		names: map[string][]string{
+			"1.2.3.4":     {"Host.Example.com.", "alias.example.com."},
+			"5.6.7.8":     {"spoofed.example.com."},
+			"2001:db8::1": {"v6.example.com."},
+		},
+		ips: map[string][]string{
+			"host.example.com":    {"1.2.3.4"},
+			"alias.example.com":   {"9.9.9.9"},
+			"spoofed.example.com": {"1.2.3.4"},
+			"v6.example.com":      {"2001:db8:0::1"},
+		},
+	}
+
+	var tests = []struct {
+		ip       string
+		expected []string
+	}{
+		{"1.2.3.4", []string{"host.example.com"}},
+		// the reverse lookup isn't confirmed by the forward one
+		{"5.6.7.8", nil},
+		{"2001:db8::1", []string{"v6.example.com"}},
+		{"10.0.0.1", nil},
+		{"not an ip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.ip,
+			func(t *testing.T) {
+				got := LookupHosts(context.Background(), r, tt.ip)
+
+				if len(got) != len(tt.expected) {
+					t.Fatalf("expected %v got %v", tt.expected, got)
+				}
+
+				for i := range got {
+					if got[i] != tt.expected[i] {
+						t.Fatalf("expected %v got %v", tt.expected, got)
+					}
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,98 @@
+package acl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// GlftpdPasswordPrefix marks a password hash imported from glftpd, the hash
+// is replaced with bcrypt the first time the User logs in
+const GlftpdPasswordPrefix = "glftpd"
+
+const (
+	glftpdIterations = 100
+	glftpdKeyLen     = sha1.Size
+)
+
+// ErrPasswordPolicy is returned when a new password doesn't meet the
+// configured password policy
+var ErrPasswordPolicy = errors.New("password does not meet policy")
+
+// CheckPasswordPolicy checks a new password is at least PasswordMinLength
+// long and, with PasswordMixed, has both letters and digits
+func (o *AuthenticatorOpts) CheckPasswordPolicy(pass string) error {
+	if o == nil {
+		return nil
+	}
+
+	if len(pass) < o.PasswordMinLength {
+		return errors.WithMessagef(ErrPasswordPolicy, "must be at least %d characters", o.PasswordMinLength)
+	}
+
+	if !o.PasswordMixed {
+		return nil
+	}
+
+	var letter, digit bool
+
+	for _, r := range pass {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+
+	if !letter || !digit {
+		return errors.WithMessage(ErrPasswordPolicy, "must contain letters and digits")
+	}
+
+	return nil
+}
+
+// hashPassword hashes the password for storage
+func hashPassword(pass string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+}
+
+// comparePassword checks the password against a stored hash, returns true
+// as the second value if the hash is in a legacy format and should be replaced
+func comparePassword(hashed []byte, pass string) (bool, bool) {
+	if bytes.HasPrefix(hashed, []byte(GlftpdPasswordPrefix)) {
+		return checkGlftpdPassword(string(hashed[len(GlftpdPasswordPrefix):]), pass), true
+	}
+
+	return bcrypt.CompareHashAndPassword(hashed, []byte(pass)) == nil, false
+}
+
+// checkGlftpdPassword checks the password against a glftpd PBKDF2-SHA1 hash
+// in the form of $<hex salt>$<hex hash>
+func checkGlftpdPassword(hashed, pass string) bool {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 3 || len(parts[0]) != 0 {
+		return false
+	}
+
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[2])
+	if err != nil || len(expected) != glftpdKeyLen {
+		return false
+	}
+
+	key := pbkdf2.Key([]byte(pass), salt, glftpdIterations, glftpdKeyLen, sha1.New)
+
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
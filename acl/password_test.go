@@ -0,0 +1,70 @@
+package acl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func newGlftpdHash(pass, salt string) string {
+	s, _ := hex.DecodeString(salt)
+	key := pbkdf2.Key([]byte(pass), s, glftpdIterations, glftpdKeyLen, sha1.New)
+	return "$" + salt + "$" + hex.EncodeToString(key)
+}
+
+func TestCheckGlftpdPassword(t *testing.T) {
+	hash := newGlftpdHash("secret", "1a2b3c4d")
+
+	var tests = []struct {
+		hash     string
+		pass     string
+		expected bool
+	}{
+		{hash, "secret", true},
+		{hash, "wrong", false},
+		{"$1a2b3c4d", "secret", false},
+		{"$zz$" + hash[10:], "secret", false},
+		{"1a2b3c4d$" + hash[10:], "secret", false},
+	}
+
+	for _, tt := range tests {
+		if got := checkGlftpdPassword(tt.hash, tt.pass); got != tt.expected {
+			t.Errorf("expected %t for '%s' with '%s'", tt.expected, tt.hash, tt.pass)
+		}
+	}
+}
+
+func TestLegacyPasswordUpgrade(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	_, err := auth.AddUser("user", "unused")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.UpdateUser("user", func(u *User) error {
+		u.Password = []byte(GlftpdPasswordPrefix + newGlftpdHash("secret", "00ff00ff"))
+		return nil
+	}), nil)
+
+	if auth.CheckPassword("user", "wrong") {
+		t.Fatal("expected wrong password to fail")
+	}
+
+	if !auth.CheckPassword("user", "secret") {
+		t.Fatal("expected glftpd password to be accepted")
+	}
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if bytes.HasPrefix(u.Password, []byte(GlftpdPasswordPrefix)) {
+		t.Fatal("expected password to be upgraded")
+	}
+
+	if !auth.CheckPassword("user", "secret") {
+		t.Fatal("expected upgraded password to be accepted")
+	}
+}
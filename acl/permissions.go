@@ -51,10 +51,16 @@ func NewRule(line string) (Rule, error) {
 	return rule, nil
 }
 
+// Scope returns the PermissionScope the Rule applies to
+func (r Rule) Scope() PermissionScope { return r.scope }
+
 // Permissions is a snapshot of the current permissions. They are stored
 // as PermissionScope and then path
 type Permissions struct {
 	current map[PermissionScope][]Rule
+
+	// users with FlagAnonymous are only checked against these
+	anonymous *Permissions
 }
 
 // NewPermissions takes a slice of Rules and creates a way for callers to check ACL
@@ -83,9 +89,17 @@ func NewPermissions(rules []Rule) (*Permissions, error) {
 	return &p, nil
 }
 
+// SetAnonymous sets the Permissions used for users with FlagAnonymous, see
+// AnonymousRules
+func (p *Permissions) SetAnonymous(anonymous *Permissions) { p.anonymous = anonymous }
+
 // Match takes a scope a path and a *User and checks to see if they match any rules defaults
 // to no match
 func (p *Permissions) Match(scope PermissionScope, path string, user *User) bool {
+	if p.anonymous != nil && user.HasFlag(FlagAnonymous) {
+		return p.anonymous.Match(scope, path, user)
+	}
+
 	s, ok := p.current[scope]
 	if !ok {
 		// potential to return an error here
@@ -106,6 +120,10 @@ func (p *Permissions) Match(scope PermissionScope, path string, user *User) bool
 
 // MatchNoDefault takes a scope a path and a *User and checks to see if they match any rules
 func (p *Permissions) MatchNoDefault(scope PermissionScope, path string, user *User) (bool, bool) {
+	if p.anonymous != nil && user.HasFlag(FlagAnonymous) {
+		return p.anonymous.MatchNoDefault(scope, path, user)
+	}
+
 	s, ok := p.current[scope]
 	if !ok {
 		// potential to return an error here
@@ -21,8 +21,8 @@ func TestNewRule(t *testing.T) {
 				PermissionScopeDownload,
 				glob.MustCompile("/path/test/dir"),
 				&ACL{
-					collection{false, []string{"user"}, nil},
-					collection{true, nil, nil},
+					collection{false, []string{"user"}, nil, nil},
+					collection{true, nil, nil, nil},
 				},
 			},
 			nil,
@@ -34,8 +34,8 @@ func TestNewRule(t *testing.T) {
 				PermissionScopeDownload,
 				glob.MustCompile("/path/test/dir"),
 				&ACL{
-					collection{true, nil, nil},
-					collection{false, []string{"user"}, nil},
+					collection{true, nil, nil, nil},
+					collection{false, []string{"user"}, nil, nil},
 				},
 			},
 			nil,
@@ -212,3 +212,32 @@ func TestPermissionsCheck(t *testing.T) {
 		)
 	}
 }
+
+func TestRegisterPermissionScope(t *testing.T) {
+	_, err := NewRule("archive /** *")
+	checkErr(t, err, errors.New("unknown permission scope 'archive'"))
+
+	scope, err := RegisterPermissionScope("Archive")
+	checkErr(t, err, nil)
+	defer delete(StringToPermissionScope, string(scope))
+
+	if scope != "archive" {
+		t.Fatalf("expected scope 'archive' got '%s'", scope)
+	}
+
+	rule, err := NewRule("archive /** *")
+	checkErr(t, err, nil)
+
+	if rule.Scope() != scope {
+		t.Errorf("expected rule scope '%s' got '%s'", scope, rule.Scope())
+	}
+
+	_, err = RegisterPermissionScope("archive")
+	checkErr(t, err, errors.New("permission scope 'archive' already exists"))
+
+	_, err = RegisterPermissionScope("download")
+	checkErr(t, err, errors.New("permission scope 'download' already exists"))
+
+	_, err = RegisterPermissionScope("two words")
+	checkErr(t, err, errors.New("bad permission scope 'two words'"))
+}
@@ -0,0 +1,73 @@
+package acl
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAuthenticator is the backend used when none is configured
+const DefaultAuthenticator = "badger"
+
+// AuthenticatorFactory creates an Authenticator from the options. params holds
+// the value of every `auth <key> <value>` line so that backends can have their
+// own settings
+type AuthenticatorFactory func(opts *AuthenticatorOpts, params map[string]string) (Authenticator, error)
+
+// Reconcilable is implemented by Authenticators that can reassign ownership
+// of files when a User or Group is deleted
+type Reconcilable interface {
+	SetReconciler(Reconciler)
+}
+
+var (
+	factoriesMtx sync.RWMutex
+	factories    = make(map[string]AuthenticatorFactory)
+)
+
+// RegisterAuthenticator makes an Authenticator backend available by name for
+// use with `auth backend <name>`. It is intended to be called from init and
+// panics if the name is already registered or the factory is nil
+func RegisterAuthenticator(name string, factory AuthenticatorFactory) {
+	factoriesMtx.Lock()
+	defer factoriesMtx.Unlock()
+
+	if factory == nil {
+		panic("acl: RegisterAuthenticator factory is nil")
+	}
+
+	if _, ok := factories[name]; ok {
+		panic("acl: RegisterAuthenticator called twice for " + name)
+	}
+
+	factories[name] = factory
+}
+
+// NewAuthenticator creates an Authenticator using the registered backend
+func NewAuthenticator(name string, opts *AuthenticatorOpts, params map[string]string) (Authenticator, error) {
+	factoriesMtx.RLock()
+	factory, ok := factories[name]
+	factoriesMtx.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("unknown authenticator backend '%s'", name)
+	}
+
+	return factory(opts, params)
+}
+
+// Authenticators returns the sorted names of the registered backends
+func Authenticators() []string {
+	factoriesMtx.RLock()
+	defer factoriesMtx.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,59 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestRegisterAuthenticator(t *testing.T) {
+	var called bool
+
+	RegisterAuthenticator("test", func(opts *AuthenticatorOpts, params map[string]string) (Authenticator, error) {
+		called = params["test_option"] == "value"
+		return nil, nil
+	})
+
+	var found bool
+	for _, name := range Authenticators() {
+		if name == "test" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatal("expected test to be registered")
+	}
+
+	_, err := NewAuthenticator("test", &AuthenticatorOpts{}, map[string]string{"test_option": "value"})
+	checkErr(t, err, nil)
+
+	if !called {
+		t.Fatal("expected factory to be called with params")
+	}
+
+	if _, err := NewAuthenticator("unknown", &AuthenticatorOpts{}, nil); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic registering twice")
+		}
+	}()
+
+	RegisterAuthenticator("test", func(*AuthenticatorOpts, map[string]string) (Authenticator, error) { return nil, nil })
+}
+
+func TestBuiltinAuthenticators(t *testing.T) {
+	auth, err := NewAuthenticator("sql", &AuthenticatorOpts{SQLDriver: "sqlite", SQLDSN: ":memory:"}, nil)
+	checkErr(t, err, nil)
+
+	if _, ok := auth.(*SQLAuthenticator); !ok {
+		t.Fatalf("expected *SQLAuthenticator got %T", auth)
+	}
+
+	auth.(*SQLAuthenticator).Close()
+
+	if _, err := NewAuthenticator("sql", &AuthenticatorOpts{}, nil); err == nil {
+		t.Fatal("expected error with no sql options")
+	}
+}
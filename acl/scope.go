@@ -1,5 +1,11 @@
 package acl
 
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 // PermissionScope is an "enum" for the different filesystem permissions
 // supported.
 type PermissionScope string
@@ -17,19 +23,64 @@ const (
 	PermissionScopeHideUser                  = "hide_user"
 	PermissionScopeHideGroup                 = "hide_group"
 	PermissionScopePrivate                   = "private"
+	PermissionScopeSymlink                   = "symlink"
+
+	// hide removes matching entries from listings and refuses downloads
+	// for users the acl matches, i.e. `hide /**/.incomplete* !-admin *`
+	PermissionScopeHide = "hide"
+
+	// hideinwho hides sessions in matching paths from SITE WHO for users
+	// the acl matches, i.e. `hideinwho /staff/** !-admin *`
+	PermissionScopeHideInWho = "hideinwho"
+
+	// wipe allows SITE WIPE to remove matching paths and everything under
+	// them regardless of the delete scopes, i.e. `wipe /** -admin`
+	PermissionScopeWipe = "wipe"
+
+	// free leech scopes, these are consulted when accounting for a
+	// transfer. ratio_free means downloads do not consume credits and
+	// upload_free means uploads are not awarded any credits
+	PermissionScopeRatioFree  = "ratio_free"
+	PermissionScopeUploadFree = "upload_free"
 )
 
 var StringToPermissionScope = map[string]PermissionScope{
-	string(PermissionScopeDownload):  PermissionScopeDownload,
-	string(PermissionScopeUpload):    PermissionScopeUpload,
-	string(PermissionScopeRename):    PermissionScopeRename,
-	string(PermissionScopeRenameOwn): PermissionScopeRenameOwn,
-	string(PermissionScopeDelete):    PermissionScopeDelete,
-	string(PermissionScopeDeleteOwn): PermissionScopeDeleteOwn,
-	string(PermissionScopeResume):    PermissionScopeResume,
-	string(PermissionScopeResumeOwn): PermissionScopeResumeOwn,
-	string(PermissionScopeMakeDir):   PermissionScopeMakeDir,
-	string(PermissionScopeHideUser):  PermissionScopeHideUser,
-	string(PermissionScopeHideGroup): PermissionScopeHideGroup,
-	string(PermissionScopePrivate):   PermissionScopePrivate,
+	string(PermissionScopeDownload):   PermissionScopeDownload,
+	string(PermissionScopeUpload):     PermissionScopeUpload,
+	string(PermissionScopeRename):     PermissionScopeRename,
+	string(PermissionScopeRenameOwn):  PermissionScopeRenameOwn,
+	string(PermissionScopeDelete):     PermissionScopeDelete,
+	string(PermissionScopeDeleteOwn):  PermissionScopeDeleteOwn,
+	string(PermissionScopeResume):     PermissionScopeResume,
+	string(PermissionScopeResumeOwn):  PermissionScopeResumeOwn,
+	string(PermissionScopeMakeDir):    PermissionScopeMakeDir,
+	string(PermissionScopeHideUser):   PermissionScopeHideUser,
+	string(PermissionScopeHideGroup):  PermissionScopeHideGroup,
+	string(PermissionScopePrivate):    PermissionScopePrivate,
+	string(PermissionScopeSymlink):    PermissionScopeSymlink,
+	string(PermissionScopeHide):       PermissionScopeHide,
+	string(PermissionScopeHideInWho):  PermissionScopeHideInWho,
+	string(PermissionScopeWipe):       PermissionScopeWipe,
+	string(PermissionScopeRatioFree):  PermissionScopeRatioFree,
+	string(PermissionScopeUploadFree): PermissionScopeUploadFree,
+}
+
+// RegisterPermissionScope adds a scope that acl rules can use, i.e. by a
+// plugin. Has to be called before the config is parsed, fails if the scope
+// already exists
+func RegisterPermissionScope(name string) (PermissionScope, error) {
+	name = strings.ToLower(name)
+
+	if len(name) == 0 || strings.ContainsAny(name, " \t") {
+		return "", errors.Errorf("bad permission scope '%s'", name)
+	}
+
+	if _, ok := StringToPermissionScope[name]; ok {
+		return "", errors.Errorf("permission scope '%s' already exists", name)
+	}
+
+	scope := PermissionScope(name)
+	StringToPermissionScope[name] = scope
+
+	return scope, nil
 }
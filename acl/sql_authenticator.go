@@ -0,0 +1,1160 @@
+package acl
+
+import (
+	"database/sql"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sqlDialect describes the differences between the supported databases
+type sqlDialect struct {
+	// placeholder returns the nth (1 based) bind parameter
+	placeholder func(int) string
+	// appended to selects of rows that will be updated in the same transaction
+	forUpdate string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		forUpdate:   " FOR UPDATE",
+	},
+	"mysql": {
+		placeholder: func(int) string { return "?" },
+		forUpdate:   " FOR UPDATE",
+	},
+	// sqlite locks the whole database on write
+	"sqlite": {
+		placeholder: func(int) string { return "?" },
+	},
+}
+
+// sqlMigrations are applied in order, each one is recorded in ftp_schema
+// once applied. Never edit an existing migration, add a new one
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE ftp_users (
+			name_key VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			password VARCHAR(255) NOT NULL,
+			primary_group VARCHAR(255) NOT NULL DEFAULT '',
+			credits BIGINT NOT NULL DEFAULT 0,
+			logins INTEGER NOT NULL DEFAULT 0,
+			uploads INTEGER NOT NULL DEFAULT 0,
+			downloads INTEGER NOT NULL DEFAULT 0,
+			created_at BIGINT NOT NULL DEFAULT 0,
+			last_login_at BIGINT NOT NULL DEFAULT 0,
+			deleted_at BIGINT NOT NULL DEFAULT 0,
+			expires_at BIGINT NOT NULL DEFAULT 0,
+			totp_secret VARCHAR(255) NOT NULL DEFAULT '',
+			totp_last_step BIGINT NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE ftp_groups (
+			name_key VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			max_members INTEGER NOT NULL DEFAULT 0,
+			gadmin_slots INTEGER NOT NULL DEFAULT 0,
+			leech_slots INTEGER NOT NULL DEFAULT 0,
+			added_at BIGINT NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE ftp_user_groups (
+			user_key VARCHAR(255) NOT NULL,
+			group_key VARCHAR(255) NOT NULL,
+			group_name VARCHAR(255) NOT NULL,
+			is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+			added_at BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_key, group_key)
+		)`,
+		`CREATE INDEX ftp_user_groups_group_key ON ftp_user_groups (group_key)`,
+		`CREATE TABLE ftp_user_flags (
+			user_key VARCHAR(255) NOT NULL,
+			flag VARCHAR(255) NOT NULL,
+			PRIMARY KEY (user_key, flag)
+		)`,
+		`CREATE TABLE ftp_user_ips (
+			user_key VARCHAR(255) NOT NULL,
+			mask VARCHAR(255) NOT NULL,
+			added_at BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_key, mask)
+		)`,
+		`CREATE TABLE ftp_user_logins (
+			user_key VARCHAR(255) NOT NULL,
+			idx INTEGER NOT NULL,
+			logged_at BIGINT NOT NULL,
+			ip VARCHAR(255) NOT NULL,
+			ident VARCHAR(255) NOT NULL,
+			PRIMARY KEY (user_key, idx)
+		)`,
+		`CREATE TABLE ftp_failures (
+			kind VARCHAR(16) NOT NULL,
+			name_key VARCHAR(255) NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_at BIGINT NOT NULL,
+			locked_until BIGINT NOT NULL,
+			PRIMARY KEY (kind, name_key)
+		)`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN ratio INTEGER NOT NULL DEFAULT 0`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN home_dir VARCHAR(1024) NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN idle_time INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE ftp_users ADD COLUMN template VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN tagline VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE ftp_users ADD COLUMN max_logins INTEGER NOT NULL DEFAULT 0`,
+	},
+	{
+		`ALTER TABLE ftp_groups ADD COLUMN description VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN comment VARCHAR(255) NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE ftp_users ADD COLUMN max_uploads INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE ftp_users ADD COLUMN max_downloads INTEGER NOT NULL DEFAULT 0`,
+	},
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	Query(string, ...interface{}) (*sql.Rows, error)
+	QueryRow(string, ...interface{}) *sql.Row
+}
+
+// SQLAuthenticator implements an Authenticator using a database/sql database
+// so that users can be shared with other applications, i.e. a web frontend
+type SQLAuthenticator struct {
+	*AuthenticatorOpts
+	db         *sql.DB
+	dialect    sqlDialect
+	reconciler Reconciler
+
+	// serialises read-modify-write transactions
+	mtx sync.Mutex
+}
+
+// NewSQLAuthenticator takes in options, a database and the name of its dialect
+// (postgres, mysql or sqlite) and returns a new SQLAuthenticator which implements
+// the Authenticator interface. Any outstanding schema migrations are applied
+func NewSQLAuthenticator(opts *AuthenticatorOpts, db *sql.DB, dialect string) (*SQLAuthenticator, error) {
+	d, ok := sqlDialects[dialect]
+	if !ok {
+		return nil, errors.Errorf("unknown sql dialect '%s'", dialect)
+	}
+
+	a := &SQLAuthenticator{
+		AuthenticatorOpts: opts,
+		db:                db,
+		dialect:           d,
+	}
+
+	if err := a.migrate(); err != nil {
+		return nil, errors.WithMessage(err, "unable to migrate schema")
+	}
+
+	return a, nil
+}
+
+func init() {
+	RegisterAuthenticator("sql", func(opts *AuthenticatorOpts, params map[string]string) (Authenticator, error) {
+		if len(opts.SQLDriver) == 0 || len(opts.SQLDSN) == 0 {
+			return nil, errors.New("`auth sql_driver` and `auth sql_dsn` required for the sql backend")
+		}
+
+		db, err := sql.Open(opts.SQLDriver, opts.SQLDSN)
+		if err != nil {
+			return nil, err
+		}
+
+		auth, err := NewSQLAuthenticator(opts, db, opts.SQLDriver)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return auth, nil
+	})
+}
+
+// SetReconciler sets the Reconciler used to reassign ownership on deletes
+func (a *SQLAuthenticator) SetReconciler(r Reconciler) { a.reconciler = r }
+
+// Close closes the underlying database
+func (a *SQLAuthenticator) Close() error { return a.db.Close() }
+
+// migrate applies any migrations that haven't been applied yet
+func (a *SQLAuthenticator) migrate() error {
+	if _, err := a.db.Exec(`CREATE TABLE IF NOT EXISTS ftp_schema (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+
+	if err := a.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM ftp_schema`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		err := a.tx(func(tx *sql.Tx) error {
+			for _, stmt := range sqlMigrations[i] {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+
+			return a.exec(tx, `INSERT INTO ftp_schema (version) VALUES (?)`, i+1)
+		})
+
+		if err != nil {
+			return errors.WithMessagef(err, "migration %d", i+1)
+		}
+	}
+
+	return nil
+}
+
+// rebind replaces ? with the dialect's placeholders
+func (a *SQLAuthenticator) rebind(query string) string {
+	var b strings.Builder
+
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(a.dialect.placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func (a *SQLAuthenticator) exec(q querier, query string, args ...interface{}) error {
+	_, err := q.Exec(a.rebind(query), args...)
+	return err
+}
+
+func (a *SQLAuthenticator) query(q querier, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.Query(a.rebind(query), args...)
+}
+
+func (a *SQLAuthenticator) queryRow(q querier, query string, args ...interface{}) *sql.Row {
+	return q.QueryRow(a.rebind(query), args...)
+}
+
+// tx runs fn in a transaction, committing if fn returns nil
+func (a *SQLAuthenticator) tx(fn func(*sql.Tx) error) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// exists checks to see if the query returns a row
+func (a *SQLAuthenticator) exists(q querier, query string, args ...interface{}) (bool, error) {
+	var one int
+
+	if err := a.queryRow(q, query, args...).Scan(&one); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (a *SQLAuthenticator) userExists(q querier, name string) (bool, error) {
+	return a.exists(q, `SELECT 1 FROM ftp_users WHERE name_key = ?`, sqlKey(name))
+}
+
+func (a *SQLAuthenticator) groupExists(q querier, name string) (bool, error) {
+	return a.exists(q, `SELECT 1 FROM ftp_groups WHERE name_key = ?`, sqlKey(name))
+}
+
+// loadUser reads the User and all of their related rows, if lock is true the
+// User's row is locked until the end of the transaction
+func (a *SQLAuthenticator) loadUser(q querier, name string, lock bool) (*User, error) {
+	key := sqlKey(name)
+
+	query := `SELECT name, password, primary_group, credits, ratio, logins, uploads, downloads,
+		created_at, last_login_at, deleted_at, expires_at, totp_secret, totp_last_step, home_dir,
+		idle_time, template, tagline, max_logins, comment, max_uploads, max_downloads FROM ftp_users
+		WHERE name_key = ?`
+
+	if lock {
+		query += a.dialect.forUpdate
+	}
+
+	var (
+		u                                    User
+		password                             string
+		created, lastLogin, deleted, expires int64
+	)
+
+	err := a.queryRow(q, query, key).Scan(
+		&u.Name, &password, &u.PrimaryGroup, &u.Credits, &u.Ratio, &u.Logins, &u.Uploads, &u.Downloads,
+		&created, &lastLogin, &deleted, &expires, &u.TOTPSecret, &u.TOTPLastStep, &u.HomeDir,
+		&u.IdleTime, &u.Template, &u.Tagline, &u.MaxLogins, &u.Comment, &u.MaxUploads, &u.MaxDownloads,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserDoesntExist
+		}
+		return nil, err
+	}
+
+	u.Password = []byte(password)
+	u.CreatedAt = fromUnix(created)
+	u.LastLoginAt = fromUnix(lastLogin)
+	u.DeletedAt = fromUnix(deleted)
+	u.ExpiresAt = fromUnix(expires)
+
+	err = a.scanRows(q, func(rows *sql.Rows) error {
+		var (
+			group   string
+			isAdmin bool
+			added   int64
+		)
+
+		if err := rows.Scan(&group, &isAdmin, &added); err != nil {
+			return err
+		}
+
+		if u.Groups == nil {
+			u.Groups = make(map[string]GroupSettings)
+		}
+
+		u.Groups[group] = GroupSettings{IsAdmin: isAdmin, AddedAt: fromUnix(added)}
+
+		return nil
+	}, `SELECT group_name, is_admin, added_at FROM ftp_user_groups WHERE user_key = ?`, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.scanRows(q, func(rows *sql.Rows) error {
+		var flag string
+
+		if err := rows.Scan(&flag); err != nil {
+			return err
+		}
+
+		u.AddFlag(flag)
+
+		return nil
+	}, `SELECT flag FROM ftp_user_flags WHERE user_key = ?`, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.scanRows(q, func(rows *sql.Rows) error {
+		var (
+			mask  string
+			added int64
+		)
+
+		if err := rows.Scan(&mask, &added); err != nil {
+			return err
+		}
+
+		if u.IPs == nil {
+			u.IPs = make(map[string]time.Time)
+		}
+
+		u.IPs[mask] = fromUnix(added)
+
+		return nil
+	}, `SELECT mask, added_at FROM ftp_user_ips WHERE user_key = ?`, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.scanRows(q, func(rows *sql.Rows) error {
+		var (
+			l  Login
+			at int64
+		)
+
+		if err := rows.Scan(&at, &l.IP, &l.Ident); err != nil {
+			return err
+		}
+
+		l.At = fromUnix(at)
+
+		u.LoginHistory = append(u.LoginHistory, l)
+
+		return nil
+	}, `SELECT logged_at, ip, ident FROM ftp_user_logins WHERE user_key = ? ORDER BY idx`, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// storeUser writes the User and replaces all of their related rows, if insert
+// is true a new row is created for the User
+func (a *SQLAuthenticator) storeUser(q querier, u *User, insert bool) error {
+	key := sqlKey(u.Name)
+
+	args := []interface{}{
+		u.Name, string(u.Password), u.PrimaryGroup, u.Credits, u.Ratio, u.Logins, u.Uploads, u.Downloads,
+		toUnix(u.CreatedAt), toUnix(u.LastLoginAt), toUnix(u.DeletedAt), toUnix(u.ExpiresAt),
+		u.TOTPSecret, u.TOTPLastStep, u.HomeDir, u.IdleTime, u.Template, u.Tagline, u.MaxLogins, u.Comment,
+		u.MaxUploads, u.MaxDownloads, key,
+	}
+
+	var err error
+
+	if insert {
+		err = a.exec(q, `INSERT INTO ftp_users (name, password, primary_group, credits, ratio, logins,
+			uploads, downloads, created_at, last_login_at, deleted_at, expires_at, totp_secret,
+			totp_last_step, home_dir, idle_time, template, tagline, max_logins, comment, max_uploads,
+			max_downloads, name_key)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	} else {
+		err = a.exec(q, `UPDATE ftp_users SET name = ?, password = ?, primary_group = ?, credits = ?,
+			ratio = ?, logins = ?, uploads = ?, downloads = ?, created_at = ?, last_login_at = ?, deleted_at = ?,
+			expires_at = ?, totp_secret = ?, totp_last_step = ?, home_dir = ?,
+			idle_time = ?, template = ?, tagline = ?, max_logins = ?, comment = ?, max_uploads = ?,
+			max_downloads = ? WHERE name_key = ?`, args...)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.deleteUserRows(q, key); err != nil {
+		return err
+	}
+
+	for group, settings := range u.Groups {
+		err := a.exec(q,
+			`INSERT INTO ftp_user_groups (user_key, group_key, group_name, is_admin, added_at) VALUES (?, ?, ?, ?, ?)`,
+			key, sqlKey(group), group, settings.IsAdmin, toUnix(settings.AddedAt),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for flag := range u.Flags {
+		if err := a.exec(q, `INSERT INTO ftp_user_flags (user_key, flag) VALUES (?, ?)`, key, flag); err != nil {
+			return err
+		}
+	}
+
+	for mask, added := range u.IPs {
+		if err := a.exec(q, `INSERT INTO ftp_user_ips (user_key, mask, added_at) VALUES (?, ?, ?)`, key, mask, toUnix(added)); err != nil {
+			return err
+		}
+	}
+
+	for i, l := range u.LoginHistory {
+		err := a.exec(q,
+			`INSERT INTO ftp_user_logins (user_key, idx, logged_at, ip, ident) VALUES (?, ?, ?, ?, ?)`,
+			key, i, toUnix(l.At), l.IP, l.Ident,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteUserRows removes the rows related to the User
+func (a *SQLAuthenticator) deleteUserRows(q querier, key string) error {
+	for _, table := range []string{"ftp_user_groups", "ftp_user_flags", "ftp_user_ips", "ftp_user_logins"} {
+		if err := a.exec(q, `DELETE FROM `+table+` WHERE user_key = ?`, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGroup reads the Group and its members, if lock is true the Group's row
+// is locked until the end of the transaction
+func (a *SQLAuthenticator) loadGroup(q querier, name string, lock bool) (*Group, error) {
+	key := sqlKey(name)
+
+	query := `SELECT name, description, max_members, gadmin_slots, leech_slots, added_at FROM ftp_groups
+		WHERE name_key = ?`
+
+	if lock {
+		query += a.dialect.forUpdate
+	}
+
+	var (
+		g     Group
+		added int64
+	)
+
+	if err := a.queryRow(q, query, key).Scan(&g.Name, &g.Description, &g.MaxMembers, &g.GadminSlots, &g.LeechSlots, &added); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGroupDoesntExist
+		}
+		return nil, err
+	}
+
+	g.AddedAt = fromUnix(added)
+
+	err := a.scanRows(q, func(rows *sql.Rows) error {
+		var user string
+
+		if err := rows.Scan(&user); err != nil {
+			return err
+		}
+
+		g.addMember(user)
+
+		return nil
+	}, `SELECT user_key FROM ftp_user_groups WHERE group_key = ?`, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &g, nil
+}
+
+// storeGroup writes the Group, members are stored with the Users so are
+// ignored. If insert is true a new row is created for the Group
+func (a *SQLAuthenticator) storeGroup(q querier, g *Group, insert bool) error {
+	args := []interface{}{g.Name, g.Description, g.MaxMembers, g.GadminSlots, g.LeechSlots, toUnix(g.AddedAt), sqlKey(g.Name)}
+
+	if insert {
+		return a.exec(q, `INSERT INTO ftp_groups (name, description, max_members, gadmin_slots, leech_slots,
+			added_at, name_key) VALUES (?, ?, ?, ?, ?, ?, ?)`, args...)
+	}
+
+	return a.exec(q, `UPDATE ftp_groups SET name = ?, description = ?, max_members = ?, gadmin_slots = ?,
+		leech_slots = ?, added_at = ? WHERE name_key = ?`, args...)
+}
+
+// scanRows runs the query and calls fn for each row
+func (a *SQLAuthenticator) scanRows(q querier, fn func(*sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := a.query(q, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// keys returns the name_key of all rows in the table starting with prefix
+func (a *SQLAuthenticator) keys(q querier, table, prefix string) ([]string, error) {
+	var keys []string
+
+	err := a.scanRows(q, func(rows *sql.Rows) error {
+		var key string
+
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+
+		return nil
+	}, `SELECT name_key FROM `+table+` WHERE name_key LIKE ? ESCAPE '!'`, sqlLikePrefix(prefix))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// collation differs between databases, sort so the order is the same
+	// as the other Authenticators
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// AddUser creates a user setting the password
+func (a *SQLAuthenticator) AddUser(name, pass string) (*User, error) {
+	// hash password
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &User{}
+
+	u.Name = name
+	u.Password = hashed
+	u.CreatedAt = time.Now()
+
+	err = a.tx(func(tx *sql.Tx) error {
+		ok, err := a.userExists(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return ErrUserExists
+		}
+
+		return a.storeUser(tx, u, true)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// AddGroup creates a Group
+func (a *SQLAuthenticator) AddGroup(name string) (*Group, error) {
+	g := &Group{}
+
+	g.Name = name
+	g.AddedAt = time.Now()
+
+	err := a.tx(func(tx *sql.Tx) error {
+		ok, err := a.groupExists(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return ErrGroupExists
+		}
+
+		return a.storeGroup(tx, g, true)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// GetUser attempts to retrieve a User from the store using the name
+func (a *SQLAuthenticator) GetUser(name string) (*User, error) {
+	return a.loadUser(a.db, name, false)
+}
+
+// GetGroup attempts to retrieve a Group from the store using the name
+func (a *SQLAuthenticator) GetGroup(name string) (*Group, error) {
+	return a.loadGroup(a.db, name, false)
+}
+
+// GetAllUsers returns every User ordered by name
+func (a *SQLAuthenticator) GetAllUsers() ([]*User, error) {
+	return a.GetUsersWithPrefix("")
+}
+
+// GetAllGroups returns every Group ordered by name
+func (a *SQLAuthenticator) GetAllGroups() ([]*Group, error) {
+	return a.GetGroupsWithPrefix("")
+}
+
+// GetUsersWithPrefix returns all Users whose name starts with prefix (case
+// insensitive) ordered by name
+func (a *SQLAuthenticator) GetUsersWithPrefix(prefix string) ([]*User, error) {
+	keys, err := a.keys(a.db, "ftp_users", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*User
+
+	for _, key := range keys {
+		u, err := a.loadUser(a.db, key, false)
+		if err != nil {
+			// deleted since listing
+			if err == ErrUserDoesntExist {
+				continue
+			}
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// GetGroupsWithPrefix returns all Groups whose name starts with prefix (case
+// insensitive) ordered by name
+func (a *SQLAuthenticator) GetGroupsWithPrefix(prefix string) ([]*Group, error) {
+	keys, err := a.keys(a.db, "ftp_groups", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*Group
+
+	for _, key := range keys {
+		g, err := a.loadGroup(a.db, key, false)
+		if err != nil {
+			// deleted since listing
+			if err == ErrGroupDoesntExist {
+				continue
+			}
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, nil
+}
+
+// SaveUser saves an existing User
+func (a *SQLAuthenticator) SaveUser(user *User) error {
+	return a.tx(func(tx *sql.Tx) error {
+		ok, err := a.userExists(tx, user.Name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrUserDoesntExist
+		}
+
+		return a.storeUser(tx, user, false)
+	})
+}
+
+// SaveGroup saves an existing Group
+func (a *SQLAuthenticator) SaveGroup(group *Group) error {
+	return a.tx(func(tx *sql.Tx) error {
+		ok, err := a.groupExists(tx, group.Name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrGroupDoesntExist
+		}
+
+		return a.storeGroup(tx, group, false)
+	})
+}
+
+// UpdateUser performs a read-modify-write on the User in a single transaction,
+// if fn returns an error the User is not saved
+func (a *SQLAuthenticator) UpdateUser(name string, fn func(*User) error) error {
+	return a.tx(func(tx *sql.Tx) error {
+		u, err := a.loadUser(tx, name, true)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+
+		return a.storeUser(tx, u, false)
+	})
+}
+
+// UpdateGroup performs a read-modify-write on the Group in a single transaction,
+// if fn returns an error the Group is not saved
+func (a *SQLAuthenticator) UpdateGroup(name string, fn func(*Group) error) error {
+	return a.tx(func(tx *sql.Tx) error {
+		g, err := a.loadGroup(tx, name, true)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(g); err != nil {
+			return err
+		}
+
+		return a.storeGroup(tx, g, false)
+	})
+}
+
+// PurgeUser removes the User from the store and from the members of
+// their Groups. Any files owned by the User are reassigned to the OrphanUser
+func (a *SQLAuthenticator) PurgeUser(name string) error {
+	err := a.tx(func(tx *sql.Tx) error {
+		ok, err := a.userExists(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrUserDoesntExist
+		}
+
+		if err := a.deleteUserRows(tx, sqlKey(name)); err != nil {
+			return err
+		}
+
+		return a.exec(tx, `DELETE FROM ftp_users WHERE name_key = ?`, sqlKey(name))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if a.reconciler != nil {
+		if err := a.reconciler.ReassignUser(name, a.OrphanUser); err != nil {
+			return errors.WithMessage(err, "unable to reassign user ownership")
+		}
+	}
+
+	return nil
+}
+
+// DeleteGroup removes the Group from the store and removes it from
+// any Users. Any files owned by the Group are reassigned to the OrphanGroup
+func (a *SQLAuthenticator) DeleteGroup(name string) error {
+	err := a.tx(func(tx *sql.Tx) error {
+		ok, err := a.groupExists(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrGroupDoesntExist
+		}
+
+		if err := a.exec(tx, `DELETE FROM ftp_groups WHERE name_key = ?`, sqlKey(name)); err != nil {
+			return err
+		}
+
+		if err := a.exec(tx, `UPDATE ftp_users SET primary_group = '' WHERE LOWER(primary_group) = ?`, sqlKey(name)); err != nil {
+			return err
+		}
+
+		return a.exec(tx, `DELETE FROM ftp_user_groups WHERE group_key = ?`, sqlKey(name))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if a.reconciler != nil {
+		if err := a.reconciler.ReassignGroup(name, a.OrphanGroup); err != nil {
+			return errors.WithMessage(err, "unable to reassign group ownership")
+		}
+	}
+
+	return nil
+}
+
+// updateMembership loads the User and Group in a transaction and calls fn, if
+// fn is successful both are saved
+func (a *SQLAuthenticator) updateMembership(user, group string, fn func(*sql.Tx, *User, *Group) error) error {
+	return a.tx(func(tx *sql.Tx) error {
+		u, err := a.loadUser(tx, user, true)
+		if err != nil {
+			return err
+		}
+
+		g, err := a.loadGroup(tx, group, true)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tx, u, g); err != nil {
+			return err
+		}
+
+		if err := a.storeUser(tx, u, false); err != nil {
+			return err
+		}
+
+		return a.storeGroup(tx, g, false)
+	})
+}
+
+// AddUserToGroup adds the User to the Group in the same transaction. If the
+// User has no PrimaryGroup then it is set to the Group
+func (a *SQLAuthenticator) AddUserToGroup(user, group string) error {
+	return a.updateMembership(user, group, func(tx *sql.Tx, u *User, g *Group) error {
+		return joinGroup(u, g)
+	})
+}
+
+// GadminAddUserToGroup adds the User to the Group on behalf of a group admin,
+// using up one of the Group's gadmin slots, or a leech slot if the User is a leech
+func (a *SQLAuthenticator) GadminAddUserToGroup(gadmin, user, group string) error {
+	return a.updateMembership(user, group, func(tx *sql.Tx, u *User, g *Group) error {
+		admin, err := a.loadUser(tx, gadmin, false)
+		if err != nil {
+			return err
+		}
+
+		if err := useGadminSlot(admin, u, g); err != nil {
+			return err
+		}
+
+		return joinGroup(u, g)
+	})
+}
+
+// RemoveUserFromGroup removes the User from the Group in the same transaction.
+// If it was the User's PrimaryGroup then the PrimaryGroup is cleared
+func (a *SQLAuthenticator) RemoveUserFromGroup(user, group string) error {
+	return a.updateMembership(user, group, func(tx *sql.Tx, u *User, g *Group) error {
+		return leaveGroup(u, g)
+	})
+}
+
+// SetPrimaryGroup sets the User's PrimaryGroup, the User must already be a
+// member of the Group
+func (a *SQLAuthenticator) SetPrimaryGroup(user, group string) error {
+	return a.updateMembership(user, group, func(tx *sql.Tx, u *User, g *Group) error {
+		if !u.InGroup(g.Name) {
+			return ErrUserNotInGroup
+		}
+
+		u.PrimaryGroup = g.Name
+
+		return nil
+	})
+}
+
+// shared read-modify-write operations, see user_updates.go
+func (a *SQLAuthenticator) CheckPassword(name, pass string) bool { return checkPassword(a, name, pass) }
+func (a *SQLAuthenticator) ChangePassword(name, pass string) error {
+	return changePassword(a, a.AuthenticatorOpts, name, pass)
+}
+func (a *SQLAuthenticator) CheckTOTP(name, code string) bool   { return checkTOTP(a, name, code) }
+func (a *SQLAuthenticator) AddFlag(name, flag string) error    { return addFlag(a, name, flag) }
+func (a *SQLAuthenticator) RemoveFlag(name, flag string) error { return removeFlag(a, name, flag) }
+func (a *SQLAuthenticator) AddIP(name, mask string) error      { return addIP(a, name, mask) }
+func (a *SQLAuthenticator) RemoveIP(name, mask string) error   { return removeIP(a, name, mask) }
+func (a *SQLAuthenticator) DeleteUser(name string) error       { return deleteUser(a, name) }
+func (a *SQLAuthenticator) ReaddUser(name string) error        { return readdUser(a, name) }
+func (a *SQLAuthenticator) IncrCredits(name string, n int64) (int64, error) {
+	return incrCredits(a, name, n)
+}
+func (a *SQLAuthenticator) DecrCredits(name string, n int64, force bool) (int64, error) {
+	return decrCredits(a, name, n, force)
+}
+func (a *SQLAuthenticator) RecordLogin(name, ident, ip string) error {
+	return recordLogin(a, name, ident, ip)
+}
+func (a *SQLAuthenticator) LoginHistory(name string) ([]Login, error) { return loginHistory(a, name) }
+func (a *SQLAuthenticator) Export(w io.Writer, credentials bool) error {
+	return exportBackup(a, w, credentials)
+}
+func (a *SQLAuthenticator) Import(r io.Reader) error { return importBackup(a, r) }
+
+// FailedLogin records a failed login for both the user and the ip, locking
+// either once they reach LockoutAttempts. Returns how long the reply to the
+// client should be delayed for
+func (a *SQLAuthenticator) FailedLogin(name, ip string) (time.Duration, error) {
+	var count int
+
+	lockout := time.Duration(a.LockoutTime) * time.Second
+	now := time.Now()
+
+	err := a.tx(func(tx *sql.Tx) error {
+		for _, f := range []*Failures{
+			{Kind: failuresKindUser, Name: name},
+			{Kind: failuresKindIP, Name: ip},
+		} {
+			var last, locked int64
+
+			err := a.queryRow(tx,
+				`SELECT attempts, last_at, locked_until FROM ftp_failures WHERE kind = ? AND name_key = ?`+a.dialect.forUpdate,
+				f.Kind, sqlKey(f.Name),
+			).Scan(&f.Count, &last, &locked)
+
+			found := err == nil
+
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+
+			// failures are forgotten once there hasn't been one for
+			// the lockout time
+			if found && lockout > 0 && now.Sub(fromUnix(last)) > lockout {
+				f.Count = 0
+				locked = 0
+			}
+
+			f.Count++
+			f.LastAt = now
+			f.LockedUntil = fromUnix(locked)
+
+			if a.LockoutAttempts > 0 && f.Count >= a.LockoutAttempts {
+				f.LockedUntil = now.Add(lockout)
+			}
+
+			if f.Count > count {
+				count = f.Count
+			}
+
+			args := []interface{}{f.Count, toUnix(f.LastAt), toUnix(f.LockedUntil), f.Kind, sqlKey(f.Name)}
+
+			if found {
+				err = a.exec(tx, `UPDATE ftp_failures SET attempts = ?, last_at = ?, locked_until = ? WHERE kind = ? AND name_key = ?`, args...)
+			} else {
+				err = a.exec(tx, `INSERT INTO ftp_failures (attempts, last_at, locked_until, kind, name_key) VALUES (?, ?, ?, ?, ?)`, args...)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(a.TarpitDelay*count) * time.Second, nil
+}
+
+// Locked checks to see if either the user or the ip are locked out
+func (a *SQLAuthenticator) Locked(name, ip string) bool {
+	return a.lockedUntil(failuresKindUser, name) || a.lockedUntil(failuresKindIP, ip)
+}
+
+func (a *SQLAuthenticator) lockedUntil(kind, name string) bool {
+	var locked int64
+
+	err := a.queryRow(a.db, `SELECT locked_until FROM ftp_failures WHERE kind = ? AND name_key = ?`, kind, sqlKey(name)).Scan(&locked)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Before(fromUnix(locked))
+}
+
+// ClearFailedLogins removes the failed logins of the user, the failed logins of
+// the ip are kept so that a valid account can't be used to reset them
+func (a *SQLAuthenticator) ClearFailedLogins(name string) error {
+	return a.exec(a.db, `DELETE FROM ftp_failures WHERE kind = ? AND name_key = ?`, failuresKindUser, sqlKey(name))
+}
+
+// Unlock removes any failed logins for the user or ip called name. Returns
+// true if anything was removed
+func (a *SQLAuthenticator) Unlock(name string) (bool, error) {
+	res, err := a.db.Exec(a.rebind(`DELETE FROM ftp_failures WHERE name_key = ?`), sqlKey(name))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// ExpireUsers finds all expired Users and performs the configured ExpiredAction
+// on them. Returns the names of any Users that were acted on
+func (a *SQLAuthenticator) ExpireUsers() ([]string, error) {
+	if a.ExpiredAction != ExpiredActionFlag && a.ExpiredAction != ExpiredActionPurge {
+		return nil, nil
+	}
+
+	var expired []string
+
+	query := `SELECT name FROM ftp_users WHERE expires_at > 0 AND expires_at < ?`
+	args := []interface{}{time.Now().Unix()}
+
+	if a.ExpiredAction == ExpiredActionFlag {
+		query += ` AND name_key NOT IN (SELECT user_key FROM ftp_user_flags WHERE flag = ?)`
+		args = append(args, FlagExpired)
+	}
+
+	err := a.scanRows(a.db, func(rows *sql.Rows) error {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		expired = append(expired, name)
+
+		return nil
+	}, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range expired {
+		switch a.ExpiredAction {
+		case ExpiredActionFlag:
+			err = a.AddFlag(name, FlagExpired)
+		case ExpiredActionPurge:
+			err = a.PurgeUser(name)
+		}
+
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to expire '%s'", name)
+		}
+	}
+
+	return expired, nil
+}
+
+// sqlKey returns the key used to look up users and groups by name
+func sqlKey(name string) string { return strings.ToLower(name) }
+
+// sqlLikePrefix escapes the prefix for use in a LIKE with ! as the escape character
+func sqlLikePrefix(prefix string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return r.Replace(sqlKey(prefix)) + "%"
+}
+
+// toUnix converts t to unix seconds, the zero time is stored as 0
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// fromUnix converts unix seconds to a time, 0 is the zero time
+func fromUnix(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
+
+// ensure SQLAuthenticator satisfies the Authenticator interface
+var _ Authenticator = (*SQLAuthenticator)(nil)
@@ -0,0 +1,335 @@
+package acl
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteAuthenticator(t *testing.T) (*SQLAuthenticator, *testReconciler) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	// each connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+
+	opts := AuthenticatorOpts{
+		OrphanUser:  "nobody",
+		OrphanGroup: "nogroup",
+	}
+
+	auth, err := NewSQLAuthenticator(&opts, db, "sqlite")
+	if err != nil {
+		t.Fatalf("error creating authenticator: %s", err)
+	}
+
+	r := newTestReconciler()
+	auth.SetReconciler(r)
+
+	return auth, r
+}
+
+func closeSQLiteAuthenticator(t *testing.T, auth *SQLAuthenticator) {
+	t.Helper()
+	if err := auth.Close(); err != nil {
+		t.Fatalf("error closing authenticator: %s", err)
+	}
+}
+
+func TestSQLMigrate(t *testing.T) {
+	auth, _ := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	// migrating again is a no-op
+	checkErr(t, auth.migrate(), nil)
+
+	var version int
+	checkErr(t, auth.db.QueryRow(`SELECT MAX(version) FROM ftp_schema`).Scan(&version), nil)
+
+	if version != len(sqlMigrations) {
+		t.Fatalf("expected version %d got %d", len(sqlMigrations), version)
+	}
+}
+
+func TestSQLRebind(t *testing.T) {
+	auth := &SQLAuthenticator{dialect: sqlDialects["postgres"]}
+
+	got := auth.rebind(`SELECT a FROM b WHERE c = ? AND d = ?`)
+	if got != `SELECT a FROM b WHERE c = $1 AND d = $2` {
+		t.Fatalf("unexpected rebind: %s", got)
+	}
+}
+
+func TestSQLUsers(t *testing.T) {
+	auth, r := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	if _, err := auth.GetUser("user"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	_, err := auth.AddUser("User", "pass")
+	checkErr(t, err, nil)
+
+	if _, err := auth.AddUser("user", "pass"); err != ErrUserExists {
+		t.Fatalf("expected ErrUserExists got: %s", err)
+	}
+
+	if !auth.CheckPassword("user", "pass") || auth.CheckPassword("user", "wrong") {
+		t.Fatal("unexpected CheckPassword result")
+	}
+
+	checkErr(t, auth.AddFlag("user", "siteop"), nil)
+	checkErr(t, auth.AddIP("user", "*@127.0.0.1"), nil)
+	checkErr(t, auth.RecordLogin("user", "ident", "127.0.0.1"), nil)
+	checkErr(t, auth.RecordLogin("user", "", "10.0.0.1"), nil)
+
+	expires := time.Now().Add(time.Hour)
+
+	checkErr(t, auth.UpdateUser("user", func(u *User) error {
+		u.ExpiresAt = expires
+		u.Tagline = "hello world"
+		u.MaxLogins = 2
+		u.MaxUploads = 3
+		u.MaxDownloads = 4
+		u.Comment = "staff note"
+		return nil
+	}), nil)
+
+	u, err := auth.GetUser("USER")
+	checkErr(t, err, nil)
+
+	if u.Name != "User" {
+		t.Errorf("expected name 'User' got '%s'", u.Name)
+	}
+
+	if !u.HasFlag("siteop") {
+		t.Error("expected user to have siteop flag")
+	}
+
+	if !u.MatchIP("ident", "127.0.0.1", nil) {
+		t.Error("expected user to match ip")
+	}
+
+	if u.Logins != 2 || len(u.LoginHistory) != 2 || u.LoginHistory[0].IP != "10.0.0.1" {
+		t.Errorf("unexpected login history: %d %v", u.Logins, u.LoginHistory)
+	}
+
+	if u.ExpiresAt.Unix() != expires.Unix() {
+		t.Errorf("expected expires at %s got %s", expires, u.ExpiresAt)
+	}
+
+	if u.Tagline != "hello world" || u.MaxLogins != 2 {
+		t.Errorf("unexpected tagline '%s' and max logins %d", u.Tagline, u.MaxLogins)
+	}
+
+	if u.MaxUploads != 3 || u.MaxDownloads != 4 {
+		t.Errorf("unexpected max uploads %d and max downloads %d", u.MaxUploads, u.MaxDownloads)
+	}
+
+	if u.Comment != "staff note" {
+		t.Errorf("unexpected comment '%s'", u.Comment)
+	}
+
+	checkErr(t, auth.DeleteUser("user"), nil)
+
+	u, err = auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if !u.Deleted() {
+		t.Error("expected user to be deleted")
+	}
+
+	checkErr(t, auth.PurgeUser("user"), nil)
+
+	if err := auth.PurgeUser("user"); err != ErrUserDoesntExist {
+		t.Fatalf("expected ErrUserDoesntExist got: %s", err)
+	}
+
+	if r.users["user"] != "nobody" {
+		t.Errorf("expected user to be reassigned to nobody got '%s'", r.users["user"])
+	}
+}
+
+func TestSQLCredits(t *testing.T) {
+	auth, _ := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := auth.IncrCredits("user", 10); err != nil {
+				t.Errorf("unexpected error in IncrCredits: %s", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if _, err := auth.DecrCredits("user", 201, false); err != ErrNotEnoughCredits {
+		t.Fatalf("expected ErrNotEnoughCredits got: %s", err)
+	}
+
+	credits, err := auth.DecrCredits("user", 50, false)
+	checkErr(t, err, nil)
+
+	if credits != 150 {
+		t.Fatalf("expected 150 credits got %d", credits)
+	}
+}
+
+func TestSQLGroups(t *testing.T) {
+	auth, r := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	for _, name := range []string{"gadmin", "user", "other"} {
+		_, err := auth.AddUser(name, "pass")
+		checkErr(t, err, nil)
+	}
+
+	for _, name := range []string{"group", "other", "site_ops"} {
+		_, err := auth.AddGroup(name)
+		checkErr(t, err, nil)
+	}
+
+	if _, err := auth.AddGroup("GROUP"); err != ErrGroupExists {
+		t.Fatalf("expected ErrGroupExists got: %s", err)
+	}
+
+	checkErr(t, auth.AddUserToGroup("gadmin", "group"), nil)
+	checkErr(t, auth.AddUserToGroup("user", "other"), nil)
+
+	if err := auth.AddUserToGroup("gadmin", "group"); err != ErrUserInGroup {
+		t.Fatalf("expected ErrUserInGroup got: %s", err)
+	}
+
+	checkErr(t, auth.UpdateUser("gadmin", func(u *User) error {
+		u.Groups["group"] = GroupSettings{IsAdmin: true}
+		return nil
+	}), nil)
+
+	checkErr(t, auth.UpdateGroup("group", func(g *Group) error {
+		g.MaxMembers = 2
+		g.GadminSlots = 1
+		g.Description = "the group"
+		return nil
+	}), nil)
+
+	checkErr(t, auth.GadminAddUserToGroup("gadmin", "user", "group"), nil)
+
+	if err := auth.AddUserToGroup("other", "group"); err != ErrGroupFull {
+		t.Fatalf("expected ErrGroupFull got: %s", err)
+	}
+
+	g, err := auth.GetGroup("group")
+	checkErr(t, err, nil)
+
+	if fmt.Sprint(g.MemberNames()) != "[gadmin user]" || g.GadminSlots != 0 {
+		t.Fatalf("unexpected group: %v %d", g.MemberNames(), g.GadminSlots)
+	}
+
+	if g.Description != "the group" {
+		t.Errorf("expected description 'the group' got '%s'", g.Description)
+	}
+
+	checkErr(t, auth.SetPrimaryGroup("user", "group"), nil)
+	checkErr(t, auth.DeleteGroup("group"), nil)
+
+	u, err := auth.GetUser("user")
+	checkErr(t, err, nil)
+
+	if u.InGroup("group") || len(u.PrimaryGroup) > 0 || !u.InGroup("other") {
+		t.Fatalf("unexpected groups after delete: %v primary '%s'", u.Groups, u.PrimaryGroup)
+	}
+
+	if r.groups["group"] != "nogroup" {
+		t.Errorf("expected group to be reassigned to nogroup got '%s'", r.groups["group"])
+	}
+
+	// _ must not be treated as a wildcard
+	groups, err := auth.GetGroupsWithPrefix("site_")
+	checkErr(t, err, nil)
+
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group got %d", len(groups))
+	}
+
+	users, err := auth.GetAllUsers()
+	checkErr(t, err, nil)
+
+	if len(users) != 3 || users[0].Name != "gadmin" {
+		t.Fatalf("unexpected users: %v", users)
+	}
+}
+
+func TestSQLLockout(t *testing.T) {
+	auth, _ := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	auth.LockoutAttempts = 2
+	auth.LockoutTime = 60
+	auth.TarpitDelay = 1
+
+	for i := 0; i < 2; i++ {
+		_, err := auth.FailedLogin("user", "1.2.3.4")
+		checkErr(t, err, nil)
+	}
+
+	if !auth.Locked("other", "1.2.3.4") {
+		t.Fatal("expected ip to be locked")
+	}
+
+	ok, err := auth.Unlock("1.2.3.4")
+	checkErr(t, err, nil)
+
+	if !ok || auth.Locked("other", "1.2.3.4") {
+		t.Fatal("expected ip to be unlocked")
+	}
+
+	if !auth.Locked("user", "5.6.7.8") {
+		t.Fatal("expected user to still be locked")
+	}
+}
+
+func TestSQLExpireUsers(t *testing.T) {
+	auth, _ := newSQLiteAuthenticator(t)
+	defer closeSQLiteAuthenticator(t, auth)
+
+	auth.ExpiredAction = ExpiredActionFlag
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	checkErr(t, auth.UpdateUser("user", func(u *User) error {
+		u.ExpiresAt = time.Now().Add(-time.Hour)
+		return nil
+	}), nil)
+
+	expired, err := auth.ExpireUsers()
+	checkErr(t, err, nil)
+
+	if len(expired) != 1 {
+		t.Fatalf("expected 1 expired user got %v", expired)
+	}
+
+	expired, err = auth.ExpireUsers()
+	checkErr(t, err, nil)
+
+	if len(expired) != 0 {
+		t.Fatalf("expected already flagged user to be skipped got %v", expired)
+	}
+}
@@ -0,0 +1,166 @@
+package acl
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTemplate is the Template used when adding a user without naming one
+const DefaultTemplate = "default"
+
+// Template holds the defaults given to users created from it, templates are
+// configured with `template <name> <field> <value>`
+type Template struct {
+	Name string
+
+	// the user is added to each group, the first becomes their
+	// PrimaryGroup
+	Groups []string `goftpd:"groups"`
+	Flags  []string `goftpd:"flags"`
+	Ratio  int      `goftpd:"ratio"`
+
+	// seconds before an idle user is disconnected, zero uses the
+	// server's idle_timeout
+	IdleTime int `goftpd:"idle_time"`
+
+	// ident@ip masks given to every user. When RequireIP is set at least
+	// one mask must be given when adding a user and RequireIdent rejects
+	// masks that accept any ident
+	IPs          []string `goftpd:"ips"`
+	RequireIP    bool     `goftpd:"require_ip"`
+	RequireIdent bool     `goftpd:"require_ident"`
+}
+
+// FindTemplate returns the named Template, an empty name returns the
+// DefaultTemplate or an empty Template if it isn't in templates
+func FindTemplate(templates map[string]*Template, name string) (*Template, bool) {
+	if len(name) == 0 {
+		if t, ok := templates[DefaultTemplate]; ok {
+			return t, true
+		}
+		return &Template{}, true
+	}
+
+	t, ok := templates[strings.ToLower(name)]
+	return t, ok
+}
+
+// Validate checks the Template's flags and masks, normalising them
+func (t *Template) Validate() error {
+	for idx, f := range t.Flags {
+		t.Flags[idx] = strings.ToLower(f)
+	}
+
+	if t.Ratio < 0 {
+		return errors.Errorf("template '%s': ratio can't be negative", t.Name)
+	}
+
+	if t.IdleTime < 0 {
+		return errors.Errorf("template '%s': idle_time can't be negative", t.Name)
+	}
+
+	for idx, m := range t.IPs {
+		mask, err := ParseMask(m)
+		if err != nil {
+			return errors.WithMessagef(err, "template '%s'", t.Name)
+		}
+		t.IPs[idx] = mask
+	}
+
+	return nil
+}
+
+// CheckIPs checks the masks given for a new user against the Template's
+// mask policy, returning the normalised masks
+func (t *Template) CheckIPs(ips []string) ([]string, error) {
+	var masks []string
+
+	for _, ip := range ips {
+		mask, err := ParseMask(ip)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.RequireIdent && strings.HasPrefix(mask, "*@") {
+			return nil, errors.Errorf("mask '%s' requires an ident", mask)
+		}
+
+		masks = append(masks, mask)
+	}
+
+	if t.RequireIP && len(masks) == 0 {
+		return nil, errors.New("at least one ident@ip mask is required")
+	}
+
+	return masks, nil
+}
+
+// Apply gives the User the Template's flags, ratio, idle time and masks and
+// records the Template's name. Groups are not applied as joining them needs
+// the Authenticator
+func (t *Template) Apply(u *User) error {
+	u.Template = t.Name
+	u.Ratio = t.Ratio
+	u.IdleTime = t.IdleTime
+
+	for _, f := range t.Flags {
+		u.AddFlag(f)
+	}
+
+	for _, m := range t.IPs {
+		if _, err := u.AddIP(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddUserFromTemplate creates the User, applies the Template and adds them to
+// the Template's groups along with any extra ident@ip masks. If any step fails
+// the User is deleted so no half created accounts are left behind
+func AddUserFromTemplate(a Authenticator, t *Template, name, password string, ips []string) (*User, error) {
+	masks, err := t.CheckIPs(ips)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.CheckPasswordPolicy(password); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.AddUser(name, password); err != nil {
+		return nil, err
+	}
+
+	err = a.UpdateUser(name, func(u *User) error {
+		if err := t.Apply(u); err != nil {
+			return err
+		}
+
+		for _, m := range masks {
+			if _, err := u.AddIP(m); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		for _, g := range t.Groups {
+			if err = a.AddUserToGroup(name, g); err != nil {
+				err = errors.WithMessagef(err, "unable to add to group '%s'", g)
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		a.PurgeUser(name)
+		return nil, err
+	}
+
+	return a.GetUser(name)
+}
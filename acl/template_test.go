@@ -0,0 +1,85 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAddUserFromTemplate(t *testing.T) {
+	a, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, a)
+
+	for _, g := range []string{"first", "second"} {
+		_, err := a.AddGroup(g)
+		checkErr(t, err, nil)
+	}
+
+	tmpl := Template{
+		Name:         "member",
+		Groups:       []string{"first", "second"},
+		Flags:        []string{"Nuker"},
+		Ratio:        3,
+		IdleTime:     600,
+		IPs:          []string{"ident@10.0.0.*"},
+		RequireIP:    true,
+		RequireIdent: true,
+	}
+
+	checkErr(t, tmpl.Validate(), nil)
+
+	_, err := AddUserFromTemplate(a, &tmpl, "user", "password", nil)
+	checkErr(t, err, errors.New("at least one ident@ip mask is required"))
+
+	_, err = AddUserFromTemplate(a, &tmpl, "user", "password", []string{"127.0.0.1"})
+	checkErr(t, err, errors.New("mask '*@127.0.0.1' requires an ident"))
+
+	u, err := AddUserFromTemplate(a, &tmpl, "user", "password", []string{"user@127.0.0.1"})
+	checkErr(t, err, nil)
+
+	if u.Template != "member" || u.Ratio != 3 || u.IdleTime != 600 || !u.HasFlag("nuker") {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	if u.PrimaryGroup != "first" || !u.InGroup("second") {
+		t.Errorf("unexpected groups: %+v", u.Groups)
+	}
+
+	if !u.MatchIP("ident", "10.0.0.1", nil) || !u.MatchIP("user", "127.0.0.1", nil) {
+		t.Errorf("unexpected ips: %+v", u.IPs)
+	}
+
+	// failing to join a group removes the user
+	tmpl.Groups = []string{"first", "missing"}
+
+	_, err = AddUserFromTemplate(a, &tmpl, "other", "password", []string{"user@127.0.0.1"})
+	checkErr(t, err, ErrGroupDoesntExist)
+
+	if _, err := a.GetUser("other"); err != ErrUserDoesntExist {
+		t.Errorf("expected user to be removed, got %v", err)
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	templates := map[string]*Template{
+		"member": &Template{Name: "member"},
+	}
+
+	if tmpl, ok := FindTemplate(templates, ""); !ok || len(tmpl.Name) > 0 {
+		t.Errorf("expected empty template, got %+v", tmpl)
+	}
+
+	if tmpl, ok := FindTemplate(templates, "Member"); !ok || tmpl.Name != "member" {
+		t.Errorf("expected member template, got %+v", tmpl)
+	}
+
+	if _, ok := FindTemplate(templates, "missing"); ok {
+		t.Error("expected missing template not to be found")
+	}
+
+	templates[DefaultTemplate] = &Template{Name: DefaultTemplate}
+
+	if tmpl, ok := FindTemplate(templates, ""); !ok || tmpl.Name != DefaultTemplate {
+		t.Errorf("expected default template, got %+v", tmpl)
+	}
+}
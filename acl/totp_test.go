@@ -0,0 +1,84 @@
+package acl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors (SHA1), truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	var tests = []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		checkErr(t, err, nil)
+
+		if code != tt.expected {
+			t.Errorf("expected %s at %d got %s", tt.expected, tt.unix, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	checkErr(t, err, nil)
+
+	now := time.Now()
+
+	code, err := TOTPCode(secret, TOTPStep(now.Add(-TOTPPeriod)))
+	checkErr(t, err, nil)
+
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Error("expected previous code to be valid")
+	}
+
+	code, err = TOTPCode(secret, TOTPStep(now.Add(-3*TOTPPeriod)))
+	checkErr(t, err, nil)
+
+	if _, ok := ValidateTOTP(secret, code, now); ok {
+		t.Error("expected old code to be invalid")
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("expected short code to be invalid")
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	auth, _ := newMemoryAuthenticator(t)
+	defer closeMemoryAuthenticator(t, auth)
+
+	_, err := auth.AddUser("user", "pass")
+	checkErr(t, err, nil)
+
+	code, err := TOTPCode("JBSWY3DPEHPK3PXP", TOTPStep(time.Now()))
+	checkErr(t, err, nil)
+
+	if auth.CheckTOTP("user", code) {
+		t.Fatal("expected check to fail without a secret")
+	}
+
+	checkErr(t, auth.UpdateUser("user", func(u *User) error {
+		u.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		return nil
+	}), nil)
+
+	if !auth.CheckTOTP("user", code) {
+		t.Fatal("expected code to be valid")
+	}
+
+	if auth.CheckTOTP("user", code) {
+		t.Fatal("expected code to not be reusable")
+	}
+}
@@ -2,6 +2,8 @@ package acl
 
 import (
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 	"time"
 )
@@ -14,8 +16,46 @@ type User struct {
 	PrimaryGroup string
 	Groups       map[string]GroupSettings
 
+	// flags are lower cased and can be matched against in acl rules,
+	// i.e. siteop or nuker
+	Flags map[string]struct{}
+
 	// bytes available for download
-	Credits int
+	Credits int64
+	// upload to download ratio, i.e. 3 for 1:3. leeches are marked with
+	// FlagLeech
+	Ratio int
+
+	// base32 TOTP secret, only required at login once the user has
+	// confirmed it and been given FlagTOTP
+	TOTPSecret string
+	// the last TOTP time step used to login, codes can't be reused
+	TOTPLastStep int64
+
+	// the directory the User starts in after login, users with FlagJail
+	// can't leave it. empty is the root
+	HomeDir string
+
+	// seconds the User can be idle before being disconnected, zero uses
+	// the server's idle_timeout
+	IdleTime int
+
+	// name of the Template the User was created from
+	Template string
+
+	// shown next to the User in listings such as SITE WHO
+	Tagline string
+
+	// a note about the User left by staff, never shown to the User
+	Comment string
+
+	// number of sessions the User can have at once, 0 is unlimited
+	MaxLogins int
+
+	// number of uploads and downloads the User can run at once across all
+	// of their sessions, 0 is unlimited
+	MaxUploads   int
+	MaxDownloads int
 
 	// login based attributes
 	Logins    int
@@ -27,12 +67,63 @@ type User struct {
 	LastLoginAt time.Time
 	DeletedAt   time.Time
 
+	// zero value means the account never expires
+	ExpiresAt time.Time
+
+	// the most recent logins, newest first
+	LoginHistory []Login
+
 	// map of ident@ip matches against the time they were added
 	// potential to add TTL on ips here, or for maintenace (clean
 	// all ips older than x)
 	IPs map[string]time.Time
 }
 
+// FlagJail restricts a User to their HomeDir
+const FlagJail = "jail"
+
+// FlagUploadOnly and FlagDownloadOnly restrict a User to transfers in a
+// single direction whatever the path rules, i.e. for affil FXP accounts
+const (
+	FlagUploadOnly   = "uploadonly"
+	FlagDownloadOnly = "downloadonly"
+)
+
+// FlagDeleted marks a User as deleted, they can't login until readded
+const FlagDeleted = "deleted"
+
+// MaxLoginHistory is the number of logins kept in a User's LoginHistory
+const MaxLoginHistory = 10
+
+// Login records a successful login
+type Login struct {
+	At    time.Time
+	IP    string
+	Ident string
+}
+
+// addLogin adds a login to the User, updating last seen details and trimming
+// the LoginHistory to MaxLoginHistory
+func (u *User) addLogin(l Login) {
+	u.Logins++
+	u.LastLoginAt = l.At
+
+	u.LoginHistory = append([]Login{l}, u.LoginHistory...)
+
+	if len(u.LoginHistory) > MaxLoginHistory {
+		u.LoginHistory = u.LoginHistory[:MaxLoginHistory]
+	}
+}
+
+// LastLogin returns the most recent Login, false if the User has never
+// logged in
+func (u *User) LastLogin() (Login, bool) {
+	if len(u.LoginHistory) == 0 {
+		return Login{}, false
+	}
+	return u.LoginHistory[0], true
+}
+
 // Used to satisfy the authenticator Entry interface
 func (u User) Key() []byte {
 	return []byte(fmt.Sprintf(
@@ -41,12 +132,234 @@ func (u User) Key() []byte {
 	))
 }
 
+// Expired checks to see if the User's account has expired
+func (u *User) Expired() bool {
+	return !u.ExpiresAt.IsZero() && time.Now().After(u.ExpiresAt)
+}
+
+// Deleted checks to see if the User has been deleted but not purged
+func (u *User) Deleted() bool {
+	return u.HasFlag(FlagDeleted)
+}
+
+// HasFlag checks to see if the User has the flag
+func (u *User) HasFlag(flag string) bool {
+	_, ok := u.Flags[strings.ToLower(flag)]
+	return ok
+}
+
+// AddFlag adds the flag to the User, returns false if the user already
+// had the flag
+func (u *User) AddFlag(flag string) bool {
+	if u.HasFlag(flag) {
+		return false
+	}
+
+	if u.Flags == nil {
+		u.Flags = make(map[string]struct{}, 0)
+	}
+
+	u.Flags[strings.ToLower(flag)] = struct{}{}
+
+	return true
+}
+
+// RemoveFlag removes the flag from the User, returns false if the user
+// did not have the flag
+func (u *User) RemoveFlag(flag string) bool {
+	if !u.HasFlag(flag) {
+		return false
+	}
+
+	delete(u.Flags, strings.ToLower(flag))
+
+	return true
+}
+
+// AddIP validates and adds the ident@ip mask to the User, returns false if
+// the mask already exists
+func (u *User) AddIP(mask string) (bool, error) {
+	mask, err := ParseMask(mask)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := u.IPs[mask]; ok {
+		return false, nil
+	}
+
+	if u.IPs == nil {
+		u.IPs = make(map[string]time.Time, 0)
+	}
+
+	u.IPs[mask] = time.Now()
+
+	return true, nil
+}
+
+// RemoveIP removes the ident@ip mask from the User, returns false if the
+// mask did not exist
+func (u *User) RemoveIP(mask string) bool {
+	mask, err := ParseMask(mask)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := u.IPs[mask]; !ok {
+		return false
+	}
+
+	delete(u.IPs, mask)
+
+	return true
+}
+
+// MatchIP checks to see if the ident and ip match any of the User's masks. A
+// User with no masks is not allowed from anywhere. hosts is called at most
+// once, when a hostname mask is reached, see MatchMask
+func (u *User) MatchIP(ident, ip string, hosts Hosts) bool {
+	var (
+		looked bool
+		names  []string
+	)
+
+	once := func() []string {
+		if !looked && hosts != nil {
+			names, looked = hosts(), true
+		}
+		return names
+	}
+
+	for mask := range u.IPs {
+		if MatchMask(mask, ident, ip, once) {
+			return true
+		}
+	}
+	return false
+}
+
+// Home returns the cleaned HomeDir, defaulting to the root
+func (u *User) Home() string {
+	if len(u.HomeDir) == 0 {
+		return "/"
+	}
+	return path.Clean("/" + u.HomeDir)
+}
+
+// CanUpload checks to see if the User is allowed to upload at all
+func (u *User) CanUpload() bool { return !u.HasFlag(FlagDownloadOnly) }
+
+// CanDownload checks to see if the User is allowed to download at all
+func (u *User) CanDownload() bool { return !u.HasFlag(FlagUploadOnly) }
+
+// Leech checks to see if the User transfers without credits, either flagged
+// as a leech or with no ratio. Leeches aren't awarded or charged credits
+func (u *User) Leech() bool { return u.HasFlag(FlagLeech) || u.Ratio == 0 }
+
+// CanAccess checks to see if the User is allowed to access the path. Users
+// with FlagJail can only access their Home, everyone else can access anything
+func (u *User) CanAccess(p string) bool {
+	if !u.HasFlag(FlagJail) {
+		return true
+	}
+
+	home := u.Home()
+	p = path.Clean("/" + p)
+
+	return home == "/" || p == home || strings.HasPrefix(p, home+"/")
+}
+
+// InGroup checks to see if the User is a member of the group
+func (u *User) InGroup(name string) bool {
+	for g := range u.Groups {
+		if strings.EqualFold(g, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGroupAdmin checks to see if the User is an admin of the group
+func (u *User) IsGroupAdmin(name string) bool {
+	for g, settings := range u.Groups {
+		if strings.EqualFold(g, name) {
+			return settings.IsAdmin
+		}
+	}
+	return false
+}
+
+// removeGroup removes the group from the User, if it was the PrimaryGroup
+// then the PrimaryGroup is cleared. Returns true if the User was changed
+func (u *User) removeGroup(name string) bool {
+	var changed bool
+
+	for g := range u.Groups {
+		if strings.EqualFold(g, name) {
+			delete(u.Groups, g)
+			changed = true
+		}
+	}
+
+	if strings.EqualFold(u.PrimaryGroup, name) {
+		u.PrimaryGroup = ""
+		changed = true
+	}
+
+	return changed
+}
+
 type Group struct {
 	Name string
 
+	// a short description shown by SITE GRP and SITE GROUPS
+	Description string
+
+	// lowercased names of the Users in the Group
+	Members map[string]struct{}
+
+	// maximum number of members, 0 is unlimited
+	MaxMembers int
+	// number of users group admins can still add
+	GadminSlots int
+	// number of leeches group admins can still add
+	LeechSlots int
+
 	AddedAt time.Time
 }
 
+// HasMember checks to see if the User is a member of the Group
+func (g *Group) HasMember(name string) bool {
+	_, ok := g.Members[strings.ToLower(name)]
+	return ok
+}
+
+// MemberNames returns the sorted names of the Group's members
+func (g *Group) MemberNames() []string {
+	names := make([]string, 0, len(g.Members))
+	for name := range g.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *Group) addMember(name string) {
+	if g.Members == nil {
+		g.Members = make(map[string]struct{})
+	}
+	g.Members[strings.ToLower(name)] = struct{}{}
+}
+
+func (g *Group) removeMember(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := g.Members[name]; !ok {
+		return false
+	}
+	delete(g.Members, name)
+	return true
+}
+
 func (g Group) Key() []byte {
 	return []byte(fmt.Sprintf(
 		"groups:%s",
@@ -0,0 +1,277 @@
+package acl
+
+import (
+	"time"
+
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// userUpdater is implemented by Authenticators, the operations in this file
+// are built on top of it so they behave the same across backends
+type userUpdater interface {
+	GetUser(string) (*User, error)
+	UpdateUser(string, func(*User) error) error
+	Logger() logging.Logger
+}
+
+// checkPassword checks to see if the password is the correct one for
+// the user. Any failure (i.e. user doesn't exist) returns false.
+func checkPassword(a userUpdater, name, pass string) bool {
+	u, err := a.GetUser(name)
+	if err != nil {
+		return false
+	}
+
+	ok, legacy := comparePassword(u.Password, pass)
+	if !ok {
+		return false
+	}
+
+	// replace imported hashes now that we know the password, the
+	// policy isn't applied as the password itself isn't changing
+	if legacy {
+		if err := changePassword(a, nil, name, pass); err != nil {
+			a.Logger().Warn("unable to upgrade password hash", logging.User(name), logging.Err(err))
+		}
+	}
+
+	return true
+}
+
+// changePassword changes the password for the User, checking it against
+// the policy in opts if given
+func changePassword(a userUpdater, opts *AuthenticatorOpts, name, pass string) error {
+	if err := opts.CheckPasswordPolicy(pass); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(pass)
+	if err != nil {
+		return err
+	}
+
+	return a.UpdateUser(name, func(u *User) error {
+		u.Password = hashed
+		return nil
+	})
+}
+
+// checkTOTP checks the code against the User's TOTP secret. A code can only
+// be used once, any failure returns false
+func checkTOTP(a userUpdater, name, code string) bool {
+	errInvalid := errors.New("invalid code")
+
+	err := a.UpdateUser(name, func(u *User) error {
+		if len(u.TOTPSecret) == 0 {
+			return errInvalid
+		}
+
+		step, ok := ValidateTOTP(u.TOTPSecret, code, time.Now())
+		if !ok || step <= u.TOTPLastStep {
+			return errInvalid
+		}
+
+		u.TOTPLastStep = step
+
+		return nil
+	})
+
+	return err == nil
+}
+
+// addFlag adds a flag to the User
+func addFlag(a userUpdater, name, flag string) error {
+	if !AllowedUserAndGroupCharsRE.MatchString(flag) {
+		return errors.Errorf("flag contains invalid characters: '%s'", flag)
+	}
+
+	return a.UpdateUser(name, func(u *User) error {
+		if !u.AddFlag(flag) {
+			return ErrFlagExists
+		}
+		return nil
+	})
+}
+
+// removeFlag removes a flag from the User
+func removeFlag(a userUpdater, name, flag string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		if !u.RemoveFlag(flag) {
+			return ErrFlagDoesntExist
+		}
+		return nil
+	})
+}
+
+// addIP adds an ident@ip mask to the User
+func addIP(a userUpdater, name, mask string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		ok, err := u.AddIP(mask)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrIPExists
+		}
+
+		return nil
+	})
+}
+
+// removeIP removes an ident@ip mask from the User
+func removeIP(a userUpdater, name, mask string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		if !u.RemoveIP(mask) {
+			return ErrIPDoesntExist
+		}
+		return nil
+	})
+}
+
+// deleteUser marks the User as deleted, they are unable to login but keep
+// their stats, groups and files until purged
+func deleteUser(a userUpdater, name string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		if u.Deleted() {
+			return ErrUserDeleted
+		}
+
+		u.AddFlag(FlagDeleted)
+		u.DeletedAt = time.Now()
+
+		return nil
+	})
+}
+
+// readdUser restores a deleted User
+func readdUser(a userUpdater, name string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		if !u.Deleted() {
+			return ErrUserNotDeleted
+		}
+
+		u.RemoveFlag(FlagDeleted)
+		u.DeletedAt = time.Time{}
+
+		return nil
+	})
+}
+
+// incrCredits atomically adds n credits to the User, returns the new balance
+func incrCredits(a userUpdater, name string, n int64) (int64, error) {
+	var credits int64
+
+	err := a.UpdateUser(name, func(u *User) error {
+		u.Credits += n
+		credits = u.Credits
+		return nil
+	})
+
+	return credits, err
+}
+
+// decrCredits atomically removes n credits from the User, returns the new balance.
+// If force is false and the User does not have enough credits then ErrNotEnoughCredits
+// is returned and the balance is unchanged
+func decrCredits(a userUpdater, name string, n int64, force bool) (int64, error) {
+	var credits int64
+
+	err := a.UpdateUser(name, func(u *User) error {
+		if !force && u.Credits < n {
+			credits = u.Credits
+			return ErrNotEnoughCredits
+		}
+
+		u.Credits -= n
+		credits = u.Credits
+		return nil
+	})
+
+	return credits, err
+}
+
+// recordLogin records a successful login for the User from the ident and ip
+func recordLogin(a userUpdater, name, ident, ip string) error {
+	return a.UpdateUser(name, func(u *User) error {
+		u.addLogin(Login{
+			At:    time.Now(),
+			IP:    ip,
+			Ident: ident,
+		})
+		return nil
+	})
+}
+
+// loginHistory returns the most recent logins for the User, newest first
+func loginHistory(a userUpdater, name string) ([]Login, error) {
+	u, err := a.GetUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.LoginHistory, nil
+}
+
+// joinGroup adds the User to the Group checking the Group has room. If the
+// User has no PrimaryGroup then it is set to the Group
+func joinGroup(u *User, g *Group) error {
+	if u.InGroup(g.Name) {
+		return ErrUserInGroup
+	}
+
+	if g.MaxMembers > 0 && len(g.Members) >= g.MaxMembers {
+		return ErrGroupFull
+	}
+
+	if u.Groups == nil {
+		u.Groups = make(map[string]GroupSettings)
+	}
+
+	u.Groups[g.Name] = GroupSettings{AddedAt: time.Now()}
+
+	if len(u.PrimaryGroup) == 0 {
+		u.PrimaryGroup = g.Name
+	}
+
+	g.addMember(u.Name)
+
+	return nil
+}
+
+// useGadminSlot checks the admin can add the User to the Group and uses up
+// a gadmin slot, or a leech slot if the User is a leech
+func useGadminSlot(admin, u *User, g *Group) error {
+	if !admin.IsGroupAdmin(g.Name) {
+		return ErrNotGroupAdmin
+	}
+
+	if u.HasFlag(FlagLeech) {
+		if g.LeechSlots <= 0 {
+			return ErrNoLeechSlots
+		}
+		g.LeechSlots--
+		return nil
+	}
+
+	if g.GadminSlots <= 0 {
+		return ErrNoGadminSlots
+	}
+	g.GadminSlots--
+
+	return nil
+}
+
+// leaveGroup removes the User from the Group, if it was the User's
+// PrimaryGroup then the PrimaryGroup is cleared
+func leaveGroup(u *User, g *Group) error {
+	if !u.InGroup(g.Name) {
+		return ErrUserNotInGroup
+	}
+
+	u.removeGroup(g.Name)
+	g.removeMember(u.Name)
+
+	return nil
+}
@@ -0,0 +1,362 @@
+// Package announce posts announcements of new releases, completed races,
+// nukes and pres to Discord channels and Telegram chats, so a site can
+// keep its users informed without running an IRC daemon and sitebot.
+// Announcements are rendered from templates using the cookie package, in
+// the same way as the site's other messages
+package announce
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// events that can be announced
+const (
+	// a release directory created in a section
+	EventNewDir = "newdir"
+	// every file of a release has passed its sfv check
+	EventComplete = "complete"
+	EventNuke     = "nuke"
+	EventPre      = "pre"
+	// an upload was found infected, see the scan package
+	EventInfected = "infected"
+)
+
+var events = map[string]struct{}{
+	EventNewDir:   {},
+	EventComplete: {},
+	EventNuke:     {},
+	EventPre:      {},
+	EventInfected: {},
+}
+
+// defaults used when options are empty
+const (
+	defaultNewDir    = "NEW in %[section]: %[release] by %[user]/%[group]"
+	defaultComplete  = "COMPLETE %[release]: %[size] in %[files] files at %[speed] by %[racers] racers, won by %[winner]"
+	defaultNuke      = "NUKE %[release] x%[multiplier] by %[nuker]: %[reason]"
+	defaultPre       = "PRE in %[section]: %[release] by %[group] (%[files] files, %[size])"
+	defaultInfected  = "INFECTED %[path] uploaded by %[user]/%[group]: %[signature] (%[action])"
+	defaultTimeout   = 10
+	defaultQueueSize = 100
+)
+
+// the apis of the targets, replaced in tests
+var (
+	discordAPI  = "https://discord.com/api/v10"
+	telegramAPI = "https://api.telegram.org"
+)
+
+// the longest message each target accepts
+const (
+	discordMaxLength  = 2000
+	telegramMaxLength = 4096
+)
+
+// Target is a channel or chat announcements are sent to
+type Target interface {
+	Send(client *http.Client, text string) error
+	String() string
+}
+
+// NewTarget takes a line of text (i.e. from a config file) in the form of
+// `discord <bot token> <channel id>` or `telegram <bot token> <chat id>`
+func NewTarget(line string) (Target, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) != 3 {
+		return nil, errors.New("target requires 3 fields")
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "discord":
+		for _, r := range fields[2] {
+			if r < '0' || r > '9' {
+				return nil, errors.Errorf("discord channel id must be a number: '%s'", fields[2])
+			}
+		}
+
+		return &Discord{token: fields[1], channel: fields[2], api: discordAPI}, nil
+
+	case "telegram":
+		return &Telegram{token: fields[1], chat: fields[2], api: telegramAPI}, nil
+	}
+
+	return nil, errors.Errorf("unknown target '%s'", fields[0])
+}
+
+// Discord posts to a channel as a bot
+type Discord struct {
+	token   string
+	channel string
+	api     string
+}
+
+func (d *Discord) String() string { return "discord:" + d.channel }
+
+// Send posts the text as a message to the channel
+func (d *Discord) Send(client *http.Client, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"content": truncate(text, discordMaxLength),
+		// announcements shouldn't ping anyone named in a release
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/channels/%s/messages", d.api, d.channel)
+
+	return post(client, url, "Bot "+d.token, body)
+}
+
+// Telegram sends to a chat, group or channel as a bot
+type Telegram struct {
+	token string
+	// a chat id or @channelname
+	chat string
+	api  string
+}
+
+func (t *Telegram) String() string { return "telegram:" + t.chat }
+
+// Send sends the text as a message to the chat
+func (t *Telegram) Send(client *http.Client, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  t.chat,
+		"text":                     truncate(text, telegramMaxLength),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.api, t.token)
+
+	return post(client, url, "", body)
+}
+
+// post sends the JSON body, anything other than a 2xx response is a
+// failure
+func post(client *http.Client, url, authorization string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the telegram url holds the token
+		return errors.New(strings.Replace(err.Error(), url, "<url>", -1))
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// truncate shortens the text to at most n runes
+func truncate(text string, n int) string {
+	r := []rune(text)
+	if len(r) <= n {
+		return text
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// Announcer renders and sends the announcements of events
+type Announcer interface {
+	Announce(event string, cookies cookie.Cookies)
+	Close() error
+}
+
+type AnnounceOpts struct {
+	// events to announce, defaults to all of them
+	Events []string `goftpd:"events"`
+	// the templates of each event, see the defaults for their cookies
+	NewDir   string `goftpd:"newdir"`
+	Complete string `goftpd:"complete"`
+	Nuke     string `goftpd:"nuke"`
+	Pre      string `goftpd:"pre"`
+	Infected string `goftpd:"infected"`
+	// seconds to wait for a target to respond, zero uses 10
+	Timeout int `goftpd:"timeout"`
+	// announcements waiting to be sent, more are dropped. zero uses 100
+	QueueSize int `goftpd:"queue_size"`
+
+	logger logging.Logger
+}
+
+// SetLogger sets where failed announcements are logged, defaults to
+// logging.Stderr
+func (o *AnnounceOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where failed announcements are logged
+func (o *AnnounceOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// message is an announcement on its way to a single target
+type message struct {
+	target Target
+	text   string
+}
+
+// BotAnnouncer implements Announcer, announcements are queued and sent to
+// every target in the background
+type BotAnnouncer struct {
+	*AnnounceOpts
+	targets []Target
+
+	templates map[string]string
+	client    *http.Client
+
+	// held while queueing so the queue isn't closed underneath Announce
+	mtx    sync.RWMutex
+	closed bool
+	queue  chan message
+	wg     sync.WaitGroup
+}
+
+// NewBotAnnouncer takes in options and the targets to announce to, the
+// background sender is started straight away. Fails on an unknown event
+func NewBotAnnouncer(opts *AnnounceOpts, targets []Target) (*BotAnnouncer, error) {
+	if len(opts.NewDir) == 0 {
+		opts.NewDir = defaultNewDir
+	}
+
+	if len(opts.Complete) == 0 {
+		opts.Complete = defaultComplete
+	}
+
+	if len(opts.Nuke) == 0 {
+		opts.Nuke = defaultNuke
+	}
+
+	if len(opts.Pre) == 0 {
+		opts.Pre = defaultPre
+	}
+
+	if len(opts.Infected) == 0 {
+		opts.Infected = defaultInfected
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	all := map[string]string{
+		EventNewDir:   opts.NewDir,
+		EventComplete: opts.Complete,
+		EventNuke:     opts.Nuke,
+		EventPre:      opts.Pre,
+		EventInfected: opts.Infected,
+	}
+
+	templates := all
+
+	if len(opts.Events) > 0 {
+		templates = make(map[string]string, len(opts.Events))
+
+		for _, e := range opts.Events {
+			e = strings.ToLower(e)
+
+			if _, ok := events[e]; !ok {
+				return nil, errors.Errorf("unknown event '%s'", e)
+			}
+
+			templates[e] = all[e]
+		}
+	}
+
+	a := BotAnnouncer{
+		AnnounceOpts: opts,
+		targets:      targets,
+		templates:    templates,
+		client: &http.Client{
+			Timeout: time.Duration(opts.Timeout) * time.Second,
+		},
+		queue: make(chan message, opts.QueueSize),
+	}
+
+	a.wg.Add(1)
+	go a.run()
+
+	return &a, nil
+}
+
+// Announce renders the template of the event with the cookies and queues it
+// for every target. If the queue is full the announcement is dropped and
+// logged, once closed announcements are ignored
+func (a *BotAnnouncer) Announce(event string, cookies cookie.Cookies) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	if a.closed || len(a.targets) == 0 {
+		return
+	}
+
+	template, ok := a.templates[event]
+	if !ok {
+		return
+	}
+
+	text := cookies.Expand(template)
+
+	for _, t := range a.targets {
+		select {
+		case a.queue <- message{t, text}:
+		default:
+			a.Logger().Warn("announce queue full, dropped announcement", logging.F("event", event), logging.F("target", t.String()))
+		}
+	}
+}
+
+// run sends queued announcements until the queue is closed
+func (a *BotAnnouncer) run() {
+	defer a.wg.Done()
+
+	for m := range a.queue {
+		if err := m.target.Send(a.client, m.text); err != nil {
+			a.Logger().Warn("announce failed", logging.F("target", m.target.String()), logging.Err(err))
+		}
+	}
+}
+
+// Close stops accepting announcements and waits for those queued to be sent
+func (a *BotAnnouncer) Close() error {
+	a.mtx.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mtx.Unlock()
+
+	a.wg.Wait()
+	return nil
+}
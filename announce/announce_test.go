@@ -0,0 +1,156 @@
+package announce
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+func TestNewTarget(t *testing.T) {
+	var tests = []struct {
+		line     string
+		expected error
+	}{
+		{"discord token 123456789", nil},
+		{"TELEGRAM 123:abc -100123", nil},
+		{"telegram 123:abc @channel", nil},
+		{"discord token", errors.New("target requires 3 fields")},
+		{"discord token general", errors.New("discord channel id must be a number: 'general'")},
+		{"irc token #channel", errors.New("unknown target 'irc'")},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTarget(tt.line)
+		checkErr(t, err, tt.expected)
+	}
+}
+
+func TestNewBotAnnouncerUnknownEvent(t *testing.T) {
+	_, err := NewBotAnnouncer(&AnnounceOpts{Events: []string{"nuke", "login"}}, nil)
+	checkErr(t, err, errors.New("unknown event 'login'"))
+}
+
+// request is what a test api received
+type request struct {
+	path          string
+	authorization string
+	body          map[string]interface{}
+}
+
+func newTestAPI(t *testing.T) (*httptest.Server, func() []request) {
+	t.Helper()
+
+	var (
+		mtx      sync.Mutex
+		received []request
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		checkErr(t, err, nil)
+
+		req := request{
+			path:          r.URL.Path,
+			authorization: r.Header.Get("Authorization"),
+		}
+		checkErr(t, json.Unmarshal(b, &req.body), nil)
+
+		mtx.Lock()
+		received = append(received, req)
+		mtx.Unlock()
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, func() []request {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return received
+	}
+}
+
+func TestBotAnnouncer(t *testing.T) {
+	srv, received := newTestAPI(t)
+
+	targets := []Target{
+		&Discord{token: "discord-token", channel: "123", api: srv.URL},
+		&Telegram{token: "telegram-token", chat: "@site", api: srv.URL},
+	}
+
+	a, err := NewBotAnnouncer(&AnnounceOpts{Events: []string{"newdir"}, NewDir: "NEW %[release] by %[user]"}, targets)
+	checkErr(t, err, nil)
+
+	a.Announce(EventNewDir, cookie.Cookies{"release": "Some.Release-GRP", "user": "user"})
+	// not in events
+	a.Announce(EventNuke, cookie.Cookies{"release": "Some.Release-GRP"})
+
+	checkErr(t, a.Close(), nil)
+
+	// ignored once closed
+	a.Announce(EventNewDir, cookie.Cookies{})
+
+	got := received()
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 requests got %d", len(got))
+	}
+
+	for _, r := range got {
+		switch r.path {
+		case "/channels/123/messages":
+			if r.authorization != "Bot discord-token" {
+				t.Errorf("unexpected discord authorization '%s'", r.authorization)
+			}
+
+			if r.body["content"] != "NEW Some.Release-GRP by user" {
+				t.Errorf("unexpected discord content '%v'", r.body["content"])
+			}
+
+		case "/bottelegram-token/sendMessage":
+			if r.body["chat_id"] != "@site" || r.body["text"] != "NEW Some.Release-GRP by user" {
+				t.Errorf("unexpected telegram body %v", r.body)
+			}
+
+		default:
+			t.Errorf("unexpected path '%s'", r.path)
+		}
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	d := &Discord{token: "token", channel: "123", api: srv.URL}
+
+	checkErr(t, d.Send(http.DefaultClient, "text"), errors.New("unexpected status 401 Unauthorized"))
+
+	// the token isn't leaked in errors
+	srv.Close()
+
+	tg := &Telegram{token: "secret-token", chat: "1", api: srv.URL}
+
+	err := tg.Send(http.DefaultClient, "text")
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("expected an error without the token but got '%v'", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("unexpected '%s'", got)
+	}
+
+	if got := truncate("ééééééééééé", 10); got != "ééééééé..." {
+		t.Errorf("unexpected '%s'", got)
+	}
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// defaultStatsInterval is the seconds between ServerStats when the request
+// doesn't give an interval
+const defaultStatsInterval = 5
+
+// defaultAuditLimit is the most entries returned by Audit when the request
+// doesn't give a limit
+const defaultAuditLimit = 100
+
+// auditActor is the actor of entries recorded for calls to the api
+const auditActor = "api"
+
+// AdminService implements AdminServer
+type AdminService struct {
+	UnimplementedAdminServer
+
+	auth    acl.Authenticator
+	trail   audit.Log
+	control Control
+	logger  logging.Logger
+}
+
+// NewAdminService returns an AdminService using the Authenticator, audit
+// Log and Control. Calls that change anything are recorded in the audit Log
+func NewAdminService(auth acl.Authenticator, trail audit.Log, control Control) *AdminService {
+	return &AdminService{
+		auth:    auth,
+		trail:   trail,
+		control: control,
+		logger:  logging.Stderr,
+	}
+}
+
+func (a *AdminService) ListUsers(ctx context.Context, req *ListUsersRequest) (*ListUsersResponse, error) {
+	users, err := a.auth.GetUsersWithPrefix(req.Prefix)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	resp := ListUsersResponse{
+		Users: make([]*User, 0, len(users)),
+	}
+
+	for _, u := range users {
+		resp.Users = append(resp.Users, newUser(u))
+	}
+
+	return &resp, nil
+}
+
+func (a *AdminService) GetUser(ctx context.Context, req *GetUserRequest) (*User, error) {
+	return a.user(req.Name)
+}
+
+// AddUser adds a user from the default template as SITE ADDUSER does
+func (a *AdminService) AddUser(ctx context.Context, req *AddUserRequest) (*User, error) {
+	t, ok := a.control.Template("")
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no default template")
+	}
+
+	u, err := acl.AddUserFromTemplate(a.auth, t, req.Name, req.Password, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if len(req.Group) > 0 {
+		if err := a.auth.AddUserToGroup(u.Name, req.Group); err != nil && err != acl.ErrUserInGroup {
+			return nil, toStatus(err)
+		}
+
+		if err := a.auth.SetPrimaryGroup(u.Name, req.Group); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	a.record(ctx, "adduser", u.Name, "group="+req.Group)
+
+	return a.user(u.Name)
+}
+
+// DeleteUser marks the user as deleted, with purge they are then removed
+// for good
+func (a *AdminService) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	if err := a.auth.DeleteUser(req.Name); err != nil && !(req.Purge && err == acl.ErrUserDeleted) {
+		return nil, toStatus(err)
+	}
+
+	if req.Purge {
+		if err := a.auth.PurgeUser(req.Name); err != nil {
+			return nil, toStatus(err)
+		}
+
+		a.record(ctx, "purge", req.Name)
+	} else {
+		a.record(ctx, "deluser", req.Name)
+	}
+
+	return &DeleteUserResponse{}, nil
+}
+
+func (a *AdminService) ChangePassword(ctx context.Context, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	if err := a.auth.ChangePassword(req.Name, req.Password); err != nil {
+		return nil, toStatus(err)
+	}
+
+	a.record(ctx, "chpass", req.Name)
+
+	return &ChangePasswordResponse{}, nil
+}
+
+func (a *AdminService) ChangeFlags(ctx context.Context, req *ChangeFlagsRequest) (*User, error) {
+	for _, f := range req.Add {
+		if err := a.auth.AddFlag(req.Name, f); err != nil && err != acl.ErrFlagExists {
+			return nil, toStatus(err)
+		}
+	}
+
+	for _, f := range req.Remove {
+		if err := a.auth.RemoveFlag(req.Name, f); err != nil && err != acl.ErrFlagDoesntExist {
+			return nil, toStatus(err)
+		}
+	}
+
+	a.record(ctx, "flags", req.Name, changes(req.Add, req.Remove)...)
+
+	return a.user(req.Name)
+}
+
+func (a *AdminService) ChangeGroups(ctx context.Context, req *ChangeGroupsRequest) (*User, error) {
+	for _, g := range req.Add {
+		if err := a.auth.AddUserToGroup(req.Name, g); err != nil && err != acl.ErrUserInGroup {
+			return nil, toStatus(err)
+		}
+	}
+
+	for _, g := range req.Remove {
+		if err := a.auth.RemoveUserFromGroup(req.Name, g); err != nil && err != acl.ErrUserNotInGroup {
+			return nil, toStatus(err)
+		}
+	}
+
+	a.record(ctx, "groups", req.Name, changes(req.Add, req.Remove)...)
+
+	return a.user(req.Name)
+}
+
+// AdjustCredits gives or takes credits, taking more than the user has
+// fails
+func (a *AdminService) AdjustCredits(ctx context.Context, req *AdjustCreditsRequest) (*User, error) {
+	var err error
+
+	if req.Bytes < 0 {
+		_, err = a.auth.DecrCredits(req.Name, -req.Bytes, false)
+	} else {
+		_, err = a.auth.IncrCredits(req.Name, req.Bytes)
+	}
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	a.record(ctx, "credits", req.Name, strconv.FormatInt(req.Bytes, 10))
+
+	return a.user(req.Name)
+}
+
+func (a *AdminService) Who(ctx context.Context, req *WhoRequest) (*WhoResponse, error) {
+	infos := a.control.Sessions()
+
+	resp := WhoResponse{
+		Sessions: make([]*Session, 0, len(infos)),
+	}
+
+	for _, info := range infos {
+		resp.Sessions = append(resp.Sessions, &Session{
+			Login:       info.Login,
+			Anonymous:   info.Anonymous,
+			Ident:       info.Ident,
+			Ip:          info.IP,
+			Command:     info.Command,
+			Cwd:         info.CWD,
+			File:        info.File,
+			Transfer:    string(info.Transfer),
+			Bytes:       info.Bytes,
+			Speed:       info.Speed,
+			Connected:   unix(info.Connected),
+			IdleSeconds: info.Idle.Seconds(),
+		})
+	}
+
+	return &resp, nil
+}
+
+func (a *AdminService) Kick(ctx context.Context, req *KickRequest) (*KickResponse, error) {
+	if len(req.User) == 0 && len(req.Ip) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user or ip required")
+	}
+
+	n := a.control.Kick(func(info cmd.SessionInfo) bool {
+		if len(req.User) > 0 && !strings.EqualFold(info.Login, req.User) {
+			return false
+		}
+		if len(req.Ip) > 0 && info.IP != req.Ip {
+			return false
+		}
+		return true
+	})
+
+	target := req.User
+	if len(target) == 0 {
+		target = req.Ip
+	}
+
+	a.record(ctx, "kick", target, "user="+req.User, "ip="+req.Ip, "kicked="+strconv.Itoa(n))
+
+	return &KickResponse{Kicked: int32(n)}, nil
+}
+
+func (a *AdminService) StreamStats(req *StreamStatsRequest, stream Admin_StreamStatsServer) error {
+	interval := time.Duration(req.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultStatsInterval * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		info := a.control.Info()
+
+		err := stream.Send(&ServerStats{
+			Version:       info.Version,
+			Started:       unix(info.Started),
+			Connections:   info.Connections,
+			Uploads:       info.Uploads,
+			Downloads:     info.Downloads,
+			UploadBytes:   info.UploadBytes,
+			DownloadBytes: info.DownloadBytes,
+			Sessions:      int32(len(a.control.Sessions())),
+			UploadSpeed:   info.UploadSpeed,
+			DownloadSpeed: info.DownloadSpeed,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (a *AdminService) Audit(ctx context.Context, req *AuditRequest) (*AuditResponse, error) {
+	q := audit.Query{
+		Actor:  req.Actor,
+		Target: req.Target,
+		Action: req.Action,
+		Limit:  int(req.Limit),
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = defaultAuditLimit
+	}
+
+	if req.Since > 0 {
+		q.Since = time.Unix(req.Since, 0)
+	}
+
+	entries, err := a.trail.Search(q)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := AuditResponse{
+		Entries: make([]*AuditEntry, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &AuditEntry{
+			Time:   unix(e.Time),
+			Actor:  e.Actor,
+			Ip:     e.IP,
+			Action: e.Action,
+			Target: e.Target,
+			Params: e.Params,
+		})
+	}
+
+	return &resp, nil
+}
+
+// record adds a call that changed something to the audit Log, a failure is
+// logged as the change has already been made
+func (a *AdminService) record(ctx context.Context, action, target string, params ...string) {
+	e := audit.Entry{
+		Actor:  auditActor,
+		Action: action,
+		Target: target,
+		Params: params,
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		e.IP = p.Addr.String()
+
+		if host, _, err := net.SplitHostPort(e.IP); err == nil {
+			e.IP = host
+		}
+	}
+
+	if err := a.trail.Record(e); err != nil {
+		a.logger.Error("unable to record audit entry", logging.F("action", action), logging.F("target", target), logging.Err(err))
+	}
+}
+
+// changes describes additions and removals as +name and -name
+func changes(add, remove []string) []string {
+	var c []string
+
+	for _, a := range add {
+		c = append(c, "+"+a)
+	}
+
+	for _, r := range remove {
+		c = append(c, "-"+r)
+	}
+
+	return c
+}
+
+// user returns the named user
+func (a *AdminService) user(name string) (*User, error) {
+	u, err := a.auth.GetUser(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return newUser(u), nil
+}
+
+// newUser describes the acl.User, secrets such as the password are never
+// included
+func newUser(u *acl.User) *User {
+	user := User{
+		Name:         u.Name,
+		PrimaryGroup: u.PrimaryGroup,
+		Credits:      u.Credits,
+		Ratio:        int32(u.Ratio),
+		Tagline:      u.Tagline,
+		Comment:      u.Comment,
+		Logins:       int32(u.Logins),
+		Uploads:      int32(u.Uploads),
+		Downloads:    int32(u.Downloads),
+		Created:      unix(u.CreatedAt),
+		LastLogin:    unix(u.LastLoginAt),
+	}
+
+	for g := range u.Groups {
+		user.Groups = append(user.Groups, g)
+	}
+
+	for f := range u.Flags {
+		user.Flags = append(user.Flags, f)
+	}
+
+	_, user.Deleted = u.Flags[acl.FlagDeleted]
+
+	sort.Strings(user.Groups)
+	sort.Strings(user.Flags)
+
+	return &user
+}
+
+// unix returns the time in unix seconds, zero for the zero time
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// toStatus maps acl errors on to gRPC codes
+func toStatus(err error) error {
+	switch errors.Cause(err) {
+	case acl.ErrUserDoesntExist, acl.ErrGroupDoesntExist:
+		return status.Error(codes.NotFound, err.Error())
+
+	case acl.ErrUserExists, acl.ErrGroupExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+
+	case acl.ErrPasswordPolicy, acl.ErrMaskPolicy, acl.ErrBadInput:
+		return status.Error(codes.InvalidArgument, err.Error())
+
+	case acl.ErrUserDeleted, acl.ErrUserNotDeleted, acl.ErrNotEnoughCredits, acl.ErrGroupFull, acl.ErrNoLeechSlots:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,148 @@
+// Package api serves the Admin gRPC service defined in admin.proto so that
+// programs can manage users, see who is online, kick sessions and follow
+// the server's totals without going through SITE commands
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Control is what the Admin service needs of the FTP server
+type Control interface {
+	Sessions() []cmd.SessionInfo
+	Info() cmd.ServerInfo
+	Kick(func(cmd.SessionInfo) bool) int
+	Template(string) (*acl.Template, bool)
+	TLSConfig() *tls.Config
+}
+
+type APIOpts struct {
+	// the api is only served when a port is set
+	Host string `goftpd:"host"`
+	Port int    `goftpd:"port"`
+	// required, clients send it as `authorization: Bearer <token>`
+	Token string `goftpd:"token"`
+	// serve using the FTP server's certificate
+	TLS bool `goftpd:"tls"`
+
+	logger logging.Logger
+}
+
+// Enabled checks to see if the api should be served
+func (o *APIOpts) Enabled() bool { return o.Port > 0 }
+
+// SetLogger sets where failures to record to the audit log are logged,
+// defaults to logging.Stderr
+func (o *APIOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where failures to record to the audit log are logged
+func (o *APIOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// Server serves the Admin service
+type Server struct {
+	*APIOpts
+
+	grpc *grpc.Server
+}
+
+// NewServer returns a Server for the Admin service using the Authenticator,
+// audit Log and Control. Fails if no token is set
+func NewServer(opts *APIOpts, auth acl.Authenticator, trail audit.Log, control Control) (*Server, error) {
+	if len(opts.Token) == 0 {
+		return nil, errors.New("api token required")
+	}
+
+	s := Server{
+		APIOpts: opts,
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	}
+
+	if opts.TLS {
+		if control.TLSConfig() == nil {
+			return nil, errors.New("api tls requires the server's tls certificate")
+		}
+
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(control.TLSConfig())))
+	}
+
+	s.grpc = grpc.NewServer(serverOpts...)
+
+	service := NewAdminService(auth, trail, control)
+	service.logger = opts.Logger()
+
+	RegisterAdminServer(s.grpc, service)
+
+	return &s, nil
+}
+
+// ListenAndServe listens on the configured Host and Port until the context
+// is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(s.Host, fmt.Sprintf("%d", s.Port)))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.grpc.GracefulStop()
+	}()
+
+	return s.grpc.Serve(l)
+}
+
+// authorize checks the token sent in the metadata of the call
+func (s *Server) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, v := range md.Get("authorization") {
+		token := strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "bad or missing token")
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testToken = "secret"
+
+// newTestClient serves the api over an in memory listener
+func newTestClient(t *testing.T, control *testControl) AdminClient {
+	t.Helper()
+
+	s, err := NewServer(&APIOpts{Token: testToken}, newMemoryAuthenticator(t), newMemoryAuditLog(t), control)
+	checkErr(t, err, nil)
+
+	l := bufconn.Listen(1 << 20)
+
+	go s.grpc.Serve(l)
+	t.Cleanup(s.grpc.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	checkErr(t, err, nil)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return NewAdminClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func checkCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if status.Code(err) != code {
+		t.Fatalf("expected code %s but got '%v'", code, err)
+	}
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	_, err := NewServer(&APIOpts{}, newMemoryAuthenticator(t), newMemoryAuditLog(t), &testControl{})
+	checkErr(t, err, errors.New("api token required"))
+}
+
+func TestToken(t *testing.T) {
+	client := newTestClient(t, &testControl{})
+
+	_, err := client.Who(context.Background(), &WhoRequest{})
+	checkCode(t, err, codes.Unauthenticated)
+
+	_, err = client.Who(withToken("wrong"), &WhoRequest{})
+	checkCode(t, err, codes.Unauthenticated)
+
+	_, err = client.Who(withToken(testToken), &WhoRequest{})
+	checkErr(t, err, nil)
+}
+
+func TestUsers(t *testing.T) {
+	client := newTestClient(t, &testControl{})
+	ctx := withToken(testToken)
+
+	u, err := client.AddUser(ctx, &AddUserRequest{Name: "bob", Password: "password"})
+	checkErr(t, err, nil)
+
+	if u.Name != "bob" || u.Ratio != 3 || len(u.Flags) != 1 || u.Flags[0] != "3" {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+
+	_, err = client.AddUser(ctx, &AddUserRequest{Name: "bob", Password: "password"})
+	checkCode(t, err, codes.AlreadyExists)
+
+	_, err = client.GetUser(ctx, &GetUserRequest{Name: "alice"})
+	checkCode(t, err, codes.NotFound)
+
+	u, err = client.ChangeFlags(ctx, &ChangeFlagsRequest{Name: "bob", Add: []string{"1"}, Remove: []string{"3"}})
+	checkErr(t, err, nil)
+
+	if len(u.Flags) != 1 || u.Flags[0] != "1" {
+		t.Fatalf("unexpected flags: %v", u.Flags)
+	}
+
+	u, err = client.AdjustCredits(ctx, &AdjustCreditsRequest{Name: "bob", Bytes: 1024})
+	checkErr(t, err, nil)
+
+	if u.Credits != 1024 {
+		t.Fatalf("expected 1024 credits but got %d", u.Credits)
+	}
+
+	_, err = client.AdjustCredits(ctx, &AdjustCreditsRequest{Name: "bob", Bytes: -2048})
+	checkCode(t, err, codes.FailedPrecondition)
+
+	u, err = client.AdjustCredits(ctx, &AdjustCreditsRequest{Name: "bob", Bytes: -1000})
+	checkErr(t, err, nil)
+
+	if u.Credits != 24 {
+		t.Fatalf("expected 24 credits but got %d", u.Credits)
+	}
+
+	list, err := client.ListUsers(ctx, &ListUsersRequest{Prefix: "b"})
+	checkErr(t, err, nil)
+
+	if len(list.Users) != 1 {
+		t.Fatalf("expected 1 user but got %d", len(list.Users))
+	}
+
+	_, err = client.DeleteUser(ctx, &DeleteUserRequest{Name: "bob"})
+	checkErr(t, err, nil)
+
+	u, err = client.GetUser(ctx, &GetUserRequest{Name: "bob"})
+	checkErr(t, err, nil)
+
+	if !u.Deleted {
+		t.Fatal("expected user to be deleted")
+	}
+
+	_, err = client.DeleteUser(ctx, &DeleteUserRequest{Name: "bob", Purge: true})
+	checkErr(t, err, nil)
+
+	_, err = client.GetUser(ctx, &GetUserRequest{Name: "bob"})
+	checkCode(t, err, codes.NotFound)
+}
+
+func TestWhoAndKick(t *testing.T) {
+	control := testControl{
+		sessions: []cmd.SessionInfo{
+			{Login: "bob", IP: "10.0.0.1", Transfer: cmd.TransferUpload},
+			{Login: "bob", IP: "10.0.0.2"},
+			{Login: "alice", IP: "10.0.0.1"},
+		},
+	}
+
+	client := newTestClient(t, &control)
+	ctx := withToken(testToken)
+
+	who, err := client.Who(ctx, &WhoRequest{})
+	checkErr(t, err, nil)
+
+	if len(who.Sessions) != 3 || who.Sessions[0].Transfer != string(cmd.TransferUpload) {
+		t.Fatalf("unexpected sessions: %v", who.Sessions)
+	}
+
+	_, err = client.Kick(ctx, &KickRequest{})
+	checkCode(t, err, codes.InvalidArgument)
+
+	kick, err := client.Kick(ctx, &KickRequest{User: "bob", Ip: "10.0.0.1"})
+	checkErr(t, err, nil)
+
+	if kick.Kicked != 1 || control.kicked[0].IP != "10.0.0.1" || control.kicked[0].Login != "bob" {
+		t.Fatalf("unexpected kick: %d %v", kick.Kicked, control.kicked)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	control := testControl{
+		sessions: []cmd.SessionInfo{{Login: "bob", IP: "10.0.0.1"}},
+	}
+
+	client := newTestClient(t, &control)
+	ctx := withToken(testToken)
+
+	_, err := client.AddUser(ctx, &AddUserRequest{Name: "bob", Password: "password"})
+	checkErr(t, err, nil)
+
+	_, err = client.ChangePassword(ctx, &ChangePasswordRequest{Name: "bob", Password: "secret"})
+	checkErr(t, err, nil)
+
+	_, err = client.AdjustCredits(ctx, &AdjustCreditsRequest{Name: "bob", Bytes: 1024})
+	checkErr(t, err, nil)
+
+	// failures aren't recorded
+	_, err = client.AdjustCredits(ctx, &AdjustCreditsRequest{Name: "bob", Bytes: -4096})
+	checkCode(t, err, codes.FailedPrecondition)
+
+	_, err = client.Kick(ctx, &KickRequest{User: "bob"})
+	checkErr(t, err, nil)
+
+	resp, err := client.Audit(ctx, &AuditRequest{Target: "bob"})
+	checkErr(t, err, nil)
+
+	expected := []string{"kick", "credits", "chpass", "adduser"}
+
+	if len(resp.Entries) != len(expected) {
+		t.Fatalf("expected %d entries but got %v", len(expected), resp.Entries)
+	}
+
+	for i, action := range expected {
+		e := resp.Entries[i]
+
+		if e.Action != action || e.Actor != "api" || e.Time == 0 {
+			t.Errorf("unexpected entry %d: %+v", i, e)
+		}
+
+		for _, p := range e.Params {
+			if strings.Contains(p, "password") || strings.Contains(p, "secret") {
+				t.Errorf("expected passwords not to be recorded: %v", e.Params)
+			}
+		}
+	}
+
+	if resp.Entries[1].Params[0] != "1024" {
+		t.Errorf("expected credits to be recorded: %v", resp.Entries[1].Params)
+	}
+
+	resp, err = client.Audit(ctx, &AuditRequest{Action: "kick", Limit: 1})
+	checkErr(t, err, nil)
+
+	if len(resp.Entries) != 1 || resp.Entries[0].Params[2] != "kicked=1" {
+		t.Fatalf("unexpected kick entries: %v", resp.Entries)
+	}
+}
+
+func TestStreamStats(t *testing.T) {
+	control := testControl{
+		info: cmd.ServerInfo{
+			Version:     "test",
+			Started:     time.Unix(1600000000, 0),
+			Connections: 10,
+			Uploads:     2,
+			UploadSpeed: 1024,
+		},
+		sessions: []cmd.SessionInfo{{Login: "bob"}},
+	}
+
+	client := newTestClient(t, &control)
+
+	ctx, cancel := context.WithCancel(withToken(testToken))
+	defer cancel()
+
+	stream, err := client.StreamStats(ctx, &StreamStatsRequest{Interval: 1})
+	checkErr(t, err, nil)
+
+	stats, err := stream.Recv()
+	checkErr(t, err, nil)
+
+	if stats.Version != "test" || stats.Started != 1600000000 || stats.Connections != 10 || stats.Uploads != 2 || stats.Sessions != 1 || stats.UploadSpeed != 1024 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
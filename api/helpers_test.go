@@ -0,0 +1,90 @@
+package api
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("expected nil but got '%s'", got)
+	}
+
+	if got.Error() != expected.Error() {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func newMemoryAuthenticator(t *testing.T) *acl.BadgerAuthenticator {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return acl.NewBadgerAuthenticator(&acl.AuthenticatorOpts{}, db)
+}
+
+func newMemoryAuditLog(t *testing.T) *audit.BadgerLog {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return audit.NewBadgerLog(&audit.AuditOpts{}, db)
+}
+
+// testControl implements Control with a fixed set of sessions
+type testControl struct {
+	info     cmd.ServerInfo
+	sessions []cmd.SessionInfo
+	kicked   []cmd.SessionInfo
+}
+
+func (c *testControl) Sessions() []cmd.SessionInfo { return c.sessions }
+
+func (c *testControl) Info() cmd.ServerInfo { return c.info }
+
+func (c *testControl) Kick(fn func(cmd.SessionInfo) bool) int {
+	var n int
+	for _, s := range c.sessions {
+		if fn(s) {
+			c.kicked = append(c.kicked, s)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *testControl) Template(name string) (*acl.Template, bool) {
+	return &acl.Template{Flags: []string{"3"}, Ratio: 3}, true
+}
+
+func (c *testControl) TLSConfig() *tls.Config { return nil }
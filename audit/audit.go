@@ -0,0 +1,208 @@
+// Package audit keeps an append-only log of privileged actions, who changed
+// which user, nuked or wiped what and who was kicked, so staff can see who
+// did what after the fact
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "audit:"
+
+// Entry is a recorded action
+type Entry struct {
+	Time time.Time
+	// the user who took the action, api for calls to the admin api, scan
+	// for infected uploads and auto for automatic bans
+	Actor string
+	// where the actor connected from
+	IP string
+	// i.e. adduser, nuke or kick
+	Action string
+	// the user, group or path acted on, empty if there isn't one
+	Target string
+	// the parameters of the action, secrets such as passwords are never
+	// recorded
+	Params []string
+}
+
+// Query filters entries, empty fields match everything. Names are matched
+// ignoring case
+type Query struct {
+	Actor  string
+	Target string
+	Action string
+	// only entries recorded at or after
+	Since time.Time
+	// the most entries returned, zero for all
+	Limit int
+}
+
+// match checks to see if the Entry matches the Query
+func (q Query) match(e Entry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+
+	if len(q.Actor) > 0 && !strings.EqualFold(q.Actor, e.Actor) {
+		return false
+	}
+
+	if len(q.Target) > 0 && !strings.EqualFold(q.Target, e.Target) {
+		return false
+	}
+
+	if len(q.Action) > 0 && !strings.EqualFold(q.Action, e.Action) {
+		return false
+	}
+
+	return true
+}
+
+// Log records privileged actions
+type Log interface {
+	Record(Entry) error
+	Search(Query) ([]Entry, error)
+	Close() error
+}
+
+type AuditOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// BadgerLog implements Log using a badger key/value store. Entries are keyed
+// by the time they were recorded and are never changed or removed
+type BadgerLog struct {
+	*AuditOpts
+	db *badger.DB
+
+	// keeps keys unique when entries are recorded in the same nanosecond
+	mtx  sync.Mutex
+	last int64
+}
+
+// NewBadgerLog takes in options and a badger DB
+func NewBadgerLog(opts *AuditOpts, db *badger.DB) *BadgerLog {
+	return &BadgerLog{
+		AuditOpts: opts,
+		db:        db,
+	}
+}
+
+// key orders entries by the time they were recorded
+func (l *BadgerLog) key(nanos int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", keyPrefix, nanos))
+}
+
+// Record appends the Entry, setting its Time if it isn't already set
+func (l *BadgerLog) Record(e Entry) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	nanos := e.Time.UnixNano()
+	if nanos <= l.last {
+		nanos = l.last + 1
+	}
+
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(e); err != nil {
+		return err
+	}
+
+	err := l.db.Update(func(tx *badger.Txn) error {
+		key := l.key(nanos)
+
+		// entries are never replaced
+		for {
+			_, err := tx.Get(key)
+			if err == badger.ErrKeyNotFound {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			nanos++
+			key = l.key(nanos)
+		}
+
+		return tx.Set(key, b.Bytes())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	l.last = nanos
+
+	return nil
+}
+
+// Search returns the entries matching the Query, newest first
+func (l *BadgerLog) Search(q Query) ([]Entry, error) {
+	var entries []Entry
+
+	err := l.db.View(func(tx *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+		opts.Reverse = true
+
+		it := tx.NewIterator(opts)
+		defer it.Close()
+
+		// reverse iteration starts from the last key before the seek,
+		// ~ sorts after every digit
+		for it.Seek([]byte(keyPrefix + "~")); it.Valid(); it.Next() {
+			// keys are never earlier than the Time of their entry so
+			// every entry after one keyed before Since is too early
+			if !q.Since.IsZero() && string(it.Item().Key()) < string(l.key(q.Since.UnixNano())) {
+				break
+			}
+
+			var e Entry
+
+			err := it.Item().Value(func(val []byte) error {
+				return msgpack.Unmarshal(val, &e)
+			})
+			if err != nil {
+				return err
+			}
+
+			if !q.match(e) {
+				continue
+			}
+
+			entries = append(entries, e)
+
+			if q.Limit > 0 && len(entries) >= q.Limit {
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Close closes the underlying badger store
+func (l *BadgerLog) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordAndSearch(t *testing.T) {
+	l := newMemoryLog(t)
+	defer closeMemoryLog(t, l)
+
+	start := time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Time: start, Actor: "admin", IP: "127.0.0.1", Action: "adduser", Target: "bob", Params: []string{"bob"}},
+		{Time: start.Add(time.Minute), Actor: "admin", Action: "nuke", Target: "/mp3/release", Params: []string{"release", "3", "bad"}},
+		{Time: start.Add(2 * time.Minute), Actor: "siteop", Action: "change", Target: "bob", Params: []string{"bob", "flags", "+1"}},
+		{Time: start.Add(3 * time.Minute), Actor: "api", Action: "kick", Target: "bob"},
+	}
+
+	for _, e := range entries {
+		checkErr(t, l.Record(e), nil)
+	}
+
+	var tests = []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"all", Query{}, []string{"kick", "change", "nuke", "adduser"}},
+		{"limit", Query{Limit: 2}, []string{"kick", "change"}},
+		{"actor", Query{Actor: "ADMIN"}, []string{"nuke", "adduser"}},
+		{"target", Query{Target: "bob"}, []string{"kick", "change", "adduser"}},
+		{"action", Query{Action: "nuke"}, []string{"nuke"}},
+		{"since", Query{Since: start.Add(90 * time.Second)}, []string{"kick", "change"}},
+		{"combined", Query{Target: "bob", Actor: "admin"}, []string{"adduser"}},
+		{"none", Query{Actor: "nobody"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Search(tt.query)
+			checkErr(t, err, nil)
+
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d entries got %+v", len(tt.expected), got)
+			}
+
+			for i, action := range tt.expected {
+				if got[i].Action != action {
+					t.Errorf("expected entry %d to be %s got %s", i, action, got[i].Action)
+				}
+			}
+		})
+	}
+
+	got, err := l.Search(Query{Action: "adduser"})
+	checkErr(t, err, nil)
+
+	if got[0].IP != "127.0.0.1" || !got[0].Time.Equal(start) || len(got[0].Params) != 1 {
+		t.Fatalf("unexpected entry %+v", got[0])
+	}
+}
+
+func TestRecordSameTime(t *testing.T) {
+	l := newMemoryLog(t)
+	defer closeMemoryLog(t, l)
+
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		checkErr(t, l.Record(Entry{Time: now, Actor: "admin", Action: "wipe"}), nil)
+	}
+
+	// entries without a time are given one
+	checkErr(t, l.Record(Entry{Actor: "admin", Action: "wipe"}), nil)
+
+	got, err := l.Search(Query{})
+	checkErr(t, err, nil)
+
+	if len(got) != 6 {
+		t.Fatalf("expected every entry to be kept got %d", len(got))
+	}
+
+	for _, e := range got {
+		if e.Time.IsZero() {
+			t.Fatalf("expected entry to have a time %+v", e)
+		}
+	}
+}
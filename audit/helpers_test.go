@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryLog(t *testing.T) *BadgerLog {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	return NewBadgerLog(&AuditOpts{}, db)
+}
+
+func closeMemoryLog(t *testing.T, l *BadgerLog) {
+	t.Helper()
+	if err := l.Close(); err != nil {
+		t.Fatalf("error closing audit log: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
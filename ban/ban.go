@@ -0,0 +1,359 @@
+// Package ban keeps a persistent list of banned ips and networks, checked
+// when a connection is accepted. Like fail2ban, addresses are banned for a
+// while after repeated failures such as bad logins
+package ban
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "ban:"
+
+// AutoBan is who automatic bans are made by
+const AutoBan = "auto"
+
+var ErrNotBanned = errors.New("not banned")
+
+// Ban is a banned ip or network
+type Ban struct {
+	// the network in CIDR notation, a single ip is a /32 or /128
+	Network string
+	Reason  string
+	// who made the ban, AutoBan for automatic bans
+	By      string
+	Created time.Time
+	// zero never expires
+	Expires time.Time
+}
+
+// Expired checks to see if the ban has expired by now
+func (b Ban) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && !now.Before(b.Expires)
+}
+
+// String returns the ban as shown to users
+func (b Ban) String() string {
+	var s strings.Builder
+
+	s.WriteString(b.Network)
+	s.WriteString(" by ")
+	s.WriteString(b.By)
+
+	if b.Expires.IsZero() {
+		s.WriteString(" forever")
+	} else {
+		s.WriteString(" until ")
+		s.WriteString(b.Expires.Format("2006-01-02 15:04:05"))
+	}
+
+	if len(b.Reason) > 0 {
+		s.WriteString(": ")
+		s.WriteString(b.Reason)
+	}
+
+	return s.String()
+}
+
+// Bans stores the banned networks
+type Bans interface {
+	// Add bans the ip or cidr for the duration, zero bans forever. Adding
+	// an existing ban replaces it
+	Add(network, reason, by string, d time.Duration) (*Ban, error)
+	// Remove lifts the ban of the ip or cidr
+	Remove(string) error
+	// List returns the bans that haven't expired, oldest first
+	List() ([]Ban, error)
+	// Banned returns the ban covering the ip if there is one
+	Banned(net.IP) (*Ban, bool)
+	// Fail records a failure from the ip, once it has failed too many
+	// times the ip is banned and the ban is returned
+	Fail(ip net.IP, reason string) (*Ban, error)
+	Close() error
+}
+
+type BanOpts struct {
+	DB string `goftpd:"db"`
+	// an ip with MaxFailures failures within FindTime seconds is banned
+	// for BanTime seconds. zero max_failures disables automatic bans
+	MaxFailures int `goftpd:"max_failures"`
+	FindTime    int `goftpd:"find_time"`
+	BanTime     int `goftpd:"ban_time"`
+	// ips or cidrs never banned automatically
+	Ignore []string `goftpd:"ignore"`
+}
+
+// ParseNetwork parses an ip or cidr, returning the network it covers
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, errors.Errorf("'%s' is not an ip or cidr", s)
+	}
+
+	return network, nil
+}
+
+// entry is a ban and the network it covers
+type entry struct {
+	Ban
+	network *net.IPNet
+}
+
+// BadgerBans implements Bans using a badger key/value store, the bans are
+// kept in memory as well so checking an ip doesn't touch the store
+type BadgerBans struct {
+	*BanOpts
+	db *badger.DB
+
+	ignore []*net.IPNet
+
+	mtx      sync.RWMutex
+	entries  map[string]entry
+	failures map[string][]time.Time
+}
+
+// NewBadgerBans takes in options and a badger DB, loading the bans that
+// haven't expired. Fails on a bad ignore entry
+func NewBadgerBans(opts *BanOpts, db *badger.DB) (*BadgerBans, error) {
+	b := BadgerBans{
+		BanOpts:  opts,
+		db:       db,
+		entries:  make(map[string]entry),
+		failures: make(map[string][]time.Time),
+	}
+
+	for _, i := range opts.Ignore {
+		network, err := ParseNetwork(i)
+		if err != nil {
+			return nil, errors.WithMessage(err, "ignore")
+		}
+		b.ignore = append(b.ignore, network)
+	}
+
+	err := db.View(func(tx *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+
+		it := tx.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var ban Ban
+
+			err := it.Item().Value(func(val []byte) error {
+				return msgpack.Unmarshal(val, &ban)
+			})
+			if err != nil {
+				return err
+			}
+
+			network, err := ParseNetwork(ban.Network)
+			if err != nil {
+				return err
+			}
+
+			b.entries[ban.Network] = entry{ban, network}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// Add bans the ip or cidr, the ban is stored with a ttl so the store
+// forgets it once it expires
+func (b *BadgerBans) Add(network, reason, by string, d time.Duration) (*Ban, error) {
+	n, err := ParseNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	ban := Ban{
+		Network: n.String(),
+		Reason:  reason,
+		By:      by,
+		Created: now,
+	}
+
+	if d > 0 {
+		ban.Expires = now.Add(d)
+	}
+
+	var buf bytes.Buffer
+
+	if err := msgpack.NewEncoder(&buf).Encode(ban); err != nil {
+		return nil, err
+	}
+
+	err = b.db.Update(func(tx *badger.Txn) error {
+		e := badger.NewEntry([]byte(keyPrefix+ban.Network), buf.Bytes())
+		if d > 0 {
+			e = e.WithTTL(d)
+		}
+		return tx.SetEntry(e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.mtx.Lock()
+	b.entries[ban.Network] = entry{ban, n}
+	b.mtx.Unlock()
+
+	return &ban, nil
+}
+
+// Remove lifts the ban of the ip or cidr, it has to match the ban exactly
+func (b *BadgerBans) Remove(network string) error {
+	n, err := ParseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	key := n.String()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	e, ok := b.entries[key]
+	if !ok || e.Expired(time.Now()) {
+		return ErrNotBanned
+	}
+
+	err = b.db.Update(func(tx *badger.Txn) error {
+		return tx.Delete([]byte(keyPrefix + key))
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(b.entries, key)
+
+	return nil
+}
+
+// List returns the bans that haven't expired, oldest first
+func (b *BadgerBans) List() ([]Ban, error) {
+	now := time.Now()
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	bans := make([]Ban, 0, len(b.entries))
+
+	for _, e := range b.entries {
+		if !e.Expired(now) {
+			bans = append(bans, e.Ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Created.Before(bans[j].Created)
+	})
+
+	return bans, nil
+}
+
+// Banned returns the ban covering the ip, expired bans are forgotten
+func (b *BadgerBans) Banned(ip net.IP) (*Ban, bool) {
+	now := time.Now()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for key, e := range b.entries {
+		if e.Expired(now) {
+			delete(b.entries, key)
+			continue
+		}
+
+		if e.network.Contains(ip) {
+			ban := e.Ban
+			return &ban, true
+		}
+	}
+
+	return nil, false
+}
+
+// Fail records a failure from the ip, bans it for BanTime once it has
+// MaxFailures within FindTime. Ignored ips are never banned
+func (b *BadgerBans) Fail(ip net.IP, reason string) (*Ban, error) {
+	if b.MaxFailures <= 0 || ip == nil {
+		return nil, nil
+	}
+
+	for _, n := range b.ignore {
+		if n.Contains(ip) {
+			return nil, nil
+		}
+	}
+
+	now := time.Now()
+	window := now.Add(-time.Duration(b.FindTime) * time.Second)
+
+	key := ip.String()
+
+	b.mtx.Lock()
+
+	var recent []time.Time
+
+	for _, t := range b.failures[key] {
+		if t.After(window) {
+			recent = append(recent, t)
+		}
+	}
+
+	recent = append(recent, now)
+
+	if len(recent) < b.MaxFailures {
+		b.failures[key] = recent
+		b.prune(window)
+		b.mtx.Unlock()
+		return nil, nil
+	}
+
+	delete(b.failures, key)
+	b.mtx.Unlock()
+
+	return b.Add(key, reason, AutoBan, time.Duration(b.BanTime)*time.Second)
+}
+
+// prune forgets ips with no failures since the window, must be called with
+// mtx held
+func (b *BadgerBans) prune(window time.Time) {
+	for key, times := range b.failures {
+		if !times[len(times)-1].After(window) {
+			delete(b.failures, key)
+		}
+	}
+}
+
+// Close closes the underlying store
+func (b *BadgerBans) Close() error {
+	return b.db.Close()
+}
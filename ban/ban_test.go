@@ -0,0 +1,157 @@
+package ban
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+)
+
+func TestParseNetwork(t *testing.T) {
+	var tests = []struct {
+		network  string
+		expected string
+		err      error
+	}{
+		{"10.0.0.1", "10.0.0.1/32", nil},
+		{"10.0.0.0/8", "10.0.0.0/8", nil},
+		{"10.1.2.3/16", "10.1.0.0/16", nil},
+		{"2001:db8::1", "2001:db8::1/128", nil},
+		{"10.0.0", "", errors.New("'10.0.0' is not an ip or cidr")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.network,
+			func(t *testing.T) {
+				n, err := ParseNetwork(tt.network)
+				checkErr(t, err, tt.err)
+
+				if err == nil && n.String() != tt.expected {
+					t.Errorf("expected '%s' got '%s'", tt.expected, n)
+				}
+			},
+		)
+	}
+}
+
+func TestAddRemoveBanned(t *testing.T) {
+	b := newMemoryBans(t, &BanOpts{})
+	defer closeMemoryBans(t, b)
+
+	_, err := b.Add("10.0.0.0/8", "spam", "admin", 0)
+	checkErr(t, err, nil)
+
+	_, err = b.Add("192.168.0.1", "short", "admin", time.Millisecond)
+	checkErr(t, err, nil)
+
+	ban, ok := b.Banned(net.ParseIP("10.1.2.3"))
+	if !ok || ban.Network != "10.0.0.0/8" || ban.Reason != "spam" || ban.By != "admin" {
+		t.Fatalf("expected 10.1.2.3 to be banned by 10.0.0.0/8 got %v", ban)
+	}
+
+	if _, ok := b.Banned(net.ParseIP("11.0.0.1")); ok {
+		t.Fatal("expected 11.0.0.1 not to be banned")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := b.Banned(net.ParseIP("192.168.0.1")); ok {
+		t.Fatal("expected the ban of 192.168.0.1 to have expired")
+	}
+
+	bans, err := b.List()
+	checkErr(t, err, nil)
+
+	if len(bans) != 1 {
+		t.Fatalf("expected 1 ban got %d", len(bans))
+	}
+
+	checkErr(t, b.Remove("192.168.0.1"), ErrNotBanned)
+	checkErr(t, b.Remove("10.0.0.0/8"), nil)
+
+	if _, ok := b.Banned(net.ParseIP("10.1.2.3")); ok {
+		t.Fatal("expected 10.1.2.3 not to be banned")
+	}
+}
+
+func TestBansPersist(t *testing.T) {
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	checkErr(t, err, nil)
+	defer db.Close()
+
+	b, err := NewBadgerBans(&BanOpts{}, db)
+	checkErr(t, err, nil)
+
+	_, err = b.Add("2001:db8::/32", "", "admin", time.Hour)
+	checkErr(t, err, nil)
+
+	b, err = NewBadgerBans(&BanOpts{}, db)
+	checkErr(t, err, nil)
+
+	if _, ok := b.Banned(net.ParseIP("2001:db8::1")); !ok {
+		t.Fatal("expected the ban to be loaded")
+	}
+}
+
+func TestFail(t *testing.T) {
+	b := newMemoryBans(t, &BanOpts{
+		MaxFailures: 3,
+		FindTime:    60,
+		BanTime:     600,
+		Ignore:      []string{"127.0.0.1"},
+	})
+	defer closeMemoryBans(t, b)
+
+	ip := net.ParseIP("10.0.0.1")
+
+	for i := 0; i < 2; i++ {
+		ban, err := b.Fail(ip, "failed login")
+		checkErr(t, err, nil)
+
+		if ban != nil {
+			t.Fatalf("expected no ban after %d failures", i+1)
+		}
+	}
+
+	ban, err := b.Fail(ip, "failed login")
+	checkErr(t, err, nil)
+
+	if ban == nil || ban.Network != "10.0.0.1/32" || ban.By != AutoBan {
+		t.Fatalf("expected an automatic ban got %v", ban)
+	}
+
+	if d := time.Until(ban.Expires); d <= 590*time.Second || d > 600*time.Second {
+		t.Errorf("expected the ban to expire in 600s got %s", d)
+	}
+
+	if _, ok := b.Banned(ip); !ok {
+		t.Fatal("expected 10.0.0.1 to be banned")
+	}
+
+	for i := 0; i < 5; i++ {
+		ban, err := b.Fail(net.ParseIP("127.0.0.1"), "failed login")
+		checkErr(t, err, nil)
+
+		if ban != nil {
+			t.Fatal("expected ignored ips not to be banned")
+		}
+	}
+}
+
+func TestNewBadgerBansIgnore(t *testing.T) {
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	checkErr(t, err, nil)
+	defer db.Close()
+
+	_, err = NewBadgerBans(&BanOpts{Ignore: []string{"nope"}}, db)
+	checkErr(t, err, errors.New("ignore: 'nope' is not an ip or cidr"))
+}
@@ -0,0 +1,52 @@
+package ban
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryBans(t *testing.T, opts *BanOpts) *BadgerBans {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	b, err := NewBadgerBans(opts, db)
+	if err != nil {
+		t.Fatalf("error creating bans: %s", err)
+	}
+
+	return b
+}
+
+func closeMemoryBans(t *testing.T, b *BadgerBans) {
+	t.Helper()
+	if err := b.Close(); err != nil {
+		t.Fatalf("error closing bans: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("expected nil but got '%s'", got)
+	}
+
+	if got.Error() != expected.Error() {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
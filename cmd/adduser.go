@@ -3,18 +3,20 @@ package cmd
 import (
 	"log"
 
-	"github.com/goftpd/goftpd/config"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	var cfg, username, password string
+	var cfg, username, password, template string
+	var ips []string
 
 	var adduserCmd = &cobra.Command{
 		Use:   "adduser",
 		Short: "Check goftpd adduser",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c, err := config.ParseFile(cfg)
+			c, err := loadConfig(cfg)
 			if err != nil {
 				return err
 			}
@@ -25,14 +27,28 @@ func init() {
 				return err
 			}
 
+			templates, err := c.ParseTemplates()
+			if err != nil {
+				return err
+			}
+
+			t, ok := acl.FindTemplate(templates, template)
+			if !ok {
+				return errors.Errorf("unknown template '%s'", template)
+			}
+
 			// add user
-			user, err := auth.AddUser(username, password)
+			user, err := acl.AddUserFromTemplate(auth, t, username, password, ips)
 			if err != nil {
 				return err
 			}
 
 			log.Printf("created user '%s'", user.Name)
 
+			if len(t.Name) > 0 {
+				log.Printf("applied template '%s'", t.Name)
+			}
+
 			return nil
 		},
 	}
@@ -40,6 +56,8 @@ func init() {
 	adduserCmd.Flags().StringVarP(&cfg, "config", "c", "goftpd.conf", "config file to load")
 	adduserCmd.Flags().StringVarP(&username, "username", "u", "", "user to create")
 	adduserCmd.Flags().StringVarP(&password, "password", "p", "", "password to add to user")
+	adduserCmd.Flags().StringVarP(&template, "template", "t", "", "template to create the user from, defaults to the default template")
+	adduserCmd.Flags().StringSliceVarP(&ips, "ip", "i", []string{"*@127.0.0.1"}, "ident@ip masks the user is allowed to login from")
 
 	adduserCmd.MarkFlagRequired("username")
 	adduserCmd.MarkFlagRequired("password")
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var cfg, file string
+	var credentials bool
+
+	var exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export users and groups to JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(cfg)
+			if err != nil {
+				return err
+			}
+
+			auth, err := c.ParseAuthenticator()
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+
+			if file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				w = f
+			}
+
+			if err := auth.Export(w, credentials); err != nil {
+				return err
+			}
+
+			log.Printf("exported users and groups to '%s'", file)
+
+			return nil
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&cfg, "config", "c", "goftpd.conf", "config file to load")
+	exportCmd.Flags().StringVarP(&file, "file", "f", "-", "file to write the export to, - for stdout")
+	exportCmd.Flags().BoolVar(&credentials, "credentials", false, "include password hashes and TOTP secrets")
+
+	var importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import users and groups from a JSON export",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(cfg)
+			if err != nil {
+				return err
+			}
+
+			auth, err := c.ParseAuthenticator()
+			if err != nil {
+				return err
+			}
+
+			var r io.Reader = os.Stdin
+
+			if file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				r = f
+			}
+
+			if err := auth.Import(r); err != nil {
+				return err
+			}
+
+			log.Printf("imported users and groups from '%s'", file)
+
+			return nil
+		},
+	}
+
+	importCmd.Flags().StringVarP(&cfg, "config", "c", "goftpd.conf", "config file to load")
+	importCmd.Flags().StringVarP(&file, "file", "f", "-", "file to read the export from, - for stdin")
+
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
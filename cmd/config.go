@@ -3,7 +3,6 @@ package cmd
 import (
 	"log"
 
-	"github.com/goftpd/goftpd/config"
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +13,7 @@ func init() {
 		Use:   "config",
 		Short: "Check goftpd config",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c, err := config.ParseFile(cfg)
+			c, err := loadConfig(cfg)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/goftpd/goftpd/glftpd"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var cfg, root string
+	var sections []string
+
+	var importCmd = &cobra.Command{
+		Use:   "import-glftpd",
+		Short: "Import users and groups from glftpd",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := glftpd.NewImporterOpts(root)
+			opts.Sections = make(map[int]string, len(sections))
+
+			for _, s := range sections {
+				parts := strings.SplitN(s, "=", 2)
+				if len(parts) != 2 {
+					return errors.Errorf("expected section in the form of <number>=<name> got '%s'", s)
+				}
+
+				n, err := strconv.Atoi(parts[0])
+				if err != nil {
+					return errors.Errorf("bad section number '%s'", parts[0])
+				}
+
+				opts.Sections[n] = parts[1]
+			}
+
+			c, err := loadConfig(cfg)
+			if err != nil {
+				return err
+			}
+
+			auth, err := c.ParseAuthenticator()
+			if err != nil {
+				return err
+			}
+
+			st, err := c.ParseStats()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			result, err := glftpd.NewImporter(opts, auth, st).Import()
+			if err != nil {
+				return err
+			}
+
+			for _, g := range result.Groups {
+				log.Printf("imported group '%s'", g)
+			}
+
+			for _, u := range result.Users {
+				log.Printf("imported user '%s'", u)
+			}
+
+			for _, w := range result.Warnings {
+				log.Printf("warning: %s", w)
+			}
+
+			return nil
+		},
+	}
+
+	importCmd.Flags().StringVarP(&cfg, "config", "c", "goftpd.conf", "config file to load")
+	importCmd.Flags().StringVarP(&root, "root", "r", "/glftpd", "glftpd install directory")
+	importCmd.Flags().StringSliceVarP(&sections, "section", "s", nil, "map glftpd stat sections to goftpd sections, i.e. 1=mp3")
+
+	rootCmd.AddCommand(importCmd)
+}
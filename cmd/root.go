@@ -4,12 +4,42 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/goftpd/goftpd/config"
+	"github.com/goftpd/goftpd/ftp"
+
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "goftpd",
-	Short: "GO FTPD!",
+	Use:     "goftpd",
+	Short:   "GO FTPD!",
+	Version: ftp.Version,
+}
+
+// overrides of the config given with --set
+var configSets []string
+
+func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&configSets, "set", nil, "override a config option, <namespace>.<key>=<value>. takes precedence over GOFTPD_<NAMESPACE>_<KEY> environment variables, which take precedence over the file")
+}
+
+// loadConfig parses the config file then applies the environment and --set
+// overrides, in that order
+func loadConfig(path string) (*config.Config, error) {
+	c, err := config.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.OverrideEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+
+	if err := c.OverrideFlags(configSets); err != nil {
+		return nil, err
+	}
+
+	return c, nil
 }
 
 func Execute() {
@@ -2,9 +2,15 @@ package cmd
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/goftpd/goftpd/config"
+	"github.com/goftpd/goftpd/api"
 	"github.com/goftpd/goftpd/ftp"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/sitebot"
+	"github.com/goftpd/goftpd/systemd"
 	"github.com/spf13/cobra"
 )
 
@@ -16,16 +22,28 @@ func init() {
 		Short: "Run goftpd",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			cfg, err := config.ParseFile(configPath)
+			cfg, err := loadConfig(configPath)
 			if err != nil {
 				return err
 			}
 
+			// everything parsed after the logger is given it
+			logger, err := cfg.ParseLogger()
+			if err != nil {
+				return err
+			}
+			defer logger.Close()
+
 			serverOpts, err := cfg.ParseServerOpts()
 			if err != nil {
 				return err
 			}
 
+			// plugins can add permission scopes used by the fs acl rules
+			if err := ftp.LoadPlugins(serverOpts.Plugins); err != nil {
+				return err
+			}
+
 			fs, err := cfg.ParseFS()
 			if err != nil {
 				return err
@@ -38,12 +56,223 @@ func init() {
 				return err
 			}
 
-			server, err := ftp.NewServer(serverOpts, fs, auth)
+			st, err := cfg.ParseStats()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			dupes, err := cfg.ParseDupe()
+			if err != nil {
+				return err
+			}
+			defer dupes.Close()
+
+			dirs, err := cfg.ParseDirlog()
+			if err != nil {
+				return err
+			}
+			defer dirs.Close()
+
+			messages, err := cfg.ParseInbox()
+			if err != nil {
+				return err
+			}
+			defer messages.Close()
+
+			nukes, err := cfg.ParseNuke()
+			if err != nil {
+				return err
+			}
+			defer nukes.Close()
+
+			pres, err := cfg.ParsePre()
+			if err != nil {
+				return err
+			}
+			defer pres.Close()
+
+			scripts, err := cfg.ParseScripts()
+			if err != nil {
+				return err
+			}
+			defer scripts.Close()
+
+			engine, err := cfg.ParseLua()
+			if err != nil {
+				return err
+			}
+			defer engine.Close()
+
+			webhooks, err := cfg.ParseWebhooks()
+			if err != nil {
+				return err
+			}
+			defer webhooks.Close()
+
+			glftpdLog, err := cfg.ParseGlftpdLog()
+			if err != nil {
+				return err
+			}
+			defer glftpdLog.Close()
+
+			transfers, err := cfg.ParseXferlog()
+			if err != nil {
+				return err
+			}
+			defer transfers.Close()
+
+			feed, err := cfg.ParseSpy()
+			if err != nil {
+				return err
+			}
+			defer feed.Close()
+
+			races, err := cfg.ParseRace()
+			if err != nil {
+				return err
+			}
+			defer races.Close()
+
+			trail, err := cfg.ParseAudit()
+			if err != nil {
+				return err
+			}
+			defer trail.Close()
+
+			announcer, err := cfg.ParseAnnounce()
+			if err != nil {
+				return err
+			}
+			defer announcer.Close()
+
+			pretimes, err := cfg.ParsePretime()
 			if err != nil {
 				return err
 			}
 
-			ctx := context.Background()
+			scanner, err := cfg.ParseScan()
+			if err != nil {
+				return err
+			}
+			defer scanner.Close()
+
+			bans, err := cfg.ParseBans()
+			if err != nil {
+				return err
+			}
+			defer bans.Close()
+
+			// sockets passed by systemd socket activation
+			inherited, err := systemd.Listeners()
+			if err != nil {
+				return err
+			}
+
+			serverOpts.SetInherited(inherited)
+
+			server, err := ftp.NewServer(serverOpts, ftp.ServerDeps{
+				FS:        fs,
+				Auth:      auth,
+				Stats:     st,
+				Dupe:      dupes,
+				Dirlog:    dirs,
+				Inbox:     messages,
+				Nukes:     nukes,
+				Pre:       pres,
+				Scripts:   scripts,
+				Lua:       engine,
+				Webhooks:  webhooks,
+				GlftpdLog: glftpdLog,
+				Xferlog:   transfers,
+				Spy:       feed,
+				Race:      races,
+				Audit:     trail,
+				Announce:  announcer,
+				Pretime:   pretimes,
+				Scanner:   scanner,
+				Bans:      bans,
+			})
+			if err != nil {
+				return err
+			}
+
+			apiOpts, err := cfg.ParseAPI()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go handleSignals(cancel, configPath, server, logger)
+
+			if apiOpts.Enabled() {
+				adminAPI, err := api.NewServer(apiOpts, auth, trail, server)
+				if err != nil {
+					return err
+				}
+
+				go func() {
+					if err := adminAPI.ListenAndServe(ctx); err != nil {
+						logger.Error("api stopped", logging.Err(err))
+					}
+				}()
+			}
+
+			if feed.Enabled() {
+				go func() {
+					if err := feed.ListenAndServe(ctx, server); err != nil {
+						logger.Error("spy stopped", logging.Err(err))
+					}
+				}()
+			}
+
+			sitebotOpts, err := cfg.ParseSitebot()
+			if err != nil {
+				return err
+			}
+
+			if sitebotOpts.Enabled() {
+				bot, err := sitebot.NewServer(sitebotOpts, fs, auth, st, dupes, dirs)
+				if err != nil {
+					return err
+				}
+
+				go func() {
+					if err := bot.ListenAndServe(ctx, server); err != nil {
+						logger.Error("sitebot stopped", logging.Err(err))
+					}
+				}()
+			}
+
+			scheduler, err := cfg.ParseSchedule()
+			if err != nil {
+				return err
+			}
+
+			for name, fn := range server.Jobs() {
+				scheduler.Register(name, fn)
+			}
+
+			scheduler.Register("gc", func(ctx context.Context, args []string) error {
+				return cfg.CollectGarbage()
+			})
+
+			if err := scheduler.Check(); err != nil {
+				return err
+			}
+
+			go func() {
+				if err := scheduler.Run(ctx); err != nil {
+					logger.Error("scheduler stopped", logging.Err(err))
+				}
+			}()
+
+			go func() {
+				<-server.Ready()
+				notify(systemd.Ready, logger)
+			}()
 
 			if err := server.ListenAndServe(ctx); err != nil {
 				return err
@@ -57,3 +286,54 @@ func init() {
 
 	rootCmd.AddCommand(runCmd)
 }
+
+// handleSignals stops the server on SIGINT or SIGTERM and reloads the
+// server's SITE rules, templates and aliases from the config on SIGHUP
+func handleSignals(cancel context.CancelFunc, configPath string, server *ftp.Server, logger logging.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			logger.Info("stopping", logging.F("signal", sig.String()))
+			notify(systemd.Stopping, logger)
+			cancel()
+			return
+		}
+
+		notify(systemd.Reloading, logger)
+
+		if err := reload(configPath, server); err != nil {
+			logger.Error("unable to reload config", logging.Err(err))
+		} else {
+			logger.Info("reloaded config")
+		}
+
+		notify(systemd.Ready, logger)
+	}
+}
+
+// reload parses the config again and hands the server its new options
+func reload(configPath string, server *ftp.Server) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	opts, err := cfg.ParseServerOpts()
+	if err != nil {
+		return err
+	}
+
+	server.Reload(opts)
+
+	return nil
+}
+
+// notify tells systemd the state of the server when run as a Type=notify
+// service
+func notify(state string, logger logging.Logger) {
+	if _, err := systemd.Notify(state); err != nil {
+		logger.Warn("unable to notify systemd", logging.F("state", state), logging.Err(err))
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/pkg/errors"
+)
+
+// ParseAliases parses the alias namespace, each line is in the form
+// `alias <name> <command> [<params>...]`, i.e. `alias req request add`
+func (c *Config) ParseAliases() (cmd.Aliases, error) {
+	aliases := make(cmd.Aliases)
+
+	for _, l := range c.lines[NamespaceAlias] {
+		name, params, err := cmd.NewAlias(l.text)
+		if err != nil {
+			return nil, errors.Errorf("error parsing alias on %s: %s", l, err)
+		}
+
+		if _, ok := aliases[name]; ok {
+			return nil, errors.Errorf("error parsing alias on %s: '%s' is already defined", l, name)
+		}
+
+		aliases[name] = params
+	}
+
+	if err := aliases.Validate(); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
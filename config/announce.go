@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/announce"
+	"github.com/pkg/errors"
+)
+
+// ParseAnnounce parses the announce namespace. Targets are defined using
+// `announce discord <bot token> <channel id>` and
+// `announce telegram <bot token> <chat id>`
+func (c *Config) ParseAnnounce() (announce.Announcer, error) {
+	var opts announce.AnnounceOpts
+
+	lines := c.lines[NamespaceAnnounce]
+
+	if err := c.parse(lines, &opts, "discord", "telegram"); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	if opts.Timeout < 0 || opts.QueueSize < 0 {
+		return nil, errors.New("announce timeout and queue_size can't be negative")
+	}
+
+	var targets []announce.Target
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if name := strings.ToLower(fields[0]); name != "discord" && name != "telegram" {
+			continue
+		}
+
+		t, err := announce.NewTarget(l.text)
+		if err != nil {
+			return nil, errors.Errorf("error parsing announce target on %s: %s", l, err)
+		}
+
+		targets = append(targets, t)
+	}
+
+	return announce.NewBotAnnouncer(&opts, targets)
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/api"
+)
+
+// ParseAPI parses the api namespace, the api is only served when
+// `api port <port>` is set
+func (c *Config) ParseAPI() (*api.APIOpts, error) {
+	var opts api.APIOpts
+
+	if err := c.parse(c.lines[NamespaceAPI], &opts); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	return &opts, nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/audit"
+)
+
+// ParseAudit parses the audit namespace
+func (c *Config) ParseAudit() (audit.Log, error) {
+	var opts audit.AuditOpts
+
+	if err := c.parse(c.lines[NamespaceAudit], &opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "audit.db"
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.NewBadgerLog(&opts, db), nil
+}
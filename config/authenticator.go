@@ -1,9 +1,15 @@
 package config
 
 import (
-	"github.com/dgraph-io/badger/v2"
+	"strings"
+
 	"github.com/goftpd/goftpd/acl"
 	"github.com/pkg/errors"
+
+	// sql drivers for use with `auth sql_driver`
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 func (c *Config) ParseAuthenticator() (acl.Authenticator, error) {
@@ -14,24 +20,105 @@ func (c *Config) ParseAuthenticator() (acl.Authenticator, error) {
 		return nil, errors.New("no auth options provided")
 	}
 
-	if err := c.parse(lines, &opts); err != nil {
+	if err := c.parseLenient(lines, &opts); err != nil {
 		return nil, err
 	}
 
+	opts.SetLogger(c.logger)
+
 	if len(opts.DB) == 0 {
 		opts.DB = "users.db"
 	}
 
-	opt := badger.DefaultOptions(opts.DB)
-	// disable badger logger
-	opt.Logger = nil
+	switch opts.ExpiredAction {
+	case "":
+		opts.ExpiredAction = acl.ExpiredActionNone
+	case acl.ExpiredActionNone, acl.ExpiredActionFlag, acl.ExpiredActionPurge:
+	default:
+		return nil, errors.Errorf("unknown `auth expired_action` '%s'", opts.ExpiredAction)
+	}
+
+	// a negative lockout_attempts or tarpit_delay disables them
+	if opts.LockoutAttempts == 0 {
+		opts.LockoutAttempts = 5
+	}
+
+	if opts.LockoutTime <= 0 {
+		opts.LockoutTime = 900
+	}
+
+	switch {
+	case opts.TarpitDelay == 0:
+		opts.TarpitDelay = 1
+	case opts.TarpitDelay < 0:
+		opts.TarpitDelay = 0
+	}
+
+	if opts.PasswordMinLength < 0 {
+		return nil, errors.New("`auth password_min_length` can't be negative")
+	}
+
+	if opts.MaxIPs < 0 || opts.MinIPOctets < 0 || opts.MinIPOctets > 4 {
+		return nil, errors.New("`auth max_ips` can't be negative and `auth min_ip_octets` must be between 0 and 4")
+	}
+
+	// orphaned files default to the fs default user and group, this
+	// way they are displayed the same as files with no shadow entry
+	fsOpts, err := c.parseFSOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.OrphanUser) == 0 {
+		opts.OrphanUser = fsOpts.DefaultUser
+	}
+
+	if len(opts.OrphanGroup) == 0 {
+		opts.OrphanGroup = fsOpts.DefaultGroup
+	}
+
+	if len(opts.OrphanUser) == 0 || len(opts.OrphanGroup) == 0 {
+		return nil, errors.New("`auth orphan_user` and `auth orphan_group` required when no fs defaults are set")
+	}
+
+	if len(opts.Backend) == 0 {
+		opts.Backend = acl.DefaultAuthenticator
+
+		// sql_driver on its own implies the sql backend
+		if len(opts.SQLDriver) > 0 {
+			opts.Backend = "sql"
+		}
+	}
+
+	params := make(map[string]string, len(lines))
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) > 1 {
+			params[strings.ToLower(fields[0])] = strings.Join(fields[1:], " ")
+		}
+	}
 
-	db, err := badger.Open(opt)
+	auth, err := acl.NewAuthenticator(opts.Backend, &opts, params)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to create `auth backend` '%s'", opts.Backend)
+	}
+
+	shadow, err := c.openShadow(fsOpts.ShadowDB)
 	if err != nil {
 		return nil, err
 	}
 
-	auth := acl.NewBadgerAuthenticator(db)
+	if r, ok := auth.(acl.Reconcilable); ok {
+		r.SetReconciler(shadow)
+	}
+
+	if b, ok := auth.(badgerBacked); ok {
+		db := b.DB()
+		c.collectors = append(c.collectors, func() error {
+			return collectGarbage(db)
+		})
+	}
 
 	return auth, nil
 }
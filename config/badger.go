@@ -0,0 +1,60 @@
+package config
+
+import (
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// gcDiscardRatio is the fraction of a value log file that has to be stale
+// before it is rewritten
+const gcDiscardRatio = 0.5
+
+// badgerBacked is implemented by stores that open their own badger DB,
+// i.e. the badger Authenticator, so it is collected the same as the rest
+type badgerBacked interface {
+	DB() *badger.DB
+}
+
+// openBadger opens the badger DB at path, logging to the config's logger.
+// The DB is included in CollectGarbage
+func (c *Config) openBadger(path string) (*badger.DB, error) {
+	opt := badger.DefaultOptions(path)
+	// badger's info lines are only logged at debug
+	opt.Logger = logging.Badger(c.logger)
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	c.collectors = append(c.collectors, func() error {
+		return collectGarbage(db)
+	})
+
+	return db, nil
+}
+
+// CollectGarbage reclaims the space used by stale values in every badger
+// DB opened while parsing, stopping at the first failure
+func (c *Config) CollectGarbage() error {
+	for _, fn := range c.collectors {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectGarbage rewrites the value log files of the DB until none are
+// worth rewriting
+func collectGarbage(db *badger.DB) error {
+	for {
+		switch err := db.RunValueLogGC(gcDiscardRatio); err {
+		case nil:
+		case badger.ErrNoRewrite, badger.ErrGCInMemoryMode:
+			return nil
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/ban"
+	"github.com/pkg/errors"
+)
+
+// ParseBans parses the ban namespace
+func (c *Config) ParseBans() (ban.Bans, error) {
+	var opts ban.BanOpts
+
+	if err := c.parse(c.lines[NamespaceBan], &opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "bans.db"
+	}
+
+	if opts.MaxFailures < 0 || opts.FindTime < 0 || opts.BanTime < 0 {
+		return nil, errors.New("ban max_failures, find_time and ban_time can't be negative")
+	}
+
+	if opts.FindTime == 0 {
+		opts.FindTime = 600
+	}
+
+	if opts.BanTime == 0 {
+		opts.BanTime = 3600
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return ban.NewBadgerBans(&opts, db)
+}
@@ -8,57 +8,141 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/vfs"
 	"github.com/pkg/errors"
 )
 
 type Namespace string
 
 const (
-	NamespaceVar    Namespace = "var"
-	NamespaceServer Namespace = "server"
-	NamespaceACL    Namespace = "acl"
-	NamespaceFS     Namespace = "fs"
-	NamespaceAuth   Namespace = "auth"
+	NamespaceVar       Namespace = "var"
+	NamespaceServer    Namespace = "server"
+	NamespaceACL       Namespace = "acl"
+	NamespaceFS        Namespace = "fs"
+	NamespaceAuth      Namespace = "auth"
+	NamespaceSite      Namespace = "site"
+	NamespaceStats     Namespace = "stats"
+	NamespaceTemplate  Namespace = "template"
+	NamespaceDupe      Namespace = "dupe"
+	NamespaceDirlog    Namespace = "dirlog"
+	NamespaceInbox     Namespace = "inbox"
+	NamespaceNuke      Namespace = "nuke"
+	NamespacePre       Namespace = "pre"
+	NamespaceScript    Namespace = "script"
+	NamespaceWebhook   Namespace = "webhook"
+	NamespaceGlftpd    Namespace = "glftpd"
+	NamespaceLog       Namespace = "log"
+	NamespaceXferlog   Namespace = "xferlog"
+	NamespaceAPI       Namespace = "api"
+	NamespaceSpy       Namespace = "spy"
+	NamespaceZipscript Namespace = "zipscript"
+	NamespaceRace      Namespace = "race"
+	NamespaceAudit     Namespace = "audit"
+	NamespaceSitebot   Namespace = "sitebot"
+	NamespaceAnnounce  Namespace = "announce"
+	NamespacePretime   Namespace = "pretime"
+	NamespaceSchedule  Namespace = "schedule"
+	NamespaceAlias     Namespace = "alias"
+	NamespaceScan      Namespace = "scan"
+	NamespaceListener  Namespace = "listener"
+	NamespaceBan       Namespace = "ban"
 )
 
 var stringToNamespace = map[string]Namespace{
-	string(NamespaceServer): NamespaceServer,
-	string(NamespaceACL):    NamespaceACL,
-	string(NamespaceFS):     NamespaceFS,
-	string(NamespaceVar):    NamespaceVar,
-	string(NamespaceAuth):   NamespaceAuth,
+	string(NamespaceServer):    NamespaceServer,
+	string(NamespaceACL):       NamespaceACL,
+	string(NamespaceFS):        NamespaceFS,
+	string(NamespaceVar):       NamespaceVar,
+	string(NamespaceAuth):      NamespaceAuth,
+	string(NamespaceSite):      NamespaceSite,
+	string(NamespaceStats):     NamespaceStats,
+	string(NamespaceTemplate):  NamespaceTemplate,
+	string(NamespaceDupe):      NamespaceDupe,
+	string(NamespaceDirlog):    NamespaceDirlog,
+	string(NamespaceInbox):     NamespaceInbox,
+	string(NamespaceNuke):      NamespaceNuke,
+	string(NamespacePre):       NamespacePre,
+	string(NamespaceScript):    NamespaceScript,
+	string(NamespaceWebhook):   NamespaceWebhook,
+	string(NamespaceGlftpd):    NamespaceGlftpd,
+	string(NamespaceLog):       NamespaceLog,
+	string(NamespaceXferlog):   NamespaceXferlog,
+	string(NamespaceAPI):       NamespaceAPI,
+	string(NamespaceSpy):       NamespaceSpy,
+	string(NamespaceZipscript): NamespaceZipscript,
+	string(NamespaceRace):      NamespaceRace,
+	string(NamespaceAudit):     NamespaceAudit,
+	string(NamespaceSitebot):   NamespaceSitebot,
+	string(NamespaceAnnounce):  NamespaceAnnounce,
+	string(NamespacePretime):   NamespacePretime,
+	string(NamespaceSchedule):  NamespaceSchedule,
+	string(NamespaceAlias):     NamespaceAlias,
+	string(NamespaceScan):      NamespaceScan,
+	string(NamespaceListener):  NamespaceListener,
+	string(NamespaceBan):       NamespaceBan,
 }
 
+// Line is a line of the config file with its namespace removed
 type Line struct {
+	ns   Namespace
 	text string
 	line int
+
+	// set when the line overrides the file, i.e. the environment variable
+	source string
+}
+
+// String describes where the line came from for errors, i.e. line 12
+func (l Line) String() string {
+	if len(l.source) > 0 {
+		return l.source
+	}
+	return fmt.Sprintf("line %d", l.line)
+}
+
+// errorf returns an error giving where the line came from
+func (l Line) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("error on %s: %s", l, fmt.Sprintf(format, args...))
 }
 
 type Config struct {
 	lines map[Namespace][]Line
 
 	variables map[string]string
+
+	// shared between the fs and auth namespaces
+	shadow *vfs.ShadowStore
+
+	// set by ParseLogger and handed to everything parsed after it
+	logger logging.Logger
+
+	// run by CollectGarbage, one for each badger DB opened
+	collectors []func() error
 }
 
 func ParseFile(file string) (*Config, error) {
 	c := Config{
 		lines:     make(map[Namespace][]Line, 0),
 		variables: make(map[string]string, 0),
+		logger:    logging.Stderr,
 	}
 
 	// first read in any variables
 	if err := c.parseVariables(file); err != nil {
-		return nil, err
+		return nil, errors.WithMessage(err, file)
 	}
 
 	// then read everything else
 	if err := c.parseLines(file); err != nil {
-		return nil, err
+		return nil, errors.WithMessage(err, file)
 	}
 
 	return &c, nil
 }
 
+// parseVariables reads the `var <name> <value>` lines, any other problems
+// are left to parseLines
 func (c *Config) parseVariables(file string) error {
 	f, err := os.Open(file)
 	if err != nil {
@@ -68,47 +152,21 @@ func (c *Config) parseVariables(file string) error {
 
 	scanner := bufio.NewScanner(f)
 
-	var line int
 	for scanner.Scan() {
-		line++
-
 		fields := strings.Fields(scanner.Text())
 
-		// ignore empty lines
-		if len(fields) == 0 {
-			continue
-		}
-
-		// ignore comments
-		if len(fields) > 0 && len(fields[0]) > 0 && fields[0][0] == '#' {
-			continue
-		}
-
-		if len(fields) < 2 {
-			fmt.Fprintf(os.Stderr, "Config Error: parsing line %d: not enough arguments: '%s'\n", line, scanner.Text())
+		if len(fields) < 2 || fields[0] != string(NamespaceVar) {
 			continue
 		}
 
-		ns, ok := stringToNamespace[fields[0]]
-		if !ok {
-			fmt.Fprintf(os.Stderr, "Config Error: parsing line %d: '%s' is not a valid namespace.\n", line, fields[0])
-			continue
-		}
-
-		// check if this is a variable
-		if ns != NamespaceVar {
-			continue
-		}
 		c.variables[fields[1]] = strings.Join(fields[2:], " ")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return scanner.Err()
 }
 
+// parseLines groups the lines of the file by namespace, replacing variables.
+// Fails on a line without a value or with an unknown namespace
 func (c *Config) parseLines(file string) error {
 	f, err := os.Open(file)
 	if err != nil {
@@ -130,19 +188,22 @@ func (c *Config) parseLines(file string) error {
 		}
 
 		// ignore comments
-		if len(fields) > 0 && len(fields[0]) > 0 && fields[0][0] == '#' {
-			continue
-		}
-
-		if len(fields) < 2 {
-			fmt.Fprintf(os.Stderr, "Config Error: parsing line %d: not enough arguments: '%s'\n", line, scanner.Text())
+		if len(fields[0]) > 0 && fields[0][0] == '#' {
 			continue
 		}
 
 		ns, ok := stringToNamespace[fields[0]]
 		if !ok {
-			fmt.Fprintf(os.Stderr, "Config Error: parsing line %d: '%s' is not a valid namespace.\n", line, fields[0])
-			continue
+			namespaces := make([]string, 0, len(stringToNamespace))
+			for name := range stringToNamespace {
+				namespaces = append(namespaces, name)
+			}
+
+			return errors.Errorf("error on line %d: unknown namespace '%s'%s", line, fields[0], suggest(fields[0], namespaces))
+		}
+
+		if len(fields) < 2 {
+			return errors.Errorf("error on line %d: `%s` needs a value", line, fields[0])
 		}
 
 		// check if this is a variable
@@ -154,88 +215,212 @@ func (c *Config) parseLines(file string) error {
 			if len(f) > 1 && f[0] == '$' {
 				v, ok := c.variables[f[1:]]
 				if !ok {
-					return fmt.Errorf("Config Error: uninitialized variable '%s' on line %d", f, line)
+					return errors.Errorf("error on line %d: uninitialized variable '%s'", line, f)
 				}
 				fields[idx] = v
 			}
 		}
 
-		if _, ok := c.lines[ns]; !ok {
-			c.lines[ns] = make([]Line, 0)
-		}
-
 		c.lines[ns] = append(c.lines[ns], Line{
+			ns:   ns,
 			text: strings.Join(fields[1:], " "),
 			line: line,
 		})
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return scanner.Err()
 }
 
 // parse searches opts for any fields tagged with "goftpd" and then attempts
-// to parse and insert the corresponding Line
-func (c *Config) parse(lines []Line, opts interface{}) error {
-	rv := reflect.ValueOf(opts)
+// to parse and insert the corresponding Line. Lines with a key that isn't a
+// tag of opts or one of the directives the caller handles itself are an
+// error, as are keys given more than once
+func (c *Config) parse(lines []Line, opts interface{}, directives ...string) error {
+	return c.parseOpts(lines, opts, true, directives)
+}
+
+// parseLenient is parse for namespaces where unknown keys are handed on,
+// i.e. to an Authenticator backend as its params
+func (c *Config) parseLenient(lines []Line, opts interface{}) error {
+	return c.parseOpts(lines, opts, false, nil)
+}
+
+func (c *Config) parseOpts(lines []Line, opts interface{}, strict bool, directives []string) error {
+	rv := reflect.Indirect(reflect.ValueOf(opts))
+	rt := rv.Type()
+
+	tags := make(map[string]int, rt.NumField())
+
+	for i := 0; i < rt.NumField(); i++ {
+		if tag, ok := rt.Field(i).Tag.Lookup("goftpd"); ok && rv.Field(i).CanSet() {
+			tags[tag] = i
+		}
+	}
+
+	// the line each key was set on
+	set := make(map[string]Line, len(lines))
 
 	for _, l := range lines {
 		fields := strings.Fields(l.text)
 
 		if len(fields) < 2 {
-			return errors.Errorf("error on line %d", l.line)
+			return l.errorf("`%s %s` needs a value", l.ns, l.text)
+		}
+
+		key := strings.ToLower(fields[0])
+
+		i, ok := tags[key]
+		if !ok {
+			if !strict || contains(directives, key) {
+				continue
+			}
+
+			known := append([]string{}, directives...)
+			for tag := range tags {
+				known = append(known, tag)
+			}
+
+			return l.errorf("unknown option `%s %s`%s", l.ns, key, suggest(key, known))
 		}
 
-		tag := strings.ToLower(fields[0])
+		if prev, ok := set[key]; ok {
+			return l.errorf("`%s %s` is already set on %s", l.ns, key, prev)
+		}
+
+		set[key] = l
+
+		if err := setField(rv.Field(i), fields[1:]); err != nil {
+			return errors.Errorf("error parsing `%s %s` on %s: %s", l.ns, key, l, err)
+		}
+	}
 
-		// iterate over the opts
-		for i := 0; i < reflect.Indirect(rv).NumField(); i++ {
+	return nil
+}
 
-			if value, ok := reflect.Indirect(rv).Type().Field(i).Tag.Lookup("goftpd"); ok {
-				if value == tag {
-					if reflect.Indirect(rv).Field(i).CanSet() {
-						switch reflect.Indirect(rv).Field(i).Kind() {
+// setField parses the values in to the field, string fields take the
+// values joined with spaces and slices one value each
+func setField(field reflect.Value, values []string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(strings.Join(values, " "))
 
-						case reflect.String:
-							reflect.Indirect(rv).Field(i).SetString(strings.Join(fields[1:], " "))
+	case reflect.Bool:
+		if len(values) > 1 {
+			return errors.Errorf("expected a bool but got %d values", len(values))
+		}
 
-						case reflect.Int:
-							if len(fields) > 2 {
-								return errors.Errorf("error parsing int on line %d: too many fields", l.line)
-							}
+		b, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return errors.Errorf("'%s' is not a bool", values[0])
+		}
 
-							num, err := strconv.Atoi(fields[1])
-							if err != nil {
-								return errors.Errorf("error parsing int on line %d: not a number", l.line)
-							}
+		field.SetBool(b)
 
-							reflect.Indirect(rv).Field(i).SetInt(int64(num))
+	case reflect.Int:
+		if len(values) > 1 {
+			return errors.Errorf("expected a number but got %d values", len(values))
+		}
 
-						case reflect.Slice:
-							switch reflect.Indirect(rv).Field(i).Type().Elem().Kind() {
-							case reflect.Int:
+		num, err := strconv.Atoi(values[0])
+		if err != nil {
+			return errors.Errorf("'%s' is not a number", values[0])
+		}
 
-								var nums []int
+		field.SetInt(int64(num))
 
-								for _, f := range fields[1:] {
-									num, err := strconv.Atoi(f)
-									if err != nil {
-										return errors.Errorf("error parsing int on line %d: not a number", l.line)
-									}
-									nums = append(nums, num)
-								}
+	case reflect.Slice:
+		switch field.Type().Elem().Kind() {
+		case reflect.Int:
+			nums := make([]int, 0, len(values))
 
-								reflect.Indirect(rv).Field(i).Set(reflect.ValueOf(nums))
-							}
-						}
-					}
+			for _, v := range values {
+				num, err := strconv.Atoi(v)
+				if err != nil {
+					return errors.Errorf("'%s' is not a number", v)
 				}
+				nums = append(nums, num)
 			}
+
+			field.Set(reflect.ValueOf(nums))
+
+		case reflect.String:
+			field.Set(reflect.ValueOf(values))
+
+		default:
+			return errors.Errorf("unsupported type %s", field.Type())
 		}
+
+	default:
+		return errors.Errorf("unsupported type %s", field.Type())
 	}
 
 	return nil
 }
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSuggestDistance is the most edits a name can be from a known name for
+// it to be suggested
+const maxSuggestDistance = 2
+
+// suggest returns `, did you mean 'x'?` naming the known name closest to
+// name, empty if none are close
+func suggest(name string, known []string) string {
+	best, bestDistance := "", maxSuggestDistance+1
+
+	for _, k := range known {
+		if d := distance(strings.ToLower(name), k); d < bestDistance || (d == bestDistance && k < best) {
+			best, bestDistance = k, d
+		}
+	}
+
+	if len(best) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean '%s'?", best)
+}
+
+// distance returns the Levenshtein distance between a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(nums ...int) int {
+	m := nums[0]
+	for _, n := range nums[1:] {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseStrict(t *testing.T) {
+	var tests = []struct {
+		name     string
+		text     string
+		expected testOpts
+		err      error
+	}{
+		{
+			"every type",
+			"server name site one\nserver enabled true\nserver timeout 30\nserver ports 21 990\nserver hosts a b",
+			testOpts{Name: "site one", Enabled: true, Timeout: 30, Ports: []int{21, 990}, Hosts: []string{"a", "b"}},
+			nil,
+		},
+		{
+			"directive",
+			"server job @daily gc\nserver name site",
+			testOpts{Name: "site"},
+			nil,
+		},
+		{
+			"unknown key",
+			"server name site\nserver colour blue",
+			testOpts{},
+			errors.New("error on line 2: unknown option `server colour`"),
+		},
+		{
+			"misspelled key",
+			"server timeuot 30",
+			testOpts{},
+			errors.New("error on line 1: unknown option `server timeuot`, did you mean 'timeout'?"),
+		},
+		{
+			"misspelled directive",
+			"server jobs @daily gc",
+			testOpts{},
+			errors.New("error on line 1: unknown option `server jobs`, did you mean 'job'?"),
+		},
+		{
+			"set twice",
+			"server name one\n\nserver name two",
+			testOpts{},
+			errors.New("error on line 3: `server name` is already set on line 1"),
+		},
+		{
+			"not a bool",
+			"server enabled maybe",
+			testOpts{},
+			errors.New("error parsing `server enabled` on line 1: 'maybe' is not a bool"),
+		},
+		{
+			"not a number",
+			"server timeout soon",
+			testOpts{},
+			errors.New("error parsing `server timeout` on line 1: 'soon' is not a number"),
+		},
+		{
+			"not a list of numbers",
+			"server ports 21 ftps",
+			testOpts{},
+			errors.New("error parsing `server ports` on line 1: 'ftps' is not a number"),
+		},
+		{
+			"too many values",
+			"server timeout 1 2",
+			testOpts{},
+			errors.New("error parsing `server timeout` on line 1: expected a number but got 2 values"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newTestConfig(t, tt.text)
+			checkErr(t, err, nil)
+
+			var opts testOpts
+			err = c.parse(c.lines[NamespaceServer], &opts, "job")
+			checkErr(t, err, tt.err)
+
+			if tt.err == nil && !reflect.DeepEqual(opts, tt.expected) {
+				t.Fatalf("expected %+v got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	c, err := newTestConfig(t, "auth name site\nauth dsn user@/goftpd")
+	checkErr(t, err, nil)
+
+	var opts testOpts
+	checkErr(t, c.parseLenient(c.lines[NamespaceAuth], &opts), nil)
+
+	if opts.Name != "site" {
+		t.Fatalf("expected name 'site' got '%s'", opts.Name)
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		err  string
+	}{
+		{"unknown namespace", "colour name site", "error on line 1: unknown namespace 'colour'"},
+		{"misspelled namespace", "# comment\nsever name site", "error on line 2: unknown namespace 'sever', did you mean 'server'?"},
+		{"no value", "server", "error on line 1: `server` needs a value"},
+		{"uninitialized variable", "server name $site", "error on line 1: uninitialized variable '$site'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestConfig(t, tt.text)
+			if err == nil || !strings.HasSuffix(err.Error(), ": "+tt.err) {
+				t.Fatalf("expected '%s' but got '%v'", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expected string
+	}{
+		{"timeuot", ", did you mean 'timeout'?"},
+		{"TIMEOUT", ", did you mean 'timeout'?"},
+		{"nmae", ", did you mean 'name'?"},
+		{"colour", ""},
+	}
+
+	known := []string{"name", "enabled", "timeout", "ports", "hosts"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggest(tt.name, known); got != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/pkg/errors"
+)
+
+// ParseDirlog parses the dirlog namespace. Sections are defined using
+// `dirlog section <name> <glob>` and are matched in order
+func (c *Config) ParseDirlog() (dirlog.Dirlog, error) {
+	var opts dirlog.DirlogOpts
+
+	lines := c.lines[NamespaceDirlog]
+
+	if err := c.parse(lines, &opts, "section"); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "dirlog.db"
+	}
+
+	var sections []dirlog.Section
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "section" {
+			continue
+		}
+
+		section, err := dirlog.NewSection(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing dirlog section on %s: %s", l, err)
+		}
+
+		sections = append(sections, section)
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return dirlog.NewBadgerDirlog(&opts, db, sections), nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/pkg/errors"
+)
+
+// ParseDupe parses the dupe namespace. Rules are defined using
+// `dupe check <glob> <action>` and are matched in order
+func (c *Config) ParseDupe() (dupe.Dupe, error) {
+	var opts dupe.DupeOpts
+
+	lines := c.lines[NamespaceDupe]
+
+	if err := c.parse(lines, &opts, "check"); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "dupe.db"
+	}
+
+	var rules []dupe.Rule
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "check" {
+			continue
+		}
+
+		rule, err := dupe.NewRule(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing dupe check on %s: %s", l, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return dupe.NewBadgerDupe(&opts, db, rules), nil
+}
@@ -1,15 +1,87 @@
 package config
 
 import (
+	"os"
 	"regexp"
+	"strings"
 
-	"github.com/dgraph-io/badger/v2"
-	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5"
+	"github.com/gobwas/glob"
 	"github.com/goftpd/goftpd/vfs"
 	"github.com/pkg/errors"
 )
 
 func (c *Config) ParseFS() (vfs.VFS, error) {
+	opts, err := c.parseFSOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	ufs, err := c.parseMounts(vfs.NewOSFS(opts.Root))
+	if err != nil {
+		return nil, err
+	}
+
+	shadowFS, err := c.openShadow(opts.ShadowDB)
+	if err != nil {
+		return nil, err
+	}
+
+	perms, err := c.ParsePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := vfs.NewFilesystem(opts, ufs, shadowFS, perms)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// parseMounts parses `fs mount <path> <dir>` lines, mounting each host dir
+// at the virtual path. Without any mounts root is returned as is
+func (c *Config) parseMounts(root billy.Filesystem) (billy.Filesystem, error) {
+	var mfs *vfs.MountFS
+
+	for _, l := range c.lines[NamespaceFS] {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "mount" {
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, errors.Errorf("error parsing fs mount on %s: expected `fs mount <path> <dir>`", l)
+		}
+
+		finfo, err := os.Stat(fields[2])
+		if err != nil {
+			return nil, errors.Errorf("error parsing fs mount on %s: %s", l, err)
+		}
+
+		if !finfo.IsDir() {
+			return nil, errors.Errorf("error parsing fs mount on %s: '%s' is not a directory", l, fields[2])
+		}
+
+		if mfs == nil {
+			mfs = vfs.NewMountFS(root)
+		}
+
+		if err := mfs.Mount(fields[1], vfs.NewOSFS(fields[2])); err != nil {
+			return nil, errors.Errorf("error parsing fs mount on %s: %s", l, err)
+		}
+	}
+
+	if mfs == nil {
+		return root, nil
+	}
+
+	return mfs, nil
+}
+
+// parseFSOpts parses and validates the fs namespace
+func (c *Config) parseFSOpts() (*vfs.FilesystemOpts, error) {
 	var opts vfs.FilesystemOpts
 
 	lines, ok := c.lines[NamespaceFS]
@@ -17,7 +89,7 @@ func (c *Config) ParseFS() (vfs.VFS, error) {
 		return nil, errors.New("no fs options provided")
 	}
 
-	if err := c.parse(lines, &opts); err != nil {
+	if err := c.parse(lines, &opts, "mount", "filename", "skiplist", "dated", "rotate", "rotate_exclude"); err != nil {
 		return nil, err
 	}
 
@@ -37,28 +109,140 @@ func (c *Config) ParseFS() (vfs.VFS, error) {
 		opts.SetHideRE(re)
 	}
 
-	ufs := osfs.New(opts.Root)
+	var fileMode, dirMode os.FileMode
 
-	opt := badger.DefaultOptions(opts.ShadowDB)
-	// disable badger logger
-	opt.Logger = nil
+	if len(opts.FileMode) > 0 {
+		m, err := vfs.ParseMode(opts.FileMode)
+		if err != nil {
+			return nil, errors.WithMessage(err, `"fs file_mode" is bad`)
+		}
+		fileMode = m
+	}
 
-	db, err := badger.Open(opt)
-	if err != nil {
-		return nil, err
+	if len(opts.DirMode) > 0 {
+		m, err := vfs.ParseMode(opts.DirMode)
+		if err != nil {
+			return nil, errors.WithMessage(err, `"fs dir_mode" is bad`)
+		}
+		dirMode = m
 	}
 
-	shadowFS := vfs.NewShadowStore(db)
+	opts.SetModes(fileMode, dirMode)
 
-	perms, err := c.ParsePermissions()
+	if (opts.UID > 0 || opts.GID > 0) && os.Geteuid() != 0 {
+		return nil, errors.New(`"fs uid" and "fs gid" require running as root`)
+	}
+
+	var rules []vfs.FilenameRule
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "filename" {
+			continue
+		}
+
+		rule, err := vfs.NewFilenameRule(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing fs filename on %s: %s", l, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	opts.SetFilenameRules(rules)
+
+	var skiplist []vfs.SkipRule
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "skiplist" {
+			continue
+		}
+
+		rule, err := vfs.NewSkipRule(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing fs skiplist on %s: %s", l, err)
+		}
+
+		skiplist = append(skiplist, rule)
+	}
+
+	opts.SetSkiplist(skiplist)
+
+	var dated []vfs.DatedDir
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "dated" {
+			continue
+		}
+
+		d, err := vfs.NewDatedDir(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing fs dated on %s: %s", l, err)
+		}
+
+		dated = append(dated, d)
+	}
+
+	opts.SetDatedDirs(dated)
+
+	var rotations []vfs.Rotation
+	var excludes []glob.Glob
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "rotate":
+			r, err := vfs.NewRotation(strings.Join(fields[1:], " "))
+			if err != nil {
+				return nil, errors.Errorf("error parsing fs rotate on %s: %s", l, err)
+			}
+
+			rotations = append(rotations, r)
+
+		case "rotate_exclude":
+			for _, f := range fields[1:] {
+				g, err := glob.Compile(strings.ToLower(f), '/')
+				if err != nil {
+					return nil, errors.Errorf("error parsing fs rotate_exclude on %s: %s", l, err)
+				}
+
+				excludes = append(excludes, g)
+			}
+		}
+	}
+
+	opts.SetRotations(rotations)
+	opts.SetRotateExcludes(excludes)
+
+	z, err := c.ParseZipscript()
 	if err != nil {
 		return nil, err
 	}
 
-	fs, err := vfs.NewFilesystem(&opts, ufs, shadowFS, perms)
+	opts.SetZipscript(z)
+
+	return &opts, nil
+}
+
+// openShadow opens the ShadowStore at path. The store is shared so that the
+// Authenticator can reconcile ownership using the same underlying db
+func (c *Config) openShadow(path string) (*vfs.ShadowStore, error) {
+	if c.shadow != nil {
+		return c.shadow, nil
+	}
+
+	db, err := c.openBadger(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return fs, nil
+	c.shadow = vfs.NewShadowStore(db)
+
+	return c.shadow, nil
 }
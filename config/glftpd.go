@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/glftpd"
+)
+
+// ParseGlftpdLog parses the glftpd namespace, `glftpd log <file>` turns on
+// writing a glftpd.log compatible event log
+func (c *Config) ParseGlftpdLog() (glftpd.Log, error) {
+	var opts glftpd.LogOpts
+
+	if err := c.parse(c.lines[NamespaceGlftpd], &opts); err != nil {
+		return nil, err
+	}
+
+	return glftpd.NewFileLog(&opts)
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testOpts has a field of each type setField supports
+type testOpts struct {
+	Name    string   `goftpd:"name"`
+	Enabled bool     `goftpd:"enabled"`
+	Timeout int      `goftpd:"timeout"`
+	Ports   []int    `goftpd:"ports"`
+	Hosts   []string `goftpd:"hosts"`
+}
+
+// newTestConfig parses the text as a config file
+func newTestConfig(t *testing.T, text string) (*Config, error) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "goftpd-config")
+	if err != nil {
+		t.Fatalf("unexpected error creating config: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatalf("unexpected error writing config: %s", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error closing config: %s", err)
+	}
+
+	return ParseFile(f.Name())
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("expected nil but got '%s'", got)
+	}
+
+	if got.Error() != expected.Error() {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/pkg/errors"
+)
+
+// ParseInbox parses the inbox namespace
+func (c *Config) ParseInbox() (inbox.Inbox, error) {
+	var opts inbox.InboxOpts
+
+	if err := c.parse(c.lines[NamespaceInbox], &opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "inbox.db"
+	}
+
+	if opts.MaxLength < 0 || opts.MaxMessages < 0 || opts.Retention < 0 {
+		return nil, errors.New("inbox limits can not be negative")
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return inbox.NewBadgerInbox(&opts, db), nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp"
+	"github.com/pkg/errors"
+)
+
+// ParseListeners parses the listener namespace, each line is in the form
+// `listener <name> <field> <value>` or `listener <name> site <command>
+// <acl>`. A listener's site rules are applied after the site namespace's,
+// replacing the rules of the same commands for its sessions
+func (c *Config) ParseListeners() ([]*ftp.ListenerOpts, error) {
+	grouped := make(map[string][]Line, 0)
+
+	// listeners are bound in the order they first appear
+	var names []string
+
+	for _, l := range c.lines[NamespaceListener] {
+		fields := strings.Fields(l.text)
+		if len(fields) < 3 {
+			return nil, errors.Errorf("error parsing listener on %s: expected `listener <name> <field> <value>`", l)
+		}
+
+		name := strings.ToLower(fields[0])
+
+		if _, ok := grouped[name]; !ok {
+			names = append(names, name)
+		}
+
+		grouped[name] = append(grouped[name], Line{
+			ns:     NamespaceListener,
+			text:   strings.Join(fields[1:], " "),
+			line:   l.line,
+			source: l.source,
+		})
+	}
+
+	listeners := make([]*ftp.ListenerOpts, 0, len(names))
+
+	for _, name := range names {
+		opts := ftp.ListenerOpts{Name: name}
+
+		if err := c.parse(grouped[name], &opts, "site"); err != nil {
+			return nil, err
+		}
+
+		if opts.Port == 0 {
+			return nil, errors.Errorf("listener %s requires a port", name)
+		}
+
+		if opts.ProxyProtocol && len(opts.ProxyTrusted) == 0 {
+			return nil, errors.Errorf("listener %s proxy_protocol requires proxy_trusted", name)
+		}
+
+		if len(opts.Host) == 0 {
+			opts.Host = "::"
+		}
+
+		var site []Line
+		for _, l := range grouped[name] {
+			if fields := strings.Fields(l.text); strings.ToLower(fields[0]) == "site" {
+				site = append(site, Line{
+					ns:     l.ns,
+					text:   strings.Join(fields[1:], " "),
+					line:   l.line,
+					source: l.source,
+				})
+			}
+		}
+
+		if len(site) > 0 {
+			rules, err := c.commandRules(append(c.lines[NamespaceSite], site...))
+			if err != nil {
+				return nil, err
+			}
+
+			permissions, err := acl.NewCommandPermissions(rules)
+			if err != nil {
+				return nil, err
+			}
+
+			opts.SetCommandPermissions(permissions)
+		}
+
+		listeners = append(listeners, &opts)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseListeners(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		err  error
+	}{
+		{
+			"listener",
+			"listener lb port 2122\nlistener lb host 127.0.0.1",
+			nil,
+		},
+		{
+			"no port",
+			"listener lb host 127.0.0.1",
+			errors.New("listener lb requires a port"),
+		},
+		{
+			"proxy trusted",
+			"listener lb port 2122\nlistener lb proxy_protocol true\nlistener lb proxy_trusted 10.0.0.5",
+			nil,
+		},
+		{
+			"proxy without trusted",
+			"listener lb port 2122\nlistener lb proxy_protocol true",
+			errors.New("listener lb proxy_protocol requires proxy_trusted"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newTestConfig(t, tt.text)
+			checkErr(t, err, nil)
+
+			_, err = c.ParseListeners()
+			checkErr(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseServerOptsProxyTrusted(t *testing.T) {
+	c, err := newTestConfig(t, "server public_ip 127.0.0.1\nserver proxy_protocol true")
+	checkErr(t, err, nil)
+
+	_, err = c.ParseServerOpts()
+	checkErr(t, err, errors.New("proxy_protocol requires proxy_trusted"))
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/logging"
+)
+
+// ParseLogger parses the log namespace. Has to be called before anything
+// else is parsed so they are given the logger, they log to stderr
+// otherwise
+func (c *Config) ParseLogger() (*logging.WriterLogger, error) {
+	var opts logging.LogOpts
+
+	if err := c.parse(c.lines[NamespaceLog], &opts); err != nil {
+		return nil, err
+	}
+
+	logger, err := logging.NewLogger(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger = logger
+
+	return logger, nil
+}
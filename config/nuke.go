@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/pkg/errors"
+)
+
+// ParseNuke parses the nuke namespace
+func (c *Config) ParseNuke() (nuke.Nukes, error) {
+	var opts nuke.NukeOpts
+
+	if err := c.parse(c.lines[NamespaceNuke], &opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "nuke.db"
+	}
+
+	if strings.Contains(opts.Prefix, "/") {
+		return nil, errors.New("nuke prefix can not contain '/'")
+	}
+
+	if opts.MaxMultiplier < 0 {
+		return nil, errors.New("nuke max_multiplier can not be negative")
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return nuke.NewBadgerNukes(&opts, db), nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix starts the names of environment variables overriding the config
+const EnvPrefix = "GOFTPD_"
+
+// Override replaces every line of the namespace starting with the fields of
+// key with `<key> <value>`, adding it if there isn't one. Variables in the
+// value are replaced as they are in the file. source describes where the
+// override came from in errors, i.e. the environment variable
+func (c *Config) Override(ns, key, value, source string) error {
+	namespace, ok := stringToNamespace[strings.ToLower(ns)]
+	if !ok {
+		namespaces := make([]string, 0, len(stringToNamespace))
+		for name := range stringToNamespace {
+			namespaces = append(namespaces, name)
+		}
+
+		return errors.Errorf("error on %s: unknown namespace '%s'%s", source, ns, suggest(ns, namespaces))
+	}
+
+	if namespace == NamespaceVar {
+		return errors.Errorf("error on %s: variables can't be overridden", source)
+	}
+
+	keyFields := strings.Fields(strings.ToLower(key))
+	valueFields := strings.Fields(value)
+
+	if len(keyFields) == 0 || len(valueFields) == 0 {
+		return errors.Errorf("error on %s: expected a key and a value", source)
+	}
+
+	for idx, f := range valueFields {
+		if len(f) > 1 && f[0] == '$' {
+			v, ok := c.variables[f[1:]]
+			if !ok {
+				return errors.Errorf("error on %s: uninitialized variable '%s'", source, f)
+			}
+			valueFields[idx] = v
+		}
+	}
+
+	lines := c.lines[namespace][:0]
+
+	for _, l := range c.lines[namespace] {
+		if !hasKey(l.text, keyFields) {
+			lines = append(lines, l)
+		}
+	}
+
+	c.lines[namespace] = append(lines, Line{
+		ns:     namespace,
+		text:   strings.Join(append(keyFields, valueFields...), " "),
+		source: source,
+	})
+
+	return nil
+}
+
+// hasKey checks to see if the text starts with the fields of the key
+func hasKey(text string, key []string) bool {
+	fields := strings.Fields(text)
+
+	if len(fields) < len(key) {
+		return false
+	}
+
+	for i, k := range key {
+		if strings.ToLower(fields[i]) != k {
+			return false
+		}
+	}
+
+	return true
+}
+
+// OverrideEnv applies environment variables in the form of
+// `GOFTPD_<NAMESPACE>_<KEY>=<value>`, i.e. GOFTPD_SERVER_PUBLIC_IP, as
+// overrides. They are applied in order of name so the result doesn't
+// depend on the order of the environment
+func (c *Config) OverrideEnv(environ []string) error {
+	sorted := append([]string{}, environ...)
+	sort.Strings(sorted)
+
+	for _, e := range sorted {
+		if !strings.HasPrefix(e, EnvPrefix) {
+			continue
+		}
+
+		name, value := e, ""
+		if i := strings.IndexByte(e, '='); i >= 0 {
+			name, value = e[:i], e[i+1:]
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(name, EnvPrefix), "_", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("error on %s: expected %s<NAMESPACE>_<KEY>", name, EnvPrefix)
+		}
+
+		if err := c.Override(parts[0], parts[1], value, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OverrideFlags applies overrides in the form of
+// `<namespace>.<key>=<value>`, keys of more than one field are separated by
+// dots, i.e. template.default.ratio=5
+func (c *Config) OverrideFlags(sets []string) error {
+	for _, s := range sets {
+		source := "--set " + s
+
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return errors.Errorf("error on %s: expected <namespace>.<key>=<value>", source)
+		}
+
+		parts := strings.Split(s[:i], ".")
+		if len(parts) < 2 {
+			return errors.Errorf("error on %s: expected <namespace>.<key>=<value>", source)
+		}
+
+		if err := c.Override(parts[0], strings.Join(parts[1:], " "), s[i+1:], source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
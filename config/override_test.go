@@ -0,0 +1,190 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestOverride(t *testing.T) {
+	const file = "var site goftpd\n" +
+		"server name file\n" +
+		"server enabled false\n" +
+		"server timeout 30\n" +
+		"server ports 21\n"
+
+	var tests = []struct {
+		name     string
+		environ  []string
+		sets     []string
+		expected testOpts
+		err      error
+	}{
+		{
+			"file",
+			nil,
+			nil,
+			testOpts{Name: "file", Timeout: 30, Ports: []int{21}},
+			nil,
+		},
+		{
+			"environment",
+			[]string{
+				"GOFTPD_SERVER_ENABLED=true",
+				"GOFTPD_SERVER_PORTS=21 990",
+				"GOFTPD_SERVER_HOSTS=a b",
+				"HOME=/root",
+			},
+			nil,
+			testOpts{Name: "file", Enabled: true, Timeout: 30, Ports: []int{21, 990}, Hosts: []string{"a", "b"}},
+			nil,
+		},
+		{
+			"flags",
+			nil,
+			[]string{"server.timeout=60", "SERVER.NAME=flag site"},
+			testOpts{Name: "flag site", Timeout: 60, Ports: []int{21}},
+			nil,
+		},
+		{
+			"flags over environment over file",
+			[]string{"GOFTPD_SERVER_NAME=env", "GOFTPD_SERVER_TIMEOUT=45"},
+			[]string{"server.name=flag"},
+			testOpts{Name: "flag", Timeout: 45, Ports: []int{21}},
+			nil,
+		},
+		{
+			"variables",
+			nil,
+			[]string{"server.name=$site"},
+			testOpts{Name: "goftpd", Timeout: 30, Ports: []int{21}},
+			nil,
+		},
+		{
+			"bad value",
+			[]string{"GOFTPD_SERVER_ENABLED=yes please"},
+			nil,
+			testOpts{},
+			errors.New("error parsing `server enabled` on GOFTPD_SERVER_ENABLED: expected a bool but got 2 values"),
+		},
+		{
+			"misspelled key",
+			nil,
+			[]string{"server.timout=5"},
+			testOpts{},
+			errors.New("error on --set server.timout=5: unknown option `server timout`, did you mean 'timeout'?"),
+		},
+		{
+			"misspelled namespace",
+			[]string{"GOFTPD_SERVR_NAME=x"},
+			nil,
+			testOpts{},
+			errors.New("error on GOFTPD_SERVR_NAME: unknown namespace 'SERVR', did you mean 'server'?"),
+		},
+		{
+			"no key",
+			[]string{"GOFTPD_SERVER=x"},
+			nil,
+			testOpts{},
+			errors.New("error on GOFTPD_SERVER: expected GOFTPD_<NAMESPACE>_<KEY>"),
+		},
+		{
+			"no value",
+			[]string{"GOFTPD_SERVER_NAME="},
+			nil,
+			testOpts{},
+			errors.New("error on GOFTPD_SERVER_NAME: expected a key and a value"),
+		},
+		{
+			"no equals",
+			nil,
+			[]string{"server.name"},
+			testOpts{},
+			errors.New("error on --set server.name: expected <namespace>.<key>=<value>"),
+		},
+		{
+			"no namespace",
+			nil,
+			[]string{"name=x"},
+			testOpts{},
+			errors.New("error on --set name=x: expected <namespace>.<key>=<value>"),
+		},
+		{
+			"variable",
+			nil,
+			[]string{"var.site=x"},
+			testOpts{},
+			errors.New("error on --set var.site=x: variables can't be overridden"),
+		},
+		{
+			"uninitialized variable",
+			nil,
+			[]string{"server.name=$missing"},
+			testOpts{},
+			errors.New("error on --set server.name=$missing: uninitialized variable '$missing'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newTestConfig(t, file)
+			checkErr(t, err, nil)
+
+			err = c.OverrideEnv(tt.environ)
+			if err == nil {
+				err = c.OverrideFlags(tt.sets)
+			}
+
+			var opts testOpts
+			if err == nil {
+				err = c.parse(c.lines[NamespaceServer], &opts)
+			}
+
+			checkErr(t, err, tt.err)
+
+			if tt.err == nil && !reflect.DeepEqual(opts, tt.expected) {
+				t.Fatalf("expected %+v got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestOverrideKeyFields(t *testing.T) {
+	c, err := newTestConfig(t, "template default ratio 3\ntemplate default credits 0\ntemplate leech ratio 0")
+	checkErr(t, err, nil)
+
+	checkErr(t, c.OverrideFlags([]string{"template.default.ratio=5"}), nil)
+	checkErr(t, c.OverrideFlags([]string{"template.leech.credits=10"}), nil)
+
+	var got []string
+	for _, l := range c.lines[NamespaceTemplate] {
+		got = append(got, l.text)
+	}
+
+	expected := []string{
+		"default credits 0",
+		"leech ratio 0",
+		"default ratio 5",
+		"leech credits 10",
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q got %q", expected, got)
+	}
+}
+
+func TestOverrideEnvOrder(t *testing.T) {
+	c, err := newTestConfig(t, "server name file")
+	checkErr(t, err, nil)
+
+	// applied by name so the last of these wins whatever order they come in
+	checkErr(t, c.OverrideEnv([]string{"GOFTPD_SERVER_NAME=b", "GOFTPD_SERVER_Name=a"}), nil)
+
+	var opts testOpts
+	checkErr(t, c.parse(c.lines[NamespaceServer], &opts), nil)
+
+	if opts.Name != "a" {
+		t.Fatalf("expected name 'a' got '%s'", opts.Name)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp"
 	"github.com/pkg/errors"
 )
 
@@ -15,7 +16,7 @@ func (c *Config) ParsePermissions() (*acl.Permissions, error) {
 	for _, l := range lines {
 		r, err := acl.NewRule(l.text)
 		if err != nil {
-			return nil, errors.Errorf("error parsing acl rule on line %d: %s", l.line, err)
+			return nil, errors.Errorf("error parsing acl rule on %s: %s", l, err)
 		}
 		rules = append(rules, r)
 	}
@@ -25,5 +26,33 @@ func (c *Config) ParsePermissions() (*acl.Permissions, error) {
 		return nil, err
 	}
 
+	// anonymous users are confined to the server's anonymous_paths
+	var server ftp.ServerOpts
+
+	if err := c.parse(c.lines[NamespaceServer], &server); err != nil {
+		return nil, err
+	}
+
+	if server.Anonymous {
+		anonymousRules, err := acl.AnonymousRules(server.AnonymousPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		// hidden entries and sessions stay hidden from anonymous users
+		for _, r := range rules {
+			if r.Scope() == acl.PermissionScopeHide || r.Scope() == acl.PermissionScopeHideInWho {
+				anonymousRules = append(anonymousRules, r)
+			}
+		}
+
+		anonymous, err := acl.NewPermissions(anonymousRules)
+		if err != nil {
+			return nil, err
+		}
+
+		permissions.SetAnonymous(anonymous)
+	}
+
 	return permissions, nil
 }
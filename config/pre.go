@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/pre"
+	"github.com/pkg/errors"
+)
+
+// ParsePre parses the pre namespace. Group pre areas are defined using
+// `pre area <group> <path>` and the sections releases can be pred in to
+// using `pre section <name> <path>`
+func (c *Config) ParsePre() (pre.Pre, error) {
+	var opts pre.PreOpts
+
+	lines := c.lines[NamespacePre]
+
+	if err := c.parse(lines, &opts, "area", "section"); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "pre.db"
+	}
+
+	var areas []pre.Area
+	var sections []pre.Section
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "area":
+			area, err := pre.NewArea(strings.Join(fields[1:], " "))
+			if err != nil {
+				return nil, errors.Errorf("error parsing pre area on %s: %s", l, err)
+			}
+
+			areas = append(areas, area)
+
+		case "section":
+			section, err := pre.NewSection(strings.Join(fields[1:], " "))
+			if err != nil {
+				return nil, errors.Errorf("error parsing pre section on %s: %s", l, err)
+			}
+
+			sections = append(sections, section)
+		}
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return pre.NewBadgerPre(&opts, db, areas, sections), nil
+}
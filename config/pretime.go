@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/pretime"
+	"github.com/pkg/errors"
+)
+
+// ParsePretime parses the pretime namespace, releases are only looked up
+// when `pretime url <url>` is set
+func (c *Config) ParsePretime() (pretime.Lookup, error) {
+	var opts pretime.PretimeOpts
+
+	if err := c.parse(c.lines[NamespacePretime], &opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout < 0 || opts.MaxAge < 0 {
+		return nil, errors.New("pretime timeout and max_age can't be negative")
+	}
+
+	return pretime.NewHTTPLookup(&opts)
+}
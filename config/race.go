@@ -0,0 +1,26 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/race"
+)
+
+// ParseRace parses the race namespace, releases are only raced once the
+// zipscript has seen their sfv
+func (c *Config) ParseRace() (race.Race, error) {
+	var opts race.RaceOpts
+
+	if err := c.parse(c.lines[NamespaceRace], &opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "race.db"
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return race.NewBadgerRace(&opts, db), nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/scan"
+	"github.com/pkg/errors"
+)
+
+// ParseScan parses the scan namespace. Staff are told about infected
+// uploads using `scan notify discord <bot token> <channel id>` and
+// `scan notify telegram <bot token> <chat id>`
+func (c *Config) ParseScan() (scan.Scanner, error) {
+	var opts scan.ScanOpts
+
+	lines := c.lines[NamespaceScan]
+
+	if err := c.parse(lines, &opts, "notify"); err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout < 0 || opts.MaxScans < 0 {
+		return nil, errors.New("scan timeout and max_scans can't be negative")
+	}
+
+	var targets []announce.Target
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || fields[0] != "notify" {
+			continue
+		}
+
+		t, err := announce.NewTarget(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing scan notify on %s: %s", l, err)
+		}
+
+		targets = append(targets, t)
+	}
+
+	scanner, err := scan.NewUploadScanner(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(targets) > 0 {
+		announceOpts := announce.AnnounceOpts{
+			Events:   []string{announce.EventInfected},
+			Infected: opts.Message,
+		}
+
+		announceOpts.SetLogger(c.logger)
+
+		a, err := announce.NewBotAnnouncer(&announceOpts, targets)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetAnnouncer(a)
+	}
+
+	return scanner, nil
+}
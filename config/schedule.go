@@ -0,0 +1,102 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/schedule"
+	"github.com/pkg/errors"
+)
+
+// ParseSchedule parses the schedule namespace. Jobs are defined using
+// `schedule job <minute> <hour> <day of month> <month> <day of week> <job> [<args>...]`
+// or `schedule job <macro> <job> [<args>...]`
+func (c *Config) ParseSchedule() (*schedule.Scheduler, error) {
+	var opts schedule.ScheduleOpts
+
+	lines := c.lines[NamespaceSchedule]
+
+	if err := c.parse(lines, &opts, "job"); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	if opts.Timeout < 0 {
+		return nil, errors.New("schedule timeout can't be negative")
+	}
+
+	var jobs []schedule.Job
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || fields[0] != "job" {
+			continue
+		}
+
+		j, err := schedule.NewJob(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing schedule job on %s: %s", l, err)
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	c.warnUnscheduled(jobs)
+
+	return schedule.NewScheduler(&opts, jobs), nil
+}
+
+// warnUnscheduled warns about settings that only take effect when a job is
+// run but have no job scheduled, so they are never silently ignored
+func (c *Config) warnUnscheduled(jobs []schedule.Job) {
+	scheduled := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		scheduled[j.Name()] = true
+	}
+
+	var dated, rotate bool
+	for _, l := range c.lines[NamespaceFS] {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "dated":
+			dated = true
+		case "rotate":
+			rotate = true
+		}
+	}
+
+	// errors are reported when the namespaces are parsed themselves
+	var authOpts acl.AuthenticatorOpts
+	if err := c.parseLenient(c.lines[NamespaceAuth], &authOpts); err != nil {
+		authOpts.ExpiredAction = ""
+	}
+
+	var inboxOpts inbox.InboxOpts
+	if err := c.parseLenient(c.lines[NamespaceInbox], &inboxOpts); err != nil {
+		inboxOpts.Retention = 0
+	}
+
+	settings := []struct {
+		setting string
+		job     string
+		set     bool
+	}{
+		{"fs dated", "dated", dated},
+		{"fs rotate", "rotate", rotate},
+		{"auth expired_action", "expire_users", len(authOpts.ExpiredAction) > 0 && authOpts.ExpiredAction != acl.ExpiredActionNone},
+		{"inbox retention", "expire_messages", inboxOpts.Retention > 0},
+	}
+
+	for _, s := range settings {
+		if s.set && !scheduled[s.job] {
+			c.logger.Warn("setting has no job scheduled so never takes effect", logging.F("setting", s.setting), logging.F("job", s.job))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+func TestParseScheduleUnscheduled(t *testing.T) {
+	var tests = []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			"nothing set",
+			"auth expired_action none\ninbox retention 0",
+			nil,
+		},
+		{
+			"all scheduled",
+			"fs dated /0day MMDD\nfs rotate /0day 7\nauth expired_action purge\ninbox retention 30\n" +
+				"schedule job @daily dated\nschedule job @hourly rotate\nschedule job @hourly expire_users\nschedule job @hourly expire_messages",
+			nil,
+		},
+		{
+			"none scheduled",
+			"fs dated /0day MMDD\nfs rotate /0day 7\nauth expired_action flag\ninbox retention 30",
+			[]string{"fs dated", "fs rotate", "auth expired_action", "inbox retention"},
+		},
+		{
+			"some scheduled",
+			"fs dated /0day MMDD\nfs rotate /0day 7\nschedule job @daily dated",
+			[]string{"fs rotate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newTestConfig(t, tt.text)
+			if err != nil {
+				t.Fatalf("unexpected error parsing config: %s", err)
+			}
+
+			var b bytes.Buffer
+			c.logger = logging.NewWriterLogger(&b, logging.LevelInfo, logging.FormatConsole)
+
+			if _, err := c.ParseSchedule(); err != nil {
+				t.Fatalf("unexpected error parsing schedule: %s", err)
+			}
+
+			for _, setting := range []string{"fs dated", "fs rotate", "auth expired_action", "inbox retention"} {
+				warned := strings.Contains(b.String(), setting)
+
+				expected := false
+				for _, e := range tt.expected {
+					expected = expected || e == setting
+				}
+
+				if warned != expected {
+					t.Errorf("expected warning for '%s' to be %t in '%s'", setting, expected, b.String())
+				}
+			}
+		})
+	}
+}
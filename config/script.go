@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/script"
+	"github.com/pkg/errors"
+)
+
+// ParseScripts parses the script namespace. Programs to run are defined
+// using `script run <event> <glob> <program> [<args>...]`
+func (c *Config) ParseScripts() (script.Runner, error) {
+	var opts script.ScriptOpts
+
+	lines := c.lines[NamespaceScript]
+
+	if err := c.parse(lines, &opts, "run", "lua"); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	if opts.Timeout < 0 {
+		return nil, errors.New("script timeout can't be negative")
+	}
+
+	if opts.MaxRunning < 0 {
+		return nil, errors.New("script max_running can't be negative")
+	}
+
+	var scripts []script.Script
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "run" {
+			continue
+		}
+
+		s, err := script.NewScript(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing script run on %s: %s", l, err)
+		}
+
+		scripts = append(scripts, s)
+	}
+
+	return script.NewExecRunner(&opts, scripts), nil
+}
+
+// ParseLua parses the Lua files to load from the script namespace, defined
+// using `script lua <file>`. Files are loaded in order
+func (c *Config) ParseLua() (script.Engine, error) {
+	var opts script.ScriptOpts
+
+	lines := c.lines[NamespaceScript]
+
+	if err := c.parse(lines, &opts, "run", "lua"); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	var files []string
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "lua" {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, errors.Errorf("error parsing script lua on %s: expected `script lua <file>`", l)
+		}
+
+		files = append(files, fields[1])
+	}
+
+	return script.NewLuaEngine(&opts, files)
+}
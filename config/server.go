@@ -1,8 +1,6 @@
 package config
 
 import (
-	"crypto/tls"
-
 	"github.com/goftpd/goftpd/ftp"
 	"github.com/pkg/errors"
 )
@@ -19,11 +17,18 @@ func (c *Config) ParseServerOpts() (*ftp.ServerOpts, error) {
 		return nil, err
 	}
 
+	opts.SetLogger(c.logger)
+
 	// validation
 	if len(opts.PublicIP) == 0 {
 		return nil, errors.New("public_ip required")
 	}
 
+	// anyone could give a header and pick their own ip otherwise
+	if opts.ProxyProtocol && len(opts.ProxyTrusted) == 0 {
+		return nil, errors.New("proxy_protocol requires proxy_trusted")
+	}
+
 	// set defaults
 	if len(opts.Name) == 0 {
 		opts.Name = "go"
@@ -48,23 +53,54 @@ func (c *Config) ParseServerOpts() (*ftp.ServerOpts, error) {
 		}
 	}
 
+	if opts.IdentTimeout <= 0 {
+		opts.IdentTimeout = 5
+	}
+
 	if opts.PassivePorts[0] >= opts.PassivePorts[1] {
 		return nil, errors.New("Passvive Ports must be in order: min,max")
 	}
 
-	// setup tlsConfig
-	tlsConfig := &tls.Config{}
+	tlsConfig, err := newTLSConfig(&opts)
+	if err != nil {
+		return nil, err
+	}
 
-	tlsConfig.NextProtos = []string{"ftp"}
+	opts.SetTLSConfig(tlsConfig)
 
-	cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+	commandPermissions, err := c.ParseCommandPermissions()
 	if err != nil {
 		return nil, err
 	}
 
-	tlsConfig.Certificates = []tls.Certificate{cert}
+	opts.SetCommandPermissions(commandPermissions)
 
-	opts.SetTLSConfig(tlsConfig)
+	templates, err := c.ParseTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.SetTemplates(templates)
+
+	aliases, err := c.ParseAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.SetAliases(aliases)
+
+	listeners, err := c.ParseListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range listeners {
+		if l.Host == opts.Host && l.Port == opts.Port {
+			return nil, errors.Errorf("listener %s uses the server's host and port", l.Name)
+		}
+	}
+
+	opts.SetListeners(listeners)
 
 	return &opts, nil
 
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// ParseCommandPermissions parses the site namespace, each line is in the
+// form `site <command> <acl>`. Commands with no rules are denied
+func (c *Config) ParseCommandPermissions() (*acl.CommandPermissions, error) {
+	rules, err := c.commandRules(c.lines[NamespaceSite])
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := acl.NewCommandPermissions(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// commandRules parses lines in the form `<command> <acl>`
+func (c *Config) commandRules(lines []Line) ([]acl.CommandRule, error) {
+	var rules []acl.CommandRule
+
+	for _, l := range lines {
+		r, err := acl.NewCommandRule(l.text)
+		if err != nil {
+			return nil, errors.Errorf("error parsing site rule on %s: %s", l, err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/sitebot"
+)
+
+// ParseSitebot parses the sitebot namespace, the sitebot is only served
+// when `sitebot socket <path>` or `sitebot port <port>` is set
+func (c *Config) ParseSitebot() (*sitebot.SitebotOpts, error) {
+	var opts sitebot.SitebotOpts
+
+	if err := c.parse(c.lines[NamespaceSitebot], &opts); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	return &opts, nil
+}
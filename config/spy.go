@@ -0,0 +1,24 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/spy"
+	"github.com/pkg/errors"
+)
+
+// ParseSpy parses the spy namespace, the feed is only served when
+// `spy port <port>` is set
+func (c *Config) ParseSpy() (*spy.Hub, error) {
+	var opts spy.SpyOpts
+
+	if err := c.parse(c.lines[NamespaceSpy], &opts); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	if opts.Progress < 0 || opts.QueueSize < 0 {
+		return nil, errors.New("spy progress and queue_size can't be negative")
+	}
+
+	return spy.NewHub(&opts)
+}
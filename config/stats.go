@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/stats"
+	"github.com/pkg/errors"
+)
+
+// ParseStats parses the stats namespace. Sections are defined using
+// `stats section <name> <glob>` and are matched in order
+func (c *Config) ParseStats() (stats.Stats, error) {
+	var opts stats.StatsOpts
+
+	lines := c.lines[NamespaceStats]
+
+	if err := c.parse(lines, &opts, "section"); err != nil {
+		return nil, err
+	}
+
+	if len(opts.DB) == 0 {
+		opts.DB = "stats.db"
+	}
+
+	var sections []stats.Section
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "section" {
+			continue
+		}
+
+		section, err := stats.NewSection(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing stats section on %s: %s", l, err)
+		}
+
+		sections = append(sections, section)
+	}
+
+	db, err := c.openBadger(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats.NewBadgerStats(&opts, db, sections), nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// ParseTemplates parses the template namespace, each line is in the form
+// `template <name> <field> <value>`
+func (c *Config) ParseTemplates() (map[string]*acl.Template, error) {
+	templates := make(map[string]*acl.Template, 0)
+
+	grouped := make(map[string][]Line, 0)
+
+	for _, l := range c.lines[NamespaceTemplate] {
+		fields := strings.Fields(l.text)
+		if len(fields) < 3 {
+			return nil, errors.Errorf("error parsing template on %s: expected `template <name> <field> <value>`", l)
+		}
+
+		name := strings.ToLower(fields[0])
+
+		grouped[name] = append(grouped[name], Line{
+			ns:     NamespaceTemplate,
+			text:   strings.Join(fields[1:], " "),
+			line:   l.line,
+			source: l.source,
+		})
+	}
+
+	for name, lines := range grouped {
+		t := acl.Template{Name: name}
+
+		if err := c.parse(lines, &t); err != nil {
+			return nil, err
+		}
+
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+
+		templates[name] = &t
+	}
+
+	return templates, nil
+}
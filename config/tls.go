@@ -0,0 +1,114 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strings"
+
+	"github.com/goftpd/goftpd/ftp"
+	"github.com/pkg/errors"
+)
+
+// tlsVersions are the names of the versions allowed by tls_min_version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves are the names of the curves allowed by tls_curves
+var tlsCurves = map[string]tls.CurveID{
+	"x25519": tls.X25519,
+	"p256":   tls.CurveP256,
+	"p384":   tls.CurveP384,
+	"p521":   tls.CurveP521,
+}
+
+// tlsClientAuth are the modes allowed by tls_client_auth
+var tlsClientAuth = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"require": tls.RequireAnyClientCert,
+	// verified against tls_client_ca if given
+	"verify":         tls.VerifyClientCertIfGiven,
+	"require_verify": tls.RequireAndVerifyClientCert,
+}
+
+// newTLSConfig builds the tls.Config of the server from its tls_ options,
+// failing on anything unknown or insecure
+func newTLSConfig(opts *ftp.ServerOpts) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"ftp"},
+	}
+
+	if len(opts.TLSALPN) > 0 {
+		tlsConfig.NextProtos = opts.TLSALPN
+	}
+
+	if len(opts.TLSMinVersion) > 0 {
+		v, ok := tlsVersions[opts.TLSMinVersion]
+		if !ok {
+			return nil, errors.Errorf("tls_min_version must be 1.0, 1.1, 1.2 or 1.3: '%s'", opts.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = v
+	}
+
+	if len(opts.TLSCiphers) > 0 {
+		suites := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = s.ID
+		}
+
+		for _, name := range opts.TLSCiphers {
+			id, ok := suites[strings.ToUpper(name)]
+			if !ok {
+				return nil, errors.Errorf("unknown or insecure tls cipher '%s'", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	for _, name := range opts.TLSCurves {
+		id, ok := tlsCurves[strings.ToLower(name)]
+		if !ok {
+			return nil, errors.Errorf("tls_curves must be x25519, p256, p384 or p521: '%s'", name)
+		}
+		tlsConfig.CurvePreferences = append(tlsConfig.CurvePreferences, id)
+	}
+
+	if len(opts.TLSClientAuth) > 0 {
+		auth, ok := tlsClientAuth[strings.ToLower(opts.TLSClientAuth)]
+		if !ok {
+			return nil, errors.Errorf("tls_client_auth must be none, request, require, verify or require_verify: '%s'", opts.TLSClientAuth)
+		}
+		tlsConfig.ClientAuth = auth
+	}
+
+	if len(opts.TLSClientCA) > 0 {
+		b, err := ioutil.ReadFile(opts.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no certificates in tls_client_ca '%s'", opts.TLSClientCA)
+		}
+
+		tlsConfig.ClientCAs = pool
+	} else if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, errors.New("tls_client_auth verify and require_verify need tls_client_ca")
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	return tlsConfig, nil
+}
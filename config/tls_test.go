@@ -0,0 +1,133 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp"
+)
+
+// writeTestCert writes a self signed certificate and its key to dir,
+// returning their paths
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "goftpd"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %s", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error marshalling key: %s", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error writing certificate: %s", err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("unexpected error writing key: %s", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goftpd-tls")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir)
+
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatalf("unexpected error writing ca: %s", err)
+	}
+
+	var tests = []struct {
+		opts ftp.ServerOpts
+		err  error
+	}{
+		{ftp.ServerOpts{TLSMinVersion: "1.4"}, errors.New("tls_min_version must be 1.0, 1.1, 1.2 or 1.3: '1.4'")},
+		{ftp.ServerOpts{TLSCiphers: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, errors.New("unknown or insecure tls cipher 'TLS_RSA_WITH_RC4_128_SHA'")},
+		{ftp.ServerOpts{TLSCurves: []string{"p224"}}, errors.New("tls_curves must be x25519, p256, p384 or p521: 'p224'")},
+		{ftp.ServerOpts{TLSClientAuth: "maybe"}, errors.New("tls_client_auth must be none, request, require, verify or require_verify: 'maybe'")},
+		{ftp.ServerOpts{TLSClientAuth: "verify"}, errors.New("tls_client_auth verify and require_verify need tls_client_ca")},
+		{ftp.ServerOpts{TLSClientCA: empty}, errors.New("no certificates in tls_client_ca '" + empty + "'")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			tt.opts.TLSCertFile = certFile
+			tt.opts.TLSKeyFile = keyFile
+
+			_, err := newTLSConfig(&tt.opts)
+			checkErr(t, err, tt.err)
+		})
+	}
+
+	opts := ftp.ServerOpts{
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSMinVersion: "1.3",
+		TLSCiphers:    []string{"tls_aes_256_gcm_sha384"},
+		TLSCurves:     []string{"X25519", "p256"},
+		TLSClientAuth: "request",
+	}
+
+	config, err := newTLSConfig(&opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("expected TLS 1.3 got %x", config.MinVersion)
+	}
+
+	if !reflect.DeepEqual(config.CipherSuites, []uint16{tls.TLS_AES_256_GCM_SHA384}) {
+		t.Fatalf("unexpected cipher suites: %v", config.CipherSuites)
+	}
+
+	if !reflect.DeepEqual(config.CurvePreferences, []tls.CurveID{tls.X25519, tls.CurveP256}) {
+		t.Fatalf("unexpected curves: %v", config.CurvePreferences)
+	}
+
+	if config.ClientAuth != tls.RequestClientCert {
+		t.Fatalf("expected client certificates to be requested got %d", config.ClientAuth)
+	}
+
+	if !reflect.DeepEqual(config.NextProtos, []string{"ftp"}) {
+		t.Fatalf("expected the ftp alpn protocol by default got %v", config.NextProtos)
+	}
+}
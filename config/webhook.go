@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/webhook"
+	"github.com/pkg/errors"
+)
+
+// ParseWebhooks parses the webhook namespace. Endpoints are defined using
+// `webhook hook <event> <url>`
+func (c *Config) ParseWebhooks() (webhook.Dispatcher, error) {
+	var opts webhook.WebhookOpts
+
+	lines := c.lines[NamespaceWebhook]
+
+	if err := c.parse(lines, &opts, "hook"); err != nil {
+		return nil, err
+	}
+
+	opts.SetLogger(c.logger)
+
+	if opts.Timeout < 0 || opts.Retries < 0 || opts.QueueSize < 0 {
+		return nil, errors.New("webhook timeout, retries and queue_size can't be negative")
+	}
+
+	var hooks []webhook.Hook
+
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != "hook" {
+			continue
+		}
+
+		h, err := webhook.NewHook(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, errors.Errorf("error parsing webhook hook on %s: %s", l, err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	return webhook.NewHTTPDispatcher(&opts, hooks), nil
+}
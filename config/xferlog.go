@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/xferlog"
+)
+
+// ParseXferlog parses the xferlog namespace, `xferlog file <file>` turns on
+// recording every transfer
+func (c *Config) ParseXferlog() (xferlog.Log, error) {
+	var opts xferlog.XferlogOpts
+
+	if err := c.parse(c.lines[NamespaceXferlog], &opts); err != nil {
+		return nil, err
+	}
+
+	return xferlog.NewFileLog(&opts)
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"github.com/goftpd/goftpd/zipscript"
+	"github.com/pkg/errors"
+)
+
+// ParseZipscript parses the zipscript namespace, releases are only checked
+// when `zipscript enabled true` is set
+func (c *Config) ParseZipscript() (*zipscript.Zipscript, error) {
+	var opts zipscript.ZipscriptOpts
+
+	if err := c.parse(c.lines[NamespaceZipscript], &opts); err != nil {
+		return nil, err
+	}
+
+	z, err := zipscript.NewZipscript(&opts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to create zipscript")
+	}
+
+	return z, nil
+}
@@ -0,0 +1,188 @@
+// Package cookie renders the `%[name]` cookies of login messages, .message
+// files, SITE replies and announcements so each of them formats users,
+// sizes and speeds in the same way.
+//
+// A cookie can be padded to a width as with printf, `%-10[user]` left
+// aligns the user in 10 columns and `%10[user]` right aligns it. Unknown
+// cookies are left as they are
+package cookie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+// Cookies maps the names of cookies to their values
+type Cookies map[string]string
+
+// Merge adds the cookies of others, later cookies replace earlier ones of
+// the same name. Returns c so calls can be chained
+func (c Cookies) Merge(others ...Cookies) Cookies {
+	for _, o := range others {
+		for name, value := range o {
+			c[name] = value
+		}
+	}
+	return c
+}
+
+// Expand replaces every cookie in text with its value
+func (c Cookies) Expand(text string) string {
+	var b strings.Builder
+
+	for {
+		i := strings.IndexByte(text, '%')
+		if i < 0 {
+			b.WriteString(text)
+			break
+		}
+
+		b.WriteString(text[:i])
+		text = text[i:]
+
+		value, n, ok := c.parse(text)
+		if !ok {
+			b.WriteByte('%')
+			text = text[1:]
+			continue
+		}
+
+		b.WriteString(value)
+		text = text[n:]
+	}
+
+	return b.String()
+}
+
+// parse reads the cookie at the start of text, returning its padded value
+// and length. ok is false if text doesn't start with a known cookie
+func (c Cookies) parse(text string) (value string, n int, ok bool) {
+	// skip the %
+	n = 1
+
+	left := n < len(text) && text[n] == '-'
+	if left {
+		n++
+	}
+
+	start := n
+	for n < len(text) && text[n] >= '0' && text[n] <= '9' {
+		n++
+	}
+
+	var width int
+	if n > start {
+		width, _ = strconv.Atoi(text[start:n])
+	}
+
+	if n >= len(text) || text[n] != '[' {
+		return "", 0, false
+	}
+
+	end := strings.IndexByte(text[n:], ']')
+	if end < 0 {
+		return "", 0, false
+	}
+
+	value, ok = c[text[n+1:n+end]]
+	if !ok {
+		return "", 0, false
+	}
+
+	n += end + 1
+
+	if left {
+		width = -width
+	}
+
+	return fmt.Sprintf("%*s", width, value), n, true
+}
+
+// User returns the cookies describing the User. The staff comment is
+// deliberately not available
+func User(u *acl.User) Cookies {
+	return Cookies{
+		"user":      u.Name,
+		"group":     u.PrimaryGroup,
+		"tagline":   u.Tagline,
+		"credits":   FormatBytes(u.Credits),
+		"ratio":     FormatRatio(u),
+		"logins":    strconv.Itoa(u.Logins),
+		"uploads":   strconv.Itoa(u.Uploads),
+		"downloads": strconv.Itoa(u.Downloads),
+	}
+}
+
+// Transfer returns the cookies of a transfer of bytes at bps bytes a second
+func Transfer(bytes int64, bps float64) Cookies {
+	return Cookies{
+		"bytes": FormatBytes(bytes),
+		"speed": FormatSpeed(bps),
+	}
+}
+
+// FormatBytes renders a number of bytes in the largest whole unit
+func FormatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	f := float64(n)
+	i := 0
+
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d%s", n, units[i])
+	}
+
+	return fmt.Sprintf("%.1f%s", f, units[i])
+}
+
+// FormatSpeed renders bytes per second
+func FormatSpeed(bps float64) string {
+	return FormatBytes(int64(bps)) + "/s"
+}
+
+// FormatAge renders how long ago something happened in its two largest
+// units, i.e. 3d4h or 12m30s
+func FormatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	d = d.Truncate(time.Second)
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+
+	hours := d / time.Hour
+	d -= hours * time.Hour
+
+	minutes := d / time.Minute
+	seconds := (d - minutes*time.Minute) / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+	}
+
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// FormatRatio renders the User's ratio, i.e. 1:3 or Leech
+func FormatRatio(u *acl.User) string {
+	if u.Leech() {
+		return "Leech"
+	}
+	return fmt.Sprintf("1:%d", u.Ratio)
+}
@@ -0,0 +1,102 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestExpand(t *testing.T) {
+	c := Cookies{
+		"user":  "bob",
+		"group": "friends",
+	}
+
+	var tests = []struct {
+		text     string
+		expected string
+	}{
+		{"Welcome back %[user]!", "Welcome back bob!"},
+		{"%[user]@%[group]", "bob@friends"},
+		{"[%-6[user]]", "[bob   ]"},
+		{"[%6[user]]", "[   bob]"},
+		{"[%2[group]]", "[friends]"},
+		{"%[unknown] %[user]", "%[unknown] bob"},
+		{"100% of %[user", "100% of %[user"},
+		{"%%[user]", "%bob"},
+		{"%-[user]", "bob"},
+		{"%", "%"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := c.Expand(tt.text); got != tt.expected {
+				t.Fatalf("expected '%s' but got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	c := Cookies{"user": "bob", "group": "friends"}.Merge(Cookies{"group": "siteops"}, Cookies{"free": "1.0GB"})
+
+	if c["user"] != "bob" || c["group"] != "siteops" || c["free"] != "1.0GB" {
+		t.Fatalf("unexpected cookies: %v", c)
+	}
+}
+
+func TestUser(t *testing.T) {
+	u := acl.User{
+		Name:         "bob",
+		PrimaryGroup: "friends",
+		Tagline:      "hello",
+		Comment:      "staff only",
+		Credits:      3 * 1024 * 1024,
+		Ratio:        3,
+		Logins:       2,
+	}
+
+	c := User(&u)
+
+	expected := "bob friends hello 3.0MB 1:3 2"
+	if got := c.Expand("%[user] %[group] %[tagline] %[credits] %[ratio] %[logins]"); got != expected {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+
+	if _, ok := c["comment"]; ok {
+		t.Fatal("expected no comment cookie")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		got      string
+		expected string
+	}{
+		{FormatBytes(0), "0B"},
+		{FormatBytes(1023), "1023B"},
+		{FormatBytes(1536), "1.5KB"},
+		{FormatBytes(5 * 1024 * 1024 * 1024), "5.0GB"},
+		{FormatSpeed(2048), "2.0KB/s"},
+		{FormatRatio(&acl.User{}), "Leech"},
+		{FormatRatio(&acl.User{Ratio: 3}), "1:3"},
+		{FormatAge(-time.Second), "0s"},
+		{FormatAge(90 * time.Second), "1m30s"},
+		{FormatAge(26*time.Hour + 5*time.Minute), "1d2h"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("expected '%s' but got '%s'", tt.expected, tt.got)
+		}
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	expected := "1.0MB at 512.0KB/s"
+	if got := Transfer(1024*1024, 512*1024).Expand("%[bytes] at %[speed]"); got != expected {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
@@ -0,0 +1,316 @@
+// Package dirlog provides a persistent log of directories created in the
+// configured sections, i.e. new releases
+package dirlog
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "dirlog:"
+
+// Entry is a logged directory
+type Entry struct {
+	Path    string
+	Section string
+	User    string
+	Group   string
+	Time    time.Time
+	// when the release was pred, zero if it isn't known
+	Pretime time.Time `msgpack:",omitempty"`
+	// flagged as pred too long before it was created, see the pretime
+	// package
+	Old bool `msgpack:",omitempty"`
+}
+
+// Name returns the name of the directory
+func (e Entry) Name() string {
+	return filepath.Base(e.Path)
+}
+
+// Age returns how long after its pre the directory was created, false if
+// the pre time isn't known
+func (e Entry) Age() (time.Duration, bool) {
+	if e.Pretime.IsZero() {
+		return 0, false
+	}
+	return e.Time.Sub(e.Pretime), true
+}
+
+// Section groups the directories that are logged, i.e. /mp3/* would log
+// every release created in /mp3 as the MP3 section
+type Section struct {
+	name string
+	g    glob.Glob
+}
+
+// NewSection takes a line of text (i.e. from a config file) in the form of
+// `<name> <glob>`
+func NewSection(line string) (Section, error) {
+	var section Section
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return section, errors.New("section requires 2 fields")
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[1]), '/')
+	if err != nil {
+		return section, err
+	}
+
+	section.name = strings.ToLower(fields[0])
+	section.g = g
+
+	return section, nil
+}
+
+// Dirlog records directories created in sections
+type Dirlog interface {
+	Record(string, string, string) (bool, error)
+	Get(string) (*Entry, error)
+	Latest(string, int) ([]Entry, error)
+	Sections() []string
+	Search(string, int) ([]Entry, error)
+	Remove(string) error
+	SetPretime(string, time.Time, bool) error
+	Close() error
+}
+
+type DirlogOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// BadgerDirlog implements Dirlog using a badger key/value store
+type BadgerDirlog struct {
+	*DirlogOpts
+	db       *badger.DB
+	sections []Section
+}
+
+// NewBadgerDirlog takes in options, a badger DB and the sections to log.
+// Sections are matched in order, first match wins
+func NewBadgerDirlog(opts *DirlogOpts, db *badger.DB, sections []Section) *BadgerDirlog {
+	return &BadgerDirlog{
+		DirlogOpts: opts,
+		db:         db,
+		sections:   sections,
+	}
+}
+
+func (d *BadgerDirlog) key(path string) []byte {
+	return []byte(keyPrefix + strings.ToLower(filepath.Clean(path)))
+}
+
+// section returns the name of the section the path belongs to, or an empty
+// string if it isn't in any
+func (d *BadgerDirlog) section(path string) string {
+	path = strings.ToLower(path)
+
+	for _, s := range d.sections {
+		if s.g.Match(path) {
+			return s.name
+		}
+	}
+
+	return ""
+}
+
+// Sections returns the name of every section in the order they are matched
+func (d *BadgerDirlog) Sections() []string {
+	names := make([]string, 0, len(d.sections))
+	for _, s := range d.sections {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Record logs the directory created by user if it is in a section, replacing
+// any existing Entry for the path. Returns whether it was logged
+func (d *BadgerDirlog) Record(path, user, group string) (bool, error) {
+	section := d.section(path)
+	if len(section) == 0 {
+		return false, nil
+	}
+
+	e := Entry{
+		Path:    filepath.Clean(path),
+		Section: section,
+		User:    user,
+		Group:   group,
+		Time:    time.Now(),
+	}
+
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(e); err != nil {
+		return false, err
+	}
+
+	err := d.db.Update(func(tx *badger.Txn) error {
+		return tx.Set(d.key(path), b.Bytes())
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get returns the Entry for the path, nil if it wasn't logged
+func (d *BadgerDirlog) Get(path string) (*Entry, error) {
+	var e Entry
+
+	err := d.db.View(func(tx *badger.Txn) error {
+		item, err := tx.Get(d.key(path))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &e)
+		})
+	})
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// filter returns up to limit entries that match, newest first
+func (d *BadgerDirlog) filter(match func(Entry) bool, limit int) ([]Entry, error) {
+	var entries []Entry
+
+	err := d.db.View(func(tx *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+
+		it := tx.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var e Entry
+
+			err := it.Item().Value(func(val []byte) error {
+				return msgpack.Unmarshal(val, &e)
+			})
+			if err != nil {
+				return err
+			}
+
+			if match(e) {
+				entries = append(entries, e)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Latest returns up to limit of the newest entries in the section, an empty
+// section returns entries from every section
+func (d *BadgerDirlog) Latest(section string, limit int) ([]Entry, error) {
+	section = strings.ToLower(section)
+
+	return d.filter(func(e Entry) bool {
+		return len(section) == 0 || e.Section == section
+	}, limit)
+}
+
+// Search returns up to limit entries with names matching the glob pattern,
+// newest first. A pattern without wildcards matches anywhere in the name
+func (d *BadgerDirlog) Search(pattern string, limit int) ([]Entry, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if len(pattern) == 0 {
+		return nil, errors.New("pattern required")
+	}
+
+	if glob.QuoteMeta(pattern) == pattern {
+		pattern = "*" + pattern + "*"
+	}
+
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.filter(func(e Entry) bool {
+		return g.Match(strings.ToLower(e.Name()))
+	}, limit)
+}
+
+// Remove deletes the Entry for the path, i.e. when the directory is removed
+func (d *BadgerDirlog) Remove(path string) error {
+	return d.db.Update(func(tx *badger.Txn) error {
+		return tx.Delete(d.key(path))
+	})
+}
+
+// SetPretime records when the directory was pred and whether it is old,
+// nothing is done if the directory wasn't logged
+func (d *BadgerDirlog) SetPretime(path string, pretime time.Time, old bool) error {
+	return d.db.Update(func(tx *badger.Txn) error {
+		item, err := tx.Get(d.key(path))
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return err
+		}
+
+		var e Entry
+
+		err = item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &e)
+		})
+		if err != nil {
+			return err
+		}
+
+		e.Pretime, e.Old = pretime, old
+
+		var b bytes.Buffer
+
+		if err := msgpack.NewEncoder(&b).Encode(e); err != nil {
+			return err
+		}
+
+		return tx.Set(d.key(path), b.Bytes())
+	})
+}
+
+// Close closes the underlying badger store
+func (d *BadgerDirlog) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,156 @@
+package dirlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewSection(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"mp3 /mp3/*", nil},
+		{"mp3", errors.New("section requires 2 fields")},
+		{"mp3 /[", errors.New("unexpected end of input")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewSection(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	d := newMemoryDirlog(t, "mp3 /mp3/*", "0day /0day/*/*")
+	defer closeMemoryDirlog(t, d)
+
+	var tests = []struct {
+		path    string
+		section string
+	}{
+		{"/mp3/Artist-Album-2020-GRP", "mp3"},
+		{"/0day/1015/Some.App-GRP", "0day"},
+		{"/mp3/Artist-Album-2020-GRP/CD1", ""},
+		{"/0day/1015", ""},
+	}
+
+	for _, tt := range tests {
+		logged, err := d.Record(tt.path, "user", "group")
+		checkErr(t, err, nil)
+
+		if logged != (len(tt.section) > 0) {
+			t.Errorf("expected '%s' logged to be %t", tt.path, !logged)
+		}
+
+		e, err := d.Get(tt.path)
+		checkErr(t, err, nil)
+
+		if len(tt.section) == 0 {
+			if e != nil {
+				t.Errorf("unexpected entry for '%s': %+v", tt.path, e)
+			}
+			continue
+		}
+
+		if e == nil || e.Section != tt.section || e.User != "user" || e.Group != "group" {
+			t.Errorf("unexpected entry for '%s': %+v", tt.path, e)
+		}
+	}
+}
+
+func TestLatestSearchRemove(t *testing.T) {
+	d := newMemoryDirlog(t, "mp3 /mp3/*", "0day /0day/*")
+	defer closeMemoryDirlog(t, d)
+
+	for _, p := range []string{
+		"/mp3/Artist-Album-2020-GRP",
+		"/0day/Some.App-GRP",
+		"/mp3/Other-Album-2020-GRP",
+	} {
+		_, err := d.Record(p, "user", "group")
+		checkErr(t, err, nil)
+	}
+
+	entries, err := d.Latest("", 0)
+	checkErr(t, err, nil)
+
+	if len(entries) != 3 || entries[0].Name() != "Other-Album-2020-GRP" {
+		t.Fatalf("expected 3 entries newest first got %+v", entries)
+	}
+
+	if sections := d.Sections(); len(sections) != 2 || sections[0] != "mp3" || sections[1] != "0day" {
+		t.Fatalf("unexpected sections %v", sections)
+	}
+
+	entries, err = d.Latest("MP3", 1)
+	checkErr(t, err, nil)
+
+	if len(entries) != 1 || entries[0].Name() != "Other-Album-2020-GRP" {
+		t.Fatalf("unexpected latest mp3: %+v", entries)
+	}
+
+	entries, err = d.Search("album", 0)
+	checkErr(t, err, nil)
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries got %+v", entries)
+	}
+
+	_, err = d.Search(" ", 0)
+	checkErr(t, err, errors.New("pattern required"))
+
+	checkErr(t, d.Remove("/MP3/Other-Album-2020-GRP"), nil)
+
+	entries, err = d.Search("album", 0)
+	checkErr(t, err, nil)
+
+	if len(entries) != 1 || entries[0].Name() != "Artist-Album-2020-GRP" {
+		t.Fatalf("unexpected entries after remove: %+v", entries)
+	}
+}
+
+func TestSetPretime(t *testing.T) {
+	d := newMemoryDirlog(t, "mp3 /mp3/*")
+	defer closeMemoryDirlog(t, d)
+
+	path := "/mp3/Artist-Album-2020-GRP"
+
+	_, err := d.Record(path, "user", "group")
+	checkErr(t, err, nil)
+
+	e, err := d.Get(path)
+	checkErr(t, err, nil)
+
+	if _, ok := e.Age(); ok {
+		t.Fatal("expected no age before the pretime is set")
+	}
+
+	pretime := e.Time.Add(-2 * time.Hour)
+
+	checkErr(t, d.SetPretime(path, pretime, true), nil)
+
+	e, err = d.Get(path)
+	checkErr(t, err, nil)
+
+	if age, ok := e.Age(); !ok || age != 2*time.Hour || !e.Old {
+		t.Errorf("unexpected age %s (%t) old %t", age, ok, e.Old)
+	}
+
+	// not logged
+	checkErr(t, d.SetPretime("/mp3/Other-GRP", pretime, false), nil)
+
+	e, err = d.Get("/mp3/Other-GRP")
+	checkErr(t, err, nil)
+
+	if e != nil {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
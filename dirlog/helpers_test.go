@@ -0,0 +1,54 @@
+package dirlog
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryDirlog(t *testing.T, lines ...string) *BadgerDirlog {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	var sections []Section
+	for _, l := range lines {
+		section, err := NewSection(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewSection: %s", err)
+		}
+		sections = append(sections, section)
+	}
+
+	return NewBadgerDirlog(&DirlogOpts{}, db, sections)
+}
+
+func closeMemoryDirlog(t *testing.T, d *BadgerDirlog) {
+	t.Helper()
+	if err := d.Close(); err != nil {
+		t.Fatalf("error closing dirlog: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
@@ -0,0 +1,315 @@
+// Package dupe provides a persistent record of uploaded file and directory
+// names so duplicate uploads can be rejected or warned about
+package dupe
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "dupe:"
+
+// Action is an "enum" for what happens when a dupe is uploaded
+type Action string
+
+const (
+	ActionIgnore Action = "ignore"
+	ActionWarn          = "warn"
+	ActionReject        = "reject"
+)
+
+var stringToAction = map[string]Action{
+	string(ActionIgnore): ActionIgnore,
+	string(ActionWarn):   ActionWarn,
+	string(ActionReject): ActionReject,
+}
+
+// Entry is a recorded upload
+type Entry struct {
+	Name string
+	Path string
+	User string
+	Time time.Time
+	Dir  bool
+}
+
+// String describes the Entry for replying to users
+func (e Entry) String() string {
+	return fmt.Sprintf("'%s' uploaded by %s on %s", e.Path, e.User, e.Time.Format("2006-01-02 15:04"))
+}
+
+// Rule decides what happens when a dupe is uploaded to paths matching
+// its glob
+type Rule struct {
+	g      glob.Glob
+	action Action
+}
+
+// NewRule takes a line of text (i.e. from a config file) in the form of
+// `<glob> <action>`
+func NewRule(line string) (Rule, error) {
+	var rule Rule
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return rule, errors.New("rule requires 2 fields")
+	}
+
+	action, ok := stringToAction[strings.ToLower(fields[1])]
+	if !ok {
+		return rule, errors.Errorf("unknown action '%s'", fields[1])
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[0]), '/')
+	if err != nil {
+		return rule, err
+	}
+
+	rule.g = g
+	rule.action = action
+
+	return rule, nil
+}
+
+// Normalize returns the name recorded for a path, names are compared
+// case insensitively
+func Normalize(path string) string {
+	return strings.ToLower(strings.TrimSpace(filepath.Base(path)))
+}
+
+// Dupe records uploads and checks for duplicates
+type Dupe interface {
+	Record(string, string, bool) error
+	Check(string) (Action, *Entry, error)
+	Search(string, int) ([]Entry, error)
+	Remove(string) (int, error)
+	Close() error
+}
+
+type DupeOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// BadgerDupe implements Dupe using a badger key/value store
+type BadgerDupe struct {
+	*DupeOpts
+	db    *badger.DB
+	rules []Rule
+}
+
+// NewBadgerDupe takes in options, a badger DB and the rules to check uploads
+// against. Rules are matched in order, first match wins
+func NewBadgerDupe(opts *DupeOpts, db *badger.DB, rules []Rule) *BadgerDupe {
+	return &BadgerDupe{
+		DupeOpts: opts,
+		db:       db,
+		rules:    rules,
+	}
+}
+
+func (d *BadgerDupe) key(name string) []byte {
+	return []byte(keyPrefix + name)
+}
+
+// action returns the action of the first rule matching path, paths not
+// matching any rule are ignored
+func (d *BadgerDupe) action(path string) Action {
+	path = strings.ToLower(path)
+
+	for _, r := range d.rules {
+		if r.g.Match(path) {
+			return r.action
+		}
+	}
+
+	return ActionIgnore
+}
+
+// Record saves the name of the uploaded path, replacing any existing Entry
+// with the same name
+func (d *BadgerDupe) Record(path, user string, dir bool) error {
+	e := Entry{
+		Name: filepath.Base(path),
+		Path: path,
+		User: user,
+		Time: time.Now(),
+		Dir:  dir,
+	}
+
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(e); err != nil {
+		return err
+	}
+
+	return d.db.Update(func(tx *badger.Txn) error {
+		return tx.Set(d.key(Normalize(path)), b.Bytes())
+	})
+}
+
+// Check looks for a recorded upload with the same name as path. Returns the
+// action of the matching rule and the existing Entry, if there is no dupe or
+// the rule ignores dupes ActionIgnore and nil are returned
+func (d *BadgerDupe) Check(path string) (Action, *Entry, error) {
+	action := d.action(path)
+	if action == ActionIgnore {
+		return ActionIgnore, nil, nil
+	}
+
+	var e Entry
+
+	err := d.db.View(func(tx *badger.Txn) error {
+		item, err := tx.Get(d.key(Normalize(path)))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &e)
+		})
+	})
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return ActionIgnore, nil, nil
+		}
+		return ActionIgnore, nil, err
+	}
+
+	return action, &e, nil
+}
+
+// compile compiles the pattern used to search names, patterns without any
+// wildcards match anywhere in a name
+func compile(pattern string, substring bool) (glob.Glob, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if len(pattern) == 0 {
+		return nil, errors.New("pattern required")
+	}
+
+	if substring && glob.QuoteMeta(pattern) == pattern {
+		pattern = "*" + pattern + "*"
+	}
+
+	return glob.Compile(pattern)
+}
+
+// walk calls fn for every Entry with a name matching g
+func (d *BadgerDupe) walk(tx *badger.Txn, g glob.Glob, fn func([]byte, Entry) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(keyPrefix)
+
+	it := tx.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+
+		if !g.Match(string(item.Key()[len(keyPrefix):])) {
+			continue
+		}
+
+		var e Entry
+
+		err := item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &e)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := fn(item.KeyCopy(nil), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Search returns up to limit entries with names matching the pattern, newest
+// first. A pattern without wildcards matches anywhere in the name
+func (d *BadgerDupe) Search(pattern string, limit int) ([]Entry, error) {
+	g, err := compile(pattern, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+
+	err = d.db.View(func(tx *badger.Txn) error {
+		return d.walk(tx, g, func(_ []byte, e Entry) error {
+			entries = append(entries, e)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Remove deletes every Entry with a name matching the pattern so it can be
+// uploaded again. Returns the number removed
+func (d *BadgerDupe) Remove(pattern string) (int, error) {
+	g, err := compile(pattern, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys [][]byte
+
+	err = d.db.View(func(tx *badger.Txn) error {
+		return d.walk(tx, g, func(key []byte, _ Entry) error {
+			keys = append(keys, key)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	// use a batch as there is potential for the deletes to be
+	// too big for a single transaction
+	wb := d.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Close closes the underlying badger store
+func (d *BadgerDupe) Close() error {
+	return d.db.Close()
+}
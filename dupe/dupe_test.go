@@ -0,0 +1,121 @@
+package dupe
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewRule(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"/mp3/* reject", nil},
+		{"/** WARN", nil},
+		{"/mp3/*", errors.New("rule requires 2 fields")},
+		{"/mp3/* nuke", errors.New("unknown action 'nuke'")},
+		{"/[ warn", errors.New("unexpected end of input")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewRule(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d := newMemoryDupe(t, "/mp3/* reject", "/0day/** warn")
+	defer closeMemoryDupe(t, d)
+
+	checkErr(t, d.Record("/mp3/Some.Release-GRP", "user", true), nil)
+	checkErr(t, d.Record("/0day/file.zip", "user", false), nil)
+
+	var tests = []struct {
+		path   string
+		action Action
+	}{
+		{"/mp3/some.release-grp", ActionReject},
+		{"/0day/other/FILE.ZIP", ActionWarn},
+		{"/mp3/new.release-grp", ActionIgnore},
+		{"/other/file.zip", ActionIgnore},
+	}
+
+	for _, tt := range tests {
+		action, e, err := d.Check(tt.path)
+		checkErr(t, err, nil)
+
+		if action != tt.action {
+			t.Errorf("expected action for '%s' to be '%s' got '%s'", tt.path, tt.action, action)
+		}
+
+		if (action == ActionIgnore) != (e == nil) {
+			t.Errorf("unexpected entry for '%s': %+v", tt.path, e)
+		}
+	}
+
+	_, e, err := d.Check("/mp3/SOME.RELEASE-GRP")
+	checkErr(t, err, nil)
+
+	if e.User != "user" || e.Path != "/mp3/Some.Release-GRP" || !e.Dir {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestSearchRemove(t *testing.T) {
+	d := newMemoryDupe(t)
+	defer closeMemoryDupe(t, d)
+
+	checkErr(t, d.Record("/mp3/Artist-Album-2020-GRP", "user", true), nil)
+	checkErr(t, d.Record("/mp3/Artist-Single-2020-GRP", "user", true), nil)
+	checkErr(t, d.Record("/mp3/Other-Album-2020-GRP", "user", true), nil)
+
+	entries, err := d.Search("artist", 0)
+	checkErr(t, err, nil)
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries got %d", len(entries))
+	}
+
+	// newest first
+	if entries[0].Name != "Artist-Single-2020-GRP" {
+		t.Errorf("expected newest first got %+v", entries)
+	}
+
+	entries, err = d.Search("*-album-*", 1)
+	checkErr(t, err, nil)
+
+	if len(entries) != 1 {
+		t.Fatalf("expected limit of 1 got %d", len(entries))
+	}
+
+	_, err = d.Search(" ", 0)
+	checkErr(t, err, errors.New("pattern required"))
+
+	// remove doesn't match substrings
+	n, err := d.Remove("artist")
+	checkErr(t, err, nil)
+
+	if n != 0 {
+		t.Errorf("expected nothing removed got %d", n)
+	}
+
+	n, err = d.Remove("artist-*")
+	checkErr(t, err, nil)
+
+	if n != 2 {
+		t.Errorf("expected 2 removed got %d", n)
+	}
+
+	entries, err = d.Search("*", 0)
+	checkErr(t, err, nil)
+
+	if len(entries) != 1 || entries[0].Name != "Other-Album-2020-GRP" {
+		t.Errorf("unexpected entries after remove: %+v", entries)
+	}
+}
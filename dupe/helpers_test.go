@@ -0,0 +1,54 @@
+package dupe
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryDupe(t *testing.T, lines ...string) *BadgerDupe {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	var rules []Rule
+	for _, l := range lines {
+		rule, err := NewRule(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewRule: %s", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return NewBadgerDupe(&DupeOpts{}, db, rules)
+}
+
+func closeMemoryDupe(t *testing.T, d *BadgerDupe) {
+	t.Helper()
+	if err := d.Close(); err != nil {
+		t.Fatalf("error closing dupe: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
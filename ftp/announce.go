@@ -0,0 +1,122 @@
+package ftp
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/race"
+)
+
+// releaseCookies returns the cookies describing the release at the path,
+// its section comes from the dirlog
+func (s *Server) releaseCookies(p string) cookie.Cookies {
+	c := cookie.Cookies{
+		"release": path.Base(p),
+		"path":    p,
+		"section": "",
+	}
+
+	if entry, err := s.dirlog.Get(p); err == nil && entry != nil {
+		c["section"] = entry.Section
+	}
+
+	return c
+}
+
+// serverCookies returns the cookies describing the server's current speeds
+// and totals, available to every announcement
+func (s *Server) serverCookies() cookie.Cookies {
+	info := s.Info()
+
+	return cookie.Cookies{
+		"site_uploads":        strconv.FormatInt(info.Uploads, 10),
+		"site_upload_bytes":   cookie.FormatBytes(info.UploadBytes),
+		"site_upload_speed":   cookie.FormatSpeed(info.UploadSpeed),
+		"site_downloads":      strconv.FormatInt(info.Downloads, 10),
+		"site_download_bytes": cookie.FormatBytes(info.DownloadBytes),
+		"site_download_speed": cookie.FormatSpeed(info.DownloadSpeed),
+	}
+}
+
+// announceEvent announces the event with the server's cookies, the event's
+// own cookies replace any of the same name
+func (s *Server) announceEvent(event string, c cookie.Cookies) {
+	s.announce.Announce(event, s.serverCookies().Merge(c))
+}
+
+// announceNewDir announces a release created in a section along with how
+// long after its pre it was created, if known
+func (s *Server) announceNewDir(e dirlog.Entry) {
+	c := cookie.Cookies{
+		"release": e.Name(),
+		"path":    e.Path,
+		"section": e.Section,
+		"user":    e.User,
+		"group":   e.Group,
+		"age":     "",
+		"pretime": "",
+		"old":     "",
+	}
+
+	if age, ok := e.Age(); ok {
+		c["age"] = cookie.FormatAge(age)
+		c["pretime"] = e.Pretime.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	if e.Old {
+		c["old"] = "OLD"
+	}
+
+	s.announceEvent(announce.EventNewDir, c)
+}
+
+// announceComplete announces a completed release along with its winners
+func (s *Server) announceComplete(release *race.Release) {
+	var winner, winnerGroup string
+
+	if users := release.Users(); len(users) > 0 {
+		winner = users[0].Name
+	}
+
+	if groups := release.Groups(); len(groups) > 0 {
+		winnerGroup = groups[0].Name
+	}
+
+	s.announceEvent(announce.EventComplete, s.releaseCookies(release.Path).Merge(
+		cookie.Transfer(release.Bytes, release.Speed()),
+		cookie.Cookies{
+			"size":         cookie.FormatBytes(release.Bytes),
+			"files":        strconv.Itoa(release.Files),
+			"racers":       strconv.Itoa(len(release.Racers)),
+			"winner":       winner,
+			"winner_group": winnerGroup,
+			"time":         cookie.FormatAge(release.Elapsed()),
+		},
+	))
+}
+
+// announceNuke is a cmd.Hook run after every command, announcing a
+// successful SITE NUKE
+func (s *Server) announceNuke(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	if hc.Name != "SITE NUKE" || r.Code != cmd.StatusOK.Code || len(hc.Params) == 0 {
+		return
+	}
+
+	record, err := s.nukes.Get(s.fs.Join(session.CWD(), hc.Params[:1]))
+	if err != nil || record == nil {
+		return
+	}
+
+	s.announceEvent(announce.EventNuke, s.releaseCookies(record.Path).Merge(cookie.Cookies{
+		"nuker":      record.Nuker,
+		"reason":     record.Reason,
+		"multiplier": strconv.Itoa(record.Multiplier),
+		"size":       cookie.FormatBytes(record.Bytes()),
+		"nukees":     strconv.Itoa(len(record.Nukees)),
+	}))
+}
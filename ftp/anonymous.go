@@ -0,0 +1,116 @@
+package ftp
+
+import (
+	"context"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// acquireAnonymous takes one of the AnonymousSlots, returns false if they
+// are all in use
+func (s *Server) acquireAnonymous() bool {
+	s.anonymousMtx.Lock()
+	defer s.anonymousMtx.Unlock()
+
+	if s.AnonymousSlots > 0 && s.anonymousSessions >= s.AnonymousSlots {
+		return false
+	}
+
+	s.anonymousSessions++
+
+	return true
+}
+
+// releaseAnonymous gives back a slot taken with acquireAnonymous
+func (s *Server) releaseAnonymous() {
+	s.anonymousMtx.Lock()
+	defer s.anonymousMtx.Unlock()
+
+	if s.anonymousSessions > 0 {
+		s.anonymousSessions--
+	}
+}
+
+// limitedDataConn limits the average speed of reads and writes to a data
+// connection to rate bytes per second
+type limitedDataConn struct {
+	cmd.DataConn
+
+	ctx  context.Context
+	rate int
+
+	start time.Time
+	total int64
+}
+
+func newLimitedDataConn(ctx context.Context, d cmd.DataConn, rate int) *limitedDataConn {
+	return &limitedDataConn{
+		DataConn: d,
+		ctx:      ctx,
+		rate:     rate,
+		start:    time.Now(),
+	}
+}
+
+// Read reads at most a second's worth of data before waiting
+func (l *limitedDataConn) Read(p []byte) (int, error) {
+	if len(p) > l.rate {
+		p = p[:l.rate]
+	}
+
+	n, err := l.DataConn.Read(p)
+	if err != nil {
+		return n, err
+	}
+
+	return n, l.wait(n)
+}
+
+// Write writes p a second's worth at a time, waiting in between
+func (l *limitedDataConn) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > l.rate {
+			chunk = chunk[:l.rate]
+		}
+
+		n, err := l.DataConn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		if err := l.wait(n); err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// wait sleeps until the average speed is back under the rate
+func (l *limitedDataConn) wait(n int) error {
+	l.total += int64(n)
+
+	expected := time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second))
+
+	delay := expected - time.Since(l.start)
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+package ftp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// redacted replaces parameters that are never recorded
+const redacted = "********"
+
+// auditCommand describes how a privileged SITE command is recorded
+type auditCommand struct {
+	// returns what the command acted on
+	target func(*Server, cmd.Session, []string) string
+	// indexes of parameters never recorded, i.e. passwords
+	redact []int
+	// optional, only commands it returns true for are recorded
+	match func([]string) bool
+}
+
+// param targets the parameter at i
+func param(i int) func(*Server, cmd.Session, []string) string {
+	return func(s *Server, session cmd.Session, params []string) string {
+		if i < len(params) {
+			return params[i]
+		}
+		return ""
+	}
+}
+
+// pathParam targets the path given by the parameter at i
+func pathParam(i int) func(*Server, cmd.Session, []string) string {
+	return func(s *Server, session cmd.Session, params []string) string {
+		if i < len(params) {
+			return s.fs.Join(session.CWD(), params[i:i+1])
+		}
+		return ""
+	}
+}
+
+// lastPathParam targets the path given by the last parameter
+func lastPathParam(s *Server, session cmd.Session, params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	return s.fs.Join(session.CWD(), params[len(params)-1:])
+}
+
+// noTarget is used for commands acting on the whole site
+func noTarget(*Server, cmd.Session, []string) string { return "" }
+
+// auditCommands are the privileged SITE commands recorded in the audit log
+var auditCommands = map[string]auditCommand{
+	"SITE ADDUSER":   {target: param(0), redact: []int{1}},
+	"SITE GADDUSER":  {target: param(1), redact: []int{2}},
+	"SITE DELUSER":   {target: param(0)},
+	"SITE READD":     {target: param(0)},
+	"SITE PURGE":     {target: param(0)},
+	"SITE CHPASS":    {target: param(0), redact: []int{1}},
+	"SITE CHANGE":    {target: param(0)},
+	"SITE ADDIP":     {target: param(0)},
+	"SITE DELIP":     {target: param(0)},
+	"SITE UNLOCK":    {target: param(0)},
+	"SITE GRPADD":    {target: param(0)},
+	"SITE GRPDEL":    {target: param(0)},
+	"SITE GRPCHANGE": {target: param(0)},
+	// without a field SITE GRP only shows the group
+	"SITE GRP":    {target: param(0), match: func(p []string) bool { return len(p) > 1 }},
+	"SITE OTP":    {target: param(1), match: func(p []string) bool { return len(p) > 0 && strings.EqualFold(p[0], "reset") }},
+	"SITE CHOWN":  {target: lastPathParam},
+	"SITE CHGRP":  {target: lastPathParam},
+	"SITE NUKE":   {target: pathParam(0)},
+	"SITE UNNUKE": {target: pathParam(0)},
+	"SITE WIPE":   {target: pathParam(0)},
+	"SITE UNDUPE": {target: func(s *Server, session cmd.Session, p []string) string { return strings.Join(p, " ") }},
+	"SITE BAN":    {target: param(0)},
+	"SITE UNBAN":  {target: param(0)},
+	"SITE TRACE":  {target: param(0)},
+	"SITE CLOSE":  {target: noTarget},
+	"SITE REOPEN": {target: noTarget},
+}
+
+// auditCommand is a cmd.Hook run after every command, recording the
+// privileged SITE commands that succeeded in the audit log
+func (s *Server) auditCommand(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	c, ok := auditCommands[hc.Name]
+	if !ok || r.Err != nil || r.Code < 200 || r.Code >= 300 {
+		return
+	}
+
+	if c.match != nil && !c.match(hc.Params) {
+		return
+	}
+
+	params := make([]string, len(hc.Params))
+	copy(params, hc.Params)
+
+	for _, i := range c.redact {
+		if i < len(params) {
+			params[i] = redacted
+		}
+	}
+
+	s.recordAudit(audit.Entry{
+		Actor:  session.Login(),
+		IP:     sessionIP(session),
+		Action: strings.ToLower(strings.TrimPrefix(hc.Name, "SITE ")),
+		Target: c.target(s, session, hc.Params),
+		Params: params,
+	})
+}
+
+func (s *Server) recordAudit(e audit.Entry) {
+	if err := s.audit.Record(e); err != nil {
+		s.Logger().Error("unable to record audit entry",
+			logging.User(e.Actor),
+			logging.F("action", e.Action),
+			logging.F("target", e.Target),
+			logging.Err(err),
+		)
+	}
+}
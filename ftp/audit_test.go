@@ -0,0 +1,82 @@
+package ftp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+func TestAuditAlias(t *testing.T) {
+	opts := ServerOpts{Trace: true}
+	opts.SetAliases(cmd.Aliases{"AU": {"adduser"}})
+
+	s, done := newTestServer(t, &opts, "adduser *")
+	defer done()
+
+	s.addUser(t, "admin", nil)
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	c.login("admin")
+	c.expect("SITE AU bob secret *@127.0.0.1", 200)
+
+	entries, err := s.audit.Search(audit.Query{Action: "adduser"})
+	if err != nil {
+		t.Fatalf("unexpected error searching audit log: %s", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry but got %d", len(entries))
+	}
+
+	expected := []string{"bob", redacted, "*@127.0.0.1"}
+	if e := entries[0]; e.Actor != "admin" || e.Target != "bob" || !reflect.DeepEqual(e.Params, expected) {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	log := s.log.String()
+
+	if strings.Contains(log, "secret") {
+		t.Fatalf("password logged: %s", log)
+	}
+
+	if !strings.Contains(log, "SITE AU bob "+redacted) {
+		t.Fatalf("expected masked trace of the alias: %s", log)
+	}
+}
+
+func TestMaskLine(t *testing.T) {
+	aliases := cmd.Aliases{
+		"AU":   {"ADDUSER"},
+		"GAU":  {"GADDUSER", "staff"},
+		"PASS": {"CHPASS", "bob"},
+	}
+
+	var tests = []struct {
+		line     string
+		expected string
+	}{
+		{"PASS secret\r\n", "PASS " + redacted},
+		{"SITE ADDUSER bob secret", "SITE ADDUSER bob " + redacted},
+		{"SITE AU bob secret", "SITE AU bob " + redacted},
+		{"SITE GAU bob secret", "SITE GAU bob " + redacted},
+		{"SITE PASS secret", "SITE PASS " + redacted},
+		{"SITE WHO", "SITE WHO"},
+		{"SITE DELUSER bob", "SITE DELUSER bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := maskLine(tt.line, aliases); got != tt.expected {
+				t.Fatalf("expected '%s' but got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
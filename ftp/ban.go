@@ -0,0 +1,74 @@
+package ftp
+
+import (
+	"net"
+
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// banned checks to see if the connection's ip is banned, logging it if so
+func (s *Server) banned(conn net.Conn) bool {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+
+	b, ok := s.bans.Banned(net.ParseIP(host))
+	if !ok {
+		return false
+	}
+
+	s.Logger().Debug("refused banned connection", logging.IP(host), logging.F("ban", b.Network))
+
+	return true
+}
+
+// Violation records a failure such as a bad login from the session's ip,
+// returns true if the ip has now been banned. Sessions from the banned ip
+// are kicked apart from this one, which the caller should close
+func (s *Session) Violation(reason string) bool {
+	ip := sessionIP(s)
+
+	b, err := s.server.bans.Fail(net.ParseIP(ip), reason)
+	if err != nil {
+		s.Logger().Error("unable to ban", logging.F("reason", reason), logging.Err(err))
+		return false
+	}
+
+	if b == nil {
+		return false
+	}
+
+	s.Logger().Warn("banned", logging.F("ban", b.Network), logging.F("reason", reason))
+
+	s.server.recordAudit(audit.Entry{
+		Actor:  ban.AutoBan,
+		IP:     ip,
+		Action: "ban",
+		Target: b.Network,
+		Params: []string{reason},
+	})
+
+	s.server.kickBanned(s, b)
+
+	return true
+}
+
+// kickBanned disconnects every session covered by the ban apart from except
+func (s *Server) kickBanned(except *Session, b *ban.Ban) int {
+	network, err := ban.ParseNetwork(b.Network)
+	if err != nil {
+		return 0
+	}
+
+	return s.kick(except, func(info cmd.SessionInfo) bool {
+		host, _, err := net.SplitHostPort(info.IP)
+		if err != nil {
+			host = info.IP
+		}
+		return network.Contains(net.ParseIP(host))
+	})
+}
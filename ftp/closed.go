@@ -0,0 +1,88 @@
+package ftp
+
+import (
+	"sync"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// defaultClosedMessage is shown to users refused while the site is closed
+// when no reason was given and the server has no closed_message
+const defaultClosedMessage = "Site is closed for maintenance."
+
+// closedState is whether the site is closed to new logins and why, it is
+// only kept in memory so a restart reopens the site
+type closedState struct {
+	mtx    sync.RWMutex
+	closed bool
+	reason string
+}
+
+// CloseSite closes the site to new logins of users that aren't exempt, the
+// reason is shown to them. Closing an already closed site updates the reason
+func (s *Server) CloseSite(reason string) {
+	s.closed.mtx.Lock()
+	defer s.closed.mtx.Unlock()
+
+	if len(reason) == 0 {
+		reason = s.ClosedMessage
+	}
+
+	if len(reason) == 0 {
+		reason = defaultClosedMessage
+	}
+
+	s.closed.closed = true
+	s.closed.reason = reason
+}
+
+// ReopenSite allows logins again, returns false if the site wasn't closed
+func (s *Server) ReopenSite() bool {
+	s.closed.mtx.Lock()
+	defer s.closed.mtx.Unlock()
+
+	wasClosed := s.closed.closed
+
+	s.closed.closed = false
+	s.closed.reason = ""
+
+	return wasClosed
+}
+
+// SiteClosed returns the reason the site is closed, false if it is open
+func (s *Server) SiteClosed() (string, bool) {
+	s.closed.mtx.RLock()
+	defer s.closed.mtx.RUnlock()
+
+	return s.closed.reason, s.closed.closed
+}
+
+// Kick disconnects every session fn returns true for, returning how many
+// were disconnected
+func (s *Server) Kick(fn func(cmd.SessionInfo) bool) int {
+	return s.kick(nil, fn)
+}
+
+// kick disconnects every session fn returns true for apart from except,
+// returning how many were disconnected. Only the control connection is
+// closed, a transfer in progress finishes before the session ends
+func (s *Server) kick(except *Session, fn func(cmd.SessionInfo) bool) int {
+	s.sessionsMtx.RLock()
+	defer s.sessionsMtx.RUnlock()
+
+	var n int
+
+	for session := range s.sessions {
+		if session == except || !fn(session.snapshot()) {
+			continue
+		}
+
+		session.mtx.Lock()
+		session.control.Close()
+		session.mtx.Unlock()
+
+		n++
+	}
+
+	return n
+}
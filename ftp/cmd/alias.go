@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Aliases map custom SITE names to another SITE command with preset
+// parameters, i.e. REQ to REQUEST ADD. The keys are upper cased names
+type Aliases map[string][]string
+
+// NewAlias takes a line of text (i.e. from a config file) in the form of
+// `<name> <command> [<params>...]` and returns the upper cased name and the
+// parameters it is replaced with. Fails if the name is a built in SITE
+// command
+func NewAlias(line string) (string, []string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", nil, errors.New("alias requires a name and a command")
+	}
+
+	name := strings.ToUpper(fields[0])
+
+	if _, ok := SiteCommandMap[name]; ok {
+		return "", nil, errors.Errorf("'%s' is a built in command", name)
+	}
+
+	return name, fields[1:], nil
+}
+
+// Validate makes sure no alias points at another, aliases are only resolved
+// once
+func (a Aliases) Validate() error {
+	for name, params := range a {
+		if _, ok := a[strings.ToUpper(params[0])]; ok {
+			return errors.Errorf("alias '%s' points at another alias", name)
+		}
+	}
+
+	return nil
+}
+
+// Resolve replaces the name of an alias at the start of the SITE parameters
+// with its command and preset parameters, any other parameters follow them
+func (a Aliases) Resolve(params []string) []string {
+	if len(params) == 0 {
+		return params
+	}
+
+	preset, ok := a[strings.ToUpper(params[0])]
+	if !ok {
+		return params
+	}
+
+	resolved := make([]string, 0, len(preset)+len(params)-1)
+	resolved = append(resolved, preset...)
+	resolved = append(resolved, params[1:]...)
+
+	return resolved
+}
+
+// ResolveLine resolves the alias used by a SITE command in the fields sent
+// by the client, any other command is returned as it is. The server
+// resolves aliases before a command runs so hooks, the audit log and
+// traces see the command an alias points at
+func (a Aliases) ResolveLine(fields []string) []string {
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "SITE") {
+		return fields
+	}
+
+	return append(fields[:1:1], a.Resolve(fields[1:])...)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+
+	"github.com/goftpd/goftpd/stats"
 )
 
 /*
@@ -37,6 +39,10 @@ func (c commandAPPE) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
+	if ok, err := canTransfer(s, user, stats.Upload); !ok {
+		return err
+	}
+
 	if s.DataProtected() {
 		if err := s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for upload using TLS/SSL."); err != nil {
 			return err
@@ -51,14 +57,21 @@ func (c commandAPPE) Execute(ctx context.Context, s Session, params []string) er
 
 	writer, err := s.FS().ResumeUploadFile(path, user)
 	if err != nil {
-		return s.ReplyError(StatusActionNotOK, err)
+		return s.ReplyError(uploadStatus(err), err)
 	}
 
 	n, err := io.Copy(writer, s.Data())
 	if err != nil {
-		return s.ReplyError(StatusActionNotOK, err)
+		writer.Close()
+		return s.ReplyError(uploadStatus(err), err)
 	}
 
+	if err := writer.Close(); err != nil {
+		return s.ReplyError(uploadStatus(err), err)
+	}
+
+	recordTransfer(s, user, path, stats.Upload, n)
+
 	s.ClearData()
 
 	return s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes.", n))
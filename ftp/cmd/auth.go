@@ -59,6 +59,11 @@ func (c commandAUTH) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusSyntaxError)
 	}
 
+	// the control connection is already using TLS
+	if s.State() != SessionStateNull {
+		return s.ReplyWithMessage(StatusBadCommandSequence, "TLS already negotiated.")
+	}
+
 	if strings.ToUpper(params[0]) != "TLS" {
 		return s.ReplyWithMessage(
 			StatusParameterNotImplemented,
@@ -2,10 +2,23 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"io"
+	"net"
 
 	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/stats"
 	"github.com/goftpd/goftpd/vfs"
 )
 
@@ -60,15 +73,47 @@ type Session interface {
 	ReplyWithArgs(Status, ...interface{}) error
 	ReplyError(Status, error) error
 	ReplyStatus(Status) error
+	ReplyPartial(Status, string) error
 
 	// TLS
 	Upgrade() error
+	// the state of the control connection's TLS, false without TLS
+	TLSState() (tls.ConnectionState, bool)
+	// connected to an implicit TLS listener, data connections have to be
+	// protected too
+	ImplicitTLS() bool
+
+	// connection
+	RemoteAddr() net.Addr
+	SiteName() string
+	LoginMessage() string
+
+	// logs with the session's id, ip and login on every line
+	Logger() logging.Logger
 
 	Close() error
 
+	// flushes the login and state of the session back to before USER,
+	// the control connection and its TLS are kept
+	Reinitialize()
+
+	// records a failure such as a bad login from the session's ip, true
+	// once the ip has been banned for too many
+	Violation(string) bool
+
 	// filesystem
 	FS() vfs.VFS
 	Auth() acl.Authenticator
+	Stats() stats.Stats
+	Dupe() dupe.Dupe
+	Dirlog() dirlog.Dirlog
+	Inbox() inbox.Inbox
+	Nukes() nuke.Nukes
+	Pre() pre.Pre
+	Lua() script.Engine
+	Race() race.Race
+	Audit() audit.Log
+	Bans() ban.Bans
 
 	// data
 	Data() DataConn
@@ -92,13 +137,44 @@ type Session interface {
 	SetRenameFrom([]string)
 	RenameFrom() []string
 
+	SetXDupe(int)
+	XDupe() int
+
 	SetCWD(string)
 	CWD() string
 
+	// state stashed by commands, hooks and plugins, see Values
+	Values() *Values
+
 	SetLogin(string)
 	Login() string
+	Ident() string
+
+	// anonymous
+	AnonymousEnabled() bool
+	Anonymous() bool
+	LoginAnonymous() bool
 
 	User() (*acl.User, bool)
+	Template(string) (*acl.Template, bool)
+	CommandAllowed(string, *acl.User) bool
+	// custom SITE names resolved before SITE commands are run
+	Aliases() Aliases
+
+	// every session connected to the server
+	Sessions() []SessionInfo
+	// version, uptime and totals of the server
+	ServerInfo() ServerInfo
+	// disconnects every other session the func returns true for
+	Kick(func(SessionInfo) bool) int
+	// turns logging the control connection on or off for every session
+	// the func returns true for
+	Trace(func(SessionInfo) bool, bool) int
+
+	// maintenance
+	CloseSite(string)
+	ReopenSite() bool
+	SiteClosed() (string, bool)
 
 	LastCommand() string
 }
@@ -109,4 +185,14 @@ type Command interface {
 }
 
 var CommandMap = map[string]Command{}
+
+// remoteIP returns the ip of the session's remote address
+func remoteIP(s Session) string {
+	host, _, err := net.SplitHostPort(s.RemoteAddr().String())
+	if err != nil {
+		return s.RemoteAddr().String()
+	}
+	return host
+}
+
 var featSlice = []string{}
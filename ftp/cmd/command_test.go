@@ -0,0 +1,65 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestCommands(t *testing.T) {
+	var tests = []struct {
+		name  string
+		steps []ftptest.Step
+	}{
+		{
+			"pwd",
+			[]ftptest.Step{
+				{Send: "PWD", Expect: 257},
+				{Send: "PWD /", Expect: 501},
+			},
+		},
+		{
+			"cwd",
+			[]ftptest.Step{
+				{Send: "CWD /", Expect: 250},
+				{Send: "CWD", Expect: 501},
+			},
+		},
+		{
+			"type",
+			[]ftptest.Step{
+				{Send: "TYPE I", Expect: 200},
+				{Send: "TYPE A", Expect: 200},
+				{Send: "TYPE X", Expect: 501},
+			},
+		},
+		{
+			"mode",
+			[]ftptest.Step{
+				{Send: "MODE S", Expect: 200},
+				{Send: "MODE Z", Expect: 504},
+				{Send: "MODE", Expect: 501},
+			},
+		},
+		{
+			"rein",
+			[]ftptest.Step{
+				{Send: "REIN", Expect: 220},
+				{Send: "NOOP", Expect: 530},
+				{Send: "REIN now", Expect: 501},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), tt.steps...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
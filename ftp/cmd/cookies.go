@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/cookie"
+)
+
+// sessionCookies returns the cookies that can be used in messages shown to
+// the User, i.e. the login message as `%[tagline]`. Along with the User's
+// own cookies are the site's name, the section and free space of the
+// current directory and the server's totals and speeds
+func sessionCookies(s Session, u *acl.User) cookie.Cookies {
+	info := s.ServerInfo()
+	cwd := s.CWD()
+
+	c := cookie.User(u).Merge(cookie.Cookies{
+		"sitename":            s.SiteName(),
+		"cwd":                 cwd,
+		"section":             s.Stats().Section(cwd),
+		"version":             info.Version,
+		"online":              strconv.Itoa(len(s.Sessions())),
+		"connections":         strconv.FormatInt(info.Connections, 10),
+		"site_uploads":        strconv.FormatInt(info.Uploads, 10),
+		"site_upload_bytes":   cookie.FormatBytes(info.UploadBytes),
+		"site_upload_speed":   cookie.FormatSpeed(info.UploadSpeed),
+		"site_downloads":      strconv.FormatInt(info.Downloads, 10),
+		"site_download_bytes": cookie.FormatBytes(info.DownloadBytes),
+		"site_download_speed": cookie.FormatSpeed(info.DownloadSpeed),
+	})
+
+	if free, err := s.FS().FreeSpace(cwd); err == nil {
+		c["free"] = cookie.FormatBytes(int64(free))
+	}
+
+	return c
+}
@@ -3,6 +3,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
 )
 
 /*
@@ -14,8 +19,17 @@ import (
       parameters are similarly unchanged.  The argument is a
       pathname specifying a directory or other system dependent
       file group designator.
+
+   A .message file in the directory is shown before the reply with its
+   cookies, such as %[user] and %[free], expanded.
 */
 
+// messageFile is shown to users entering the directory holding it
+const messageFile = ".message"
+
+// maxMessageSize is the most of a messageFile that is shown
+const maxMessageSize = 16 * 1024
+
 type commandCWD struct{}
 
 func (c commandCWD) RequireState() SessionState { return SessionStateLoggedIn }
@@ -40,7 +54,32 @@ func (c commandCWD) Execute(ctx context.Context, s Session, params []string) err
 
 	s.SetCWD(path)
 
-	return s.ReplyWithMessage(StatusFileActionOK, fmt.Sprintf(`Current Working Dir "%s"`, path))
+	reply := fmt.Sprintf(`Current Working Dir "%s"`, path)
+
+	if message := readMessageFile(s, path, user); len(message) > 0 {
+		reply = message + "\n" + reply
+	}
+
+	return s.ReplyWithMessage(StatusFileActionOK, reply)
+}
+
+// readMessageFile returns the expanded messageFile of the directory, empty
+// if there isn't one or the User can't read it
+func readMessageFile(s Session, dir string, user *acl.User) string {
+	f, err := s.FS().DownloadFile(s.FS().Join(dir, []string{messageFile}), user)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxMessageSize))
+	if err != nil {
+		return ""
+	}
+
+	text := strings.TrimRight(strings.Replace(string(b), "\r", "", -1), "\n")
+
+	return sessionCookies(s, user).Expand(text)
 }
 
 func init() {
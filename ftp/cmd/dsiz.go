@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+   DSIZ <path>
+
+      Replies with the size in bytes of every file under the directory.
+      Sizes are cached so large trees aren't walked on every request.
+*/
+
+type commandDSIZ struct{}
+
+func (c commandDSIZ) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandDSIZ) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	path := s.CWD()
+	if len(params) > 0 {
+		path = s.FS().Join(s.CWD(), params)
+	}
+
+	size, err := s.FS().DirSize(path, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusFileStatus, fmt.Sprintf("%d", size))
+}
+
+func init() {
+	CommandMap["DSIZ"] = &commandDSIZ{}
+	featSlice = append(featSlice, "DSIZ")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// checkDupe checks the dupe rules for path. An error is returned if the
+// upload should be rejected, otherwise a warning to include in the reply
+// or an empty string
+func checkDupe(s Session, path string) (string, error) {
+	action, e, err := s.Dupe().Check(path)
+	if err != nil {
+		// don't block uploads if the dupe db is broken
+		s.Logger().Error("unable to check dupe", logging.Path(path), logging.Err(err))
+		return "", nil
+	}
+
+	switch action {
+	case dupe.ActionReject:
+		return "", errors.Errorf("dupe of %s", e)
+	case dupe.ActionWarn:
+		return fmt.Sprintf("Warning: dupe of %s.", e), nil
+	}
+
+	return "", nil
+}
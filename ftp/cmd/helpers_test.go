@@ -0,0 +1,56 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+// newLoggedInSession returns a session using TLS logged in as someone, who
+// can download anything unless opts has its own FS. update changes someone
+// before they login
+func newLoggedInSession(t *testing.T, opts *ftptest.SessionOpts, update func(*acl.User) error) (*ftptest.Session, func()) {
+	t.Helper()
+
+	if opts.FS == nil {
+		fs, err := ftptest.NewMemoryFS("download /** *", "upload /** *")
+		if err != nil {
+			t.Fatalf("unexpected error creating fs: %s", err)
+		}
+		opts.FS = fs
+	}
+
+	auth, err := ftptest.NewMemoryAuthenticator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating authenticator: %s", err)
+	}
+
+	if _, err := auth.AddUser("someone", "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	if update != nil {
+		if err := auth.UpdateUser("someone", update); err != nil {
+			t.Fatalf("unexpected error updating user: %s", err)
+		}
+	}
+
+	opts.Auth = auth
+	opts.TLS = true
+
+	s, err := ftptest.NewSession(opts)
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	if err := s.LoginAs("someone"); err != nil {
+		t.Fatalf("unexpected error logging in: %s", err)
+	}
+
+	return s, func() {
+		if err := auth.Close(); err != nil {
+			t.Fatalf("unexpected error closing authenticator: %s", err)
+		}
+	}
+}
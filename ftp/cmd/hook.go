@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"time"
+)
+
+// HookCommand is the command a Hook is run around
+type HookCommand struct {
+	// upper cased, site commands include the name of the site command
+	// i.e. SITE WHO
+	Name string
+	// the parameters as sent by the client, the parameter of PASS is the
+	// password so shouldn't be logged
+	Params []string
+}
+
+// HookResult is how a command finished
+type HookResult struct {
+	// the code of the last reply sent to the client, 0 if none was sent
+	Code int
+	// returned by the command, most commands reply with their failures
+	// and return nil
+	Err      error
+	Duration time.Duration
+}
+
+// Hook is run around every command the session runs once the command has
+// been found and the session is in the state it requires. Before can
+// refuse the command by returning an error, which is sent to the client,
+// After is only run for commands that weren't refused. Hooks are run on
+// the session's goroutine so shouldn't block
+type Hook interface {
+	Before(context.Context, Session, HookCommand) error
+	After(context.Context, Session, HookCommand, HookResult)
+}
+
+// HookFuncs implements Hook with funcs, either can be nil
+type HookFuncs struct {
+	BeforeFunc func(context.Context, Session, HookCommand) error
+	AfterFunc  func(context.Context, Session, HookCommand, HookResult)
+}
+
+func (h HookFuncs) Before(ctx context.Context, s Session, c HookCommand) error {
+	if h.BeforeFunc == nil {
+		return nil
+	}
+	return h.BeforeFunc(ctx, s, c)
+}
+
+func (h HookFuncs) After(ctx context.Context, s Session, c HookCommand, r HookResult) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, s, c, r)
+	}
+}
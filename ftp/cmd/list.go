@@ -27,10 +27,6 @@ type commandLIST struct{}
 func (c commandLIST) RequireState() SessionState { return SessionStateLoggedIn }
 
 func (c commandLIST) Execute(ctx context.Context, s Session, params []string) error {
-	if len(params) == 0 {
-		params = append(params, s.CWD())
-	}
-
 	if s.Data() == nil {
 		return s.ReplyStatus(StatusCantOpenDataConnection)
 	}
@@ -40,32 +36,12 @@ func (c commandLIST) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
-	var options, path string
-
-	// check if we have options and set the path eitherway
-	// check if we have options and set the path eitherway
-	if len(params[0]) > 0 && params[0][0] == '-' {
-		options = params[0]
-		if len(params) > 1 {
-			params = params[1:]
-		} else {
-			params = []string{}
-		}
-	}
-
-	path = s.FS().Join(s.CWD(), params)
-
 	// get file list and parse with any options
-	finfo, err := s.FS().ListDir(path, user)
+	_, finfo, err := listDir(s, user, params)
 	if err != nil {
 		return s.ReplyError(StatusActionAbortedError, err)
 	}
 
-	if len(options) > 0 {
-		// handle options
-		finfo.SortByName()
-	}
-
 	if s.DataProtected() {
 		if err := s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for directory listing using TLS/SSL."); err != nil {
 			return err
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// listOptions are the ls style flags supported by LIST, NLST and STAT
+type listOptions struct {
+	sorted  bool
+	byTime  bool
+	bySize  bool
+	reverse bool
+}
+
+// parseListOptions takes any leading params starting with `-` as flags and
+// returns the remaining params. Unknown flags (i.e. -l and -a) are accepted
+// and just sort by name
+func parseListOptions(params []string) (listOptions, []string) {
+	var opts listOptions
+
+	for len(params) > 0 && len(params[0]) > 1 && params[0][0] == '-' {
+		opts.sorted = true
+
+		for _, f := range params[0][1:] {
+			switch f {
+			case 't':
+				opts.byTime = true
+			case 'S':
+				opts.bySize = true
+			case 'r':
+				opts.reverse = true
+			}
+		}
+
+		params = params[1:]
+	}
+
+	return opts, params
+}
+
+// listDir lists the directory given by params for LIST, NLST and STAT. If the
+// last part of the path is a glob (i.e. `*.sfv`) its parent is listed and only
+// matching names are returned. Returns the path that was listed
+func listDir(s Session, user *acl.User, params []string) (string, vfs.FileList, error) {
+	opts, params := parseListOptions(params)
+
+	if len(params) == 0 {
+		params = []string{s.CWD()}
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	var g glob.Glob
+
+	if base := filepath.Base(path); strings.ContainsAny(base, "*?[") {
+		var err error
+
+		g, err = glob.Compile(base)
+		if err != nil {
+			return path, nil, err
+		}
+
+		path = filepath.Dir(path)
+	}
+
+	finfo, err := s.FS().ListDir(path, user)
+	if err != nil {
+		return path, nil, err
+	}
+
+	if g != nil {
+		finfo = finfo.Filter(g)
+	}
+
+	switch {
+	case opts.byTime:
+		finfo.SortByModTime()
+	case opts.bySize:
+		finfo.SortBySize()
+	case opts.sorted:
+		finfo.SortByName()
+	}
+
+	if opts.reverse {
+		finfo.Reverse()
+	}
+
+	return path, finfo, nil
+}
@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -29,10 +33,22 @@ func (c commandMKD) Execute(ctx context.Context, s Session, params []string) err
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
+	warning, err := checkDupe(s, path)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
 	if err := s.FS().MakeDir(path, user); err != nil {
+		if errors.Is(err, vfs.ErrFilenameNotAllowed) {
+			return s.ReplyError(StatusBadFilename, err)
+		}
 		return s.ReplyError(StatusActionNotOK, err)
 	}
 
+	if len(warning) > 0 {
+		return s.ReplyWithMessage(StatusPathCreated, fmt.Sprintf(`"%s" created. %s`, path, warning))
+	}
+
 	return s.ReplyWithArgs(StatusPathCreated, path)
 }
 
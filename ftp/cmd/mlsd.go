@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+   MLSD (RFC 3659)
+
+      The MLSD command is intended to transfer a listing of the
+      contents of a directory over the data connection, using the
+      same facts as MLST.
+*/
+
+type commandMLSD struct{}
+
+func (c commandMLSD) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMLSD) Execute(ctx context.Context, s Session, params []string) error {
+	if s.Data() == nil {
+		return s.ReplyStatus(StatusCantOpenDataConnection)
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	finfo, err := s.FS().ListDir(path, user)
+	if err != nil {
+		return s.ReplyError(StatusActionAbortedError, err)
+	}
+
+	if s.DataProtected() {
+		if err := s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for directory listing using TLS/SSL."); err != nil {
+			return err
+		}
+	} else {
+		if err := s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for directory listing."); err != nil {
+			return err
+		}
+	}
+	defer s.Data().Close()
+	defer s.ClearData()
+
+	n, err := s.Data().Write(finfo.Machine())
+	if err != nil {
+		return s.ReplyError(StatusActionAbortedError, err)
+	}
+
+	s.Data().Close()
+
+	return s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("Closing data connection, sent %d bytes", n))
+}
+
+func init() {
+	CommandMap["MLSD"] = &commandMLSD{}
+}
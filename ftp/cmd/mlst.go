@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+   MLST (RFC 3659)
+
+      The MLST command is intended to transfer information about a
+      single file system object over the control connection. The
+      facts returned include any checksums recorded when the file was
+      uploaded as X.CRC32 and X.SHA256.
+*/
+
+type commandMLST struct{}
+
+func (c commandMLST) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandMLST) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	finfo, err := s.FS().StatFile(path, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusFileActionOK, fmt.Sprintf("Listing %s\n %s %s\n", path, finfo.Facts(), path))
+}
+
+func init() {
+	CommandMap["MLST"] = &commandMLST{}
+	featSlice = append(featSlice, "MLST type*;size*;modify*;UNIX.owner*;UNIX.group*;X.CRC32*;X.SHA256*;")
+}
@@ -3,6 +3,12 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/logging"
 )
 
 /*
@@ -19,6 +25,10 @@ import (
       the sensitive password information.
 */
 
+// defaultLoginMessage is shown after login when the server has no
+// login_message
+const defaultLoginMessage = "Welcome back %[user]!"
+
 type commandPASS struct{}
 
 func (c commandPASS) Feat() string               { return "PASS" }
@@ -34,12 +44,136 @@ func (c commandPASS) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusBadCommandSequence)
 	}
 
-	if !s.Auth().CheckPassword(s.Login(), params[0]) {
+	if s.AnonymousEnabled() && acl.IsAnonymousLogin(s.Login()) {
+		if reason, closed := s.SiteClosed(); closed {
+			s.SetLogin("")
+			return s.ReplyWithMessage(StatusNotLoggedIn, reason)
+		}
+
+		return c.anonymous(s)
+	}
+
+	if s.Auth().Locked(s.Login(), remoteIP(s)) {
+		s.Logger().Info("login denied", logging.F("ident", s.Ident()), logging.F("reason", "locked out"))
 		s.SetLogin("")
-		return s.ReplyStatus(StatusNotLoggedIn)
+
+		if s.Violation("login while locked out") {
+			return ReplyBanned(s)
+		}
+
+		return s.ReplyStatus(StatusLoginLocked)
+	}
+
+	pass := params[0]
+
+	var code string
+
+	// check the user is allowed to login from this address before
+	// checking the password, reply the same as a bad login so as
+	// not to leak any information
+	if user, err := s.Auth().GetUser(s.Login()); err == nil {
+		if user.Deleted() {
+			s.Logger().Info("login denied", logging.F("ident", s.Ident()), logging.F("reason", "user is deleted"))
+			return c.fail(ctx, s)
+		}
+
+		// only looked up when the user has a hostname mask
+		hosts := func() []string {
+			return acl.LookupHosts(ctx, net.DefaultResolver, remoteIP(s))
+		}
+
+		if !user.MatchIP(s.Ident(), remoteIP(s), hosts) {
+			s.Logger().Info("login denied", logging.F("ident", s.Ident()), logging.F("reason", "no matching ip mask"))
+			return c.fail(ctx, s)
+		}
+
+		// users with totp append the code to their password
+		if user.HasFlag(acl.FlagTOTP) {
+			if len(pass) < acl.TOTPDigits {
+				return c.fail(ctx, s)
+			}
+
+			code = pass[len(pass)-acl.TOTPDigits:]
+			pass = pass[:len(pass)-acl.TOTPDigits]
+		}
+	}
+
+	if !s.Auth().CheckPassword(s.Login(), pass) {
+		return c.fail(ctx, s)
 	}
 
-	if err := s.ReplyWithArgs(StatusUserLoggedIn, fmt.Sprintf("Welcome back %s!", s.Login())); err != nil {
+	if len(code) > 0 && !s.Auth().CheckTOTP(s.Login(), code) {
+		return c.fail(ctx, s)
+	}
+
+	if err := s.Auth().ClearFailedLogins(s.Login()); err != nil {
+		s.Logger().Error("unable to clear failed logins", logging.Err(err))
+	}
+
+	// only tell the user their account has expired once we know
+	// the password is correct
+	user, ok := s.User()
+	if !ok {
+		return c.fail(ctx, s)
+	}
+
+	if user.Expired() {
+		s.SetLogin("")
+		return s.ReplyStatus(StatusAccountExpired)
+	}
+
+	if reason, closed := s.SiteClosed(); closed && !s.CommandAllowed("closed", user) {
+		s.Logger().Info("login denied", logging.F("ident", s.Ident()), logging.F("reason", "site is closed"))
+		s.SetLogin("")
+		return s.ReplyWithMessage(StatusNotLoggedIn, sessionCookies(s, user).Expand(reason))
+	}
+
+	if user.MaxLogins > 0 && userSessions(s, user) >= user.MaxLogins {
+		s.Logger().Info("login denied", logging.F("ident", s.Ident()), logging.F("reason", "too many sessions"))
+		s.SetLogin("")
+		return s.ReplyStatus(StatusTooManySessions)
+	}
+
+	s.SetCWD(user.Home())
+
+	if err := s.Auth().RecordLogin(s.Login(), s.Ident(), remoteIP(s)); err != nil {
+		s.Logger().Error("unable to record login", logging.Err(err))
+	}
+
+	welcome := s.LoginMessage()
+	if len(welcome) == 0 {
+		welcome = defaultLoginMessage
+	}
+
+	message := fmt.Sprintf(StatusUserLoggedIn.Message, sessionCookies(s, user).Expand(welcome))
+
+	unread, err := s.Inbox().Unread(user.Name)
+	if err != nil {
+		s.Logger().Error("unable to count unread messages", logging.Err(err))
+	}
+
+	if unread > 0 {
+		message = fmt.Sprintf("You have %d unread message(s), see SITE MSG.\n%s", unread, message)
+	}
+
+	if err := s.ReplyWithMessage(StatusUserLoggedIn, message); err != nil {
+		s.SetLogin("")
+		return err
+	}
+
+	s.SetState(SessionStateLoggedIn)
+
+	return nil
+}
+
+// anonymous logs the session in anonymously, any password is accepted
+func (c commandPASS) anonymous(s Session) error {
+	if !s.LoginAnonymous() {
+		s.SetLogin("")
+		return s.ReplyWithMessage(StatusNotLoggedIn, "Too many anonymous users, try again later.")
+	}
+
+	if err := s.ReplyWithMessage(StatusUserLoggedIn, "Anonymous access granted, restrictions apply."); err != nil {
 		s.SetLogin("")
 		return err
 	}
@@ -49,6 +183,47 @@ func (c commandPASS) Execute(ctx context.Context, s Session, params []string) er
 	return nil
 }
 
+// fail records the failed login and tarpits the client before replying
+func (c commandPASS) fail(ctx context.Context, s Session) error {
+	delay, err := s.Auth().FailedLogin(s.Login(), remoteIP(s))
+	if err != nil {
+		s.Logger().Error("unable to record failed login", logging.Err(err))
+	}
+
+	s.SetLogin("")
+
+	if s.Violation("failed login") {
+		return ReplyBanned(s)
+	}
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return s.ReplyStatus(StatusNotLoggedIn)
+}
+
+// userSessions counts the logged in sessions of the user, the session
+// logging in isn't counted as it isn't logged in yet
+func userSessions(s Session, user *acl.User) int {
+	var n int
+
+	for _, info := range s.Sessions() {
+		if info.State == SessionStateLoggedIn && !info.Anonymous && strings.EqualFold(info.Login, user.Name) {
+			n++
+		}
+	}
+
+	return n
+}
+
 func init() {
 	CommandMap["PASS"] = &commandPASS{}
 }
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestPassIdent(t *testing.T) {
+	var tests = []struct {
+		mask   string
+		ident  string
+		expect int
+	}{
+		{"bob@127.0.0.1", "bob", 230},
+		{"bob@127.0.0.1", "eve", 530},
+		// ident is off or the client doesn't run identd
+		{"bob@127.0.0.1", "", 530},
+		{"*@127.0.0.1", "eve", 230},
+		{"*@127.0.0.1", "", 230},
+		{"bob@10.0.0.1", "bob", 530},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mask+" "+tt.ident, func(t *testing.T) {
+			fs, err := ftptest.NewMemoryFS()
+			if err != nil {
+				t.Fatalf("unexpected error creating fs: %s", err)
+			}
+
+			st, err := ftptest.NewMemoryStats()
+			if err != nil {
+				t.Fatalf("unexpected error creating stats: %s", err)
+			}
+			defer st.Close()
+
+			messages, err := ftptest.NewMemoryInbox()
+			if err != nil {
+				t.Fatalf("unexpected error creating inbox: %s", err)
+			}
+			defer messages.Close()
+
+			auth, err := ftptest.NewMemoryAuthenticator(nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating authenticator: %s", err)
+			}
+			defer auth.Close()
+
+			if _, err := auth.AddUser("someone", "password"); err != nil {
+				t.Fatalf("unexpected error adding user: %s", err)
+			}
+
+			err = auth.UpdateUser("someone", func(u *acl.User) error {
+				_, err := u.AddIP(tt.mask)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("unexpected error updating user: %s", err)
+			}
+
+			s, err := ftptest.NewSession(&ftptest.SessionOpts{
+				FS:    fs,
+				Auth:  auth,
+				Stats: st,
+				Inbox: messages,
+				TLS:   true,
+				Ident: tt.ident,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error creating session: %s", err)
+			}
+
+			err = s.Converse(context.Background(),
+				ftptest.Step{Send: "USER someone", Expect: 331},
+				ftptest.Step{Send: "PASS password", Expect: tt.expect},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -78,6 +78,10 @@ func (c commandPROT) Execute(ctx context.Context, s Session, params []string) er
 		s.SetDataProtected(true)
 
 	case "C":
+		// implicit TLS clients never expect plaintext
+		if s.ImplicitTLS() {
+			return s.ReplyWithArgs(StatusBadProtectionLevel, params[0])
+		}
 		s.SetDataProtected(false)
 
 	case "S":
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Register adds an FTP command, the name is upper cased. Commands compiled
+// in to the server or loaded from plugins register themselves this way,
+// it has to happen before the server starts. Fails if the command exists
+func Register(name string, c Command) error {
+	return register(CommandMap, "", name, c)
+}
+
+// RegisterSite adds a SITE command, the name is upper cased. Like the
+// built in SITE commands it should check its own site permission with
+// Session.CommandAllowed. Fails if the command exists
+func RegisterSite(name string, c Command) error {
+	return register(SiteCommandMap, "SITE ", name, c)
+}
+
+// RegisterFeat adds a line to the reply to FEAT, it has to be called
+// before the first FEAT is answered
+func RegisterFeat(feat string) {
+	featSlice = append(featSlice, feat)
+}
+
+func register(m map[string]Command, prefix, name string, c Command) error {
+	name = strings.ToUpper(name)
+
+	if len(name) == 0 || strings.ContainsAny(name, " \t") {
+		return errors.Errorf("bad command name '%s%s'", prefix, name)
+	}
+
+	if c == nil {
+		return errors.Errorf("%s%s has no command", prefix, name)
+	}
+
+	if _, ok := m[name]; ok {
+		return errors.Errorf("%s%s is already registered", prefix, name)
+	}
+
+	m[name] = c
+
+	return nil
+}
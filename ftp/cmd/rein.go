@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+)
+
+/*
+   REINITIALIZE (REIN)
+
+      This command terminates a USER, flushing all I/O and account
+      information, except to allow any transfer in progress to be
+      completed.  All parameters are reset to the default settings
+      and the control connection is left open.  This is identical
+      to the state in which a user finds himself immediately after
+      the control connection is opened.  A USER command may be
+      expected to follow.
+*/
+
+type commandREIN struct{}
+
+func (c commandREIN) RequireState() SessionState { return SessionStateAuth }
+
+func (c commandREIN) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 0 {
+		return s.ReplyStatus(StatusSyntaxError)
+	}
+
+	s.Reinitialize()
+
+	return s.ReplyStatus(StatusServiceReady)
+}
+
+func init() {
+	CommandMap["REIN"] = &commandREIN{}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+
+	"github.com/goftpd/goftpd/stats"
 )
 
 /*
@@ -36,10 +38,24 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
+	if ok, err := canTransfer(s, user, stats.Download); !ok {
+		return err
+	}
+
 	reader, err := s.FS().DownloadFile(path, user)
 	if err != nil {
 		return s.ReplyError(StatusActionNotOK, err)
 	}
+	defer reader.Close()
+
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if ok, err := canAfford(s, user, path, size-int64(s.RestartPosition())); !ok {
+		return err
+	}
 
 	if s.DataProtected() {
 		if err := s.ReplyWithMessage(StatusTransferStatusOK, "Opening connection for download using TLS/SSL."); err != nil {
@@ -57,10 +73,8 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 	defer s.SetRestartPosition(0)
 
 	// seek reader
-	if s.RestartPosition() > 0 {
-		if _, err := reader.Seek(int64(s.RestartPosition()), io.SeekStart); err != nil {
-			return s.ReplyError(StatusActionNotOK, err)
-		}
+	if _, err := reader.Seek(int64(s.RestartPosition()), io.SeekStart); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
 	}
 
 	n, err := io.Copy(s.Data(), reader)
@@ -68,6 +82,8 @@ func (c commandRETR) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyError(StatusActionNotOK, err)
 	}
 
+	recordTransfer(s, user, path, stats.Download, n)
+
 	s.Data().Close()
 
 	return s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes.", n))
@@ -37,7 +37,7 @@ func (c commandRNTO) Execute(ctx context.Context, s Session, params []string) er
 	newpath := s.FS().Join(s.CWD(), params)
 
 	if err := s.FS().RenameFile(oldpath, newpath, user); err != nil {
-		return s.ReplyError(StatusActionNotOK, err)
+		return s.ReplyError(uploadStatus(err), err)
 	}
 
 	return s.ReplyStatus(StatusFileActionOK)
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/script"
+	"github.com/pkg/errors"
+)
+
+/*
+   SITE PARAMETERS (SITE)
+
+      This command is used by the server to provide services
+      specific to his system that are essential to file transfer
+      but not sufficiently universal to be included as commands in
+      the protocol.  The nature of these services and the
+      specification of their syntax can be stated in a reply to
+      the HELP SITE command.
+
+   SITE commands not built in are looked up in those registered by Lua
+   scripts, see the script namespace. Aliases from the alias namespace are
+   resolved by the server before the command runs, see Aliases.ResolveLine,
+   permissions are those of the command they point at.
+*/
+
+type commandSITE struct{}
+
+func (c commandSITE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandSITE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyStatus(StatusSyntaxError)
+	}
+
+	name := strings.ToUpper(params[0])
+
+	sc, ok := SiteCommandMap[name]
+	if !ok {
+		return c.lua(s, name, params[1:])
+	}
+
+	if s.State() < sc.RequireState() {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	return sc.Execute(ctx, s, params[1:])
+}
+
+// lua runs a SITE command registered by a Lua script, they require the
+// site permission of their lower cased name
+func (c commandSITE) lua(s Session, name string, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	env := script.NewServerEnv(s.Auth(), s.FS())
+
+	// check the command exists before the permission so unknown commands
+	// are reported the same to everyone
+	found := false
+	for _, n := range s.Lua().Commands() {
+		if n == name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return s.ReplyWithMessage(StatusNotImplemented, fmt.Sprintf("SITE %s not implemented.", name))
+	}
+
+	if !s.CommandAllowed(strings.ToLower(name), user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	reply, _, err := s.Lua().Site(name, env, user, params)
+	if err != nil {
+		s.Logger().Warn("lua command failed", logging.Command("SITE "+name), logging.Err(err))
+		return s.ReplyError(StatusActionNotOK, errors.New("script failed"))
+	}
+
+	if len(reply) == 0 {
+		return s.ReplyStatus(StatusOK)
+	}
+
+	return s.ReplyWithMessage(StatusOK, reply)
+}
+
+// SiteCommandMap holds the sub commands of SITE, the key is the upper cased
+// name of the sub command
+var SiteCommandMap = map[string]Command{}
+
+func init() {
+	CommandMap["SITE"] = &commandSITE{}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE ADDIP <user> <ident@ip> [<ident@ip> ...]
+
+	Adds ident@ip masks to a user, the ip can also be a hostname such as
+	*@*.example.com. Users can add masks to themselves within the mask
+	policy, the `auth max_ips` and `auth min_ip_octets` options.
+	Adding masks to anybody else requires the `addip` site permission and
+	skips the policy.
+
+	SITE DELIP <user> <ident@ip> [<ident@ip> ...]
+
+	Removes ident@ip masks from a user. Users can remove their own masks
+	apart from the last one, removing anybody else's requires the `delip`
+	site permission.
+*/
+
+type siteCommandADDIP struct{}
+
+func (c siteCommandADDIP) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandADDIP) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE ADDIP <user> <ident@ip> [<ident@ip> ...]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	u, self, err := ipTarget(s, user, params[0], "addip")
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	existing := len(u.IPs)
+
+	var b strings.Builder
+
+	for _, mask := range params[1:] {
+		if self {
+			if err := s.Auth().CheckMaskPolicy(mask, existing); err != nil {
+				fmt.Fprintf(&b, "Unable to add '%s': %s\n", mask, err)
+				continue
+			}
+		}
+
+		if err := s.Auth().AddIP(u.Name, mask); err != nil {
+			fmt.Fprintf(&b, "Unable to add '%s': %s\n", mask, err)
+			continue
+		}
+
+		existing++
+
+		fmt.Fprintf(&b, "Added '%s' to %s.\n", mask, u.Name)
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+type siteCommandDELIP struct{}
+
+func (c siteCommandDELIP) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandDELIP) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE DELIP <user> <ident@ip> [<ident@ip> ...]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	u, self, err := ipTarget(s, user, params[0], "delip")
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	remaining := len(u.IPs)
+
+	var b strings.Builder
+
+	for _, mask := range params[1:] {
+		// users can't lock themselves out
+		if self && remaining <= 1 {
+			fmt.Fprintf(&b, "Unable to remove '%s': can not remove your last mask\n", mask)
+			continue
+		}
+
+		if err := s.Auth().RemoveIP(u.Name, mask); err != nil {
+			fmt.Fprintf(&b, "Unable to remove '%s': %s\n", mask, err)
+			continue
+		}
+
+		remaining--
+
+		fmt.Fprintf(&b, "Removed '%s' from %s.\n", mask, u.Name)
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+// ipTarget returns the user whose masks are being changed and whether it
+// is the user themselves, anybody else needs the permission
+func ipTarget(s Session, user *acl.User, name, permission string) (*acl.User, bool, error) {
+	if s.Anonymous() {
+		return nil, false, acl.ErrPermissionDenied
+	}
+
+	self := strings.EqualFold(name, user.Name)
+
+	if !self && !s.CommandAllowed(permission, user) {
+		return nil, false, acl.ErrPermissionDenied
+	}
+
+	u, err := s.Auth().GetUser(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if u.Deleted() {
+		return nil, false, errors.New("user is deleted")
+	}
+
+	return u, self, nil
+}
+
+func init() {
+	SiteCommandMap["ADDIP"] = &siteCommandADDIP{}
+	SiteCommandMap["DELIP"] = &siteCommandDELIP{}
+}
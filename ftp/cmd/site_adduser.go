@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE ADDUSER <user> <password> [<ident@ip> ...]
+
+	Creates a user from the default template with any ident@ip masks
+	given. Requires the `adduser` site permission.
+
+	SITE GADDUSER <group> <user> <password> [<ident@ip> ...]
+
+	Creates a user as above and adds them to the group, using up one of
+	the group's gadmin slots. Requires the `gadduser` site permission and
+	being an admin of the group.
+
+	SITE DELUSER <user>
+
+	Deletes a user, they are kept until purged and can be readded. Requires
+	the `deluser` site permission, or the `gdeluser` site permission and
+	being an admin of the user's primary group. Group admins can't delete
+	staff, users who are group admins or have flags other than the built in
+	ones and those of the default template.
+*/
+
+type siteCommandADDUSER struct{}
+
+func (c siteCommandADDUSER) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandADDUSER) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE ADDUSER <user> <password> [<ident@ip> ...]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("adduser", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	u, err := addUser(s, params[0], params[1], params[2:])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Added '%s'.", u.Name))
+}
+
+type siteCommandGADDUSER struct{}
+
+func (c siteCommandGADDUSER) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandGADDUSER) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 3 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE GADDUSER <group> <user> <password> [<ident@ip> ...]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("gadduser", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	g, err := s.Auth().GetGroup(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	// checked up front so no user is created only to be purged
+	if !user.IsGroupAdmin(g.Name) {
+		return s.ReplyError(StatusActionNotOK, acl.ErrNotGroupAdmin)
+	}
+
+	u, err := addUser(s, params[1], params[2], params[3:])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if err := s.Auth().GadminAddUserToGroup(user.Name, u.Name, g.Name); err != nil {
+		s.Auth().PurgeUser(u.Name)
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if err := s.Auth().SetPrimaryGroup(u.Name, g.Name); err != nil {
+		s.Auth().PurgeUser(u.Name)
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Added '%s' to %s.", u.Name, g.Name))
+}
+
+// addUser creates the user from the default template
+func addUser(s Session, name, password string, ips []string) (*acl.User, error) {
+	t, ok := s.Template("")
+	if !ok {
+		return nil, errors.New("no default template")
+	}
+
+	return acl.AddUserFromTemplate(s.Auth(), t, name, password, ips)
+}
+
+type siteCommandDELUSER struct{}
+
+func (c siteCommandDELUSER) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandDELUSER) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE DELUSER <user>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	allowed := s.CommandAllowed("deluser", user)
+	gadmin := s.CommandAllowed("gdeluser", user)
+
+	if !allowed && !gadmin {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	u, err := s.Auth().GetUser(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if strings.EqualFold(u.Name, user.Name) {
+		return s.ReplyError(StatusActionNotOK, errors.New("can not delete yourself"))
+	}
+
+	if !allowed && !adminOf(s, user, u) {
+		return s.ReplyError(StatusActionNotOK, acl.ErrNotGroupAdmin)
+	}
+
+	if err := s.Auth().DeleteUser(u.Name); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Deleted '%s'.", u.Name))
+}
+
+// accountFlags are the built in flags describing a user's account rather
+// than granting them anything
+var accountFlags = []string{
+	acl.FlagLeech,
+	acl.FlagJail,
+	acl.FlagUploadOnly,
+	acl.FlagDownloadOnly,
+	acl.FlagTOTP,
+	acl.FlagExpired,
+	acl.FlagDeleted,
+}
+
+// adminOf checks to see if admin is a group admin of the user's primary
+// group and the user isn't staff. Staff are group admins themselves or have
+// flags other than the accountFlags and those of the default template, i.e.
+// siteop, so can't be deleted by the admin of a group they're a member of
+func adminOf(s Session, admin, user *acl.User) bool {
+	if len(user.PrimaryGroup) == 0 || !admin.IsGroupAdmin(user.PrimaryGroup) {
+		return false
+	}
+
+	for g := range user.Groups {
+		if user.IsGroupAdmin(g) {
+			return false
+		}
+	}
+
+	allowed := accountFlags
+	if t, ok := s.Template(""); ok {
+		allowed = append(allowed[:len(allowed):len(allowed)], t.Flags...)
+	}
+
+	for flag := range user.Flags {
+		if !hasFlag(allowed, flag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasFlag checks to see if the flag is one of flags, ignoring case
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	SiteCommandMap["ADDUSER"] = &siteCommandADDUSER{}
+	SiteCommandMap["GADDUSER"] = &siteCommandGADDUSER{}
+	SiteCommandMap["DELUSER"] = &siteCommandDELUSER{}
+}
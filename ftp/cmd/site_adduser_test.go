@@ -0,0 +1,227 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+// newGadminSession returns a session logged in as someone, the admin of
+// staff which has a single gadmin slot. someone is also a member of other
+// but not its admin
+func newGadminSession(t *testing.T, permissions ...string) (*ftptest.Session, func()) {
+	t.Helper()
+
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: permissions}, nil)
+
+	auth := s.Auth()
+
+	for _, name := range []string{"staff", "other"} {
+		if _, err := auth.AddGroup(name); err != nil {
+			t.Fatalf("unexpected error adding group: %s", err)
+		}
+
+		if err := auth.AddUserToGroup("someone", name); err != nil {
+			t.Fatalf("unexpected error adding to group: %s", err)
+		}
+	}
+
+	err := auth.UpdateGroup("staff", func(g *acl.Group) error {
+		g.GadminSlots = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating group: %s", err)
+	}
+
+	err = auth.UpdateUser("someone", func(u *acl.User) error {
+		u.Groups["staff"] = acl.GroupSettings{IsAdmin: true}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating user: %s", err)
+	}
+
+	return s, done
+}
+
+// addMember adds the user to the groups, the first being their primary
+// group, with the flags
+func addMember(t *testing.T, auth acl.Authenticator, name string, groups []string, flags ...string) {
+	t.Helper()
+
+	if _, err := auth.AddUser(name, "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	for _, g := range groups {
+		if err := auth.AddUserToGroup(name, g); err != nil {
+			t.Fatalf("unexpected error adding to group: %s", err)
+		}
+	}
+
+	if len(groups) > 0 {
+		if err := auth.SetPrimaryGroup(name, groups[0]); err != nil {
+			t.Fatalf("unexpected error setting primary group: %s", err)
+		}
+	}
+
+	for _, f := range flags {
+		if err := auth.AddFlag(name, f); err != nil {
+			t.Fatalf("unexpected error adding flag: %s", err)
+		}
+	}
+}
+
+func TestSiteAddUser(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, nil)
+	defer done()
+
+	err := s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE ADDUSER bob password", Expect: 530},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, done = newLoggedInSession(t, &ftptest.SessionOpts{Permissions: []string{"adduser *"}}, nil)
+	defer done()
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE ADDUSER bob", Expect: 501},
+		ftptest.Step{Send: "SITE ADDUSER bob password *@10.0.0.1", Expect: 200},
+		ftptest.Step{Send: "SITE ADDUSER bob password", Expect: 550},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Auth().GetUser("bob")
+	if err != nil {
+		t.Fatalf("unexpected error getting user: %s", err)
+	}
+
+	if !u.MatchIP("", "10.0.0.1", nil) {
+		t.Fatalf("expected bob to have the mask: %v", u.IPs)
+	}
+}
+
+func TestSiteGAddUser(t *testing.T) {
+	s, done := newGadminSession(t)
+	defer done()
+
+	err := s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE GADDUSER staff bob password", Expect: 530},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, done = newGadminSession(t, "gadduser *")
+	defer done()
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE GADDUSER staff bob", Expect: 501},
+		ftptest.Step{Send: "SITE GADDUSER missing bob password", Expect: 550},
+		ftptest.Step{Send: "SITE GADDUSER other bob password", Expect: 550},
+		ftptest.Step{Send: "SITE GADDUSER staff bob password", Expect: 200},
+		ftptest.Step{Send: "SITE GADDUSER staff carol password", Expect: 550},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Auth().GetUser("bob")
+	if err != nil {
+		t.Fatalf("unexpected error getting user: %s", err)
+	}
+
+	if u.PrimaryGroup != "staff" {
+		t.Fatalf("expected primary group staff got '%s'", u.PrimaryGroup)
+	}
+
+	g, err := s.Auth().GetGroup("staff")
+	if err != nil {
+		t.Fatalf("unexpected error getting group: %s", err)
+	}
+
+	if g.GadminSlots != 0 {
+		t.Fatalf("expected the slot to be used got %d", g.GadminSlots)
+	}
+
+	// refused for a lack of slots after being created
+	if _, err := s.Auth().GetUser("carol"); err != acl.ErrUserDoesntExist {
+		t.Fatalf("expected carol to be purged got %v", err)
+	}
+}
+
+func TestSiteDelUser(t *testing.T) {
+	var tests = []struct {
+		name        string
+		permissions []string
+		groups      []string
+		flags       []string
+		send        string
+		expect      int
+	}{
+		{"no permission", nil, []string{"staff"}, nil, "SITE DELUSER bob", 530},
+		{"syntax", []string{"gdeluser *"}, []string{"staff"}, nil, "SITE DELUSER", 501},
+		{"self", []string{"deluser *"}, []string{"staff"}, nil, "SITE DELUSER someone", 550},
+		{"missing", []string{"deluser *"}, []string{"staff"}, nil, "SITE DELUSER missing", 550},
+		{"primary group", []string{"gdeluser *"}, []string{"staff"}, nil, "SITE DELUSER bob", 200},
+		{"leech", []string{"gdeluser *"}, []string{"staff"}, []string{acl.FlagLeech}, "SITE DELUSER bob", 200},
+		{"foreign primary group", []string{"gdeluser *"}, []string{"other", "staff"}, nil, "SITE DELUSER bob", 550},
+		{"no group", []string{"gdeluser *"}, nil, nil, "SITE DELUSER bob", 550},
+		{"siteop member", []string{"gdeluser *"}, []string{"staff"}, []string{"siteop"}, "SITE DELUSER bob", 550},
+		{"siteop by staff", []string{"deluser *"}, []string{"staff"}, []string{"siteop"}, "SITE DELUSER bob", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newGadminSession(t, tt.permissions...)
+			defer done()
+
+			addMember(t, s.Auth(), "bob", tt.groups, tt.flags...)
+
+			err := s.Converse(context.Background(),
+				ftptest.Step{Send: tt.send, Expect: tt.expect},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := s.Auth().GetUser("bob")
+			if err != nil {
+				t.Fatalf("unexpected error getting user: %s", err)
+			}
+
+			if u.Deleted() != (tt.expect == 200) {
+				t.Fatalf("expected deleted to be %t", tt.expect == 200)
+			}
+		})
+	}
+}
+
+func TestSiteDelUserGadmin(t *testing.T) {
+	s, done := newGadminSession(t, "gdeluser *")
+	defer done()
+
+	addMember(t, s.Auth(), "bob", []string{"staff"})
+
+	err := s.Auth().UpdateUser("bob", func(u *acl.User) error {
+		u.Groups["staff"] = acl.GroupSettings{IsAdmin: true}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating user: %s", err)
+	}
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE DELUSER bob", Expect: 550},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/audit"
+	"github.com/pkg/errors"
+)
+
+// default number of entries listed by SITE AUDIT
+const auditDefaultLimit = 20
+
+/*
+	SITE AUDIT [-<limit>] [actor=<user>] [target=<name>] [action=<action>]
+
+	Lists the newest privileged actions from the audit log, who took them,
+	from where and with which parameters. Actions taken through the admin
+	api have the actor api. Requires the `audit` site permission.
+*/
+
+type siteCommandAUDIT struct{}
+
+func (c siteCommandAUDIT) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandAUDIT) Execute(ctx context.Context, s Session, params []string) error {
+	limit, params, err := parseLimit(params, auditDefaultLimit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("audit", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	q := audit.Query{
+		Limit: limit,
+	}
+
+	for _, p := range params {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE AUDIT [-<limit>] [actor=<user>] [target=<name>] [action=<action>]")
+		}
+
+		switch strings.ToLower(parts[0]) {
+		case "actor":
+			q.Actor = parts[1]
+		case "target":
+			q.Target = parts[1]
+		case "action":
+			q.Action = parts[1]
+		default:
+			return s.ReplyError(StatusActionNotOK, errors.Errorf("unknown filter '%s', filters: actor, target, action", parts[0]))
+		}
+	}
+
+	entries, err := s.Audit().Search(q)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(entries) == 0 {
+		return s.ReplyWithMessage(StatusOK, "No audit entries.")
+	}
+
+	var b strings.Builder
+
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %s@%s %s", e.Time.Format("2006-01-02 15:04:05"), e.Actor, e.IP, e.Action)
+
+		if len(e.Target) > 0 {
+			fmt.Fprintf(&b, " %s", e.Target)
+		}
+
+		if len(e.Params) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(e.Params, " "))
+		}
+
+		b.WriteString("\n")
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+func init() {
+	SiteCommandMap["AUDIT"] = &siteCommandAUDIT{}
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE BAN <ip|cidr> [<duration>] [<reason>]
+
+	Bans an ip or network, connections from it are refused and sessions
+	already connected are kicked. The duration is a number followed by s,
+	m, h, d or w, i.e. 12h, without one the ban never expires. Requires the
+	`ban` site permission.
+
+	SITE UNBAN <ip|cidr>
+
+	Lifts a ban, the ip or cidr has to be given as it was banned. Requires
+	the `ban` site permission.
+
+	SITE BANS
+
+	Lists the bans, including those made automatically after repeated
+	failed logins. Requires the `bans` site permission.
+*/
+
+// ErrBanned is returned when a session is closed after its ip is banned
+var ErrBanned = errors.New("banned")
+
+// ReplyBanned tells the client it has been banned and closes the session
+func ReplyBanned(s Session) error {
+	s.ReplyWithMessage(StatusServiceUnavailable, "Too many failures, banned.")
+	return NewFatalError(ErrBanned)
+}
+
+// banUnits are the units of a ban duration
+var banUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseBanDuration parses a number followed by a unit, i.e. 12h
+func parseBanDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	unit, ok := banUnits[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+type siteCommandBAN struct{}
+
+func (c siteCommandBAN) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandBAN) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE BAN <ip|cidr> [<duration>] [<reason>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("ban", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	network, err := ban.ParseNetwork(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if network.Contains(net.ParseIP(remoteIP(s))) {
+		return s.ReplyWithMessage(StatusActionNotOK, "Refusing to ban yourself.")
+	}
+
+	var d time.Duration
+
+	reason := params[1:]
+	if len(reason) > 0 {
+		if parsed, ok := parseBanDuration(strings.ToLower(reason[0])); ok {
+			d, reason = parsed, reason[1:]
+		}
+	}
+
+	b, err := s.Bans().Add(params[0], strings.Join(reason, " "), user.Name, d)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	s.Logger().Info("banned", logging.F("ban", b.Network), logging.F("reason", b.Reason))
+
+	network, _ = ban.ParseNetwork(b.Network)
+
+	n := s.Kick(func(info SessionInfo) bool {
+		host, _, err := net.SplitHostPort(info.IP)
+		if err != nil {
+			host = info.IP
+		}
+		return network.Contains(net.ParseIP(host))
+	})
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Banned %s.\nKicked %d session(s).", b, n))
+}
+
+type siteCommandUNBAN struct{}
+
+func (c siteCommandUNBAN) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUNBAN) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE UNBAN <ip|cidr>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("ban", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if err := s.Bans().Remove(params[0]); err != nil {
+		if errors.Is(err, ban.ErrNotBanned) {
+			return s.ReplyWithMessage(StatusActionNotOK, fmt.Sprintf("'%s' is not banned.", params[0]))
+		}
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Unbanned '%s'.", params[0]))
+}
+
+type siteCommandBANS struct{}
+
+func (c siteCommandBANS) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandBANS) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("bans", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	bans, err := s.Bans().List()
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(bans) == 0 {
+		return s.ReplyWithMessage(StatusOK, "No bans.")
+	}
+
+	var b strings.Builder
+
+	for _, e := range bans {
+		fmt.Fprintf(&b, "%s %s\n", e.Created.Format("2006-01-02 15:04:05"), e)
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+func init() {
+	SiteCommandMap["BAN"] = &siteCommandBAN{}
+	SiteCommandMap["UNBAN"] = &siteCommandUNBAN{}
+	SiteCommandMap["BANS"] = &siteCommandBANS{}
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE CHANGE <user> <field> <value>
+
+	Changes an attribute of a user. Requires the `change` site permission,
+	or the `change_<field>` site permission for a single field.
+
+	Fields:
+		expires       <never|YYYY-MM-DD|<days>d>
+		homedir       <path> [jail]
+		ratio         <ratio>, 0 makes the user a leech
+		idle_time     <seconds>, 0 uses the server's idle_timeout
+		flags         <+flag|-flag> ...
+		max_logins    <sessions>, 0 is unlimited
+		max_uploads   <uploads>, simultaneous, 0 is unlimited
+		max_downloads <downloads>, simultaneous, 0 is unlimited
+		num_logins    <logins>
+		tagline       <text|none>
+		comment       <text|none>, only shown to staff
+*/
+
+// longest tagline and comment a User can have
+const (
+	maxTagline = 64
+	maxComment = 255
+)
+
+// changeField applies the value to the field of the User
+type changeField func(*acl.User, []string) error
+
+var changeFields = map[string]changeField{
+	"expires":       changeExpires,
+	"homedir":       changeHomeDir,
+	"ratio":         changeRatio,
+	"idle_time":     changeNumber(func(u *acl.User) *int { return &u.IdleTime }),
+	"flags":         changeFlags,
+	"max_logins":    changeNumber(func(u *acl.User) *int { return &u.MaxLogins }),
+	"max_uploads":   changeNumber(func(u *acl.User) *int { return &u.MaxUploads }),
+	"max_downloads": changeNumber(func(u *acl.User) *int { return &u.MaxDownloads }),
+	"num_logins":    changeNumber(func(u *acl.User) *int { return &u.Logins }),
+	"tagline":       changeTagline,
+	"comment":       changeComment,
+}
+
+type siteCommandCHANGE struct{}
+
+func (c siteCommandCHANGE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCHANGE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 3 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE CHANGE <user> <field> <value>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	field := strings.ToLower(params[1])
+
+	if !s.CommandAllowed("change", user) && !s.CommandAllowed("change_"+field, user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	fn, ok := changeFields[field]
+	if !ok {
+		return s.ReplyWithMessage(StatusParameterNotImplemented, fmt.Sprintf("Unknown field '%s'.", field))
+	}
+
+	err := s.Auth().UpdateUser(params[0], func(u *acl.User) error {
+		return fn(u, params[2:])
+	})
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Changed %s for %s.", field, params[0]))
+}
+
+// changeExpires sets when the User expires, accepts `never`, a date in the
+// form of YYYY-MM-DD or a number of days from now, i.e. `30d`
+func changeExpires(u *acl.User, params []string) error {
+	value := strings.ToLower(params[0])
+
+	switch {
+	case value == "never" || value == "0":
+		u.ExpiresAt = time.Time{}
+
+	case strings.HasSuffix(value, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days <= 0 {
+			return errors.Errorf("bad number of days: '%s'", value)
+		}
+		u.ExpiresAt = time.Now().AddDate(0, 0, days)
+
+	default:
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return errors.Errorf("bad date, expected YYYY-MM-DD: '%s'", value)
+		}
+		u.ExpiresAt = t
+	}
+
+	return nil
+}
+
+// changeHomeDir sets the directory the User starts in, `jail` can be given
+// after the path to restrict the User to it
+func changeHomeDir(u *acl.User, params []string) error {
+	if len(params) > 2 || (len(params) == 2 && !strings.EqualFold(params[1], "jail")) {
+		return errors.New("expected homedir <path> [jail]")
+	}
+
+	if !strings.HasPrefix(params[0], "/") {
+		return errors.Errorf("homedir must be absolute: '%s'", params[0])
+	}
+
+	u.HomeDir = path.Clean(params[0])
+
+	if len(params) == 2 {
+		u.AddFlag(acl.FlagJail)
+	} else {
+		u.RemoveFlag(acl.FlagJail)
+	}
+
+	return nil
+}
+
+// changeNumber returns a changeField that sets a number that can't be negative
+func changeNumber(field func(*acl.User) *int) changeField {
+	return func(u *acl.User, params []string) error {
+		if len(params) != 1 {
+			return errors.New("expected a single number")
+		}
+
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 0 {
+			return errors.Errorf("bad number: '%s'", params[0])
+		}
+
+		*field(u) = n
+
+		return nil
+	}
+}
+
+// changeRatio sets the User's ratio, a ratio of 0 makes them a leech
+func changeRatio(u *acl.User, params []string) error {
+	if err := changeNumber(func(u *acl.User) *int { return &u.Ratio })(u, params); err != nil {
+		return err
+	}
+
+	if u.Ratio == 0 {
+		u.AddFlag(acl.FlagLeech)
+	} else {
+		u.RemoveFlag(acl.FlagLeech)
+	}
+
+	return nil
+}
+
+// changeFlags adds flags prefixed with + and removes those prefixed with -.
+// Deleting users has its own commands so the deleted flag can't be changed
+func changeFlags(u *acl.User, params []string) error {
+	for _, p := range params {
+		if len(p) < 2 || (p[0] != '+' && p[0] != '-') {
+			return errors.Errorf("expected +flag or -flag: '%s'", p)
+		}
+
+		flag := strings.ToLower(p[1:])
+
+		for _, r := range flag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				return errors.Errorf("bad flag: '%s'", flag)
+			}
+		}
+
+		if flag == acl.FlagDeleted {
+			return errors.Errorf("'%s' can't be changed", flag)
+		}
+
+		if p[0] == '+' {
+			u.AddFlag(flag)
+		} else {
+			u.RemoveFlag(flag)
+		}
+	}
+
+	return nil
+}
+
+// changeTagline sets the User's tagline, `none` clears it
+func changeTagline(u *acl.User, params []string) error {
+	tagline, err := parseText("tagline", params, maxTagline)
+	if err != nil {
+		return err
+	}
+
+	u.Tagline = tagline
+
+	return nil
+}
+
+// changeComment sets the staff comment on the User, `none` clears it
+func changeComment(u *acl.User, params []string) error {
+	comment, err := parseText("comment", params, maxComment)
+	if err != nil {
+		return err
+	}
+
+	u.Comment = comment
+
+	return nil
+}
+
+// parseText joins the params in to a single line of text of at most max
+// characters, `none` is treated as empty
+func parseText(field string, params []string, max int) (string, error) {
+	text := strings.Join(params, " ")
+
+	if strings.EqualFold(text, "none") {
+		return "", nil
+	}
+
+	if len(text) > max {
+		return "", errors.Errorf("%s can't be longer than %d characters", field, max)
+	}
+
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			return "", errors.Errorf("%s can't contain control characters", field)
+		}
+	}
+
+	return text, nil
+}
+
+func init() {
+	SiteCommandMap["CHANGE"] = &siteCommandCHANGE{}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+/*
+	SITE CHOWN [-R] <user>[:<group>] <path>
+
+	Changes the owner, and optionally the group, of a file or directory.
+	With -R everything under a directory is changed as well. Requires the
+	`chown` site permission.
+
+	SITE CHGRP [-R] <group> <path>
+
+	Changes the group of a file or directory. Requires the `chgrp` site
+	permission.
+*/
+
+type siteCommandCHOWN struct{}
+
+func (c siteCommandCHOWN) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCHOWN) Execute(ctx context.Context, s Session, params []string) error {
+	recursive, params := parseRecursive(params)
+
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE CHOWN [-R] <user>[:<group>] <path>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("chown", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	owner := params[0]
+
+	var group string
+	if idx := strings.Index(owner, ":"); idx >= 0 {
+		owner, group = owner[:idx], owner[idx+1:]
+	}
+
+	// make sure the user and group exist
+	u, err := s.Auth().GetUser(owner)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+	owner = u.Name
+
+	if len(group) > 0 {
+		g, err := s.Auth().GetGroup(group)
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+		group = g.Name
+	}
+
+	return changeOwner(s, user, params[1:], owner, group, recursive)
+}
+
+type siteCommandCHGRP struct{}
+
+func (c siteCommandCHGRP) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCHGRP) Execute(ctx context.Context, s Session, params []string) error {
+	recursive, params := parseRecursive(params)
+
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE CHGRP [-R] <group> <path>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("chgrp", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	// make sure the group exists
+	g, err := s.Auth().GetGroup(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return changeOwner(s, user, params[1:], "", g.Name, recursive)
+}
+
+// parseRecursive checks for a leading -R flag
+func parseRecursive(params []string) (bool, []string) {
+	if len(params) > 0 && strings.EqualFold(params[0], "-R") {
+		return true, params[1:]
+	}
+	return false, params
+}
+
+// changeOwner changes the owner and group of the path in params and replies
+// with the number of paths changed
+func changeOwner(s Session, user *acl.User, params []string, owner, group string, recursive bool) error {
+	path := s.FS().Join(s.CWD(), params)
+
+	n, err := s.FS().ChangeOwner(path, owner, group, recursive, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Changed %d path(s).", n))
+}
+
+func init() {
+	SiteCommandMap["CHOWN"] = &siteCommandCHOWN{}
+	SiteCommandMap["CHGRP"] = &siteCommandCHGRP{}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+/*
+	SITE CHPASS <user> <password>
+
+	Changes a user's password, the new password has to meet the password
+	policy. Users can always change their own password, changing anybody
+	else's requires the `chpass` site permission.
+*/
+
+type siteCommandCHPASS struct{}
+
+func (c siteCommandCHPASS) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCHPASS) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE CHPASS <user> <password>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if s.Anonymous() {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if !strings.EqualFold(params[0], user.Name) && !s.CommandAllowed("chpass", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	u, err := s.Auth().GetUser(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if err := s.Auth().ChangePassword(u.Name, params[1]); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	s.Logger().Info("changed password", logging.F("target", u.Name), logging.F("ident", s.Ident()))
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Changed password for '%s'.", u.Name))
+}
+
+func init() {
+	SiteCommandMap["CHPASS"] = &siteCommandCHPASS{}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+/*
+	SITE CLOSE [-kick] [<reason>]
+
+	Closes the site for maintenance, new logins are refused with the
+	reason, or the server's closed_message when none is given. Users with
+	the `closed` site permission can still login. With -kick every logged
+	in user without the `closed` site permission is disconnected, along
+	with anonymous users and sessions that haven't logged in. Requires the
+	`close` site permission.
+
+	SITE REOPEN
+
+	Reopens the site to logins. Requires the `close` site permission.
+
+	The site is reopened when the server restarts.
+*/
+
+type siteCommandCLOSE struct{}
+
+func (c siteCommandCLOSE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCLOSE) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("close", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	var kick bool
+
+	if len(params) > 0 && strings.ToLower(params[0]) == "-kick" {
+		kick = true
+		params = params[1:]
+	}
+
+	s.CloseSite(strings.Join(params, " "))
+
+	reason, _ := s.SiteClosed()
+
+	s.Logger().Info("closed the site", logging.F("reason", reason))
+
+	message := fmt.Sprintf("Site closed: %s", reason)
+
+	if kick {
+		n := s.Kick(func(info SessionInfo) bool {
+			if info.State < SessionStateLoggedIn || info.Anonymous {
+				return true
+			}
+
+			u, err := s.Auth().GetUser(info.Login)
+			if err != nil {
+				return true
+			}
+
+			return !s.CommandAllowed("closed", u)
+		})
+
+		message += fmt.Sprintf("\nKicked %d session(s).", n)
+	}
+
+	return s.ReplyWithMessage(StatusOK, message)
+}
+
+type siteCommandREOPEN struct{}
+
+func (c siteCommandREOPEN) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandREOPEN) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("close", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if !s.ReopenSite() {
+		return s.ReplyWithMessage(StatusActionNotOK, "Site is not closed.")
+	}
+
+	s.Logger().Info("reopened the site")
+
+	return s.ReplyWithMessage(StatusOK, "Site reopened.")
+}
+
+func init() {
+	SiteCommandMap["CLOSE"] = &siteCommandCLOSE{}
+	SiteCommandMap["REOPEN"] = &siteCommandREOPEN{}
+}
@@ -0,0 +1,134 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteClose(t *testing.T) {
+	var tests = []struct {
+		name        string
+		permissions []string
+		steps       []ftptest.Step
+		closed      bool
+		reason      string
+	}{
+		{
+			"no permission",
+			nil,
+			[]ftptest.Step{
+				{Send: "SITE CLOSE", Expect: 530},
+				{Send: "SITE REOPEN", Expect: 530},
+			},
+			false,
+			"",
+		},
+		{
+			"closed",
+			[]string{"close *"},
+			[]ftptest.Step{
+				{Send: "SITE CLOSE back in 10 minutes", Expect: 200},
+			},
+			true,
+			"back in 10 minutes",
+		},
+		{
+			"reopened",
+			[]string{"close *"},
+			[]ftptest.Step{
+				{Send: "SITE CLOSE maintenance", Expect: 200},
+				{Send: "SITE REOPEN", Expect: 200},
+			},
+			false,
+			"",
+		},
+		{
+			"reopened when open",
+			[]string{"close *"},
+			[]ftptest.Step{
+				{Send: "SITE REOPEN", Expect: 550},
+			},
+			false,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: tt.permissions}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), tt.steps...); err != nil {
+				t.Fatal(err)
+			}
+
+			if reason, closed := s.SiteClosed(); closed != tt.closed || reason != tt.reason {
+				t.Fatalf("expected closed %t '%s' got %t '%s'", tt.closed, tt.reason, closed, reason)
+			}
+		})
+	}
+}
+
+func TestSiteCloseLogin(t *testing.T) {
+	st, err := ftptest.NewMemoryStats()
+	if err != nil {
+		t.Fatalf("unexpected error creating stats: %s", err)
+	}
+	defer st.Close()
+
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: []string{"close *"}, Stats: st}, func(u *acl.User) error {
+		_, err := u.AddIP("*@127.0.0.1")
+		return err
+	})
+	defer done()
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE CLOSE maintenance", Expect: 200},
+		ftptest.Step{Send: "USER someone", Expect: 331},
+		ftptest.Step{Send: "PASS password", Expect: 530},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, _ := s.LastReply(); reply.Message != "maintenance" {
+		t.Fatalf("expected the reason got '%s'", reply)
+	}
+}
+
+func TestSiteCloseKick(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{
+		Permissions: []string{"close *", "closed -staffer"},
+		Sessions: []cmd.SessionInfo{
+			{Login: "staffer", State: cmd.SessionStateLoggedIn},
+			{Login: "user", State: cmd.SessionStateLoggedIn},
+			{Login: "anonymous", Anonymous: true, State: cmd.SessionStateLoggedIn},
+			{State: cmd.SessionStateAuth},
+		},
+	}, nil)
+	defer done()
+
+	for _, name := range []string{"staffer", "user"} {
+		if _, err := s.Auth().AddUser(name, "password"); err != nil {
+			t.Fatalf("unexpected error adding user: %s", err)
+		}
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE CLOSE -kick", Expect: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, _ := s.LastReply(); reply.Message != "Site closed: \nKicked 3 session(s)." {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+
+	// the session itself and the staffer
+	sessions := s.Sessions()
+	if len(sessions) != 2 || sessions[1].Login != "staffer" {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+}
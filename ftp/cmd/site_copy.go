@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+	SITE COPY <path> <newpath>
+
+	Copies a file or directory, and everything under it, to newpath on
+	the server keeping the owners of everything copied. Copies can cross
+	mounts. Requires the `copy` site permission.
+
+	SITE MOVE <path> <newpath>
+
+	Moves a file or directory to newpath on the server, including between
+	mounts. Requires the `move` site permission.
+*/
+
+type siteCommandCOPY struct{}
+
+func (c siteCommandCOPY) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandCOPY) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE COPY <path> <newpath>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("copy", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	oldpath := s.FS().Join(s.CWD(), params[:1])
+	newpath := s.FS().Join(s.CWD(), params[1:])
+
+	n, err := s.FS().CopyTree(oldpath, newpath, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Copied %d bytes to %s.", n, newpath))
+}
+
+type siteCommandMOVE struct{}
+
+func (c siteCommandMOVE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandMOVE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE MOVE <path> <newpath>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("move", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	oldpath := s.FS().Join(s.CWD(), params[:1])
+	newpath := s.FS().Join(s.CWD(), params[1:])
+
+	if err := s.FS().MoveTree(oldpath, newpath, user); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Moved to %s.", newpath))
+}
+
+func init() {
+	SiteCommandMap["COPY"] = &siteCommandCOPY{}
+	SiteCommandMap["MOVE"] = &siteCommandMOVE{}
+}
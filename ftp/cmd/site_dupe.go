@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// default number of results returned by SITE DUPE
+const dupeSearchLimit = 50
+
+/*
+	SITE DUPE [-<limit>] <pattern>
+
+	Searches the dupe database for uploads with names matching the glob
+	pattern, a pattern without wildcards matches anywhere in the name.
+	Requires the `dupe` site permission.
+
+	SITE UNDUPE <pattern>
+
+	Removes uploads with names matching the glob pattern from the dupe
+	database so they can be uploaded again. Requires the `undupe` site
+	permission.
+*/
+
+type siteCommandDUPE struct{}
+
+func (c siteCommandDUPE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandDUPE) Execute(ctx context.Context, s Session, params []string) error {
+	limit, params, err := parseLimit(params, dupeSearchLimit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE DUPE [-<limit>] <pattern>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("dupe", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	pattern := strings.Join(params, " ")
+
+	entries, err := s.Dupe().Search(pattern, limit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(entries) == 0 {
+		return s.ReplyWithMessage(StatusOK, "No dupes found.")
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Dupes matching '%s':\n", pattern)
+
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %s %s\n", formatTime(e.Time), e.User, e.Path)
+	}
+
+	fmt.Fprintf(&b, "Found %d dupe(s).", len(entries))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+type siteCommandUNDUPE struct{}
+
+func (c siteCommandUNDUPE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUNDUPE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE UNDUPE <pattern>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("undupe", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	n, err := s.Dupe().Remove(strings.Join(params, " "))
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Removed %d dupe(s).", n))
+}
+
+func init() {
+	SiteCommandMap["DUPE"] = &siteCommandDUPE{}
+	SiteCommandMap["UNDUPE"] = &siteCommandUNDUPE{}
+}
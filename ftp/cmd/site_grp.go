@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE GRP <group> [<field> <value>]
+
+	Shows a group's members and slots, or changes one of the group's
+	fields, see SITE GRPCHANGE. Requires the `grp` site permission.
+*/
+
+// grpField applies the value to the field of the Group
+type grpField func(*acl.Group, []string) error
+
+var grpFields = map[string]grpField{
+	"slots":        grpSlots(func(g *acl.Group) *int { return &g.MaxMembers }),
+	"gadmin_slots": grpSlots(func(g *acl.Group) *int { return &g.GadminSlots }),
+	"leech_slots":  grpSlots(func(g *acl.Group) *int { return &g.LeechSlots }),
+	"description":  grpDescription,
+}
+
+type siteCommandGRP struct{}
+
+func (c siteCommandGRP) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandGRP) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 && len(params) < 3 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE GRP <group> [<field> <value>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("grp", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if len(params) == 1 {
+		g, err := s.Auth().GetGroup(params[0])
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, c.format(g))
+	}
+
+	return changeGroup(s, params[0], params[1], params[2:])
+}
+
+// changeGroup sets the field of the Group to the value in params
+func changeGroup(s Session, group, field string, params []string) error {
+	field = strings.ToLower(field)
+
+	fn, ok := grpFields[field]
+	if !ok {
+		return s.ReplyWithMessage(StatusParameterNotImplemented, fmt.Sprintf("Unknown field '%s'.", field))
+	}
+
+	err := s.Auth().UpdateGroup(group, func(g *acl.Group) error {
+		return fn(g, params)
+	})
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Changed %s for %s.", field, group))
+}
+
+// grpSlots returns a grpField that sets a number of slots
+func grpSlots(field func(*acl.Group) *int) grpField {
+	return func(g *acl.Group, params []string) error {
+		if len(params) != 1 {
+			return errors.New("expected a single number of slots")
+		}
+
+		value, err := strconv.Atoi(params[0])
+		if err != nil || value < 0 {
+			return errors.Errorf("bad number of slots: '%s'", params[0])
+		}
+
+		*field(g) = value
+
+		return nil
+	}
+}
+
+// grpDescription sets the Group's description, `none` clears it
+func grpDescription(g *acl.Group, params []string) error {
+	description := strings.Join(params, " ")
+
+	if strings.EqualFold(description, "none") {
+		description = ""
+	}
+
+	if len(description) > maxDescription {
+		return errors.Errorf("description can't be longer than %d characters", maxDescription)
+	}
+
+	for _, r := range description {
+		if unicode.IsControl(r) {
+			return errors.New("description can't contain control characters")
+		}
+	}
+
+	g.Description = description
+
+	return nil
+}
+
+// format renders the details of the Group
+func (c siteCommandGRP) format(g *acl.Group) string {
+	var b strings.Builder
+
+	slots := "Unlimited"
+	if g.MaxMembers > 0 {
+		slots = strconv.Itoa(g.MaxMembers)
+	}
+
+	fmt.Fprintf(&b, "Group: %s\n", g.Name)
+
+	if len(g.Description) > 0 {
+		fmt.Fprintf(&b, "Description: %s\n", g.Description)
+	}
+
+	fmt.Fprintf(&b, "Added: %s\n", formatTime(g.AddedAt))
+	fmt.Fprintf(&b, "Members: %d/%s\n", len(g.Members), slots)
+	fmt.Fprintf(&b, "Gadmin Slots: %d\n", g.GadminSlots)
+	fmt.Fprintf(&b, "Leech Slots: %d\n", g.LeechSlots)
+
+	for _, name := range g.MemberNames() {
+		fmt.Fprintf(&b, " %s\n", name)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func init() {
+	SiteCommandMap["GRP"] = &siteCommandGRP{}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE GRPADD <group> [<description>]
+
+	Creates a group. Requires the `grpadd` site permission.
+
+	SITE GRPDEL <group>
+
+	Deletes a group, only groups without any members can be deleted. Files
+	owned by the group are reassigned to the orphan group. Requires the
+	`grpdel` site permission.
+
+	SITE GRPCHANGE <group> <field> <value>
+
+	Changes a field of a group. Requires the `grpchange` site permission,
+	admins of the group can change its description without it.
+
+	Fields:
+		slots        maximum number of members, 0 is unlimited
+		gadmin_slots number of users group admins can still add
+		leech_slots  number of leeches group admins can still add
+		description  <text>, none clears it
+*/
+
+// maxDescription is the longest description a Group can have
+const maxDescription = 64
+
+type siteCommandGRPADD struct{}
+
+func (c siteCommandGRPADD) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandGRPADD) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE GRPADD <group> [<description>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("grpadd", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if err := checkGroupName(params[0]); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	// check the description before creating anything
+	var g acl.Group
+	if err := grpDescription(&g, params[1:]); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if _, err := s.Auth().AddGroup(params[0]); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(g.Description) > 0 {
+		err := s.Auth().UpdateGroup(params[0], func(group *acl.Group) error {
+			group.Description = g.Description
+			return nil
+		})
+
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Added group '%s'.", params[0]))
+}
+
+// checkGroupName makes sure a new group's name is made up of letters,
+// digits, - and _
+func checkGroupName(name string) error {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return errors.Errorf("bad group name: '%s'", name)
+		}
+	}
+	return nil
+}
+
+type siteCommandGRPDEL struct{}
+
+func (c siteCommandGRPDEL) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandGRPDEL) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE GRPDEL <group>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("grpdel", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	g, err := s.Auth().GetGroup(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(g.Members) > 0 {
+		return s.ReplyError(StatusActionNotOK, errors.Errorf("group has %d member(s)", len(g.Members)))
+	}
+
+	if err := s.Auth().DeleteGroup(g.Name); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Deleted group '%s'.", g.Name))
+}
+
+type siteCommandGRPCHANGE struct{}
+
+func (c siteCommandGRPCHANGE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandGRPCHANGE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 3 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE GRPCHANGE <group> <field> <value>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	// slots are handed out by staff, gadmins can only describe their group
+	gadmin := strings.EqualFold(params[1], "description") && user.IsGroupAdmin(params[0])
+
+	if !gadmin && !s.CommandAllowed("grpchange", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	return changeGroup(s, params[0], params[1], params[2:])
+}
+
+func init() {
+	SiteCommandMap["GRPADD"] = &siteCommandGRPADD{}
+	SiteCommandMap["GRPDEL"] = &siteCommandGRPDEL{}
+	SiteCommandMap["GRPCHANGE"] = &siteCommandGRPCHANGE{}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+)
+
+/*
+	SITE VERS
+
+	Shows the version of the server.
+
+	SITE UPTIME
+
+	Shows when the server started and how long it has been running.
+
+	SITE SWHO
+
+	Shows how many sessions are connected and what they are doing, the
+	current upload and download speed and the totals since the server
+	started. Unlike SITE WHO every session is listed. Requires the `swho`
+	site permission.
+*/
+
+// ServerInfo is the version, uptime, totals and current speeds of the
+// server
+type ServerInfo struct {
+	Version string
+	Started time.Time
+	Uptime  time.Duration
+
+	// connections accepted since the server started
+	Connections int64
+
+	// transfers and bytes since the server started
+	Uploads       int64
+	Downloads     int64
+	UploadBytes   int64
+	DownloadBytes int64
+
+	// bytes a second over the last few seconds across every transfer
+	UploadSpeed   float64
+	DownloadSpeed float64
+}
+
+type siteCommandVERS struct{}
+
+func (c siteCommandVERS) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandVERS) Execute(ctx context.Context, s Session, params []string) error {
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("goftpd %s", s.ServerInfo().Version))
+}
+
+type siteCommandUPTIME struct{}
+
+func (c siteCommandUPTIME) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUPTIME) Execute(ctx context.Context, s Session, params []string) error {
+	info := s.ServerInfo()
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Up %s since %s.", cookie.FormatAge(info.Uptime), formatTime(info.Started)))
+}
+
+type siteCommandSWHO struct{}
+
+func (c siteCommandSWHO) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandSWHO) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("swho", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	info := s.ServerInfo()
+	sessions := s.Sessions()
+
+	var b strings.Builder
+
+	var loggedIn, anonymous, uploading, downloading int
+
+	for _, session := range sessions {
+		b.WriteString(siteCommandWHO{}.format(s, session))
+		b.WriteString("\n")
+
+		if session.State == SessionStateLoggedIn {
+			loggedIn++
+			if session.Anonymous {
+				anonymous++
+			}
+		}
+
+		switch session.Transfer {
+		case TransferUpload:
+			uploading++
+		case TransferDownload:
+			downloading++
+		}
+	}
+
+	fmt.Fprintf(&b, "Sessions: %d connected, %d logged in, %d anonymous\n", len(sessions), loggedIn, anonymous)
+	fmt.Fprintf(&b, "Up: %d at %s Down: %d at %s\n", uploading, cookie.FormatSpeed(info.UploadSpeed), downloading, cookie.FormatSpeed(info.DownloadSpeed))
+	fmt.Fprintf(&b, "Since %s: %d connection(s), %d upload(s) of %s, %d download(s) of %s", formatTime(info.Started), info.Connections, info.Uploads, cookie.FormatBytes(info.UploadBytes), info.Downloads, cookie.FormatBytes(info.DownloadBytes))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+func init() {
+	SiteCommandMap["VERS"] = &siteCommandVERS{}
+	SiteCommandMap["UPTIME"] = &siteCommandUPTIME{}
+	SiteCommandMap["SWHO"] = &siteCommandSWHO{}
+}
@@ -0,0 +1,120 @@
+package cmd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+// testServerInfo is the ServerInfo of a server up for 3 days and 4 hours
+var testServerInfo = cmd.ServerInfo{
+	Version:       "v1.2.3",
+	Started:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local),
+	Uptime:        76 * time.Hour,
+	Connections:   12,
+	Uploads:       3,
+	Downloads:     4,
+	UploadBytes:   2048,
+	DownloadBytes: 512,
+	UploadSpeed:   1536,
+	DownloadSpeed: 100,
+}
+
+func TestSiteInfo(t *testing.T) {
+	var tests = []struct {
+		send     string
+		expected string
+	}{
+		{"SITE VERS", "goftpd v1.2.3"},
+		{"SITE UPTIME", "Up 3d4h since 2020-01-02 03:04:05."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{ServerInfo: testServerInfo}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: 200}); err != nil {
+				t.Fatal(err)
+			}
+
+			if reply, _ := s.LastReply(); reply.Message != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, reply.Message)
+			}
+		})
+	}
+}
+
+func TestSiteInfoNotLoggedIn(t *testing.T) {
+	for _, send := range []string{"SITE VERS", "SITE UPTIME", "SITE SWHO"} {
+		t.Run(send, func(t *testing.T) {
+			s, err := ftptest.NewSession(&ftptest.SessionOpts{TLS: true, ServerInfo: testServerInfo})
+			if err != nil {
+				t.Fatalf("unexpected error creating session: %s", err)
+			}
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: send, Expect: 530}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSiteSWHO(t *testing.T) {
+	var tests = []struct {
+		name        string
+		permissions []string
+		expect      int
+	}{
+		{"no permission", nil, 530},
+		{"not in the group", []string{"swho =staff"}, 530},
+		{"allowed", []string{"swho *"}, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{
+				Permissions: tt.permissions,
+				ServerInfo:  testServerInfo,
+				Sessions: []cmd.SessionInfo{
+					{Login: "uploader", State: cmd.SessionStateLoggedIn, Transfer: cmd.TransferUpload},
+					{Login: "anonymous", Anonymous: true, State: cmd.SessionStateLoggedIn, Transfer: cmd.TransferDownload},
+					{State: cmd.SessionStateAuth},
+				},
+			}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE SWHO", Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.expect != 200 {
+				return
+			}
+
+			reply, _ := s.LastReply()
+
+			// every session is listed as in SITE WHO followed by the totals
+			lines := strings.Split(reply.Message, "\n")
+			if len(lines) < 3 {
+				t.Fatalf("expected the totals got '%s'", reply.Message)
+			}
+
+			expected := []string{
+				"Sessions: 4 connected, 3 logged in, 1 anonymous",
+				"Up: 1 at 1.5KB/s Down: 1 at 100B/s",
+				"Since 2020-01-02 03:04:05: 12 connection(s), 3 upload(s) of 2.0KB, 4 download(s) of 512B",
+			}
+
+			for i, line := range lines[len(lines)-3:] {
+				if line != expected[i] {
+					t.Fatalf("expected '%s' got '%s'", expected[i], line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE MSG [READ]
+
+	Shows the messages in your inbox, marking them as read.
+
+	SITE MSG SEND <user> <message>
+
+	Sends a message to another user's inbox. Requires the `msg` site
+	permission.
+
+	SITE MSG DELETE <id|all>
+
+	Deletes a message, or every message, from your inbox.
+
+	Anonymous users have no inbox. How long messages can be, how many an
+	inbox holds and how long they are kept for are set in the inbox
+	namespace.
+*/
+
+type siteCommandMSG struct{}
+
+func (c siteCommandMSG) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandMSG) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if s.Anonymous() {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if len(params) == 0 {
+		return c.read(s, user.Name)
+	}
+
+	switch strings.ToUpper(params[0]) {
+	case "READ":
+		return c.read(s, user.Name)
+
+	case "SEND":
+		if len(params) < 3 {
+			break
+		}
+
+		if !s.CommandAllowed("msg", user) {
+			return s.ReplyStatus(StatusPermissionDenied)
+		}
+
+		to, err := s.Auth().GetUser(params[1])
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		if to.Deleted() {
+			return s.ReplyError(StatusActionNotOK, errors.New("user is deleted"))
+		}
+
+		if _, err := s.Inbox().Send(user.Name, to.Name, strings.Join(params[2:], " ")); err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Message sent to '%s'.", to.Name))
+
+	case "DELETE":
+		if len(params) != 2 {
+			break
+		}
+
+		if strings.ToLower(params[1]) == "all" {
+			n, err := s.Inbox().Clear(user.Name)
+			if err != nil {
+				return s.ReplyError(StatusActionNotOK, err)
+			}
+
+			return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Deleted %d message(s).", n))
+		}
+
+		id, err := strconv.ParseUint(params[1], 10, 64)
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, errors.New("id must be a number or all"))
+		}
+
+		if err := s.Inbox().Delete(user.Name, id); err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Deleted message %d.", id))
+	}
+
+	return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE MSG [READ|SEND <user> <message>|DELETE <id|all>]")
+}
+
+// read replies with every message in the user's inbox
+func (c siteCommandMSG) read(s Session, name string) error {
+	messages, err := s.Inbox().Read(name)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	var b strings.Builder
+
+	for _, m := range messages {
+		var unread string
+		if !m.Read {
+			unread = " (new)"
+		}
+
+		fmt.Fprintf(&b, "#%d from %s at %s%s\n", m.ID, m.From, formatTime(m.Time), unread)
+		fmt.Fprintf(&b, "  %s\n", m.Body)
+	}
+
+	fmt.Fprintf(&b, "%d message(s).", len(messages))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+func init() {
+	SiteCommandMap["MSG"] = &siteCommandMSG{}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+// default number of releases listed per section by SITE NEW
+const newDefaultLimit = 10
+
+/*
+	SITE NEW [-<limit>] [<section>]
+
+	Lists the newest releases from the dirlog along with how long ago they
+	were created, the newest in each section or only those in the section
+	given. Releases created too long after their pre are marked [OLD].
+	Requires the `new` site permission.
+*/
+
+type siteCommandNEW struct{}
+
+func (c siteCommandNEW) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandNEW) Execute(ctx context.Context, s Session, params []string) error {
+	limit, params, err := parseLimit(params, newDefaultLimit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(params) > 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE NEW [-<limit>] [<section>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("new", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	sections := s.Dirlog().Sections()
+
+	if len(params) == 1 {
+		var found bool
+		for _, section := range sections {
+			if strings.EqualFold(section, params[0]) {
+				sections = []string{section}
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return s.ReplyError(StatusActionNotOK, errors.Errorf("unknown section '%s', sections: %s", params[0], strings.Join(sections, ", ")))
+		}
+	}
+
+	now := time.Now()
+
+	var b strings.Builder
+
+	for _, section := range sections {
+		entries, err := s.Dirlog().Latest(section, limit)
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "[%s]\n", strings.ToUpper(section))
+
+		for _, e := range entries {
+			fmt.Fprintf(&b, " %-8s %s by %s/%s", cookie.FormatAge(now.Sub(e.Time)), e.Name(), e.User, e.Group)
+
+			if e.Old {
+				b.WriteString(" [OLD]")
+			}
+
+			b.WriteString("\n")
+		}
+	}
+
+	if b.Len() == 0 {
+		return s.ReplyWithMessage(StatusOK, "No new releases.")
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+func init() {
+	SiteCommandMap["NEW"] = &siteCommandNEW{}
+}
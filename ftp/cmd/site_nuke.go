@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/pkg/errors"
+)
+
+// default and maximum number of nukes listed by SITE NUKES
+const (
+	nukesDefaultLimit = 10
+	nukesMaxLimit     = 50
+)
+
+/*
+	SITE NUKE <dir> <multiplier> <reason>
+
+	Nukes a directory, renaming it with the nuke prefix. Everyone who
+	uploaded to it loses their upload multiplied by their ratio and the
+	multiplier in credits, and the upload is taken off their alltime
+	stats. The multiplier is at least 1, leeches lose no credits. Requires
+	the `nuke` site permission.
+
+	SITE UNNUKE <dir>
+
+	Reverses a nuke, renaming the directory back and giving back the
+	credits and stats that were taken. The directory can be given by
+	either its nuked or original name. Requires the `unnuke` site
+	permission.
+
+	SITE NUKES [<count>]
+
+	Lists the most recent nukes. Requires the `nukes` site permission.
+*/
+
+type siteCommandNUKE struct{}
+
+func (c siteCommandNUKE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandNUKE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 3 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE NUKE <dir> <multiplier> <reason>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("nuke", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	multiplier, err := strconv.Atoi(params[1])
+	if err != nil || multiplier < 1 {
+		return s.ReplyError(StatusActionNotOK, errors.New("multiplier must be a number of at least 1"))
+	}
+
+	path := s.FS().Join(s.CWD(), params[:1])
+
+	uploaders, err := s.FS().Uploaders(path, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	r := nuke.Record{
+		Path:       path,
+		NukedPath:  s.Nukes().NukedPath(path),
+		Reason:     strings.Join(params[2:], " "),
+		Multiplier: multiplier,
+		Nuker:      user.Name,
+		Time:       time.Now(),
+	}
+
+	for _, u := range uploaders {
+		nukee := nuke.Nukee{
+			User:  u.User,
+			Group: u.Group,
+			Bytes: u.Bytes,
+			Files: u.Files,
+		}
+
+		// leeches were never awarded credits for the upload so lose none
+		if uploader, err := s.Auth().GetUser(u.User); err == nil && !uploader.Leech() {
+			nukee.Credits = u.Bytes * int64(uploader.Ratio) * int64(multiplier)
+		}
+
+		r.Nukees = append(r.Nukees, nukee)
+	}
+
+	// the record is added first so the same directory can't be nuked twice
+	if err := s.Nukes().Add(r); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if err := s.FS().MoveTree(r.Path, r.NukedPath, user); err != nil {
+		if err := s.Nukes().Remove(r.Path); err != nil {
+			s.Logger().Error("unable to remove nuke", logging.Path(r.Path), logging.Err(err))
+		}
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	section := s.Stats().Section(r.Path)
+
+	for _, e := range r.Nukees {
+		if e.Credits > 0 {
+			if _, err := s.Auth().DecrCredits(e.User, e.Credits, true); err != nil && err != acl.ErrUserDoesntExist {
+				s.Logger().Error("unable to take nuke credits", logging.F("nukee", e.User), logging.Err(err))
+			}
+		}
+
+		adjustNukeStats(s, e, section, -1)
+	}
+
+	s.Logger().Info("nuked", logging.Path(r.Path), logging.F("multiplier", r.Multiplier), logging.F("reason", r.Reason))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Nuked %s x%d: %s\n", r.Path, r.Multiplier, r.Reason)
+
+	for _, e := range r.Nukees {
+		fmt.Fprintf(&b, "%s/%s %s in %d file(s), lost %s\n", e.User, e.Group, cookie.FormatBytes(e.Bytes), e.Files, cookie.FormatBytes(e.Credits))
+	}
+
+	fmt.Fprintf(&b, "Renamed to %s.", r.NukedPath)
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+type siteCommandUNNUKE struct{}
+
+func (c siteCommandUNNUKE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUNNUKE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE UNNUKE <dir>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("unnuke", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	path := s.FS().Join(s.CWD(), params[:1])
+
+	r, err := s.Nukes().Get(path)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if r == nil {
+		return s.ReplyError(StatusActionNotOK, nuke.ErrNotNuked)
+	}
+
+	if err := s.FS().MoveTree(r.NukedPath, r.Path, user); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if err := s.Nukes().Remove(r.Path); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	section := s.Stats().Section(r.Path)
+
+	for _, e := range r.Nukees {
+		if e.Credits > 0 {
+			if _, err := s.Auth().IncrCredits(e.User, e.Credits); err != nil && err != acl.ErrUserDoesntExist {
+				s.Logger().Error("unable to give back nuke credits", logging.F("nukee", e.User), logging.Err(err))
+			}
+		}
+
+		adjustNukeStats(s, e, section, 1)
+	}
+
+	s.Logger().Info("unnuked", logging.Path(r.Path))
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Unnuked %s, %d user(s) given back their credits.", r.Path, len(r.Nukees)))
+}
+
+// adjustNukeStats adds, or with a sign of -1 takes, the nukee's upload to
+// their alltime stats for the section and AllSections. Failures are logged
+// as the nuke itself was successful
+func adjustNukeStats(s Session, e nuke.Nukee, section string, sign int64) {
+	totals := stats.Totals{
+		UploadBytes: sign * e.Bytes,
+		UploadFiles: sign * e.Files,
+	}
+
+	for _, sec := range []string{section, stats.AllSections} {
+		if err := s.Stats().Add(e.User, sec, stats.PeriodAllTime, time.Now(), totals); err != nil {
+			s.Logger().Error("unable to adjust stats on nuke", logging.F("nukee", e.User), logging.Err(err))
+		}
+	}
+}
+
+type siteCommandNUKES struct{}
+
+func (c siteCommandNUKES) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandNUKES) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE NUKES [<count>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("nukes", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	limit := nukesDefaultLimit
+
+	if len(params) == 1 {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 1 {
+			return s.ReplyError(StatusActionNotOK, errors.New("count must be a positive number"))
+		}
+
+		limit = n
+		if limit > nukesMaxLimit {
+			limit = nukesMaxLimit
+		}
+	}
+
+	records, err := s.Nukes().Latest(limit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(records) == 0 {
+		return s.ReplyWithMessage(StatusOK, "No nukes found.")
+	}
+
+	var b strings.Builder
+
+	for _, r := range records {
+		fmt.Fprintf(&b, "%s %s x%d by %s: %s\n", formatTime(r.Time), r.Path, r.Multiplier, r.Nuker, r.Reason)
+
+		names := make([]string, 0, len(r.Nukees))
+		for _, e := range r.Nukees {
+			names = append(names, fmt.Sprintf("%s (%s)", e.User, cookie.FormatBytes(e.Bytes)))
+		}
+
+		if len(names) > 0 {
+			fmt.Fprintf(&b, "  %s\n", strings.Join(names, ", "))
+		}
+	}
+
+	fmt.Fprintf(&b, "Found %d nuke(s).", len(records))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+func init() {
+	SiteCommandMap["NUKE"] = &siteCommandNUKE{}
+	SiteCommandMap["UNNUKE"] = &siteCommandUNNUKE{}
+	SiteCommandMap["NUKES"] = &siteCommandNUKES{}
+}
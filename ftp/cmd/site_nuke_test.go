@@ -0,0 +1,127 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteNukeCredits(t *testing.T) {
+	var tests = []struct {
+		name       string
+		ratio      int
+		flag       string
+		multiplier string
+		expect     int
+		after      int64
+	}{
+		{"charged", 3, "", "2", 200, 40},
+		{"leech flag", 3, acl.FlagLeech, "2", 200, 100},
+		{"no ratio", 0, "", "2", 200, 100},
+		{"zero multiplier", 3, "", "0", 550, 100},
+		{"negative multiplier", 3, "", "-1", 550, 100},
+		{"bad multiplier", 3, "", "x", 550, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := ftptest.NewMemoryFS("download /** *", "upload /** *", "makedir /** *")
+			if err != nil {
+				t.Fatalf("unexpected error creating fs: %s", err)
+			}
+
+			st, err := ftptest.NewMemoryStats()
+			if err != nil {
+				t.Fatalf("unexpected error creating stats: %s", err)
+			}
+			defer st.Close()
+
+			nukes, err := ftptest.NewMemoryNukes(nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating nukes: %s", err)
+			}
+			defer nukes.Close()
+
+			auth, err := ftptest.NewMemoryAuthenticator(nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating authenticator: %s", err)
+			}
+			defer auth.Close()
+
+			if _, err := auth.AddUser("someone", "password"); err != nil {
+				t.Fatalf("unexpected error adding user: %s", err)
+			}
+
+			uploader, err := auth.AddUser("uploader", "password")
+			if err != nil {
+				t.Fatalf("unexpected error adding user: %s", err)
+			}
+
+			if err := fs.MakeDir("/release", uploader); err != nil {
+				t.Fatalf("unexpected error making dir: %s", err)
+			}
+
+			w, err := fs.UploadFile("/release/file", uploader)
+			if err != nil {
+				t.Fatalf("unexpected error uploading: %s", err)
+			}
+			w.Write([]byte("0123456789"))
+			w.Close()
+
+			err = auth.UpdateUser("uploader", func(u *acl.User) error {
+				u.Ratio = tt.ratio
+				u.Credits = 100
+				if len(tt.flag) > 0 {
+					u.AddFlag(tt.flag)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error updating user: %s", err)
+			}
+
+			s, err := ftptest.NewSession(&ftptest.SessionOpts{
+				FS:          fs,
+				Auth:        auth,
+				Stats:       st,
+				Nukes:       nukes,
+				TLS:         true,
+				Permissions: []string{"nuke *"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error creating session: %s", err)
+			}
+
+			if err := s.LoginAs("someone"); err != nil {
+				t.Fatalf("unexpected error logging in: %s", err)
+			}
+
+			err = s.Converse(context.Background(),
+				ftptest.Step{Send: "SITE NUKE /release " + tt.multiplier + " bad", Expect: tt.expect},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := auth.GetUser("uploader")
+			if err != nil {
+				t.Fatalf("unexpected error getting user: %s", err)
+			}
+
+			if u.Credits != tt.after {
+				t.Fatalf("expected %d credits got %d", tt.after, u.Credits)
+			}
+
+			r, err := nukes.Get("/release")
+			if err != nil {
+				t.Fatalf("unexpected error getting nuke: %s", err)
+			}
+
+			if (r != nil) != (tt.expect == 200) {
+				t.Fatalf("unexpected nuke record: %+v", r)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE OTP <enable|confirm|disable|reset> [<code|user>]
+
+	Manages TOTP two factor authentication for the current user. Once
+	enabled and confirmed the code must be appended to the password when
+	logging in.
+
+		enable           generates a new secret
+		confirm <code>   confirms the secret and requires it at login
+		disable <code>   removes the secret
+		reset <user>     removes another user's secret, requires the
+		                 `otp` site permission
+*/
+
+type siteCommandOTP struct{}
+
+func (c siteCommandOTP) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandOTP) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE OTP <enable|confirm|disable|reset> [<code|user>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	action := strings.ToLower(params[0])
+
+	if action == "enable" {
+		return c.enable(s, user)
+	}
+
+	if len(params) != 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE OTP <enable|confirm|disable|reset> [<code|user>]")
+	}
+
+	switch action {
+	case "confirm":
+		if len(user.TOTPSecret) == 0 {
+			return s.ReplyWithMessage(StatusActionNotOK, "Use SITE OTP enable first.")
+		}
+
+		if !s.Auth().CheckTOTP(user.Name, params[1]) {
+			return s.ReplyWithMessage(StatusActionNotOK, "Invalid code.")
+		}
+
+		if err := s.Auth().AddFlag(user.Name, acl.FlagTOTP); err != nil && err != acl.ErrFlagExists {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, "OTP enabled, append the code to your password when logging in.")
+
+	case "disable":
+		if !s.Auth().CheckTOTP(user.Name, params[1]) {
+			return s.ReplyWithMessage(StatusActionNotOK, "Invalid code.")
+		}
+
+		if err := s.Auth().UpdateUser(user.Name, clearTOTP); err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, "OTP disabled.")
+
+	case "reset":
+		if !s.CommandAllowed("otp", user) {
+			return s.ReplyStatus(StatusPermissionDenied)
+		}
+
+		if err := s.Auth().UpdateUser(params[1], clearTOTP); err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		return s.ReplyWithMessage(StatusOK, fmt.Sprintf("OTP reset for %s.", params[1]))
+	}
+
+	return s.ReplyWithMessage(StatusParameterNotImplemented, fmt.Sprintf("Unknown action '%s'.", action))
+}
+
+// enable generates a new secret for the user, it isn't required at login
+// until it has been confirmed
+func (c siteCommandOTP) enable(s Session, user *acl.User) error {
+	if user.HasFlag(acl.FlagTOTP) {
+		return s.ReplyWithMessage(StatusActionNotOK, "OTP already enabled, use SITE OTP disable first.")
+	}
+
+	secret, err := acl.GenerateTOTPSecret()
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, errors.WithMessage(err, "unable to generate secret"))
+	}
+
+	err = s.Auth().UpdateUser(user.Name, func(u *acl.User) error {
+		u.TOTPSecret = secret
+		u.TOTPLastStep = 0
+		return nil
+	})
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf(
+		"Secret: %s\nURI: %s\nConfirm with SITE OTP confirm <code>.",
+		secret,
+		acl.TOTPURI(s.SiteName(), user.Name, secret),
+	))
+}
+
+// clearTOTP removes the User's TOTP secret
+func clearTOTP(u *acl.User) error {
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RemoveFlag(acl.FlagTOTP)
+	return nil
+}
+
+func init() {
+	SiteCommandMap["OTP"] = &siteCommandOTP{}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/pre"
+)
+
+/*
+	SITE PRE <dir> <section>
+
+	Pres a release prepared in the pre area of one of your groups, moving
+	it in to the section and giving it to the group. The pre time is
+	recorded and the release is announced. Requires the `pre` site
+	permission and being a member of the group that owns the pre area.
+*/
+
+type siteCommandPRE struct{}
+
+func (c siteCommandPRE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandPRE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 2 {
+		sections := strings.Join(s.Pre().Sections(), ", ")
+		return s.ReplyWithMessage(StatusSyntaxError, fmt.Sprintf("Usage: SITE PRE <dir> <section>\nSections: %s", sections))
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("pre", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	path := s.FS().Join(s.CWD(), params[:1])
+
+	groups := make([]string, 0, len(user.Groups))
+	for g := range user.Groups {
+		groups = append(groups, g)
+	}
+
+	group, err := s.Pre().Group(path, groups)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	section, dest, err := s.Pre().Destination(path, params[1])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	name := filepath.Base(path)
+
+	existing, err := s.Pre().Get(name)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if existing != nil {
+		return s.ReplyError(StatusActionNotOK, pre.ErrAlreadyPred)
+	}
+
+	uploaders, err := s.FS().Uploaders(path, user)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	r := pre.Release{
+		Name:    name,
+		Section: section,
+		Path:    dest,
+		User:    user.Name,
+		Group:   group,
+	}
+
+	for _, u := range uploaders {
+		r.Files += u.Files
+		r.Bytes += u.Bytes
+	}
+
+	if err := s.FS().MoveTree(path, dest, user); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	r.Time = time.Now()
+
+	if _, err := s.FS().ChangeOwner(dest, "", group, true, user); err != nil {
+		s.Logger().Error("unable to give pre to group", logging.Path(dest), logging.F("group", group), logging.Err(err))
+	}
+
+	if err := s.Pre().Record(r); err != nil {
+		s.Logger().Error("unable to record pre", logging.Path(dest), logging.Err(err))
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Pred %s to %s, %d file(s) totalling %s.", name, dest, r.Files, cookie.FormatBytes(r.Bytes)))
+}
+
+func init() {
+	SiteCommandMap["PRE"] = &siteCommandPRE{}
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+/*
+	SITE PURGE <user>
+
+	Permanently removes a deleted user, any files they own are reassigned
+	to the orphan user. Requires the `purge` site permission.
+*/
+
+type siteCommandPURGE struct{}
+
+func (c siteCommandPURGE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandPURGE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE PURGE <user>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("purge", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	// only deleted users can be purged
+	u, err := s.Auth().GetUser(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if !u.Deleted() {
+		return s.ReplyError(StatusActionNotOK, acl.ErrUserNotDeleted)
+	}
+
+	if err := s.Auth().PurgeUser(u.Name); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Purged '%s'.", u.Name))
+}
+
+func init() {
+	SiteCommandMap["PURGE"] = &siteCommandPURGE{}
+}
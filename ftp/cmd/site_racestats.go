@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/race"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE RACESTATS [<release>]
+
+	Shows who has uploaded to the release, the current directory by
+	default, and how fast, ranked by users and by groups along with how
+	much of the release is done. Only releases with an sfv are raced.
+	Requires the `racestats` site permission.
+*/
+
+type siteCommandRACESTATS struct{}
+
+func (c siteCommandRACESTATS) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandRACESTATS) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("racestats", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	path := s.CWD()
+	if len(params) > 0 {
+		path = s.FS().Join(s.CWD(), params)
+	}
+
+	release, err := s.Race().Get(path)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if release == nil {
+		return s.ReplyError(StatusActionNotOK, errors.Errorf("no race stats for '%s'", path))
+	}
+
+	return s.ReplyWithMessage(StatusOK, formatRace(release))
+}
+
+// formatRace renders the standings of a release
+func formatRace(release *race.Release) string {
+	var b strings.Builder
+
+	status := fmt.Sprintf("%d%% of %d files", release.Percent(), release.Total)
+	if release.Complete() {
+		status = "complete"
+	}
+
+	fmt.Fprintf(&b, "Race stats for %s (%s)\n", release.Name(), status)
+	fmt.Fprintf(&b, "%s in %d files over %s at %s\n",
+		cookie.FormatBytes(release.Bytes),
+		release.Files,
+		cookie.FormatAge(release.Elapsed().Round(time.Second)),
+		cookie.FormatSpeed(release.Speed()),
+	)
+
+	fmt.Fprintf(&b, "%-4s %-20s %10s %6s %4s %12s\n", "#", "User", "Up", "Files", "%", "Speed")
+
+	for i, u := range release.Users() {
+		fmt.Fprintf(&b, "%-4d %-20s %10s %6d %3d%% %12s\n", i+1, u.Name+"/"+u.Group, cookie.FormatBytes(u.Bytes), u.Files, u.Percent(*release), cookie.FormatSpeed(u.Speed()))
+	}
+
+	fmt.Fprintf(&b, "%-4s %-20s %10s %6s %4s\n", "#", "Group", "Up", "Files", "%")
+
+	for i, g := range release.Groups() {
+		fmt.Fprintf(&b, "%-4d %-20s %10s %6d %3d%%\n", i+1, g.Name, cookie.FormatBytes(g.Bytes), g.Files, g.Percent(*release))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func init() {
+	SiteCommandMap["RACESTATS"] = &siteCommandRACESTATS{}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+	SITE READD <user>
+
+	Restores a deleted user, allowing them to login again. Requires the
+	`readd` site permission.
+*/
+
+type siteCommandREADD struct{}
+
+func (c siteCommandREADD) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandREADD) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE READD <user>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("readd", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if err := s.Auth().ReaddUser(params[0]); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Readded '%s'.", params[0]))
+}
+
+func init() {
+	SiteCommandMap["READD"] = &siteCommandREADD{}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// default number of results returned by SITE SEARCH
+	searchDefaultLimit = 20
+	// most results SITE SEARCH and SITE DUPE can be asked for
+	searchMaxLimit = 500
+)
+
+/*
+	SITE SEARCH [-<limit>] <pattern>
+
+	Searches the dirlog for releases with names matching the glob pattern,
+	a pattern without wildcards matches anywhere in the name. Shows where
+	each release lives, newest first. Requires the `search` site
+	permission.
+*/
+
+type siteCommandSEARCH struct{}
+
+func (c siteCommandSEARCH) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandSEARCH) Execute(ctx context.Context, s Session, params []string) error {
+	limit, params, err := parseLimit(params, searchDefaultLimit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE SEARCH [-<limit>] <pattern>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("search", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	pattern := strings.Join(params, " ")
+
+	entries, err := s.Dirlog().Search(pattern, limit)
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if len(entries) == 0 {
+		return s.ReplyWithMessage(StatusOK, "No releases found.")
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Releases matching '%s':\n", pattern)
+
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s [%s] %s by %s/%s\n", formatTime(e.Time), e.Section, e.Path, e.User, e.Group)
+	}
+
+	fmt.Fprintf(&b, "Found %d release(s).", len(entries))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+// parseLimit takes an optional leading -<limit> off the params, i.e.
+// `-10 pattern`, returning def if there isn't one. Limits are capped at
+// searchMaxLimit
+func parseLimit(params []string, def int) (int, []string, error) {
+	if len(params) == 0 || !strings.HasPrefix(params[0], "-") {
+		return def, params, nil
+	}
+
+	limit, err := strconv.Atoi(params[0][1:])
+	if err != nil || limit < 1 {
+		return 0, nil, errors.New("limit must be a positive number")
+	}
+
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	return limit, params[1:], nil
+}
+
+func init() {
+	SiteCommandMap["SEARCH"] = &siteCommandSEARCH{}
+}
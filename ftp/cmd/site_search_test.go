@@ -0,0 +1,117 @@
+package cmd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteSearch(t *testing.T) {
+	var tests = []struct {
+		send        string
+		permissions []string
+		expect      int
+		contains    []string
+	}{
+		{"SITE SEARCH", []string{"search *"}, 501, nil},
+		{"SITE SEARCH -5", []string{"search *"}, 501, nil},
+		{"SITE SEARCH -0 album", []string{"search *"}, 550, nil},
+		{"SITE SEARCH -many album", []string{"search *"}, 550, nil},
+		{"SITE SEARCH album", nil, 530, nil},
+		{"SITE SEARCH album", []string{"search =staff"}, 530, nil},
+		{"SITE SEARCH nothing", []string{"search *"}, 200, []string{"No releases found."}},
+		{
+			"SITE SEARCH album",
+			[]string{"search *"},
+			200,
+			[]string{"Releases matching 'album':", "[mp3] /mp3/Artist-Album-2020 by someone/staff", "Found 1 release(s)."},
+		},
+		{"SITE SEARCH *-*", []string{"search *"}, 200, []string{"Found 2 release(s)."}},
+		{"SITE SEARCH -1 *-*", []string{"search *"}, 200, []string{"Found 1 release(s)."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			releases, err := ftptest.NewMemoryDirlog("mp3 /mp3/*")
+			if err != nil {
+				t.Fatalf("unexpected error creating dirlog: %s", err)
+			}
+			defer releases.Close()
+
+			for _, path := range []string{"/mp3/Artist-Album-2020", "/mp3/Other-Single-2021"} {
+				if _, err := releases.Record(path, "someone", "staff"); err != nil {
+					t.Fatalf("unexpected error recording '%s': %s", path, err)
+				}
+			}
+
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: tt.permissions, Dirlog: releases}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, _ := s.LastReply()
+
+			for _, c := range tt.contains {
+				if !strings.Contains(reply.Message, c) {
+					t.Fatalf("expected '%s' in '%s'", c, reply.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteDupe(t *testing.T) {
+	var tests = []struct {
+		send        string
+		permissions []string
+		expect      int
+		contains    []string
+	}{
+		{"SITE DUPE", []string{"dupe *"}, 501, nil},
+		{"SITE DUPE -5", []string{"dupe *"}, 501, nil},
+		{"SITE DUPE -0 file", []string{"dupe *"}, 550, nil},
+		{"SITE DUPE file", nil, 530, nil},
+		{"SITE DUPE nothing", []string{"dupe *"}, 200, []string{"No dupes found."}},
+		{"SITE DUPE one", []string{"dupe *"}, 200, []string{"Dupes matching 'one':", "someone /a/file-one.txt", "Found 1 dupe(s)."}},
+		{"SITE DUPE file", []string{"dupe *"}, 200, []string{"Found 2 dupe(s)."}},
+		{"SITE DUPE -1 file", []string{"dupe *"}, 200, []string{"Found 1 dupe(s)."}},
+		{"SITE UNDUPE", []string{"undupe *"}, 501, nil},
+		{"SITE UNDUPE *one*", []string{"dupe *"}, 530, nil},
+		{"SITE UNDUPE *one*", []string{"undupe *"}, 200, []string{"Removed 1 dupe(s)."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			dupes, err := ftptest.NewMemoryDupe()
+			if err != nil {
+				t.Fatalf("unexpected error creating dupe: %s", err)
+			}
+			defer dupes.Close()
+
+			for _, path := range []string{"/a/file-one.txt", "/b/file-two.txt"} {
+				if err := dupes.Record(path, "someone", false); err != nil {
+					t.Fatalf("unexpected error recording '%s': %s", path, err)
+				}
+			}
+
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: tt.permissions, Dupe: dupes}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, _ := s.LastReply()
+
+			for _, c := range tt.contains {
+				if !strings.Contains(reply.Message, c) {
+					t.Fatalf("expected '%s' in '%s'", c, reply.Message)
+				}
+			}
+		})
+	}
+}
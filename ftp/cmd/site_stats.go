@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/pkg/errors"
+)
+
+/*
+	SITE STATS [<user>] [day|week|month|alltime]
+
+	Shows a user's ratio and credits along with what they have uploaded and
+	downloaded in each section for the period, alltime by default. With no
+	user shows the current user. Showing other users requires the `stats`
+	site permission.
+*/
+
+type siteCommandSTATS struct{}
+
+func (c siteCommandSTATS) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandSTATS) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE STATS [<user>] [day|week|month|alltime]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	period := stats.Period(stats.PeriodAllTime)
+
+	// the period can be given without a user
+	if len(params) > 0 {
+		if p, ok := parsePeriod(params[len(params)-1]); ok {
+			period = p
+			params = params[:len(params)-1]
+		} else if len(params) == 2 {
+			return s.ReplyError(StatusActionNotOK, errors.Errorf("unknown period '%s'", params[1]))
+		}
+	}
+
+	target := user
+
+	if len(params) == 1 && !strings.EqualFold(params[0], user.Name) {
+		if !s.CommandAllowed("stats", user) {
+			return s.ReplyStatus(StatusPermissionDenied)
+		}
+
+		var err error
+		target, err = s.Auth().GetUser(params[0])
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+	}
+
+	sections := append(s.Stats().Sections(), stats.DefaultSection, stats.AllSections)
+
+	now := time.Now()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Stats for %s (%s)\n", target.Name, period)
+	fmt.Fprintf(&b, "Ratio: %s Credits: %s\n", cookie.FormatRatio(target), cookie.FormatBytes(target.Credits))
+	fmt.Fprintf(&b, "%-10s %12s %8s %12s %8s\n", "Section", "Up", "Files", "Down", "Files")
+
+	for _, section := range sections {
+		totals, err := s.Stats().Get(target.Name, section, period, now)
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+
+		// sections without any transfers are left out, apart from the total
+		if section != stats.AllSections && totals == (stats.Totals{}) {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%-10s %12s %8d %12s %8d\n", section, cookie.FormatBytes(totals.UploadBytes), totals.UploadFiles, cookie.FormatBytes(totals.DownloadBytes), totals.DownloadFiles)
+	}
+
+	return s.ReplyWithMessage(StatusOK, strings.TrimSuffix(b.String(), "\n"))
+}
+
+// parsePeriod returns the stats.Period with the name
+func parsePeriod(name string) (stats.Period, bool) {
+	for _, p := range stats.Periods {
+		if strings.EqualFold(string(p), name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func init() {
+	SiteCommandMap["STATS"] = &siteCommandSTATS{}
+}
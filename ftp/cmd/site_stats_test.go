@@ -0,0 +1,152 @@
+package cmd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftptest"
+	"github.com/goftpd/goftpd/stats"
+)
+
+// newStatsSession returns a session logged in as someone with the
+// permissions along with other, who has uploaded 2KB at 1:3 and has a
+// comment
+func newStatsSession(t *testing.T, permissions []string) (*ftptest.Session, func()) {
+	t.Helper()
+
+	st, err := ftptest.NewMemoryStats()
+	if err != nil {
+		t.Fatalf("unexpected error creating stats: %s", err)
+	}
+
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{Permissions: permissions, Stats: st}, nil)
+
+	if _, err := s.Auth().AddUser("other", "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	err = s.Auth().UpdateUser("other", func(u *acl.User) error {
+		u.Comment = "trusted"
+		u.Ratio = 3
+		u.Credits = 6144
+		_, err := u.AddIP("*@10.0.0.1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating user: %s", err)
+	}
+
+	if err := st.Record("other", "/file.txt", stats.Upload, 2048); err != nil {
+		t.Fatalf("unexpected error recording stats: %s", err)
+	}
+
+	return s, func() {
+		done()
+		st.Close()
+	}
+}
+
+func TestSiteStats(t *testing.T) {
+	var tests = []struct {
+		send        string
+		permissions []string
+		expect      int
+		contains    []string
+	}{
+		{"SITE STATS other day extra", nil, 501, nil},
+		{"SITE STATS other yesterday", []string{"stats *"}, 550, nil},
+		{"SITE STATS other", nil, 530, nil},
+		{"SITE STATS other", []string{"stats =staff"}, 530, nil},
+		{"SITE STATS missing", []string{"stats *"}, 550, nil},
+		{"SITE STATS", nil, 200, []string{"Stats for someone (alltime)"}},
+		{"SITE STATS week", nil, 200, []string{"Stats for someone (week)"}},
+		{"SITE STATS SOMEONE day", nil, 200, []string{"Stats for someone (day)"}},
+		{
+			"SITE STATS other",
+			[]string{"stats *"},
+			200,
+			[]string{
+				"Stats for other (alltime)",
+				"Ratio: 1:3 Credits: 6.0KB",
+				"default           2.0KB        1           0B        0",
+				"all               2.0KB        1           0B        0",
+			},
+		},
+		{"SITE STATS other month", []string{"stats *"}, 200, []string{"Stats for other (month)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			s, done := newStatsSession(t, tt.permissions)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, _ := s.LastReply()
+
+			for _, c := range tt.contains {
+				if !strings.Contains(reply.Message, c) {
+					t.Fatalf("expected '%s' in '%s'", c, reply.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteUser(t *testing.T) {
+	var tests = []struct {
+		send        string
+		permissions []string
+		expect      int
+		contains    []string
+		excludes    []string
+	}{
+		{"SITE USER other extra", nil, 501, nil, nil},
+		{"SITE USER other", nil, 530, nil, nil},
+		{"SITE USER missing", []string{"user *"}, 550, nil, nil},
+		{"SITE USER", nil, 200, []string{"User: someone"}, nil},
+		{
+			"SITE USER other",
+			[]string{"user *"},
+			200,
+			[]string{"User: other", "Credits: 6.0KB", "Ratio: 1:3", "IPs:\n *@10.0.0.1\n", "Uploaded: 2.0KB in 1 file(s)", "Downloaded: 0B in 0 file(s)"},
+			[]string{"Comment: trusted"},
+		},
+		{
+			"SITE USER other",
+			[]string{"user *", "change_comment *"},
+			200,
+			[]string{"Comment: trusted"},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			s, done := newStatsSession(t, tt.permissions)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, _ := s.LastReply()
+
+			for _, c := range tt.contains {
+				if !strings.Contains(reply.Message, c) {
+					t.Fatalf("expected '%s' in '%s'", c, reply.Message)
+				}
+			}
+
+			for _, c := range tt.excludes {
+				if strings.Contains(reply.Message, c) {
+					t.Fatalf("expected no '%s' in '%s'", c, reply.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+)
+
+/*
+	SITE SYMLINK <target> <link>
+
+	Creates a symbolic link at link pointing to target. A relative target
+	is relative to the directory holding the link. Who may create links is
+	controlled by the `symlink` acl scope rather than a site permission.
+*/
+
+type siteCommandSYMLINK struct{}
+
+func (c siteCommandSYMLINK) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandSYMLINK) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) < 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE SYMLINK <target> <link>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	link := s.FS().Join(s.CWD(), params[1:])
+
+	if err := s.FS().CreateSymlink(params[0], link, user); err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, "Link created.")
+}
+
+func init() {
+	SiteCommandMap["SYMLINK"] = &siteCommandSYMLINK{}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+/*
+	SITE TAGLINE <text|none>
+
+	Sets your tagline, shown next to you in SITE WHO and SITE USER and
+	available to messages as the %[tagline] cookie. `none` clears it.
+	Requires the `tagline` site permission.
+*/
+
+type siteCommandTAGLINE struct{}
+
+func (c siteCommandTAGLINE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandTAGLINE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE TAGLINE <text|none>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if s.Anonymous() || !s.CommandAllowed("tagline", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	err := s.Auth().UpdateUser(user.Name, func(u *acl.User) error {
+		return changeTagline(u, params)
+	})
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Tagline changed for %s.", user.Name))
+}
+
+func init() {
+	SiteCommandMap["TAGLINE"] = &siteCommandTAGLINE{}
+}
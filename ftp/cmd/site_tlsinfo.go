@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+/*
+	SITE TLSINFO
+
+	Shows the TLS version, cipher suite and ALPN protocol negotiated by the
+	session's control connection, whether it was resumed, the client
+	certificate if one was given and whether the data connections are
+	protected.
+*/
+
+// tlsVersionName returns the name of a TLS version
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// yesNo returns yes or no
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+type siteCommandTLSINFO struct{}
+
+func (c siteCommandTLSINFO) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandTLSINFO) Execute(ctx context.Context, s Session, params []string) error {
+	state, ok := s.TLSState()
+	if !ok {
+		return s.ReplyWithMessage(StatusActionNotOK, "The control connection isn't using TLS.")
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Version: %s\n", tlsVersionName(state.Version))
+	fmt.Fprintf(&b, "Cipher: %s\n", tls.CipherSuiteName(state.CipherSuite))
+
+	alpn := state.NegotiatedProtocol
+	if len(alpn) == 0 {
+		alpn = "none"
+	}
+	fmt.Fprintf(&b, "ALPN: %s\n", alpn)
+
+	if len(state.ServerName) > 0 {
+		fmt.Fprintf(&b, "Server name: %s\n", state.ServerName)
+	}
+
+	fmt.Fprintf(&b, "Resumed: %s\n", yesNo(state.DidResume))
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		fmt.Fprintf(&b, "Client certificate: %s (verified: %s)\n", cert.Subject, yesNo(len(state.VerifiedChains) > 0))
+	} else {
+		b.WriteString("Client certificate: none\n")
+	}
+
+	fmt.Fprintf(&b, "Data protected: %s", yesNo(s.DataProtected()))
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+func init() {
+	SiteCommandMap["TLSINFO"] = &siteCommandTLSINFO{}
+}
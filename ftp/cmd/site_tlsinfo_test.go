@@ -0,0 +1,81 @@
+package cmd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteTLSInfo(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, nil)
+	defer done()
+
+	err := s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE TLSINFO", Expect: 200},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Version: TLS 1.3\n" +
+		"Cipher: TLS_AES_128_GCM_SHA256\n" +
+		"ALPN: none\n" +
+		"Resumed: no\n" +
+		"Client certificate: none\n" +
+		"Data protected: no"
+
+	if reply, _ := s.LastReply(); reply.Message != expected {
+		t.Fatalf("expected '%s' got '%s'", expected, reply.Message)
+	}
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "PBSZ 0", Expect: 200},
+		ftptest.Step{Send: "PROT P", Expect: 200},
+		ftptest.Step{Send: "SITE TLSINFO", Expect: 200},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, _ := s.LastReply(); !strings.HasSuffix(reply.Message, "Data protected: yes") {
+		t.Fatalf("expected the data connections to be protected got '%s'", reply.Message)
+	}
+}
+
+func TestSiteTLSInfoNotLoggedIn(t *testing.T) {
+	s, err := ftptest.NewSession(&ftptest.SessionOpts{TLS: true})
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE TLSINFO", Expect: 530}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSiteTLSInfoPlaintext(t *testing.T) {
+	auth, err := ftptest.NewMemoryAuthenticator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating authenticator: %s", err)
+	}
+	defer auth.Close()
+
+	if _, err := auth.AddUser("someone", "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	s, err := ftptest.NewSession(&ftptest.SessionOpts{Auth: auth})
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	if err := s.LoginAs("someone"); err != nil {
+		t.Fatalf("unexpected error logging in: %s", err)
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE TLSINFO", Expect: 550}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+/*
+	SITE TRACE <user|ip> [on|off]
+
+	Logs every line sent and received on the control connections of the
+	sessions logged in as the user or connected from the ip, including
+	sessions that haven't logged in yet. The passwords of PASS and SITE
+	commands are masked. Turns tracing on unless off is given, it ends
+	when the session disconnects. The server's trace option traces every
+	session. Requires the `trace` site permission.
+*/
+
+type siteCommandTRACE struct{}
+
+func (c siteCommandTRACE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandTRACE) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("trace", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	if len(params) == 0 || len(params) > 2 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE TRACE <user|ip> [on|off]")
+	}
+
+	on := true
+
+	if len(params) == 2 {
+		switch strings.ToLower(params[1]) {
+		case "on":
+		case "off":
+			on = false
+		default:
+			return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE TRACE <user|ip> [on|off]")
+		}
+	}
+
+	target := params[0]
+
+	n := s.Trace(func(info SessionInfo) bool {
+		return (info.Login == target && !info.Anonymous) || info.IP == target
+	}, on)
+
+	state := "off"
+	if on {
+		state = "on"
+	}
+
+	s.Logger().Info("turned tracing "+state, logging.F("target", target), logging.F("sessions", n))
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Tracing turned %s for %d session(s) of %s.", state, n, target))
+}
+
+func init() {
+	SiteCommandMap["TRACE"] = &siteCommandTRACE{}
+}
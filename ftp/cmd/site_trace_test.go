@@ -0,0 +1,57 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteTrace(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{
+		Permissions: []string{"trace *"},
+		Sessions: []cmd.SessionInfo{
+			{Login: "other", IP: "10.0.0.1"},
+			{IP: "10.0.0.2"},
+		},
+	}, nil)
+	defer done()
+
+	err := s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE TRACE", Expect: 501},
+		ftptest.Step{Send: "SITE TRACE other maybe", Expect: 501},
+		ftptest.Step{Send: "SITE TRACE other", Expect: 200},
+		ftptest.Step{Send: "SITE TRACE 10.0.0.2 on", Expect: 200},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traced := func() []bool {
+		var flags []bool
+		for _, info := range s.Sessions() {
+			flags = append(flags, info.Traced)
+		}
+		return flags
+	}
+
+	if got := traced(); got[0] || !got[1] || !got[2] {
+		t.Fatalf("unexpected traced sessions: %v", got)
+	}
+
+	err = s.Converse(context.Background(),
+		ftptest.Step{Send: "SITE TRACE other off", Expect: 200},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := traced(); got[1] || !got[2] {
+		t.Fatalf("unexpected traced sessions: %v", got)
+	}
+
+	if reply, _ := s.LastReply(); reply.Message != "Tracing turned off for 1 session(s) of other." {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+}
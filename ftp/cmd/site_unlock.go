@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+	SITE UNLOCK <user|ip>
+
+	Clears the failed logins of a user or ip, removing any lockout.
+	Requires the `unlock` site permission.
+*/
+
+type siteCommandUNLOCK struct{}
+
+func (c siteCommandUNLOCK) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUNLOCK) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) != 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE UNLOCK <user|ip>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("unlock", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	found, err := s.Auth().Unlock(params[0])
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	if !found {
+		return s.ReplyWithMessage(StatusActionNotOK, fmt.Sprintf("No failed logins for '%s'.", params[0]))
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Unlocked '%s'.", params[0]))
+}
+
+func init() {
+	SiteCommandMap["UNLOCK"] = &siteCommandUNLOCK{}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/stats"
+)
+
+/*
+	SITE USER [<user>]
+
+	Shows details about a user, with no parameters shows the current user.
+	Showing other users requires the `user` site permission. The staff
+	comment is only shown to those who can change it.
+*/
+
+type siteCommandUSER struct{}
+
+func (c siteCommandUSER) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandUSER) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) > 1 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE USER [<user>]")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	target := user
+
+	if len(params) == 1 && !strings.EqualFold(params[0], user.Name) {
+		if !s.CommandAllowed("user", user) {
+			return s.ReplyStatus(StatusPermissionDenied)
+		}
+
+		var err error
+		target, err = s.Auth().GetUser(params[0])
+		if err != nil {
+			return s.ReplyError(StatusActionNotOK, err)
+		}
+	}
+
+	staff := s.CommandAllowed("change", user) || s.CommandAllowed("change_comment", user)
+
+	message := c.format(target, staff)
+
+	// transfers are shown as a summary, SITE STATS breaks them down
+	if totals, err := s.Stats().Get(target.Name, stats.AllSections, stats.PeriodAllTime, time.Now()); err == nil {
+		message += fmt.Sprintf("\nUploaded: %s in %d file(s)", cookie.FormatBytes(totals.UploadBytes), totals.UploadFiles)
+		message += fmt.Sprintf("\nDownloaded: %s in %d file(s)", cookie.FormatBytes(totals.DownloadBytes), totals.DownloadFiles)
+	}
+
+	return s.ReplyWithMessage(StatusOK, message)
+}
+
+// format renders the details of the User, staff also see the comment
+func (c siteCommandUSER) format(u *acl.User, staff bool) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "User: %s\n", u.Name)
+	fmt.Fprintf(&b, "Created: %s\n", formatTime(u.CreatedAt))
+	fmt.Fprintf(&b, "Expires: %s\n", formatTime(u.ExpiresAt))
+
+	if u.Deleted() {
+		fmt.Fprintf(&b, "Deleted: %s\n", formatTime(u.DeletedAt))
+	}
+
+	fmt.Fprintf(&b, "Logins: %d\n", u.Logins)
+	fmt.Fprintf(&b, "Credits: %s\n", cookie.FormatBytes(u.Credits))
+	fmt.Fprintf(&b, "Ratio: %s\n", cookie.FormatRatio(u))
+
+	if len(u.Groups) > 0 {
+		fmt.Fprintf(&b, "Groups: %s\n", formatGroups(u))
+	}
+
+	if len(u.Flags) > 0 {
+		flags := make([]string, 0, len(u.Flags))
+		for f := range u.Flags {
+			flags = append(flags, f)
+		}
+
+		sort.Strings(flags)
+
+		fmt.Fprintf(&b, "Flags: %s\n", strings.Join(flags, " "))
+	}
+
+	if u.HasFlag(acl.FlagJail) {
+		fmt.Fprintf(&b, "Home: %s (jailed)\n", u.Home())
+	} else {
+		fmt.Fprintf(&b, "Home: %s\n", u.Home())
+	}
+
+	if u.IdleTime > 0 {
+		fmt.Fprintf(&b, "Idle Time: %ds\n", u.IdleTime)
+	}
+
+	if len(u.Template) > 0 {
+		fmt.Fprintf(&b, "Template: %s\n", u.Template)
+	}
+
+	if len(u.Tagline) > 0 {
+		fmt.Fprintf(&b, "Tagline: %s\n", u.Tagline)
+	}
+
+	if staff && len(u.Comment) > 0 {
+		fmt.Fprintf(&b, "Comment: %s\n", u.Comment)
+	}
+
+	if u.MaxLogins > 0 {
+		fmt.Fprintf(&b, "Max Logins: %d\n", u.MaxLogins)
+	}
+
+	if u.MaxUploads > 0 {
+		fmt.Fprintf(&b, "Max Uploads: %d\n", u.MaxUploads)
+	}
+
+	if u.MaxDownloads > 0 {
+		fmt.Fprintf(&b, "Max Downloads: %d\n", u.MaxDownloads)
+	}
+
+	if len(u.IPs) > 0 {
+		masks := make([]string, 0, len(u.IPs))
+		for mask := range u.IPs {
+			masks = append(masks, mask)
+		}
+
+		sort.Strings(masks)
+
+		b.WriteString("IPs:\n")
+		for _, mask := range masks {
+			fmt.Fprintf(&b, " %s\n", mask)
+		}
+	}
+
+	if l, ok := u.LastLogin(); ok {
+		fmt.Fprintf(&b, "Last Seen: %s from %s\n", formatTime(l.At), formatLoginAddr(l))
+	} else {
+		b.WriteString("Last Seen: Never\n")
+	}
+
+	if len(u.LoginHistory) > 0 {
+		b.WriteString("Recent Logins:\n")
+		for _, l := range u.LoginHistory {
+			fmt.Fprintf(&b, " %s %s\n", formatTime(l.At), formatLoginAddr(l))
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatGroups renders the User's groups, the primary group first and
+// groups they are an admin of marked with a *
+func formatGroups(u *acl.User) string {
+	names := make([]string, 0, len(u.Groups))
+
+	for g := range u.Groups {
+		if g != u.PrimaryGroup {
+			names = append(names, g)
+		}
+	}
+
+	sort.Strings(names)
+
+	if _, ok := u.Groups[u.PrimaryGroup]; ok {
+		names = append([]string{u.PrimaryGroup}, names...)
+	}
+
+	for i, g := range names {
+		if u.Groups[g].IsAdmin {
+			names[i] = "*" + g
+		}
+	}
+
+	return strings.Join(names, " ")
+}
+
+// formatTime renders a time for SITE output, zero times are shown as Never
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// formatLoginAddr renders the ident@ip of a Login
+func formatLoginAddr(l acl.Login) string {
+	ident := l.Ident
+	if len(ident) == 0 {
+		ident = "*"
+	}
+	return ident + "@" + l.IP
+}
+
+func init() {
+	SiteCommandMap["USER"] = &siteCommandUSER{}
+}
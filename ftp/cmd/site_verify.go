@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+	SITE VERIFY <path>
+
+	Re-reads the file and checks it still matches the checksum recorded
+	when it was uploaded. Files without a checksum have one recorded.
+	Requires the `verify` site permission.
+*/
+
+type siteCommandVERIFY struct{}
+
+func (c siteCommandVERIFY) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandVERIFY) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE VERIFY <path>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("verify", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	sum, err := s.FS().VerifyChecksum(path, user)
+	if err != nil {
+		if err == vfs.ErrChecksumMismatch {
+			return s.ReplyWithMessage(StatusActionNotOK, fmt.Sprintf("Checksum mismatch, file is now CRC32 %08X SHA256 %x.", sum.CRC32, sum.SHA256))
+		}
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("OK, CRC32 %08X SHA256 %x.", sum.CRC32, sum.SHA256))
+}
+
+func init() {
+	SiteCommandMap["VERIFY"] = &siteCommandVERIFY{}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+)
+
+/*
+	SITE WHO
+
+	Lists the sessions connected to the server, what they are doing and how
+	fast they are transferring. Sessions in paths matched by the `hideinwho`
+	acl scope are left out. Requires the `who` site permission.
+*/
+
+// Transfer is the direction data is moving over a session's data connection
+type Transfer string
+
+const (
+	TransferNone     Transfer = ""
+	TransferUpload            = "UP"
+	TransferDownload          = "DN"
+)
+
+// SessionInfo is a snapshot of a connected session
+type SessionInfo struct {
+	// unique to the session while the server is running
+	ID uint64
+
+	// empty until the session has sent USER
+	Login     string
+	Anonymous bool
+
+	Ident string
+	IP    string
+	State SessionState
+
+	// the command being run, empty while the session is idle
+	Command string
+	CWD     string
+	// the file being transferred by RETR, STOR and the like
+	File string
+
+	Transfer Transfer
+	Bytes    int64
+	// average bytes per second since the transfer started
+	Speed float64
+
+	Connected time.Time
+	Idle      time.Duration
+
+	// the control connection is being logged, see SITE TRACE
+	Traced bool
+}
+
+// Path returns where the session is, the file being transferred if there
+// is one
+func (i SessionInfo) Path() string {
+	if len(i.File) > 0 {
+		if strings.HasPrefix(i.File, "/") {
+			return path.Clean(i.File)
+		}
+		return path.Join(i.CWD, i.File)
+	}
+	return i.CWD
+}
+
+type siteCommandWHO struct{}
+
+func (c siteCommandWHO) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandWHO) Execute(ctx context.Context, s Session, params []string) error {
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("who", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	var b strings.Builder
+
+	var shown int
+
+	for _, info := range s.Sessions() {
+		if s.FS().HideInWho(info.CWD, user) || s.FS().HideInWho(info.Path(), user) {
+			continue
+		}
+
+		b.WriteString(c.format(s, info))
+		b.WriteString("\n")
+		shown++
+	}
+
+	fmt.Fprintf(&b, "%d session(s) online.", shown)
+
+	return s.ReplyWithMessage(StatusOK, b.String())
+}
+
+// format renders a single session
+func (c siteCommandWHO) format(s Session, info SessionInfo) string {
+	var b strings.Builder
+
+	name := info.Login
+	switch {
+	case info.Anonymous:
+		name = "anonymous"
+	case info.State < SessionStateLoggedIn:
+		name = "-login-"
+	}
+
+	ident := info.Ident
+	if len(ident) == 0 {
+		ident = "*"
+	}
+
+	fmt.Fprintf(&b, "%-12s %s@%s", name, ident, info.IP)
+
+	if info.State == SessionStateLoggedIn && !info.Anonymous {
+		if u, err := s.Auth().GetUser(info.Login); err == nil {
+			if len(u.PrimaryGroup) > 0 {
+				fmt.Fprintf(&b, " [%s]", u.PrimaryGroup)
+			}
+			if len(u.Tagline) > 0 {
+				fmt.Fprintf(&b, " %s", u.Tagline)
+			}
+		}
+	}
+
+	switch {
+	case info.Transfer != TransferNone:
+		fmt.Fprintf(&b, "\n  %s %s %s at %s", info.Transfer, info.Path(), cookie.FormatBytes(info.Bytes), cookie.FormatSpeed(info.Speed))
+	case len(info.Command) > 0:
+		fmt.Fprintf(&b, "\n  %s in %s", info.Command, info.CWD)
+	default:
+		fmt.Fprintf(&b, "\n  IDLE %s in %s", info.Idle.Truncate(time.Second), info.CWD)
+	}
+
+	return b.String()
+}
+
+func init() {
+	SiteCommandMap["WHO"] = &siteCommandWHO{}
+}
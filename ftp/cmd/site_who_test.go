@@ -0,0 +1,112 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+// whoSessions are the other sessions connected in the SITE WHO tests
+var whoSessions = []cmd.SessionInfo{
+	{Login: "uploader", IP: "10.0.0.1", Ident: "up", State: cmd.SessionStateLoggedIn, CWD: "/incoming", File: "file.txt", Transfer: cmd.TransferUpload, Bytes: 2048, Speed: 1024},
+	{Login: "anonymous", Anonymous: true, IP: "10.0.0.2", State: cmd.SessionStateLoggedIn, CWD: "/pub", Idle: 90 * time.Second},
+	{Login: "hidden", IP: "10.0.0.3", State: cmd.SessionStateLoggedIn, CWD: "/staff/secret", Command: "LIST"},
+	{IP: "10.0.0.4", State: cmd.SessionStateAuth},
+}
+
+func TestSiteWho(t *testing.T) {
+	var tests = []struct {
+		name        string
+		permissions []string
+		rules       []string
+		expect      int
+		expected    string
+	}{
+		{"no permission", nil, nil, 530, ""},
+		{"not in the group", []string{"who =staff"}, nil, 530, ""},
+		{
+			"allowed",
+			[]string{"who *"},
+			nil,
+			200,
+			"someone      *@127.0.0.1\n" +
+				"  SITE WHO in /\n" +
+				"uploader     up@10.0.0.1 [uploaders] Uploading\n" +
+				"  UP /incoming/file.txt 2.0KB at 1.0KB/s\n" +
+				"anonymous    *@10.0.0.2\n" +
+				"  IDLE 1m30s in /pub\n" +
+				"hidden       *@10.0.0.3\n" +
+				"  LIST in /staff/secret\n" +
+				"-login-      *@10.0.0.4\n" +
+				"  IDLE 0s in \n" +
+				"5 session(s) online.",
+		},
+		{
+			"hidden",
+			[]string{"who *"},
+			[]string{"hideinwho /staff/** *"},
+			200,
+			"someone      *@127.0.0.1\n" +
+				"  SITE WHO in /\n" +
+				"uploader     up@10.0.0.1 [uploaders] Uploading\n" +
+				"  UP /incoming/file.txt 2.0KB at 1.0KB/s\n" +
+				"anonymous    *@10.0.0.2\n" +
+				"  IDLE 1m30s in /pub\n" +
+				"-login-      *@10.0.0.4\n" +
+				"  IDLE 0s in \n" +
+				"4 session(s) online.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := ftptest.NewMemoryFS(tt.rules...)
+			if err != nil {
+				t.Fatalf("unexpected error creating fs: %s", err)
+			}
+
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{
+				FS:          fs,
+				Permissions: tt.permissions,
+				Sessions:    whoSessions,
+			}, nil)
+			defer done()
+
+			if _, err := s.Auth().AddUser("uploader", "password"); err != nil {
+				t.Fatalf("unexpected error adding user: %s", err)
+			}
+
+			err = s.Auth().UpdateUser("uploader", func(u *acl.User) error {
+				u.PrimaryGroup = "uploaders"
+				u.Tagline = "Uploading"
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error updating user: %s", err)
+			}
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE WHO", Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+
+			if reply, _ := s.LastReply(); tt.expect == 200 && reply.Message != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, reply.Message)
+			}
+		})
+	}
+}
+
+func TestSiteWhoNotLoggedIn(t *testing.T) {
+	s, err := ftptest.NewSession(&ftptest.SessionOpts{TLS: true, Permissions: []string{"who *"}})
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE WHO", Expect: 530}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+	SITE WIPE <path>
+
+	Removes a file or directory and everything under it, ignoring the
+	delete and deleteown acls. Progress is reported as large trees are
+	removed. Requires the `wipe` site permission and matching the `wipe`
+	acl scope for the path.
+*/
+
+type siteCommandWIPE struct{}
+
+func (c siteCommandWIPE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandWIPE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusSyntaxError, "Usage: SITE WIPE <path>")
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	if !s.CommandAllowed("wipe", user) {
+		return s.ReplyStatus(StatusPermissionDenied)
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	removed, err := s.FS().Wipe(path, user, func(r vfs.Removed) {
+		// the wipe carries on even if the client has gone away
+		s.ReplyPartial(StatusOK, fmt.Sprintf("Wiped %d file(s) and %d dir(s) so far...", r.Files, r.Dirs))
+	})
+
+	if removed.Files > 0 || removed.Dirs > 0 {
+		s.Logger().Info("wiped", logging.Path(path), logging.F("files", removed.Files), logging.F("dirs", removed.Dirs), logging.Bytes(removed.Bytes))
+	}
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("Wiped %s, %d file(s) and %d dir(s) totalling %s.", path, removed.Files, removed.Dirs, cookie.FormatBytes(removed.Bytes)))
+}
+
+func init() {
+	SiteCommandMap["WIPE"] = &siteCommandWIPE{}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+/*
+	SITE XDUPE [<mode>]
+
+	Sets the X-DUPE mode of the session, with no mode shows the current
+	one. When an upload is refused as a dupe the files already in the
+	directory are listed in the reply so race clients can skip them.
+
+	Modes:
+		0 off
+		1 one file per line, lines cut at 80 characters
+		2 as many files per line as fit in 80 characters
+		3 one file per line, no limit
+		4 every file on a single line
+*/
+
+// xdupeMaxMode is the highest X-DUPE mode and xdupeLineLength the longest
+// line modes 1 and 2 send
+const (
+	xdupeMaxMode    = 4
+	xdupeLineLength = 80
+)
+
+type siteCommandXDUPE struct{}
+
+func (c siteCommandXDUPE) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c siteCommandXDUPE) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyWithMessage(StatusOK, fmt.Sprintf("X-DUPE mode is %d.", s.XDupe()))
+	}
+
+	mode, err := strconv.Atoi(params[0])
+	if len(params) > 1 || err != nil || mode < 0 || mode > xdupeMaxMode {
+		return s.ReplyWithMessage(StatusSyntaxError, fmt.Sprintf("Usage: SITE XDUPE [<0-%d>]", xdupeMaxMode))
+	}
+
+	s.SetXDupe(mode)
+
+	return s.ReplyWithMessage(StatusOK, fmt.Sprintf("X-DUPE mode set to %d.", mode))
+}
+
+// replyDupe refuses the upload to path as a dupe. With X-DUPE enabled the
+// files already in the directory are listed before the reply
+func replyDupe(s Session, path string, err error) error {
+	mode := s.XDupe()
+	if mode == 0 {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	files, lerr := s.FS().ListDir(filepath.Dir(path), user)
+	if lerr != nil {
+		s.Logger().Error("unable to list for x-dupe", logging.Path(filepath.Dir(path)), logging.Err(lerr))
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+
+	// clients expect a space between the dash and X-DUPE
+	for _, line := range formatXDupe(mode, names) {
+		if err := s.ReplyPartial(StatusBadFilename, " X-DUPE: "+line); err != nil {
+			return err
+		}
+	}
+
+	return s.ReplyWithMessage(StatusBadFilename, fmt.Sprintf("%s: This file looks like a dupe!", filepath.Base(path)))
+}
+
+// formatXDupe splits the names in to the lines sent for the X-DUPE mode
+func formatXDupe(mode int, names []string) []string {
+	// the length of a line without the names
+	width := xdupeLineLength - len("553- X-DUPE: ")
+
+	switch mode {
+	case 1:
+		lines := make([]string, 0, len(names))
+		for _, name := range names {
+			if len(name) > width {
+				name = name[:width]
+			}
+			lines = append(lines, name)
+		}
+		return lines
+
+	case 2:
+		var lines []string
+		var line string
+
+		for _, name := range names {
+			if len(line) > 0 && len(line)+1+len(name) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+
+			if len(line) > 0 {
+				line += " "
+			}
+			line += name
+		}
+
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+
+		return lines
+
+	case 4:
+		if len(names) == 0 {
+			return nil
+		}
+		return []string{strings.Join(names, " ")}
+	}
+
+	return names
+}
+
+func init() {
+	SiteCommandMap["XDUPE"] = &siteCommandXDUPE{}
+	featSlice = append(featSlice, "XDUPE")
+}
@@ -0,0 +1,117 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestSiteXDupe(t *testing.T) {
+	var tests = []struct {
+		send   string
+		expect int
+	}{
+		{"SITE XDUPE 5", 501},
+		{"SITE XDUPE -1", 501},
+		{"SITE XDUPE one", 501},
+		{"SITE XDUPE 1 2", 501},
+		{"SITE XDUPE 3", 200},
+		{"SITE XDUPE", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, nil)
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: tt.send, Expect: tt.expect}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSiteXDupeNotLoggedIn(t *testing.T) {
+	s, err := ftptest.NewSession(&ftptest.SessionOpts{TLS: true})
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "SITE XDUPE 1", Expect: 530}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.XDupe() != 0 {
+		t.Fatalf("expected the mode to be unchanged got %d", s.XDupe())
+	}
+}
+
+func TestSiteXDupeReply(t *testing.T) {
+	var tests = []struct {
+		mode     string
+		expected []string
+	}{
+		{"0", nil},
+		{"1", []string{" X-DUPE: a.txt", " X-DUPE: b.txt"}},
+		{"4", []string{" X-DUPE: a.txt b.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dupes, err := ftptest.NewMemoryDupe()
+			if err != nil {
+				t.Fatalf("unexpected error creating dupe: %s", err)
+			}
+			defer dupes.Close()
+
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{Dupe: dupes}, nil)
+			defer done()
+
+			user, _ := s.User()
+
+			for _, name := range []string{"/a.txt", "/b.txt"} {
+				w, err := s.FS().UploadFile(name, user)
+				if err != nil {
+					t.Fatalf("unexpected error uploading '%s': %s", name, err)
+				}
+				w.Close()
+			}
+
+			err = s.Converse(context.Background(),
+				ftptest.Step{Send: "SITE XDUPE " + tt.mode, Expect: 200},
+				ftptest.Step{Send: "PASV", Expect: 227},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			replies, err := s.Exec(context.Background(), "STOR a.txt")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var partial []string
+			for _, r := range replies {
+				if r.Partial {
+					partial = append(partial, r.Message)
+				}
+			}
+
+			if len(partial) != len(tt.expected) {
+				t.Fatalf("expected %q got %v", tt.expected, replies)
+			}
+
+			for i := range partial {
+				if partial[i] != tt.expected[i] {
+					t.Fatalf("expected %q got %v", tt.expected, replies)
+				}
+			}
+
+			last := replies[len(replies)-1]
+			if tt.mode != "0" && (last.Code != 553 || last.Message != "a.txt: This file looks like a dupe!") {
+				t.Fatalf("unexpected reply: %s", last)
+			}
+		})
+	}
+}
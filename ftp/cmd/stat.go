@@ -46,31 +46,12 @@ func (c commandSTAT) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
-	var options, path string
-
-	// check if we have options and set the path eitherway
-	if len(params[0]) > 0 && params[0][0] == '-' {
-		options = params[0]
-		if len(params) > 1 {
-			params = params[1:]
-		} else {
-			params = []string{}
-		}
-	}
-
-	path = s.FS().Join(s.CWD(), params)
-
 	// get file list and parse with any options
-	finfo, err := s.FS().ListDir(path, user)
+	path, finfo, err := listDir(s, user, params)
 	if err != nil {
 		return s.ReplyError(StatusActionAbortedError, err)
 	}
 
-	if len(options) > 0 {
-		// handle options
-		finfo.SortByName()
-	}
-
 	return s.ReplyWithMessage(
 		StatusSystemStatus,
 		fmt.Sprintf(
@@ -34,6 +34,15 @@ var (
 	StatusUserLoggedOut                  = Status{232, "Logout command noted, will complete when transfer done."}
 	StatusSecurityExchangeOK             = Status{234, "Authentication mechanism accepted."}
 	StatusNotLoggedIn                    = Status{530, "Not logged in."}
+	StatusAccountExpired                 = Status{530, "Not logged in. Account expired."}
+	StatusPermissionDenied               = Status{530, "Permission denied."}
+	StatusLoginLocked                    = Status{530, "Not logged in. Too many failed logins, try again later."}
+	StatusTooManySessions                = Status{530, "Not logged in. Too many sessions."}
+	StatusUploadOnly                     = Status{550, "Permission denied. Account is upload only."}
+	StatusDownloadOnly                   = Status{550, "Permission denied. Account is download only."}
+	StatusNotEnoughCredits               = Status{550, "Requested action not taken. Not enough credits."}
+	StatusNoUploadSlot                   = Status{450, "Requested action not taken. All %d upload slots in use."}
+	StatusNoDownloadSlot                 = Status{450, "Requested action not taken. All %d download slots in use."}
 	StatusNeedPassword                   = Status{331, "User name okay, need password."}
 	StatusNeedAccount                    = Status{332, "Need account for login."}
 	StatusNeedAccountToStor              = Status{532, "Need account for storing files."}
@@ -46,4 +55,5 @@ var (
 	StatusPageTypeUnknown                = Status{551, "Requested action aborted. Page type unknown."}
 	StatusNoDiskFree                     = Status{452, "Requested action not taken. Insufficient storage space in system. File unavailable (e.g., file busy)."}
 	StatusBadFilename                    = Status{553, "Requested action not taken. File name not allowed."}
+	StatusBadCRC                         = Status{553, "Requested action not taken. File failed its CRC check."}
 )
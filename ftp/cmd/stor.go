@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+
+	"github.com/goftpd/goftpd/stats"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -38,9 +42,21 @@ func (c commandSTOR) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusNotLoggedIn)
 	}
 
+	if ok, err := canTransfer(s, user, stats.Upload); !ok {
+		return err
+	}
+
+	warning, err := checkDupe(s, path)
+	if err != nil {
+		return replyDupe(s, path, err)
+	}
+
 	writer, err := s.FS().UploadFile(path, user)
 	if err != nil {
-		return s.ReplyError(StatusActionNotOK, err)
+		if errors.Is(err, os.ErrExist) {
+			return replyDupe(s, path, err)
+		}
+		return s.ReplyError(uploadStatus(err), err)
 	}
 
 	if s.DataProtected() {
@@ -57,11 +73,22 @@ func (c commandSTOR) Execute(ctx context.Context, s Session, params []string) er
 
 	n, err := io.Copy(writer, s.Data())
 	if err != nil {
-		return s.ReplyError(StatusActionNotOK, err)
+		writer.Close()
+		return s.ReplyError(uploadStatus(err), err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return s.ReplyError(uploadStatus(err), err)
 	}
 
+	recordTransfer(s, user, path, stats.Upload, n)
+
 	s.Data().Close()
 
+	if len(warning) > 0 {
+		return s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes. %s", n, warning))
+	}
+
 	return s.ReplyWithMessage(StatusDataClosedOK, fmt.Sprintf("OK, received %d bytes.", n))
 }
 
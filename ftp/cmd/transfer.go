@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/goftpd/goftpd/zipscript"
+	"github.com/pkg/errors"
+)
+
+// recordTransfer is called once a transfer has completed successfully and
+// updates the user's stats and credits. Failures are logged as the transfer
+// itself was successful
+func recordTransfer(s Session, user *acl.User, path string, dir stats.Direction, n int64) {
+	if err := s.Stats().Record(user.Name, path, dir, n); err != nil {
+		s.Logger().Error("unable to record stats", logging.Path(path), logging.Err(err))
+	}
+
+	if err := chargeCredits(s, user, path, dir, n); err != nil {
+		s.Logger().Error("unable to update credits", logging.Path(path), logging.Err(err))
+	}
+
+	direction := "upload"
+	if dir == stats.Download {
+		direction = "download"
+	}
+
+	s.Logger().Info("transfer", logging.F("direction", direction), logging.Path(path), logging.Bytes(n))
+}
+
+// chargeCredits awards the user n times their ratio in credits for an
+// upload, unless the path is upload free, and takes n credits for a
+// download, unless the path is ratio free. Leeches are neither awarded nor
+// charged
+func chargeCredits(s Session, user *acl.User, path string, dir stats.Direction, n int64) error {
+	if n <= 0 || s.Anonymous() || user.Leech() {
+		return nil
+	}
+
+	if dir == stats.Upload {
+		if s.FS().UploadFree(path, user) {
+			return nil
+		}
+		_, err := s.Auth().IncrCredits(user.Name, n*int64(user.Ratio))
+		return err
+	}
+
+	if s.FS().RatioFree(path, user) {
+		return nil
+	}
+
+	// the download has already happened so it's taken even if it leaves the
+	// user short, i.e. another session downloaded at the same time
+	_, err := s.Auth().DecrCredits(user.Name, n, true)
+	return err
+}
+
+// canAfford checks the user has the credits to download n bytes of the
+// path, replying if they don't
+func canAfford(s Session, user *acl.User, path string, n int64) (bool, error) {
+	if n <= 0 || s.Anonymous() || user.Leech() || s.FS().RatioFree(path, user) {
+		return true, nil
+	}
+
+	if user.Credits >= n {
+		return true, nil
+	}
+
+	return false, s.ReplyStatus(StatusNotEnoughCredits)
+}
+
+// uploadCommands and downloadCommands are the commands taking an upload or
+// download slot while they run
+var (
+	uploadCommands   = map[string]struct{}{"STOR": {}, "APPE": {}}
+	downloadCommands = map[string]struct{}{"RETR": {}}
+)
+
+// canTransfer checks the user is allowed to transfer in the direction and
+// has a free slot, replying if not. Every transfer command checks it before
+// touching the filesystem
+func canTransfer(s Session, user *acl.User, dir stats.Direction) (bool, error) {
+	if dir == stats.Download && !user.CanDownload() {
+		return false, s.ReplyStatus(StatusUploadOnly)
+	}
+
+	if dir == stats.Upload && !user.CanUpload() {
+		return false, s.ReplyStatus(StatusDownloadOnly)
+	}
+
+	return transferSlot(s, user, dir)
+}
+
+// transferSlot checks the user has a free upload or download slot, replying
+// if they don't. Slots are counted from the commands running in every
+// session of the user, including this one
+func transferSlot(s Session, user *acl.User, dir stats.Direction) (bool, error) {
+	max, commands, status := user.MaxUploads, uploadCommands, StatusNoUploadSlot
+	if dir == stats.Download {
+		max, commands, status = user.MaxDownloads, downloadCommands, StatusNoDownloadSlot
+	}
+
+	if max <= 0 || s.Anonymous() {
+		return true, nil
+	}
+
+	var n int
+
+	for _, info := range s.Sessions() {
+		if info.Anonymous || !strings.EqualFold(info.Login, user.Name) {
+			continue
+		}
+
+		if _, ok := commands[info.Command]; ok {
+			n++
+		}
+	}
+
+	if n <= max {
+		return true, nil
+	}
+
+	return false, s.ReplyWithArgs(status, max)
+}
+
+// uploadStatus is the status to reply with when an upload fails
+func uploadStatus(err error) Status {
+	if errors.Is(err, vfs.ErrNoSpace) {
+		return StatusNoDiskFree
+	}
+	if errors.Is(err, vfs.ErrFilenameNotAllowed) || errors.Is(err, zipscript.ErrSFVExists) {
+		return StatusBadFilename
+	}
+	if errors.Is(err, zipscript.ErrBadCRC) {
+		return StatusBadCRC
+	}
+	return StatusActionNotOK
+}
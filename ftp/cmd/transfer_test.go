@@ -0,0 +1,209 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestTransferSlots(t *testing.T) {
+	var tests = []struct {
+		name     string
+		sessions []cmd.SessionInfo
+		send     string
+		expect   int
+	}{
+		{
+			"download slot free",
+			[]cmd.SessionInfo{{Login: "someone", Command: "STOR"}},
+			"RETR missing",
+			550,
+		},
+		{
+			"download slots in use",
+			[]cmd.SessionInfo{{Login: "someone", Command: "RETR"}},
+			"RETR missing",
+			450,
+		},
+		{
+			"another user downloading",
+			[]cmd.SessionInfo{{Login: "other", Command: "RETR"}},
+			"RETR missing",
+			550,
+		},
+		{
+			"upload slots in use",
+			[]cmd.SessionInfo{
+				{Login: "someone", Command: "STOR"},
+				{Login: "SOMEONE", Command: "APPE"},
+			},
+			"APPE file",
+			450,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{Sessions: tt.sessions}, func(u *acl.User) error {
+				u.MaxDownloads = 1
+				u.MaxUploads = 2
+				return nil
+			})
+			defer done()
+
+			err := s.Converse(context.Background(),
+				ftptest.Step{Send: "PASV", Expect: 227},
+				ftptest.Step{Send: tt.send, Expect: tt.expect},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTransferDirection(t *testing.T) {
+	var tests = []struct {
+		name    string
+		flag    string
+		send    string
+		refused bool
+		expect  cmd.Status
+	}{
+		{"upload only download", acl.FlagUploadOnly, "RETR file", true, cmd.StatusUploadOnly},
+		{"download only upload", acl.FlagDownloadOnly, "STOR file", true, cmd.StatusDownloadOnly},
+		{"download only append", acl.FlagDownloadOnly, "APPE file", true, cmd.StatusDownloadOnly},
+		{"download only download", acl.FlagDownloadOnly, "RETR file", false, cmd.StatusDownloadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, func(u *acl.User) error {
+				u.AddFlag(tt.flag)
+				return nil
+			})
+			defer done()
+
+			if err := s.Converse(context.Background(), ftptest.Step{Send: "PASV", Expect: 227}); err != nil {
+				t.Fatal(err)
+			}
+
+			replies, err := s.Exec(context.Background(), tt.send)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			refused := len(replies) == 1 && replies[0].Message == tt.expect.Message
+			if refused != tt.refused {
+				t.Fatalf("expected refused to be %t but got %v", tt.refused, replies)
+			}
+		})
+	}
+}
+
+func TestTransferCredits(t *testing.T) {
+	var tests = []struct {
+		name    string
+		ratio   int
+		credits int64
+		send    string
+		expect  int
+		after   int64
+	}{
+		{"download charged", 3, 10, "RETR /file", 226, 0},
+		{"download short", 3, 5, "RETR /file", 550, 5},
+		{"download ratio free", 3, 0, "RETR /free/file", 226, 0},
+		{"download leech", 0, 0, "RETR /file", 226, 0},
+		{"upload awarded", 3, 0, "APPE /file", 226, 30},
+		{"upload free", 3, 0, "APPE /free/file", 226, 0},
+		{"upload leech", 0, 0, "APPE /file", 226, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := ftptest.NewMemoryFS(
+				"download /** *",
+				"upload /** *",
+				"makedir /** *",
+				"resume /** *",
+				"ratio_free /free/** *",
+				"upload_free /free/** *",
+			)
+			if err != nil {
+				t.Fatalf("unexpected error creating fs: %s", err)
+			}
+
+			st, err := ftptest.NewMemoryStats()
+			if err != nil {
+				t.Fatalf("unexpected error creating stats: %s", err)
+			}
+			defer st.Close()
+
+			auth, err := ftptest.NewMemoryAuthenticator(nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating authenticator: %s", err)
+			}
+			defer auth.Close()
+
+			user, err := auth.AddUser("someone", "password")
+			if err != nil {
+				t.Fatalf("unexpected error adding user: %s", err)
+			}
+
+			for _, path := range []string{"/file", "/free/file"} {
+				if path == "/free/file" {
+					if err := fs.MakeDir("/free", user); err != nil {
+						t.Fatalf("unexpected error making dir: %s", err)
+					}
+				}
+
+				w, err := fs.UploadFile(path, user)
+				if err != nil {
+					t.Fatalf("unexpected error uploading '%s': %s", path, err)
+				}
+				w.Write([]byte("0123456789"))
+				w.Close()
+			}
+
+			err = auth.UpdateUser("someone", func(u *acl.User) error {
+				u.Ratio = tt.ratio
+				u.Credits = tt.credits
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error updating user: %s", err)
+			}
+
+			s, err := ftptest.NewSession(&ftptest.SessionOpts{FS: fs, Auth: auth, Stats: st, TLS: true})
+			if err != nil {
+				t.Fatalf("unexpected error creating session: %s", err)
+			}
+
+			if err := s.LoginAs("someone"); err != nil {
+				t.Fatalf("unexpected error logging in: %s", err)
+			}
+
+			s.Upload = []byte("0123456789")
+
+			err = s.Converse(context.Background(),
+				ftptest.Step{Send: "PASV", Expect: 227},
+				ftptest.Step{Send: tt.send, Expect: tt.expect},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := auth.GetUser("someone")
+			if err != nil {
+				t.Fatalf("unexpected error getting user: %s", err)
+			}
+
+			if u.Credits != tt.after {
+				t.Fatalf("expected %d credits got %d", tt.after, u.Credits)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+
+	"github.com/goftpd/goftpd/acl"
 )
 
 /*
@@ -33,8 +35,24 @@ func (c commandUSER) Execute(ctx context.Context, s Session, params []string) er
 		return s.ReplyStatus(StatusSyntaxError)
 	}
 
+	// flush the login already given the same as REIN, otherwise the new
+	// user would be logged in without PASS checking them
+	if s.State() == SessionStateLoggedIn {
+		s.Reinitialize()
+	}
+
 	s.SetLogin("")
 
+	if s.AnonymousEnabled() && acl.IsAnonymousLogin(params[0]) {
+		if err := s.ReplyWithMessage(StatusNeedPassword, "Anonymous login ok, send your email address as your password."); err != nil {
+			return err
+		}
+
+		s.SetLogin(params[0])
+
+		return nil
+	}
+
 	if err := s.ReplyStatus(StatusNeedPassword); err != nil {
 		return err
 	}
@@ -0,0 +1,32 @@
+package cmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestUserAfterLogin(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{
+		Anonymous:   true,
+		Permissions: []string{"who *"},
+	}, nil)
+	defer done()
+
+	err := s.Converse(context.Background(),
+		ftptest.Step{Send: "USER anonymous", Expect: 331},
+		ftptest.Step{Send: "PASS me@example.com", Expect: 230},
+		ftptest.Step{Send: "USER someone", Expect: 331},
+		ftptest.Step{Send: "SITE WHO", Expect: 530},
+		ftptest.Step{Send: "PWD", Expect: 530},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.State() != cmd.SessionStateAuth || s.Anonymous() {
+		t.Fatalf("expected the login to be flushed but got state %d anonymous %t", s.State(), s.Anonymous())
+	}
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key names a value stored on a session. The namespace keeps extensions
+// from colliding, i.e. a plugin uses its own name, and both are compared
+// ignoring case
+type Key struct {
+	namespace string
+	name      string
+}
+
+// NewKey returns the Key of the name in the namespace
+func NewKey(namespace, name string) Key {
+	return Key{
+		namespace: strings.ToLower(namespace),
+		name:      strings.ToLower(name),
+	}
+}
+
+func (k Key) String() string { return k.namespace + "." + k.name }
+
+// Values is state stashed on a session by commands, hooks and plugins so
+// they don't need fields of their own on the session. Values are kept until
+// the session disconnects or is reinitialized. Safe to use from other
+// goroutines
+type Values struct {
+	mtx sync.RWMutex
+	m   map[Key]interface{}
+}
+
+// Set stores the value under the key, replacing any there
+func (v *Values) Set(key Key, value interface{}) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.m == nil {
+		v.m = make(map[Key]interface{})
+	}
+
+	v.m[key] = value
+}
+
+// Get returns the value stored under the key
+func (v *Values) Get(key Key) (interface{}, bool) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	value, ok := v.m[key]
+	return value, ok
+}
+
+// Delete removes the value stored under the key
+func (v *Values) Delete(key Key) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	delete(v.m, key)
+}
+
+// Namespace returns a copy of the values in the namespace keyed by name
+func (v *Values) Namespace(namespace string) map[string]interface{} {
+	namespace = strings.ToLower(namespace)
+
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	values := make(map[string]interface{})
+
+	for k, value := range v.m {
+		if k.namespace == namespace {
+			values[k.name] = value
+		}
+	}
+
+	return values
+}
+
+// CopyTo replaces the values in dst with a copy of those in v
+func (v *Values) CopyTo(dst *Values) {
+	v.mtx.RLock()
+	m := make(map[Key]interface{}, len(v.m))
+	for k, value := range v.m {
+		m[k] = value
+	}
+	v.mtx.RUnlock()
+
+	dst.mtx.Lock()
+	defer dst.mtx.Unlock()
+
+	dst.m = m
+}
+
+// Clear removes every value
+func (v *Values) Clear() {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	v.m = nil
+}
+
+// String returns the value under the key if it is a string
+func (v *Values) String(key Key) (string, bool) {
+	value, ok := v.Get(key)
+	if !ok {
+		return "", false
+	}
+
+	s, ok := value.(string)
+	return s, ok
+}
+
+// Int returns the value under the key if it is an int
+func (v *Values) Int(key Key) (int, bool) {
+	value, ok := v.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	i, ok := value.(int)
+	return i, ok
+}
+
+// Int64 returns the value under the key if it is an int64
+func (v *Values) Int64(key Key) (int64, bool) {
+	value, ok := v.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	i, ok := value.(int64)
+	return i, ok
+}
+
+// Bool returns the value under the key if it is a bool, false otherwise
+func (v *Values) Bool(key Key) bool {
+	value, ok := v.Get(key)
+	if !ok {
+		return false
+	}
+
+	b, _ := value.(bool)
+	return b
+}
+
+// Time returns the value under the key if it is a time.Time
+func (v *Values) Time(key Key) (time.Time, bool) {
+	value, ok := v.Get(key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, ok := value.(time.Time)
+	return t, ok
+}
+
+// Add adds delta to the int64 under the key, a missing value or one of
+// another type starts from zero. Returns the new value
+func (v *Values) Add(key Key, delta int64) int64 {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.m == nil {
+		v.m = make(map[Key]interface{})
+	}
+
+	n, _ := v.m[key].(int64)
+	n += delta
+
+	v.m[key] = n
+
+	return n
+}
@@ -0,0 +1,146 @@
+package cmd_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/ftptest"
+)
+
+func TestValues(t *testing.T) {
+	var v cmd.Values
+
+	if _, ok := v.Get(cmd.NewKey("test", "missing")); ok {
+		t.Fatal("expected no value in empty values")
+	}
+
+	now := time.Now()
+
+	v.Set(cmd.NewKey("test", "string"), "value")
+	v.Set(cmd.NewKey("test", "int"), 1)
+	v.Set(cmd.NewKey("test", "int64"), int64(2))
+	v.Set(cmd.NewKey("test", "bool"), true)
+	v.Set(cmd.NewKey("test", "time"), now)
+	v.Set(cmd.NewKey("other", "string"), "other")
+
+	// keys ignore case
+	if s, ok := v.String(cmd.NewKey("TEST", "String")); !ok || s != "value" {
+		t.Fatalf("expected 'value' got '%s' %t", s, ok)
+	}
+
+	if i, ok := v.Int(cmd.NewKey("test", "int")); !ok || i != 1 {
+		t.Fatalf("expected 1 got %d %t", i, ok)
+	}
+
+	if i, ok := v.Int64(cmd.NewKey("test", "int64")); !ok || i != 2 {
+		t.Fatalf("expected 2 got %d %t", i, ok)
+	}
+
+	if !v.Bool(cmd.NewKey("test", "bool")) {
+		t.Fatal("expected true")
+	}
+
+	if tm, ok := v.Time(cmd.NewKey("test", "time")); !ok || !tm.Equal(now) {
+		t.Fatalf("expected %s got %s %t", now, tm, ok)
+	}
+
+	// the wrong type isn't converted
+	if _, ok := v.Int(cmd.NewKey("test", "int64")); ok {
+		t.Fatal("expected an int64 not to be returned as an int")
+	}
+
+	if _, ok := v.String(cmd.NewKey("test", "int")); ok {
+		t.Fatal("expected an int not to be returned as a string")
+	}
+
+	if v.Bool(cmd.NewKey("test", "string")) {
+		t.Fatal("expected a string not to be true")
+	}
+
+	expected := map[string]interface{}{"string": "other"}
+	if ns := v.Namespace("Other"); !reflect.DeepEqual(ns, expected) {
+		t.Fatalf("expected %v got %v", expected, ns)
+	}
+
+	v.Delete(cmd.NewKey("other", "string"))
+
+	if ns := v.Namespace("other"); len(ns) != 0 {
+		t.Fatalf("expected the namespace to be empty got %v", ns)
+	}
+
+	v.Clear()
+
+	if _, ok := v.Get(cmd.NewKey("test", "string")); ok {
+		t.Fatal("expected no value once cleared")
+	}
+}
+
+func TestValuesAdd(t *testing.T) {
+	var v cmd.Values
+
+	key := cmd.NewKey("test", "count")
+
+	if n := v.Add(key, 2); n != 2 {
+		t.Fatalf("expected 2 got %d", n)
+	}
+
+	if n := v.Add(key, -3); n != -1 {
+		t.Fatalf("expected -1 got %d", n)
+	}
+
+	// starts again from zero when replaced by another type
+	v.Set(key, "two")
+
+	if n := v.Add(key, 5); n != 5 {
+		t.Fatalf("expected 5 got %d", n)
+	}
+}
+
+func TestValuesCopyTo(t *testing.T) {
+	var src, dst cmd.Values
+
+	src.Set(cmd.NewKey("test", "a"), "a")
+	dst.Set(cmd.NewKey("test", "b"), "b")
+
+	src.CopyTo(&dst)
+
+	if _, ok := dst.Get(cmd.NewKey("test", "b")); ok {
+		t.Fatal("expected the values in dst to be replaced")
+	}
+
+	// the copy is independent of the original
+	src.Set(cmd.NewKey("test", "c"), "c")
+
+	expected := map[string]interface{}{"a": "a"}
+	if ns := dst.Namespace("test"); !reflect.DeepEqual(ns, expected) {
+		t.Fatalf("expected %v got %v", expected, ns)
+	}
+}
+
+func TestValuesReinitialize(t *testing.T) {
+	s, done := newLoggedInSession(t, &ftptest.SessionOpts{}, nil)
+	defer done()
+
+	key := cmd.NewKey("test", "value")
+
+	s.Values().Set(key, "value")
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "NOOP", Expect: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Values().Get(key); !ok {
+		t.Fatal("expected the value to be kept between commands")
+	}
+
+	if err := s.Converse(context.Background(), ftptest.Step{Send: "REIN", Expect: 220}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Values().Get(key); ok {
+		t.Fatal("expected the value to be cleared by REIN")
+	}
+}
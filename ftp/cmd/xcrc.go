@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goftpd/goftpd/vfs"
+)
+
+/*
+   XCRC <path>
+
+      Replies with the CRC32 of the file. The checksum recorded when the
+      file was uploaded is used, files without one are read and have it
+      recorded.
+*/
+
+type commandXCRC struct{}
+
+func (c commandXCRC) RequireState() SessionState { return SessionStateLoggedIn }
+
+func (c commandXCRC) Execute(ctx context.Context, s Session, params []string) error {
+	if len(params) == 0 {
+		return s.ReplyStatus(StatusSyntaxError)
+	}
+
+	user, ok := s.User()
+	if !ok {
+		return s.ReplyStatus(StatusNotLoggedIn)
+	}
+
+	path := s.FS().Join(s.CWD(), params)
+
+	sum, err := s.FS().Checksum(path, user)
+	if err == vfs.ErrNoChecksum {
+		sum, err = s.FS().VerifyChecksum(path, user)
+	}
+
+	if err != nil {
+		return s.ReplyError(StatusActionNotOK, err)
+	}
+
+	return s.ReplyWithMessage(StatusFileActionOK, fmt.Sprintf("%08X", sum.CRC32))
+}
+
+func init() {
+	CommandMap["XCRC"] = &commandXCRC{}
+	featSlice = append(featSlice, "XCRC")
+}
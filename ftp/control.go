@@ -3,12 +3,16 @@ package ftp
 import (
 	"bufio"
 	"net"
+	"sync"
 )
 
 type Control struct {
 	net.Conn
 	reader *bufio.Reader
 	writer *bufio.Writer
+
+	// held while writing a reply
+	mtx sync.Mutex
 }
 
 func newControl(conn net.Conn) *Control {
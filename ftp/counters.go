@@ -0,0 +1,133 @@
+package ftp
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// Version of the daemon, set when building with
+// -ldflags "-X github.com/goftpd/goftpd/ftp.Version=v1.2.3"
+var Version = "dev"
+
+// counters are the server's totals since it started, only ever updated
+// atomically, and its current speeds
+type counters struct {
+	connections   int64
+	uploads       int64
+	downloads     int64
+	uploadBytes   int64
+	downloadBytes int64
+
+	uploadRate   rate
+	downloadRate rate
+}
+
+// transferred adds n bytes moved in the direction to the totals and the
+// current speed
+func (c *counters) transferred(t cmd.Transfer, n int) {
+	now := time.Now()
+
+	switch t {
+	case cmd.TransferUpload:
+		atomic.AddInt64(&c.uploadBytes, int64(n))
+		c.uploadRate.add(now, int64(n))
+	case cmd.TransferDownload:
+		atomic.AddInt64(&c.downloadBytes, int64(n))
+		c.downloadRate.add(now, int64(n))
+	}
+}
+
+// finished counts a completed transfer in the direction
+func (c *counters) finished(t cmd.Transfer) {
+	switch t {
+	case cmd.TransferUpload:
+		atomic.AddInt64(&c.uploads, 1)
+	case cmd.TransferDownload:
+		atomic.AddInt64(&c.downloads, 1)
+	}
+}
+
+// Info returns the version, uptime, totals and current speeds of the
+// server
+func (s *Server) Info() cmd.ServerInfo {
+	now := time.Now()
+
+	return cmd.ServerInfo{
+		Version:       Version,
+		Started:       s.started,
+		Uptime:        now.Sub(s.started),
+		Connections:   atomic.LoadInt64(&s.counters.connections),
+		Uploads:       atomic.LoadInt64(&s.counters.uploads),
+		Downloads:     atomic.LoadInt64(&s.counters.downloads),
+		UploadBytes:   atomic.LoadInt64(&s.counters.uploadBytes),
+		DownloadBytes: atomic.LoadInt64(&s.counters.downloadBytes),
+		UploadSpeed:   s.counters.uploadRate.speed(now),
+		DownloadSpeed: s.counters.downloadRate.speed(now),
+	}
+}
+
+// rateWindow is how many seconds the current speeds are averaged over
+const rateWindow = 10
+
+// rate is a rolling count of the bytes moved over the last rateWindow
+// seconds, one bucket a second. Every active transfer adds to it as it
+// goes, so it is the speed of the whole server rather than the sum of
+// each transfer's average
+type rate struct {
+	mtx     sync.Mutex
+	buckets [rateWindow]int64
+	// the unix second of the newest bucket
+	last int64
+}
+
+// add counts n bytes moved at now
+func (r *rate) add(now time.Time, n int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	sec := r.advance(now)
+	r.buckets[sec%rateWindow] += n
+}
+
+// speed returns the bytes a second moved over the window ending at now
+func (r *rate) speed(now time.Time) float64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.advance(now)
+
+	var total int64
+	for _, n := range r.buckets {
+		total += n
+	}
+
+	// the newest bucket is only part way through its second
+	elapsed := float64(rateWindow-1) + float64(now.Nanosecond())/float64(time.Second)
+
+	return float64(total) / elapsed
+}
+
+// advance empties the buckets of the seconds since the last one used,
+// returning now's second. Must be called with mtx held
+func (r *rate) advance(now time.Time) int64 {
+	sec := now.Unix()
+
+	if sec <= r.last {
+		return r.last
+	}
+
+	if sec-r.last >= rateWindow {
+		r.buckets = [rateWindow]int64{}
+	} else {
+		for i := r.last + 1; i <= sec; i++ {
+			r.buckets[i%rateWindow] = 0
+		}
+	}
+
+	r.last = sec
+
+	return sec
+}
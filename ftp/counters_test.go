@@ -0,0 +1,52 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+func TestRate(t *testing.T) {
+	var r rate
+
+	start := time.Unix(1000, 0)
+
+	// a second of 1000 bytes and a second of 800 bytes, measured at the
+	// start of the next second
+	r.add(start, 1000)
+	r.add(start.Add(time.Second), 800)
+
+	if speed := r.speed(start.Add(2 * time.Second)); speed != 200 {
+		t.Fatalf("expected 200B/s got %f", speed)
+	}
+
+	// the first second falls out of the window
+	if speed := r.speed(start.Add(rateWindow * time.Second)); speed != 800.0/(rateWindow-1) {
+		t.Fatalf("expected %fB/s got %f", 800.0/(rateWindow-1), speed)
+	}
+
+	// everything falls out of the window after a gap
+	if speed := r.speed(start.Add(time.Hour)); speed != 0 {
+		t.Fatalf("expected 0B/s got %f", speed)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{})
+	defer done()
+
+	s.counters.transferred(cmd.TransferUpload, 100)
+	s.counters.transferred(cmd.TransferDownload, 50)
+	s.counters.finished(cmd.TransferUpload)
+
+	info := s.Info()
+
+	if info.Uploads != 1 || info.UploadBytes != 100 || info.Downloads != 0 || info.DownloadBytes != 50 {
+		t.Fatalf("unexpected totals: %+v", info)
+	}
+
+	if info.Version != Version {
+		t.Fatalf("expected version '%s' got '%s'", Version, info.Version)
+	}
+}
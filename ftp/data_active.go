@@ -2,8 +2,8 @@ package ftp
 
 import (
 	"context"
-	"crypto/tls"
 	"net"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -62,7 +62,7 @@ func (s *Server) newActiveDataConn(ctx context.Context, param string, dataProtec
 			connection via the accept() call; the FTP client, as defined in
 			[RFC-959], is always the TLS client, as defined in [RFC-2246].
 		*/
-		d.conn = tls.Server(d.conn, s.TLSConfig())
+		d.conn = s.dataTLS(d.conn)
 	}
 
 	return &d, nil
@@ -93,6 +93,17 @@ func (d *activeDataConn) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// sendFile implements fileSender, sending with sendfile unless the
+// connection uses TLS
+func (d *activeDataConn) sendFile(f *os.File, n int64) (int64, bool, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, true, err
+	}
+	written, ok, err := sendFile(d.conn, f, n)
+	d.written += int(written)
+	return written, ok, err
+}
+
 func (d *activeDataConn) Host() string      { return d.host }
 func (d *activeDataConn) Port() int         { return int(d.port) }
 func (d *activeDataConn) BytesRead() int    { return d.read }
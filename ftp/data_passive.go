@@ -3,7 +3,6 @@ package ftp
 import (
 	"context"
 	"crypto/rand"
-	"crypto/tls"
 	"errors"
 	"net"
 	"os"
@@ -14,6 +13,10 @@ import (
 	"time"
 )
 
+// errDataCrashed is returned by a passive data connection that panicked
+// while waiting for the client to connect
+var errDataCrashed = errors.New("data connection crashed")
+
 type passiveDataConn struct {
 	ctx context.Context
 
@@ -24,6 +27,9 @@ type passiveDataConn struct {
 
 	onClose func()
 
+	// wraps the accepted connection in TLS, nil for plain connections
+	protect func(net.Conn) net.Conn
+
 	written int
 	read    int
 
@@ -63,16 +69,10 @@ func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*p
 
 		port := n.Int64() + int64(s.PassivePorts[0])
 
-		// if we want to support none tls, do it here
-		var ln net.Listener
-
 		addr := net.JoinHostPort(s.PublicIP, strconv.Itoa(int(port)))
 
-		if dataProtected {
-			ln, err = tls.Listen("tcp", addr, s.tlsConfig)
-		} else {
-			ln, err = net.Listen("tcp", addr)
-		}
+		// the connection is wrapped in tls once accepted
+		ln, err := net.Listen("tcp", addr)
 
 		// check listen error
 		if err != nil {
@@ -93,7 +93,11 @@ func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*p
 			},
 		}
 
-		go dc.Accept(ctx, ln)
+		if dataProtected {
+			dc.protect = s.dataTLS
+		}
+
+		go s.acceptPassive(ctx, &dc, ln)
 
 		return &dc, nil
 	}
@@ -102,6 +106,22 @@ func (s *Server) newPassiveDataConn(ctx context.Context, dataProtected bool) (*p
 
 }
 
+// acceptPassive waits for the client to connect to the passive data port,
+// a panic fails the data connection rather than taking the server down
+func (s *Server) acceptPassive(ctx context.Context, dc *passiveDataConn, ln net.Listener) {
+	defer func() {
+		if e := recover(); e != nil {
+			s.logPanic("passive data connection crashed", e)
+
+			dc.Lock()
+			dc.err = errDataCrashed
+			dc.Unlock()
+		}
+	}()
+
+	dc.Accept(ctx, ln)
+}
+
 // Close implements the io.Closer interface and also allows us
 // to call our onClose fn that will cleanup server state
 func (d *passiveDataConn) Close() error {
@@ -140,6 +160,10 @@ func (d *passiveDataConn) Accept(ctx context.Context, ln net.Listener) {
 	}()
 
 	d.conn, d.err = ln.Accept()
+
+	if d.err == nil && d.protect != nil {
+		d.conn = d.protect(d.conn)
+	}
 }
 
 // Read implements the io.Reader interface as well as providing us
@@ -180,6 +204,25 @@ func (d *passiveDataConn) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// sendFile implements fileSender, sending with sendfile unless the
+// connection uses TLS
+func (d *passiveDataConn) sendFile(f *os.File, n int64) (int64, bool, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, true, err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.err != nil {
+		return 0, true, d.err
+	}
+
+	written, ok, err := sendFile(d.conn, f, n)
+	d.written += int(written)
+	return written, ok, err
+}
+
 // isErrorAddressAlreadyInUse checks to see if this is a bind to port issue
 func isErrorAddressAlreadyInUse(err error) bool {
 	errOpError, ok := err.(*net.OpError)
@@ -0,0 +1,237 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// dataCommands use a data connection so can run for as long as the
+// transfer takes, they are given the TransferTimeout
+var dataCommands = map[string]struct{}{
+	"RETR": {},
+	"STOR": {},
+	"APPE": {},
+	"LIST": {},
+	"NLST": {},
+	"MLSD": {},
+}
+
+// commandDeadline returns how long the command can run for, zero is
+// unlimited
+func (s *Session) commandDeadline(hc cmd.HookCommand) time.Duration {
+	timeout := s.server.CommandTimeout
+
+	if _, ok := dataCommands[hc.Name]; ok {
+		timeout = s.server.TransferTimeout
+	}
+
+	return time.Duration(timeout) * time.Second
+}
+
+// errAbandoned is returned by Session methods called by a command that
+// timed out
+var errAbandoned = errors.New("command abandoned")
+
+// commandResult is how a command run by runWithDeadline finished
+type commandResult struct {
+	err error
+
+	// set if the command panicked
+	panic interface{}
+	stack []byte
+}
+
+// commandPanic is a panic in a command handed back to the session's
+// goroutine, keeping the stack of the command's goroutine
+type commandPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p commandPanic) String() string {
+	return fmt.Sprintf("%v\n%s", p.value, p.stack)
+}
+
+// controlCommands use the control connection directly, one that times out
+// could still be reading from it so the session is closed
+var controlCommands = map[string]struct{}{
+	"AUTH": {},
+}
+
+// runWithDeadline runs f in its own goroutine so the session isn't held up
+// by a command wedged on the filesystem or in a hook. Once the deadline
+// passes the command is abandoned and a 451 sent, the session is carried on
+// by a successor, see Session.successor
+func (s *Session) runWithDeadline(ctx context.Context, hc cmd.HookCommand, f func(context.Context) error) error {
+	timeout := s.commandDeadline(hc)
+	if timeout <= 0 {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the id is sent before the result so it is always known on timeout
+	ids := make(chan uint64, 1)
+	done := make(chan commandResult, 1)
+
+	go func() {
+		ids <- goroutineID()
+
+		defer func() {
+			if e := recover(); e != nil {
+				done <- commandResult{panic: e, stack: debug.Stack()}
+			}
+		}()
+
+		done <- commandResult{err: f(ctx)}
+	}()
+
+	id := <-ids
+
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			panic(commandPanic{r.panic, r.stack})
+		}
+		return r.err
+
+	case <-ctx.Done():
+	}
+
+	// cancelled by the server shutting down rather than timing out, the
+	// command is left to finish
+	if ctx.Err() != context.DeadlineExceeded {
+		r := <-done
+		if r.panic != nil {
+			panic(commandPanic{r.panic, r.stack})
+		}
+		return r.err
+	}
+
+	// the command's ctx is cancelled and from here on it can't change the
+	// session, whatever it is blocked on
+	cancel()
+
+	s.mtx.Lock()
+	s.abandoned = true
+	data := s.data
+	s.data = nil
+	s.mtx.Unlock()
+
+	s.Logger().Error("command timed out",
+		logging.Command(hc.Name),
+		logging.Duration(timeout),
+		logging.F("stack", goroutineStack(id)),
+	)
+
+	// unblock a transfer stuck on the data connection
+	if data != nil {
+		data.Close()
+	}
+
+	s.control.SetWriteDeadline(time.Now().Add(time.Second * 5))
+	err := s.writeReply(cmd.StatusActionAbortedError.Code, "Command timed out.")
+	s.control.SetWriteDeadline(time.Time{})
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := controlCommands[hc.Name]; ok {
+		return cmd.NewFatalError(ctx.Err())
+	}
+
+	return nil
+}
+
+// successor returns a copy of an abandoned session to carry on serving the
+// client, the abandoned command is left with the old session which nothing
+// else uses. The session's data connection was closed when it was abandoned
+func (s *Session) successor() *Session {
+	n := Session{
+		server:   s.server,
+		id:       s.id,
+		listener: s.listener,
+		ident:    s.ident,
+	}
+
+	s.mtx.Lock()
+	n.control = s.control
+	n.state = s.state
+	n.dataProtected = s.dataProtected
+	n.binaryMode = s.binaryMode
+	n.lastCommand = s.lastCommand
+	n.lastReply = s.lastReply
+	n.renameFrom = s.renameFrom
+	n.restartPosition = s.restartPosition
+	n.xdupe = s.xdupe
+	n.login = s.login
+	n.anonymous = s.anonymous
+	n.currentDir = s.currentDir
+
+	// the anonymous slot is the successor's to give back
+	s.anonymous = false
+	s.mtx.Unlock()
+
+	s.infoMtx.Lock()
+	n.info = s.info
+	s.infoMtx.Unlock()
+
+	s.values.CopyTo(&n.values)
+	n.setTrace(s.tracing())
+
+	s.server.replaceSession(s, &n)
+
+	return &n
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from the
+// first line of its stack, `goroutine 1 [running]:`
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+
+	return id
+}
+
+// goroutineStack returns the stack of the goroutine, empty if it has
+// finished
+func goroutineStack(id uint64) string {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+	prefix := []byte(fmt.Sprintf("goroutine %d ", id))
+
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, prefix) {
+			return string(stack)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,102 @@
+package ftp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// wedgedCommand blocks until the ctx is cancelled, then once released
+// tries to change the session the way a late command would
+type wedgedCommand struct {
+	release chan struct{}
+	done    chan error
+}
+
+func (c wedgedCommand) RequireState() cmd.SessionState { return cmd.SessionStateLoggedIn }
+
+func (c wedgedCommand) Execute(ctx context.Context, s cmd.Session, params []string) error {
+	<-ctx.Done()
+	<-c.release
+
+	s.SetCWD("/wedged")
+	s.SetState(cmd.SessionStateNull)
+	s.SetLogin("")
+	s.SetBinaryMode(true)
+
+	c.done <- s.ReplyStatus(cmd.StatusOK)
+
+	return nil
+}
+
+func TestRunWithDeadline(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{CommandTimeout: 1})
+	defer done()
+
+	wedged := wedgedCommand{make(chan struct{}), make(chan error, 1)}
+
+	cmd.CommandMap["XWEDGE"] = wedged
+	defer delete(cmd.CommandMap, "XWEDGE")
+
+	s.addUser(t, "someone", nil)
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	c.login("someone")
+	c.expect("CWD /", 250)
+
+	if msg := c.expect("XWEDGE", 451); msg != "Command timed out." {
+		t.Fatalf("unexpected reply: %s", msg)
+	}
+
+	close(wedged.release)
+
+	if err := <-wedged.done; err == nil {
+		t.Fatal("expected the abandoned command to be unable to reply")
+	}
+
+	// still logged in in the same directory, nothing the late command sent
+	// is read as the reply
+	if msg := c.expect("PWD", 257); !strings.HasPrefix(msg, `"/"`) {
+		t.Fatalf("unexpected directory: %s", msg)
+	}
+
+	c.expect("NOOP", 200)
+
+	sessions := s.Sessions()
+	if len(sessions) != 1 || sessions[0].Login != "someone" || sessions[0].CWD != "/" {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+
+	log := s.log.String()
+	if !strings.Contains(log, "command timed out") || !strings.Contains(log, "wedgedCommand.Execute") {
+		t.Fatalf("expected the timeout to be logged with its stack: %s", log)
+	}
+}
+
+func TestRunWithDeadlineAuth(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{CommandTimeout: 1})
+	defer done()
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	// the handshake is never started so AUTH times out holding the control
+	// connection
+	c.expect("AUTH TLS", 234)
+
+	if code, msg := c.read(); code != 451 {
+		t.Fatalf("expected 451 got %d %s", code, msg)
+	}
+
+	c.expectClosed()
+}
@@ -0,0 +1,103 @@
+package ftp
+
+import (
+	"strconv"
+
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// handleEvent keeps the dupe db, dirlog and race stats up to date with
+// changes made to the filesystem, runs any scripts, sends webhooks, writes
+// the glftpd log, looks up the pre time of new releases, announces new and
+// completed releases and scans uploads. Failures are logged as the change
+// itself was successful
+func (s *Server) handleEvent(e vfs.Event) {
+	s.runScripts(e)
+	s.uploadWebhook(e)
+	s.logDirEvent(e)
+
+	switch e.Type {
+	case vfs.EventUpload:
+		s.recordDupe(e.Path, e.User, false)
+		s.recordRace(e)
+		s.scanUpload(e)
+
+	case vfs.EventMakeDir:
+		s.recordDupe(e.Path, e.User, true)
+
+		if s.recordDirlog(e.Path, e.User, e.Group) {
+			s.newRelease(e.Path)
+		}
+
+	case vfs.EventDeleteDir:
+		s.removeDirlog(e.Path)
+		s.removeRace(e.Path)
+
+	case vfs.EventComplete:
+		s.completeRace(e)
+
+	case vfs.EventRename:
+		if !e.Dir {
+			return
+		}
+
+		// keep the original creator of a renamed release
+		creator, group := e.User, e.Group
+
+		if entry, err := s.dirlog.Get(e.Path); err == nil && entry != nil {
+			creator, group = entry.User, entry.Group
+		}
+
+		s.removeDirlog(e.Path)
+		s.recordDirlog(e.NewPath, creator, group)
+	}
+}
+
+func (s *Server) recordDupe(path, user string, dir bool) {
+	if err := s.dupes.Record(path, user, dir); err != nil {
+		s.Logger().Error("unable to record dupe", logging.User(user), logging.Path(path), logging.Err(err))
+	}
+}
+
+// recordDirlog returns true if the directory was logged as a release of a
+// section
+func (s *Server) recordDirlog(path, user, group string) bool {
+	logged, err := s.dirlog.Record(path, user, group)
+	if err != nil {
+		s.Logger().Error("unable to record dirlog", logging.User(user), logging.Path(path), logging.Err(err))
+	}
+
+	return logged
+}
+
+func (s *Server) removeDirlog(path string) {
+	if err := s.dirlog.Remove(path); err != nil {
+		s.Logger().Error("unable to remove dirlog", logging.Path(path), logging.Err(err))
+	}
+}
+
+// announcePre announces a release once it has been pred
+func (s *Server) announcePre(r pre.Release) {
+	s.Logger().Info("pre",
+		logging.F("section", r.Section),
+		logging.F("release", r.Name),
+		logging.F("group", r.Group),
+		logging.User(r.User),
+		logging.F("files", r.Files),
+		logging.Bytes(r.Bytes),
+	)
+
+	s.announceEvent(announce.EventPre, cookie.Cookies{
+		"section": r.Section,
+		"release": r.Name,
+		"path":    r.Path,
+		"group":   r.Group,
+		"user":    r.User,
+		"files":   strconv.FormatInt(r.Files, 10),
+		"size":    cookie.FormatBytes(r.Bytes),
+	})
+}
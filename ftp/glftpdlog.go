@@ -0,0 +1,139 @@
+package ftp
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/glftpd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// logDirEvent writes the glftpd log line for a directory made, deleted or
+// wiped by a user
+func (s *Server) logDirEvent(e vfs.Event) {
+	if len(e.User) == 0 {
+		return
+	}
+
+	var event string
+
+	switch {
+	case e.Wipe:
+		event = glftpd.LogWipe
+	case e.Type == vfs.EventMakeDir:
+		event = glftpd.LogNewDir
+	case e.Type == vfs.EventDeleteDir:
+		event = glftpd.LogDelDir
+	default:
+		return
+	}
+
+	var tagline string
+	if user, err := s.auth.GetUser(e.User); err == nil {
+		tagline = user.Tagline
+	}
+
+	s.writeGlftpdLog(event, s.glftpdLog.Path(e.Path), e.User, e.Group, tagline)
+}
+
+func (s *Server) writeGlftpdLog(event string, fields ...string) {
+	if err := s.glftpdLog.Write(event, fields...); err != nil {
+		s.Logger().Error("unable to write to glftpd log", logging.F("event", event), logging.Err(err))
+	}
+}
+
+// nukeLog is a cmd.Hook writing the glftpd log lines of nukes and unnukes.
+// The record of a directory is gone once it is unnuked so it is looked up
+// before SITE UNNUKE runs
+type nukeLog struct {
+	s *Server
+
+	mtx     sync.Mutex
+	unnukes map[cmd.Session]*nuke.Record
+}
+
+func newNukeLog(s *Server) *nukeLog {
+	return &nukeLog{
+		s:       s,
+		unnukes: make(map[cmd.Session]*nuke.Record),
+	}
+}
+
+func (l *nukeLog) Before(ctx context.Context, session cmd.Session, hc cmd.HookCommand) error {
+	if hc.Name != "SITE UNNUKE" || len(hc.Params) == 0 {
+		return nil
+	}
+
+	record, err := l.s.nukes.Get(l.s.fs.Join(session.CWD(), hc.Params[:1]))
+	if err != nil || record == nil {
+		return nil
+	}
+
+	l.mtx.Lock()
+	l.unnukes[session] = record
+	l.mtx.Unlock()
+
+	return nil
+}
+
+func (l *nukeLog) After(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	var (
+		event  string
+		record *nuke.Record
+	)
+
+	switch hc.Name {
+	case "SITE NUKE":
+		if r.Code != cmd.StatusOK.Code || len(hc.Params) == 0 {
+			return
+		}
+
+		var err error
+
+		record, err = l.s.nukes.Get(l.s.fs.Join(session.CWD(), hc.Params[:1]))
+		if err != nil || record == nil {
+			return
+		}
+
+		event = glftpd.LogNuke
+
+	case "SITE UNNUKE":
+		l.mtx.Lock()
+		record = l.unnukes[session]
+		delete(l.unnukes, session)
+		l.mtx.Unlock()
+
+		if record == nil || r.Code != cmd.StatusOK.Code {
+			return
+		}
+
+		event = glftpd.LogUnnuke
+
+	default:
+		return
+	}
+
+	user, ok := session.User()
+	if !ok {
+		return
+	}
+
+	path := l.s.glftpdLog.Path(record.Path)
+	nuker := user.Name + "@" + user.PrimaryGroup
+	multiplier := strconv.Itoa(record.Multiplier)
+
+	// sitebots announce a nuke from its first line so a nuke without any
+	// nukees still gets one
+	if len(record.Nukees) == 0 {
+		l.s.writeGlftpdLog(event, path, nuker, "", multiplier+" "+glftpd.FormatMegabytes(0), record.Reason)
+		return
+	}
+
+	for _, e := range record.Nukees {
+		l.s.writeGlftpdLog(event, path, nuker, e.User+"@"+e.Group, multiplier+" "+glftpd.FormatMegabytes(e.Bytes), record.Reason)
+	}
+}
@@ -0,0 +1,462 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/ftptest"
+	"github.com/goftpd/goftpd/glftpd"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/pretime"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/scan"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/spy"
+	"github.com/goftpd/goftpd/webhook"
+	"github.com/goftpd/goftpd/xferlog"
+)
+
+// syncBuffer is a bytes.Buffer safe to log to from every session
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+// testServer is a Server using in-memory services, logging to log
+type testServer struct {
+	*Server
+
+	auth *ftptest.MemoryAuthenticator
+	log  *syncBuffer
+}
+
+// newTestServer returns a testServer using the options, TLS with a self
+// signed certificate and the command permissions, i.e. `adduser *`.
+// Everyone can upload, download and make directories anywhere
+func newTestServer(t *testing.T, opts *ServerOpts, permissions ...string) (*testServer, func()) {
+	t.Helper()
+
+	fs, err := ftptest.NewMemoryFS("download /** *", "upload /** *", "makedir /** *", "resume /** *")
+	if err != nil {
+		t.Fatalf("unexpected error creating fs: %s", err)
+	}
+
+	auth, err := ftptest.NewMemoryAuthenticator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating authenticator: %s", err)
+	}
+
+	st, err := ftptest.NewMemoryStats()
+	if err != nil {
+		t.Fatalf("unexpected error creating stats: %s", err)
+	}
+
+	bopts := badger.DefaultOptions("").WithInMemory(true)
+	bopts.Logger = nil
+
+	db, err := badger.Open(bopts)
+	if err != nil {
+		t.Fatalf("unexpected error opening badger: %s", err)
+	}
+
+	bans, err := ban.NewBadgerBans(&ban.BanOpts{}, db)
+	if err != nil {
+		t.Fatalf("unexpected error creating bans: %s", err)
+	}
+
+	engine, err := script.NewLuaEngine(&script.ScriptOpts{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating lua engine: %s", err)
+	}
+
+	glftpdLog, err := glftpd.NewFileLog(&glftpd.LogOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error creating glftpd log: %s", err)
+	}
+
+	transfers, err := xferlog.NewFileLog(&xferlog.XferlogOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error creating xferlog: %s", err)
+	}
+
+	feed, err := spy.NewHub(&spy.SpyOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error creating spy: %s", err)
+	}
+
+	announcer, err := announce.NewBotAnnouncer(&announce.AnnounceOpts{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating announcer: %s", err)
+	}
+
+	pretimes, err := pretime.NewHTTPLookup(&pretime.PretimeOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error creating pretime lookup: %s", err)
+	}
+
+	scanner, err := scan.NewUploadScanner(&scan.ScanOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error creating scanner: %s", err)
+	}
+
+	webhooks := webhook.NewHTTPDispatcher(&webhook.WebhookOpts{}, nil)
+	scripts := script.NewExecRunner(&script.ScriptOpts{}, nil)
+
+	var rules []acl.CommandRule
+	for _, l := range permissions {
+		r, err := acl.NewCommandRule(l)
+		if err != nil {
+			t.Fatalf("unexpected error parsing permission '%s': %s", l, err)
+		}
+		rules = append(rules, r)
+	}
+
+	commandPermissions, err := acl.NewCommandPermissions(rules)
+	if err != nil {
+		t.Fatalf("unexpected error creating command permissions: %s", err)
+	}
+
+	log := &syncBuffer{}
+
+	if opts.PassivePorts == nil {
+		opts.PassivePorts = []int{30000, 30100}
+	}
+
+	opts.SetCommandPermissions(commandPermissions)
+	opts.SetTLSConfig(newTestTLSConfig(t))
+	opts.SetLogger(logging.NewWriterLogger(log, logging.LevelDebug, logging.FormatConsole))
+
+	s, err := NewServer(opts, ServerDeps{
+		FS:        fs,
+		Auth:      auth,
+		Stats:     st,
+		Dupe:      dupe.NewBadgerDupe(&dupe.DupeOpts{}, db, nil),
+		Dirlog:    dirlog.NewBadgerDirlog(&dirlog.DirlogOpts{}, db, nil),
+		Inbox:     inbox.NewBadgerInbox(&inbox.InboxOpts{}, db),
+		Nukes:     nuke.NewBadgerNukes(&nuke.NukeOpts{}, db),
+		Pre:       pre.NewBadgerPre(&pre.PreOpts{}, db, nil, nil),
+		Scripts:   scripts,
+		Lua:       engine,
+		Webhooks:  webhooks,
+		GlftpdLog: glftpdLog,
+		Xferlog:   transfers,
+		Spy:       feed,
+		Race:      race.NewBadgerRace(&race.RaceOpts{}, db),
+		Audit:     audit.NewBadgerLog(&audit.AuditOpts{}, db),
+		Announce:  announcer,
+		Pretime:   pretimes,
+		Scanner:   scanner,
+		Bans:      bans,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating server: %s", err)
+	}
+
+	return &testServer{s, auth, log}, func() {
+		scanner.Close()
+		announcer.Close()
+		feed.Close()
+		transfers.Close()
+		glftpdLog.Close()
+		webhooks.Close()
+		scripts.Close()
+		engine.Close()
+		st.Close()
+		db.Close()
+
+		if err := auth.Close(); err != nil {
+			t.Fatalf("unexpected error closing authenticator: %s", err)
+		}
+	}
+}
+
+// addUser adds a user who can login from 127.0.0.1 with the password
+// `password`, update changes them once added
+func (s *testServer) addUser(t *testing.T, name string, update func(*acl.User) error) {
+	t.Helper()
+
+	if _, err := s.auth.AddUser(name, "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	err := s.auth.UpdateUser(name, func(u *acl.User) error {
+		if _, err := u.AddIP("*@127.0.0.1"); err != nil {
+			return err
+		}
+
+		if update != nil {
+			return update(u)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating user: %s", err)
+	}
+}
+
+// listen serves the server's own Host and Port and the listeners on
+// 127.0.0.1, returning the address of each by name. The server stops when
+// stop is called
+func (s *testServer) listen(t *testing.T, listeners ...*ListenerOpts) (map[string]string, func()) {
+	t.Helper()
+
+	addrs := make(map[string]string)
+	inherited := make(map[string][]net.Listener)
+
+	names := []string{"default"}
+	for _, l := range listeners {
+		names = append(names, l.Name)
+	}
+
+	for _, name := range names {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error listening: %s", err)
+		}
+
+		addrs[name] = l.Addr().String()
+		inherited[name] = []net.Listener{l}
+	}
+
+	s.SetListeners(listeners)
+	s.SetInherited(inherited)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- s.ListenAndServe(ctx) }()
+
+	select {
+	case <-s.Ready():
+	case err := <-done:
+		t.Fatalf("unexpected error serving: %s", err)
+	}
+
+	return addrs, func() {
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error serving: %s", err)
+		}
+	}
+}
+
+// newTestTLSConfig returns a tls.Config with a self signed certificate for
+// 127.0.0.1
+func newTestTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "goftpd"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %s", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// testClient is a client connected to a testServer's control connection
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// the message of the last line of the greeting
+	greeting string
+}
+
+// dial connects to addr and reads the greeting, implicit connects with
+// TLS from the start
+func dial(t *testing.T, addr string, implicit bool) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %s", err)
+	}
+
+	if implicit {
+		conn = tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	}
+
+	c := testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	code, msg := c.read()
+	if code != 220 {
+		t.Fatalf("expected greeting but got %d %s", code, msg)
+	}
+
+	c.greeting = msg
+
+	return &c
+}
+
+// replyEnd is the last line of a reply
+var replyEnd = regexp.MustCompile(`^(\d{3}) (.*)$`)
+
+// read reads a reply, returning its code and the message of its last line
+func (c *testClient) read() (int, string) {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("unexpected error reading reply: %s", err)
+		}
+
+		m := replyEnd.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+
+		code, _ := strconv.Atoi(m[1])
+
+		return code, m[2]
+	}
+}
+
+// send sends the line and returns the reply
+func (c *testClient) send(line string) (int, string) {
+	c.t.Helper()
+
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatalf("unexpected error sending '%s': %s", line, err)
+	}
+
+	return c.read()
+}
+
+// expect sends the line and fails unless the reply has the code
+func (c *testClient) expect(line string, code int) string {
+	c.t.Helper()
+
+	got, msg := c.send(line)
+	if got != code {
+		c.t.Fatalf("'%s' expected %d but got %d %s", line, code, got, msg)
+	}
+
+	return msg
+}
+
+// login upgrades to TLS, unless it already uses it, and logs in as the
+// user added with testServer.addUser
+func (c *testClient) login(name string) {
+	c.t.Helper()
+
+	if _, ok := c.conn.(*tls.Conn); !ok {
+		c.expect("AUTH TLS", 234)
+
+		c.conn = tls.Client(c.conn, &tls.Config{InsecureSkipVerify: true})
+		c.reader = bufio.NewReader(c.conn)
+	}
+
+	c.expect("USER "+name, 331)
+	c.expect("PASS password", 230)
+}
+
+// pasvAddr matches the address in a 227 reply
+var pasvAddr = regexp.MustCompile(`\((\d+),(\d+),(\d+),(\d+),(\d+),(\d+)\)`)
+
+// pasv sends PASV and connects to the data port, wrapping the connection
+// in TLS when protected
+func (c *testClient) pasv(protected bool) net.Conn {
+	c.t.Helper()
+
+	msg := c.expect("PASV", 227)
+
+	m := pasvAddr.FindStringSubmatch(msg)
+	if m == nil {
+		c.t.Fatalf("unexpected PASV reply: %s", msg)
+	}
+
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+
+	addr := net.JoinHostPort(strings.Join(m[1:5], "."), strconv.Itoa(p1*256+p2))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		c.t.Fatalf("unexpected error dialing data connection: %s", err)
+	}
+
+	if protected {
+		conn = tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	}
+
+	return conn
+}
+
+// expectClosed fails unless the server closes the connection, skipping
+// anything sent before it does
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	for {
+		_, err := c.reader.ReadString('\n')
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			c.t.Fatal("expected the connection to be closed")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *testClient) Close() error { return c.conn.Close() }
@@ -0,0 +1,49 @@
+package ftp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// AddHook registers a Hook to run around every command. Hooks run in the
+// order they were added before the command and in reverse after it. Has to
+// be called before ListenAndServe
+func (s *Server) AddHook(h cmd.Hook) {
+	s.hooks = append(s.hooks, h)
+}
+
+// newHookCommand returns the HookCommand of the fields sent by the client
+func newHookCommand(fields []string) cmd.HookCommand {
+	hc := cmd.HookCommand{
+		Name:   strings.ToUpper(fields[0]),
+		Params: fields[1:],
+	}
+
+	if hc.Name == "SITE" && len(fields) > 1 {
+		hc.Name += " " + strings.ToUpper(fields[1])
+		hc.Params = fields[2:]
+	}
+
+	return hc
+}
+
+// runBefore runs the Before of every hook, stopping at the first to refuse
+// the command
+func (s *Server) runBefore(ctx context.Context, session *Session, hc cmd.HookCommand) error {
+	for _, h := range s.hooks {
+		if err := h.Before(ctx, session, hc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runAfter runs the After of every hook
+func (s *Server) runAfter(ctx context.Context, session *Session, hc cmd.HookCommand, r cmd.HookResult) {
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		s.hooks[i].After(ctx, session, hc, r)
+	}
+}
@@ -0,0 +1,125 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// hookRecorder records the commands its hooks are run around, NOOP isn't
+// recorded so it can be sent to wait for the hooks of the command before
+type hookRecorder struct {
+	mtx    sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) hook(name string, refuse string) cmd.Hook {
+	return cmd.HookFuncs{
+		BeforeFunc: func(ctx context.Context, s cmd.Session, c cmd.HookCommand) error {
+			r.record(c, "%s before %s %s", name, c.Name, strings.Join(c.Params, " "))
+			if c.Name == refuse {
+				return errors.New("refused by " + name)
+			}
+			return nil
+		},
+		AfterFunc: func(ctx context.Context, s cmd.Session, c cmd.HookCommand, res cmd.HookResult) {
+			r.record(c, "%s after %s %d", name, c.Name, res.Code)
+		},
+	}
+}
+
+func (r *hookRecorder) record(c cmd.HookCommand, format string, args ...interface{}) {
+	if c.Name == "NOOP" {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.events = append(r.events, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+// take returns the events recorded since it was last called
+func (r *hookRecorder) take() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	events := r.events
+	r.events = nil
+
+	return events
+}
+
+func TestHooks(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{}, "who =staff")
+	defer done()
+
+	s.addUser(t, "someone", nil)
+
+	r := &hookRecorder{}
+	s.AddHook(r.hook("first", ""))
+	s.AddHook(r.hook("second", "SITE REFUSED"))
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	// hooks aren't run for commands sent in the wrong state
+	c.expect("SITE XDUPE 1", 530)
+
+	c.login("someone")
+	c.expect("NOOP", 200)
+
+	for _, e := range r.take() {
+		if strings.Contains(e, "SITE") {
+			t.Fatalf("expected no hooks to run before login got '%s'", e)
+		}
+	}
+
+	var tests = []struct {
+		send     string
+		expect   int
+		expected []string
+	}{
+		{
+			"SITE WHO",
+			530,
+			[]string{"first before SITE WHO", "second before SITE WHO", "second after SITE WHO 530", "first after SITE WHO 530"},
+		},
+		{
+			"SITE XDUPE 9",
+			501,
+			[]string{"first before SITE XDUPE 9", "second before SITE XDUPE 9", "second after SITE XDUPE 501", "first after SITE XDUPE 501"},
+		},
+		{
+			"site xdupe 1",
+			200,
+			[]string{"first before SITE XDUPE 1", "second before SITE XDUPE 1", "second after SITE XDUPE 200", "first after SITE XDUPE 200"},
+		},
+		{
+			// refused before the command is found to not exist
+			"SITE REFUSED",
+			550,
+			[]string{"first before SITE REFUSED", "second before SITE REFUSED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.send, func(t *testing.T) {
+			c.expect(tt.send, tt.expect)
+			c.expect("NOOP", 200)
+
+			if events := r.take(); !reflect.DeepEqual(events, tt.expected) {
+				t.Fatalf("expected %q got %q", tt.expected, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// identPort is the port identd listens on as defined in RFC 1413
+const identPort = "113"
+
+// lookupIdent queries the identd running on the remote end of conn
+// as described in RFC 1413, returns the user id reported
+func lookupIdent(ctx context.Context, conn net.Conn, timeout time.Duration) (string, error) {
+	remoteHost, remotePort, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return "", err
+	}
+
+	_, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		return "", err
+	}
+
+	return queryIdent(ctx, net.JoinHostPort(remoteHost, identPort), remotePort, localPort, timeout)
+}
+
+// queryIdent asks the identd at addr who owns the connection between the
+// ports, remotePort being the client's side
+func queryIdent(ctx context.Context, addr, remotePort, localPort string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := net.Dialer{}
+
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	// the query is the port on the client's side followed by
+	// the port on our side
+	if _, err := fmt.Fprintf(c, "%s, %s\r\n", remotePort, localPort); err != nil {
+		return "", err
+	}
+
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return parseIdentResponse(line, remotePort, localPort)
+}
+
+// parseIdentResponse parses a response in the form of:
+// <port-on-server> , <port-on-client> : <resp-type> : <add-info>
+func parseIdentResponse(line, remotePort, localPort string) (string, error) {
+	parts := strings.SplitN(strings.TrimSpace(line), ":", 4)
+	if len(parts) < 3 {
+		return "", errors.Errorf("malformed ident response: '%s'", line)
+	}
+
+	ports := strings.Split(parts[0], ",")
+	if len(ports) != 2 {
+		return "", errors.Errorf("malformed ident ports: '%s'", parts[0])
+	}
+
+	for idx, expected := range []string{remotePort, localPort} {
+		got, err := strconv.Atoi(strings.TrimSpace(ports[idx]))
+		if err != nil || strconv.Itoa(got) != expected {
+			return "", errors.Errorf("unexpected ident ports: '%s'", parts[0])
+		}
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(parts[1])) {
+	case "USERID":
+		if len(parts) != 4 {
+			return "", errors.Errorf("malformed ident response: '%s'", line)
+		}
+
+		ident := strings.TrimSpace(parts[3])
+		if len(ident) == 0 {
+			return "", errors.New("empty ident")
+		}
+
+		return ident, nil
+
+	case "ERROR":
+		return "", errors.Errorf("ident error: %s", strings.TrimSpace(parts[2]))
+	}
+
+	return "", errors.Errorf("unknown ident response type: '%s'", parts[1])
+}
@@ -0,0 +1,114 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseIdentResponse(t *testing.T) {
+	var tests = []struct {
+		line     string
+		expected string
+		err      error
+	}{
+		{"1024 , 21 : USERID : UNIX : bob\r\n", "bob", nil},
+		{"1024,21:userid:OTHER:bob:with:colons", "bob:with:colons", nil},
+		{"1024,21:ERROR:NO-USER", "", errors.New("ident error: NO-USER")},
+		{"1024,21:USERID:UNIX", "", errors.New("malformed ident response: '1024,21:USERID:UNIX'")},
+		{"1024,21:USERID:UNIX: ", "", errors.New("empty ident")},
+		{"1024,21", "", errors.New("malformed ident response: '1024,21'")},
+		{"1024:USERID:UNIX:bob", "", errors.New("malformed ident ports: '1024'")},
+		{"1025,21:USERID:UNIX:bob", "", errors.New("unexpected ident ports: '1025,21'")},
+		{"1024,x:USERID:UNIX:bob", "", errors.New("unexpected ident ports: '1024,x'")},
+		{"1024,21:WHO:UNIX:bob", "", errors.New("unknown ident response type: 'WHO'")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			ident, err := parseIdentResponse(tt.line, "1024", "21")
+			if (err == nil) != (tt.err == nil) || (err != nil && err.Error() != tt.err.Error()) {
+				t.Fatalf("expected error '%v' got '%v'", tt.err, err)
+			}
+
+			if ident != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, ident)
+			}
+		})
+	}
+}
+
+// serveIdent answers a single query with the response once it has checked
+// the query is for the ports 1024 and 21, an empty response never replies
+func serveIdent(t *testing.T, response string) (string, func()) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		query, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil || query != "1024, 21\r\n" {
+			c.Write([]byte("0,0:ERROR:INVALID-PORT\r\n"))
+			return
+		}
+
+		if len(response) == 0 {
+			// wait for the client to give up
+			c.Read(make([]byte, 1))
+			return
+		}
+
+		c.Write([]byte(response))
+	}()
+
+	return l.Addr().String(), func() {
+		l.Close()
+		<-done
+	}
+}
+
+func TestQueryIdent(t *testing.T) {
+	var tests = []struct {
+		name     string
+		response string
+		expected string
+		err      bool
+	}{
+		{"userid", "1024 , 21 : USERID : UNIX : bob\r\n", "bob", false},
+		{"error", "1024 , 21 : ERROR : HIDDEN-USER\r\n", "", true},
+		{"no newline", "1024 , 21 : USERID : UNIX : bob", "", true},
+		{"timeout", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, stop := serveIdent(t, tt.response)
+			defer stop()
+
+			ident, err := queryIdent(context.Background(), addr, "1024", "21", 200*time.Millisecond)
+			if tt.err != (err != nil) {
+				t.Fatalf("expected error %t got %v", tt.err, err)
+			}
+
+			if ident != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, ident)
+			}
+		})
+	}
+}
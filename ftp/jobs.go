@@ -0,0 +1,170 @@
+package ftp
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/schedule"
+	"github.com/pkg/errors"
+)
+
+// Jobs returns the server's own jobs that can be run by a schedule.Scheduler
+//
+//	dated                       creates the day's dated directories
+//	rotate                      deletes or moves old directories
+//	expire_users                deals with expired users
+//	expire_messages             removes messages past the inbox's retention
+//	stats_prune <days>          removes stats periods older than days
+//	wipe <glob> [<glob>...]     removes everything matching the globs
+//	recalc_sizes [<path>]       recalculates the sizes of directories
+func (s *Server) Jobs() map[string]schedule.JobFunc {
+	return map[string]schedule.JobFunc{
+		"dated": func(ctx context.Context, args []string) error {
+			return s.createDatedDirsNow(time.Now())
+		},
+		"rotate": func(ctx context.Context, args []string) error {
+			return s.rotateNow(time.Now())
+		},
+		"expire_users": func(ctx context.Context, args []string) error {
+			return s.expireUsersNow()
+		},
+		"expire_messages": func(ctx context.Context, args []string) error {
+			return s.expireMessagesNow(time.Now())
+		},
+		"stats_prune":  s.pruneStats,
+		"wipe":         s.wipe,
+		"recalc_sizes": s.recalculateSizes,
+	}
+}
+
+// expireUsersNow asks the Authenticator to deal with any expired users
+func (s *Server) expireUsersNow() error {
+	expired, err := s.auth.ExpireUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range expired {
+		s.Logger().Info("expired user", logging.User(name))
+	}
+
+	return nil
+}
+
+// expireMessagesNow removes messages older than the inbox's retention
+func (s *Server) expireMessagesNow(now time.Time) error {
+	expired, err := s.inbox.Expire(now)
+	if err != nil {
+		return err
+	}
+
+	if expired > 0 {
+		s.Logger().Info("expired messages", logging.F("count", expired))
+	}
+
+	return nil
+}
+
+// createDatedDirsNow creates the dated directories of the day
+func (s *Server) createDatedDirsNow(now time.Time) error {
+	created, err := s.fs.CreateDatedDirs(now)
+
+	for _, dir := range created {
+		s.Logger().Info("created dated dir", logging.Path(dir))
+	}
+
+	return err
+}
+
+// rotateNow deletes or moves directories old enough to be rotated
+func (s *Server) rotateNow(now time.Time) error {
+	rotated, err := s.fs.Rotate(now)
+
+	for _, r := range rotated {
+		switch {
+		case r.Err != nil:
+			s.Logger().Error("unable to rotate", logging.Path(r.Path), logging.Err(r.Err))
+		case len(r.Dest) > 0:
+			s.Logger().Info("rotated", logging.Path(r.Path), logging.F("dest", r.Dest))
+		default:
+			s.Logger().Info("rotated by wiping", logging.Path(r.Path))
+		}
+	}
+
+	return err
+}
+
+// pruneStats removes the stats of days, weeks and months that ended before
+// the given number of days ago
+func (s *Server) pruneStats(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("stats_prune requires a number of days")
+	}
+
+	days, err := strconv.Atoi(args[0])
+	if err != nil || days < 1 {
+		return errors.Errorf("bad number of days '%s'", args[0])
+	}
+
+	pruned, err := s.stats.Prune(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+
+	if pruned > 0 {
+		s.Logger().Info("pruned stats", logging.F("count", pruned))
+	}
+
+	return nil
+}
+
+// wipe removes everything matching each glob
+func (s *Server) wipe(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("wipe requires a glob")
+	}
+
+	for _, pattern := range args {
+		removed, err := s.fs.Purge(pattern)
+
+		if removed.Files > 0 || removed.Dirs > 0 {
+			s.Logger().Info(
+				"wiped",
+				logging.Path(pattern),
+				logging.F("files", removed.Files),
+				logging.F("dirs", removed.Dirs),
+				logging.Bytes(removed.Bytes),
+			)
+		}
+
+		if err != nil {
+			return errors.WithMessage(err, pattern)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recalculateSizes recalculates the sizes of the directories under the
+// path, defaulting to the root
+func (s *Server) recalculateSizes(ctx context.Context, args []string) error {
+	path := "/"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	size, err := s.fs.RecalculateSizes(path)
+	if err != nil {
+		return err
+	}
+
+	s.Logger().Info("recalculated sizes", logging.Path(path), logging.Bytes(size))
+
+	return nil
+}
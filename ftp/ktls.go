@@ -0,0 +1,285 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// errKTLSUnsupported is returned when kernel TLS can't be used for the
+// connection, it carries on using crypto/tls
+var errKTLSUnsupported = errors.New("kernel tls unsupported")
+
+// dataTLS wraps a data connection in TLS. With KTLS set, and the kernel
+// able to do it, the connection can hand sending over to the kernel so
+// downloads can use sendfile
+func (s *Server) dataTLS(conn net.Conn) net.Conn {
+	if !s.KTLS || !ktlsAvailable() {
+		return tls.Server(conn, s.TLSConfig())
+	}
+
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return tls.Server(conn, s.TLSConfig())
+	}
+
+	return newKTLSConn(tcp, s.TLSConfig())
+}
+
+// ktlsConn is a TLS data connection that can have the kernel encrypt what
+// it sends once the handshake is done. Only TLS 1.3 with AES-GCM is
+// offloaded, reads are always left to crypto/tls
+type ktlsConn struct {
+	*tls.Conn
+	raw *net.TCPConn
+
+	secrets keyLog
+
+	mtx sync.Mutex
+	// the client can use TLS 1.3 with AES-GCM so session tickets were
+	// disabled to leave the kernel starting from the first record
+	offloadable bool
+	// crypto/tls has written application data so its sequence number is
+	// no longer known
+	wrote bool
+	// sending is done by the kernel
+	offloaded bool
+	// offloading has been tried and failed
+	failed bool
+}
+
+func newKTLSConn(raw *net.TCPConn, config *tls.Config) *ktlsConn {
+	k := ktlsConn{raw: raw}
+
+	config = config.Clone()
+	config.KeyLogWriter = &k.secrets
+
+	// tickets are sent after the handshake using the keys handed to the
+	// kernel, without them the kernel starts from the first record. They
+	// are only disabled for clients that could be offloaded so everyone
+	// else can still resume their sessions
+	offloadConfig := config.Clone()
+	offloadConfig.SessionTicketsDisabled = true
+
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if !offloadableHello(hello) {
+			return nil, nil
+		}
+
+		k.mtx.Lock()
+		k.offloadable = true
+		k.mtx.Unlock()
+
+		return offloadConfig, nil
+	}
+
+	k.Conn = tls.Server(&ktlsWriteGuard{Conn: raw, k: &k}, config)
+
+	return &k
+}
+
+// offloadableHello returns true if the client offers TLS 1.3 and one of
+// the AES-GCM cipher suites the kernel supports
+func offloadableHello(hello *tls.ClientHelloInfo) bool {
+	var tls13 bool
+	for _, v := range hello.SupportedVersions {
+		tls13 = tls13 || v == tls.VersionTLS13
+	}
+
+	if !tls13 {
+		return false
+	}
+
+	for _, c := range hello.CipherSuites {
+		if c == tls.TLS_AES_128_GCM_SHA256 || c == tls.TLS_AES_256_GCM_SHA384 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ktlsWriteGuard stops crypto/tls writing to the connection once the
+// kernel is sending. After the handshake crypto/tls only writes by itself
+// to reply to a KeyUpdate from the peer or send an alert before a Read
+// fails, neither can be sent with its keys as the kernel has moved the
+// sequence number on. They are dropped instead, the peer only changes the
+// keys it reads with when it gets a KeyUpdate so carries on reading what
+// the kernel sends, and a failed Read is returned to the caller anyway
+type ktlsWriteGuard struct {
+	net.Conn
+	k *ktlsConn
+}
+
+func (g *ktlsWriteGuard) Write(p []byte) (int, error) {
+	g.k.mtx.Lock()
+	offloaded := g.k.offloaded
+	g.k.mtx.Unlock()
+
+	if offloaded {
+		return len(p), nil
+	}
+
+	return g.Conn.Write(p)
+}
+
+// Write sends p using the kernel once offloaded
+func (k *ktlsConn) Write(p []byte) (int, error) {
+	k.mtx.Lock()
+	offloaded := k.offloaded
+	if !offloaded {
+		k.wrote = true
+	}
+	k.mtx.Unlock()
+
+	if offloaded {
+		return k.raw.Write(p)
+	}
+
+	return k.Conn.Write(p)
+}
+
+// Close sends the close_notify alert through the kernel once offloaded,
+// crypto/tls would use the wrong keys
+func (k *ktlsConn) Close() error {
+	k.mtx.Lock()
+	offloaded := k.offloaded
+	k.mtx.Unlock()
+
+	if !offloaded {
+		return k.Conn.Close()
+	}
+
+	// the client has what it needs, a missing alert is only a warning
+	ktlsCloseNotify(k.raw)
+
+	return k.raw.Close()
+}
+
+// offload completes the handshake and hands sending to the kernel,
+// returning the TCP connection to send on. ok is false if the kernel can't
+// take over and crypto/tls should carry on
+func (k *ktlsConn) offload() (raw *net.TCPConn, ok bool, err error) {
+	if err := k.Handshake(); err != nil {
+		return nil, true, err
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.offloaded {
+		return k.raw, true, nil
+	}
+
+	if !k.offloadable || k.wrote || k.failed {
+		return nil, false, nil
+	}
+
+	state := k.ConnectionState()
+
+	key, iv, err := ktlsKeys(state, k.secrets.serverSecret())
+	if err == nil {
+		err = enableKTLS(k.raw, state.CipherSuite, key, iv)
+	}
+
+	if err != nil {
+		k.failed = true
+		return nil, false, nil
+	}
+
+	k.offloaded = true
+
+	return k.raw, true, nil
+}
+
+// keyLog keeps the server's application traffic secret from the lines
+// crypto/tls writes to a KeyLogWriter
+type keyLog struct {
+	mtx    sync.Mutex
+	secret []byte
+}
+
+// serverTrafficSecret is the label of the secret the server's first
+// application records are encrypted with
+const serverTrafficSecret = "SERVER_TRAFFIC_SECRET_0"
+
+// Write parses a `<label> <client random> <secret>` line
+func (l *keyLog) Write(line []byte) (int, error) {
+	fields := bytes.Fields(line)
+	if len(fields) != 3 || string(fields[0]) != serverTrafficSecret {
+		return len(line), nil
+	}
+
+	secret := make([]byte, hex.DecodedLen(len(fields[2])))
+	if _, err := hex.Decode(secret, fields[2]); err != nil {
+		return 0, err
+	}
+
+	l.mtx.Lock()
+	l.secret = secret
+	l.mtx.Unlock()
+
+	return len(line), nil
+}
+
+func (l *keyLog) serverSecret() []byte {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.secret
+}
+
+// ktlsKeys derives the key and iv of the server's application records from
+// its traffic secret, as in RFC 8446 section 7.3
+func ktlsKeys(state tls.ConnectionState, secret []byte) (key, iv []byte, err error) {
+	if state.Version != tls.VersionTLS13 || len(secret) == 0 {
+		return nil, nil, errKTLSUnsupported
+	}
+
+	var h func() hash.Hash
+	var keyLen int
+
+	switch state.CipherSuite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		h, keyLen = sha256.New, 16
+	case tls.TLS_AES_256_GCM_SHA384:
+		h, keyLen = sha512.New384, 32
+	default:
+		return nil, nil, errKTLSUnsupported
+	}
+
+	if key, err = expandLabel(h, secret, "key", keyLen); err != nil {
+		return nil, nil, err
+	}
+
+	if iv, err = expandLabel(h, secret, "iv", 12); err != nil {
+		return nil, nil, err
+	}
+
+	return key, iv, nil
+}
+
+// expandLabel is HKDF-Expand-Label with an empty context
+func expandLabel(h func() hash.Hash, secret []byte, label string, n int) ([]byte, error) {
+	label = "tls13 " + label
+
+	info := []byte{byte(n >> 8), byte(n), byte(len(label))}
+	info = append(info, label...)
+	info = append(info, 0)
+
+	out := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.Expand(h, secret, info), out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,153 @@
+//go:build linux
+// +build linux
+
+package ftp
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// from linux/tls.h and linux/tcp.h, not in syscall
+const (
+	solTLS           = 282
+	tcpULP           = 31
+	tlsTX            = 1
+	tlsSetRecordType = 1
+
+	tls13Version       = 0x0304
+	tlsCipherAESGCM128 = 51
+	tlsCipherAESGCM256 = 52
+
+	// the record type and description of a close_notify alert
+	recordTypeAlert   = 21
+	alertLevelWarning = 1
+	alertCloseNotify  = 0
+)
+
+// cryptoInfoAESGCM128 and cryptoInfoAESGCM256 are struct
+// tls12_crypto_info_aes_gcm_128 and 256. The 12 byte iv of TLS 1.3 is
+// split in to the 4 byte salt and the 8 bytes xored with the sequence
+// number, which starts at zero
+type cryptoInfoAESGCM128 struct {
+	version uint16
+	cipher  uint16
+	iv      [8]byte
+	key     [16]byte
+	salt    [4]byte
+	recSeq  [8]byte
+}
+
+type cryptoInfoAESGCM256 struct {
+	version uint16
+	cipher  uint16
+	iv      [8]byte
+	key     [32]byte
+	salt    [4]byte
+	recSeq  [8]byte
+}
+
+// availableULP lists the upper layer protocols loaded in to the kernel
+const availableULP = "/proc/sys/net/ipv4/tcp_available_ulp"
+
+var (
+	ktlsOnce      sync.Once
+	ktlsSupported bool
+)
+
+// ktlsAvailable returns true if the tls kernel module is loaded
+func ktlsAvailable() bool {
+	ktlsOnce.Do(func() {
+		b, err := ioutil.ReadFile(availableULP)
+		if err != nil {
+			return
+		}
+
+		for _, ulp := range strings.Fields(string(b)) {
+			ktlsSupported = ktlsSupported || ulp == "tls"
+		}
+	})
+
+	return ktlsSupported
+}
+
+// enableKTLS hands encrypting what is sent on conn to the kernel, starting
+// from the first application record. Needs the tls kernel module
+func enableKTLS(conn *net.TCPConn, suite uint16, key, iv []byte) error {
+	var info []byte
+
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		c := cryptoInfoAESGCM128{version: tls13Version, cipher: tlsCipherAESGCM128}
+		copy(c.iv[:], iv[4:])
+		copy(c.key[:], key)
+		copy(c.salt[:], iv[:4])
+
+		info = (*[unsafe.Sizeof(c)]byte)(unsafe.Pointer(&c))[:]
+
+	case tls.TLS_AES_256_GCM_SHA384:
+		c := cryptoInfoAESGCM256{version: tls13Version, cipher: tlsCipherAESGCM256}
+		copy(c.iv[:], iv[4:])
+		copy(c.key[:], key)
+		copy(c.salt[:], iv[:4])
+
+		info = (*[unsafe.Sizeof(c)]byte)(unsafe.Pointer(&c))[:]
+
+	default:
+		return errKTLSUnsupported
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+
+	err = raw.Control(func(fd uintptr) {
+		if serr = syscall.SetsockoptString(int(fd), syscall.SOL_TCP, tcpULP, "tls"); serr != nil {
+			return
+		}
+		serr = syscall.SetsockoptString(int(fd), solTLS, tlsTX, string(info))
+	})
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
+
+// ktlsCloseNotify sends a close_notify alert on a connection the kernel
+// is encrypting
+func ktlsCloseNotify(conn *net.TCPConn) error {
+	oob := make([]byte, syscall.CmsgSpace(1))
+
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = solTLS
+	h.Type = tlsSetRecordType
+	h.SetLen(syscall.CmsgLen(1))
+
+	oob[syscall.CmsgLen(0)] = recordTypeAlert
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+
+	err = raw.Write(func(fd uintptr) bool {
+		serr = syscall.Sendmsg(int(fd), []byte{alertLevelWarning, alertCloseNotify}, oob, nil, 0)
+		return serr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
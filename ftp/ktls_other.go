@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package ftp
+
+import (
+	"net"
+)
+
+// ktlsAvailable is only true on linux
+func ktlsAvailable() bool {
+	return false
+}
+
+// enableKTLS is only supported on linux
+func enableKTLS(conn *net.TCPConn, suite uint16, key, iv []byte) error {
+	return errKTLSUnsupported
+}
+
+// ktlsCloseNotify is never called as enableKTLS always fails
+func ktlsCloseNotify(conn *net.TCPConn) error {
+	return errKTLSUnsupported
+}
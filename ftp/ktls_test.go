@@ -0,0 +1,281 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// unhex decodes the space separated hex used by RFC 8448
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("unexpected error decoding '%s': %s", s, err)
+	}
+
+	return b
+}
+
+// the traffic secrets and the keys derived from them in the simple 1-RTT
+// handshake of RFC 8448 section 3
+var rfc8448Secrets = []struct {
+	name   string
+	secret string
+	key    string
+	iv     string
+}{
+	{
+		"server handshake",
+		"b6 7b 7d 69 0c c1 6c 4e 75 e5 42 13 cb 2d 37 b4 e9 c9 12 bc de d9 10 5d 42 be fd 59 d3 91 ad 38",
+		"3f ce 51 60 09 c2 17 27 d0 f2 e4 e8 6e e4 03 bc",
+		"5d 31 3e b2 67 12 76 ee 13 00 0b 30",
+	},
+	{
+		"client handshake",
+		"b3 ed db 12 6e 06 7f 35 a7 80 b3 ab f4 5e 2d 8f 3b 1a 95 07 38 f5 2e 96 00 74 6a 0e 27 a5 5a 21",
+		"db fa a6 93 d1 76 2c 5b 66 6a f5 d9 50 25 8d 01",
+		"5b d3 c7 1b 83 6e 0b 76 bb 73 26 5f",
+	},
+	{
+		"server application",
+		"a1 1a f9 f0 55 31 f8 56 ad 47 11 6b 45 a9 50 32 82 04 b4 f4 4b fb 6b 3a 4b 4f 1f 3f cb 63 16 43",
+		"9f 02 28 3b 6c 9c 07 ef c2 6b b9 f2 ac 92 e3 56",
+		"cf 78 2b 88 dd 83 54 9a ad f1 e9 84",
+	},
+}
+
+func TestExpandLabel(t *testing.T) {
+	for _, tt := range rfc8448Secrets {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := unhex(t, tt.secret)
+
+			key, err := expandLabel(sha256.New, secret, "key", 16)
+			if err != nil {
+				t.Fatalf("unexpected error expanding key: %s", err)
+			}
+
+			if !bytes.Equal(key, unhex(t, tt.key)) {
+				t.Errorf("expected key '%s' got '% x'", tt.key, key)
+			}
+
+			iv, err := expandLabel(sha256.New, secret, "iv", 12)
+			if err != nil {
+				t.Fatalf("unexpected error expanding iv: %s", err)
+			}
+
+			if !bytes.Equal(iv, unhex(t, tt.iv)) {
+				t.Errorf("expected iv '%s' got '% x'", tt.iv, iv)
+			}
+		})
+	}
+}
+
+func TestKTLSKeys(t *testing.T) {
+	// RFC 8448 uses TLS_AES_128_GCM_SHA256
+	app := rfc8448Secrets[2]
+
+	var tests = []struct {
+		name   string
+		state  tls.ConnectionState
+		secret string
+		err    error
+	}{
+		{"aes 128", tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}, app.secret, nil},
+		{"tls 1.2", tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, app.secret, errKTLSUnsupported},
+		{"chacha", tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_CHACHA20_POLY1305_SHA256}, app.secret, errKTLSUnsupported},
+		{"no secret", tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}, "", errKTLSUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, iv, err := ktlsKeys(tt.state, unhex(t, tt.secret))
+			if err != tt.err {
+				t.Fatalf("expected error '%v' got '%v'", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !bytes.Equal(key, unhex(t, app.key)) {
+				t.Errorf("expected key '%s' got '% x'", app.key, key)
+			}
+
+			if !bytes.Equal(iv, unhex(t, app.iv)) {
+				t.Errorf("expected iv '%s' got '% x'", app.iv, iv)
+			}
+		})
+	}
+
+	// the key of TLS_AES_256_GCM_SHA384 is 32 bytes
+	key, iv, err := ktlsKeys(tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_256_GCM_SHA384}, unhex(t, app.secret))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(key) != 32 || len(iv) != 12 {
+		t.Errorf("expected 32 byte key and 12 byte iv got %d and %d", len(key), len(iv))
+	}
+}
+
+func TestKeyLog(t *testing.T) {
+	var l keyLog
+
+	lines := []string{
+		"CLIENT_HANDSHAKE_TRAFFIC_SECRET 00 b3eddb12\n",
+		"SERVER_TRAFFIC_SECRET_0 00 a11af9f0\n",
+		"CLIENT_TRAFFIC_SECRET_0 00 9e40646c\n",
+	}
+
+	for _, line := range lines {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error writing '%s': %s", line, err)
+		}
+	}
+
+	if !bytes.Equal(l.serverSecret(), []byte{0xa1, 0x1a, 0xf9, 0xf0}) {
+		t.Errorf("expected server secret 'a11af9f0' got '%x'", l.serverSecret())
+	}
+}
+
+func TestOffloadableHello(t *testing.T) {
+	var tests = []struct {
+		name     string
+		versions []uint16
+		suites   []uint16
+		expected bool
+	}{
+		{"tls 1.3 aes", []uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{tls.TLS_CHACHA20_POLY1305_SHA256, tls.TLS_AES_256_GCM_SHA384}, true},
+		{"tls 1.3 chacha", []uint16{tls.VersionTLS13}, []uint16{tls.TLS_CHACHA20_POLY1305_SHA256}, false},
+		{"tls 1.2", []uint16{tls.VersionTLS12}, []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hello := tls.ClientHelloInfo{SupportedVersions: tt.versions, CipherSuites: tt.suites}
+
+			if got := offloadableHello(&hello); got != tt.expected {
+				t.Errorf("expected %t got %t", tt.expected, got)
+			}
+		})
+	}
+}
+
+// ticketCache records whether the server sent a session ticket
+type ticketCache struct {
+	mtx     sync.Mutex
+	tickets int
+}
+
+func (c *ticketCache) Get(string) (*tls.ClientSessionState, bool) { return nil, false }
+
+func (c *ticketCache) Put(_ string, cs *tls.ClientSessionState) {
+	if cs == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	c.tickets++
+	c.mtx.Unlock()
+}
+
+func TestKTLSTickets(t *testing.T) {
+	var tests = []struct {
+		name     string
+		version  uint16
+		expected bool
+	}{
+		{"tls 1.3", tls.VersionTLS13, false},
+		{"tls 1.2", tls.VersionTLS12, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("unexpected error listening: %s", err)
+			}
+			defer l.Close()
+
+			config := newTestTLSConfig(t)
+
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				k := newKTLSConn(conn.(*net.TCPConn), config)
+				defer k.Close()
+
+				k.Write([]byte("hello"))
+			}()
+
+			cache := ticketCache{}
+
+			conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+				InsecureSkipVerify: true,
+				MaxVersion:         tt.version,
+				ClientSessionCache: &cache,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error dialing: %s", err)
+			}
+			defer conn.Close()
+
+			// TLS 1.3 tickets are read along with the data
+			b := make([]byte, 5)
+			if _, err := io.ReadFull(conn, b); err != nil || string(b) != "hello" {
+				t.Fatalf("expected 'hello' got '%s' (%v)", b, err)
+			}
+
+			cache.mtx.Lock()
+			ticket := cache.tickets > 0
+			cache.mtx.Unlock()
+
+			if ticket != tt.expected {
+				t.Errorf("expected ticket %t got %t", tt.expected, ticket)
+			}
+		})
+	}
+}
+
+func TestKTLSWriteGuard(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	k := ktlsConn{}
+	g := ktlsWriteGuard{Conn: server, k: &k}
+
+	go func() {
+		g.Write([]byte("before"))
+
+		k.mtx.Lock()
+		k.offloaded = true
+		k.mtx.Unlock()
+
+		// dropped rather than sent with crypto/tls's keys
+		if n, err := g.Write([]byte("after")); n != 5 || err != nil {
+			t.Errorf("expected write to be dropped got %d (%v)", n, err)
+		}
+
+		server.Close()
+	}()
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(client); err != nil {
+		t.Fatalf("unexpected error reading: %s", err)
+	}
+
+	if b.String() != "before" {
+		t.Errorf("expected 'before' got '%s'", b.String())
+	}
+}
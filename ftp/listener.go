@@ -0,0 +1,141 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/proxyproto"
+)
+
+// defaultBanner is the greeting used when neither the server or listener
+// have a banner
+const defaultBanner = "Welcome!"
+
+// ListenerOpts describes an extra address the Server accepts control
+// connections on, each feeds the same Server
+type ListenerOpts struct {
+	Name string
+
+	Host string `goftpd:"host"`
+	Port int    `goftpd:"port"`
+
+	// connections start with a TLS handshake, implicit FTPS, and don't
+	// need to send AUTH TLS. Their data connections are protected from the
+	// start and can't be switched to plaintext with PROT C
+	ImplicitTLS bool `goftpd:"implicit_tls"`
+
+	// replaces the server's banner as the greeting
+	Banner string `goftpd:"banner"`
+
+	// connections start with a PROXY protocol header giving the address
+	// of the real client, i.e. behind haproxy. only headers from the
+	// ProxyTrusted ips or cidrs are read, nobody is trusted if it's empty
+	ProxyProtocol bool     `goftpd:"proxy_protocol"`
+	ProxyTrusted  []string `goftpd:"proxy_trusted"`
+
+	commandPermissions *acl.CommandPermissions
+}
+
+// SetCommandPermissions sets the command permissions of sessions on the
+// listener, the server's are used if not set
+func (o *ListenerOpts) SetCommandPermissions(p *acl.CommandPermissions) { o.commandPermissions = p }
+
+// Addr returns the address the listener binds to
+func (o *ListenerOpts) Addr() string {
+	return net.JoinHostPort(o.Host, fmt.Sprintf("%d", o.Port))
+}
+
+// listener is a bound ListenerOpts
+type listener struct {
+	*ListenerOpts
+	net.Listener
+}
+
+// listen binds the listener unless a socket was inherited for it, implicit
+// TLS listeners wrap their connections using the server's TLS config
+func (s *Server) listen(opts *ListenerOpts) (*listener, error) {
+	l, ok := s.inherit(opts)
+	if !ok {
+		var err error
+
+		l, err = net.Listen("tcp", opts.Addr())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the header comes before the tls handshake
+	if opts.ProxyProtocol {
+		pl, err := proxyproto.NewListener(l, opts.ProxyTrusted, 0)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l = pl
+	}
+
+	if opts.ImplicitTLS {
+		l = tls.NewListener(l, s.TLSConfig())
+	}
+
+	return &listener{opts, l}, nil
+}
+
+// inherit takes the inherited socket named after the listener, or failing
+// that one bound to its address
+func (s *Server) inherit(opts *ListenerOpts) (net.Listener, bool) {
+	if ls := s.inherited[opts.Name]; len(ls) > 0 {
+		s.inherited[opts.Name] = ls[1:]
+		return ls[0], true
+	}
+
+	for name, ls := range s.inherited {
+		for i, l := range ls {
+			addr, ok := l.Addr().(*net.TCPAddr)
+			if !ok || addr.Port != opts.Port || !sameHost(opts.Host, addr.IP) {
+				continue
+			}
+
+			s.inherited[name] = append(ls[:i:i], ls[i+1:]...)
+			return l, true
+		}
+	}
+
+	return nil, false
+}
+
+// sameHost checks to see if the host is the ip, an empty or unspecified
+// host only matches an unspecified ip
+func sameHost(host string, ip net.IP) bool {
+	h := net.ParseIP(host)
+
+	if len(host) == 0 || (h != nil && h.IsUnspecified()) {
+		return ip.IsUnspecified()
+	}
+
+	return h != nil && h.Equal(ip)
+}
+
+// listeners returns the server's own Host and Port followed by any extra
+// listeners
+func (s *Server) listeners() []*ListenerOpts {
+	all := []*ListenerOpts{
+		{
+			Name:          "default",
+			Host:          s.Host,
+			Port:          s.Port,
+			ProxyProtocol: s.ProxyProtocol,
+			ProxyTrusted:  s.ProxyTrusted,
+		},
+	}
+
+	return append(all, s.extraListeners...)
+}
+
+// accepted is a connection waiting for a session
+type accepted struct {
+	conn     net.Conn
+	listener *ListenerOpts
+}
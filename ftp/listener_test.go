@@ -0,0 +1,108 @@
+package ftp
+
+import (
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestListeners(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{Banner: "Welcome"})
+	defer done()
+
+	s.addUser(t, "someone", nil)
+
+	r, err := acl.NewCommandRule("who *")
+	if err != nil {
+		t.Fatalf("unexpected error parsing permission: %s", err)
+	}
+
+	permissions, err := acl.NewCommandPermissions([]acl.CommandRule{r})
+	if err != nil {
+		t.Fatalf("unexpected error creating permissions: %s", err)
+	}
+
+	lan := &ListenerOpts{Name: "lan", Banner: "Welcome to the lan"}
+	lan.SetCommandPermissions(permissions)
+
+	ftps := &ListenerOpts{Name: "ftps", ImplicitTLS: true}
+
+	addrs, stop := s.listen(t, lan, ftps)
+	defer stop()
+
+	var tests = []struct {
+		listener string
+		implicit bool
+		greeting string
+		who      int
+	}{
+		{"default", false, "Welcome", 530},
+		{"lan", false, "Welcome to the lan", 200},
+		{"ftps", true, "Welcome", 530},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.listener, func(t *testing.T) {
+			c := dial(t, addrs[tt.listener], tt.implicit)
+			defer c.Close()
+
+			if c.greeting != tt.greeting {
+				t.Fatalf("expected greeting '%s' got '%s'", tt.greeting, c.greeting)
+			}
+
+			c.login("someone")
+
+			c.expect("SITE WHO", tt.who)
+		})
+	}
+}
+
+func TestImplicitTLS(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{PublicIP: "127.0.0.1"})
+	defer done()
+
+	s.addUser(t, "someone", nil)
+
+	addrs, stop := s.listen(t, &ListenerOpts{Name: "ftps", ImplicitTLS: true})
+	defer stop()
+
+	c := dial(t, addrs["ftps"], true)
+	defer c.Close()
+
+	// already using TLS so AUTH isn't sent
+	c.login("someone")
+
+	c.expect("PROT C", 534)
+
+	// protected without sending PROT P
+	data := c.pasv(true)
+	c.expect("STOR secure.txt", 150)
+
+	if _, err := data.Write([]byte("over tls")); err != nil {
+		t.Fatalf("unexpected error uploading: %s", err)
+	}
+	data.Close()
+
+	if code, msg := c.read(); code != 226 {
+		t.Fatalf("expected 226 got %d %s", code, msg)
+	}
+
+	// plaintext fails the handshake
+	data = c.pasv(false)
+	c.expect("STOR plain.txt", 150)
+
+	data.Write([]byte("in the clear\r\n"))
+	data.Close()
+
+	if code, msg := c.read(); code == 226 {
+		t.Fatalf("expected the plaintext upload to fail got %d %s", code, msg)
+	}
+
+	c.expect("MLST secure.txt", 250)
+
+	// the protection level survives REIN
+	c.expect("REIN", 220)
+	c.login("someone")
+	c.expect("PROT C", 534)
+	c.expect("PROT P", 200)
+}
@@ -0,0 +1,42 @@
+package ftp
+
+import (
+	"plugin"
+
+	"github.com/pkg/errors"
+)
+
+// PluginRegisterFunc is the name of the func a plugin exports, it is called
+// once the plugin is opened to register its commands and permission
+// scopes with cmd.Register, cmd.RegisterSite and acl.RegisterPermissionScope.
+// Its signature has to be `func() error`
+const PluginRegisterFunc = "Register"
+
+// LoadPlugins opens each of the Go plugins and calls their Register func.
+// Has to be called before the rest of the config is parsed so that acl
+// rules can use the scopes they register. Plugins have to be built with
+// the same version of Go and goftpd as the server
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return errors.WithMessagef(err, "opening plugin '%s'", path)
+		}
+
+		sym, err := p.Lookup(PluginRegisterFunc)
+		if err != nil {
+			return errors.WithMessagef(err, "plugin '%s'", path)
+		}
+
+		register, ok := sym.(func() error)
+		if !ok {
+			return errors.Errorf("plugin '%s': %s is not a func() error", path, PluginRegisterFunc)
+		}
+
+		if err := register(); err != nil {
+			return errors.WithMessagef(err, "registering plugin '%s'", path)
+		}
+	}
+
+	return nil
+}
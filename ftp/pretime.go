@@ -0,0 +1,53 @@
+package ftp
+
+import (
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// newRelease announces a release created in a section. When the section is
+// one looked up the pre time is found first, in the background as the api
+// may be slow
+func (s *Server) newRelease(path string) {
+	entry, err := s.dirlog.Get(path)
+	if err != nil || entry == nil {
+		return
+	}
+
+	if !s.pretime.Enabled(entry.Section) {
+		s.announceNewDir(*entry)
+		return
+	}
+
+	go s.lookupPretime(*entry)
+}
+
+// lookupPretime records the pre time of the release in the dirlog, flagging
+// it if it is old, then announces it. A failed lookup is still announced
+func (s *Server) lookupPretime(entry dirlog.Entry) {
+	pretime, err := s.pretime.Lookup(entry.Name())
+	if err != nil {
+		s.Logger().Warn("unable to lookup pretime", logging.Path(entry.Path), logging.Err(err))
+	}
+
+	if !pretime.IsZero() {
+		entry.Pretime, entry.Old = pretime, s.pretime.Old(pretime, entry.Time)
+
+		if err := s.dirlog.SetPretime(entry.Path, entry.Pretime, entry.Old); err != nil {
+			s.Logger().Error("unable to record pretime", logging.Path(entry.Path), logging.Err(err))
+		}
+	}
+
+	if entry.Old {
+		age, _ := entry.Age()
+
+		s.Logger().Warn("old release",
+			logging.Path(entry.Path),
+			logging.User(entry.User),
+			logging.F("age", cookie.FormatAge(age)),
+		)
+	}
+
+	s.announceNewDir(entry)
+}
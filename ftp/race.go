@@ -0,0 +1,180 @@
+package ftp
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/goftpd/goftpd/glftpd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/spy"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/goftpd/goftpd/webhook"
+)
+
+// recordRace adds an upload checked by the zipscript to the stats of its
+// release
+func (s *Server) recordRace(e vfs.Event) {
+	if e.Release == nil || len(e.User) == 0 {
+		return
+	}
+
+	_, err := s.race.Record(path.Dir(e.Path), race.Upload{
+		User:     e.User,
+		Group:    e.Group,
+		Bytes:    e.Size,
+		Duration: e.Duration,
+		Time:     e.Time,
+		Done:     e.Release.Done,
+		Total:    e.Release.Total,
+	})
+
+	if err != nil {
+		s.Logger().Error("unable to record race", logging.User(e.User), logging.Path(e.Path), logging.Err(err))
+	}
+}
+
+// completeRace marks a release the zipscript has seen completed and
+// announces it along with its winners to the spy feed, webhooks, glftpd
+// log and announce targets
+func (s *Server) completeRace(e vfs.Event) {
+	release, err := s.race.Complete(e.Path, e.Time)
+	if err != nil {
+		s.Logger().Error("unable to complete race", logging.Path(e.Path), logging.Err(err))
+		return
+	}
+
+	// nothing was recorded, i.e. the race db was replaced mid race
+	if release == nil {
+		release = &race.Release{
+			Path:      e.Path,
+			Bytes:     e.Size,
+			Completed: e.Time,
+		}
+
+		if e.Release != nil {
+			release.Files, release.Done, release.Total = e.Release.Total, e.Release.Done, e.Release.Total
+		}
+	}
+
+	users := release.Users()
+
+	var winner string
+	if len(users) > 0 {
+		winner = users[0].Name
+	}
+
+	s.Logger().Info("release complete",
+		logging.Path(e.Path),
+		logging.Bytes(release.Bytes),
+		logging.F("files", release.Files),
+		logging.F("racers", len(users)),
+		logging.F("winner", winner),
+	)
+
+	data := raceData(release)
+
+	s.spy.Publish(spy.Event{
+		Event: spy.EventRace,
+		Time:  e.Time,
+		User:  e.User,
+		Group: e.Group,
+		Path:  e.Path,
+		Bytes: release.Bytes,
+		Speed: release.Speed(),
+		Data:  data,
+	})
+
+	s.webhooks.Send(webhook.Payload{
+		Event: webhook.EventRace,
+		Time:  e.Time,
+		User:  e.User,
+		Group: e.Group,
+		Path:  e.Path,
+		Size:  release.Bytes,
+		Data:  data,
+	})
+
+	s.logRace(release)
+	s.announceComplete(release)
+}
+
+// raceData describes the standings of a release for the spy feed and
+// webhooks
+func raceData(release *race.Release) map[string]interface{} {
+	ranking := func(racers []race.Racer, users bool) []map[string]interface{} {
+		ranked := make([]map[string]interface{}, 0, len(racers))
+
+		for i, r := range racers {
+			m := map[string]interface{}{
+				"rank":    i + 1,
+				"files":   r.Files,
+				"bytes":   r.Bytes,
+				"percent": r.Percent(*release),
+				"speed":   r.Speed(),
+			}
+
+			if users {
+				m["user"], m["group"] = r.Name, r.Group
+			} else {
+				m["group"] = r.Name
+			}
+
+			ranked = append(ranked, m)
+		}
+
+		return ranked
+	}
+
+	return map[string]interface{}{
+		"files":   release.Files,
+		"elapsed": release.Elapsed().Seconds(),
+		"users":   ranking(release.Users(), true),
+		"groups":  ranking(release.Groups(), false),
+	}
+}
+
+// logRace writes the glftpd log lines of a completed release
+func (s *Server) logRace(release *race.Release) {
+	p := s.glftpdLog.Path(release.Path)
+
+	users, groups := release.Users(), release.Groups()
+
+	s.writeGlftpdLog(glftpd.LogComplete,
+		p,
+		glftpd.FormatMegabytes(release.Bytes),
+		strconv.Itoa(release.Files),
+		strconv.Itoa(int(release.Elapsed().Seconds())),
+		strconv.Itoa(len(users)),
+	)
+
+	for i, u := range users {
+		s.writeGlftpdLog(glftpd.LogRaceUser,
+			p,
+			strconv.Itoa(i+1),
+			u.Name+"@"+u.Group,
+			glftpd.FormatMegabytes(u.Bytes),
+			strconv.Itoa(u.Files),
+			strconv.Itoa(u.Percent(*release)),
+			fmt.Sprintf("%.0f", u.Speed()/1024),
+		)
+	}
+
+	for i, g := range groups {
+		s.writeGlftpdLog(glftpd.LogRaceGroup,
+			p,
+			strconv.Itoa(i+1),
+			g.Name,
+			glftpd.FormatMegabytes(g.Bytes),
+			strconv.Itoa(g.Files),
+			strconv.Itoa(g.Percent(*release)),
+		)
+	}
+}
+
+func (s *Server) removeRace(path string) {
+	if err := s.race.Remove(path); err != nil {
+		s.Logger().Error("unable to remove race", logging.Path(path), logging.Err(err))
+	}
+}
@@ -0,0 +1,26 @@
+package ftp
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/goftpd/goftpd/logging"
+)
+
+// logPanic logs a recovered panic along with the stack of the goroutine
+// that panicked, so must be called from the deferred func that recovered
+// it. Panics in commands run by runWithDeadline carry their own stack
+func (s *Server) logPanic(msg string, e interface{}, fields ...logging.Field) {
+	stack := debug.Stack()
+
+	if p, ok := e.(commandPanic); ok {
+		e, stack = p.value, p.stack
+	}
+
+	fields = append(fields,
+		logging.F("panic", fmt.Sprint(e)),
+		logging.F("stack", string(stack)),
+	)
+
+	s.Logger().Error(msg, fields...)
+}
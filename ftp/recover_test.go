@@ -0,0 +1,130 @@
+package ftp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// panicCommand panics whenever it is run
+type panicCommand struct{}
+
+func (c panicCommand) RequireState() cmd.SessionState { return cmd.SessionStateLoggedIn }
+
+func (c panicCommand) Execute(ctx context.Context, s cmd.Session, params []string) error {
+	panic("xpanic")
+}
+
+func TestSessionPanic(t *testing.T) {
+	var tests = []struct {
+		name    string
+		timeout int
+	}{
+		{"inline", 0},
+		{"with deadline", 5},
+	}
+
+	cmd.CommandMap["XPANIC"] = panicCommand{}
+	defer delete(cmd.CommandMap, "XPANIC")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := newTestServer(t, &ServerOpts{CommandTimeout: tt.timeout})
+			defer done()
+
+			s.addUser(t, "someone", nil)
+			s.addUser(t, "other", nil)
+
+			addrs, stop := s.listen(t)
+			defer stop()
+
+			crashing := dial(t, addrs["default"], false)
+			defer crashing.Close()
+
+			crashing.login("someone")
+
+			bystander := dial(t, addrs["default"], false)
+			defer bystander.Close()
+
+			bystander.login("other")
+
+			if msg := crashing.expect("XPANIC", 421); msg != "Internal error, closing control connection." {
+				t.Fatalf("unexpected reply: %s", msg)
+			}
+
+			crashing.expectClosed()
+
+			// the other session and the server carry on
+			bystander.expect("NOOP", 200)
+
+			again := dial(t, addrs["default"], false)
+			defer again.Close()
+
+			again.login("someone")
+			again.expect("NOOP", 200)
+
+			for _, info := range s.Sessions() {
+				if info.ID == 1 {
+					t.Fatalf("expected the crashed session to be removed: %+v", info)
+				}
+			}
+
+			log := s.log.String()
+			if !strings.Contains(log, "session crashed") || !strings.Contains(log, "xpanic") || !strings.Contains(log, "panicCommand.Execute") {
+				t.Fatalf("expected the panic to be logged with its stack: %s", log)
+			}
+		})
+	}
+}
+
+func TestPassivePanic(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{})
+	defer done()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dc := passiveDataConn{
+		ctx: ctx,
+		protect: func(net.Conn) net.Conn {
+			panic("xprotect")
+		},
+	}
+
+	go s.acceptPassive(ctx, &dc, ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error dialing: %s", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(s.log.String(), "passive data connection crashed") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the panic to be logged: %s", s.log.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := dc.Read(make([]byte, 1)); err != errDataCrashed {
+		t.Fatalf("expected '%s' got '%v'", errDataCrashed, err)
+	}
+
+	if _, err := dc.Write([]byte("x")); err != errDataCrashed {
+		t.Fatalf("expected '%s' got '%v'", errDataCrashed, err)
+	}
+
+	if log := s.log.String(); !strings.Contains(log, "xprotect") || !strings.Contains(log, "TestPassivePanic") {
+		t.Fatalf("expected the panic to be logged with its stack: %s", log)
+	}
+}
@@ -0,0 +1,219 @@
+package ftp
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// registerSession adds the session to those listed by Sessions
+func (s *Server) registerSession(session *Session) {
+	s.sessionsMtx.Lock()
+	defer s.sessionsMtx.Unlock()
+
+	s.sessions[session] = struct{}{}
+
+	// every connection is counted so the count doubles as the session's id
+	session.id = uint64(atomic.AddInt64(&s.counters.connections, 1))
+
+	session.infoMtx.Lock()
+	session.info.ID = session.id
+	session.infoMtx.Unlock()
+}
+
+// replaceSession lists the successor of an abandoned session in its place
+func (s *Server) replaceSession(old, session *Session) {
+	s.sessionsMtx.Lock()
+	defer s.sessionsMtx.Unlock()
+
+	delete(s.sessions, old)
+	s.sessions[session] = struct{}{}
+}
+
+// unregisterSession removes the session once it has disconnected
+func (s *Server) unregisterSession(session *Session) {
+	s.spyLogout(session)
+
+	s.sessionsMtx.Lock()
+	defer s.sessionsMtx.Unlock()
+
+	delete(s.sessions, session)
+}
+
+// Sessions returns a snapshot of every connected session, oldest first
+func (s *Server) Sessions() []cmd.SessionInfo {
+	s.sessionsMtx.RLock()
+
+	infos := make([]cmd.SessionInfo, 0, len(s.sessions))
+	for session := range s.sessions {
+		infos = append(infos, session.snapshot())
+	}
+
+	s.sessionsMtx.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Connected.Before(infos[j].Connected)
+	})
+
+	return infos
+}
+
+// transferCommands have the file they transfer shown in SITE WHO, the
+// parameters of other commands could contain passwords so are never kept
+var transferCommands = map[string]struct{}{
+	"RETR": {},
+	"STOR": {},
+	"APPE": {},
+}
+
+// startCommand records the command the session is about to run
+func (s *Session) startCommand(fields []string) {
+	command := strings.ToUpper(fields[0])
+
+	var file string
+	if _, ok := transferCommands[command]; ok {
+		file = strings.Join(fields[1:], " ")
+	}
+
+	// show which site command is being run
+	if command == "SITE" && len(fields) > 1 {
+		command += " " + strings.ToUpper(fields[1])
+	}
+
+	s.infoMtx.Lock()
+	defer s.infoMtx.Unlock()
+
+	s.info.Command = command
+	s.info.File = file
+	s.info.LastActive = time.Now()
+}
+
+// finishCommand records the session as idle, picking up any changes the
+// command made to the session
+func (s *Session) finishCommand() {
+	s.infoMtx.Lock()
+	defer s.infoMtx.Unlock()
+
+	s.syncInfo()
+
+	if _, ok := transferCommands[s.info.Command]; ok {
+		s.server.logTransfer(s, s.info)
+		s.server.spyTransfer(s, s.info)
+	}
+
+	if s.info.Transfer != cmd.TransferNone {
+		s.server.counters.finished(s.info.Transfer)
+	}
+
+	s.info.Command = ""
+	s.info.File = ""
+	s.info.Transfer = cmd.TransferNone
+	s.info.Bytes = 0
+	s.info.TransferStart = time.Time{}
+	s.info.LastActive = time.Now()
+}
+
+// syncInfo copies the session's state in to its info, must be called from
+// the session's own goroutine with the infoMtx held
+func (s *Session) syncInfo() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.info.Login = s.login
+	s.info.Anonymous = s.anonymous
+	s.info.Ident = s.ident
+	s.info.State = s.state
+	s.info.CWD = s.currentDir
+}
+
+// transferred records n bytes moving over the data connection
+func (s *Session) transferred(t cmd.Transfer, n int) {
+	if n <= 0 {
+		return
+	}
+
+	s.server.counters.transferred(t, n)
+
+	s.infoMtx.Lock()
+	defer s.infoMtx.Unlock()
+
+	if s.info.TransferStart.IsZero() {
+		s.info.TransferStart = time.Now()
+	}
+
+	s.info.Transfer = t
+	s.info.Bytes += int64(n)
+	s.info.LastActive = time.Now()
+}
+
+// snapshot returns the SessionInfo of the session, safe to call from any
+// goroutine
+func (s *Session) snapshot() cmd.SessionInfo {
+	s.infoMtx.Lock()
+	defer s.infoMtx.Unlock()
+
+	now := time.Now()
+
+	info := s.info.SessionInfo
+	info.Idle = now.Sub(s.info.LastActive)
+	info.Traced = s.tracing()
+
+	if !s.info.TransferStart.IsZero() {
+		if elapsed := now.Sub(s.info.TransferStart).Seconds(); elapsed > 0 {
+			info.Speed = float64(s.info.Bytes) / elapsed
+		}
+	}
+
+	return info
+}
+
+// sessionInfo is the SessionInfo of a session along with what is needed to
+// work out its idle time and speed
+type sessionInfo struct {
+	cmd.SessionInfo
+
+	LastActive    time.Time
+	TransferStart time.Time
+}
+
+// newSessionInfo starts the info of a newly connected session
+func newSessionInfo(conn net.Conn) sessionInfo {
+	now := time.Now()
+
+	info := sessionInfo{
+		LastActive: now,
+	}
+
+	info.Connected = now
+	info.IP = conn.RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(info.IP); err == nil {
+		info.IP = host
+	}
+
+	return info
+}
+
+// trackedDataConn reports the bytes moved over a data connection to the
+// session so they can be shown in SITE WHO
+type trackedDataConn struct {
+	cmd.DataConn
+
+	session *Session
+}
+
+func (t *trackedDataConn) Read(p []byte) (int, error) {
+	n, err := t.DataConn.Read(p)
+	t.session.transferred(cmd.TransferUpload, n)
+	return n, err
+}
+
+func (t *trackedDataConn) Write(p []byte) (int, error) {
+	n, err := t.DataConn.Write(p)
+	t.session.transferred(cmd.TransferDownload, n)
+	return n, err
+}
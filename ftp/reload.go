@@ -0,0 +1,50 @@
+package ftp
+
+import (
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// Reload replaces the SITE command permissions, templates and aliases with
+// those of opts, along with the command permissions of listeners of the
+// same name. Sessions use them from their next command, everything else in
+// opts needs a restart
+func (s *Server) Reload(opts *ServerOpts) {
+	s.reloadMtx.Lock()
+	defer s.reloadMtx.Unlock()
+
+	s.commandPermissions = opts.commandPermissions
+	s.templates = opts.templates
+	s.aliases = opts.aliases
+
+	for _, l := range s.extraListeners {
+		l.commandPermissions = nil
+
+		for _, o := range opts.extraListeners {
+			if o.Name == l.Name {
+				l.commandPermissions = o.commandPermissions
+			}
+		}
+	}
+}
+
+// commandAllowed checks the command against the listener's permissions if
+// it has its own, otherwise the server's
+func (s *Server) commandAllowed(l *ListenerOpts, command string, user *acl.User) bool {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+
+	if l != nil && l.commandPermissions != nil {
+		return l.commandPermissions.Match(command, user)
+	}
+
+	return s.commandPermissions.Match(command, user)
+}
+
+// Aliases returns the SITE aliases
+func (s *Server) Aliases() cmd.Aliases {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+
+	return s.aliases
+}
@@ -0,0 +1,94 @@
+package ftp
+
+import (
+	"context"
+
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/scan"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// scanActor is the actor of infected uploads in the audit log
+const scanActor = "scan"
+
+// scanUpload scans an upload in the background when its path is one
+// scanned, infected uploads are dealt with by handleInfected
+func (s *Server) scanUpload(e vfs.Event) {
+	if !s.scanner.Enabled(e.Path) {
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.logPanic("scan crashed", r, logging.User(e.User), logging.Path(e.Path))
+			}
+		}()
+
+		result, err := s.scanner.Scan(context.Background(), s.fs.RealPath(e.Path))
+		if err != nil {
+			s.Logger().Error("unable to scan upload", logging.User(e.User), logging.Path(e.Path), logging.Err(err))
+			return
+		}
+
+		if result.Infected {
+			s.handleInfected(e, result)
+		}
+	}()
+}
+
+// handleInfected quarantines or deletes an infected upload, records it in
+// the audit log and tells staff
+func (s *Server) handleInfected(e vfs.Event, result scan.Result) {
+	action := scan.ActionDelete
+	dest := ""
+
+	var err error
+
+	if dir := s.scanner.QuarantineDir(); len(dir) > 0 {
+		action = scan.ActionQuarantine
+		dest, err = s.fs.Quarantine(e.Path, dir)
+	} else {
+		_, err = s.fs.Remove(e.Path)
+	}
+
+	if err != nil {
+		s.Logger().Error("unable to "+action+" infected upload",
+			logging.User(e.User),
+			logging.Path(e.Path),
+			logging.F("signature", result.Signature),
+			logging.Err(err),
+		)
+
+		action = "left"
+	} else {
+		s.Logger().Warn("infected upload",
+			logging.User(e.User),
+			logging.Path(e.Path),
+			logging.F("signature", result.Signature),
+			logging.F("action", action),
+		)
+	}
+
+	params := []string{result.Signature, e.User, action}
+	if len(dest) > 0 {
+		params = append(params, dest)
+	}
+
+	s.recordAudit(audit.Entry{
+		Actor:  scanActor,
+		Action: "infected",
+		Target: e.Path,
+		Params: params,
+	})
+
+	s.scanner.Notify(cookie.Cookies{
+		"path":      e.Path,
+		"user":      e.User,
+		"group":     e.Group,
+		"signature": result.Signature,
+		"action":    action,
+	})
+}
@@ -0,0 +1,73 @@
+package ftp
+
+import (
+	"context"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// eventScripts maps the filesystem events scripts are run on to the
+// script event
+var eventScripts = map[vfs.EventType]string{
+	vfs.EventUpload:     script.EventUpload,
+	vfs.EventMakeDir:    script.EventMakeDir,
+	vfs.EventDeleteFile: script.EventDelete,
+	vfs.EventDeleteDir:  script.EventDelete,
+}
+
+// runScripts runs the scripts and Lua handlers for a change made to the filesystem by a
+// user, changes made by the server itself don't run scripts
+func (s *Server) runScripts(e vfs.Event) {
+	event, ok := eventScripts[e.Type]
+	if !ok || len(e.User) == 0 {
+		return
+	}
+
+	s.run(script.Vars{
+		Event:    event,
+		User:     e.User,
+		Group:    e.Group,
+		Path:     e.Path,
+		RealPath: s.fs.RealPath(e.Path),
+		Size:     e.Size,
+		CRC:      e.Checksum.CRC32,
+	})
+}
+
+// downloadScripts is a cmd.Hook run after every command, running the
+// download scripts once a RETR has finished successfully
+func (s *Server) downloadScripts(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	if hc.Name != "RETR" || r.Code != cmd.StatusDataClosedOK.Code || len(hc.Params) == 0 {
+		return
+	}
+
+	user, ok := session.User()
+	if !ok {
+		return
+	}
+
+	path := s.fs.Join(session.CWD(), hc.Params)
+
+	v := script.Vars{
+		Event:    script.EventDownload,
+		User:     user.Name,
+		Group:    user.PrimaryGroup,
+		Path:     path,
+		RealPath: s.fs.RealPath(path),
+	}
+
+	if finfo, err := s.fs.StatFile(path, user); err == nil {
+		v.Size = finfo.Size()
+		v.CRC = finfo.Checksum.CRC32
+	}
+
+	s.run(v)
+}
+
+// run runs the external programs and Lua handlers for the event
+func (s *Server) run(v script.Vars) {
+	s.scripts.Run(v)
+	s.lua.Event(v, script.NewServerEnv(s.auth, s.fs))
+}
@@ -0,0 +1,86 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"os"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// sendfileChunk is the most sent by a single sendfile, so a download is
+// reported to SITE WHO and the server's speeds as it goes rather than all at
+// once when it finishes
+const sendfileChunk = 4 * 1024 * 1024
+
+// fileSender is implemented by data connections that can send part of a
+// file with sendfile, ok is false if they can't, i.e. they use TLS without
+// the kernel
+type fileSender interface {
+	sendFile(f *os.File, n int64) (written int64, ok bool, err error)
+}
+
+// sendFile sends at most n bytes of f from its current offset over conn,
+// the TCPConn uses sendfile when given a limited *os.File. TLS connections
+// are only sent on once the kernel has taken over their encryption. ok is
+// false if conn can't use sendfile
+func sendFile(conn net.Conn, f *os.File, n int64) (int64, bool, error) {
+	var tcp *net.TCPConn
+
+	switch c := conn.(type) {
+	case *net.TCPConn:
+		tcp = c
+	case *ktlsConn:
+		raw, ok, err := c.offload()
+		if !ok || err != nil {
+			return 0, ok, err
+		}
+		tcp = raw
+	default:
+		return 0, false, nil
+	}
+
+	written, err := tcp.ReadFrom(&io.LimitedReader{R: f, N: n})
+
+	return written, true, err
+}
+
+// writerOnly hides ReadFrom so io.Copy falls back to Write
+type writerOnly struct {
+	io.Writer
+}
+
+// ReadFrom sends downloads of files on the host with sendfile when the
+// data connection is plain TCP, or TLS encrypted by the kernel, and isn't
+// rate limited. Anything else is copied through Write
+func (t *trackedDataConn) ReadFrom(r io.Reader) (int64, error) {
+	hf, ok := r.(vfs.HostFile)
+	if !ok {
+		return io.Copy(writerOnly{t}, r)
+	}
+
+	sender, ok := t.DataConn.(fileSender)
+	if !ok {
+		return io.Copy(writerOnly{t}, r)
+	}
+
+	f := hf.HostFile()
+
+	var total int64
+
+	for {
+		n, ok, err := sender.sendFile(f, sendfileChunk)
+		if !ok {
+			copied, err := io.Copy(writerOnly{t}, r)
+			return total + copied, err
+		}
+
+		total += n
+		t.session.transferred(cmd.TransferDownload, int(n))
+
+		if err != nil || n < sendfileChunk {
+			return total, err
+		}
+	}
+}
@@ -3,13 +3,33 @@ package ftp
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"math/big"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/glftpd"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/pretime"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/scan"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/spy"
+	"github.com/goftpd/goftpd/stats"
 	"github.com/goftpd/goftpd/vfs"
+	"github.com/goftpd/goftpd/webhook"
+	"github.com/goftpd/goftpd/xferlog"
+	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -24,13 +44,115 @@ type ServerOpts struct {
 
 	PublicIP string `goftpd:"public_ip"`
 
+	// connections on Host and Port start with a PROXY protocol header, see
+	// ListenerOpts
+	ProxyProtocol bool     `goftpd:"proxy_protocol"`
+	ProxyTrusted  []string `goftpd:"proxy_trusted"`
+
+	// ident lookups are performed on connect with a timeout in seconds
+	Ident        bool `goftpd:"ident"`
+	IdentTimeout int  `goftpd:"ident_timeout"`
+
+	// anonymous logins are confined to AnonymousPaths, limited to
+	// AnonymousSlots concurrent sessions and AnonymousRate KB/s per
+	// transfer. zero slots or rate is unlimited
+	Anonymous      bool     `goftpd:"anonymous"`
+	AnonymousPaths []string `goftpd:"anonymous_paths"`
+	AnonymousSlots int      `goftpd:"anonymous_slots"`
+	AnonymousRate  int      `goftpd:"anonymous_rate"`
+
+	// seconds a session can wait between commands before being
+	// disconnected, users with an IdleTime override it. zero is unlimited
+	IdleTimeout int `goftpd:"idle_timeout"`
+
+	// seconds a command can run before it is abandoned with a 451, the
+	// session carries on without it. commands using a data connection get
+	// TransferTimeout. zero is unlimited
+	CommandTimeout  int `goftpd:"command_timeout"`
+	TransferTimeout int `goftpd:"transfer_timeout"`
+
+	// log every line sent and received on the control connection of every
+	// session, SITE TRACE turns it on for single sessions
+	Trace bool `goftpd:"trace"`
+
+	// on linux, downloads over data connections using TLS 1.3 with AES-GCM
+	// are encrypted by the kernel so they can be sent with sendfile. needs
+	// the tls kernel module, without it crypto/tls is used as usual. Data
+	// connections that could be offloaded aren't sent session tickets
+	// since they would use up the kernel's first records
+	KTLS bool `goftpd:"ktls"`
+
+	// shown to users after they login, cookies such as %[user] and
+	// %[free] are expanded, see the cookie package
+	LoginMessage string `goftpd:"login_message"`
+
+	// the greeting sent on connect, defaults to Welcome!. listeners can
+	// have their own
+	Banner string `goftpd:"banner"`
+
+	// shown to users refused while the site is closed when SITE CLOSE is
+	// given no reason
+	ClosedMessage string `goftpd:"closed_message"`
+
+	// Go plugins loaded before the rest of the config, see LoadPlugins
+	Plugins []string `goftpd:"plugins"`
+
 	TLSCertFile string `goftpd:"tls_cert_file"`
 	TLSKeyFile  string `goftpd:"tls_key_file"`
-	tlsConfig   *tls.Config
+
+	// the oldest version allowed, 1.0 to 1.3, defaults to 1.2
+	TLSMinVersion string `goftpd:"tls_min_version"`
+	// cipher suites allowed for 1.0 to 1.2 using their standard names,
+	// defaults to Go's secure suites. 1.3 suites can't be changed
+	TLSCiphers []string `goftpd:"tls_ciphers"`
+	// key exchange curves in order of preference, x25519, p256, p384 and
+	// p521
+	TLSCurves []string `goftpd:"tls_curves"`
+	// ALPN protocols offered, defaults to ftp
+	TLSALPN []string `goftpd:"tls_alpn"`
+	// whether clients are asked for certificates, none, request, require,
+	// verify or require_verify. certificates are verified against the PEM
+	// encoded TLSClientCA
+	TLSClientAuth string `goftpd:"tls_client_auth"`
+	TLSClientCA   string `goftpd:"tls_client_ca"`
+
+	tlsConfig *tls.Config
+
+	commandPermissions *acl.CommandPermissions
+	templates          map[string]*acl.Template
+	aliases            cmd.Aliases
+	extraListeners     []*ListenerOpts
+	inherited          map[string][]net.Listener
+	logger             logging.Logger
 }
 
 func (o *ServerOpts) SetTLSConfig(t *tls.Config) { o.tlsConfig = t }
 
+func (o *ServerOpts) SetCommandPermissions(p *acl.CommandPermissions) { o.commandPermissions = p }
+
+func (o *ServerOpts) SetTemplates(t map[string]*acl.Template) { o.templates = t }
+
+func (o *ServerOpts) SetAliases(a cmd.Aliases) { o.aliases = a }
+
+// SetListeners sets the listeners accepting connections as well as Host
+// and Port
+func (o *ServerOpts) SetListeners(l []*ListenerOpts) { o.extraListeners = l }
+
+// SetInherited sets the sockets passed to the process keyed by name, i.e.
+// by systemd. Listeners use the one of their name or address in place of
+// binding their own
+func (o *ServerOpts) SetInherited(l map[string][]net.Listener) { o.inherited = l }
+
+func (o *ServerOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where the Server logs, logging.Stderr if not set
+func (o *ServerOpts) Logger() logging.Logger {
+	if o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
 // Server. Serves stuff.
 type Server struct {
 	*ServerOpts
@@ -39,31 +161,141 @@ type Server struct {
 
 	auth acl.Authenticator
 
+	stats stats.Stats
+
+	dupes dupe.Dupe
+
+	dirlog dirlog.Dirlog
+
+	inbox inbox.Inbox
+
+	nukes nuke.Nukes
+
+	pre pre.Pre
+
+	scripts script.Runner
+
+	lua script.Engine
+
+	webhooks webhook.Dispatcher
+
+	glftpdLog glftpd.Log
+
+	xferlog xferlog.Log
+
+	spy spy.Feed
+
+	race race.Race
+
+	audit audit.Log
+
+	announce announce.Announcer
+
+	pretime pretime.Lookup
+
+	scanner scan.Scanner
+
+	bans ban.Bans
+
 	sessionPool sync.Pool
 
+	anonymousSessions int
+	anonymousMtx      sync.Mutex
+
+	sessions    map[*Session]struct{}
+	sessionsMtx sync.RWMutex
+
+	started  time.Time
+	counters *counters
+
+	closed closedState
+
+	hooks []cmd.Hook
+
+	// held while swapping the options replaced by Reload
+	reloadMtx sync.RWMutex
+
+	ready chan struct{}
+
 	passivePortsMax *big.Int
 	passivePorts    map[int64]struct{}
 	passivePortsMtx sync.Mutex
 }
 
-// NewServer returns a Server using the supplied ServerOpts and VFS. Will
-// fail if some required options are missing or it's unable to load
-// the specified TLS cert/key files.
-func NewServer(opts *ServerOpts, fs vfs.VFS, auth acl.Authenticator) (*Server, error) {
+// ServerDeps are the services a Server uses, everything is required
+type ServerDeps struct {
+	FS        vfs.VFS
+	Auth      acl.Authenticator
+	Stats     stats.Stats
+	Dupe      dupe.Dupe
+	Dirlog    dirlog.Dirlog
+	Inbox     inbox.Inbox
+	Nukes     nuke.Nukes
+	Pre       pre.Pre
+	Scripts   script.Runner
+	Lua       script.Engine
+	Webhooks  webhook.Dispatcher
+	GlftpdLog glftpd.Log
+	Xferlog   xferlog.Log
+	Spy       spy.Feed
+	Race      race.Race
+	Audit     audit.Log
+	Announce  announce.Announcer
+	Pretime   pretime.Lookup
+	Scanner   scan.Scanner
+	Bans      ban.Bans
+}
+
+// NewServer returns a Server using the supplied ServerOpts and the services
+// in deps. Will fail if some required options are missing or it's unable
+// to load the specified TLS cert/key files.
+func NewServer(opts *ServerOpts, deps ServerDeps) (*Server, error) {
 
 	s := Server{
 		ServerOpts: opts,
-		fs:         fs,
-		auth:       auth,
+		fs:         deps.FS,
+		auth:       deps.Auth,
+		stats:      deps.Stats,
+		dupes:      deps.Dupe,
+		dirlog:     deps.Dirlog,
+		inbox:      deps.Inbox,
+		nukes:      deps.Nukes,
+		pre:        deps.Pre,
+		scripts:    deps.Scripts,
+		lua:        deps.Lua,
+		webhooks:   deps.Webhooks,
+		glftpdLog:  deps.GlftpdLog,
+		xferlog:    deps.Xferlog,
+		spy:        deps.Spy,
+		race:       deps.Race,
+		audit:      deps.Audit,
+		announce:   deps.Announce,
+		pretime:    deps.Pretime,
+		scanner:    deps.Scanner,
+		bans:       deps.Bans,
 		sessionPool: sync.Pool{
 			New: func() interface{} {
 				return &Session{}
 			},
 		},
+		sessions:        make(map[*Session]struct{}),
+		ready:           make(chan struct{}),
+		started:         time.Now(),
+		counters:        &counters{},
 		passivePorts:    make(map[int64]struct{}, 0),
 		passivePortsMax: big.NewInt(int64(opts.PassivePorts[1] - opts.PassivePorts[0])),
 	}
 
+	s.fs.Subscribe(s.handleEvent)
+	s.pre.Subscribe(s.announcePre)
+
+	s.AddHook(cmd.HookFuncs{AfterFunc: s.downloadScripts})
+	s.AddHook(cmd.HookFuncs{AfterFunc: s.commandWebhooks})
+	s.AddHook(cmd.HookFuncs{AfterFunc: s.spyLogins})
+	s.AddHook(cmd.HookFuncs{AfterFunc: s.auditCommand})
+	s.AddHook(cmd.HookFuncs{AfterFunc: s.announceNuke})
+	s.AddHook(newNukeLog(&s))
+
 	return &s, nil
 }
 
@@ -71,71 +303,115 @@ func (s *Server) TLSConfig() *tls.Config {
 	return s.tlsConfig
 }
 
-// ListenAndServe creates a new tcp listener on the configured Host and Port.
-// New connections are buffered down a channel before being given their own
-// goroutine. Takes a context and attemps to shutdown on cancellation/deadline
+// Ready is closed once the server is listening
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Template returns the named user Template, the default Template when name
+// is empty
+func (s *Server) Template(name string) (*acl.Template, bool) {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+
+	return acl.FindTemplate(s.templates, name)
+}
+
+// ListenAndServe creates a tcp listener on the configured Host and Port and
+// any extra listeners. New connections are buffered down a channel before
+// being given their own goroutine. Takes a context and attemps to shutdown
+// on cancellation/deadline
 func (s *Server) ListenAndServe(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	addr := net.JoinHostPort(s.Host, fmt.Sprintf("%d", s.Port))
+	var listeners []*listener
 
-	l, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	for _, opts := range s.listeners() {
+		l, err := s.listen(opts)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return errors.WithMessagef(err, "listener %s", opts.Name)
+		}
+		defer l.Close()
+
+		listeners = append(listeners, l)
 	}
-	defer l.Close()
 
-	conns := make(chan net.Conn, 10)
+	for name, ls := range s.inherited {
+		for _, l := range ls {
+			s.Logger().Warn("inherited socket not used by a listener", logging.F("name", name), logging.F("addr", l.Addr().String()))
+			l.Close()
+		}
+	}
+
+	if s.KTLS && !ktlsAvailable() {
+		s.Logger().Warn("ktls enabled but the tls kernel module isn't loaded, data connections use crypto/tls")
+	}
+
+	// the dated job might not run for most of a day, so today's directories
+	// are created now rather than waiting for it
+	if err := s.createDatedDirsNow(time.Now()); err != nil {
+		s.Logger().Error("unable to create dated dirs", logging.Err(err))
+	}
+
+	close(s.ready)
+
+	conns := make(chan accepted, 10)
 
 	var errg errgroup.Group
 
-	errg.Go(func() error {
-		for {
-			conn, err := l.Accept()
-			if err != nil {
-
-				// check if this is a cancellation
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-				}
+	for _, l := range listeners {
+		l := l
 
-				// check if this is temporary
-				if ne, ok := err.(net.Error); ok && ne.Temporary() {
-					continue
-				}
+		errg.Go(func() error {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
 
-				// fatal cancel ctx and return error
-				cancel()
+					// check if this is a cancellation
+					select {
+					case <-ctx.Done():
+						return nil
+					default:
+					}
 
-				return err
-			}
+					// check if this is temporary
+					if ne, ok := err.(net.Error); ok && ne.Temporary() {
+						continue
+					}
 
-			conns <- conn
-		}
+					// fatal cancel ctx and return error
+					cancel()
 
-		return nil
-	})
+					return errors.WithMessagef(err, "listener %s", l.Name)
+				}
+
+				conns <- accepted{conn, l.ListenerOpts}
+			}
+		})
+	}
 
 	errg.Go(func() error {
 		for {
 			select {
-			case c := <-conns:
-				go s.handleConnection(ctx, c)
+			case a := <-conns:
+				go s.handleConnection(ctx, a.conn, a.listener)
 
 			case <-ctx.Done():
 
-				// attempt to close the listener
-				if err := l.Close(); err != nil {
-					return err
+				// attempt to close the listeners
+				for _, l := range listeners {
+					if err := l.Close(); err != nil {
+						return err
+					}
 				}
 
 				return nil
 			}
 		}
-
-		return nil
 	})
 
 	if err := errg.Wait(); err != nil {
@@ -145,12 +421,33 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 	return nil
 }
 
-// handleConnection takes a context and a tcp connection and attempts to
-// start a new session
-func (server *Server) handleConnection(ctx context.Context, conn net.Conn) {
+// handleConnection takes a context, a tcp connection and the listener that
+// accepted it and attempts to start a new session
+func (server *Server) handleConnection(ctx context.Context, conn net.Conn, l *ListenerOpts) {
 	session := server.sessionPool.Get().(*Session)
 	session.Reset()
-	defer server.sessionPool.Put(session)
+
+	defer func() {
+		// a command that timed out could still be using the session
+		if !session.abandoned {
+			server.sessionPool.Put(session)
+		}
+	}()
+
+	// serve recovers its own panics, this catches any before it starts
+	defer func() {
+		if e := recover(); e != nil {
+			server.logPanic("connection crashed", e, logging.IP(conn.RemoteAddr().String()))
+			conn.Close()
+		}
+	}()
+
+	if server.banned(conn) {
+		conn.Close()
+		return
+	}
+
+	session.listener = l
 
 	session.serve(ctx, server, conn)
 }
@@ -1,18 +1,28 @@
 package ftp
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
-	"os"
-	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
 	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/stats"
 	"github.com/goftpd/goftpd/vfs"
 )
 
@@ -21,6 +31,12 @@ import (
 type Session struct {
 	server *Server
 
+	// set once registered with the server
+	id uint64
+
+	// the listener that accepted the connection
+	listener *ListenerOpts
+
 	control *Control
 	data    cmd.DataConn
 
@@ -29,85 +45,380 @@ type Session struct {
 	dataProtected   bool
 	binaryMode      bool
 	lastCommand     string
+	lastReply       int
 	renameFrom      []string
 	restartPosition int
+	xdupe           int
 
 	// authentication
-	login string
+	login     string
+	ident     string
+	anonymous bool
 
 	// fs abstract away?
 	currentDir string
+
+	// what the session is doing, shown in SITE WHO
+	info    sessionInfo
+	infoMtx sync.Mutex
+
+	values cmd.Values
+
+	// data commands that are running, Reinitialize waits for them so a
+	// transfer in progress is completed
+	transfers sync.WaitGroup
+
+	// held by the methods commands use to look at and change the session,
+	// the session's own goroutine only needs it while a command runs
+	mtx sync.Mutex
+
+	// a command timed out and may still be running, it can't change the
+	// session and the session can't be reused. The client is served by a
+	// successor from then on. Set with mtx held
+	abandoned bool
+
+	// the control connection is logged, set by other sessions running
+	// SITE TRACE so only accessed atomically
+	trace int32
+}
+
+// set runs fn with mtx held unless a command has been abandoned, returning
+// false if it has. Everything a command can change goes through it
+func (s *Session) set(fn func()) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.abandoned {
+		return false
+	}
+
+	fn()
+
+	return true
 }
 
 // SetState sets the current state of the session
-func (s *Session) SetState(state cmd.SessionState) { s.state = state }
+func (s *Session) SetState(state cmd.SessionState) { s.set(func() { s.state = state }) }
 
 // State shows the current state of the session
-func (s *Session) State() cmd.SessionState { return s.state }
+func (s *Session) State() cmd.SessionState {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.state
+}
 
 // SetBinaryMode sets the current state of the session
-func (s *Session) SetBinaryMode(t bool) { s.binaryMode = t }
+func (s *Session) SetBinaryMode(t bool) { s.set(func() { s.binaryMode = t }) }
 
 // BinaryMode shows the current state of the session
-func (s *Session) BinaryMode() bool { return s.binaryMode }
+func (s *Session) BinaryMode() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.binaryMode
+}
 
 // SetDataProtected sets the current state of the session
-func (s *Session) SetDataProtected(t bool) { s.dataProtected = t }
+func (s *Session) SetDataProtected(t bool) { s.set(func() { s.dataProtected = t }) }
 
 // DataProtected shows the current state of the session
-func (s *Session) DataProtected() bool { return s.dataProtected }
+func (s *Session) DataProtected() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.dataProtected
+}
 
 // SetRestartPosition sets the current state of the session
-func (s *Session) SetRestartPosition(t int) { s.restartPosition = t }
+func (s *Session) SetRestartPosition(t int) { s.set(func() { s.restartPosition = t }) }
 
 // RestartPosition shows the current state of the session
-func (s *Session) RestartPosition() int { return s.restartPosition }
+func (s *Session) RestartPosition() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.restartPosition
+}
 
 // SetRenameFrom sets the current state of the session
-func (s *Session) SetRenameFrom(t []string) { s.renameFrom = t }
+func (s *Session) SetRenameFrom(t []string) { s.set(func() { s.renameFrom = t }) }
+
+// SetXDupe sets the X-DUPE mode of the session
+func (s *Session) SetXDupe(t int) { s.set(func() { s.xdupe = t }) }
+
+// XDupe shows the X-DUPE mode of the session, 0 is off
+func (s *Session) XDupe() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.xdupe
+}
 
 // CWD gets the current working directory
-func (s *Session) CWD() string { return s.currentDir }
+func (s *Session) CWD() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.currentDir
+}
 
 // SetCWD sets the current working directory
-func (s *Session) SetCWD(t string) { s.currentDir = t }
+func (s *Session) SetCWD(t string) { s.set(func() { s.currentDir = t }) }
+
+// lastReplyCode returns the code of the last reply sent
+func (s *Session) lastReplyCode() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.lastReply
+}
 
 // LastCommnad returns the last command to be successful
-func (s *Session) LastCommand() string { return s.lastCommand }
+func (s *Session) LastCommand() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.lastCommand
+}
 
 // RenameFrom shows the current state of the session
-func (s *Session) RenameFrom() []string { return s.renameFrom }
+func (s *Session) RenameFrom() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-// SetLogin sets the current state of the session
-func (s *Session) SetLogin(t string) { s.login = t }
+	return s.renameFrom
+}
+
+// Values returns the state stashed on the session by commands, hooks and
+// plugins
+func (s *Session) Values() *cmd.Values { return &s.values }
+
+// SetLogin sets the current state of the session, any anonymous login is
+// given up
+func (s *Session) SetLogin(t string) {
+	s.set(func() {
+		s.releaseAnonymous()
+		s.login = t
+	})
+}
 
 // Login shows the current state of the session
-func (s *Session) Login() string { return s.login }
+func (s *Session) Login() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.login
+}
+
+// Ident shows the ident response from the remote host, empty if ident
+// lookups are disabled or failed
+func (s *Session) Ident() string { return s.ident }
+
+// AnonymousEnabled checks to see if the server allows anonymous logins
+func (s *Session) AnonymousEnabled() bool { return s.server.Anonymous }
+
+// Anonymous checks to see if the session is logged in anonymously
+func (s *Session) Anonymous() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.anonymous
+}
+
+// LoginAnonymous marks the session as anonymous if there is a free anonymous
+// slot, returns false if there isn't
+func (s *Session) LoginAnonymous() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.abandoned {
+		return false
+	}
+
+	if s.anonymous {
+		return true
+	}
+
+	if !s.server.acquireAnonymous() {
+		return false
+	}
+
+	s.anonymous = true
+
+	return true
+}
+
+// releaseAnonymous gives back the session's anonymous slot, mtx must be
+// held
+func (s *Session) releaseAnonymous() {
+	if !s.anonymous {
+		return
+	}
+
+	s.server.releaseAnonymous()
+	s.anonymous = false
+}
+
+// limit rate limits the data connection of anonymous sessions, mtx must be
+// held
+func (s *Session) limit(ctx context.Context, d cmd.DataConn) cmd.DataConn {
+	if !s.anonymous || s.server.AnonymousRate <= 0 {
+		return d
+	}
+	return newLimitedDataConn(ctx, d, s.server.AnonymousRate*1024)
+}
+
+func (s *Session) Data() cmd.DataConn {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
 
-func (s *Session) Data() cmd.DataConn { return s.data }
-func (s *Session) ClearData()         { s.data = nil }
+	return s.data
+}
+func (s *Session) ClearData() { s.set(func() { s.data = nil }) }
 func (s *Session) NewPassiveDataConn(ctx context.Context) error {
-	d, err := s.server.newPassiveDataConn(ctx, s.dataProtected)
+	d, err := s.server.newPassiveDataConn(ctx, s.DataProtected())
 	if err != nil {
 		return err
 	}
-	s.data = d
-	return nil
+	return s.setData(ctx, d)
 }
 func (s *Session) NewActiveDataConn(ctx context.Context, params string) error {
-	d, err := s.server.newActiveDataConn(ctx, params, s.dataProtected)
+	d, err := s.server.newActiveDataConn(ctx, params, s.DataProtected())
 	if err != nil {
 		return err
 	}
-	s.data = d
+	return s.setData(ctx, d)
+}
+
+// setData makes d the data connection, it is closed if the command opening
+// it has been abandoned
+func (s *Session) setData(ctx context.Context, d cmd.DataConn) error {
+	if !s.set(func() { s.data = s.track(s.limit(ctx, d)) }) {
+		d.Close()
+		return cmd.NewFatalError(errAbandoned)
+	}
 	return nil
 }
 
+// track reports transfers over the data connection to SITE WHO
+func (s *Session) track(d cmd.DataConn) cmd.DataConn {
+	return &trackedDataConn{DataConn: d, session: s}
+}
+
+// Sessions returns every session connected to the server
+func (s *Session) Sessions() []cmd.SessionInfo { return s.server.Sessions() }
+
+// ServerInfo returns the version, uptime and totals of the server
+func (s *Session) ServerInfo() cmd.ServerInfo { return s.server.Info() }
+
+// CloseSite closes the site to new logins, see Server.CloseSite
+func (s *Session) CloseSite(reason string) { s.server.CloseSite(reason) }
+
+// ReopenSite allows logins again, see Server.ReopenSite
+func (s *Session) ReopenSite() bool { return s.server.ReopenSite() }
+
+// SiteClosed returns the reason the site is closed, false if it is open
+func (s *Session) SiteClosed() (string, bool) { return s.server.SiteClosed() }
+
+// Trace turns logging the control connection on or off for every session
+// fn returns true for, including this one, returning how many were changed
+func (s *Session) Trace(fn func(cmd.SessionInfo) bool, on bool) int { return s.server.trace(fn, on) }
+
+// Kick disconnects every other session fn returns true for, returning how
+// many were disconnected
+func (s *Session) Kick(fn func(cmd.SessionInfo) bool) int { return s.server.kick(s, fn) }
+
+// RemoteAddr returns the remote address of the control connection
+func (s *Session) RemoteAddr() net.Addr { return s.control.RemoteAddr() }
+
+// Logger returns the server's logger adding the session's id, ip and login
+// to every line
+func (s *Session) Logger() logging.Logger {
+	fields := []logging.Field{
+		logging.Session(s.id),
+		logging.IP(sessionIP(s)),
+	}
+
+	if login := s.Login(); len(login) > 0 {
+		fields = append(fields, logging.User(login))
+	}
+
+	return s.server.Logger().With(fields...)
+}
+
+// SiteName returns the short name of the site
+func (s *Session) SiteName() string { return s.server.Name }
+
+// LoginMessage returns the message shown to users after they login
+func (s *Session) LoginMessage() string { return s.server.LoginMessage }
+
 func (s *Session) FS() vfs.VFS             { return s.server.fs }
 func (s *Session) Auth() acl.Authenticator { return s.server.auth }
+func (s *Session) Stats() stats.Stats      { return s.server.stats }
+func (s *Session) Dupe() dupe.Dupe         { return s.server.dupes }
+func (s *Session) Dirlog() dirlog.Dirlog   { return s.server.dirlog }
+func (s *Session) Inbox() inbox.Inbox      { return s.server.inbox }
+func (s *Session) Nukes() nuke.Nukes       { return s.server.nukes }
+func (s *Session) Pre() pre.Pre            { return s.server.pre }
+func (s *Session) Lua() script.Engine      { return s.server.lua }
+func (s *Session) Race() race.Race         { return s.server.race }
+func (s *Session) Audit() audit.Log        { return s.server.audit }
+func (s *Session) Bans() ban.Bans          { return s.server.bans }
+
+// CommandAllowed checks to see if the user is allowed to use the command
+// based on the site rules, or those of the session's listener if it has
+// its own
+func (s *Session) CommandAllowed(command string, user *acl.User) bool {
+	return s.server.commandAllowed(s.listener, command, user)
+}
+
+// banner returns the greeting of the session's listener, falling back to
+// the server's
+func (s *Session) banner() string {
+	if s.listener != nil && len(s.listener.Banner) > 0 {
+		return s.listener.Banner
+	}
+
+	if len(s.server.Banner) > 0 {
+		return s.server.Banner
+	}
+
+	return defaultBanner
+}
+
+// Aliases returns the SITE aliases of the server
+func (s *Session) Aliases() cmd.Aliases { return s.server.Aliases() }
+
+// Template returns the named user Template, see acl.FindTemplate
+func (s *Session) Template(name string) (*acl.Template, bool) {
+	return s.server.Template(name)
+}
+
+// idleTimeout returns how long the session can wait for the next command,
+// logged in users with an IdleTime override the server's IdleTimeout
+func (s *Session) idleTimeout() time.Duration {
+	timeout := s.server.IdleTimeout
+
+	if s.state == cmd.SessionStateLoggedIn && !s.anonymous {
+		if u, ok := s.User(); ok && u.IdleTime > 0 {
+			timeout = u.IdleTime
+		}
+	}
+
+	return time.Duration(timeout) * time.Second
+}
 
 func (s *Session) User() (*acl.User, bool) {
-	u, err := s.server.auth.GetUser(s.login)
+	s.mtx.Lock()
+	anonymous, login := s.anonymous, s.login
+	s.mtx.Unlock()
+
+	if anonymous {
+		return acl.NewAnonymousUser(), true
+	}
+
+	u, err := s.server.auth.GetUser(login)
 	if err != nil {
 		return nil, false
 	}
@@ -117,6 +428,8 @@ func (s *Session) User() (*acl.User, bool) {
 // Reset is used by sync.Pool and helps to minimise allocations
 func (s *Session) Reset() {
 	s.server = nil
+	s.id = 0
+	s.listener = nil
 
 	s.control = nil
 	s.data = nil
@@ -125,12 +438,69 @@ func (s *Session) Reset() {
 	s.dataProtected = false
 	s.binaryMode = false
 	s.lastCommand = ""
+	s.lastReply = 0
 	s.renameFrom = []string{}
 	s.restartPosition = 0
+	s.xdupe = 0
 
 	s.login = ""
+	s.ident = ""
+	s.anonymous = false
 
 	s.currentDir = "/"
+
+	s.infoMtx.Lock()
+	s.info = sessionInfo{}
+	s.infoMtx.Unlock()
+
+	s.values.Clear()
+
+	s.abandoned = false
+
+	s.setTrace(false)
+}
+
+// Reinitialize flushes the session back to how it was before USER, as
+// with REIN or a USER after logging in. Any transfer in progress is
+// completed first, as RFC 959 asks, then a data connection left open is
+// closed. The control connection is kept, along with its TLS and the
+// protection level of data connections
+func (s *Session) Reinitialize() {
+	s.transfers.Wait()
+
+	s.server.spyLogout(s)
+
+	var data cmd.DataConn
+
+	ok := s.set(func() {
+		s.releaseAnonymous()
+
+		data, s.data = s.data, nil
+
+		s.state = cmd.SessionStateNull
+		if _, ok := s.control.Conn.(*tls.Conn); ok {
+			s.state = cmd.SessionStateAuth
+		}
+
+		s.binaryMode = false
+		s.lastCommand = ""
+		s.renameFrom = []string{}
+		s.restartPosition = 0
+		s.xdupe = 0
+
+		s.login = ""
+
+		s.currentDir = "/"
+	})
+	if !ok {
+		return
+	}
+
+	if data != nil {
+		data.Close()
+	}
+
+	s.values.Clear()
 }
 
 // Close attempts to gracefully close the control and any running
@@ -171,8 +541,44 @@ func (s *Session) ReplyWithMessage(st cmd.Status, message string) error {
 	return s.reply(st.Code, message)
 }
 
-// reply is the underlying code for splitting a message across multiple lines
+// ReplyPartial sends a single line of a multi-line reply, the command has
+// to finish with one of the other replies. Used to report progress on long
+// running commands
+func (s *Session) ReplyPartial(st cmd.Status, message string) error {
+	if !s.set(func() {}) {
+		return cmd.NewFatalError(errAbandoned)
+	}
+
+	s.control.mtx.Lock()
+	defer s.control.mtx.Unlock()
+
+	line := fmt.Sprintf("%d-%s\r\n", st.Code, message)
+
+	if _, err := s.control.writer.WriteString(line); err != nil {
+		return cmd.NewFatalError(err)
+	}
+
+	if err := s.control.writer.Flush(); err != nil {
+		return cmd.NewFatalError(err)
+	}
+
+	s.traceSent(line)
+
+	return nil
+}
+
+// reply is the underlying code for splitting a message across multiple
+// lines, a command that has been abandoned can't reply
 func (s *Session) reply(code int, message string) error {
+	if !s.set(func() { s.lastReply = code }) {
+		return cmd.NewFatalError(errAbandoned)
+	}
+
+	return s.writeReply(code, message)
+}
+
+// writeReply sends the reply without checking the command was abandoned
+func (s *Session) writeReply(code int, message string) error {
 	parts := strings.Split(message, "\n")
 
 	b := strings.Builder{}
@@ -207,6 +613,11 @@ func (s *Session) reply(code int, message string) error {
 		return cmd.NewFatalError(err)
 	}
 
+	// a partial reply could be being sent by another goroutine, i.e. to
+	// report progress
+	s.control.mtx.Lock()
+	defer s.control.mtx.Unlock()
+
 	_, err := s.control.writer.WriteString(b.String())
 	if err != nil {
 		return cmd.NewFatalError(err)
@@ -216,6 +627,8 @@ func (s *Session) reply(code int, message string) error {
 		return cmd.NewFatalError(err)
 	}
 
+	s.traceSent(b.String())
+
 	return nil
 }
 
@@ -226,47 +639,104 @@ func (s *Session) Upgrade() error {
 		return err
 	}
 
-	s.control = newControl(tlsConn)
+	if !s.set(func() { s.control = newControl(tlsConn) }) {
+		return cmd.NewFatalError(errAbandoned)
+	}
 
 	return nil
 }
 
+// TLSState returns the state of the control connection's TLS, false if it
+// isn't using TLS
+func (s *Session) TLSState() (tls.ConnectionState, bool) {
+	s.mtx.Lock()
+	c, ok := s.control.Conn.(*tls.Conn)
+	s.mtx.Unlock()
+
+	if ok {
+		return c.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// ImplicitTLS returns true if the session was accepted by an implicit TLS
+// listener
+func (s *Session) ImplicitTLS() bool {
+	return s.listener != nil && s.listener.ImplicitTLS
+}
+
 // serve takes a connection and fs and parses commands on the control channel
 // it traps any panics and attempts to close the session
 func (s *Session) serve(ctx context.Context, server *Server, conn net.Conn) {
 	defer func() {
 		if e := recover(); e != nil {
-			var buf bytes.Buffer
-			fmt.Fprintf(&buf, "Handler crashed with error: %v", e)
-
-			for i := 1; ; i++ {
-				_, file, line, ok := runtime.Caller(i)
-				if !ok {
-					break
-				} else {
-					fmt.Fprintf(&buf, "\n")
-				}
-				fmt.Fprintf(&buf, "%v:%v", file, line)
-			}
+			server.logPanic("session crashed", e, logging.Session(s.id))
 
-			fmt.Fprintf(os.Stderr, "%s", buf.String())
+			// let the client know rather than just dropping it
+			if s.control != nil {
+				s.control.SetWriteDeadline(time.Now().Add(time.Second * 5))
+				s.ReplyWithMessage(cmd.StatusServiceUnavailable, "Internal error, closing control connection.")
+			}
 		}
+		s.server.unregisterSession(s)
+
+		s.mtx.Lock()
+		s.releaseAnonymous()
+		s.mtx.Unlock()
+
 		s.Close()
 	}()
 
 	s.control = newControl(conn)
 	s.server = server
 
-	s.ReplyWithMessage(cmd.StatusServiceReady, "Welcome!")
+	if server.Trace {
+		s.setTrace(true)
+	}
+
+	// the connection is already wrapped in TLS, and clients expect their
+	// data connections to be as well without sending PROT P
+	if s.ImplicitTLS() {
+		s.state = cmd.SessionStateAuth
+		s.dataProtected = true
+	}
+
+	if server.Ident {
+		ident, err := lookupIdent(ctx, conn, time.Duration(server.IdentTimeout)*time.Second)
+		if err != nil {
+			server.Logger().Warn("ident lookup failed", logging.IP(conn.RemoteAddr().String()), logging.Err(err))
+		}
+		s.ident = ident
+	}
 
-	defer s.Close()
+	s.infoMtx.Lock()
+	s.info = newSessionInfo(conn)
+	s.syncInfo()
+	s.infoMtx.Unlock()
+
+	server.registerSession(s)
+
+	s.ReplyWithMessage(cmd.StatusServiceReady, s.banner())
 
 	for {
+		// a zero deadline waits forever
+		var deadline time.Time
+		if timeout := s.idleTimeout(); timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		s.control.SetReadDeadline(deadline)
+
 		line, err := s.control.reader.ReadString('\n')
 		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				s.control.SetWriteDeadline(time.Now().Add(time.Second * 5))
+				s.ReplyWithMessage(cmd.StatusServiceUnavailable, "Idle timeout, closing control connection.")
+			}
 			break
 		}
 
+		s.traceReceived(line)
+
 		// check for cancellation
 		select {
 		case <-ctx.Done():
@@ -281,15 +751,23 @@ func (s *Session) serve(ctx context.Context, server *Server, conn net.Conn) {
 		}
 
 		if err := s.handleCommand(ctx, fields); err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR handleCommand: %s", err)
+			s.Logger().Warn("closing session after a fatal error", logging.Err(err))
 			break
 		}
+
+		// the command timed out and could still be running, it keeps the
+		// abandoned session to itself
+		if s.abandoned {
+			s = s.successor()
+		}
 	}
 }
 
 // handleCommand takes in the client input in the form of a slice of strings
 // and tries to find and execute a command. can return an error
 func (session *Session) handleCommand(ctx context.Context, fields []string) error {
+	fields = session.Aliases().ResolveLine(fields)
+
 	// TODO: ugly as sin
 	c, ok := cmd.CommandMap[strings.ToUpper(fields[0])]
 
@@ -300,6 +778,10 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 	if session.State() < c.RequireState() {
 		switch c.RequireState() {
 		case cmd.SessionStateAuth:
+			// logging in without tls
+			if session.Violation("sent " + strings.ToUpper(fields[0]) + " before AUTH") {
+				return cmd.ReplyBanned(session)
+			}
 			return session.ReplyWithMessage(cmd.StatusBadCommandSequence, "Please send AUTH first.")
 		case cmd.SessionStateLoggedIn:
 			return session.ReplyStatus(cmd.StatusNotLoggedIn)
@@ -308,8 +790,48 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 		return session.ReplyStatus(cmd.StatusNotImplemented)
 	}
 
-	// pre command hook
-	if err := c.Execute(ctx, session, fields[1:]); err != nil {
+	session.startCommand(fields)
+	defer session.finishCommand()
+
+	hc := newHookCommand(fields)
+
+	return session.runWithDeadline(ctx, hc, func(ctx context.Context) error {
+		return session.runCommand(ctx, c, hc, fields)
+	})
+}
+
+// runCommand runs the command between the hooks
+func (session *Session) runCommand(ctx context.Context, c cmd.Command, hc cmd.HookCommand, fields []string) error {
+	if _, ok := dataCommands[hc.Name]; ok {
+		session.transfers.Add(1)
+		defer session.transfers.Done()
+	}
+
+	if err := session.server.runBefore(ctx, session, hc); err != nil {
+		return session.ReplyError(cmd.StatusActionNotOK, err)
+	}
+
+	session.set(func() { session.lastReply = 0 })
+	start := time.Now()
+
+	err := c.Execute(ctx, session, fields[1:])
+
+	result := cmd.HookResult{
+		Code:     session.lastReplyCode(),
+		Err:      err,
+		Duration: time.Since(start),
+	}
+
+	session.server.runAfter(ctx, session, hc, result)
+
+	session.Logger().Debug("command",
+		logging.Command(hc.Name),
+		logging.F("code", result.Code),
+		logging.Duration(result.Duration),
+		logging.Err(result.Err),
+	)
+
+	if err != nil {
 		// check the type of the error, if its a fatal err then
 		// return it, otherwise return nil to continue
 		if errors.Is(err, cmd.ErrCommandFatal) {
@@ -319,9 +841,7 @@ func (session *Session) handleCommand(ctx context.Context, fields []string) erro
 		return nil
 	}
 
-	session.lastCommand = strings.ToUpper(fields[0])
-
-	// post command hook
+	session.set(func() { session.lastCommand = strings.ToUpper(fields[0]) })
 
 	return nil
 }
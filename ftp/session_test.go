@@ -0,0 +1,125 @@
+package ftp
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// onlySession returns the server's only session
+func (s *testServer) onlySession(t *testing.T) *Session {
+	t.Helper()
+
+	s.sessionsMtx.RLock()
+	defer s.sessionsMtx.RUnlock()
+
+	if len(s.sessions) != 1 {
+		t.Fatalf("expected 1 session got %d", len(s.sessions))
+	}
+
+	for session := range s.sessions {
+		return session
+	}
+
+	return nil
+}
+
+func TestReinitialize(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{})
+	defer done()
+
+	s.addUser(t, "someone", nil)
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	c.login("someone")
+
+	c.expect("TYPE I", 200)
+	c.expect("REST 100", 350)
+	c.expect("PBSZ 0", 200)
+	c.expect("PROT P", 200)
+	c.expect("CWD /", 250)
+
+	c.expect("REIN", 220)
+
+	session := s.onlySession(t)
+
+	session.mtx.Lock()
+	login, state := session.login, session.state
+	binary, rest, protected := session.binaryMode, session.restartPosition, session.dataProtected
+	cwd := session.currentDir
+	session.mtx.Unlock()
+
+	if len(login) != 0 || binary || rest != 0 || cwd != "/" {
+		t.Fatalf("expected the session to be reset: login '%s' binary %t rest %d cwd '%s'", login, binary, rest, cwd)
+	}
+
+	// still using TLS so AUTH isn't needed again, nor PBSZ and PROT
+	if state != cmd.SessionStateAuth || !protected {
+		t.Fatalf("expected the security state to be kept: state %d protected %t", state, protected)
+	}
+
+	c.expect("PWD", 530)
+
+	c.login("someone")
+	c.expect("NOOP", 200)
+}
+
+func TestReinitializeTransfer(t *testing.T) {
+	s, done := newTestServer(t, &ServerOpts{PublicIP: "127.0.0.1"})
+	defer done()
+
+	s.addUser(t, "someone", nil)
+
+	addrs, stop := s.listen(t)
+	defer stop()
+
+	c := dial(t, addrs["default"], false)
+	defer c.Close()
+
+	c.login("someone")
+
+	content := []byte("some file that is being downloaded")
+
+	data := c.pasv(false)
+	c.expect("STOR file.txt", 150)
+
+	if _, err := data.Write(content); err != nil {
+		t.Fatalf("unexpected error uploading: %s", err)
+	}
+	data.Close()
+
+	if code, msg := c.read(); code != 226 {
+		t.Fatalf("expected 226 got %d %s", code, msg)
+	}
+
+	data = c.pasv(false)
+	defer data.Close()
+
+	// REIN arrives while the download is in progress
+	c.expect("RETR file.txt\r\nREIN", 150)
+
+	got, err := ioutil.ReadAll(data)
+	if err != nil {
+		t.Fatalf("unexpected error downloading: %s", err)
+	}
+
+	if string(got) != string(content) {
+		t.Fatalf("expected '%s' got '%s'", content, got)
+	}
+
+	if code, msg := c.read(); code != 226 {
+		t.Fatalf("expected the transfer to complete got %d %s", code, msg)
+	}
+
+	if code, msg := c.read(); code != 220 {
+		t.Fatalf("expected REIN once the transfer completed got %d %s", code, msg)
+	}
+
+	c.expect("PWD", 530)
+}
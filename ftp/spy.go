@@ -0,0 +1,82 @@
+package ftp
+
+import (
+	"context"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/spy"
+)
+
+// spyLogins is a cmd.Hook run after every command, publishing logins to
+// the spy feed
+func (s *Server) spyLogins(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	if hc.Name != "PASS" || r.Code != cmd.StatusUserLoggedIn.Code {
+		return
+	}
+
+	e := spy.Event{
+		Event: spy.EventLogin,
+		User:  session.Login(),
+		IP:    sessionIP(session),
+		Data: map[string]interface{}{
+			"ident":     session.Ident(),
+			"anonymous": session.Anonymous(),
+		},
+	}
+
+	if session, ok := session.(*Session); ok {
+		e.Session = session.id
+	}
+
+	if user, ok := session.User(); ok {
+		e.Group = user.PrimaryGroup
+	}
+
+	s.spy.Publish(e)
+}
+
+// spyLogout publishes a logged in session disconnecting
+func (s *Server) spyLogout(session *Session) {
+	info := session.snapshot()
+
+	if info.State != cmd.SessionStateLoggedIn {
+		return
+	}
+
+	s.spy.Publish(spy.Event{
+		Event:   spy.EventLogout,
+		Session: info.ID,
+		User:    info.Login,
+		IP:      info.IP,
+	})
+}
+
+// spyTransfer publishes a RETR, STOR or APPE that finished successfully.
+// Must be called from the session's goroutine with its infoMtx held
+func (s *Server) spyTransfer(session *Session, info sessionInfo) {
+	if session.lastReplyCode() != cmd.StatusDataClosedOK.Code {
+		return
+	}
+
+	e := spy.Event{
+		Event:   spy.EventUpload,
+		Session: info.ID,
+		User:    info.Login,
+		IP:      info.IP,
+		Path:    info.Path(),
+		Bytes:   info.Bytes,
+	}
+
+	if info.Command == "RETR" {
+		e.Event = spy.EventDownload
+	}
+
+	if !info.TransferStart.IsZero() {
+		if elapsed := time.Since(info.TransferStart).Seconds(); elapsed > 0 {
+			e.Speed = float64(info.Bytes) / elapsed
+		}
+	}
+
+	s.spy.Publish(e)
+}
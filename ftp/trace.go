@@ -0,0 +1,121 @@
+package ftp
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+)
+
+// trace turns tracing on or off for every session fn returns true for,
+// returning how many were changed
+func (s *Server) trace(fn func(cmd.SessionInfo) bool, on bool) int {
+	s.sessionsMtx.RLock()
+	defer s.sessionsMtx.RUnlock()
+
+	var n int
+
+	for session := range s.sessions {
+		if !fn(session.snapshot()) {
+			continue
+		}
+
+		session.setTrace(on)
+		n++
+	}
+
+	return n
+}
+
+// setTrace turns logging the lines of the control connection on or off
+func (s *Session) setTrace(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.trace, v)
+}
+
+// tracing checks to see if the control connection is being logged
+func (s *Session) tracing() bool { return atomic.LoadInt32(&s.trace) == 1 }
+
+// traceReceived logs a line sent by the client, passwords are masked
+func (s *Session) traceReceived(line string) {
+	if !s.tracing() {
+		return
+	}
+
+	s.Logger().Info("trace",
+		logging.F("at", traceTime()),
+		logging.F("dir", "recv"),
+		logging.F("line", maskLine(line, s.Aliases())),
+	)
+}
+
+// traceSent logs each line of a reply sent to the client
+func (s *Session) traceSent(reply string) {
+	if !s.tracing() {
+		return
+	}
+
+	at := traceTime()
+
+	for _, line := range strings.Split(strings.TrimSuffix(reply, "\r\n"), "\r\n") {
+		s.Logger().Info("trace",
+			logging.F("at", at),
+			logging.F("dir", "sent"),
+			logging.F("line", line),
+		)
+	}
+}
+
+// traceTime is when a line was traced, the log's own time is only to the
+// second which isn't enough to follow a conversation
+func traceTime() string { return time.Now().Format("15:04:05.000000") }
+
+// maskLine returns the line without its line ending and with the password
+// of PASS, and of the SITE commands that have one, redacted. SITE aliases
+// are masked as the command they point at
+func maskLine(line string, aliases cmd.Aliases) string {
+	line = strings.TrimRight(line, "\r\n")
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return line
+	}
+
+	name := strings.ToUpper(fields[0])
+
+	if name == "PASS" {
+		return fields[0] + " " + redacted
+	}
+
+	if name != "SITE" {
+		return line
+	}
+
+	site := strings.ToUpper(fields[1])
+
+	// the parameters sent to an alias follow its preset ones
+	var preset int
+	if p, ok := aliases[site]; ok {
+		site = strings.ToUpper(p[0])
+		preset = len(p) - 1
+	}
+
+	c, ok := auditCommands["SITE "+site]
+	if !ok || len(c.redact) == 0 {
+		return line
+	}
+
+	// the redacted indexes are of the parameters after the site command
+	for _, i := range c.redact {
+		if j := i - preset + 2; j >= 2 && j < len(fields) {
+			fields[j] = redacted
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
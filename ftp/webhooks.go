@@ -0,0 +1,99 @@
+package ftp
+
+import (
+	"context"
+	"net"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/goftpd/goftpd/webhook"
+)
+
+// uploadWebhook sends the upload webhook for an upload made by a user
+func (s *Server) uploadWebhook(e vfs.Event) {
+	if e.Type != vfs.EventUpload || len(e.User) == 0 {
+		return
+	}
+
+	s.webhooks.Send(webhook.Payload{
+		Event: webhook.EventUpload,
+		Time:  e.Time,
+		User:  e.User,
+		Group: e.Group,
+		Path:  e.Path,
+		Size:  e.Size,
+	})
+}
+
+// commandWebhooks is a cmd.Hook run after every command, sending the
+// webhooks of logins, nukes and uploads refused for lack of space
+func (s *Server) commandWebhooks(ctx context.Context, session cmd.Session, hc cmd.HookCommand, r cmd.HookResult) {
+	switch {
+	case hc.Name == "PASS" && r.Code == cmd.StatusUserLoggedIn.Code:
+		p := webhook.Payload{
+			Event: webhook.EventLogin,
+			User:  session.Login(),
+			IP:    sessionIP(session),
+			Data: map[string]interface{}{
+				"ident":     session.Ident(),
+				"anonymous": session.Anonymous(),
+			},
+		}
+
+		if user, ok := session.User(); ok {
+			p.Group = user.PrimaryGroup
+		}
+
+		s.webhooks.Send(p)
+
+	case hc.Name == "SITE NUKE" && r.Code == cmd.StatusOK.Code && len(hc.Params) > 0:
+		record, err := s.nukes.Get(s.fs.Join(session.CWD(), hc.Params[:1]))
+		if err != nil || record == nil {
+			return
+		}
+
+		nukees := make([]string, 0, len(record.Nukees))
+		for _, e := range record.Nukees {
+			nukees = append(nukees, e.User)
+		}
+
+		s.webhooks.Send(webhook.Payload{
+			Event: webhook.EventNuke,
+			Time:  record.Time,
+			User:  record.Nuker,
+			Path:  record.Path,
+			Size:  record.Bytes(),
+			Data: map[string]interface{}{
+				"reason":     record.Reason,
+				"multiplier": record.Multiplier,
+				"nuked_path": record.NukedPath,
+				"nukees":     nukees,
+			},
+		})
+
+	case hc.Name == "STOR" && r.Code == cmd.StatusNoDiskFree.Code && len(hc.Params) > 0:
+		p := webhook.Payload{
+			Event: webhook.EventQuota,
+			User:  session.Login(),
+			IP:    sessionIP(session),
+			Path:  s.fs.Join(session.CWD(), hc.Params),
+		}
+
+		if user, ok := session.User(); ok {
+			p.Group = user.PrimaryGroup
+		}
+
+		s.webhooks.Send(p)
+	}
+}
+
+// sessionIP returns the IP address of the session's client
+func sessionIP(session cmd.Session) string {
+	addr := session.RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
@@ -0,0 +1,47 @@
+package ftp
+
+import (
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/xferlog"
+)
+
+// logTransfer writes the xferlog record of a RETR, STOR or APPE once it has
+// finished. Transfers refused before any data moved aren't recorded. Must
+// be called from the session's goroutine with its infoMtx held
+func (s *Server) logTransfer(session *Session, info sessionInfo) {
+	complete := session.lastReplyCode() == cmd.StatusDataClosedOK.Code
+
+	if info.Bytes == 0 && !complete {
+		return
+	}
+
+	now := time.Now()
+
+	t := xferlog.Transfer{
+		Time:      now,
+		IP:        info.IP,
+		Bytes:     info.Bytes,
+		Path:      s.fs.Join(info.CWD, []string{info.File}),
+		Binary:    session.BinaryMode(),
+		Direction: xferlog.Upload,
+		Anonymous: session.Anonymous(),
+		User:      session.Login(),
+		Ident:     session.ident,
+		Complete:  complete,
+	}
+
+	if info.Command == "RETR" {
+		t.Direction = xferlog.Download
+	}
+
+	if !info.TransferStart.IsZero() {
+		t.Duration = now.Sub(info.TransferStart)
+	}
+
+	if err := s.xferlog.Write(t); err != nil {
+		s.Logger().Error("unable to write xferlog", logging.Path(t.Path), logging.Err(err))
+	}
+}
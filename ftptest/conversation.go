@@ -0,0 +1,42 @@
+package ftptest
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Step is a line sent by the client and the code of the final reply it
+// expects
+type Step struct {
+	Send   string
+	Expect int
+}
+
+// Converse sends each step in turn the way a client would, stopping at the
+// first that doesn't get the reply it expects or ends in a fatal error
+func (s *Session) Converse(ctx context.Context, steps ...Step) error {
+	for i, step := range steps {
+		replies, err := s.Exec(ctx, step.Send)
+		if err != nil {
+			return errors.WithMessagef(err, "step %d '%s'", i+1, step.Send)
+		}
+
+		var last *Reply
+		for j := range replies {
+			if !replies[j].Partial {
+				last = &replies[j]
+			}
+		}
+
+		if last == nil {
+			return errors.Errorf("step %d '%s' expected %d but got no reply", i+1, step.Send, step.Expect)
+		}
+
+		if last.Code != step.Expect {
+			return errors.Errorf("step %d '%s' expected %d but got '%s'", i+1, step.Send, step.Expect, last)
+		}
+	}
+
+	return nil
+}
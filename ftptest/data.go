@@ -0,0 +1,60 @@
+package ftptest
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+// errDataClosed is returned using a DataConn after it is closed
+var errDataClosed = errors.New("data connection closed")
+
+// DataConn implements cmd.DataConn in memory, reads come from the Upload
+// of the Session that opened it and writes are kept in Downloaded
+type DataConn struct {
+	kind string
+
+	upload *bytes.Reader
+
+	// everything written by the command, i.e. a listing or download
+	Downloaded bytes.Buffer
+
+	// Close has been called
+	Closed bool
+}
+
+func newDataConn(kind string, upload []byte) *DataConn {
+	return &DataConn{
+		kind:   kind,
+		upload: bytes.NewReader(upload),
+	}
+}
+
+func (d *DataConn) Host() string { return "127.0.0.1" }
+func (d *DataConn) Port() int    { return 20000 }
+func (d *DataConn) Kind() string { return d.kind }
+
+func (d *DataConn) BytesRead() int    { return int(d.upload.Size()) - d.upload.Len() }
+func (d *DataConn) BytesWritten() int { return d.Downloaded.Len() }
+
+func (d *DataConn) Read(p []byte) (int, error) {
+	if d.Closed {
+		return 0, errDataClosed
+	}
+	return d.upload.Read(p)
+}
+
+func (d *DataConn) Write(p []byte) (int, error) {
+	if d.Closed {
+		return 0, errDataClosed
+	}
+	return d.Downloaded.Write(p)
+}
+
+func (d *DataConn) Close() error {
+	d.Closed = true
+	return nil
+}
+
+var _ cmd.DataConn = (*DataConn)(nil)
@@ -0,0 +1,50 @@
+package ftptest
+
+import "testing"
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
+
+func newTestSession(t *testing.T, opts *SessionOpts) *Session {
+	t.Helper()
+
+	s, err := NewSession(opts)
+	if err != nil {
+		t.Fatalf("unexpected error creating session: %s", err)
+	}
+
+	return s
+}
+
+func newTestAuthenticator(t *testing.T) *MemoryAuthenticator {
+	t.Helper()
+
+	auth, err := NewMemoryAuthenticator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating authenticator: %s", err)
+	}
+
+	return auth
+}
+
+func closeTestAuthenticator(t *testing.T, auth *MemoryAuthenticator) {
+	t.Helper()
+
+	if err := auth.Close(); err != nil {
+		t.Fatalf("unexpected error closing authenticator: %s", err)
+	}
+}
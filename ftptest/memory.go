@@ -0,0 +1,155 @@
+package ftptest
+
+import (
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// NewMemoryFS returns a vfs.Filesystem kept in memory using the path
+// permission rules, i.e. `upload / *`, as given by `fs` lines in the
+// config. Files are owned by nobody:nogroup by default
+func NewMemoryFS(rules ...string) (*vfs.Filesystem, error) {
+	var rs []acl.Rule
+
+	for _, l := range rules {
+		r, err := acl.NewRule(l)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+
+	permissions, err := acl.NewPermissions(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := vfs.FilesystemOpts{
+		DefaultUser:  "nobody",
+		DefaultGroup: "nogroup",
+	}
+
+	return vfs.NewMemoryFilesystem(&opts, permissions)
+}
+
+// MemoryAuthenticator is an acl.BadgerAuthenticator using an in-memory
+// badger, it has to be closed
+type MemoryAuthenticator struct {
+	*acl.BadgerAuthenticator
+	db *badger.DB
+}
+
+// NewMemoryAuthenticator returns an empty MemoryAuthenticator using the
+// options, nil uses the defaults
+func NewMemoryAuthenticator(opts *acl.AuthenticatorOpts) (*MemoryAuthenticator, error) {
+	if opts == nil {
+		opts = &acl.AuthenticatorOpts{}
+	}
+
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoryAuthenticator{acl.NewBadgerAuthenticator(opts, db), db}, nil
+}
+
+// Close closes the underlying badger
+func (a *MemoryAuthenticator) Close() error {
+	return a.db.Close()
+}
+
+// NewMemoryStats returns an empty stats.BadgerStats using an in-memory
+// badger with no sections, it has to be closed
+func NewMemoryStats() (*stats.BadgerStats, error) {
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return stats.NewBadgerStats(&stats.StatsOpts{}, db, nil), nil
+}
+
+// NewMemoryNukes returns an empty nuke.BadgerNukes using an in-memory badger
+// and the options, nil uses the defaults. It has to be closed
+func NewMemoryNukes(opts *nuke.NukeOpts) (*nuke.BadgerNukes, error) {
+	if opts == nil {
+		opts = &nuke.NukeOpts{}
+	}
+
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return nuke.NewBadgerNukes(opts, db), nil
+}
+
+// NewMemoryDupe returns an empty dupe.BadgerDupe using an in-memory badger
+// and the rules, i.e. `/mp3/** reject`, as given by `dupe` lines in the
+// config. It has to be closed
+func NewMemoryDupe(rules ...string) (*dupe.BadgerDupe, error) {
+	var rs []dupe.Rule
+
+	for _, l := range rules {
+		r, err := dupe.NewRule(l)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return dupe.NewBadgerDupe(&dupe.DupeOpts{}, db, rs), nil
+}
+
+// NewMemoryDirlog returns an empty dirlog.BadgerDirlog using an in-memory
+// badger and the sections, i.e. `mp3 /mp3/*`, as given by `dirlog section`
+// lines in the config. It has to be closed
+func NewMemoryDirlog(sections ...string) (*dirlog.BadgerDirlog, error) {
+	var ss []dirlog.Section
+
+	for _, l := range sections {
+		s, err := dirlog.NewSection(l)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return dirlog.NewBadgerDirlog(&dirlog.DirlogOpts{}, db, ss), nil
+}
+
+// NewMemoryInbox returns an empty inbox.BadgerInbox using an in-memory
+// badger, it has to be closed
+func NewMemoryInbox() (*inbox.BadgerInbox, error) {
+	db, err := openMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	return inbox.NewBadgerInbox(&inbox.InboxOpts{}, db), nil
+}
+
+// openMemory opens a badger kept in memory
+func openMemory() (*badger.DB, error) {
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts.Logger = nil
+
+	return badger.Open(opts)
+}
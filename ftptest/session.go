@@ -0,0 +1,571 @@
+// Package ftptest provides an in-memory cmd.Session and in-memory
+// filesystem and users so the commands in ftp/cmd can be tested without a
+// server, network or disk, much like net/http/httptest. Conversations are
+// scripted as the lines a client sends and the replies it expects, see
+// Session.Converse
+package ftptest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/audit"
+	"github.com/goftpd/goftpd/ban"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/inbox"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/nuke"
+	"github.com/goftpd/goftpd/pre"
+	"github.com/goftpd/goftpd/race"
+	"github.com/goftpd/goftpd/script"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/pkg/errors"
+)
+
+// SessionOpts are the services and settings of a Session. Services left
+// nil are unavailable, commands using them will panic the same as on a
+// server without them, apart from Lua which has no commands
+type SessionOpts struct {
+	FS     vfs.VFS
+	Auth   acl.Authenticator
+	Stats  stats.Stats
+	Dupe   dupe.Dupe
+	Dirlog dirlog.Dirlog
+	Inbox  inbox.Inbox
+	Nukes  nuke.Nukes
+	Pre    pre.Pre
+	Lua    script.Engine
+	Race   race.Race
+	Audit  audit.Log
+	Bans   ban.Bans
+
+	// command permissions in the form of `<command> <acl>`, i.e. `who *`,
+	// as given by `site` lines in the config
+	Permissions []string
+	Templates   map[string]*acl.Template
+	Aliases     cmd.Aliases
+
+	SiteName     string
+	LoginMessage string
+	Anonymous    bool
+
+	// the session starts as if AUTH TLS has already been sent
+	TLS bool
+	// the session starts as if connected to an implicit TLS listener,
+	// with TLS and PROT P
+	ImplicitTLS bool
+
+	// defaults to 127.0.0.1:1024
+	RemoteAddr net.Addr
+	// the ident response of the remote host, empty as if ident is off
+	Ident string
+
+	// the other sessions connected, shown by Sessions, disconnected by
+	// Kick and traced by Trace
+	Sessions   []cmd.SessionInfo
+	ServerInfo cmd.ServerInfo
+
+	// defaults to discarding everything
+	Logger logging.Logger
+}
+
+// Reply is a reply sent by a command, the lines of multi-line replies are
+// joined with a \n
+type Reply struct {
+	Code    int
+	Message string
+	// a line of a reply still to be finished, see cmd.Session.ReplyPartial
+	Partial bool
+}
+
+func (r Reply) String() string {
+	return fmt.Sprintf("%d %s", r.Code, r.Message)
+}
+
+// Session implements cmd.Session in memory. It isn't safe for concurrent
+// use, the same as commands on a real session
+type Session struct {
+	opts *SessionOpts
+
+	commandPermissions *acl.CommandPermissions
+
+	// every reply sent by a command, oldest first
+	Replies []Reply
+
+	// sent by the client over every data connection opened
+	Upload []byte
+	// every data connection opened by a command, oldest first
+	DataConns []*DataConn
+
+	// reasons given to Violation
+	Violations []string
+
+	// Close has been called
+	Closed bool
+
+	tls  bool
+	data cmd.DataConn
+
+	state           cmd.SessionState
+	dataProtected   bool
+	binaryMode      bool
+	lastCommand     string
+	renameFrom      []string
+	restartPosition int
+	xdupe           int
+
+	login     string
+	anonymous bool
+
+	currentDir string
+
+	// the command being run, shown by Sessions
+	command string
+	// turned on by Trace, shown by Sessions
+	traced bool
+
+	closedReason string
+	closed       bool
+
+	values cmd.Values
+}
+
+// NewSession takes in options and returns a Session as if a client had
+// just connected. Fails on a bad permission
+func NewSession(opts *SessionOpts) (*Session, error) {
+	var rules []acl.CommandRule
+
+	for _, l := range opts.Permissions {
+		r, err := acl.NewCommandRule(l)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "permission '%s'", l)
+		}
+		rules = append(rules, r)
+	}
+
+	commandPermissions, err := acl.NewCommandPermissions(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Session{
+		opts:               opts,
+		commandPermissions: commandPermissions,
+		tls:                opts.TLS || opts.ImplicitTLS,
+		dataProtected:      opts.ImplicitTLS,
+		currentDir:         "/",
+	}
+
+	if s.tls {
+		s.state = cmd.SessionStateAuth
+	}
+
+	return &s, nil
+}
+
+// LoginAs logs the session in as the user without sending USER and PASS,
+// the user has to exist in Auth
+func (s *Session) LoginAs(name string) error {
+	if s.opts.Auth == nil {
+		return errors.New("no authenticator")
+	}
+
+	user, err := s.opts.Auth.GetUser(name)
+	if err != nil {
+		return err
+	}
+
+	s.login = user.Name
+	s.state = cmd.SessionStateLoggedIn
+	s.currentDir = user.Home()
+
+	return nil
+}
+
+// LastReply returns the most recent reply, false if none have been sent
+func (s *Session) LastReply() (Reply, bool) {
+	if len(s.Replies) == 0 {
+		return Reply{}, false
+	}
+	return s.Replies[len(s.Replies)-1], true
+}
+
+func (s *Session) reply(code int, message string) error {
+	s.Replies = append(s.Replies, Reply{Code: code, Message: message})
+	return nil
+}
+
+func (s *Session) ReplyWithMessage(st cmd.Status, message string) error {
+	return s.reply(st.Code, message)
+}
+
+func (s *Session) ReplyWithArgs(st cmd.Status, args ...interface{}) error {
+	return s.reply(st.Code, fmt.Sprintf(st.Message, args...))
+}
+
+func (s *Session) ReplyError(st cmd.Status, err error) error {
+	return s.reply(st.Code, fmt.Sprintf("%s (%s)", st.Message, err.Error()))
+}
+
+func (s *Session) ReplyStatus(st cmd.Status) error {
+	return s.reply(st.Code, st.Message)
+}
+
+func (s *Session) ReplyPartial(st cmd.Status, message string) error {
+	s.Replies = append(s.Replies, Reply{Code: st.Code, Message: message, Partial: true})
+	return nil
+}
+
+// Upgrade pretends the control connection has negotiated TLS
+func (s *Session) Upgrade() error {
+	s.tls = true
+	return nil
+}
+
+// ImplicitTLS returns SessionOpts.ImplicitTLS
+func (s *Session) ImplicitTLS() bool { return s.opts.ImplicitTLS }
+
+// TLSState returns a TLS 1.3 state once the session has negotiated TLS
+func (s *Session) TLSState() (tls.ConnectionState, bool) {
+	if !s.tls {
+		return tls.ConnectionState{}, false
+	}
+
+	return tls.ConnectionState{
+		Version:           tls.VersionTLS13,
+		HandshakeComplete: true,
+		CipherSuite:       tls.TLS_AES_128_GCM_SHA256,
+	}, true
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	if s.opts.RemoteAddr == nil {
+		return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1024}
+	}
+	return s.opts.RemoteAddr
+}
+
+func (s *Session) SiteName() string     { return s.opts.SiteName }
+func (s *Session) LoginMessage() string { return s.opts.LoginMessage }
+
+func (s *Session) Logger() logging.Logger {
+	if s.opts.Logger == nil {
+		return logging.NewWriterLogger(ioutil.Discard, logging.LevelError, logging.FormatConsole)
+	}
+	return s.opts.Logger
+}
+
+func (s *Session) Close() error {
+	s.Closed = true
+	return nil
+}
+
+// Reinitialize flushes the session back to before USER, as the server
+// does for REIN or a USER after logging in
+func (s *Session) Reinitialize() {
+	if s.data != nil {
+		s.data.Close()
+		s.data = nil
+	}
+
+	s.state = cmd.SessionStateNull
+	if s.tls {
+		s.state = cmd.SessionStateAuth
+	}
+
+	s.binaryMode = false
+	s.lastCommand = ""
+	s.renameFrom = []string{}
+	s.restartPosition = 0
+	s.xdupe = 0
+
+	s.login = ""
+	s.anonymous = false
+
+	s.currentDir = "/"
+
+	s.values.Clear()
+}
+
+// Violation records the reason, the session's ip is failed with Bans if
+// there are any
+func (s *Session) Violation(reason string) bool {
+	s.Violations = append(s.Violations, reason)
+
+	if s.opts.Bans == nil {
+		return false
+	}
+
+	addr, ok := s.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	b, err := s.opts.Bans.Fail(addr.IP, reason)
+
+	return err == nil && b != nil
+}
+
+func (s *Session) FS() vfs.VFS             { return s.opts.FS }
+func (s *Session) Auth() acl.Authenticator { return s.opts.Auth }
+func (s *Session) Stats() stats.Stats      { return s.opts.Stats }
+func (s *Session) Dupe() dupe.Dupe         { return s.opts.Dupe }
+func (s *Session) Dirlog() dirlog.Dirlog   { return s.opts.Dirlog }
+func (s *Session) Inbox() inbox.Inbox      { return s.opts.Inbox }
+func (s *Session) Nukes() nuke.Nukes       { return s.opts.Nukes }
+func (s *Session) Pre() pre.Pre            { return s.opts.Pre }
+func (s *Session) Race() race.Race         { return s.opts.Race }
+func (s *Session) Audit() audit.Log        { return s.opts.Audit }
+func (s *Session) Bans() ban.Bans          { return s.opts.Bans }
+
+func (s *Session) Lua() script.Engine {
+	if s.opts.Lua == nil {
+		return noScripts{}
+	}
+	return s.opts.Lua
+}
+
+func (s *Session) Data() cmd.DataConn { return s.data }
+func (s *Session) ClearData()         { s.data = nil }
+
+// NewPassiveDataConn opens a DataConn reading Upload
+func (s *Session) NewPassiveDataConn(ctx context.Context) error {
+	s.data = s.newDataConn("Passive")
+	return nil
+}
+
+// NewActiveDataConn opens a DataConn reading Upload, the address isn't
+// connected to
+func (s *Session) NewActiveDataConn(ctx context.Context, params string) error {
+	s.data = s.newDataConn("Active")
+	return nil
+}
+
+func (s *Session) newDataConn(kind string) *DataConn {
+	d := newDataConn(kind, s.Upload)
+	s.DataConns = append(s.DataConns, d)
+	return d
+}
+
+func (s *Session) State() cmd.SessionState         { return s.state }
+func (s *Session) SetState(state cmd.SessionState) { s.state = state }
+
+func (s *Session) SetBinaryMode(t bool) { s.binaryMode = t }
+func (s *Session) BinaryMode() bool     { return s.binaryMode }
+
+func (s *Session) SetDataProtected(t bool) { s.dataProtected = t }
+func (s *Session) DataProtected() bool     { return s.dataProtected }
+
+func (s *Session) SetRestartPosition(t int) { s.restartPosition = t }
+func (s *Session) RestartPosition() int     { return s.restartPosition }
+
+func (s *Session) SetRenameFrom(t []string) { s.renameFrom = t }
+func (s *Session) RenameFrom() []string     { return s.renameFrom }
+
+func (s *Session) SetXDupe(t int) { s.xdupe = t }
+func (s *Session) XDupe() int     { return s.xdupe }
+
+func (s *Session) SetCWD(t string) { s.currentDir = t }
+func (s *Session) CWD() string     { return s.currentDir }
+
+func (s *Session) Values() *cmd.Values { return &s.values }
+
+// SetLogin sets the login, any anonymous login is given up
+func (s *Session) SetLogin(t string) {
+	s.anonymous = false
+	s.login = t
+}
+
+func (s *Session) Login() string { return s.login }
+func (s *Session) Ident() string { return s.opts.Ident }
+
+func (s *Session) AnonymousEnabled() bool { return s.opts.Anonymous }
+func (s *Session) Anonymous() bool        { return s.anonymous }
+
+// LoginAnonymous marks the session as anonymous, there is no limit on
+// anonymous sessions
+func (s *Session) LoginAnonymous() bool {
+	s.anonymous = true
+	return true
+}
+
+func (s *Session) User() (*acl.User, bool) {
+	if s.anonymous {
+		return acl.NewAnonymousUser(), true
+	}
+
+	if s.opts.Auth == nil {
+		return nil, false
+	}
+
+	u, err := s.opts.Auth.GetUser(s.login)
+	if err != nil {
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Session) Template(name string) (*acl.Template, bool) {
+	return acl.FindTemplate(s.opts.Templates, name)
+}
+
+func (s *Session) CommandAllowed(command string, user *acl.User) bool {
+	return s.commandPermissions.Match(command, user)
+}
+
+func (s *Session) Aliases() cmd.Aliases { return s.opts.Aliases }
+
+// Sessions returns the session itself followed by the other Sessions
+func (s *Session) Sessions() []cmd.SessionInfo {
+	ip := ""
+	if addr, ok := s.RemoteAddr().(*net.TCPAddr); ok {
+		ip = addr.IP.String()
+	}
+
+	self := cmd.SessionInfo{
+		Login:     s.login,
+		Anonymous: s.anonymous,
+		IP:        ip,
+		State:     s.state,
+		Command:   s.command,
+		CWD:       s.currentDir,
+		Traced:    s.traced,
+	}
+
+	return append([]cmd.SessionInfo{self}, s.opts.Sessions...)
+}
+
+func (s *Session) ServerInfo() cmd.ServerInfo { return s.opts.ServerInfo }
+
+// Kick removes the other Sessions fn returns true for
+func (s *Session) Kick(fn func(cmd.SessionInfo) bool) int {
+	var kept []cmd.SessionInfo
+
+	for _, info := range s.opts.Sessions {
+		if !fn(info) {
+			kept = append(kept, info)
+		}
+	}
+
+	n := len(s.opts.Sessions) - len(kept)
+	s.opts.Sessions = kept
+
+	return n
+}
+
+// Trace sets Traced of the session itself and the other Sessions fn returns
+// true for
+func (s *Session) Trace(fn func(cmd.SessionInfo) bool, on bool) int {
+	var n int
+
+	if fn(s.Sessions()[0]) {
+		s.traced = on
+		n++
+	}
+
+	for i := range s.opts.Sessions {
+		if fn(s.opts.Sessions[i]) {
+			s.opts.Sessions[i].Traced = on
+			n++
+		}
+	}
+
+	return n
+}
+
+func (s *Session) CloseSite(reason string) {
+	s.closed = true
+	s.closedReason = reason
+}
+
+func (s *Session) ReopenSite() bool {
+	wasClosed := s.closed
+
+	s.closed = false
+	s.closedReason = ""
+
+	return wasClosed
+}
+
+func (s *Session) SiteClosed() (string, bool) { return s.closedReason, s.closed }
+
+func (s *Session) LastCommand() string { return s.lastCommand }
+
+// Exec runs a line sent by the client the way the server does, without any
+// hooks, returning the replies sent. Only fatal errors are returned
+func (s *Session) Exec(ctx context.Context, line string) ([]Reply, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	start := len(s.Replies)
+
+	err := s.exec(ctx, fields)
+
+	return s.Replies[start:], err
+}
+
+// exec mirrors the server's handling of a command
+func (s *Session) exec(ctx context.Context, fields []string) error {
+	fields = s.opts.Aliases.ResolveLine(fields)
+
+	name := strings.ToUpper(fields[0])
+
+	c, ok := cmd.CommandMap[name]
+	if !ok {
+		return s.ReplyStatus(cmd.StatusNotImplemented)
+	}
+
+	if s.state < c.RequireState() {
+		switch c.RequireState() {
+		case cmd.SessionStateAuth:
+			if s.Violation("sent " + name + " before AUTH") {
+				return cmd.ReplyBanned(s)
+			}
+			return s.ReplyWithMessage(cmd.StatusBadCommandSequence, "Please send AUTH first.")
+		case cmd.SessionStateLoggedIn:
+			return s.ReplyStatus(cmd.StatusNotLoggedIn)
+		}
+		return s.ReplyStatus(cmd.StatusNotImplemented)
+	}
+
+	s.command = name
+	if name == "SITE" && len(fields) > 1 {
+		s.command += " " + strings.ToUpper(fields[1])
+	}
+	defer func() { s.command = "" }()
+
+	if err := c.Execute(ctx, s, fields[1:]); err != nil {
+		if errors.Is(err, cmd.ErrCommandFatal) {
+			return err
+		}
+		return nil
+	}
+
+	s.lastCommand = name
+
+	return nil
+}
+
+var _ cmd.Session = (*Session)(nil)
+
+// noScripts is the Lua engine of a Session without one
+type noScripts struct{}
+
+func (noScripts) Commands() []string { return nil }
+
+func (noScripts) Site(string, script.Env, *acl.User, []string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (noScripts) Event(script.Vars, script.Env) {}
+func (noScripts) Close() error                  { return nil }
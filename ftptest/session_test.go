@@ -0,0 +1,198 @@
+package ftptest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+func TestNewSessionBadPermission(t *testing.T) {
+	_, err := NewSession(&SessionOpts{Permissions: []string{"who"}})
+	checkErr(t, err, errors.New("command rule requires minimum of 2 fields"))
+}
+
+func TestConverse(t *testing.T) {
+	var tests = []struct {
+		name  string
+		opts  SessionOpts
+		steps []Step
+		err   error
+	}{
+		{
+			"anonymous login",
+			SessionOpts{TLS: true, Anonymous: true},
+			[]Step{
+				{"USER anonymous", 331},
+				{"PASS me@example.com", 230},
+				{"PWD", 257},
+			},
+			nil,
+		},
+		{
+			"unexpected reply",
+			SessionOpts{TLS: true},
+			[]Step{
+				{"PASS me@example.com", 230},
+			},
+			errors.New("step 1 'PASS me@example.com' expected 230 but got '503 Bad sequence of commands.'"),
+		},
+		{
+			"before auth",
+			SessionOpts{},
+			[]Step{
+				{"USER anonymous", 503},
+			},
+			nil,
+		},
+		{
+			"unknown command",
+			SessionOpts{TLS: true},
+			[]Step{
+				{"XYZZY", 502},
+			},
+			nil,
+		},
+		{
+			"reinitialize",
+			SessionOpts{TLS: true, Anonymous: true},
+			[]Step{
+				{"USER anonymous", 331},
+				{"PASS me@example.com", 230},
+				{"REIN", 220},
+				{"PWD", 530},
+			},
+			nil,
+		},
+		{
+			"user after login",
+			SessionOpts{TLS: true, Anonymous: true},
+			[]Step{
+				{"USER anonymous", 331},
+				{"PASS me@example.com", 230},
+				{"USER someone", 331},
+				{"PWD", 530},
+			},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(t, &tt.opts)
+
+			err := s.Converse(context.Background(), tt.steps...)
+			checkErr(t, err, tt.err)
+
+			if err != nil && tt.err != nil && err.Error() != tt.err.Error() {
+				t.Fatalf("expected '%s' but got '%s'", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestExecRecordsViolations(t *testing.T) {
+	s := newTestSession(t, &SessionOpts{})
+
+	replies, err := s.Exec(context.Background(), "USER someone")
+	checkErr(t, err, nil)
+
+	if len(replies) != 1 || replies[0].Code != cmd.StatusBadCommandSequence.Code {
+		t.Fatalf("expected a single 503 but got %v", replies)
+	}
+
+	if len(s.Violations) != 1 {
+		t.Fatalf("expected 1 violation but got %d", len(s.Violations))
+	}
+}
+
+func TestExecLastCommand(t *testing.T) {
+	s := newTestSession(t, &SessionOpts{TLS: true, Anonymous: true})
+
+	err := s.Converse(context.Background(),
+		Step{"USER anonymous", 331},
+		Step{"PASS me@example.com", 230},
+		Step{"MODE S", 200},
+	)
+	checkErr(t, err, nil)
+
+	if s.LastCommand() != "MODE" {
+		t.Fatalf("expected last command MODE but got '%s'", s.LastCommand())
+	}
+
+	if reply, ok := s.LastReply(); !ok || reply.Code != 200 {
+		t.Fatalf("expected last reply 200 but got %v", reply)
+	}
+}
+
+func TestLoginAs(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	defer closeTestAuthenticator(t, auth)
+
+	if _, err := auth.AddUser("someone", "password"); err != nil {
+		t.Fatalf("unexpected error adding user: %s", err)
+	}
+
+	s := newTestSession(t, &SessionOpts{Auth: auth})
+
+	checkErr(t, s.LoginAs("nobody"), errors.New("user not found"))
+	checkErr(t, s.LoginAs("someone"), nil)
+
+	if s.State() != cmd.SessionStateLoggedIn {
+		t.Fatalf("expected logged in but got %d", s.State())
+	}
+
+	user, ok := s.User()
+	if !ok || user.Name != "someone" {
+		t.Fatalf("expected user someone but got %v", user)
+	}
+}
+
+func TestDataConn(t *testing.T) {
+	s := newTestSession(t, &SessionOpts{})
+	s.Upload = []byte("uploaded")
+
+	checkErr(t, s.NewPassiveDataConn(context.Background()), nil)
+
+	d := s.Data()
+
+	b, err := ioutil.ReadAll(d)
+	checkErr(t, err, nil)
+
+	if string(b) != "uploaded" || d.BytesRead() != len(b) {
+		t.Fatalf("expected to read 'uploaded' but got '%s' (%d)", b, d.BytesRead())
+	}
+
+	if _, err := d.Write([]byte("downloaded")); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+
+	checkErr(t, d.Close(), nil)
+
+	_, err = d.Write([]byte("closed"))
+	checkErr(t, err, errDataClosed)
+
+	if len(s.DataConns) != 1 || s.DataConns[0].Downloaded.String() != "downloaded" {
+		t.Fatalf("expected 'downloaded' to be kept")
+	}
+}
+
+func TestKick(t *testing.T) {
+	s := newTestSession(t, &SessionOpts{
+		Sessions: []cmd.SessionInfo{
+			{ID: 2, Login: "a"},
+			{ID: 3, Login: "b"},
+		},
+	})
+
+	n := s.Kick(func(info cmd.SessionInfo) bool { return info.Login == "a" })
+	if n != 1 {
+		t.Fatalf("expected 1 kicked but got %d", n)
+	}
+
+	if sessions := s.Sessions(); len(sessions) != 2 || sessions[1].Login != "b" {
+		t.Fatalf("expected the session itself and b but got %v", sessions)
+	}
+}
@@ -0,0 +1,371 @@
+package glftpd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/pkg/errors"
+)
+
+// Flags maps glftpd's flags to goftpd flags, any flag not in the map is
+// imported as glftpd followed by the lowercased flag, i.e. glftpdh
+var Flags = map[rune]string{
+	'1': "siteop",
+	'2': "gadmin",
+	'3': "glock",
+	'4': "exempt",
+	'5': "color",
+	'6': acl.FlagDeleted,
+	'7': "useredit",
+	'8': "anonymous",
+	'A': "nuke",
+	'B': "unnuke",
+	'C': "undupe",
+	'D': "kick",
+	'E': "kill",
+	'F': "take",
+	'G': "shutdown",
+}
+
+// statPeriods maps userfile stat keywords to the stats Period they are
+// imported in to
+var statPeriods = map[string]stats.Period{
+	"ALLUP":   stats.PeriodAllTime,
+	"ALLDN":   stats.PeriodAllTime,
+	"MONTHUP": stats.PeriodMonth,
+	"MONTHDN": stats.PeriodMonth,
+	"WKUP":    stats.PeriodWeek,
+	"WKDN":    stats.PeriodWeek,
+	"DAYUP":   stats.PeriodDay,
+	"DAYDN":   stats.PeriodDay,
+}
+
+// ImporterOpts are the locations of the glftpd files to import
+type ImporterOpts struct {
+	// etc/passwd and etc/group
+	Passwd string
+	Group  string
+
+	// ftp-data/users and ftp-data/groups directories
+	Users  string
+	Groups string
+
+	// maps glftpd stat section numbers to goftpd section names, section 0
+	// and any missing sections are imported in to stats.DefaultSection
+	Sections map[int]string
+}
+
+// NewImporterOpts returns the default file locations for a glftpd install
+// in root, i.e. /glftpd
+func NewImporterOpts(root string) *ImporterOpts {
+	return &ImporterOpts{
+		Passwd: filepath.Join(root, "etc", "passwd"),
+		Group:  filepath.Join(root, "etc", "group"),
+		Users:  filepath.Join(root, "ftp-data", "users"),
+		Groups: filepath.Join(root, "ftp-data", "groups"),
+	}
+}
+
+// Importer creates goftpd users and groups from a glftpd install
+type Importer struct {
+	*ImporterOpts
+	auth  acl.Authenticator
+	stats stats.Stats
+}
+
+// Result is the outcome of an Import. Warnings are problems that did not
+// stop the import, i.e. a user that already exists
+type Result struct {
+	Users    []string
+	Groups   []string
+	Warnings []string
+}
+
+func (r *Result) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// NewImporter creates an Importer, st can be nil in which case stats are
+// not imported
+func NewImporter(opts *ImporterOpts, auth acl.Authenticator, st stats.Stats) *Importer {
+	return &Importer{
+		ImporterOpts: opts,
+		auth:         auth,
+		stats:        st,
+	}
+}
+
+// Import creates the groups and then the users. Users and groups that
+// already exist are skipped
+func (i *Importer) Import() (*Result, error) {
+	var result Result
+
+	f, err := os.Open(i.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := ParseGroup(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err = os.Open(i.Passwd)
+	if err != nil {
+		return nil, err
+	}
+
+	passwd, err := ParsePasswd(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		if _, err := i.auth.AddGroup(g.Name); err != nil {
+			if err == acl.ErrGroupExists {
+				result.warn("group '%s' already exists, skipping", g.Name)
+				continue
+			}
+			return nil, errors.WithMessagef(err, "group '%s'", g.Name)
+		}
+
+		result.Groups = append(result.Groups, g.Name)
+	}
+
+	for _, p := range passwd {
+		ok, err := i.importUser(p, &result)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "user '%s'", p.Name)
+		}
+
+		if ok {
+			result.Users = append(result.Users, p.Name)
+		}
+	}
+
+	// slots are set once everyone has been added so they are not used up
+	// by the import
+	for _, name := range result.Groups {
+		if err := i.importGroupFile(name); err != nil {
+			return nil, errors.WithMessagef(err, "group '%s'", name)
+		}
+	}
+
+	return &result, nil
+}
+
+// importUser creates the User from the passwd entry and their userfile,
+// returns false if the User was skipped
+func (i *Importer) importUser(p PasswdEntry, result *Result) (bool, error) {
+	uf := &UserFile{}
+	hasUserFile := true
+
+	f, err := os.Open(filepath.Join(i.Users, p.Name))
+	if err == nil {
+		uf, err = ParseUserFile(f)
+		f.Close()
+		if err != nil {
+			return false, err
+		}
+	} else if os.IsNotExist(err) {
+		hasUserFile = false
+		result.warn("user '%s' has no userfile, only the password was imported", p.Name)
+	} else {
+		return false, err
+	}
+
+	// the password isn't known so a random one is used until the glftpd
+	// hash is set
+	if _, err := i.auth.AddUser(p.Name, randomPassword()); err != nil {
+		if err == acl.ErrUserExists {
+			result.warn("user '%s' already exists, skipping", p.Name)
+			return false, nil
+		}
+		return false, err
+	}
+
+	err = i.auth.UpdateUser(p.Name, func(u *acl.User) error {
+		u.Password = []byte(acl.GlftpdPasswordPrefix + p.Hash)
+
+		for _, flag := range ConvertFlags(uf.Flags) {
+			u.AddFlag(flag)
+		}
+
+		// glftpd uses a ratio of 0 for leeches
+		if hasUserFile && uf.Ratio == 0 {
+			u.AddFlag(acl.FlagLeech)
+		}
+
+		u.Ratio = uf.Ratio
+		u.Credits = uf.Credits
+		u.ExpiresAt = uf.Expires
+		u.Logins = uf.Logins
+		u.LastLoginAt = uf.LastLogin
+
+		if !uf.Added.IsZero() {
+			u.CreatedAt = uf.Added
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, mask := range uf.IPs {
+		if err := i.auth.AddIP(p.Name, mask); err != nil {
+			result.warn("user '%s': unable to add ip mask '%s': %s", p.Name, mask, err)
+		}
+	}
+
+	for _, g := range uf.Groups {
+		if err := i.auth.AddUserToGroup(p.Name, g.Name); err != nil {
+			result.warn("user '%s': unable to add to group '%s': %s", p.Name, g.Name, err)
+			continue
+		}
+
+		if !g.Gadmin {
+			continue
+		}
+
+		err := i.auth.UpdateUser(p.Name, func(u *acl.User) error {
+			for name, settings := range u.Groups {
+				if strings.EqualFold(name, g.Name) {
+					settings.IsAdmin = true
+					u.Groups[name] = settings
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := i.importStats(p.Name, uf); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// importGroupFile sets the slots from the group's groupfile if it has one
+func (i *Importer) importGroupFile(name string) error {
+	f, err := os.Open(filepath.Join(i.Groups, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	gf, err := ParseGroupFile(f)
+	if err != nil {
+		return err
+	}
+
+	return i.auth.UpdateGroup(name, func(g *acl.Group) error {
+		g.GadminSlots = convertSlots(gf.Slots)
+		g.LeechSlots = convertSlots(gf.LeechSlots)
+		return nil
+	})
+}
+
+// importStats adds the userfile stats to each section as well as
+// stats.AllSections. Only the all time stats keep their meaning, the
+// month, week and day stats are added to the current period
+func (i *Importer) importStats(name string, uf *UserFile) error {
+	if i.stats == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	for _, keyword := range StatKeywords {
+		period := statPeriods[keyword]
+		upload := strings.HasSuffix(keyword, "UP")
+
+		var all stats.Totals
+
+		for n, transfers := range uf.Stats[keyword] {
+			if transfers.Files == 0 && transfers.Bytes == 0 {
+				continue
+			}
+
+			var totals stats.Totals
+			if upload {
+				totals.UploadFiles, totals.UploadBytes = transfers.Files, transfers.Bytes
+			} else {
+				totals.DownloadFiles, totals.DownloadBytes = transfers.Files, transfers.Bytes
+			}
+
+			if err := i.stats.Add(name, i.section(n), period, now, totals); err != nil {
+				return err
+			}
+
+			all.Merge(totals)
+		}
+
+		if all == (stats.Totals{}) {
+			continue
+		}
+
+		if err := i.stats.Add(name, stats.AllSections, period, now, all); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// section returns the goftpd section name for a glftpd section number
+func (i *Importer) section(n int) string {
+	if name, ok := i.Sections[n]; ok && n > 0 {
+		return name
+	}
+	return stats.DefaultSection
+}
+
+// ConvertFlags converts a glftpd flag string, i.e. 1ABC, to goftpd flags
+func ConvertFlags(flags string) []string {
+	var converted []string
+
+	for _, r := range flags {
+		if flag, ok := Flags[r]; ok {
+			converted = append(converted, flag)
+			continue
+		}
+
+		converted = append(converted, "glftpd"+strings.ToLower(string(r)))
+	}
+
+	return converted
+}
+
+// convertSlots converts glftpd's unlimited slots (-1) to a count that will
+// not run out
+func convertSlots(n int) int {
+	if n < 0 {
+		return math.MaxInt32
+	}
+	return n
+}
+
+func randomPassword() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
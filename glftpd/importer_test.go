@@ -0,0 +1,242 @@
+package glftpd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/stats"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const testUserFile = `
+USER Added by glftpd
+GENERAL 0,0 -1 0 0
+FLAGS 1ABCH
+CREDITS 2048 0 0
+RATIO 3 0 0
+ADDED 1577836800 glftpd
+EXPIRES 0
+TIME 42 1580515200 0 0
+IP *@127.0.0.1
+IP ident@10.0.0.*
+GROUP siteops 1
+GROUP friends 0
+ALLUP 10 1000 60 5 500 30
+ALLDN 2 200 10
+WKUP 1 100 5
+`
+
+func TestParseUserFile(t *testing.T) {
+	uf, err := ParseUserFile(strings.NewReader(testUserFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if uf.Flags != "1ABCH" || uf.Credits != 2048*1024 || uf.Ratio != 3 {
+		t.Errorf("unexpected userfile: %+v", uf)
+	}
+
+	if uf.Logins != 42 || !uf.LastLogin.Equal(time.Unix(1580515200, 0)) {
+		t.Errorf("unexpected logins: %+v", uf)
+	}
+
+	if !uf.Expires.IsZero() || !uf.Added.Equal(time.Unix(1577836800, 0)) {
+		t.Errorf("unexpected times: %+v", uf)
+	}
+
+	if len(uf.IPs) != 2 || len(uf.Groups) != 2 || !uf.Groups[0].Gadmin || uf.Groups[1].Gadmin {
+		t.Errorf("unexpected ips or groups: %+v", uf)
+	}
+
+	up := uf.Stats["ALLUP"]
+	if len(up) != 2 || up[1] != (Transfers{Files: 5, Bytes: 500 * 1024, Seconds: 30}) {
+		t.Errorf("unexpected ALLUP: %+v", up)
+	}
+
+	if _, err := ParseUserFile(strings.NewReader("ALLUP 1 2")); err == nil {
+		t.Error("expected error for incomplete stats")
+	}
+}
+
+func TestConvertFlags(t *testing.T) {
+	got := strings.Join(ConvertFlags("13AH"), ",")
+	if got != "siteop,glock,nuke,glftpdh" {
+		t.Errorf("unexpected flags: %s", got)
+	}
+}
+
+func TestImport(t *testing.T) {
+	root, err := ioutil.TempDir("", "glftpd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	opts := NewImporterOpts(root)
+	opts.Sections = map[int]string{1: "mp3"}
+
+	salt := []byte("salt")
+	hash := pbkdf2.Key([]byte("password"), salt, 100, sha1.Size, sha1.New)
+
+	writeFile(t, opts.Passwd, strings.Join([]string{
+		"glftpd:$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(hash) + ":0:0:01-01-20:/site:/bin/false",
+		"leech:$00$00:1:100:01-01-20:/site:/bin/false",
+	}, "\n"))
+
+	writeFile(t, opts.Group, "siteops:SiteOps:100:\nfriends:Friends:200:\n")
+	writeFile(t, filepath.Join(opts.Users, "glftpd"), testUserFile)
+	writeFile(t, filepath.Join(opts.Users, "leech"), "RATIO 0\nGROUP friends 0\nIP badmask@\n")
+	writeFile(t, filepath.Join(opts.Groups, "siteops"), "GROUPNFO SiteOps\nSLOTS 2 -1\n")
+
+	auth := newMemoryAuthenticator(t)
+	st := newMemoryStats(t)
+	defer st.Close()
+
+	if _, err := auth.AddGroup("friends"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := NewImporter(opts, auth, st).Import()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(result.Users) != 2 || len(result.Groups) != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// friends already existed and leech has a bad mask
+	if len(result.Warnings) != 2 {
+		t.Errorf("expected 2 warnings got: %v", result.Warnings)
+	}
+
+	u, err := auth.GetUser("glftpd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, flag := range []string{"siteop", "nuke", "unnuke", "undupe", "glftpdh"} {
+		if !u.HasFlag(flag) {
+			t.Errorf("expected flag '%s'", flag)
+		}
+	}
+
+	if u.HasFlag(acl.FlagLeech) || u.Ratio != 3 || u.Credits != 2048*1024 || u.Logins != 42 {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	if u.PrimaryGroup != "siteops" || !u.IsGroupAdmin("siteops") || u.IsGroupAdmin("friends") {
+		t.Errorf("unexpected groups: %s %+v", u.PrimaryGroup, u.Groups)
+	}
+
+	if !u.MatchIP("ident", "10.0.0.1", nil) || !u.MatchIP("any", "127.0.0.1", nil) {
+		t.Errorf("unexpected ips: %+v", u.IPs)
+	}
+
+	// the glftpd hash should work and be replaced
+	if !auth.CheckPassword("glftpd", "password") {
+		t.Fatal("expected glftpd password to be accepted")
+	}
+
+	u, _ = auth.GetUser("glftpd")
+	if strings.HasPrefix(string(u.Password), acl.GlftpdPasswordPrefix) {
+		t.Error("expected glftpd hash to be replaced")
+	}
+
+	leech, err := auth.GetUser("leech")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !leech.HasFlag(acl.FlagLeech) || !leech.InGroup("friends") {
+		t.Errorf("unexpected leech: %+v", leech)
+	}
+
+	g, err := auth.GetGroup("siteops")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if g.GadminSlots != 2 || g.LeechSlots != math.MaxInt32 || len(g.Members) != 1 {
+		t.Errorf("unexpected group: %+v", g)
+	}
+
+	now := time.Now()
+
+	var tests = []struct {
+		section  string
+		period   stats.Period
+		expected stats.Totals
+	}{
+		{stats.DefaultSection, stats.PeriodAllTime, stats.Totals{UploadFiles: 10, UploadBytes: 1000 * 1024, DownloadFiles: 2, DownloadBytes: 200 * 1024}},
+		{"mp3", stats.PeriodAllTime, stats.Totals{UploadFiles: 5, UploadBytes: 500 * 1024}},
+		{stats.AllSections, stats.PeriodAllTime, stats.Totals{UploadFiles: 15, UploadBytes: 1500 * 1024, DownloadFiles: 2, DownloadBytes: 200 * 1024}},
+		{stats.AllSections, stats.PeriodWeek, stats.Totals{UploadFiles: 1, UploadBytes: 100 * 1024}},
+		{stats.AllSections, stats.PeriodDay, stats.Totals{}},
+	}
+
+	for _, tt := range tests {
+		totals, err := st.Get("glftpd", tt.section, tt.period, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if totals != tt.expected {
+			t.Errorf("expected %s %s totals %+v got %+v", tt.section, tt.period, tt.expected, totals)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func newMemoryAuthenticator(t *testing.T) *acl.BadgerAuthenticator {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	return acl.NewBadgerAuthenticator(&acl.AuthenticatorOpts{}, db)
+}
+
+func newMemoryStats(t *testing.T) *stats.BadgerStats {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	section, err := stats.NewSection("mp3 /mp3/**")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return stats.NewBadgerStats(&stats.StatsOpts{}, db, []stats.Section{section})
+}
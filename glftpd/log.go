@@ -0,0 +1,142 @@
+package glftpd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// events written to the log, each line is the time in ctime format, the
+// event followed by a colon and then the fields each in double quotes:
+//
+//	NEWDIR:  "<path>" "<user>" "<group>" "<tagline>"
+//	DELDIR:  "<path>" "<user>" "<group>" "<tagline>"
+//	WIPE:    "<path>" "<user>" "<group>" "<tagline>"
+//	NUKE:    "<path>" "<nuker>@<group>" "<nukee>@<group>" "<multiplier> <megabytes>" "<reason>"
+//	UNNUKE:  "<path>" "<nuker>@<group>" "<nukee>@<group>" "<multiplier> <megabytes>" "<reason>"
+//	REQUEST: "<user>" "<group>" "<request>"
+//	COMPLETE:  "<path>" "<megabytes>" "<files>" "<seconds>" "<racers>"
+//	RACEUSER:  "<path>" "<rank>" "<user>@<group>" "<megabytes>" "<files>" "<percent>" "<KB/s>"
+//	RACEGROUP: "<path>" "<rank>" "<group>" "<megabytes>" "<files>" "<percent>"
+//
+// nukes and unnukes are written one line per nukee as glftpd does, a
+// COMPLETE is followed by a line per racer and group in order of their rank
+const (
+	LogNewDir    = "NEWDIR"
+	LogDelDir    = "DELDIR"
+	LogWipe      = "WIPE"
+	LogNuke      = "NUKE"
+	LogUnnuke    = "UNNUKE"
+	LogRequest   = "REQUEST"
+	LogComplete  = "COMPLETE"
+	LogRaceUser  = "RACEUSER"
+	LogRaceGroup = "RACEGROUP"
+)
+
+// logTimeFormat is ctime(3), which glftpd uses for every log
+const logTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// Log writes events in the format of glftpd.log so that existing sitebots
+// and parsers can follow the site
+type Log interface {
+	// paths are as goftpd sees them, the log prefixes them
+	Write(string, ...string) error
+	Path(string) string
+	Close() error
+}
+
+type LogOpts struct {
+	// the file to append to, nothing is written when empty
+	File string `goftpd:"log"`
+	// prepended to every path, glftpd logs paths as seen from inside its
+	// chroot so sitebots usually expect /site
+	Prefix string `goftpd:"log_prefix"`
+}
+
+// FileLog implements Log appending to a file
+type FileLog struct {
+	*LogOpts
+
+	mtx sync.Mutex
+	w   io.WriteCloser
+}
+
+// NewFileLog opens the file in the options for appending, creating it if
+// needed
+func NewFileLog(opts *LogOpts) (*FileLog, error) {
+	l := FileLog{
+		LogOpts: opts,
+	}
+
+	if len(opts.File) == 0 {
+		return &l, nil
+	}
+
+	f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "opening glftpd log '%s'", opts.File)
+	}
+
+	l.w = f
+
+	return &l, nil
+}
+
+// Path returns the path as it is written to the log
+func (l *FileLog) Path(path string) string {
+	return filepath.Join("/", l.Prefix, path)
+}
+
+// Write appends a line for the event with the fields
+func (l *FileLog) Write(event string, fields ...string) error {
+	if l.w == nil {
+		return nil
+	}
+
+	line := FormatLogLine(time.Now(), event, fields...)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	_, err := io.WriteString(l.w, line)
+
+	return err
+}
+
+// Close closes the file
+func (l *FileLog) Close() error {
+	if l.w == nil {
+		return nil
+	}
+
+	return l.w.Close()
+}
+
+// FormatLogLine returns the line glftpd would log for the event, ending in a
+// new line. Double quotes and new lines can't be escaped in the format so
+// are replaced
+func FormatLogLine(t time.Time, event string, fields ...string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s:", t.Format(logTimeFormat), event)
+
+	for _, f := range fields {
+		f = strings.NewReplacer(`"`, "'", "\r", " ", "\n", " ").Replace(f)
+		fmt.Fprintf(&b, ` "%s"`, f)
+	}
+
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// FormatMegabytes formats bytes the way nuke lines give the size of a nukee
+func FormatMegabytes(bytes int64) string {
+	return fmt.Sprintf("%.1f", float64(bytes)/1024/1024)
+}
@@ -0,0 +1,91 @@
+package glftpd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLogLine(t *testing.T) {
+	at := time.Date(2020, time.March, 1, 9, 5, 3, 0, time.UTC)
+
+	var tests = []struct {
+		event    string
+		fields   []string
+		expected string
+	}{
+		{
+			LogNewDir,
+			[]string{"/site/mp3/Some-Release", "user", "group", "a tagline"},
+			`Sun Mar  1 09:05:03 2020 NEWDIR: "/site/mp3/Some-Release" "user" "group" "a tagline"` + "\n",
+		},
+		{
+			LogNuke,
+			[]string{"/site/mp3/Some-Release", "nuker@staff", "user@group", "3 12.5", "bad rip"},
+			`Sun Mar  1 09:05:03 2020 NUKE: "/site/mp3/Some-Release" "nuker@staff" "user@group" "3 12.5" "bad rip"` + "\n",
+		},
+		{
+			LogRequest,
+			[]string{"user", "group", "a \"quoted\"\nrequest"},
+			`Sun Mar  1 09:05:03 2020 REQUEST: "user" "group" "a 'quoted' request"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			got := FormatLogLine(at, tt.event, tt.fields...)
+			if got != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goftpd-glftpd-log")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "glftpd.log")
+
+	l, err := NewFileLog(&LogOpts{File: file, Prefix: "/site"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := l.Path("/mp3/Some-Release"); got != "/site/mp3/Some-Release" {
+		t.Fatalf("expected '/site/mp3/Some-Release' got '%s'", got)
+	}
+
+	if err := l.Write(LogDelDir, l.Path("/mp3/Some-Release"), "user", "group", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.HasSuffix(string(b), ` DELDIR: "/site/mp3/Some-Release" "user" "group" ""`+"\n") {
+		t.Fatalf("unexpected log '%s'", b)
+	}
+
+	// without a file nothing is written
+	l, err = NewFileLog(&LogOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := l.Write(LogWipe, "/mp3"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,332 @@
+// Package glftpd reads glftpd's passwd, group, user and group files so that
+// an existing site can be imported in to goftpd, and writes glftpd.log so
+// that tools built for glftpd keep working
+package glftpd
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PasswdEntry is a line from glftpd's etc/passwd
+type PasswdEntry struct {
+	Name string
+	Hash string
+	UID  int
+	GID  int
+}
+
+// GroupEntry is a line from glftpd's etc/group
+type GroupEntry struct {
+	Name        string
+	Description string
+	GID         int
+}
+
+// Transfers are the stats for a single stat section
+type Transfers struct {
+	Files   int64
+	Bytes   int64
+	Seconds int64
+}
+
+// UserFile is a user's file from ftp-data/users
+type UserFile struct {
+	Flags     string
+	Credits   int64
+	Ratio     int
+	Added     time.Time
+	Expires   time.Time
+	Logins    int
+	LastLogin time.Time
+	IPs       []string
+
+	// groups in the order they appear, the first is the primary group
+	Groups []UserGroup
+
+	// stats are keyed by the userfile keyword (i.e. ALLUP) and then
+	// indexed by stat section
+	Stats map[string][]Transfers
+}
+
+// UserGroup is a GROUP line from a userfile
+type UserGroup struct {
+	Name   string
+	Gadmin bool
+}
+
+// GroupFile is a group's file from ftp-data/groups
+type GroupFile struct {
+	Description string
+	// number of users gadmins can add and number of leeches they can add,
+	// negative values are unlimited
+	Slots      int
+	LeechSlots int
+}
+
+// StatKeywords are the userfile keywords that hold transfer stats
+var StatKeywords = []string{
+	"ALLUP", "ALLDN",
+	"MONTHUP", "MONTHDN",
+	"WKUP", "WKDN",
+	"DAYUP", "DAYDN",
+}
+
+// ParsePasswd parses glftpd's etc/passwd, `user:hash:uid:gid:date:dir:shell`
+func ParsePasswd(r io.Reader) ([]PasswdEntry, error) {
+	var entries []PasswdEntry
+
+	err := scanLines(r, func(n int, line string) error {
+		parts := strings.Split(line, ":")
+
+		if len(parts) < 4 {
+			return errors.Errorf("passwd line %d: expected at least 4 fields", n)
+		}
+
+		uid, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return errors.Errorf("passwd line %d: bad uid", n)
+		}
+
+		gid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return errors.Errorf("passwd line %d: bad gid", n)
+		}
+
+		entries = append(entries, PasswdEntry{
+			Name: parts[0],
+			Hash: parts[1],
+			UID:  uid,
+			GID:  gid,
+		})
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// ParseGroup parses glftpd's etc/group, `group:description:gid:`
+func ParseGroup(r io.Reader) ([]GroupEntry, error) {
+	var entries []GroupEntry
+
+	err := scanLines(r, func(n int, line string) error {
+		parts := strings.Split(line, ":")
+
+		if len(parts) < 3 {
+			return errors.Errorf("group line %d: expected at least 3 fields", n)
+		}
+
+		gid, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return errors.Errorf("group line %d: bad gid", n)
+		}
+
+		entries = append(entries, GroupEntry{
+			Name:        parts[0],
+			Description: parts[1],
+			GID:         gid,
+		})
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// ParseUserFile parses a glftpd userfile, unknown keywords are ignored
+func ParseUserFile(r io.Reader) (*UserFile, error) {
+	uf := UserFile{
+		Stats: make(map[string][]Transfers),
+	}
+
+	err := scanLines(r, func(n int, line string) error {
+		fields := strings.Fields(line)
+		keyword, args := strings.ToUpper(fields[0]), fields[1:]
+
+		var err error
+
+		switch keyword {
+		case "FLAGS":
+			if len(args) > 0 {
+				uf.Flags = args[0]
+			}
+
+		case "CREDITS":
+			// kilobytes, the first value is the default section
+			var kb int64
+			kb, err = firstInt64(args)
+			uf.Credits = kb * 1024
+
+		case "RATIO":
+			var ratio int64
+			ratio, err = firstInt64(args)
+			uf.Ratio = int(ratio)
+
+		case "ADDED":
+			uf.Added, err = firstUnix(args)
+
+		case "EXPIRES":
+			uf.Expires, err = firstUnix(args)
+
+		case "TIME":
+			// TIME <logins> <last login> <time limit> <time today>
+			if len(args) >= 2 {
+				var logins int64
+				logins, err = firstInt64(args)
+				uf.Logins = int(logins)
+
+				if err == nil {
+					uf.LastLogin, err = firstUnix(args[1:])
+				}
+			}
+
+		case "IP":
+			if len(args) > 0 {
+				uf.IPs = append(uf.IPs, args[0])
+			}
+
+		case "GROUP":
+			if len(args) > 0 {
+				uf.Groups = append(uf.Groups, UserGroup{
+					Name:   args[0],
+					Gadmin: len(args) > 1 && args[1] == "1",
+				})
+			}
+
+		case "ALLUP", "ALLDN", "MONTHUP", "MONTHDN", "WKUP", "WKDN", "DAYUP", "DAYDN":
+			uf.Stats[keyword], err = parseTransfers(args)
+		}
+
+		if err != nil {
+			return errors.WithMessagef(err, "userfile line %d", n)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &uf, nil
+}
+
+// ParseGroupFile parses a glftpd groupfile, unknown keywords are ignored
+func ParseGroupFile(r io.Reader) (*GroupFile, error) {
+	var gf GroupFile
+
+	err := scanLines(r, func(n int, line string) error {
+		fields := strings.Fields(line)
+		keyword, args := strings.ToUpper(fields[0]), fields[1:]
+
+		switch keyword {
+		case "GROUPNFO":
+			gf.Description = strings.Join(args, " ")
+
+		case "SLOTS":
+			if len(args) < 2 {
+				return errors.Errorf("groupfile line %d: SLOTS requires 2 values", n)
+			}
+
+			slots, err := strconv.Atoi(args[0])
+			if err != nil {
+				return errors.Errorf("groupfile line %d: bad slots", n)
+			}
+
+			leech, err := strconv.Atoi(args[1])
+			if err != nil {
+				return errors.Errorf("groupfile line %d: bad leech slots", n)
+			}
+
+			gf.Slots, gf.LeechSlots = slots, leech
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &gf, nil
+}
+
+// scanLines calls fn with each non empty, non comment line and its line number
+func scanLines(r io.Reader, fn func(int, string) error) error {
+	scanner := bufio.NewScanner(r)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseTransfers parses the repeating `<files> <kb> <seconds>` values of a
+// stats line, one set per stat section
+func parseTransfers(args []string) ([]Transfers, error) {
+	if len(args)%3 != 0 {
+		return nil, errors.New("stats require sets of 3 values")
+	}
+
+	transfers := make([]Transfers, 0, len(args)/3)
+
+	for i := 0; i < len(args); i += 3 {
+		var nums [3]int64
+
+		for j := range nums {
+			n, err := strconv.ParseInt(args[i+j], 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("bad stat value '%s'", args[i+j])
+			}
+			nums[j] = n
+		}
+
+		transfers = append(transfers, Transfers{
+			Files:   nums[0],
+			Bytes:   nums[1] * 1024,
+			Seconds: nums[2],
+		})
+	}
+
+	return transfers, nil
+}
+
+func firstInt64(args []string) (int64, error) {
+	if len(args) == 0 {
+		return 0, errors.New("missing value")
+	}
+
+	n, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("bad number '%s'", args[0])
+	}
+
+	return n, nil
+}
+
+// firstUnix parses a unix timestamp, 0 is the zero time
+func firstUnix(args []string) (time.Time, error) {
+	n, err := firstInt64(args)
+	if err != nil || n == 0 {
+		return time.Time{}, err
+	}
+
+	return time.Unix(n, 0), nil
+}
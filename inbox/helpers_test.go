@@ -0,0 +1,45 @@
+package inbox
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryInbox(t *testing.T, opts *InboxOpts) *BadgerInbox {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	return NewBadgerInbox(opts, db)
+}
+
+func closeMemoryInbox(t *testing.T, b *BadgerInbox) {
+	t.Helper()
+	if err := b.Close(); err != nil {
+		t.Fatalf("error closing inbox: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
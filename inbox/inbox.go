@@ -0,0 +1,305 @@
+// Package inbox provides persistent messages sent between users, each user
+// has an inbox of messages they can read and delete
+package inbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "inbox:"
+
+var (
+	ErrInboxFull = errors.New("inbox is full")
+	ErrTooLong   = errors.New("message is too long")
+	ErrNoMessage = errors.New("no such message")
+	ErrEmpty     = errors.New("message is empty")
+)
+
+// Message is a message sent from one user to another
+type Message struct {
+	ID   uint64
+	From string
+	To   string
+	Body string
+	Time time.Time
+	Read bool
+}
+
+// Inbox stores the messages sent to each user
+type Inbox interface {
+	Send(string, string, string) (*Message, error)
+	Read(string) ([]Message, error)
+	Unread(string) (int, error)
+	Delete(string, uint64) error
+	Clear(string) (int, error)
+	Expire(time.Time) (int, error)
+	Close() error
+}
+
+type InboxOpts struct {
+	DB string `goftpd:"db"`
+	// longest message that can be sent, 0 is unlimited
+	MaxLength int `goftpd:"max_length"`
+	// messages a user can have before their inbox is full, 0 is unlimited
+	MaxMessages int `goftpd:"max_messages"`
+	// days messages are kept for, 0 keeps them forever
+	Retention int `goftpd:"retention"`
+}
+
+// BadgerInbox implements Inbox using a badger key/value store
+type BadgerInbox struct {
+	*InboxOpts
+	db *badger.DB
+}
+
+// NewBadgerInbox takes in options and a badger DB
+func NewBadgerInbox(opts *InboxOpts, db *badger.DB) *BadgerInbox {
+	return &BadgerInbox{
+		InboxOpts: opts,
+		db:        db,
+	}
+}
+
+// prefix returns the prefix of every key in the user's inbox
+func (b *BadgerInbox) prefix(user string) []byte {
+	return []byte(keyPrefix + strings.ToLower(user) + ":")
+}
+
+// key returns the key of a message, ids are big endian so that iterating
+// an inbox returns the oldest message first
+func (b *BadgerInbox) key(user string, id uint64) []byte {
+	k := b.prefix(user)
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], id)
+
+	return append(k, buf[:]...)
+}
+
+// each calls fn for every message in the user's inbox, oldest first. An
+// empty user iterates every inbox
+func (b *BadgerInbox) each(tx *badger.Txn, user string, fn func(Message) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(keyPrefix)
+
+	if len(user) > 0 {
+		opts.Prefix = b.prefix(user)
+	}
+
+	it := tx.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		var m Message
+
+		err := it.Item().Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &m)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// set stores the message in its recipient's inbox
+func (b *BadgerInbox) set(tx *badger.Txn, m Message) error {
+	var buf bytes.Buffer
+
+	if err := msgpack.NewEncoder(&buf).Encode(m); err != nil {
+		return err
+	}
+
+	return tx.Set(b.key(m.To, m.ID), buf.Bytes())
+}
+
+// Send stores a message from one user in the inbox of another. Fails if the
+// message is too long or the recipient's inbox is full
+func (b *BadgerInbox) Send(from, to, body string) (*Message, error) {
+	body = strings.TrimSpace(body)
+
+	if len(body) == 0 {
+		return nil, ErrEmpty
+	}
+
+	if b.MaxLength > 0 && len(body) > b.MaxLength {
+		return nil, ErrTooLong
+	}
+
+	m := Message{
+		From: from,
+		To:   to,
+		Body: body,
+		Time: time.Now(),
+	}
+
+	err := b.db.Update(func(tx *badger.Txn) error {
+		var count int
+
+		err := b.each(tx, to, func(e Message) error {
+			count++
+			if e.ID > m.ID {
+				m.ID = e.ID
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if b.MaxMessages > 0 && count >= b.MaxMessages {
+			return ErrInboxFull
+		}
+
+		m.ID++
+
+		return b.set(tx, m)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Read returns every message in the user's inbox, oldest first, marking
+// them as read
+func (b *BadgerInbox) Read(user string) ([]Message, error) {
+	var messages []Message
+
+	err := b.db.Update(func(tx *badger.Txn) error {
+		messages = nil
+
+		err := b.each(tx, user, func(m Message) error {
+			messages = append(messages, m)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, m := range messages {
+			if m.Read {
+				continue
+			}
+
+			m.Read = true
+
+			if err := b.set(tx, m); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Unread returns how many messages in the user's inbox haven't been read
+func (b *BadgerInbox) Unread(user string) (int, error) {
+	var count int
+
+	err := b.db.View(func(tx *badger.Txn) error {
+		return b.each(tx, user, func(m Message) error {
+			if !m.Read {
+				count++
+			}
+			return nil
+		})
+	})
+
+	return count, err
+}
+
+// Delete removes a message from the user's inbox
+func (b *BadgerInbox) Delete(user string, id uint64) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		key := b.key(user, id)
+
+		if _, err := tx.Get(key); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return ErrNoMessage
+			}
+			return err
+		}
+
+		return tx.Delete(key)
+	})
+}
+
+// Clear removes every message from the user's inbox, returning how many
+// were removed
+func (b *BadgerInbox) Clear(user string) (int, error) {
+	return b.remove(user, func(Message) bool { return true })
+}
+
+// Expire removes every message older than the retention, returning how
+// many were removed
+func (b *BadgerInbox) Expire(now time.Time) (int, error) {
+	if b.Retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := now.AddDate(0, 0, -b.Retention)
+
+	return b.remove("", func(m Message) bool {
+		return m.Time.Before(cutoff)
+	})
+}
+
+// remove deletes the messages in the user's inbox that match, an empty user
+// matches every inbox
+func (b *BadgerInbox) remove(user string, match func(Message) bool) (int, error) {
+	var keys [][]byte
+
+	err := b.db.View(func(tx *badger.Txn) error {
+		return b.each(tx, user, func(m Message) error {
+			if match(m) {
+				keys = append(keys, b.key(m.To, m.ID))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Close closes the underlying badger store
+func (b *BadgerInbox) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,104 @@
+package inbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendReadDelete(t *testing.T) {
+	b := newMemoryInbox(t, &InboxOpts{MaxLength: 10, MaxMessages: 2})
+	defer closeMemoryInbox(t, b)
+
+	_, err := b.Send("alice", "bob", "  ")
+	checkErr(t, err, ErrEmpty)
+
+	_, err = b.Send("alice", "bob", "far too long to send")
+	checkErr(t, err, ErrTooLong)
+
+	first, err := b.Send("alice", "bob", "hello")
+	checkErr(t, err, nil)
+
+	second, err := b.Send("carol", "BOB", "hi there")
+	checkErr(t, err, nil)
+
+	if first.ID != 1 || second.ID != 2 {
+		t.Fatalf("expected ids 1 and 2 got %d and %d", first.ID, second.ID)
+	}
+
+	_, err = b.Send("alice", "bob", "again")
+	checkErr(t, err, ErrInboxFull)
+
+	unread, err := b.Unread("bob")
+	checkErr(t, err, nil)
+
+	if unread != 2 {
+		t.Fatalf("expected 2 unread got %d", unread)
+	}
+
+	messages, err := b.Read("bob")
+	checkErr(t, err, nil)
+
+	if len(messages) != 2 || messages[0].From != "alice" || messages[1].From != "carol" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	unread, err = b.Unread("bob")
+	checkErr(t, err, nil)
+
+	if unread != 0 {
+		t.Fatalf("expected 0 unread got %d", unread)
+	}
+
+	checkErr(t, b.Delete("bob", 3), ErrNoMessage)
+	checkErr(t, b.Delete("bob", first.ID), nil)
+
+	// the deleted message frees up room
+	_, err = b.Send("alice", "bob", "again")
+	checkErr(t, err, nil)
+
+	n, err := b.Clear("bob")
+	checkErr(t, err, nil)
+
+	if n != 2 {
+		t.Fatalf("expected 2 cleared got %d", n)
+	}
+
+	messages, err = b.Read("bob")
+	checkErr(t, err, nil)
+
+	if len(messages) != 0 {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	b := newMemoryInbox(t, &InboxOpts{Retention: 7})
+	defer closeMemoryInbox(t, b)
+
+	_, err := b.Send("alice", "bob", "hello")
+	checkErr(t, err, nil)
+
+	_, err = b.Send("alice", "carol", "hello")
+	checkErr(t, err, nil)
+
+	n, err := b.Expire(time.Now())
+	checkErr(t, err, nil)
+
+	if n != 0 {
+		t.Fatalf("expected nothing to expire got %d", n)
+	}
+
+	n, err = b.Expire(time.Now().AddDate(0, 0, 8))
+	checkErr(t, err, nil)
+
+	if n != 2 {
+		t.Fatalf("expected 2 expired got %d", n)
+	}
+
+	unread, err := b.Unread("bob")
+	checkErr(t, err, nil)
+
+	if unread != 0 {
+		t.Fatalf("expected 0 unread got %d", unread)
+	}
+}
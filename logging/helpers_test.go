@@ -0,0 +1,22 @@
+package logging
+
+import "testing"
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("expected nil but got '%s'", got)
+	}
+
+	if got.Error() != expected.Error() {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
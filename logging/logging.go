@@ -0,0 +1,358 @@
+// Package logging provides the structured, leveled logger used throughout
+// the daemon. Loggers are handed to the parts of goftpd that log, anything
+// not given one logs to Stderr
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level is how important a line is, lines below the level of a Logger
+// aren't written
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string { return levelNames[l] }
+
+// ParseLevel returns the Level with the name, one of debug, info, warn or
+// error
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return l, nil
+		}
+	}
+
+	return LevelInfo, errors.Errorf("unknown log level '%s'", name)
+}
+
+// formats lines can be written in
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Field is a key and value added to a line
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field for anything without a helper below
+func F(key string, value interface{}) Field { return Field{key, value} }
+
+// Session is the id of the session a line is about
+func Session(id uint64) Field { return Field{"session", id} }
+
+func User(name string) Field { return Field{"user", name} }
+
+func IP(ip string) Field { return Field{"ip", ip} }
+
+// Command is the upper cased name of an FTP command
+func Command(name string) Field { return Field{"command", name} }
+
+func Path(path string) Field { return Field{"path", path} }
+
+func Bytes(n int64) Field { return Field{"bytes", n} }
+
+func Duration(d time.Duration) Field { return Field{"duration", d} }
+
+// Err is the error that caused the line, nil errors are skipped
+func Err(err error) Field { return Field{"error", err} }
+
+// Logger writes leveled lines made up of a message and Fields
+type Logger interface {
+	Debug(string, ...Field)
+	Info(string, ...Field)
+	Warn(string, ...Field)
+	Error(string, ...Field)
+	// With returns a Logger adding the fields to every line
+	With(...Field) Logger
+}
+
+type LogOpts struct {
+	// one of debug, info, warn or error, defaults to info
+	Level string `goftpd:"level"`
+	// one of console or json, defaults to console
+	Format string `goftpd:"format"`
+	// appended to, defaults to stderr
+	File string `goftpd:"file"`
+	// lines are also sent to syslog when set, either local or a url in the
+	// form of udp://<host>[:<port>], tcp://<host>[:<port>] or unix://<path>
+	Syslog string `goftpd:"syslog"`
+	// defaults to daemon
+	SyslogFacility string `goftpd:"syslog_facility"`
+	// the app name of messages, defaults to goftpd
+	SyslogTag string `goftpd:"syslog_tag"`
+}
+
+// Stderr is used by anything not given a Logger
+var Stderr Logger = NewWriterLogger(os.Stderr, LevelInfo, FormatConsole)
+
+// WriterLogger implements Logger writing to an io.Writer
+type WriterLogger struct {
+	level  Level
+	json   bool
+	fields []Field
+	out    *output
+}
+
+// output is shared between a WriterLogger and those returned by its With
+// so that lines aren't interleaved
+type output struct {
+	mtx sync.Mutex
+	w   io.Writer
+
+	// optional, every line is also sent to syslog
+	syslog *SyslogWriter
+}
+
+// NewWriterLogger returns a WriterLogger writing lines of at least level to
+// w in the format
+func NewWriterLogger(w io.Writer, level Level, format string) *WriterLogger {
+	return &WriterLogger{
+		level: level,
+		json:  format == FormatJSON,
+		out:   &output{w: w},
+	}
+}
+
+// NewLogger returns a WriterLogger configured by the options
+func NewLogger(opts *LogOpts) (*WriterLogger, error) {
+	level := LevelInfo
+
+	if len(opts.Level) > 0 {
+		var err error
+		if level, err = ParseLevel(opts.Level); err != nil {
+			return nil, err
+		}
+	}
+
+	format := strings.ToLower(opts.Format)
+
+	switch format {
+	case "":
+		format = FormatConsole
+	case FormatConsole, FormatJSON:
+	default:
+		return nil, errors.Errorf("unknown log format '%s'", opts.Format)
+	}
+
+	var w io.Writer = os.Stderr
+
+	var syslog *SyslogWriter
+
+	if len(opts.Syslog) > 0 {
+		var err error
+		if syslog, err = NewSyslogWriter(opts.Syslog, opts.SyslogFacility, opts.SyslogTag); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.File) > 0 {
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			if syslog != nil {
+				syslog.Close()
+			}
+			return nil, errors.WithMessagef(err, "opening log '%s'", opts.File)
+		}
+		w = f
+	}
+
+	l := NewWriterLogger(w, level, format)
+	l.out.syslog = syslog
+
+	return l, nil
+}
+
+func (l *WriterLogger) Debug(msg string, fields ...Field) { l.write(LevelDebug, msg, fields) }
+func (l *WriterLogger) Info(msg string, fields ...Field)  { l.write(LevelInfo, msg, fields) }
+func (l *WriterLogger) Warn(msg string, fields ...Field)  { l.write(LevelWarn, msg, fields) }
+func (l *WriterLogger) Error(msg string, fields ...Field) { l.write(LevelError, msg, fields) }
+
+func (l *WriterLogger) With(fields ...Field) Logger {
+	w := *l
+	w.fields = append(append(make([]Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	return &w
+}
+
+// Close closes the file being logged to and the connection to syslog, if
+// there are either
+func (l *WriterLogger) Close() error {
+	if l.out.syslog != nil {
+		l.out.syslog.Close()
+	}
+
+	if f, ok := l.out.w.(*os.File); ok && f != os.Stderr && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+func (l *WriterLogger) write(level Level, msg string, fields []Field) {
+	if level < l.level {
+		return
+	}
+
+	var b bytes.Buffer
+
+	now := time.Now()
+
+	if l.json {
+		writeJSON(&b, now, level, msg, l.fields, fields)
+	} else {
+		writeConsole(&b, now, level, msg, l.fields, fields)
+	}
+
+	l.out.mtx.Lock()
+	defer l.out.mtx.Unlock()
+
+	l.out.w.Write(b.Bytes())
+
+	if l.out.syslog == nil {
+		return
+	}
+
+	// syslog has its own time and severity
+	if !l.json {
+		b.Reset()
+		writeConsoleFields(&b, msg, l.fields, fields)
+	}
+
+	// there is nowhere to report a failure to
+	l.out.syslog.Write(level, now, b.Bytes())
+}
+
+// writeConsole writes the line as `<time> <LEVEL> <msg> key=value...`
+func writeConsole(b *bytes.Buffer, t time.Time, level Level, msg string, sets ...[]Field) {
+	fmt.Fprintf(b, "%s %-5s ", t.Format(time.RFC3339), strings.ToUpper(level.String()))
+
+	writeConsoleFields(b, msg, sets...)
+}
+
+// writeConsoleFields writes `<msg> key=value...`
+func writeConsoleFields(b *bytes.Buffer, msg string, sets ...[]Field) {
+	b.WriteString(msg)
+
+	for _, fields := range sets {
+		for _, f := range fields {
+			v, ok := value(f.Value)
+			if !ok {
+				continue
+			}
+
+			s := fmt.Sprint(v)
+			if len(s) == 0 || strings.ContainsAny(s, " \t\r\n\"=") {
+				s = strconv.Quote(s)
+			}
+
+			fmt.Fprintf(b, " %s=%s", f.Key, s)
+		}
+	}
+
+	b.WriteByte('\n')
+}
+
+// writeJSON writes the line as a JSON object with time, level and msg keys
+// followed by the fields in order
+func writeJSON(b *bytes.Buffer, t time.Time, level Level, msg string, sets ...[]Field) {
+	writeKey := func(key string, v interface{}) {
+		k, _ := json.Marshal(key)
+
+		val, err := json.Marshal(v)
+		if err != nil {
+			val, _ = json.Marshal(fmt.Sprint(v))
+		}
+
+		b.WriteByte(',')
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+
+	ts, _ := json.Marshal(t.Format(time.RFC3339Nano))
+
+	b.WriteString(`{"time":`)
+	b.Write(ts)
+
+	writeKey("level", level.String())
+	writeKey("msg", msg)
+
+	for _, fields := range sets {
+		for _, f := range fields {
+			if v, ok := value(f.Value); ok {
+				writeKey(f.Key, v)
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+}
+
+// value returns how a Field's value is written, false if it should be
+// skipped
+func value(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case nil:
+		return nil, false
+	case error:
+		return t.Error(), true
+	case time.Duration:
+		return t.String(), true
+	case fmt.Stringer:
+		return t.String(), true
+	}
+
+	return v, true
+}
+
+// BadgerLogger passes what badger logs on to a Logger. Badger's info lines
+// are written at debug as it is chatty when opening and closing stores
+type BadgerLogger struct {
+	Logger
+}
+
+// Badger returns a logger to set as the Logger in badger's options
+func Badger(l Logger) BadgerLogger {
+	return BadgerLogger{l.With(F("component", "badger"))}
+}
+
+func (l BadgerLogger) Errorf(f string, v ...interface{}) {
+	l.Error(strings.TrimSpace(fmt.Sprintf(f, v...)))
+}
+
+func (l BadgerLogger) Warningf(f string, v ...interface{}) {
+	l.Warn(strings.TrimSpace(fmt.Sprintf(f, v...)))
+}
+
+func (l BadgerLogger) Infof(f string, v ...interface{}) {
+	l.Debug(strings.TrimSpace(fmt.Sprintf(f, v...)))
+}
+
+func (l BadgerLogger) Debugf(f string, v ...interface{}) {
+	l.Debug(strings.TrimSpace(fmt.Sprintf(f, v...)))
+}
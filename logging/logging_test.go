@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	checkErr(t, err, nil)
+
+	if level != LevelWarn {
+		t.Fatalf("expected warn got %s", level)
+	}
+
+	_, err = ParseLevel("loud")
+	checkErr(t, err, errors.New("unknown log level 'loud'"))
+}
+
+func TestWriterLoggerConsole(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewWriterLogger(&b, LevelInfo, FormatConsole)
+
+	l.Debug("not written")
+
+	l.With(Session(3), User("jawr")).Warn("transfer failed",
+		Path("/mp3/some file.mp3"),
+		Bytes(1024),
+		Duration(1500*time.Millisecond),
+		Err(errors.New("broken pipe")),
+		Err(nil),
+	)
+
+	line := b.String()
+
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("expected one line got '%s'", line)
+	}
+
+	expected := ` WARN  transfer failed session=3 user=jawr path="/mp3/some file.mp3" bytes=1024 duration=1.5s error="broken pipe"` + "\n"
+
+	if !strings.HasSuffix(line, expected) {
+		t.Fatalf("expected '%s' got '%s'", expected, line)
+	}
+}
+
+func TestWriterLoggerJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewWriterLogger(&b, LevelDebug, FormatJSON)
+
+	l.With(IP("10.0.0.1")).Debug("command", Command("STOR"), Bytes(10))
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("unexpected error: %s: %s", err, b.String())
+	}
+
+	expected := map[string]interface{}{
+		"level":   "debug",
+		"msg":     "command",
+		"ip":      "10.0.0.1",
+		"command": "STOR",
+		"bytes":   float64(10),
+	}
+
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("expected %s to be '%v' got '%v'", k, v, got[k])
+		}
+	}
+
+	if _, ok := got["time"]; !ok {
+		t.Errorf("expected a time")
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	_, err := NewLogger(&LogOpts{Format: "xml"})
+	checkErr(t, err, errors.New("unknown log format 'xml'"))
+
+	_, err = NewLogger(&LogOpts{Level: "loud"})
+	checkErr(t, err, errors.New("unknown log level 'loud'"))
+
+	l, err := NewLogger(&LogOpts{Level: "error", Format: "json"})
+	checkErr(t, err, nil)
+
+	if l.level != LevelError || !l.json {
+		t.Fatalf("unexpected logger: %+v", l)
+	}
+}
@@ -0,0 +1,250 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SyslogLocal is the destination of the local syslog daemon
+const SyslogLocal = "local"
+
+// defaults used when options are empty
+const (
+	defaultSyslogFacility = "daemon"
+	defaultSyslogTag      = "goftpd"
+	defaultSyslogPort     = "514"
+)
+
+// syslogTimeout is how long connecting or sending a message can take
+const syslogTimeout = 5 * time.Second
+
+// localSyslogPaths are where the local syslog daemon listens on different
+// systems
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// facilities by name, as numbered in RFC 5424
+var facilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// severities of each Level, as numbered in RFC 5424
+var severities = map[Level]int{
+	LevelDebug: 7,
+	LevelInfo:  6,
+	LevelWarn:  4,
+	LevelError: 3,
+}
+
+// ParseFacility returns the number of the named syslog facility, i.e.
+// daemon or local0
+func ParseFacility(name string) (int, error) {
+	f, ok := facilities[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.Errorf("unknown syslog facility '%s'", name)
+	}
+	return f, nil
+}
+
+// SyslogWriter sends lines to a syslog daemon as RFC 5424 messages. Over
+// tcp messages are framed by octet counting as in RFC 6587, over udp and
+// unix sockets each message is sent on its own
+type SyslogWriter struct {
+	network string
+	addr    string
+	// the tcp framing is used
+	stream bool
+
+	facility int
+	tag      string
+	hostname string
+	pid      int
+
+	mtx  sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogWriter connects to the destination, local for the local syslog
+// daemon or a url in the form of udp://<host>[:<port>],
+// tcp://<host>[:<port>] or unix://<path>. Messages are sent with the
+// facility and tag
+func NewSyslogWriter(dest, facility, tag string) (*SyslogWriter, error) {
+	if len(facility) == 0 {
+		facility = defaultSyslogFacility
+	}
+
+	f, err := ParseFacility(facility)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tag) == 0 {
+		tag = defaultSyslogTag
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		hostname = "-"
+	}
+
+	w := SyslogWriter{
+		facility: f,
+		tag:      tag,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}
+
+	if dest != SyslogLocal {
+		u, err := url.Parse(dest)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "bad syslog destination '%s'", dest)
+		}
+
+		switch u.Scheme {
+		case "udp", "tcp":
+			w.network, w.addr = u.Scheme, u.Host
+
+			if len(u.Port()) == 0 {
+				w.addr = net.JoinHostPort(u.Hostname(), defaultSyslogPort)
+			}
+
+			w.stream = u.Scheme == "tcp"
+
+		case "unix":
+			w.network, w.addr = "unixgram", u.Path
+
+		default:
+			return nil, errors.Errorf("bad syslog destination '%s', expected local, udp://, tcp:// or unix://", dest)
+		}
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, errors.WithMessagef(err, "connecting to syslog '%s'", dest)
+	}
+
+	return &w, nil
+}
+
+// connect dials the destination, the local daemon is tried at each of its
+// usual paths
+func (w *SyslogWriter) connect() error {
+	if len(w.network) > 0 {
+		conn, err := net.DialTimeout(w.network, w.addr, syslogTimeout)
+		if err != nil {
+			return err
+		}
+
+		w.conn = conn
+
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range localSyslogPaths {
+			conn, err := net.DialTimeout(network, path, syslogTimeout)
+			if err == nil {
+				w.network, w.addr, w.conn = network, path, conn
+				return nil
+			}
+		}
+	}
+
+	return errors.New("no local syslog daemon found")
+}
+
+// Write sends the message at the severity of the Level. A failed send is
+// retried once on a new connection
+func (w *SyslogWriter) Write(level Level, t time.Time, msg []byte) error {
+	b := w.format(level, t, msg)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.conn != nil {
+		if err := w.send(b); err == nil {
+			return nil
+		}
+
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return err
+	}
+
+	return w.send(b)
+}
+
+func (w *SyslogWriter) send(b []byte) error {
+	w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+
+	if w.stream {
+		if _, err := fmt.Fprintf(w.conn, "%d ", len(b)); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.conn.Write(b)
+
+	return err
+}
+
+// format returns the RFC 5424 message, there is no structured data and the
+// message id is left out
+func (w *SyslogWriter) format(level Level, t time.Time, msg []byte) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - - ",
+		w.facility*8+severities[level],
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		w.hostname,
+		w.tag,
+		w.pid,
+	)
+
+	b.Write(bytes.TrimRight(msg, "\n"))
+
+	return b.Bytes()
+}
+
+// Close closes the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseFacility(t *testing.T) {
+	f, err := ParseFacility("LOCAL3")
+	checkErr(t, err, nil)
+
+	if f != 19 {
+		t.Fatalf("expected 19 got %d", f)
+	}
+
+	_, err = ParseFacility("nope")
+	checkErr(t, err, errors.New("unknown syslog facility 'nope'"))
+}
+
+func TestNewSyslogWriterBadDestination(t *testing.T) {
+	_, err := NewSyslogWriter("http://localhost", "", "")
+	checkErr(t, err, errors.New("bad syslog destination 'http://localhost', expected local, udp://, tcp:// or unix://"))
+
+	_, err = NewSyslogWriter("udp://localhost:514", "nope", "")
+	checkErr(t, err, errors.New("unknown syslog facility 'nope'"))
+}
+
+// header matches the start of an RFC 5424 message without structured data
+var header = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ (\S+) \d+ - - `)
+
+func checkMessage(t *testing.T, msg string, pri int, tag, expected string) {
+	t.Helper()
+
+	m := header.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("expected an RFC 5424 message got '%s'", msg)
+	}
+
+	if m[1] != strconv.Itoa(pri) || m[2] != tag {
+		t.Fatalf("expected priority %d and tag %s got '%s'", pri, tag, msg)
+	}
+
+	if got := msg[len(m[0]):]; got != expected {
+		t.Fatalf("expected message '%s' got '%s'", expected, got)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	checkErr(t, err, nil)
+	defer pc.Close()
+
+	l, err := NewLogger(&LogOpts{
+		Level:          "debug",
+		Syslog:         "udp://" + pc.LocalAddr().String(),
+		SyslogFacility: "local0",
+		SyslogTag:      "testftpd",
+		File:           t.TempDir() + "/goftpd.log",
+	})
+	checkErr(t, err, nil)
+	defer l.Close()
+
+	l.With(User("jawr")).Warn("upload failed", Path("/mp3/some file.mp3"))
+	l.Debug("debugging")
+
+	buf := make([]byte, 2048)
+
+	for _, tt := range []struct {
+		pri int
+		msg string
+	}{
+		// local0 is 16, warning 4 and debug 7
+		{16*8 + 4, `upload failed user=jawr path="/mp3/some file.mp3"`},
+		{16*8 + 7, `debugging`},
+	} {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		n, _, err := pc.ReadFrom(buf)
+		checkErr(t, err, nil)
+
+		checkMessage(t, string(buf[:n]), tt.pri, "testftpd", tt.msg)
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	checkErr(t, err, nil)
+	defer ln.Close()
+
+	messages := make(chan string, 2)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		// octet counting, `<length> <message>`
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				return
+			}
+
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+
+			messages <- string(b)
+		}
+	}()
+
+	l, err := NewLogger(&LogOpts{
+		Format: FormatJSON,
+		Syslog: "tcp://" + ln.Addr().String(),
+		File:   t.TempDir() + "/goftpd.log",
+	})
+	checkErr(t, err, nil)
+	defer l.Close()
+
+	l.Error("first", Err(errors.New("broken")))
+	l.Info("second")
+
+	for i, expected := range []string{`"msg":"first","error":"broken"}`, `"msg":"second"}`} {
+		select {
+		case msg := <-messages:
+			// daemon is 3, error 3 and info 6
+			pri := []int{3*8 + 3, 3*8 + 6}[i]
+
+			m := header.FindStringSubmatch(msg)
+			if m == nil || m[1] != strconv.Itoa(pri) || m[2] != "goftpd" {
+				t.Fatalf("unexpected header '%s'", msg)
+			}
+
+			if !strings.HasSuffix(msg, expected) {
+				t.Fatalf("expected json message ending '%s' got '%s'", expected, msg)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+}
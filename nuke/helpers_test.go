@@ -0,0 +1,45 @@
+package nuke
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryNukes(t *testing.T, opts *NukeOpts) *BadgerNukes {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	return NewBadgerNukes(opts, db)
+}
+
+func closeMemoryNukes(t *testing.T, b *BadgerNukes) {
+	t.Helper()
+	if err := b.Close(); err != nil {
+		t.Fatalf("error closing nukes: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
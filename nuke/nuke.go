@@ -0,0 +1,279 @@
+// Package nuke provides a persistent record of nuked directories, who
+// uploaded to them and how many credits they lost so that nukes can be
+// listed and reversed
+package nuke
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "nuke:"
+
+// DefaultPrefix is prepended to the name of nuked directories when no
+// prefix is configured
+const DefaultPrefix = "NUKED-"
+
+var (
+	ErrAlreadyNuked   = errors.New("already nuked")
+	ErrBadMultiplier  = errors.New("multiplier out of range")
+	ErrReasonRequired = errors.New("reason required")
+	ErrNotNuked       = errors.New("not nuked")
+)
+
+// Nukee is a user who uploaded to a nuked directory
+type Nukee struct {
+	User  string
+	Group string
+	Bytes int64
+	Files int64
+	// credits taken from the user, given back on unnuke
+	Credits int64
+}
+
+// Record is a nuked directory
+type Record struct {
+	// where the directory was before it was nuked
+	Path string
+	// where the directory was renamed to
+	NukedPath  string
+	Reason     string
+	Multiplier int
+	Nuker      string
+	Time       time.Time
+	Nukees     []Nukee
+}
+
+// Bytes returns the total uploaded to the directory
+func (r Record) Bytes() int64 {
+	var n int64
+	for _, e := range r.Nukees {
+		n += e.Bytes
+	}
+	return n
+}
+
+// Nukes records nuked directories
+type Nukes interface {
+	NukedPath(string) string
+	Add(Record) error
+	Get(string) (*Record, error)
+	Latest(int) ([]Record, error)
+	Remove(string) error
+	Close() error
+}
+
+type NukeOpts struct {
+	DB string `goftpd:"db"`
+	// prepended to the name of nuked directories
+	Prefix string `goftpd:"prefix"`
+	// the highest multiplier a directory can be nuked with
+	MaxMultiplier int `goftpd:"max_multiplier"`
+}
+
+// BadgerNukes implements Nukes using a badger key/value store
+type BadgerNukes struct {
+	*NukeOpts
+	db *badger.DB
+}
+
+// NewBadgerNukes takes in options and a badger DB
+func NewBadgerNukes(opts *NukeOpts, db *badger.DB) *BadgerNukes {
+	if len(opts.Prefix) == 0 {
+		opts.Prefix = DefaultPrefix
+	}
+
+	return &BadgerNukes{
+		NukeOpts: opts,
+		db:       db,
+	}
+}
+
+func (n *BadgerNukes) key(path string) []byte {
+	return []byte(keyPrefix + strings.ToLower(filepath.Clean(path)))
+}
+
+// NukedPath returns where the directory at path is renamed to when nuked
+func (n *BadgerNukes) NukedPath(path string) string {
+	path = filepath.Clean(path)
+	return filepath.Join(filepath.Dir(path), n.Prefix+filepath.Base(path))
+}
+
+// originalPath returns where a nuked directory was before it was nuked,
+// false if the path doesn't have the prefix
+func (n *BadgerNukes) originalPath(path string) (string, bool) {
+	path = filepath.Clean(path)
+
+	base := filepath.Base(path)
+
+	if len(base) <= len(n.Prefix) || !strings.EqualFold(base[:len(n.Prefix)], n.Prefix) {
+		return "", false
+	}
+
+	return filepath.Join(filepath.Dir(path), base[len(n.Prefix):]), true
+}
+
+// Add stores the Record keyed by the directory's original path. Fails if
+// the directory is already nuked, there is no reason given or the
+// multiplier is out of range
+func (n *BadgerNukes) Add(r Record) error {
+	if r.Multiplier < 1 || (n.MaxMultiplier > 0 && r.Multiplier > n.MaxMultiplier) {
+		return ErrBadMultiplier
+	}
+
+	if len(strings.TrimSpace(r.Reason)) == 0 {
+		return ErrReasonRequired
+	}
+
+	if _, ok := n.originalPath(r.Path); ok {
+		return ErrAlreadyNuked
+	}
+
+	r.Path = filepath.Clean(r.Path)
+
+	if len(r.NukedPath) == 0 {
+		r.NukedPath = n.NukedPath(r.Path)
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(r); err != nil {
+		return err
+	}
+
+	return n.db.Update(func(tx *badger.Txn) error {
+		key := n.key(r.Path)
+
+		if _, err := tx.Get(key); err == nil {
+			return ErrAlreadyNuked
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		return tx.Set(key, b.Bytes())
+	})
+}
+
+// Get returns the Record of the directory, either by where it was before it
+// was nuked or where it was renamed to. Returns nil if it isn't nuked
+func (n *BadgerNukes) Get(path string) (*Record, error) {
+	paths := []string{path}
+
+	if original, ok := n.originalPath(path); ok {
+		paths = append([]string{original}, paths...)
+	}
+
+	for _, p := range paths {
+		r, err := n.get(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if r != nil {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// get returns the Record stored for the path, nil if there isn't one
+func (n *BadgerNukes) get(path string) (*Record, error) {
+	var r Record
+
+	err := n.db.View(func(tx *badger.Txn) error {
+		item, err := tx.Get(n.key(path))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &r)
+		})
+	})
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Latest returns up to limit of the most recent nukes, newest first
+func (n *BadgerNukes) Latest(limit int) ([]Record, error) {
+	var records []Record
+
+	err := n.db.View(func(tx *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(keyPrefix)
+
+		it := tx.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var r Record
+
+			err := it.Item().Value(func(val []byte) error {
+				return msgpack.Unmarshal(val, &r)
+			})
+			if err != nil {
+				return err
+			}
+
+			records = append(records, r)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
+
+// Remove deletes the Record of the directory by its original path, i.e.
+// when it is unnuked
+func (n *BadgerNukes) Remove(path string) error {
+	return n.db.Update(func(tx *badger.Txn) error {
+		key := n.key(path)
+
+		if _, err := tx.Get(key); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return ErrNotNuked
+			}
+			return err
+		}
+
+		return tx.Delete(key)
+	})
+}
+
+// Close closes the underlying badger store
+func (n *BadgerNukes) Close() error {
+	return n.db.Close()
+}
@@ -0,0 +1,91 @@
+package nuke
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddGetRemove(t *testing.T) {
+	n := newMemoryNukes(t, &NukeOpts{MaxMultiplier: 10})
+	defer closeMemoryNukes(t, n)
+
+	if p := n.NukedPath("/mp3/Artist-Album-2020-GRP"); p != "/mp3/NUKED-Artist-Album-2020-GRP" {
+		t.Fatalf("unexpected nuked path '%s'", p)
+	}
+
+	r := Record{
+		Path:       "/mp3/Artist-Album-2020-GRP",
+		Reason:     "bad.rip",
+		Multiplier: 3,
+		Nuker:      "admin",
+		Nukees: []Nukee{
+			{User: "alice", Group: "group", Bytes: 100, Files: 2, Credits: 300},
+			{User: "bob", Group: "other", Bytes: 50, Files: 1, Credits: 150},
+		},
+	}
+
+	bad := r
+	bad.Multiplier = 11
+	checkErr(t, n.Add(bad), ErrBadMultiplier)
+
+	bad.Multiplier = 0
+	checkErr(t, n.Add(bad), ErrBadMultiplier)
+
+	bad.Multiplier = -1
+	checkErr(t, n.Add(bad), ErrBadMultiplier)
+
+	bad = r
+	bad.Reason = " "
+	checkErr(t, n.Add(bad), ErrReasonRequired)
+
+	checkErr(t, n.Add(r), nil)
+	checkErr(t, n.Add(r), ErrAlreadyNuked)
+
+	bad = r
+	bad.Path = "/mp3/NUKED-Artist-Album-2020-GRP"
+	checkErr(t, n.Add(bad), ErrAlreadyNuked)
+
+	for _, p := range []string{"/mp3/artist-album-2020-grp", "/mp3/NUKED-Artist-Album-2020-GRP"} {
+		got, err := n.Get(p)
+		checkErr(t, err, nil)
+
+		if got == nil || got.Path != r.Path || got.NukedPath != "/mp3/NUKED-Artist-Album-2020-GRP" || len(got.Nukees) != 2 || got.Bytes() != 150 {
+			t.Errorf("unexpected record for '%s': %+v", p, got)
+		}
+	}
+
+	got, err := n.Get("/mp3/Other")
+	checkErr(t, err, nil)
+
+	if got != nil {
+		t.Errorf("unexpected record %+v", got)
+	}
+
+	checkErr(t, n.Remove(r.Path), nil)
+	checkErr(t, n.Remove(r.Path), ErrNotNuked)
+
+	got, err = n.Get(r.Path)
+	checkErr(t, err, nil)
+
+	if got != nil {
+		t.Errorf("unexpected record %+v", got)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	n := newMemoryNukes(t, &NukeOpts{})
+	defer closeMemoryNukes(t, n)
+
+	now := time.Now()
+
+	for i, p := range []string{"/mp3/first", "/mp3/second", "/mp3/third"} {
+		checkErr(t, n.Add(Record{Path: p, Reason: "dupe", Multiplier: 1, Time: now.Add(time.Duration(i) * time.Minute)}), nil)
+	}
+
+	records, err := n.Latest(2)
+	checkErr(t, err, nil)
+
+	if len(records) != 2 || records[0].Path != "/mp3/third" || records[1].Path != "/mp3/second" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
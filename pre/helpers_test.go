@@ -0,0 +1,63 @@
+package pre
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+func newMemoryPre(t *testing.T, areas []string, sections []string) *BadgerPre {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	var as []Area
+	for _, l := range areas {
+		a, err := NewArea(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewArea: %s", err)
+		}
+		as = append(as, a)
+	}
+
+	var ss []Section
+	for _, l := range sections {
+		s, err := NewSection(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewSection: %s", err)
+		}
+		ss = append(ss, s)
+	}
+
+	return NewBadgerPre(&PreOpts{}, db, as, ss)
+}
+
+func closeMemoryPre(t *testing.T, p *BadgerPre) {
+	t.Helper()
+	if err := p.Close(); err != nil {
+		t.Fatalf("error closing pre: %s", err)
+	}
+}
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+			return
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("unexpected error '%s'", got)
+		return
+	}
+}
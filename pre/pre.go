@@ -0,0 +1,254 @@
+// Package pre provides group pre areas, the sections releases are pred in
+// to and a persistent record of when each release was pred
+package pre
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "pre:"
+
+var (
+	ErrAlreadyPred = errors.New("release has already been pred")
+	ErrNoArea      = errors.New("not in a pre area of any of your groups")
+	ErrNoSection   = errors.New("no such pre section")
+)
+
+// Release is a pred release
+type Release struct {
+	Name    string
+	Section string
+	// where the release was moved to
+	Path  string
+	User  string
+	Group string
+	Files int64
+	Bytes int64
+	Time  time.Time
+}
+
+// Area is the directory a group prepares releases in before they are pred
+type Area struct {
+	group string
+	path  string
+}
+
+// NewArea takes a line of text (i.e. from a config file) in the form of
+// `<group> <path>`
+func NewArea(line string) (Area, error) {
+	var area Area
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return area, errors.New("area requires 2 fields")
+	}
+
+	area.group = fields[0]
+	area.path = filepath.Clean("/" + fields[1])
+
+	if area.path == "/" {
+		return area, errors.New("area can not be the root")
+	}
+
+	return area, nil
+}
+
+// Section is where pred releases are moved to
+type Section struct {
+	name string
+	path string
+}
+
+// NewSection takes a line of text (i.e. from a config file) in the form of
+// `<name> <path>`
+func NewSection(line string) (Section, error) {
+	var section Section
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return section, errors.New("section requires 2 fields")
+	}
+
+	section.name = strings.ToLower(fields[0])
+	section.path = filepath.Clean("/" + fields[1])
+
+	return section, nil
+}
+
+// Pre knows where releases are pred from and to and records them
+type Pre interface {
+	Group(string, []string) (string, error)
+	Destination(string, string) (string, string, error)
+	Sections() []string
+	Record(Release) error
+	Get(string) (*Release, error)
+	Subscribe(func(Release))
+	Close() error
+}
+
+type PreOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// BadgerPre implements Pre using a badger key/value store
+type BadgerPre struct {
+	*PreOpts
+	db       *badger.DB
+	areas    []Area
+	sections []Section
+
+	mtx         sync.RWMutex
+	subscribers []func(Release)
+}
+
+// NewBadgerPre takes in options, a badger DB, the group pre areas and the
+// sections releases can be pred in to
+func NewBadgerPre(opts *PreOpts, db *badger.DB, areas []Area, sections []Section) *BadgerPre {
+	return &BadgerPre{
+		PreOpts:  opts,
+		db:       db,
+		areas:    areas,
+		sections: sections,
+	}
+}
+
+func (p *BadgerPre) key(name string) []byte {
+	return []byte(keyPrefix + strings.ToLower(name))
+}
+
+// Group returns which of the groups owns the pre area the release at path
+// is in. Releases have to be directly inside the area
+func (p *BadgerPre) Group(path string, groups []string) (string, error) {
+	dir := filepath.Dir(filepath.Clean(path))
+
+	for _, a := range p.areas {
+		if !strings.EqualFold(a.path, dir) {
+			continue
+		}
+
+		for _, g := range groups {
+			if strings.EqualFold(g, a.group) {
+				return a.group, nil
+			}
+		}
+	}
+
+	return "", ErrNoArea
+}
+
+// Destination returns the name of the section and where the release at
+// path is moved to when pred in to it
+func (p *BadgerPre) Destination(path, section string) (string, string, error) {
+	section = strings.ToLower(section)
+
+	for _, s := range p.sections {
+		if s.name == section {
+			return s.name, filepath.Join(s.path, filepath.Base(path)), nil
+		}
+	}
+
+	return "", "", ErrNoSection
+}
+
+// Sections returns the name of every section
+func (p *BadgerPre) Sections() []string {
+	names := make([]string, 0, len(p.sections))
+	for _, s := range p.sections {
+		names = append(names, s.name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Record stores the pre time of the release and announces it to the
+// subscribers. Fails if a release with the same name has been pred
+func (p *BadgerPre) Record(r Release) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(r); err != nil {
+		return err
+	}
+
+	err := p.db.Update(func(tx *badger.Txn) error {
+		key := p.key(r.Name)
+
+		if _, err := tx.Get(key); err == nil {
+			return ErrAlreadyPred
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		return tx.Set(key, b.Bytes())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, fn := range p.subscribers {
+		fn(r)
+	}
+
+	return nil
+}
+
+// Get returns the Release with the name, nil if it hasn't been pred
+func (p *BadgerPre) Get(name string) (*Release, error) {
+	var r Release
+
+	err := p.db.View(func(tx *badger.Txn) error {
+		item, err := tx.Get(p.key(name))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, &r)
+		})
+	})
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Subscribe adds fn to be called for every Release recorded, i.e. to
+// announce it. Subscribers are called on the goroutine recording the
+// release, anything slow should hand it off to its own goroutine
+func (p *BadgerPre) Subscribe(fn func(Release)) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.subscribers = append(p.subscribers, fn)
+}
+
+// Close closes the underlying badger store
+func (p *BadgerPre) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,91 @@
+package pre
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewArea(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"grp /pre/grp", nil},
+		{"grp", errors.New("area requires 2 fields")},
+		{"grp /", errors.New("area can not be the root")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewArea(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestGroupDestination(t *testing.T) {
+	p := newMemoryPre(t, []string{"GRP /pre/grp", "other /pre/other"}, []string{"mp3 /mp3", "0day /0day"})
+	defer closeMemoryPre(t, p)
+
+	group, err := p.Group("/pre/grp/Artist-Album-2020-GRP", []string{"other", "grp"})
+	checkErr(t, err, nil)
+
+	if group != "GRP" {
+		t.Errorf("expected GRP got '%s'", group)
+	}
+
+	_, err = p.Group("/pre/grp/Artist-Album-2020-GRP/CD1", []string{"grp"})
+	checkErr(t, err, ErrNoArea)
+
+	_, err = p.Group("/pre/other/Artist-Album-2020-GRP", []string{"grp"})
+	checkErr(t, err, ErrNoArea)
+
+	section, dest, err := p.Destination("/pre/grp/Artist-Album-2020-GRP", "MP3")
+	checkErr(t, err, nil)
+
+	if section != "mp3" || dest != "/mp3/Artist-Album-2020-GRP" {
+		t.Errorf("unexpected destination %s %s", section, dest)
+	}
+
+	_, _, err = p.Destination("/pre/grp/Artist-Album-2020-GRP", "flac")
+	checkErr(t, err, ErrNoSection)
+
+	if sections := p.Sections(); len(sections) != 2 || sections[0] != "0day" || sections[1] != "mp3" {
+		t.Errorf("unexpected sections %v", sections)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	p := newMemoryPre(t, nil, nil)
+	defer closeMemoryPre(t, p)
+
+	var announced []Release
+	p.Subscribe(func(r Release) { announced = append(announced, r) })
+
+	r := Release{Name: "Artist-Album-2020-GRP", Section: "mp3", Path: "/mp3/Artist-Album-2020-GRP", User: "user", Group: "GRP"}
+
+	checkErr(t, p.Record(r), nil)
+	checkErr(t, p.Record(r), ErrAlreadyPred)
+
+	if len(announced) != 1 || announced[0].Name != r.Name || announced[0].Time.IsZero() {
+		t.Fatalf("unexpected announcements: %+v", announced)
+	}
+
+	got, err := p.Get("artist-album-2020-grp")
+	checkErr(t, err, nil)
+
+	if got == nil || got.Path != r.Path || got.Group != "GRP" {
+		t.Errorf("unexpected release %+v", got)
+	}
+
+	got, err = p.Get("missing")
+	checkErr(t, err, nil)
+
+	if got != nil {
+		t.Errorf("unexpected release %+v", got)
+	}
+}
@@ -0,0 +1,196 @@
+// Package pretime looks up when a release was pred using an external API,
+// so releases uploaded long after their pre can be flagged
+package pretime
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+// defaults used when options are empty
+const (
+	defaultField   = "pretime"
+	defaultTimeout = 5
+)
+
+// maxResponse is the most of a response read
+const maxResponse = 1 << 20
+
+// Lookup finds the pre time of releases
+type Lookup interface {
+	// Lookup returns when the release was pred, the zero time if it isn't
+	// known
+	Lookup(string) (time.Time, error)
+	// Enabled checks to see if releases in the section should be looked up
+	Enabled(string) bool
+	// Old checks to see if a release pred at the time is older than allowed
+	Old(pretime, created time.Time) bool
+}
+
+type PretimeOpts struct {
+	// releases are only looked up when set, %[release] is replaced with
+	// the escaped name of the release, i.e.
+	// https://example.com/api/pre/%[release]
+	URL string `goftpd:"url"`
+	// the field of the JSON response holding the pre time as a unix time or
+	// RFC 3339 string, nested fields are separated with dots. defaults to
+	// pretime. a response that is only a number is used as is
+	Field string `goftpd:"field"`
+	// dirlog sections to look up releases in, defaults to every section
+	Sections []string `goftpd:"sections"`
+	// seconds after its pre a release is flagged as old, zero never flags
+	MaxAge int `goftpd:"max_age"`
+	// seconds to wait for the api, zero uses 5
+	Timeout int `goftpd:"timeout"`
+}
+
+// HTTPLookup implements Lookup using an HTTP API
+type HTTPLookup struct {
+	*PretimeOpts
+	client *http.Client
+}
+
+// NewHTTPLookup returns an HTTPLookup using the options. Fails if the url
+// isn't http or https
+func NewHTTPLookup(opts *PretimeOpts) (*HTTPLookup, error) {
+	if len(opts.URL) > 0 && !strings.HasPrefix(opts.URL, "http://") && !strings.HasPrefix(opts.URL, "https://") {
+		return nil, errors.Errorf("url must be http or https: '%s'", opts.URL)
+	}
+
+	if len(opts.Field) == 0 {
+		opts.Field = defaultField
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	l := HTTPLookup{
+		PretimeOpts: opts,
+		client: &http.Client{
+			Timeout: time.Duration(opts.Timeout) * time.Second,
+		},
+	}
+
+	return &l, nil
+}
+
+// Enabled checks to see if a url is set and the section is one looked up
+func (l *HTTPLookup) Enabled(section string) bool {
+	if len(l.URL) == 0 {
+		return false
+	}
+
+	if len(l.Sections) == 0 {
+		return true
+	}
+
+	for _, s := range l.Sections {
+		if strings.EqualFold(s, section) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Old checks to see if more than MaxAge passed between the pre and the
+// release being created
+func (l *HTTPLookup) Old(pretime, created time.Time) bool {
+	if l.MaxAge <= 0 || pretime.IsZero() {
+		return false
+	}
+
+	return created.Sub(pretime) > time.Duration(l.MaxAge)*time.Second
+}
+
+// Lookup queries the api for the release, a 404 is an unknown release
+func (l *HTTPLookup) Lookup(release string) (time.Time, error) {
+	u := cookie.Cookies{"release": url.PathEscape(release)}.Expand(l.URL)
+
+	resp, err := l.client.Get(u)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return time.Time{}, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return time.Time{}, errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return l.parse(io.LimitReader(resp.Body, maxResponse))
+}
+
+// parse reads the pre time from a response
+func (l *HTTPLookup) parse(r io.Reader) (time.Time, error) {
+	var v interface{}
+
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return time.Time{}, errors.WithMessage(err, "bad response")
+	}
+
+	for _, name := range strings.Split(l.Field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		if v, ok = m[name]; !ok {
+			// the api doesn't know the release
+			return time.Time{}, nil
+		}
+	}
+
+	return parseTime(v)
+}
+
+// parseTime reads a unix time, either a number or a string, or an RFC 3339
+// string
+func parseTime(v interface{}) (time.Time, error) {
+	var s string
+
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case json.Number:
+		s = t.String()
+	case string:
+		s = strings.TrimSpace(t)
+	default:
+		return time.Time{}, errors.Errorf("unexpected pretime '%v'", v)
+	}
+
+	if len(s) == 0 || s == "0" {
+		return time.Time{}, nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(int64(f), 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("unexpected pretime '%s'", s)
+	}
+
+	return t, nil
+}
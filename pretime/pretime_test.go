@@ -0,0 +1,113 @@
+package pretime
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewHTTPLookup(t *testing.T) {
+	_, err := NewHTTPLookup(&PretimeOpts{URL: "ftp://example.com/%[release]"})
+	checkErr(t, err, errors.New("url must be http or https: 'ftp://example.com/%[release]'"))
+
+	l, err := NewHTTPLookup(&PretimeOpts{})
+	checkErr(t, err, nil)
+
+	if l.Enabled("mp3") {
+		t.Fatal("expected lookups disabled without a url")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	l, err := NewHTTPLookup(&PretimeOpts{URL: "https://example.com/%[release]", Sections: []string{"MP3", "0day"}})
+	checkErr(t, err, nil)
+
+	for section, expected := range map[string]bool{"mp3": true, "0DAY": true, "tv": false} {
+		if got := l.Enabled(section); got != expected {
+			t.Errorf("expected %s enabled to be %t", section, expected)
+		}
+	}
+}
+
+func TestOld(t *testing.T) {
+	l, err := NewHTTPLookup(&PretimeOpts{MaxAge: 3600})
+	checkErr(t, err, nil)
+
+	created := time.Now()
+
+	if l.Old(created.Add(-time.Minute), created) {
+		t.Error("expected a minute to not be old")
+	}
+
+	if !l.Old(created.Add(-2*time.Hour), created) {
+		t.Error("expected two hours to be old")
+	}
+
+	if l.Old(time.Time{}, created) {
+		t.Error("expected an unknown pretime to not be old")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	pretime := time.Unix(1600000000, 0)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/pre/") {
+		case "Number-GRP":
+			fmt.Fprintf(w, `{"data": {"pretime": %d}}`, pretime.Unix())
+		case "String-GRP":
+			fmt.Fprintf(w, `{"data": {"pretime": "%s"}}`, pretime.Format(time.RFC3339))
+		case "Missing-GRP":
+			fmt.Fprint(w, `{"data": {}}`)
+		case "Bad-GRP":
+			fmt.Fprint(w, `{"data": {"pretime": "yesterday"}}`)
+		case "Error-GRP":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	l, err := NewHTTPLookup(&PretimeOpts{URL: srv.URL + "/pre/%[release]", Field: "data.pretime"})
+	checkErr(t, err, nil)
+
+	var tests = []struct {
+		release  string
+		expected time.Time
+		err      error
+	}{
+		{"Number-GRP", pretime, nil},
+		{"String-GRP", pretime, nil},
+		{"Missing-GRP", time.Time{}, nil},
+		{"Unknown-GRP", time.Time{}, nil},
+		{"Bad-GRP", time.Time{}, errors.New("unexpected pretime 'yesterday'")},
+		{"Error-GRP", time.Time{}, errors.New("unexpected status 500 Internal Server Error")},
+	}
+
+	for _, tt := range tests {
+		got, err := l.Lookup(tt.release)
+		checkErr(t, err, tt.err)
+
+		if !got.Equal(tt.expected) {
+			t.Errorf("%s: expected %s but got %s", tt.release, tt.expected, got)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	l, err := NewHTTPLookup(&PretimeOpts{})
+	checkErr(t, err, nil)
+
+	got, err := l.parse(strings.NewReader("1600000000\n"))
+	checkErr(t, err, nil)
+
+	if got.Unix() != 1600000000 {
+		t.Fatalf("unexpected pretime %s", got)
+	}
+}
@@ -0,0 +1,338 @@
+// Package proxyproto reads the HAProxy PROXY protocol header, versions 1
+// and 2, sent by load balancers at the start of a connection so the
+// address of the real client is used in place of the load balancer's
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is how long a connection has to send its header when the
+// Listener has no Timeout
+const DefaultTimeout = 5 * time.Second
+
+// the longest v1 header including the CRLF
+const maxV1Length = 107
+
+// signature starts every v2 header
+var signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v2 commands and address families
+const (
+	commandLocal = 0x0
+	commandProxy = 0x1
+
+	familyTCP4 = 0x11
+	familyTCP6 = 0x21
+)
+
+// ErrNoHeader is returned when a connection doesn't start with a header
+var ErrNoHeader = errors.New("no proxy protocol header")
+
+// Header is the addresses given by a header, both nil if the proxy didn't
+// give any, i.e. for health checks
+type Header struct {
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// ReadHeader reads a v1 or v2 header from the start of r
+func ReadHeader(r *bufio.Reader) (Header, error) {
+	b, err := r.Peek(len(signature))
+	if err != nil && len(b) == 0 {
+		return Header{}, err
+	}
+
+	if bytes.Equal(b, signature) {
+		return readV2(r)
+	}
+
+	if bytes.HasPrefix(b, []byte("PROXY ")) {
+		return readV1(r)
+	}
+
+	return Header{}, ErrNoHeader
+}
+
+// readV1 reads the text header, `PROXY <TCP4|TCP6> <source> <destination>
+// <source port> <destination port>\r\n` or `PROXY UNKNOWN ...\r\n`
+func readV1(r *bufio.Reader) (Header, error) {
+	var line []byte
+
+	for len(line) < maxV1Length {
+		c, err := r.ReadByte()
+		if err != nil {
+			return Header{}, err
+		}
+
+		line = append(line, c)
+
+		if c == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return Header{}, errors.New("v1 header too long")
+	}
+
+	fields := strings.Fields(string(line))
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return Header{}, nil
+	}
+
+	if len(fields) != 6 {
+		return Header{}, errors.Errorf("v1 header expected 6 fields but got %d", len(fields))
+	}
+
+	if fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return Header{}, errors.Errorf("v1 header has unknown protocol '%s'", fields[1])
+	}
+
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return Header{}, err
+	}
+
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return Header{}, err
+	}
+
+	return Header{Source: src, Destination: dst}, nil
+}
+
+// parseV1Addr parses an address and port of the protocol
+func parseV1Addr(protocol, ip, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || (protocol == "TCP4") != (addr.To4() != nil) {
+		return nil, errors.Errorf("v1 header has bad %s address '%s'", protocol, ip)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("v1 header has bad port '%s'", port)
+	}
+
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+// readV2 reads the binary header, anything other than tcp over ipv4 or ipv6
+// gives no addresses
+func readV2(r *bufio.Reader) (Header, error) {
+	fixed := make([]byte, len(signature)+4)
+
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return Header{}, err
+	}
+
+	verCmd, family := fixed[12], fixed[13]
+	length := int(binary.BigEndian.Uint16(fixed[14:]))
+
+	if verCmd>>4 != 2 {
+		return Header{}, errors.Errorf("v2 header has unknown version %d", verCmd>>4)
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return Header{}, err
+	}
+
+	switch verCmd & 0xf {
+	case commandLocal:
+		return Header{}, nil
+	case commandProxy:
+	default:
+		return Header{}, errors.Errorf("v2 header has unknown command %d", verCmd&0xf)
+	}
+
+	var size int
+
+	switch family {
+	case familyTCP4:
+		size = net.IPv4len
+	case familyTCP6:
+		size = net.IPv6len
+	default:
+		return Header{}, nil
+	}
+
+	if length < size*2+4 {
+		return Header{}, errors.New("v2 header too short for its addresses")
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:size]),
+		Port: int(binary.BigEndian.Uint16(payload[size*2:])),
+	}
+
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[size : size*2]),
+		Port: int(binary.BigEndian.Uint16(payload[size*2+2:])),
+	}
+
+	return Header{Source: src, Destination: dst}, nil
+}
+
+// Listener wraps a net.Listener, connections from trusted addresses must
+// start with a header
+type Listener struct {
+	net.Listener
+
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+// NewListener wraps l, trusted are the ips or cidrs of the proxies whose
+// headers are read. When trusted is empty PROXY headers are never accepted,
+// as a header lets whoever sends it claim any address and so get past ip
+// masks, bans and lockouts. Connections from untrusted addresses are used
+// as they are. A zero timeout uses DefaultTimeout
+func NewListener(l net.Listener, trusted []string, timeout time.Duration) (*Listener, error) {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	pl := Listener{
+		Listener: l,
+		timeout:  timeout,
+	}
+
+	for _, t := range trusted {
+		cidr := t
+
+		// a single address
+		if ip := net.ParseIP(t); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Errorf("bad trusted proxy '%s'", t)
+		}
+
+		pl.trusted = append(pl.trusted, network)
+	}
+
+	return &pl, nil
+}
+
+// Accept waits for the next connection, the header of a trusted connection
+// is read on its first Read, RemoteAddr or LocalAddr so a slow proxy
+// doesn't hold up the listener
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	return NewConn(conn, l.timeout), nil
+}
+
+// isTrusted checks to see if the address is one of the trusted proxies
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, n := range l.trusted {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Conn is a connection starting with a header
+type Conn struct {
+	net.Conn
+
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	header Header
+	err    error
+}
+
+// NewConn wraps conn, its header has to be read within the timeout
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// readHeader reads the header the first time it is called, the connection
+// is closed if it is missing or bad
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+		c.header, c.err = ReadHeader(c.reader)
+
+		c.Conn.SetReadDeadline(time.Time{})
+
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+// Header returns the connection's header, reading it if needed
+func (c *Conn) Header() (Header, error) {
+	c.readHeader()
+	return c.header, c.err
+}
+
+// Read reads after the header
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.readHeader(); c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client's address given by the header, the
+// proxy's if it gave none
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.readHeader(); c.header.Source != nil {
+		return c.header.Source
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to given by the
+// header, the connection's if it gave none
+func (c *Conn) LocalAddr() net.Addr {
+	if c.readHeader(); c.header.Destination != nil {
+		return c.header.Destination
+	}
+
+	return c.Conn.LocalAddr()
+}
@@ -0,0 +1,143 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// v2 builds a v2 header for the command and family with the payload
+func v2(command, family byte, payload []byte) string {
+	var b bytes.Buffer
+
+	b.Write(signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(family)
+	b.WriteByte(byte(len(payload) >> 8))
+	b.WriteByte(byte(len(payload)))
+	b.Write(payload)
+
+	return b.String()
+}
+
+func TestReadHeader(t *testing.T) {
+	tcp4 := []byte{
+		192, 168, 0, 1,
+		10, 0, 0, 1,
+		0x30, 0x39,
+		0x00, 0x15,
+	}
+
+	var tests = []struct {
+		name   string
+		header string
+		src    string
+		dst    string
+		err    error
+	}{
+		{"v1 tcp4", "PROXY TCP4 192.168.0.1 10.0.0.1 12345 21\r\n", "192.168.0.1:12345", "10.0.0.1:21", nil},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 12345 21\r\n", "[2001:db8::1]:12345", "[2001:db8::2]:21", nil},
+		{"v1 unknown", "PROXY UNKNOWN\r\n", "", "", nil},
+		{"v1 bad address", "PROXY TCP4 2001:db8::1 10.0.0.1 12345 21\r\n", "", "", errors.New("v1 header has bad TCP4 address '2001:db8::1'")},
+		{"v1 bad port", "PROXY TCP4 192.168.0.1 10.0.0.1 123456 21\r\n", "", "", errors.New("v1 header has bad port '123456'")},
+		{"v1 fields", "PROXY TCP4 192.168.0.1 10.0.0.1 12345\r\n", "", "", errors.New("v1 header expected 6 fields but got 5")},
+		{"v1 too long", "PROXY " + strings.Repeat("a", 120) + "\r\n", "", "", errors.New("v1 header too long")},
+		{"v2 tcp4", v2(commandProxy, familyTCP4, tcp4), "192.168.0.1:12345", "10.0.0.1:21", nil},
+		{"v2 tlvs", v2(commandProxy, familyTCP4, append(tcp4, 0x04, 0x00, 0x01, 0xff)), "192.168.0.1:12345", "10.0.0.1:21", nil},
+		{"v2 local", v2(commandLocal, 0, nil), "", "", nil},
+		{"v2 short", v2(commandProxy, familyTCP4, tcp4[:8]), "", "", errors.New("v2 header too short for its addresses")},
+		{"none", "USER foo\r\n", "", "", ErrNoHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name,
+			func(t *testing.T) {
+				r := bufio.NewReader(strings.NewReader(tt.header + "AUTH TLS\r\n"))
+
+				h, err := ReadHeader(r)
+				checkErr(t, err, tt.err)
+
+				if err != nil {
+					return
+				}
+
+				var src, dst string
+				if h.Source != nil {
+					src, dst = h.Source.String(), h.Destination.String()
+				}
+
+				if src != tt.src || dst != tt.dst {
+					t.Errorf("expected %s -> %s got %s -> %s", tt.src, tt.dst, src, dst)
+				}
+
+				rest, _ := ioutil.ReadAll(r)
+				if string(rest) != "AUTH TLS\r\n" {
+					t.Errorf("expected the rest to be left but got '%s'", rest)
+				}
+			},
+		)
+	}
+}
+
+func TestNewListener(t *testing.T) {
+	_, err := NewListener(nil, []string{"10.0.0.0/8", "127.0.0.1", "::1"}, 0)
+	checkErr(t, err, nil)
+
+	_, err = NewListener(nil, []string{"10.0.0"}, 0)
+	checkErr(t, err, errors.New("bad trusted proxy '10.0.0'"))
+}
+
+func TestListener(t *testing.T) {
+	var tests = []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{"trusted", []string{"127.0.0.0/8"}, "192.168.0.1:12345"},
+		{"nobody", nil, "127.0.0.1"},
+		{"untrusted", []string{"10.0.0.0/8"}, "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name,
+			func(t *testing.T) {
+				l, err := net.Listen("tcp", "127.0.0.1:0")
+				checkErr(t, err, nil)
+				defer l.Close()
+
+				pl, err := NewListener(l, tt.trusted, 0)
+				checkErr(t, err, nil)
+
+				go func() {
+					c, err := net.Dial("tcp", l.Addr().String())
+					if err != nil {
+						return
+					}
+					defer c.Close()
+
+					c.Write([]byte("PROXY TCP4 192.168.0.1 10.0.0.1 12345 21\r\n"))
+				}()
+
+				conn, err := pl.Accept()
+				checkErr(t, err, nil)
+				defer conn.Close()
+
+				got := conn.RemoteAddr().String()
+				if tt.expected == "127.0.0.1" {
+					got, _, _ = net.SplitHostPort(got)
+				}
+
+				if got != tt.expected {
+					t.Errorf("expected '%s' got '%s'", tt.expected, got)
+				}
+			},
+		)
+	}
+}
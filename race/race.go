@@ -0,0 +1,349 @@
+// Package race keeps the racing stats of releases, who uploaded what and how
+// fast, so SITE RACESTATS can show the standings and completed releases can
+// be announced with their winners
+package race
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// keyPrefix is the prefix of every key in the store
+const keyPrefix = "race:"
+
+// maxRetries is the number of times a transaction is retried on conflict
+const maxRetries = 10
+
+// Upload is a file uploaded to a release
+type Upload struct {
+	User  string
+	Group string
+	Bytes int64
+	// how long the upload took
+	Duration time.Duration
+	Time     time.Time
+	// files of the release that have passed their CRC check and the
+	// files in its sfv, after the upload
+	Done  int
+	Total int
+}
+
+// Racer is the total of what a user or group uploaded to a release
+type Racer struct {
+	// the user or group name
+	Name  string
+	Group string `msgpack:",omitempty"`
+	Files int
+	Bytes int64
+	// time spent uploading
+	Duration time.Duration
+}
+
+// Speed returns the average bytes a second of the Racer's uploads
+func (r Racer) Speed() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// Percent returns the share of the release's bytes that the Racer uploaded
+func (r Racer) Percent(release Release) int {
+	if release.Bytes == 0 {
+		return 0
+	}
+	return int(r.Bytes * 100 / release.Bytes)
+}
+
+// Release is the racing stats of a release
+type Release struct {
+	Path   string
+	Racers []Racer
+	Files  int
+	Bytes  int64
+	// as of the latest upload
+	Done  int
+	Total int
+	// the first and latest uploads
+	Started time.Time
+	Updated time.Time
+	// set once every file in the sfv has passed
+	Completed time.Time
+}
+
+// Name returns the name of the release
+func (r Release) Name() string {
+	return filepath.Base(r.Path)
+}
+
+// Percent returns how much of the release is done
+func (r Release) Percent() int {
+	if r.Total == 0 {
+		return 0
+	}
+	return r.Done * 100 / r.Total
+}
+
+// Complete checks to see if every file in the sfv has passed
+func (r Release) Complete() bool {
+	return !r.Completed.IsZero()
+}
+
+// Elapsed returns the time between the start of the first upload and the
+// latest one finishing
+func (r Release) Elapsed() time.Duration {
+	return r.Updated.Sub(r.Started)
+}
+
+// Speed returns the bytes a second the release was uploaded at
+func (r Release) Speed() float64 {
+	if r.Elapsed() <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed().Seconds()
+}
+
+// Users returns the users who uploaded to the release, most bytes first
+func (r Release) Users() []Racer {
+	users := make([]Racer, len(r.Racers))
+	copy(users, r.Racers)
+
+	rank(users)
+
+	return users
+}
+
+// Groups returns the totals of the groups who uploaded to the release, most
+// bytes first
+func (r Release) Groups() []Racer {
+	var groups []Racer
+
+	index := make(map[string]int)
+
+	for _, u := range r.Racers {
+		i, ok := index[u.Group]
+		if !ok {
+			i = len(groups)
+			index[u.Group] = i
+			groups = append(groups, Racer{Name: u.Group})
+		}
+
+		groups[i].Files += u.Files
+		groups[i].Bytes += u.Bytes
+		groups[i].Duration += u.Duration
+	}
+
+	rank(groups)
+
+	return groups
+}
+
+// rank sorts racers by bytes, ties by files and then name
+func rank(racers []Racer) {
+	sort.SliceStable(racers, func(i, j int) bool {
+		if racers[i].Bytes != racers[j].Bytes {
+			return racers[i].Bytes > racers[j].Bytes
+		}
+		if racers[i].Files != racers[j].Files {
+			return racers[i].Files > racers[j].Files
+		}
+		return racers[i].Name < racers[j].Name
+	})
+}
+
+// add the Upload to the Release
+func (r *Release) add(u Upload) {
+	if r.Started.IsZero() || u.Time.Add(-u.Duration).Before(r.Started) {
+		r.Started = u.Time.Add(-u.Duration)
+	}
+
+	if u.Time.After(r.Updated) {
+		r.Updated = u.Time
+	}
+
+	r.Files++
+	r.Bytes += u.Bytes
+	r.Done = u.Done
+	r.Total = u.Total
+
+	for i := range r.Racers {
+		if strings.EqualFold(r.Racers[i].Name, u.User) {
+			r.Racers[i].Group = u.Group
+			r.Racers[i].Files++
+			r.Racers[i].Bytes += u.Bytes
+			r.Racers[i].Duration += u.Duration
+			return
+		}
+	}
+
+	r.Racers = append(r.Racers, Racer{
+		Name:     u.User,
+		Group:    u.Group,
+		Files:    1,
+		Bytes:    u.Bytes,
+		Duration: u.Duration,
+	})
+}
+
+// Race records the uploads to releases
+type Race interface {
+	Record(string, Upload) (*Release, error)
+	Complete(string, time.Time) (*Release, error)
+	Get(string) (*Release, error)
+	Remove(string) error
+	Close() error
+}
+
+type RaceOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// BadgerRace implements Race using a badger key/value store
+type BadgerRace struct {
+	*RaceOpts
+	db *badger.DB
+
+	// serialises updates so that racers don't constantly conflict,
+	// conflicts are still retried
+	mtx sync.Mutex
+}
+
+// NewBadgerRace takes in options and a badger DB
+func NewBadgerRace(opts *RaceOpts, db *badger.DB) *BadgerRace {
+	return &BadgerRace{
+		RaceOpts: opts,
+		db:       db,
+	}
+}
+
+func (r *BadgerRace) key(path string) []byte {
+	return []byte(keyPrefix + strings.ToLower(filepath.Clean(path)))
+}
+
+// Record adds the Upload to the stats of the release at path, returning the
+// updated Release
+func (r *BadgerRace) Record(path string, u Upload) (*Release, error) {
+	if u.Time.IsZero() {
+		u.Time = time.Now()
+	}
+
+	return r.update(path, true, func(release *Release) {
+		release.add(u)
+	})
+}
+
+// Complete marks the release at path as completed at t, returning the
+// Release. Returns nil if nothing was recorded for it
+func (r *BadgerRace) Complete(path string, t time.Time) (*Release, error) {
+	return r.update(path, false, func(release *Release) {
+		release.Completed = t
+		release.Done = release.Total
+	})
+}
+
+// update runs fn on the Release at path in a read-write transaction,
+// retrying if the transaction conflicts. A missing Release is only created
+// with create, otherwise nil is returned
+func (r *BadgerRace) update(path string, create bool, fn func(*Release)) (*Release, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	key := r.key(path)
+
+	var release *Release
+	var err error
+
+	for i := 0; i < maxRetries; i++ {
+		release = nil
+
+		err = r.db.Update(func(tx *badger.Txn) error {
+			existing, err := r.get(tx, key)
+			if err != nil {
+				return err
+			}
+
+			if existing == nil {
+				if !create {
+					return nil
+				}
+				existing = &Release{Path: filepath.Clean(path)}
+			}
+
+			fn(existing)
+
+			var b bytes.Buffer
+
+			if err := msgpack.NewEncoder(&b).Encode(existing); err != nil {
+				return err
+			}
+
+			release = existing
+
+			return tx.Set(key, b.Bytes())
+		})
+
+		if err != badger.ErrConflict {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// Get returns the Release at path, nil if nothing was recorded for it
+func (r *BadgerRace) Get(path string) (*Release, error) {
+	var release *Release
+
+	err := r.db.View(func(tx *badger.Txn) error {
+		var err error
+		release, err = r.get(tx, r.key(path))
+		return err
+	})
+
+	return release, err
+}
+
+// get decodes the Release at key, nil if the key is missing
+func (r *BadgerRace) get(tx *badger.Txn, key []byte) (*Release, error) {
+	item, err := tx.Get(key)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var release Release
+
+	err = item.Value(func(val []byte) error {
+		return msgpack.Unmarshal(val, &release)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &release, nil
+}
+
+// Remove deletes the stats of the release at path, i.e. when it is removed
+func (r *BadgerRace) Remove(path string) error {
+	return r.db.Update(func(tx *badger.Txn) error {
+		return tx.Delete(r.key(path))
+	})
+}
+
+// Close closes the underlying badger store
+func (r *BadgerRace) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,130 @@
+package race
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	r := newMemoryRace(t)
+	defer closeMemoryRace(t, r)
+
+	start := time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	uploads := []Upload{
+		{User: "alice", Group: "grpa", Bytes: 100, Duration: time.Second, Time: start.Add(time.Second), Done: 1, Total: 4},
+		{User: "bob", Group: "grpb", Bytes: 300, Duration: 2 * time.Second, Time: start.Add(2 * time.Second), Done: 2, Total: 4},
+		{User: "carol", Group: "grpa", Bytes: 150, Duration: time.Second, Time: start.Add(3 * time.Second), Done: 3, Total: 4},
+		{User: "ALICE", Group: "grpa", Bytes: 50, Duration: time.Second, Time: start.Add(4 * time.Second), Done: 4, Total: 4},
+	}
+
+	for _, u := range uploads {
+		_, err := r.Record("/mp3/Artist-Album-2020-GRP", u)
+		checkErr(t, err, nil)
+	}
+
+	release, err := r.Get("/MP3/artist-album-2020-grp/")
+	checkErr(t, err, nil)
+
+	if release == nil {
+		t.Fatal("expected release")
+	}
+
+	if release.Path != "/mp3/Artist-Album-2020-GRP" || release.Name() != "Artist-Album-2020-GRP" {
+		t.Fatalf("unexpected path '%s'", release.Path)
+	}
+
+	if release.Files != 4 || release.Bytes != 600 || release.Percent() != 100 {
+		t.Fatalf("unexpected totals %+v", release)
+	}
+
+	if release.Complete() {
+		t.Fatal("expected release not to be complete until marked")
+	}
+
+	if release.Elapsed() != 4*time.Second || release.Speed() != 150 {
+		t.Fatalf("unexpected elapsed %s and speed %f", release.Elapsed(), release.Speed())
+	}
+
+	users := release.Users()
+
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users got %+v", users)
+	}
+
+	for i, expected := range []Racer{
+		{Name: "bob", Group: "grpb", Files: 1, Bytes: 300, Duration: 2 * time.Second},
+		{Name: "alice", Group: "grpa", Files: 2, Bytes: 150, Duration: 2 * time.Second},
+		{Name: "carol", Group: "grpa", Files: 1, Bytes: 150, Duration: time.Second},
+	} {
+		if users[i] != expected {
+			t.Errorf("expected user %d to be %+v got %+v", i, expected, users[i])
+		}
+	}
+
+	if users[0].Speed() != 150 || users[0].Percent(*release) != 50 {
+		t.Errorf("unexpected speed %f and percent %d", users[0].Speed(), users[0].Percent(*release))
+	}
+
+	groups := release.Groups()
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups got %+v", groups)
+	}
+
+	if groups[0].Name != "grpa" || groups[0].Bytes != 300 || groups[0].Files != 3 {
+		t.Errorf("unexpected first group %+v", groups[0])
+	}
+
+	if groups[1].Name != "grpb" || groups[1].Bytes != 300 || groups[1].Files != 1 {
+		t.Errorf("unexpected second group %+v", groups[1])
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := newMemoryRace(t)
+	defer closeMemoryRace(t, r)
+
+	now := time.Now()
+
+	release, err := r.Complete("/mp3/nothing", now)
+	checkErr(t, err, nil)
+
+	if release != nil {
+		t.Fatalf("expected nil for release without uploads got %+v", release)
+	}
+
+	release, err = r.Get("/mp3/nothing")
+	checkErr(t, err, nil)
+
+	if release != nil {
+		t.Fatalf("expected complete not to create a release got %+v", release)
+	}
+
+	_, err = r.Record("/mp3/release", Upload{User: "user", Group: "group", Bytes: 1, Done: 1, Total: 2})
+	checkErr(t, err, nil)
+
+	release, err = r.Complete("/mp3/release", now)
+	checkErr(t, err, nil)
+
+	if release == nil || !release.Complete() || !release.Completed.Equal(now) || release.Percent() != 100 {
+		t.Fatalf("unexpected completed release %+v", release)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	r := newMemoryRace(t)
+	defer closeMemoryRace(t, r)
+
+	_, err := r.Record("/mp3/release", Upload{User: "user", Group: "group", Bytes: 1})
+	checkErr(t, err, nil)
+
+	checkErr(t, r.Remove("/mp3/release"), nil)
+
+	release, err := r.Get("/mp3/release")
+	checkErr(t, err, nil)
+
+	if release != nil {
+		t.Fatalf("expected removed release got %+v", release)
+	}
+}
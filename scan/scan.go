@@ -0,0 +1,376 @@
+// Package scan checks uploads for viruses using clamd or an external
+// command, so infected files can be quarantined or deleted as soon as they
+// arrive rather than being found by users
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/goftpd/goftpd/announce"
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+// what happens to infected uploads
+const (
+	ActionDelete     = "delete"
+	ActionQuarantine = "quarantine"
+)
+
+// defaults used when options are empty
+const (
+	defaultTimeout  = 60
+	defaultMaxScans = 2
+)
+
+// clamdChunkSize is the most of a file sent to clamd in each chunk
+const clamdChunkSize = 32 * 1024
+
+// Result is the outcome of scanning a file
+type Result struct {
+	Infected bool
+	// the name of what was found, i.e. Eicar-Signature
+	Signature string
+}
+
+// Backend scans a file on the host
+type Backend interface {
+	Scan(ctx context.Context, path string) (Result, error)
+}
+
+// Clamd scans files by streaming them to clamd, so clamd doesn't need to be
+// able to read the site's files
+type Clamd struct {
+	network string
+	addr    string
+}
+
+// NewClamd takes the address of clamd, a path for a unix socket or
+// host:port for tcp
+func NewClamd(addr string) *Clamd {
+	if strings.HasPrefix(addr, "/") {
+		return &Clamd{network: "unix", addr: addr}
+	}
+	return &Clamd{network: "tcp", addr: addr}
+}
+
+// Scan sends the file to clamd using INSTREAM
+func (c *Clamd) Scan(ctx context.Context, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, err
+	}
+
+	// each chunk is prefixed with its length, a zero length ends the stream
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+
+	for {
+		n, err := f.Read(buf)
+
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+
+			if _, err := w.Write(size); err != nil {
+				return Result{}, err
+			}
+
+			if _, err := w.Write(buf[:n]); err != nil {
+				return Result{}, err
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+
+	if _, err := w.Write(size); err != nil {
+		return Result{}, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && (err != io.EOF || len(reply) == 0) {
+		return Result{}, err
+	}
+
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply reads the reply to INSTREAM, i.e. `stream: OK` or
+// `stream: Eicar-Signature FOUND`
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+
+	return Result{}, errors.Errorf("clamd: %s", reply)
+}
+
+// Command scans files by running a program, it exits with 0 for a clean
+// file and 1 for an infected file as clamscan and clamdscan do
+type Command struct {
+	args []string
+}
+
+// NewCommand takes a command line, %[path] is replaced with where the file
+// is on the host
+func NewCommand(line string) (*Command, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil, errors.New("command requires a program")
+	}
+
+	return &Command{args: args}, nil
+}
+
+// Scan runs the command, the last line it outputs is used as the signature
+// of infected files
+func (c *Command) Scan(ctx context.Context, path string) (Result, error) {
+	cookies := cookie.Cookies{"path": path}
+
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = cookies.Expand(a)
+	}
+
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err == nil {
+		return Result{}, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return Result{Infected: true, Signature: lastLine(output)}, nil
+	}
+
+	if line := lastLine(output); len(line) > 0 {
+		return Result{}, errors.WithMessage(err, line)
+	}
+
+	return Result{}, err
+}
+
+// lastLine returns the last line of output that isn't empty
+func lastLine(output []byte) string {
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	return strings.TrimSpace(string(lines[len(lines)-1]))
+}
+
+// Scanner scans uploads and decides what happens to those infected
+type Scanner interface {
+	// Enabled checks to see if uploads to the path are scanned
+	Enabled(string) bool
+	// Scan scans the file at the path on the host
+	Scan(context.Context, string) (Result, error)
+	// QuarantineDir returns where infected uploads are moved to, empty if
+	// they are deleted
+	QuarantineDir() string
+	// Notify tells staff about an infected upload
+	Notify(cookie.Cookies)
+	Close() error
+}
+
+type ScanOpts struct {
+	// where clamd listens, a path for a unix socket or host:port
+	Clamd string `goftpd:"clamd"`
+	// or a command run to scan each upload, see NewCommand
+	Command string `goftpd:"command"`
+	// globs of the uploads scanned, defaults to all of them
+	Paths []string `goftpd:"paths"`
+	// delete or quarantine, defaults to delete
+	Action string `goftpd:"action"`
+	// the dir infected uploads are moved to when quarantined
+	Quarantine string `goftpd:"quarantine"`
+	// seconds a scan can take, zero uses 60
+	Timeout int `goftpd:"timeout"`
+	// uploads scanned at once, the rest wait. zero uses 2
+	MaxScans int `goftpd:"max_scans"`
+	// sent to staff about infected uploads, see announce.AnnounceOpts
+	Message string `goftpd:"message"`
+
+	announcer announce.Announcer
+}
+
+// SetAnnouncer sets where staff are told about infected uploads, only
+// announce.EventInfected is announced
+func (o *ScanOpts) SetAnnouncer(a announce.Announcer) { o.announcer = a }
+
+// UploadScanner implements Scanner using a Backend
+type UploadScanner struct {
+	*ScanOpts
+	backend Backend
+	paths   []glob.Glob
+
+	// limits the scans running at once
+	slots chan struct{}
+}
+
+// NewUploadScanner takes in options and returns an UploadScanner. Uploads
+// are only scanned when clamd or a command is set. Fails if both are set or
+// the action is unknown
+func NewUploadScanner(opts *ScanOpts) (*UploadScanner, error) {
+	if len(opts.Clamd) > 0 && len(opts.Command) > 0 {
+		return nil, errors.New("only one of clamd and command can be set")
+	}
+
+	if len(opts.Action) == 0 {
+		opts.Action = ActionDelete
+	}
+
+	switch opts.Action {
+	case ActionDelete:
+	case ActionQuarantine:
+		if len(opts.Quarantine) == 0 {
+			return nil, errors.New("quarantine requires a quarantine dir")
+		}
+	default:
+		return nil, errors.Errorf("unknown action '%s'", opts.Action)
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if opts.MaxScans == 0 {
+		opts.MaxScans = defaultMaxScans
+	}
+
+	s := UploadScanner{
+		ScanOpts: opts,
+		slots:    make(chan struct{}, opts.MaxScans),
+	}
+
+	switch {
+	case len(opts.Clamd) > 0:
+		s.backend = NewClamd(opts.Clamd)
+
+	case len(opts.Command) > 0:
+		c, err := NewCommand(opts.Command)
+		if err != nil {
+			return nil, err
+		}
+		s.backend = c
+	}
+
+	for _, p := range opts.Paths {
+		g, err := glob.Compile(strings.ToLower(p), '/')
+		if err != nil {
+			return nil, errors.WithMessagef(err, "bad path '%s'", p)
+		}
+		s.paths = append(s.paths, g)
+	}
+
+	return &s, nil
+}
+
+// Enabled checks to see if a backend is set and the path matches one of
+// the globs
+func (s *UploadScanner) Enabled(path string) bool {
+	if s.backend == nil {
+		return false
+	}
+
+	if len(s.paths) == 0 {
+		return true
+	}
+
+	path = strings.ToLower(path)
+
+	for _, g := range s.paths {
+		if g.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scan waits for a free slot then scans the file, the timeout starts once
+// it has one
+func (s *UploadScanner) Scan(ctx context.Context, path string) (Result, error) {
+	if s.backend == nil {
+		return Result{}, nil
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+	defer func() { <-s.slots }()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+	defer cancel()
+
+	return s.backend.Scan(ctx, path)
+}
+
+// QuarantineDir returns the quarantine dir when the action is quarantine
+func (s *UploadScanner) QuarantineDir() string {
+	if s.Action != ActionQuarantine {
+		return ""
+	}
+	return s.Quarantine
+}
+
+// Notify announces the infected upload to staff
+func (s *UploadScanner) Notify(cookies cookie.Cookies) {
+	if s.announcer != nil {
+		s.announcer.Announce(announce.EventInfected, cookies)
+	}
+}
+
+// Close waits for notifications to be sent
+func (s *UploadScanner) Close() error {
+	if s.announcer != nil {
+		return s.announcer.Close()
+	}
+	return nil
+}
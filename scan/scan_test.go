@@ -0,0 +1,250 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+func TestParseClamdReply(t *testing.T) {
+	var tests = []struct {
+		reply    string
+		expected Result
+		err      error
+	}{
+		{"stream: OK\x00", Result{}, nil},
+		{"stream: Eicar-Signature FOUND\x00", Result{Infected: true, Signature: "Eicar-Signature"}, nil},
+		{"INSTREAM size limit exceeded. ERROR\x00", Result{}, errors.New("clamd: INSTREAM size limit exceeded. ERROR")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.reply,
+			func(t *testing.T) {
+				r, err := parseClamdReply(tt.reply)
+				checkErr(t, err, tt.err)
+
+				if r != tt.expected {
+					t.Errorf("expected %+v got %+v", tt.expected, r)
+				}
+			},
+		)
+	}
+}
+
+// fakeClamd reads an INSTREAM request and replies FOUND if the stream
+// contains EICAR
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	checkErr(t, err, nil)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var data []byte
+				size := make([]byte, 4)
+
+				for {
+					if _, err := io.ReadFull(r, size); err != nil {
+						return
+					}
+
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+
+					chunk := make([]byte, n)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+
+					data = append(data, chunk...)
+				}
+
+				if strings.Contains(string(data), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+
+				conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	checkErr(t, ioutil.WriteFile(path, []byte(contents), 0755), nil)
+
+	return path
+}
+
+func TestClamd(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewClamd(fakeClamd(t))
+
+	// larger than a chunk to check it is sent in pieces
+	clean := writeFile(t, dir, "clean", strings.Repeat("a", clamdChunkSize*2+10))
+	infected := writeFile(t, dir, "infected", strings.Repeat("a", clamdChunkSize)+"EICAR")
+
+	r, err := c.Scan(context.Background(), clean)
+	checkErr(t, err, nil)
+
+	if r.Infected {
+		t.Error("expected clean file to not be infected")
+	}
+
+	r, err = c.Scan(context.Background(), infected)
+	checkErr(t, err, nil)
+
+	if !r.Infected || r.Signature != "Eicar-Signature" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+
+	if NewClamd("/run/clamd.sock").network != "unix" {
+		t.Error("expected a path to be a unix socket")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	script := writeFile(t, dir, "scan.sh", `#!/bin/sh
+case "$1" in
+	*infected) echo "$1: Eicar-Signature FOUND"; exit 1 ;;
+	*broken) echo "can't read $1"; exit 2 ;;
+esac
+`)
+
+	c, err := NewCommand(script + " %[path]")
+	checkErr(t, err, nil)
+
+	r, err := c.Scan(context.Background(), "/site/clean")
+	checkErr(t, err, nil)
+
+	if r.Infected {
+		t.Error("expected clean file to not be infected")
+	}
+
+	r, err = c.Scan(context.Background(), "/site/infected")
+	checkErr(t, err, nil)
+
+	if !r.Infected || r.Signature != "/site/infected: Eicar-Signature FOUND" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+
+	_, err = c.Scan(context.Background(), "/site/broken")
+	checkErr(t, err, errors.New("can't read /site/broken: exit status 2"))
+
+	_, err = NewCommand(" ")
+	checkErr(t, err, errors.New("command requires a program"))
+}
+
+func TestNewUploadScanner(t *testing.T) {
+	var tests = []struct {
+		opts ScanOpts
+		err  error
+	}{
+		{ScanOpts{}, nil},
+		{ScanOpts{Clamd: "/run/clamd.sock", Action: ActionQuarantine, Quarantine: "/.quarantine"}, nil},
+		{ScanOpts{Clamd: "/run/clamd.sock", Command: "clamdscan %[path]"}, errors.New("only one of clamd and command can be set")},
+		{ScanOpts{Clamd: "/run/clamd.sock", Action: ActionQuarantine}, errors.New("quarantine requires a quarantine dir")},
+		{ScanOpts{Clamd: "/run/clamd.sock", Action: "ignore"}, errors.New("unknown action 'ignore'")},
+	}
+
+	for idx, tt := range tests {
+		opts := tt.opts
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				_, err := NewUploadScanner(&opts)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+type memoryAnnouncer struct {
+	events  []string
+	cookies []cookie.Cookies
+}
+
+func (a *memoryAnnouncer) Announce(event string, cookies cookie.Cookies) {
+	a.events = append(a.events, event)
+	a.cookies = append(a.cookies, cookies)
+}
+
+func (a *memoryAnnouncer) Close() error { return nil }
+
+func TestUploadScanner(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := ScanOpts{Clamd: fakeClamd(t), Paths: []string{"/mp3/**"}}
+
+	s, err := NewUploadScanner(&opts)
+	checkErr(t, err, nil)
+
+	if !s.Enabled("/MP3/release/file.mp3") || s.Enabled("/0day/release/file.zip") {
+		t.Error("expected only paths matching the globs to be scanned")
+	}
+
+	if len(s.QuarantineDir()) > 0 {
+		t.Error("expected no quarantine dir when deleting")
+	}
+
+	r, err := s.Scan(context.Background(), writeFile(t, dir, "file", "EICAR"))
+	checkErr(t, err, nil)
+
+	if !r.Infected {
+		t.Error("expected file to be infected")
+	}
+
+	var a memoryAnnouncer
+	s.SetAnnouncer(&a)
+	s.Notify(cookie.Cookies{"path": "/mp3/release/file.mp3"})
+
+	if len(a.events) != 1 || a.events[0] != "infected" {
+		t.Errorf("unexpected announcements: %v", a.events)
+	}
+
+	disabled, err := NewUploadScanner(&ScanOpts{})
+	checkErr(t, err, nil)
+
+	if disabled.Enabled("/mp3/release/file.mp3") {
+		t.Error("expected nothing to be scanned without a backend")
+	}
+}
@@ -0,0 +1,253 @@
+// Package schedule runs jobs at times given by cron expressions, the
+// server's own jobs such as creating dated directories or compacting its
+// stores and external programs, so nothing needs adding to the host's
+// crontab
+package schedule
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// JobExec is the job that runs an external program, its arguments are the
+// program followed by its arguments
+const JobExec = "exec"
+
+// JobFunc runs a job with the arguments given to it in the config
+type JobFunc func(ctx context.Context, args []string) error
+
+// Job is a job run on a schedule
+type Job struct {
+	spec Spec
+	name string
+	args []string
+}
+
+// NewJob takes a line of text (i.e. from a config file) in the form of
+// `<minute> <hour> <day of month> <month> <day of week> <job> [<args>...]`
+// or `<macro> <job> [<args>...]`, see ParseSpec
+func NewJob(line string) (Job, error) {
+	var job Job
+
+	fields := strings.Fields(line)
+
+	// a macro is a single field
+	n := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		n = 1
+	}
+
+	if len(fields) < n+1 {
+		return job, errors.New("job requires a schedule and a name")
+	}
+
+	spec, err := ParseSpec(strings.Join(fields[:n], " "))
+	if err != nil {
+		return job, err
+	}
+
+	job.spec = spec
+	job.name = strings.ToLower(fields[n])
+	job.args = fields[n+1:]
+
+	if job.name == JobExec && len(job.args) == 0 {
+		return job, errors.New("exec requires a program")
+	}
+
+	return job, nil
+}
+
+// Name returns the name of the job
+func (j Job) Name() string { return j.name }
+
+// Next returns when the job next runs after t
+func (j Job) Next(t time.Time) time.Time { return j.spec.Next(t) }
+
+type ScheduleOpts struct {
+	// seconds an exec job can run before it is killed, zero is unlimited
+	Timeout int `goftpd:"timeout"`
+
+	logger logging.Logger
+}
+
+// SetLogger sets where jobs are logged, defaults to logging.Stderr
+func (o *ScheduleOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where jobs are logged
+func (o *ScheduleOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// Scheduler runs jobs when they are due. A job still running when it is
+// next due is skipped
+type Scheduler struct {
+	*ScheduleOpts
+	jobs []Job
+
+	funcs map[string]JobFunc
+
+	mtx     sync.Mutex
+	running map[int]bool
+	wg      sync.WaitGroup
+}
+
+// NewScheduler takes in options and the jobs to run, the exec job is
+// registered straight away
+func NewScheduler(opts *ScheduleOpts, jobs []Job) *Scheduler {
+	s := Scheduler{
+		ScheduleOpts: opts,
+		jobs:         jobs,
+		funcs:        make(map[string]JobFunc),
+		running:      make(map[int]bool),
+	}
+
+	s.Register(JobExec, s.exec)
+
+	return &s
+}
+
+// Register sets the function run for jobs of the name
+func (s *Scheduler) Register(name string, fn JobFunc) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.funcs[strings.ToLower(name)] = fn
+}
+
+// Check makes sure every job has been registered
+func (s *Scheduler) Check() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, j := range s.jobs {
+		if _, ok := s.funcs[j.name]; !ok {
+			return errors.Errorf("unknown job '%s'", j.name)
+		}
+	}
+
+	return nil
+}
+
+// Run runs jobs as they are due until the context is cancelled, then waits
+// for those running to finish. Fails if a job hasn't been registered
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+
+	defer s.wg.Wait()
+
+	next := make([]time.Time, len(s.jobs))
+
+	now := time.Now()
+	for i, j := range s.jobs {
+		next[i] = j.Next(now)
+	}
+
+	for {
+		var earliest time.Time
+
+		for _, t := range next {
+			if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+				earliest = t
+			}
+		}
+
+		// nothing will ever run
+		if earliest.IsZero() {
+			<-ctx.Done()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+
+		select {
+		case <-timer.C:
+			s.runDue(ctx, next, time.Now())
+
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// runDue starts the jobs due by now and works out when they next run
+func (s *Scheduler) runDue(ctx context.Context, next []time.Time, now time.Time) {
+	for i, j := range s.jobs {
+		if next[i].IsZero() || next[i].After(now) {
+			continue
+		}
+
+		next[i] = j.Next(now)
+
+		s.start(ctx, i)
+	}
+}
+
+// start runs the job in the background unless it is already running
+func (s *Scheduler) start(ctx context.Context, i int) {
+	j := s.jobs[i]
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.running[i] {
+		s.Logger().Warn("scheduled job still running, skipped", logging.F("job", j.name))
+		return
+	}
+
+	fn := s.funcs[j.name]
+
+	s.running[i] = true
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		started := time.Now()
+
+		if err := fn(ctx, j.args); err != nil {
+			s.Logger().Warn("scheduled job failed", logging.F("job", j.name), logging.F("args", strings.Join(j.args, " ")), logging.Err(err))
+		} else {
+			s.Logger().Debug("scheduled job finished", logging.F("job", j.name), logging.Duration(time.Since(started)))
+		}
+
+		s.mtx.Lock()
+		delete(s.running, i)
+		s.mtx.Unlock()
+	}()
+}
+
+// exec runs an external program, anything it outputs is included in the
+// error when it fails
+func (s *Scheduler) exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("exec requires a program")
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if len(output) > 0 {
+			return errors.WithMessage(err, strings.TrimSpace(string(output)))
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseSpec(t *testing.T) {
+	var tests = []struct {
+		expr string
+		err  error
+	}{
+		{"* * * * *", nil},
+		{"*/15 0-6,22 1 jan-mar mon-fri", nil},
+		{"@daily", nil},
+		{"5/10 * * * 7", nil},
+		{"* * * *", errors.New("expected 5 fields but got 4")},
+		{"60 * * * *", errors.New("minute '60': 60 is out of range 0-59")},
+		{"* * 0 * *", errors.New("day of month '0': 0 is out of range 1-31")},
+		{"* 5-1 * * *", errors.New("hour '5-1': range is backwards")},
+		{"*/0 * * * *", errors.New("minute '*/0': bad step")},
+		{"* * * foo *", errors.New("month 'foo': bad value 'foo'")},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseSpec(tt.expr)
+		checkErr(t, err, tt.err)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2020, 1, 15, 10, 30, 20, 0, time.UTC)
+
+	var tests = []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 4 * * sun", time.Date(2020, 1, 19, 4, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 feb *", time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// either day matches when both are restricted
+		{"0 0 1 * mon", time.Date(2020, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.expr)
+		checkErr(t, err, nil)
+
+		if got := spec.Next(from); !got.Equal(tt.expected) {
+			t.Errorf("%s: expected %s but got %s", tt.expr, tt.expected, got)
+		}
+	}
+}
+
+func TestNewJob(t *testing.T) {
+	var tests = []struct {
+		line string
+		name string
+		args int
+		err  error
+	}{
+		{"0 0 * * * dated", "dated", 0, nil},
+		{"@daily WIPE /tmp/*", "wipe", 1, nil},
+		{"*/5 * * * * exec /bin/true -v", "exec", 2, nil},
+		{"@daily", "", 0, errors.New("job requires a schedule and a name")},
+		{"0 0 * * *", "", 0, errors.New("job requires a schedule and a name")},
+		{"@daily exec", "", 0, errors.New("exec requires a program")},
+		{"@sometimes gc", "", 0, errors.New("expected 5 fields but got 1")},
+	}
+
+	for _, tt := range tests {
+		job, err := NewJob(tt.line)
+		checkErr(t, err, tt.err)
+
+		if tt.err == nil && (job.Name() != tt.name || len(job.args) != tt.args) {
+			t.Errorf("%s: unexpected job %+v", tt.line, job)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	job, err := NewJob("@daily gc")
+	checkErr(t, err, nil)
+
+	s := NewScheduler(&ScheduleOpts{}, []Job{job})
+
+	checkErr(t, s.Check(), errors.New("unknown job 'gc'"))
+	checkErr(t, s.Run(context.Background()), errors.New("unknown job 'gc'"))
+
+	s.Register("GC", func(context.Context, []string) error { return nil })
+
+	checkErr(t, s.Check(), nil)
+}
+
+func TestRunDue(t *testing.T) {
+	hourly, err := NewJob("@hourly count a")
+	checkErr(t, err, nil)
+
+	daily, err := NewJob("@daily count b")
+	checkErr(t, err, nil)
+
+	s := NewScheduler(&ScheduleOpts{}, []Job{hourly, daily})
+
+	var (
+		mtx sync.Mutex
+		ran []string
+	)
+
+	s.Register("count", func(ctx context.Context, args []string) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		ran = append(ran, args...)
+		return nil
+	})
+
+	from := time.Date(2020, 1, 15, 22, 30, 0, 0, time.UTC)
+
+	next := []time.Time{hourly.Next(from), daily.Next(from)}
+
+	s.runDue(context.Background(), next, time.Date(2020, 1, 15, 23, 0, 0, 0, time.UTC))
+	s.wg.Wait()
+
+	if len(ran) != 1 || ran[0] != "a" {
+		t.Fatalf("expected only the hourly job to run but ran %v", ran)
+	}
+
+	if expected := time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC); !next[0].Equal(expected) {
+		t.Fatalf("expected the hourly job next at %s but got %s", expected, next[0])
+	}
+
+	s.runDue(context.Background(), next, time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC))
+	s.wg.Wait()
+
+	if len(ran) != 3 {
+		t.Fatalf("expected both jobs to run but ran %v", ran)
+	}
+}
+
+func TestSkipRunning(t *testing.T) {
+	job, err := NewJob("* * * * * slow")
+	checkErr(t, err, nil)
+
+	s := NewScheduler(&ScheduleOpts{}, []Job{job})
+
+	release := make(chan struct{})
+
+	var runs int
+
+	s.Register("slow", func(ctx context.Context, args []string) error {
+		runs++
+		<-release
+		return nil
+	})
+
+	s.start(context.Background(), 0)
+	s.start(context.Background(), 0)
+
+	close(release)
+	s.wg.Wait()
+
+	if runs != 1 {
+		t.Fatalf("expected 1 run but got %d", runs)
+	}
+}
+
+func TestExec(t *testing.T) {
+	s := NewScheduler(&ScheduleOpts{Timeout: 5}, nil)
+
+	checkErr(t, s.exec(context.Background(), []string{"true"}), nil)
+
+	err := s.exec(context.Background(), []string{"sh", "-c", "echo broken; exit 1"})
+	checkErr(t, err, errors.New("broken: exit status 1"))
+}
@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// macros are shorthand for common Specs
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes one of the fields of a cron expression
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is also sunday
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// maxSearch is how far ahead Next looks before giving up, i.e. for the
+// 30th of February
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Spec is a parsed cron expression, a bit is set for every value of each
+// field that matches
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+
+	// a day matches either day field when both are restricted, otherwise
+	// it has to match both
+	domAny, dowAny bool
+}
+
+// ParseSpec parses a cron expression of five fields, `<minute> <hour> <day
+// of month> <month> <day of week>`, or one of the macros @yearly, @monthly,
+// @weekly, @daily and @hourly. Fields are `*`, values, ranges `a-b` and
+// lists of them separated by commas, each optionally followed by a step
+// `/n`. Months and days of the week can be given as names, i.e. jan or mon
+func ParseSpec(expr string) (Spec, error) {
+	var spec Spec
+
+	if m, ok := macros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = m
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return spec, errors.Errorf("expected %d fields but got %d", len(fields), len(parts))
+	}
+
+	sets := make([]uint64, len(fields))
+
+	for i, f := range fields {
+		set, err := f.parse(strings.ToLower(parts[i]))
+		if err != nil {
+			return spec, errors.WithMessagef(err, "%s '%s'", f.name, parts[i])
+		}
+		sets[i] = set
+	}
+
+	// sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	spec.minute, spec.hour, spec.dom, spec.month, spec.dow = sets[0], sets[1], sets[2], sets[3], sets[4]
+	spec.domAny = parts[2] == "*"
+	spec.dowAny = parts[4] == "*"
+
+	return spec, nil
+}
+
+// parse returns the set of values matched by the field
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.New("bad step")
+			}
+			step, item = n, item[:i]
+		}
+
+		lo, hi := f.min, f.max
+
+		switch {
+		case item == "*":
+
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+
+			var err error
+
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+
+			if lo > hi {
+				return 0, errors.New("range is backwards")
+			}
+
+		default:
+			v, err := f.value(item)
+			if err != nil {
+				return 0, err
+			}
+
+			lo = v
+
+			// a value with a step runs to the end, i.e. 5/15
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// value parses a single value or name of the field
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[s]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("bad value '%s'", s)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, errors.Errorf("%d is out of range %d-%d", v, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// matchDay checks to see if the day of t matches
+func (s Spec) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Next returns the first minute after t that matches, the zero time if
+// nothing matches in the next five years
+func (s Spec) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	end := t.Add(maxSearch)
+
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
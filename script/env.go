@@ -0,0 +1,45 @@
+package script
+
+import (
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// ServerEnv implements Env using the server's Authenticator and VFS
+type ServerEnv struct {
+	auth acl.Authenticator
+	fs   vfs.VFS
+}
+
+// NewServerEnv takes in the Authenticator and VFS scripts can reach
+func NewServerEnv(auth acl.Authenticator, fs vfs.VFS) *ServerEnv {
+	return &ServerEnv{
+		auth: auth,
+		fs:   fs,
+	}
+}
+
+// User returns the named User
+func (e *ServerEnv) User(name string) (*acl.User, error) {
+	return e.auth.GetUser(name)
+}
+
+// Stat stats the path with the permissions of the named User
+func (e *ServerEnv) Stat(name, path string) (vfs.FileInfo, error) {
+	u, err := e.auth.GetUser(name)
+	if err != nil {
+		return vfs.FileInfo{}, err
+	}
+
+	return e.fs.StatFile(path, u)
+}
+
+// AdjustCredits adds n credits to the named User, a negative n takes them
+// even if it leaves the User with negative credits
+func (e *ServerEnv) AdjustCredits(name string, n int64) (int64, error) {
+	if n < 0 {
+		return e.auth.DecrCredits(name, -n, true)
+	}
+
+	return e.auth.IncrCredits(name, n)
+}
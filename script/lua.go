@@ -0,0 +1,352 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/pkg/errors"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Env is what Lua scripts can reach of the server
+type Env interface {
+	User(string) (*acl.User, error)
+	// stats the path as the named user
+	Stat(string, string) (vfs.FileInfo, error)
+	// adds to, or with a negative amount takes from, the user's credits
+	AdjustCredits(string, int64) (int64, error)
+}
+
+// Engine runs Lua SITE commands and event handlers
+type Engine interface {
+	Commands() []string
+	Site(string, Env, *acl.User, []string) (string, bool, error)
+	Event(Vars, Env)
+	Close() error
+}
+
+// luaLibs are the only standard libraries scripts are given, there is no
+// access to the os, io or loading other files
+var luaLibs = []struct {
+	name string
+	fn   lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// LuaEngine implements Engine with a single sandboxed Lua state. Lua
+// states aren't safe for concurrent use so only one script runs at a time
+type LuaEngine struct {
+	*ScriptOpts
+
+	mtx      sync.Mutex
+	state    *lua.LState
+	commands map[string]*lua.LFunction
+	handlers map[string][]*lua.LFunction
+
+	// set for the duration of a call
+	env   Env
+	reply []string
+
+	wg sync.WaitGroup
+}
+
+// NewLuaEngine loads the files in to a new Lua state. Files register SITE
+// commands with goftpd.site(name, fn) and event handlers with
+// goftpd.on(event, fn)
+func NewLuaEngine(opts *ScriptOpts, files []string) (*LuaEngine, error) {
+	e := LuaEngine{
+		ScriptOpts: opts,
+		state:      lua.NewState(lua.Options{SkipOpenLibs: true}),
+		commands:   make(map[string]*lua.LFunction),
+		handlers:   make(map[string][]*lua.LFunction),
+	}
+
+	L := e.state
+
+	for _, lib := range luaLibs {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	L.SetGlobal("goftpd", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"site":    e.luaSite,
+		"on":      e.luaOn,
+		"reply":   e.luaReply,
+		"user":    e.luaUser,
+		"stat":    e.luaStat,
+		"credits": e.luaCredits,
+	}))
+
+	for _, f := range files {
+		if err := L.DoFile(f); err != nil {
+			L.Close()
+			return nil, errors.WithMessagef(err, "loading '%s'", f)
+		}
+	}
+
+	return &e, nil
+}
+
+// Commands returns the upper cased names of the SITE commands scripts have
+// registered
+func (e *LuaEngine) Commands() []string {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	names := make([]string, 0, len(e.commands))
+	for name := range e.commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Site runs the SITE command called name for the user, returning what the
+// script replied with. Returns false if no script registered the command
+func (e *LuaEngine) Site(name string, env Env, user *acl.User, params []string) (string, bool, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	fn, ok := e.commands[strings.ToUpper(name)]
+	if !ok {
+		return "", false, nil
+	}
+
+	L := e.state
+
+	args := L.NewTable()
+	for _, p := range params {
+		args.Append(lua.LString(p))
+	}
+
+	ret, err := e.call(env, fn, userTable(L, user), args)
+	if err != nil {
+		return "", true, err
+	}
+
+	if s, ok := ret.(lua.LString); ok && len(s) > 0 {
+		e.reply = append(e.reply, string(s))
+	}
+
+	return strings.Join(e.reply, "\n"), true, nil
+}
+
+// Event runs the handlers of the event in the background, failures are
+// logged
+func (e *LuaEngine) Event(v Vars, env Env) {
+	e.mtx.Lock()
+	handlers := e.handlers[v.Event]
+	e.mtx.Unlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	e.wg.Add(1)
+
+	go func() {
+		defer e.wg.Done()
+
+		e.mtx.Lock()
+		defer e.mtx.Unlock()
+
+		L := e.state
+
+		t := L.NewTable()
+		t.RawSetString("event", lua.LString(v.Event))
+		t.RawSetString("user", lua.LString(v.User))
+		t.RawSetString("group", lua.LString(v.Group))
+		t.RawSetString("path", lua.LString(v.Path))
+		t.RawSetString("size", lua.LNumber(v.Size))
+		t.RawSetString("crc", lua.LString(fmt.Sprintf("%08X", v.CRC)))
+
+		for _, fn := range handlers {
+			if _, err := e.call(env, fn, t); err != nil {
+				e.Logger().Warn("lua handler failed", logging.F("event", v.Event), logging.User(v.User), logging.Path(v.Path), logging.Err(err))
+			}
+		}
+	}()
+}
+
+// call runs fn with the env, must be called with the mtx held
+func (e *LuaEngine) call(env Env, fn *lua.LFunction, args ...lua.LValue) (lua.LValue, error) {
+	L := e.state
+
+	e.env = env
+	e.reply = nil
+
+	defer func() {
+		e.env = nil
+	}()
+
+	if e.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.Timeout)*time.Second)
+		defer cancel()
+
+		L.SetContext(ctx)
+		defer L.RemoveContext()
+	}
+
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		return nil, err
+	}
+
+	ret := L.Get(-1)
+	L.Pop(1)
+
+	return ret, nil
+}
+
+// Close waits for running handlers and closes the Lua state
+func (e *LuaEngine) Close() error {
+	e.wg.Wait()
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.state.Close()
+
+	return nil
+}
+
+// goftpd.site(name, fn) registers fn(user, params) as SITE <name>
+func (e *LuaEngine) luaSite(L *lua.LState) int {
+	name := strings.ToUpper(L.CheckString(1))
+	fn := L.CheckFunction(2)
+
+	if len(name) == 0 || strings.ContainsAny(name, " \t") {
+		L.ArgError(1, "bad command name")
+	}
+
+	e.commands[name] = fn
+
+	return 0
+}
+
+// goftpd.on(event, fn) registers fn(event) to run on the event
+func (e *LuaEngine) luaOn(L *lua.LState) int {
+	event := strings.ToLower(L.CheckString(1))
+	fn := L.CheckFunction(2)
+
+	if _, ok := events[event]; !ok {
+		L.ArgError(1, fmt.Sprintf("unknown event '%s'", event))
+	}
+
+	e.handlers[event] = append(e.handlers[event], fn)
+
+	return 0
+}
+
+// goftpd.reply(text) adds a line to the reply of a SITE command
+func (e *LuaEngine) luaReply(L *lua.LState) int {
+	e.reply = append(e.reply, L.CheckString(1))
+	return 0
+}
+
+// goftpd.user(name) returns a table describing the user or nil and an error
+func (e *LuaEngine) luaUser(L *lua.LState) int {
+	env := e.checkEnv(L)
+
+	u, err := env.User(L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(userTable(L, u))
+
+	return 1
+}
+
+// goftpd.stat(user, path) returns a table describing the path as seen by
+// the user or nil and an error
+func (e *LuaEngine) luaStat(L *lua.LState) int {
+	env := e.checkEnv(L)
+
+	finfo, err := env.Stat(L.CheckString(1), L.CheckString(2))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	t := L.NewTable()
+	t.RawSetString("name", lua.LString(finfo.Name()))
+	t.RawSetString("size", lua.LNumber(finfo.Size()))
+	t.RawSetString("dir", lua.LBool(finfo.IsDir()))
+	t.RawSetString("owner", lua.LString(finfo.Owner))
+	t.RawSetString("group", lua.LString(finfo.Group))
+	t.RawSetString("modified", lua.LNumber(finfo.ModTime().Unix()))
+	t.RawSetString("crc", lua.LString(fmt.Sprintf("%08X", finfo.Checksum.CRC32)))
+
+	L.Push(t)
+
+	return 1
+}
+
+// goftpd.credits(name, bytes) adds to, or with a negative amount takes
+// from, the user's credits and returns their new credits
+func (e *LuaEngine) luaCredits(L *lua.LState) int {
+	env := e.checkEnv(L)
+
+	credits, err := env.AdjustCredits(L.CheckString(1), L.CheckInt64(2))
+	if err != nil {
+		L.RaiseError("%s", err)
+	}
+
+	L.Push(lua.LNumber(credits))
+
+	return 1
+}
+
+// checkEnv raises an error when the server is used while loading files
+func (e *LuaEngine) checkEnv(L *lua.LState) Env {
+	if e.env == nil {
+		L.RaiseError("only available to commands and handlers")
+	}
+	return e.env
+}
+
+// userTable describes the User to scripts
+func userTable(L *lua.LState, u *acl.User) *lua.LTable {
+	t := L.NewTable()
+
+	t.RawSetString("name", lua.LString(u.Name))
+	t.RawSetString("group", lua.LString(u.PrimaryGroup))
+	t.RawSetString("credits", lua.LNumber(u.Credits))
+	t.RawSetString("ratio", lua.LNumber(u.Ratio))
+	t.RawSetString("tagline", lua.LString(u.Tagline))
+
+	groups := L.NewTable()
+	for g := range u.Groups {
+		groups.Append(lua.LString(g))
+	}
+	t.RawSetString("groups", groups)
+
+	flags := L.NewTable()
+	for f := range u.Flags {
+		flags.Append(lua.LString(f))
+	}
+	t.RawSetString("flags", flags)
+
+	return t
+}
@@ -0,0 +1,151 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+// testEnv implements Env with a single user
+type testEnv struct {
+	user *acl.User
+}
+
+func (e *testEnv) User(name string) (*acl.User, error) {
+	if !strings.EqualFold(name, e.user.Name) {
+		return nil, acl.ErrUserDoesntExist
+	}
+	return e.user, nil
+}
+
+func (e *testEnv) Stat(user, path string) (vfs.FileInfo, error) {
+	return vfs.FileInfo{}, os.ErrNotExist
+}
+
+func (e *testEnv) AdjustCredits(name string, n int64) (int64, error) {
+	if _, err := e.User(name); err != nil {
+		return 0, err
+	}
+	e.user.Credits += n
+	return e.user.Credits, nil
+}
+
+func newTestEngine(t *testing.T, source string) *LuaEngine {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "goftpd-lua")
+	checkErr(t, err, nil)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "test.lua")
+	checkErr(t, ioutil.WriteFile(path, []byte(source), 0644), nil)
+
+	e, err := NewLuaEngine(&ScriptOpts{Timeout: 1}, []string{path})
+	checkErr(t, err, nil)
+
+	return e
+}
+
+func TestLuaSite(t *testing.T) {
+	e := newTestEngine(t, `
+goftpd.site("hello", function(user, params)
+	goftpd.reply("Hello " .. user.name)
+	return "You said " .. table.concat(params, " ")
+end)
+
+goftpd.site("bonus", function(user, params)
+	local credits = goftpd.credits(params[1], tonumber(params[2]))
+	return params[1] .. " has " .. credits
+end)
+
+goftpd.site("missing", function(user, params)
+	local u, err = goftpd.user("nobody")
+	if u == nil then
+		error(err)
+	end
+end)
+
+goftpd.site("forever", function(user, params)
+	while true do end
+end)
+`)
+	defer e.Close()
+
+	env := &testEnv{user: &acl.User{Name: "user", Credits: 10}}
+
+	if names := strings.Join(e.Commands(), " "); names != "BONUS FOREVER HELLO MISSING" {
+		t.Fatalf("unexpected commands '%s'", names)
+	}
+
+	reply, ok, err := e.Site("HELLO", env, env.user, []string{"a", "b"})
+	checkErr(t, err, nil)
+
+	if !ok || reply != "Hello user\nYou said a b" {
+		t.Errorf("unexpected reply '%s'", reply)
+	}
+
+	reply, _, err = e.Site("bonus", env, env.user, []string{"user", "5"})
+	checkErr(t, err, nil)
+
+	if reply != "user has 15" || env.user.Credits != 15 {
+		t.Errorf("unexpected reply '%s' and credits %d", reply, env.user.Credits)
+	}
+
+	if _, _, err := e.Site("missing", env, env.user, nil); err == nil || !strings.Contains(err.Error(), acl.ErrUserDoesntExist.Error()) {
+		t.Errorf("expected user doesn't exist error got '%v'", err)
+	}
+
+	if _, _, err := e.Site("forever", env, env.user, nil); err == nil {
+		t.Error("expected timeout")
+	}
+
+	if _, ok, _ := e.Site("unknown", env, env.user, nil); ok {
+		t.Error("expected unknown command to not be found")
+	}
+}
+
+func TestLuaSandbox(t *testing.T) {
+	for _, source := range []string{
+		`os.exit(1)`,
+		`io.open("/etc/passwd")`,
+		`dofile("/etc/passwd")`,
+		`require("os")`,
+		`goftpd.user("user")`,
+	} {
+		dir, err := ioutil.TempDir("", "goftpd-lua")
+		checkErr(t, err, nil)
+
+		path := filepath.Join(dir, "test.lua")
+		checkErr(t, ioutil.WriteFile(path, []byte(source), 0644), nil)
+
+		if _, err := NewLuaEngine(&ScriptOpts{}, []string{path}); err == nil {
+			t.Errorf("expected '%s' to fail", source)
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLuaEvent(t *testing.T) {
+	e := newTestEngine(t, `
+goftpd.on("upload", function(event)
+	goftpd.credits(event.user, event.size * 3)
+end)
+`)
+
+	env := &testEnv{user: &acl.User{Name: "user"}}
+
+	e.Event(Vars{Event: EventUpload, User: "user", Path: "/file", Size: 100}, env)
+	e.Event(Vars{Event: EventDelete, User: "user", Path: "/file"}, env)
+
+	checkErr(t, e.Close(), nil)
+
+	if env.user.Credits != 300 {
+		t.Errorf("expected 300 credits got %d", env.user.Credits)
+	}
+}
@@ -0,0 +1,208 @@
+// Package script runs external programs when files are uploaded,
+// downloaded or deleted and directories are made, i.e. to post process
+// uploads
+package script
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// events scripts can be run on
+const (
+	EventUpload   = "upload"
+	EventDownload = "download"
+	EventMakeDir  = "makedir"
+	EventDelete   = "delete"
+)
+
+var events = map[string]struct{}{
+	EventUpload:   {},
+	EventDownload: {},
+	EventMakeDir:  {},
+	EventDelete:   {},
+}
+
+// Vars describe an event, they are passed to programs as GOFTPD_
+// environment variables
+type Vars struct {
+	Event string
+	User  string
+	Group string
+	// the path as seen by users
+	Path string
+	// where the path is on the host
+	RealPath string
+	// size of the file, zero for directories and deletes
+	Size int64
+	// CRC32 of uploaded and downloaded files, zero if unknown
+	CRC uint32
+}
+
+// Env returns the Vars as environment variables
+func (v Vars) Env() []string {
+	return []string{
+		"GOFTPD_EVENT=" + v.Event,
+		"GOFTPD_USER=" + v.User,
+		"GOFTPD_GROUP=" + v.Group,
+		"GOFTPD_PATH=" + v.Path,
+		"GOFTPD_REALPATH=" + v.RealPath,
+		fmt.Sprintf("GOFTPD_SIZE=%d", v.Size),
+		fmt.Sprintf("GOFTPD_CRC=%08X", v.CRC),
+	}
+}
+
+// Script is a program run on an event for matching paths
+type Script struct {
+	event   string
+	g       glob.Glob
+	command []string
+}
+
+// NewScript takes a line of text (i.e. from a config file) in the form of
+// `<event> <glob> <program> [<args>...]`
+func NewScript(line string) (Script, error) {
+	var script Script
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 3 {
+		return script, errors.New("script requires at least 3 fields")
+	}
+
+	script.event = strings.ToLower(fields[0])
+
+	if _, ok := events[script.event]; !ok {
+		return script, errors.Errorf("unknown event '%s'", fields[0])
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[1]), '/')
+	if err != nil {
+		return script, err
+	}
+
+	script.g = g
+	script.command = fields[2:]
+
+	return script, nil
+}
+
+// Match checks to see if the Script is run for the event on path
+func (s Script) Match(event, path string) bool {
+	return s.event == event && s.g.Match(strings.ToLower(path))
+}
+
+// Runner runs the scripts for events
+type Runner interface {
+	Run(Vars)
+	Close() error
+}
+
+type ScriptOpts struct {
+	// seconds a program can run before it is killed, zero is unlimited
+	Timeout int `goftpd:"timeout"`
+	// programs that can run at once, others wait their turn. zero is
+	// unlimited
+	MaxRunning int `goftpd:"max_running"`
+
+	logger logging.Logger
+}
+
+// SetLogger sets where failing scripts are logged, defaults to
+// logging.Stderr
+func (o *ScriptOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where failing scripts are logged
+func (o *ScriptOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// ExecRunner implements Runner by executing programs on the host
+type ExecRunner struct {
+	*ScriptOpts
+	scripts []Script
+
+	running chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewExecRunner takes in options and the scripts to run, every matching
+// script is run for an event
+func NewExecRunner(opts *ScriptOpts, scripts []Script) *ExecRunner {
+	r := ExecRunner{
+		ScriptOpts: opts,
+		scripts:    scripts,
+	}
+
+	if opts.MaxRunning > 0 {
+		r.running = make(chan struct{}, opts.MaxRunning)
+	}
+
+	return &r
+}
+
+// Run starts every script matching the event in the background. Failures
+// are logged along with anything the program output
+func (r *ExecRunner) Run(v Vars) {
+	for _, s := range r.scripts {
+		if !s.Match(v.Event, v.Path) {
+			continue
+		}
+
+		r.wg.Add(1)
+
+		go func(s Script) {
+			defer r.wg.Done()
+
+			if r.running != nil {
+				r.running <- struct{}{}
+				defer func() { <-r.running }()
+			}
+
+			if output, err := r.exec(s, v); err != nil {
+				r.Logger().Warn("script failed",
+					logging.F("script", s.command[0]),
+					logging.F("event", v.Event),
+					logging.User(v.User),
+					logging.Path(v.Path),
+					logging.F("output", string(output)),
+					logging.Err(err),
+				)
+			}
+		}(s)
+	}
+}
+
+// exec runs the program of the Script with the Vars in its environment
+func (r *ExecRunner) exec(s Script, v Vars) ([]byte, error) {
+	ctx := context.Background()
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	c.Env = append(os.Environ(), v.Env()...)
+
+	return c.CombinedOutput()
+}
+
+// Close waits for running scripts to finish
+func (r *ExecRunner) Close() error {
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,100 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewScript(t *testing.T) {
+	var tests = []struct {
+		line     string
+		expected error
+	}{
+		{"upload /incoming/** /bin/true", nil},
+		{"DOWNLOAD /** /bin/echo hello", nil},
+		{"upload /**", errors.New("script requires at least 3 fields")},
+		{"rename /** /bin/true", errors.New("unknown event 'rename'")},
+	}
+
+	for _, tt := range tests {
+		_, err := NewScript(tt.line)
+		checkErr(t, err, tt.expected)
+	}
+}
+
+func TestScriptMatch(t *testing.T) {
+	s, err := NewScript("upload /incoming/** /bin/true")
+	checkErr(t, err, nil)
+
+	var tests = []struct {
+		event    string
+		path     string
+		expected bool
+	}{
+		{EventUpload, "/incoming/rls/file.rar", true},
+		{EventUpload, "/INCOMING/rls/file.rar", true},
+		{EventDownload, "/incoming/rls/file.rar", false},
+		{EventUpload, "/archive/rls/file.rar", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.Match(tt.event, tt.path); got != tt.expected {
+			t.Errorf("expected %s of '%s' to match %t", tt.event, tt.path, tt.expected)
+		}
+	}
+}
+
+func TestExecRunner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goftpd-script")
+	checkErr(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+
+	var scripts []Script
+	for _, line := range []string{
+		"upload /incoming/** /bin/sh -c env>>" + out,
+		"upload /archive/** /bin/sh -c exit",
+		"delete /** /bin/sh -c exit",
+	} {
+		s, err := NewScript(line)
+		checkErr(t, err, nil)
+		scripts = append(scripts, s)
+	}
+
+	r := NewExecRunner(&ScriptOpts{Timeout: 5, MaxRunning: 1}, scripts)
+
+	r.Run(Vars{
+		Event:    EventUpload,
+		User:     "user",
+		Group:    "group",
+		Path:     "/incoming/rls/file.rar",
+		RealPath: "/site/incoming/rls/file.rar",
+		Size:     1024,
+		CRC:      0xdeadbeef,
+	})
+
+	checkErr(t, r.Close(), nil)
+
+	b, err := ioutil.ReadFile(out)
+	checkErr(t, err, nil)
+
+	for _, expected := range []string{
+		"GOFTPD_EVENT=upload",
+		"GOFTPD_USER=user",
+		"GOFTPD_GROUP=group",
+		"GOFTPD_PATH=/incoming/rls/file.rar",
+		"GOFTPD_REALPATH=/site/incoming/rls/file.rar",
+		"GOFTPD_SIZE=1024",
+		"GOFTPD_CRC=DEADBEEF",
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected environment to contain '%s'", expected)
+		}
+	}
+}
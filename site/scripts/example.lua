@@ -0,0 +1,30 @@
+-- loaded with `script lua site/scripts/example.lua`
+--
+-- goftpd.site(name, fn(user, params)) adds SITE <name>, it requires the
+-- `site <name>` permission. goftpd.reply(text) adds a line to the reply,
+-- the function can also return the last line. error(...) fails the
+-- command.
+--
+-- goftpd.on(event, fn(event)) runs on upload, download, makedir and
+-- delete. the event has event, user, group, path, size and crc.
+--
+-- goftpd.user(name) and goftpd.stat(user, path) return a table or nil and
+-- an error. goftpd.credits(name, bytes) adds credits, negative takes them.
+
+goftpd.site("credits", function(user, params)
+	local name = params[1] or user.name
+
+	local u, err = goftpd.user(name)
+	if u == nil then
+		return err
+	end
+
+	return string.format("%s has %d MB of credits", u.name, math.floor(u.credits / 1024 / 1024))
+end)
+
+-- a bonus of 10% of every upload to /mp3
+goftpd.on("upload", function(event)
+	if string.find(event.path, "^/mp3/") then
+		goftpd.credits(event.user, math.floor(event.size / 10))
+	end
+end)
@@ -0,0 +1,89 @@
+package sitebot
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/vfs"
+)
+
+func checkErr(t *testing.T, got, expected error) {
+	t.Helper()
+
+	if got == nil {
+		if expected != nil {
+			t.Fatalf("expected '%s' but got nil", expected)
+		}
+		return
+	}
+
+	if expected == nil {
+		t.Fatalf("expected nil but got '%s'", got)
+	}
+
+	if got.Error() != expected.Error() {
+		t.Fatalf("expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func newMemoryDB(t *testing.T) *badger.DB {
+	t.Helper()
+
+	opt := badger.DefaultOptions("").WithInMemory(true)
+	opt.Logger = nil
+
+	db, err := badger.Open(opt)
+	if err != nil {
+		t.Fatalf("error opening db: %s", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newMemoryAuthenticator(t *testing.T) *acl.BadgerAuthenticator {
+	t.Helper()
+
+	return acl.NewBadgerAuthenticator(&acl.AuthenticatorOpts{}, newMemoryDB(t))
+}
+
+func newMemoryFilesystem(t *testing.T, lines []string) *vfs.Filesystem {
+	t.Helper()
+
+	var rules []acl.Rule
+	for _, l := range lines {
+		r, err := acl.NewRule(l)
+		if err != nil {
+			t.Fatalf("unexpected error creating NewRule: %s", err)
+		}
+		rules = append(rules, r)
+	}
+
+	perms, err := acl.NewPermissions(rules)
+	if err != nil {
+		t.Fatalf("unexpected error creating Permissions: %s", err)
+	}
+
+	opts := vfs.FilesystemOpts{
+		DefaultUser:  "nobody",
+		DefaultGroup: "nogroup",
+	}
+
+	fs, err := vfs.NewFilesystem(&opts, memfs.New(), vfs.NewShadowStore(newMemoryDB(t)), perms)
+	if err != nil {
+		t.Fatalf("unexpected error creating NewFilesystem: %s", err)
+	}
+
+	return fs
+}
+
+// testControl implements Control with a fixed set of sessions
+type testControl struct {
+	sessions []cmd.SessionInfo
+}
+
+func (c *testControl) Sessions() []cmd.SessionInfo { return c.sessions }
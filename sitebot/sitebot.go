@@ -0,0 +1,553 @@
+// Package sitebot serves a line protocol on a unix or tcp socket for
+// sitebots to poll who is online, new releases, dupes and stats, so the
+// eggdrop scripts of glftpd sites can be pointed at goftpd.
+//
+// Each request is a line, `<command> [<params>]`. A reply is zero or more
+// data lines followed by `OK`, or `ERR <message>` on failure. Every data
+// line is a Tcl list of double quoted fields so scripts can use lindex on
+// it directly:
+//
+//	AUTH <token>
+//	WHO                          "<user>" "<group>" "<tagline>" "<ident>" "<ip>" "<UP|DN|CMD|IDLE>" "<path>" "<bytes>" "<bytes/s>" "<idle seconds>"
+//	BW                           "<uploads>" "<upload bytes/s>" "<downloads>" "<download bytes/s>" "<sessions>"
+//	NEW [<section>] [<limit>]    "<section>" "<path>" "<user>" "<group>" "<unix time>"
+//	DUPE [-<limit>] <pattern>    "<path>" "<user>" "<unix time>" "<dir, 1 or 0>"
+//	STATS <user> [<period>]      "<section>" "<upload bytes>" "<upload files>" "<download bytes>" "<download files>"
+//	QUIT
+//
+// AUTH is required first when a token is set
+package sitebot
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/dirlog"
+	"github.com/goftpd/goftpd/dupe"
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/goftpd/goftpd/stats"
+	"github.com/goftpd/goftpd/vfs"
+	"github.com/pkg/errors"
+)
+
+// defaults used when no limit is given
+const (
+	defaultNewLimit  = 10
+	defaultDupeLimit = 10
+	maxLimit         = 100
+)
+
+// maxLineLength is the longest request read, longer requests close the
+// connection
+const maxLineLength = 1024
+
+// Control is what the sitebot needs of the FTP server
+type Control interface {
+	Sessions() []cmd.SessionInfo
+}
+
+type SitebotOpts struct {
+	// path of a unix socket to listen on
+	Socket string `goftpd:"socket"`
+	// a tcp port to listen on
+	Host string `goftpd:"host"`
+	Port int    `goftpd:"port"`
+	// required over tcp, clients send `AUTH <token>` first
+	Token string `goftpd:"token"`
+
+	logger logging.Logger
+}
+
+// Enabled checks to see if the sitebot should be served
+func (o *SitebotOpts) Enabled() bool { return len(o.Socket) > 0 || o.Port > 0 }
+
+// SetLogger sets where bots coming and going are logged, defaults to
+// logging.Stderr
+func (o *SitebotOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where bots coming and going are logged
+func (o *SitebotOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// Server answers the requests of sitebots
+type Server struct {
+	*SitebotOpts
+
+	fs    vfs.VFS
+	auth  acl.Authenticator
+	stats stats.Stats
+	dupes dupe.Dupe
+	dirs  dirlog.Dirlog
+
+	mtx       sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+}
+
+// NewServer returns a Server answering from the VFS, Authenticator, Stats,
+// Dupe and Dirlog. Fails if listening on tcp without a token
+func NewServer(opts *SitebotOpts, fs vfs.VFS, auth acl.Authenticator, st stats.Stats, dupes dupe.Dupe, dirs dirlog.Dirlog) (*Server, error) {
+	if opts.Port > 0 && len(opts.Token) == 0 {
+		return nil, errors.New("sitebot token required to listen on tcp")
+	}
+
+	s := Server{
+		SitebotOpts: opts,
+		fs:          fs,
+		auth:        auth,
+		stats:       st,
+		dupes:       dupes,
+		dirs:        dirs,
+		conns:       make(map[net.Conn]struct{}),
+	}
+
+	return &s, nil
+}
+
+// ListenAndServe listens on the configured Socket and Port until the
+// context is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, control Control) error {
+	if len(s.Socket) > 0 {
+		// a socket left behind by an unclean shutdown
+		if err := os.Remove(s.Socket); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		l, err := net.Listen("unix", s.Socket)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Chmod(s.Socket, 0660); err != nil {
+			l.Close()
+			return err
+		}
+
+		s.listeners = append(s.listeners, l)
+	}
+
+	if s.Port > 0 {
+		l, err := net.Listen("tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+		if err != nil {
+			s.close()
+			return err
+		}
+
+		s.listeners = append(s.listeners, l)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.close()
+	}()
+
+	errs := make(chan error, len(s.listeners))
+
+	for _, l := range s.listeners {
+		go func(l net.Listener) {
+			errs <- s.Serve(l, control)
+		}(l)
+	}
+
+	var err error
+
+	for range s.listeners {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			s.close()
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// Serve answers the bots connecting to the listener until it is closed
+func (s *Server) Serve(l net.Listener, control Control) error {
+	// a token is only needed over tcp
+	_, local := l.Addr().(*net.UnixAddr)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if isClosed(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !s.add(conn) {
+			conn.Close()
+			return nil
+		}
+
+		go func() {
+			defer s.remove(conn)
+			s.handle(conn, control, local || len(s.Token) == 0)
+		}()
+	}
+}
+
+// handle answers requests until the bot quits or disconnects
+func (s *Server) handle(conn net.Conn, control Control, authed bool) {
+	remote := conn.RemoteAddr().String()
+
+	s.Logger().Debug("sitebot connected", logging.IP(remote))
+
+	r := bufio.NewReaderSize(conn, maxLineLength)
+	w := bufio.NewWriter(conn)
+
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			if err != io.EOF && !isClosed(err) {
+				s.Logger().Debug("sitebot read failed", logging.IP(remote), logging.Err(err))
+			}
+			break
+		}
+
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		command := strings.ToUpper(fields[0])
+
+		if command == "QUIT" {
+			writeOK(w)
+			w.Flush()
+			break
+		}
+
+		switch {
+		case command == "AUTH":
+			authed = len(fields) == 2 && subtle.ConstantTimeCompare([]byte(fields[1]), []byte(s.Token)) == 1
+			if !authed {
+				writeErr(w, errors.New("bad token"))
+				break
+			}
+			writeOK(w)
+
+		case !authed:
+			writeErr(w, errors.New("AUTH required"))
+
+		default:
+			s.answer(w, control, command, fields[1:])
+		}
+
+		if err := w.Flush(); err != nil {
+			break
+		}
+	}
+
+	s.Logger().Debug("sitebot disconnected", logging.IP(remote))
+}
+
+// answer writes the reply to the command
+func (s *Server) answer(w *bufio.Writer, control Control, command string, params []string) {
+	var err error
+
+	switch command {
+	case "WHO":
+		err = s.who(w, control)
+	case "BW":
+		err = s.bw(w, control)
+	case "NEW":
+		err = s.new(w, params)
+	case "DUPE":
+		err = s.dupe(w, params)
+	case "STATS":
+		err = s.userStats(w, params)
+	default:
+		err = errors.Errorf("unknown command '%s'", command)
+	}
+
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	writeOK(w)
+}
+
+// hidden checks to see if the session is somewhere hidden from SITE WHO for
+// anyone without an exception, bots can be seen by anyone on the channel
+func (s *Server) hidden(info cmd.SessionInfo) bool {
+	anyone := &acl.User{}
+	return s.fs.HideInWho(info.CWD, anyone) || s.fs.HideInWho(info.Path(), anyone)
+}
+
+func (s *Server) who(w io.Writer, control Control) error {
+	for _, info := range control.Sessions() {
+		if info.State != cmd.SessionStateLoggedIn || s.hidden(info) {
+			continue
+		}
+
+		name, group, tagline := info.Login, "", ""
+
+		if info.Anonymous {
+			name = "anonymous"
+		} else if u, err := s.auth.GetUser(info.Login); err == nil {
+			group, tagline = u.PrimaryGroup, u.Tagline
+		}
+
+		action := string(info.Transfer)
+		switch {
+		case info.Transfer != cmd.TransferNone:
+		case len(info.Command) > 0:
+			action = "CMD"
+		default:
+			action = "IDLE"
+		}
+
+		writeLine(w,
+			name,
+			group,
+			tagline,
+			info.Ident,
+			info.IP,
+			action,
+			info.Path(),
+			strconv.FormatInt(info.Bytes, 10),
+			strconv.FormatInt(int64(info.Speed), 10),
+			strconv.FormatInt(int64(info.Idle.Seconds()), 10),
+		)
+	}
+
+	return nil
+}
+
+func (s *Server) bw(w io.Writer, control Control) error {
+	var (
+		uploads, downloads int
+		up, down           float64
+	)
+
+	infos := control.Sessions()
+
+	for _, info := range infos {
+		switch info.Transfer {
+		case cmd.TransferUpload:
+			uploads++
+			up += info.Speed
+		case cmd.TransferDownload:
+			downloads++
+			down += info.Speed
+		}
+	}
+
+	writeLine(w,
+		strconv.Itoa(uploads),
+		strconv.FormatInt(int64(up), 10),
+		strconv.Itoa(downloads),
+		strconv.FormatInt(int64(down), 10),
+		strconv.Itoa(len(infos)),
+	)
+
+	return nil
+}
+
+func (s *Server) new(w io.Writer, params []string) error {
+	var section string
+
+	limit := defaultNewLimit
+
+	for _, p := range params {
+		if n, err := strconv.Atoi(p); err == nil {
+			limit = n
+			continue
+		}
+		section = p
+	}
+
+	if limit < 1 || limit > maxLimit {
+		return errors.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+
+	if len(section) > 0 {
+		var found bool
+		for _, name := range s.dirs.Sections() {
+			found = found || strings.EqualFold(name, section)
+		}
+
+		if !found {
+			return errors.Errorf("unknown section '%s'", section)
+		}
+	}
+
+	entries, err := s.dirs.Latest(section, limit)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		writeLine(w, e.Section, e.Path, e.User, e.Group, strconv.FormatInt(e.Time.Unix(), 10))
+	}
+
+	return nil
+}
+
+func (s *Server) dupe(w io.Writer, params []string) error {
+	limit := defaultDupeLimit
+
+	if len(params) > 0 && strings.HasPrefix(params[0], "-") {
+		n, err := strconv.Atoi(params[0][1:])
+		if err != nil || n < 1 || n > maxLimit {
+			return errors.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+
+		limit, params = n, params[1:]
+	}
+
+	if len(params) == 0 {
+		return errors.New("pattern required")
+	}
+
+	entries, err := s.dupes.Search(strings.Join(params, " "), limit)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		dir := "0"
+		if e.Dir {
+			dir = "1"
+		}
+
+		writeLine(w, e.Path, e.User, strconv.FormatInt(e.Time.Unix(), 10), dir)
+	}
+
+	return nil
+}
+
+func (s *Server) userStats(w io.Writer, params []string) error {
+	if len(params) == 0 || len(params) > 2 {
+		return errors.New("expected STATS <user> [<period>]")
+	}
+
+	period := stats.Period(stats.PeriodAllTime)
+
+	if len(params) == 2 {
+		var found bool
+		for _, p := range stats.Periods {
+			if strings.EqualFold(string(p), params[1]) {
+				period, found = p, true
+			}
+		}
+
+		if !found {
+			return errors.Errorf("unknown period '%s'", params[1])
+		}
+	}
+
+	u, err := s.auth.GetUser(params[0])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, section := range append(s.stats.Sections(), stats.DefaultSection, stats.AllSections) {
+		totals, err := s.stats.Get(u.Name, section, period, now)
+		if err != nil {
+			return err
+		}
+
+		if section != stats.AllSections && totals == (stats.Totals{}) {
+			continue
+		}
+
+		writeLine(w,
+			section,
+			strconv.FormatInt(totals.UploadBytes, 10),
+			strconv.FormatInt(totals.UploadFiles, 10),
+			strconv.FormatInt(totals.DownloadBytes, 10),
+			strconv.FormatInt(totals.DownloadFiles, 10),
+		)
+	}
+
+	return nil
+}
+
+// writeLine writes the fields as a Tcl list of double quoted words
+func writeLine(w io.Writer, fields ...string) {
+	quoted := make([]string, len(fields))
+
+	for i, f := range fields {
+		f = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", " ", "\n", " ").Replace(f)
+		quoted[i] = `"` + f + `"`
+	}
+
+	fmt.Fprintf(w, "%s\n", strings.Join(quoted, " "))
+}
+
+func writeOK(w io.Writer) {
+	io.WriteString(w, "OK\n")
+}
+
+func writeErr(w io.Writer, err error) {
+	fmt.Fprintf(w, "ERR %s\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error()))
+}
+
+func (s *Server) add(conn net.Conn) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.conns == nil {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (s *Server) remove(conn net.Conn) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	conn.Close()
+
+	if s.conns != nil {
+		delete(s.conns, conn)
+	}
+}
+
+// close stops listening and disconnects every bot
+func (s *Server) close() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, l := range s.listeners {
+		l.Close()
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
+
+// isClosed checks to see if the error is from using a closed connection
+func isClosed(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
@@ -0,0 +1,204 @@
+package sitebot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+)
+
+const testToken = "secret"
+
+// newTestConn answers requests over an in memory connection
+func newTestConn(t *testing.T, s *Server, control Control, authed bool) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	client, server := net.Pipe()
+
+	go func() {
+		defer server.Close()
+		s.handle(server, control, authed)
+	}()
+
+	t.Cleanup(func() { client.Close() })
+
+	return client, bufio.NewReader(client)
+}
+
+// request sends the line and returns the data lines of the reply along with
+// its final line
+func request(t *testing.T, conn net.Conn, r *bufio.Reader, line string) ([]string, string) {
+	t.Helper()
+
+	if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+		t.Fatalf("unexpected error writing request: %s", err)
+	}
+
+	var data []string
+
+	for {
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected error reading reply: %s", err)
+		}
+
+		reply = strings.TrimSuffix(reply, "\n")
+
+		if reply == "OK" || strings.HasPrefix(reply, "ERR ") {
+			return data, reply
+		}
+
+		data = append(data, reply)
+	}
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	_, err := NewServer(&SitebotOpts{Port: 1}, nil, nil, nil, nil, nil)
+	checkErr(t, err, errors.New("sitebot token required to listen on tcp"))
+
+	_, err = NewServer(&SitebotOpts{Socket: "sitebot.sock"}, nil, nil, nil, nil, nil)
+	checkErr(t, err, nil)
+}
+
+func TestAuth(t *testing.T) {
+	s, err := NewServer(&SitebotOpts{Port: 1, Token: testToken}, nil, nil, nil, nil, nil)
+	checkErr(t, err, nil)
+
+	conn, r := newTestConn(t, s, &testControl{}, false)
+
+	if _, reply := request(t, conn, r, "BW"); reply != "ERR AUTH required" {
+		t.Fatalf("expected AUTH required but got '%s'", reply)
+	}
+
+	if _, reply := request(t, conn, r, "AUTH wrong"); reply != "ERR bad token" {
+		t.Fatalf("expected bad token but got '%s'", reply)
+	}
+
+	if _, reply := request(t, conn, r, "AUTH "+testToken); reply != "OK" {
+		t.Fatalf("expected OK but got '%s'", reply)
+	}
+
+	if _, reply := request(t, conn, r, "BW"); reply != "OK" {
+		t.Fatalf("expected OK but got '%s'", reply)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	s, err := NewServer(&SitebotOpts{}, nil, nil, nil, nil, nil)
+	checkErr(t, err, nil)
+
+	conn, r := newTestConn(t, s, &testControl{}, true)
+
+	if _, reply := request(t, conn, r, "NUKE"); reply != "ERR unknown command 'NUKE'" {
+		t.Fatalf("expected unknown command but got '%s'", reply)
+	}
+
+	if _, reply := request(t, conn, r, "QUIT"); reply != "OK" {
+		t.Fatalf("expected OK but got '%s'", reply)
+	}
+
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatal("expected the connection to be closed after QUIT")
+	}
+}
+
+func TestWho(t *testing.T) {
+	auth := newMemoryAuthenticator(t)
+
+	u, err := auth.AddUser("user", "password")
+	checkErr(t, err, nil)
+
+	u.PrimaryGroup = "group"
+	u.Tagline = `say "hi"`
+	checkErr(t, auth.SaveUser(u), nil)
+
+	fs := newMemoryFilesystem(t, []string{"hideinwho /staff/** *"})
+
+	s, err := NewServer(&SitebotOpts{}, fs, auth, nil, nil, nil)
+	checkErr(t, err, nil)
+
+	control := &testControl{
+		sessions: []cmd.SessionInfo{
+			{
+				Login:    "user",
+				Ident:    "ident",
+				IP:       "127.0.0.1",
+				State:    cmd.SessionStateLoggedIn,
+				CWD:      "/incoming",
+				File:     "file.rar",
+				Transfer: cmd.TransferUpload,
+				Bytes:    2048,
+				Speed:    1024,
+				Idle:     3 * time.Second,
+			},
+			{
+				Login: "staff",
+				State: cmd.SessionStateLoggedIn,
+				CWD:   "/staff/private",
+			},
+			{
+				Login: "connecting",
+				State: cmd.SessionStateAuth,
+				CWD:   "/",
+			},
+		},
+	}
+
+	conn, r := newTestConn(t, s, control, true)
+
+	data, reply := request(t, conn, r, "WHO")
+	if reply != "OK" {
+		t.Fatalf("expected OK but got '%s'", reply)
+	}
+
+	expected := []string{
+		`"user" "group" "say \"hi\"" "ident" "127.0.0.1" "UP" "/incoming/file.rar" "2048" "1024" "3"`,
+	}
+
+	if strings.Join(data, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q but got %q", expected, data)
+	}
+}
+
+func TestBW(t *testing.T) {
+	s, err := NewServer(&SitebotOpts{}, nil, nil, nil, nil, nil)
+	checkErr(t, err, nil)
+
+	control := &testControl{
+		sessions: []cmd.SessionInfo{
+			{Transfer: cmd.TransferUpload, Speed: 100},
+			{Transfer: cmd.TransferUpload, Speed: 50},
+			{Transfer: cmd.TransferDownload, Speed: 25},
+			{Transfer: cmd.TransferNone},
+		},
+	}
+
+	conn, r := newTestConn(t, s, control, true)
+
+	data, reply := request(t, conn, r, "bw")
+	if reply != "OK" {
+		t.Fatalf("expected OK but got '%s'", reply)
+	}
+
+	if len(data) != 1 || data[0] != `"2" "150" "1" "25" "4"` {
+		t.Fatalf("unexpected bw %q", data)
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	var b bytes.Buffer
+
+	writeLine(&b, "plain", `back\slash`, `"quoted"`, "new\nline", "")
+
+	expected := `"plain" "back\\slash" "\"quoted\"" "new line" ""` + "\n"
+
+	if b.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, b.String())
+	}
+}
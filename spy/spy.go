@@ -0,0 +1,397 @@
+// Package spy streams what is happening on the site, logins, transfers in
+// progress and finished transfers, to WebSocket clients as JSON so live
+// dashboards can be built in the style of glftpd's webspy tools
+package spy
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// events sent to clients
+const (
+	EventLogin  = "login"
+	EventLogout = "logout"
+	// a transfer in progress, sent for each every progress seconds
+	EventTransfer = "transfer"
+	// a finished transfer
+	EventUpload   = "upload"
+	EventDownload = "download"
+	// every file of a release has been uploaded
+	EventRace = "race"
+)
+
+// defaults used when options are zero
+const (
+	defaultPath      = "/spy"
+	defaultProgress  = 1
+	defaultQueueSize = 256
+)
+
+// writeTimeout is how long a client has to accept a message before it is
+// disconnected
+const writeTimeout = 10 * time.Second
+
+// Event is the JSON message sent to clients
+type Event struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	// the id of the session the event is about
+	Session uint64 `json:"session,omitempty"`
+	User    string `json:"user,omitempty"`
+	Group   string `json:"group,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	// bytes per second
+	Speed float64 `json:"speed,omitempty"`
+	// event specific details, i.e. the direction of a transfer
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Feed publishes events to whoever is listening
+type Feed interface {
+	Publish(Event)
+	Close() error
+}
+
+// Control is what the Hub needs of the FTP server
+type Control interface {
+	Sessions() []cmd.SessionInfo
+	TLSConfig() *tls.Config
+}
+
+type SpyOpts struct {
+	// the feed is only served when a port is set
+	Host string `goftpd:"host"`
+	Port int    `goftpd:"port"`
+	// the url path of the websocket endpoint, defaults to /spy
+	Path string `goftpd:"path"`
+	// required, clients send it as `?token=<token>` or as
+	// `Authorization: Bearer <token>`
+	Token string `goftpd:"token"`
+	// serve using the FTP server's certificate
+	TLS bool `goftpd:"tls"`
+	// seconds between transfer events, zero uses 1
+	Progress int `goftpd:"progress"`
+	// events waiting to be sent to a client, a client falling further
+	// behind is disconnected. zero uses 256
+	QueueSize int `goftpd:"queue_size"`
+
+	logger logging.Logger
+}
+
+// Enabled checks to see if the feed should be served
+func (o *SpyOpts) Enabled() bool { return o.Port > 0 }
+
+// SetLogger sets where clients coming and going are logged, defaults to
+// logging.Stderr
+func (o *SpyOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where clients coming and going are logged
+func (o *SpyOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// client is a connected websocket
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	ip   string
+}
+
+// Hub implements Feed, every event is sent to every connected client
+type Hub struct {
+	*SpyOpts
+
+	upgrader websocket.Upgrader
+
+	mtx     sync.RWMutex
+	closed  bool
+	clients map[*client]struct{}
+}
+
+// NewHub returns a Hub using the options. Nothing is listened on until
+// ListenAndServe, until then published events are dropped
+func NewHub(opts *SpyOpts) (*Hub, error) {
+	if opts.Enabled() && len(opts.Token) == 0 {
+		return nil, errors.New("spy token required")
+	}
+
+	if len(opts.Path) == 0 {
+		opts.Path = defaultPath
+	}
+
+	if !strings.HasPrefix(opts.Path, "/") {
+		opts.Path = "/" + opts.Path
+	}
+
+	if opts.Progress == 0 {
+		opts.Progress = defaultProgress
+	}
+
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	h := Hub{
+		SpyOpts: opts,
+		upgrader: websocket.Upgrader{
+			// dashboards are often served from elsewhere, the token
+			// is what keeps the feed private
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		clients: make(map[*client]struct{}),
+	}
+
+	return &h, nil
+}
+
+// Publish sends the event to every client, a client that can't keep up is
+// disconnected
+func (h *Hub) Publish(e Event) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	if h.closed || len(h.clients) == 0 {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		h.Logger().Error("unable to marshal spy event", logging.F("event", e.Event), logging.Err(err))
+		return
+	}
+
+	for c := range h.clients {
+		select {
+		case c.send <- b:
+		default:
+			// closing the connection ends the client's read loop
+			// which removes it
+			c.conn.Close()
+		}
+	}
+}
+
+// ServeHTTP upgrades the request to a websocket once the token is checked
+// and sends it events until either side closes
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "bad or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied
+		return
+	}
+
+	c := &client{
+		conn: conn,
+		send: make(chan []byte, h.QueueSize),
+		ip:   r.RemoteAddr,
+	}
+
+	if host, _, err := net.SplitHostPort(c.ip); err == nil {
+		c.ip = host
+	}
+
+	if !h.add(c) {
+		conn.Close()
+		return
+	}
+
+	h.Logger().Info("spy client connected", logging.IP(c.ip))
+
+	go h.write(c)
+
+	// clients aren't expected to send anything, reading is how a close
+	// is noticed
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			break
+		}
+	}
+
+	h.remove(c)
+
+	h.Logger().Info("spy client disconnected", logging.IP(c.ip))
+}
+
+// authorized checks the token sent in the query or Authorization header
+func (h *Hub) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+
+	if auth := r.Header.Get("Authorization"); len(auth) > 0 {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *Hub) add(c *client) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	h.clients[c] = struct{}{}
+
+	return true
+}
+
+func (h *Hub) remove(c *client) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+
+	delete(h.clients, c)
+	close(c.send)
+}
+
+// write sends the client its queued events until the queue is closed
+func (h *Hub) write(c *client) {
+	defer c.conn.Close()
+
+	for b := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+		if err := c.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			return
+		}
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+}
+
+// progress publishes a transfer event for every transfer in progress
+func (h *Hub) progress(control Control) {
+	h.mtx.RLock()
+	idle := len(h.clients) == 0
+	h.mtx.RUnlock()
+
+	if idle {
+		return
+	}
+
+	for _, info := range control.Sessions() {
+		if info.Transfer == cmd.TransferNone {
+			continue
+		}
+
+		h.Publish(Event{
+			Event:   EventTransfer,
+			Session: info.ID,
+			User:    info.Login,
+			IP:      info.IP,
+			Path:    info.Path(),
+			Bytes:   info.Bytes,
+			Speed:   info.Speed,
+			Data: map[string]interface{}{
+				"direction": string(info.Transfer),
+			},
+		})
+	}
+}
+
+// ListenAndServe listens on the configured Host and Port, sending transfer
+// events every Progress seconds, until the context is cancelled
+func (h *Hub) ListenAndServe(ctx context.Context, control Control) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(h.Host, fmt.Sprintf("%d", h.Port)))
+	if err != nil {
+		return err
+	}
+
+	if h.TLS {
+		if control.TLSConfig() == nil {
+			l.Close()
+			return errors.New("spy tls requires the server's tls certificate")
+		}
+
+		l = tls.NewListener(l, control.TLSConfig())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(h.Path, h)
+
+	srv := http.Server{
+		Handler: mux,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(h.Progress) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.progress(control)
+
+			case <-ctx.Done():
+				// hijacked websockets aren't closed by Shutdown
+				h.Close()
+				srv.Shutdown(context.Background())
+				return
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Close disconnects every client, once closed events are ignored
+func (h *Hub) Close() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	h.closed = true
+
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.send)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package spy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goftpd/goftpd/ftp/cmd"
+	"github.com/goftpd/goftpd/logging"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const testToken = "secret"
+
+type testControl struct {
+	sessions []cmd.SessionInfo
+}
+
+func (c *testControl) Sessions() []cmd.SessionInfo { return c.sessions }
+
+func (c *testControl) TLSConfig() *tls.Config { return nil }
+
+func newTestHub(t *testing.T) (*Hub, *httptest.Server) {
+	t.Helper()
+
+	opts := SpyOpts{Port: 1, Token: testToken}
+	opts.SetLogger(logging.NewWriterLogger(ioutil.Discard, logging.LevelError, logging.FormatConsole))
+
+	h, err := NewHub(&opts)
+	checkErr(t, err, nil)
+
+	srv := httptest.NewServer(h)
+
+	t.Cleanup(func() {
+		h.Close()
+		srv.Close()
+	})
+
+	return h, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server, token string) (*websocket.Conn, error) {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/spy?token=" + token
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+
+	return conn, err
+}
+
+// waitForClients waits until the hub has n clients as they are added after
+// the handshake completes
+func waitForClients(t *testing.T, h *Hub, n int) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		h.mtx.RLock()
+		got := len(h.clients)
+		h.mtx.RUnlock()
+
+		if got == n {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d clients", n)
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) Event {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var e Event
+
+	if err := conn.ReadJSON(&e); err != nil {
+		t.Fatalf("unexpected error reading event: %s", err)
+	}
+
+	return e
+}
+
+func TestNewHubRequiresToken(t *testing.T) {
+	_, err := NewHub(&SpyOpts{Port: 1})
+	checkErr(t, err, errors.New("spy token required"))
+
+	// the feed isn't served so no token is needed
+	_, err = NewHub(&SpyOpts{})
+	checkErr(t, err, nil)
+}
+
+func TestToken(t *testing.T) {
+	_, srv := newTestHub(t)
+
+	_, err := dial(t, srv, "wrong")
+	checkErr(t, err, websocket.ErrBadHandshake)
+
+	_, err = dial(t, srv, "")
+	checkErr(t, err, websocket.ErrBadHandshake)
+
+	_, err = dial(t, srv, testToken)
+	checkErr(t, err, nil)
+}
+
+func TestPublish(t *testing.T) {
+	h, srv := newTestHub(t)
+
+	a, err := dial(t, srv, testToken)
+	checkErr(t, err, nil)
+
+	b, err := dial(t, srv, testToken)
+	checkErr(t, err, nil)
+
+	waitForClients(t, h, 2)
+
+	h.Publish(Event{Event: EventLogin, Session: 1, User: "bob", IP: "10.0.0.1"})
+
+	for _, conn := range []*websocket.Conn{a, b} {
+		e := readEvent(t, conn)
+
+		if e.Event != EventLogin || e.Session != 1 || e.User != "bob" || e.IP != "10.0.0.1" || e.Time.IsZero() {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	}
+
+	a.Close()
+
+	waitForClients(t, h, 1)
+}
+
+func TestProgress(t *testing.T) {
+	h, srv := newTestHub(t)
+
+	control := testControl{
+		sessions: []cmd.SessionInfo{
+			{ID: 1, Login: "bob", IP: "10.0.0.1", CWD: "/mp3", File: "a.mp3", Transfer: cmd.TransferUpload, Bytes: 1024, Speed: 512},
+			{ID: 2, Login: "alice", IP: "10.0.0.2", CWD: "/"},
+		},
+	}
+
+	conn, err := dial(t, srv, testToken)
+	checkErr(t, err, nil)
+
+	waitForClients(t, h, 1)
+
+	h.progress(&control)
+
+	e := readEvent(t, conn)
+
+	if e.Event != EventTransfer || e.Session != 1 || e.Path != "/mp3/a.mp3" || e.Bytes != 1024 || e.Speed != 512 {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+
+	if e.Data["direction"] != string(cmd.TransferUpload) {
+		t.Fatalf("unexpected direction: %v", e.Data["direction"])
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	b, err := json.Marshal(Event{Event: EventLogout, Time: time.Unix(0, 0).UTC(), User: "bob"})
+	checkErr(t, err, nil)
+
+	expected := `{"event":"logout","time":"1970-01-01T00:00:00Z","user":"bob"}`
+
+	if string(b) != expected {
+		t.Fatalf("expected '%s' but got '%s'", expected, b)
+	}
+}
+
+func TestClose(t *testing.T) {
+	h, srv := newTestHub(t)
+
+	conn, err := dial(t, srv, testToken)
+	checkErr(t, err, nil)
+
+	waitForClients(t, h, 1)
+
+	checkErr(t, h.Close(), nil)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected going away but got '%v'", err)
+	}
+
+	// published after closing is ignored
+	h.Publish(Event{Event: EventLogin})
+
+	waitForClients(t, h, 0)
+}
@@ -0,0 +1,357 @@
+// Package stats provides persistent per user transfer statistics broken
+// down by section and period
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// AllSections is the section that every transfer is recorded against
+// regardless of which section it matched
+const AllSections = "all"
+
+// DefaultSection is used when a transfer does not match any section
+const DefaultSection = "default"
+
+// maxRetries is the number of times a transaction is retried on conflict
+const maxRetries = 10
+
+// Direction of a transfer
+type Direction int
+
+const (
+	Upload Direction = iota
+	Download
+)
+
+// Period is an "enum" for the different periods stats are kept for
+type Period string
+
+const (
+	PeriodDay     Period = "day"
+	PeriodWeek           = "week"
+	PeriodMonth          = "month"
+	PeriodAllTime        = "alltime"
+)
+
+// Periods is every Period that is recorded
+var Periods = []Period{PeriodDay, PeriodWeek, PeriodMonth, PeriodAllTime}
+
+// key returns the part of the key identifying the period that t is in
+func (p Period) key(t time.Time) string {
+	switch p {
+	case PeriodDay:
+		return t.Format("2006-01-02")
+	case PeriodWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case PeriodMonth:
+		return t.Format("2006-01")
+	}
+	return string(PeriodAllTime)
+}
+
+// Totals are the accumulated transfers for a user, section and period
+type Totals struct {
+	UploadBytes   int64
+	UploadFiles   int64
+	DownloadBytes int64
+	DownloadFiles int64
+}
+
+// Merge adds the other Totals to t
+func (t *Totals) Merge(other Totals) {
+	t.UploadBytes += other.UploadBytes
+	t.UploadFiles += other.UploadFiles
+	t.DownloadBytes += other.DownloadBytes
+	t.DownloadFiles += other.DownloadFiles
+}
+
+// add the transfer to the Totals
+func (t *Totals) add(dir Direction, bytes int64) {
+	switch dir {
+	case Upload:
+		t.UploadBytes += bytes
+		t.UploadFiles++
+	case Download:
+		t.DownloadBytes += bytes
+		t.DownloadFiles++
+	}
+}
+
+// Stats records and retrieves transfer statistics
+type Stats interface {
+	Record(string, string, Direction, int64) error
+	Add(string, string, Period, time.Time, Totals) error
+	Get(string, string, Period, time.Time) (Totals, error)
+	Section(string) string
+	Sections() []string
+	Prune(time.Time) (int, error)
+	Close() error
+}
+
+type StatsOpts struct {
+	DB string `goftpd:"db"`
+}
+
+// Section groups paths together for stats, i.e. all of /mp3/** could
+// be the MP3 section
+type Section struct {
+	name string
+	g    glob.Glob
+}
+
+// NewSection takes a line of text (i.e. from a config file) in the form of
+// `<name> <glob>`
+func NewSection(line string) (Section, error) {
+	var section Section
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return section, errors.New("section requires 2 fields")
+	}
+
+	section.name = strings.ToLower(fields[0])
+
+	if section.name == AllSections || section.name == DefaultSection {
+		return section, errors.Errorf("section name '%s' is reserved", section.name)
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[1]), '/')
+	if err != nil {
+		return section, err
+	}
+
+	section.g = g
+
+	return section, nil
+}
+
+// BadgerStats implements Stats using a badger key/value store
+type BadgerStats struct {
+	*StatsOpts
+	db       *badger.DB
+	sections []Section
+
+	// serialises Record so that concurrent transfers don't
+	// constantly conflict, conflicts are still retried
+	mtx sync.Mutex
+}
+
+// NewBadgerStats takes in options, a badger DB and the sections to record against.
+// Sections are matched in order, first match wins
+func NewBadgerStats(opts *StatsOpts, db *badger.DB, sections []Section) *BadgerStats {
+	return &BadgerStats{
+		StatsOpts: opts,
+		db:        db,
+		sections:  sections,
+	}
+}
+
+// key creates the key for a user, section and period key
+func (s *BadgerStats) key(user, section string, period Period, t time.Time) []byte {
+	return []byte(fmt.Sprintf(
+		"stats:%s:%s:%s:%s",
+		strings.ToLower(user),
+		strings.ToLower(section),
+		period,
+		period.key(t),
+	))
+}
+
+// Section returns the name of the section the path belongs to
+func (s *BadgerStats) Section(path string) string {
+	path = strings.ToLower(path)
+
+	for _, section := range s.sections {
+		if section.g.Match(path) {
+			return section.name
+		}
+	}
+
+	return DefaultSection
+}
+
+// Sections returns the name of all configured sections
+func (s *BadgerStats) Sections() []string {
+	names := make([]string, 0, len(s.sections))
+	for _, section := range s.sections {
+		names = append(names, section.name)
+	}
+	return names
+}
+
+// Record adds a completed transfer to the user's stats for the section the path
+// belongs to as well as AllSections, for every Period. Updates are made in a single
+// transaction
+func (s *BadgerStats) Record(user, path string, dir Direction, bytes int64) error {
+	now := time.Now()
+	sections := []string{s.Section(path), AllSections}
+
+	return s.update(func(tx *badger.Txn) error {
+		for _, section := range sections {
+			for _, period := range Periods {
+				key := s.key(user, section, period, now)
+
+				totals, err := s.get(tx, key)
+				if err != nil {
+					return err
+				}
+
+				totals.add(dir, bytes)
+
+				if err := s.set(tx, key, totals); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Add merges totals in to the user's stats for the section and the period that t
+// is in, i.e. when importing stats from another server
+func (s *BadgerStats) Add(user, section string, period Period, t time.Time, totals Totals) error {
+	key := s.key(user, section, period, t)
+
+	return s.update(func(tx *badger.Txn) error {
+		existing, err := s.get(tx, key)
+		if err != nil {
+			return err
+		}
+
+		existing.Merge(totals)
+
+		return s.set(tx, key, existing)
+	})
+}
+
+// update runs fn in a read-write transaction, retrying if the transaction conflicts
+func (s *BadgerStats) update(fn func(*badger.Txn) error) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var err error
+
+	for i := 0; i < maxRetries; i++ {
+		err = s.db.Update(fn)
+
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Get retrieves the Totals for the user, section and the period that t is in
+func (s *BadgerStats) Get(user, section string, period Period, t time.Time) (Totals, error) {
+	var totals Totals
+
+	err := s.db.View(func(tx *badger.Txn) error {
+		var err error
+		totals, err = s.get(tx, s.key(user, section, period, t))
+		return err
+	})
+
+	return totals, err
+}
+
+// get decodes the Totals at key, missing keys are empty Totals
+func (s *BadgerStats) get(tx *badger.Txn, key []byte) (Totals, error) {
+	var totals Totals
+
+	item, err := tx.Get(key)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return totals, nil
+		}
+		return totals, err
+	}
+
+	err = item.Value(func(val []byte) error {
+		return msgpack.Unmarshal(val, &totals)
+	})
+
+	return totals, err
+}
+
+// set encodes the Totals at key
+func (s *BadgerStats) set(tx *badger.Txn, key []byte, totals Totals) error {
+	var b bytes.Buffer
+
+	if err := msgpack.NewEncoder(&b).Encode(totals); err != nil {
+		return err
+	}
+
+	return tx.Set(key, b.Bytes())
+}
+
+// Prune removes the day, week and month Totals of periods that ended
+// before t, all time Totals are kept. Returns how many were removed
+func (s *BadgerStats) Prune(t time.Time) (int, error) {
+	var keys [][]byte
+
+	err := s.db.View(func(tx *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte("stats:")
+
+		it := tx.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			parts := strings.Split(string(it.Item().Key()), ":")
+			if len(parts) < 5 {
+				continue
+			}
+
+			period := Period(parts[len(parts)-2])
+			if period == PeriodAllTime {
+				continue
+			}
+
+			// period keys sort in time order
+			if parts[len(parts)-1] < period.key(t) {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Close closes the underlying badger store
+func (s *BadgerStats) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewSection(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"mp3 /mp3/**", nil},
+		{"mp3", errors.New("section requires 2 fields")},
+		{"all /**", errors.New("section name 'all' is reserved")},
+		{"bad /[", errors.New("unexpected end of input")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewSection(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestSection(t *testing.T) {
+	s := newMemoryStats(t, "mp3 /mp3/**", "0day /0DAY/**")
+	defer closeMemoryStats(t, s)
+
+	var tests = []struct {
+		path     string
+		expected string
+	}{
+		{"/mp3/release/file.mp3", "mp3"},
+		{"/0day/release/file.zip", "0day"},
+		{"/other/file", DefaultSection},
+	}
+
+	for _, tt := range tests {
+		if got := s.Section(tt.path); got != tt.expected {
+			t.Errorf("expected section for '%s' to be '%s' got '%s'", tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s := newMemoryStats(t, "mp3 /mp3/**")
+	defer closeMemoryStats(t, s)
+
+	checkErr(t, s.Record("user", "/mp3/a/file.mp3", Upload, 100), nil)
+	checkErr(t, s.Record("USER", "/mp3/a/other.mp3", Upload, 50), nil)
+	checkErr(t, s.Record("user", "/other/file", Download, 10), nil)
+
+	now := time.Now()
+
+	for _, period := range Periods {
+		totals, err := s.Get("user", "mp3", period, now)
+		checkErr(t, err, nil)
+
+		if totals.UploadBytes != 150 || totals.UploadFiles != 2 {
+			t.Errorf("unexpected mp3 %s totals: %+v", period, totals)
+		}
+
+		if totals.DownloadBytes != 0 || totals.DownloadFiles != 0 {
+			t.Errorf("unexpected mp3 %s totals: %+v", period, totals)
+		}
+
+		totals, err = s.Get("user", AllSections, period, now)
+		checkErr(t, err, nil)
+
+		if totals.UploadBytes != 150 || totals.DownloadBytes != 10 || totals.DownloadFiles != 1 {
+			t.Errorf("unexpected all %s totals: %+v", period, totals)
+		}
+	}
+
+	// previous day should be empty
+	totals, err := s.Get("user", AllSections, PeriodDay, now.AddDate(0, 0, -1))
+	checkErr(t, err, nil)
+
+	if totals != (Totals{}) {
+		t.Errorf("expected empty totals for yesterday got: %+v", totals)
+	}
+}
+
+func TestRecordConcurrent(t *testing.T) {
+	s := newMemoryStats(t)
+	defer closeMemoryStats(t, s)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.Record("user", fmt.Sprintf("/file%d", i), Download, 1); err != nil {
+				t.Errorf("unexpected error recording: %s", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	totals, err := s.Get("user", AllSections, PeriodAllTime, time.Now())
+	checkErr(t, err, nil)
+
+	if totals.DownloadFiles != 20 || totals.DownloadBytes != 20 {
+		t.Errorf("expected 20 downloads got: %+v", totals)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	s := newMemoryStats(t)
+	defer closeMemoryStats(t, s)
+
+	now := time.Now()
+
+	checkErr(t, s.Record("user", "/file", Upload, 100), nil)
+	checkErr(t, s.Add("user", AllSections, PeriodAllTime, now, Totals{UploadBytes: 1000, UploadFiles: 10}), nil)
+
+	totals, err := s.Get("user", AllSections, PeriodAllTime, now)
+	checkErr(t, err, nil)
+
+	if totals.UploadBytes != 1100 || totals.UploadFiles != 11 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := newMemoryStats(t)
+	defer closeMemoryStats(t, s)
+
+	now := time.Date(2020, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, -2, 0)
+
+	totals := Totals{UploadBytes: 100, UploadFiles: 1}
+
+	for _, period := range Periods {
+		checkErr(t, s.Add("user", AllSections, period, old, totals), nil)
+		checkErr(t, s.Add("user", AllSections, period, now, totals), nil)
+	}
+
+	// day, week and month of the old totals
+	removed, err := s.Prune(now)
+	checkErr(t, err, nil)
+
+	if removed != 3 {
+		t.Fatalf("expected 3 removed but got %d", removed)
+	}
+
+	for _, period := range Periods {
+		got, err := s.Get("user", AllSections, period, old)
+		checkErr(t, err, nil)
+
+		expected := Totals{}
+		if period == PeriodAllTime {
+			expected = Totals{UploadBytes: 200, UploadFiles: 2}
+		}
+
+		if got != expected {
+			t.Errorf("%s: expected %+v but got %+v", period, expected, got)
+		}
+
+		got, err = s.Get("user", AllSections, period, now)
+		checkErr(t, err, nil)
+
+		if got.UploadFiles == 0 {
+			t.Errorf("%s: expected the current totals to be kept", period)
+		}
+	}
+}
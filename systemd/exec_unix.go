@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package systemd
+
+import "syscall"
+
+// closeOnExec stops the socket leaking in to programs run by goftpd
+func closeOnExec(fd int) { syscall.CloseOnExec(fd) }
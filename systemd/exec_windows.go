@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package systemd
+
+// closeOnExec does nothing, there is no socket activation on windows
+func closeOnExec(fd int) {}
@@ -0,0 +1,99 @@
+// Package systemd implements the parts of systemd's service protocol
+// goftpd uses, inheriting listening sockets through socket activation and
+// notifying the service manager of its state for Type=notify services.
+// Both do nothing when not started by systemd
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// states sent with Notify
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation
+const listenFdsStart = 3
+
+// Listeners returns the sockets passed by socket activation keyed by their
+// FileDescriptorName, systemd names them after the socket unit if it isn't
+// set. Returns nil if none were passed to this process. The environment
+// variables are unset so child processes don't inherit them
+func Listeners() (map[string][]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	var names []string
+	if s := os.Getenv("LISTEN_FDNAMES"); len(s) > 0 {
+		names = strings.Split(s, ":")
+	}
+
+	listeners := make(map[string][]net.Listener, n)
+
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		closeOnExec(fd)
+
+		name := "unknown"
+		if i := fd - listenFdsStart; i < len(names) {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			return nil, errors.WithMessagef(err, "socket %d (%s)", fd, name)
+		}
+
+		listeners[name] = append(listeners[name], l)
+	}
+
+	return listeners, nil
+}
+
+// Notify sends the state to the service manager, i.e. Ready. Returns false
+// if there is no service manager to notify
+func Notify(state string) (bool, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if len(addr) == 0 {
+		return false, nil
+	}
+
+	// an abstract socket
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
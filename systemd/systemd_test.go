@@ -0,0 +1,80 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+
+	ok, err := Notify(Ready)
+	checkErr(t, err, nil)
+
+	if ok {
+		t.Fatal("expected no service manager")
+	}
+
+	path := filepath.Join(t.TempDir(), "notify")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	checkErr(t, err, nil)
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	for _, state := range []string{Ready, Reloading, Stopping} {
+		ok, err := Notify(state)
+		checkErr(t, err, nil)
+
+		if !ok {
+			t.Fatal("expected the service manager to be notified")
+		}
+
+		b := make([]byte, 64)
+
+		n, err := conn.Read(b)
+		checkErr(t, err, nil)
+
+		if string(b[:n]) != state {
+			t.Errorf("expected '%s' got '%s'", state, b[:n])
+		}
+	}
+}
+
+func TestListenersNotPassed(t *testing.T) {
+	var tests = []struct {
+		name string
+		pid  string
+		fds  string
+	}{
+		{"unset", "", ""},
+		{"other process", strconv.Itoa(os.Getpid() + 1), "1"},
+		{"no fds", strconv.Itoa(os.Getpid()), "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name,
+			func(t *testing.T) {
+				os.Setenv("LISTEN_PID", tt.pid)
+				os.Setenv("LISTEN_FDS", tt.fds)
+
+				listeners, err := Listeners()
+				checkErr(t, err, nil)
+
+				if listeners != nil {
+					t.Errorf("expected no listeners got %v", listeners)
+				}
+
+				if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+					t.Error("expected LISTEN_FDS to be unset")
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,214 @@
+package vfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// ErrNoChecksum is returned when no checksum was recorded for a file
+var ErrNoChecksum = errors.New("no checksum recorded")
+
+// ErrChecksumMismatch is returned when a file no longer matches the checksum
+// recorded when it was uploaded
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksum holds the checksums of a file's contents
+type Checksum struct {
+	CRC32  uint32
+	SHA256 []byte
+}
+
+// IsZero reports whether no checksum is set
+func (c Checksum) IsZero() bool {
+	return len(c.SHA256) == 0
+}
+
+// Equal reports whether both checksums match
+func (c Checksum) Equal(o Checksum) bool {
+	return c.CRC32 == o.CRC32 && bytes.Equal(c.SHA256, o.SHA256)
+}
+
+// checksummer computes a Checksum of everything written to it
+type checksummer struct {
+	crc hash.Hash32
+	sha hash.Hash
+}
+
+func newChecksummer() *checksummer {
+	return &checksummer{
+		crc: crc32.NewIEEE(),
+		sha: sha256.New(),
+	}
+}
+
+func (c *checksummer) Write(p []byte) (int, error) {
+	c.crc.Write(p)
+	c.sha.Write(p)
+	return len(p), nil
+}
+
+// Sum returns the Checksum of everything written so far
+func (c *checksummer) Sum() Checksum {
+	return Checksum{
+		CRC32:  c.crc.Sum32(),
+		SHA256: c.sha.Sum(nil),
+	}
+}
+
+// checksumFile reads the whole file to compute its Checksum
+func (fs *Filesystem) checksumFile(path string) (Checksum, error) {
+	f, err := fs.chroot.Open(path)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer f.Close()
+
+	c := newChecksummer()
+
+	if _, err := io.Copy(c, f); err != nil {
+		return Checksum{}, err
+	}
+
+	return c.Sum(), nil
+}
+
+// Checksum returns the checksum recorded for the file when it was uploaded,
+// ErrNoChecksum is returned for files that haven't got one
+func (fs *Filesystem) Checksum(path string, user *acl.User) (Checksum, error) {
+	path, err := fs.checkDownload(path, user)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	if finfo.IsDir() {
+		return Checksum{}, errors.New("can not checksum directory.")
+	}
+
+	e, err := fs.shadow.GetEntry(path)
+	if err != nil && err != ErrNoPath {
+		return Checksum{}, err
+	}
+
+	if e.Checksum.IsZero() {
+		return Checksum{}, ErrNoChecksum
+	}
+
+	return e.Checksum, nil
+}
+
+// VerifyChecksum re-reads the file and compares it to the recorded checksum,
+// returning ErrChecksumMismatch if it has changed. Files without a checksum
+// have the computed one recorded. Returns the computed Checksum
+func (fs *Filesystem) VerifyChecksum(path string, user *acl.User) (Checksum, error) {
+	path, err := fs.checkDownload(path, user)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	finfo, err := fs.chroot.Stat(path)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	if finfo.IsDir() {
+		return Checksum{}, errors.New("can not checksum directory.")
+	}
+
+	sum, err := fs.checksumFile(path)
+	if err != nil {
+		return Checksum{}, err
+	}
+
+	e, err := fs.shadow.GetEntry(path)
+	if err != nil && err != ErrNoPath {
+		return Checksum{}, err
+	}
+
+	if !e.Checksum.IsZero() {
+		if !e.Checksum.Equal(sum) {
+			return sum, ErrChecksumMismatch
+		}
+		return sum, nil
+	}
+
+	if err == ErrNoPath {
+		e.User = fs.DefaultUser
+		e.Group = fs.DefaultGroup
+	}
+
+	e.Checksum = sum
+
+	if err := fs.shadow.SetEntry(path, e); err != nil {
+		return Checksum{}, err
+	}
+
+	return sum, nil
+}
+
+// recordUpload sets the owner and checksum of an uploaded file, keeping the
+// creation time of any existing entry
+func (fs *Filesystem) recordUpload(path string, user *acl.User, sum Checksum) error {
+	e, err := fs.shadow.GetEntry(path)
+	if err != nil && err != ErrNoPath {
+		return err
+	}
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	e.User = user.Name
+	e.Group = user.PrimaryGroup
+	e.Checksum = sum
+
+	return fs.shadow.SetEntry(path, e)
+}
+
+// checkDownload checks the user can read the path returning where it resolves
+// to, checking the same permissions as DownloadFile
+func (fs *Filesystem) checkDownload(path string, user *acl.User) (string, error) {
+	// check for jail
+	if !user.CanAccess(path) {
+		return "", os.ErrNotExist
+	}
+
+	// follow any links, everything below applies to where they point
+	path, err := fs.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !user.CanAccess(path) {
+		return "", os.ErrNotExist
+	}
+
+	if !fs.permissions.Match(acl.PermissionScopeDownload, path, user) {
+		return "", acl.ErrPermissionDenied
+	}
+
+	// check for private
+	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return "", os.ErrNotExist
+	}
+
+	if fs.hidden(path, user) {
+		// do not leak any information, just pretend
+		// it doesnt exist
+		return "", os.ErrNotExist
+	}
+
+	return path, nil
+}
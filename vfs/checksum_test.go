@@ -0,0 +1,102 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"upload /** *",
+		"resume /** *",
+		"download /** *",
+		"rename /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	expected := func(content string) Checksum {
+		sha := sha256.Sum256([]byte(content))
+		return Checksum{crc32.ChecksumIEEE([]byte(content)), sha[:]}
+	}
+
+	write := func(w io.WriteCloser, content string) {
+		t.Helper()
+
+		_, err := io.Copy(w, strings.NewReader(content))
+		checkErr(t, err, nil)
+		checkErr(t, w.Close(), nil)
+	}
+
+	// computed while uploading
+	w, err := fs.UploadFile("/file", user)
+	checkErr(t, err, nil)
+	write(w, "HELLO")
+
+	sum, err := fs.Checksum("/file", user)
+	checkErr(t, err, nil)
+
+	if !sum.Equal(expected("HELLO")) {
+		t.Errorf("unexpected checksum after upload: %+v", sum)
+	}
+
+	// resuming covers the whole file
+	w, err = fs.ResumeUploadFile("/file", user)
+	checkErr(t, err, nil)
+	write(w, " WORLD")
+
+	sum, err = fs.Checksum("/file", user)
+	checkErr(t, err, nil)
+
+	if !sum.Equal(expected("HELLO WORLD")) {
+		t.Errorf("unexpected checksum after resume: %+v", sum)
+	}
+
+	// kept on rename and shown in facts
+	checkErr(t, fs.RenameFile("/file", "/renamed", user), nil)
+
+	finfo, err := fs.StatFile("/renamed", user)
+	checkErr(t, err, nil)
+
+	if !finfo.Checksum.Equal(sum) || !strings.Contains(finfo.Facts(), "X.CRC32=") {
+		t.Errorf("unexpected facts after rename: %s", finfo.Facts())
+	}
+
+	_, err = fs.VerifyChecksum("/renamed", user)
+	checkErr(t, err, nil)
+
+	// changed behind our back
+	f, err := fs.chroot.OpenFile("/renamed", os.O_WRONLY|os.O_APPEND, defaultPerms)
+	checkErr(t, err, nil)
+	f.Write([]byte("!"))
+	f.Close()
+
+	if _, err := fs.VerifyChecksum("/renamed", user); err != ErrChecksumMismatch {
+		t.Errorf("expected ErrChecksumMismatch got: %v", err)
+	}
+
+	// files without a checksum have one recorded when verified
+	createFile(t, fs, "/other", "OTHER")
+
+	if _, err := fs.Checksum("/other", user); err != ErrNoChecksum {
+		t.Errorf("expected ErrNoChecksum got: %v", err)
+	}
+
+	_, err = fs.VerifyChecksum("/other", user)
+	checkErr(t, err, nil)
+
+	sum, err = fs.Checksum("/other", user)
+	checkErr(t, err, nil)
+
+	if !sum.Equal(expected("OTHER")) {
+		t.Errorf("unexpected checksum after verify: %+v", sum)
+	}
+}
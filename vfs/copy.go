@@ -0,0 +1,221 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// CopyTree copies the file or directory at oldpath, and everything under it,
+// to newpath along with their shadow entries. Copies can cross mounts. Who may
+// copy is decided by the caller, only jail, private and hidden paths are
+// checked here. Returns the bytes copied
+func (fs *Filesystem) CopyTree(oldpath, newpath string, user *acl.User) (int64, error) {
+	oldpath, newpath, err := fs.checkTree(oldpath, newpath, user)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := fs.copyTree(oldpath, newpath)
+
+	// account for whatever was copied even if it failed part way
+	if err := fs.adjustSize(newpath, size); err != nil {
+		return size, err
+	}
+
+	return size, err
+}
+
+// MoveTree moves the file or directory at oldpath to newpath along with the
+// shadow entries of everything under it. Moves between mounts are copied and
+// then removed. Who may move is decided by the caller, only jail, private and
+// hidden paths are checked here
+func (fs *Filesystem) MoveTree(oldpath, newpath string, user *acl.User) error {
+	oldpath, newpath, err := fs.checkTree(oldpath, newpath, user)
+	if err != nil {
+		return err
+	}
+
+	finfo, err := fs.chroot.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.moveTree(oldpath, newpath); err != nil {
+		return err
+	}
+
+	fs.events.Publish(Event{
+		Type:    EventRename,
+		Path:    oldpath,
+		NewPath: newpath,
+		User:    user.Name,
+		Group:   user.PrimaryGroup,
+		Dir:     finfo.IsDir(),
+	})
+
+	return nil
+}
+
+// checkTree checks both paths of a copy or move are visible to the user, that
+// oldpath exists and newpath doesn't and that newpath isn't inside oldpath
+func (fs *Filesystem) checkTree(oldpath, newpath string, user *acl.User) (string, string, error) {
+	oldpath = filepath.Clean("/" + oldpath)
+	newpath = filepath.Clean("/" + newpath)
+
+	for _, p := range []string{oldpath, newpath} {
+		// check for jail
+		if !user.CanAccess(p) {
+			return "", "", os.ErrNotExist
+		}
+
+		// check for private
+		if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, p, user); found && !match {
+			return "", "", os.ErrNotExist
+		}
+
+		if fs.hidden(p, user) {
+			return "", "", os.ErrNotExist
+		}
+	}
+
+	if oldpath == "/" {
+		return "", "", acl.ErrPermissionDenied
+	}
+
+	if oldpath == newpath || strings.HasPrefix(newpath, oldpath+"/") {
+		return "", "", errors.New("can not copy or move in to itself")
+	}
+
+	if _, err := fs.chroot.Lstat(oldpath); err != nil {
+		return "", "", err
+	}
+
+	if _, err := fs.chroot.Lstat(newpath); err == nil {
+		return "", "", os.ErrExist
+	}
+
+	finfo, err := fs.chroot.Stat(filepath.Dir(newpath))
+	if err != nil {
+		return "", "", err
+	}
+
+	if !finfo.IsDir() {
+		return "", "", errors.New("parent is not a directory")
+	}
+
+	return oldpath, newpath, nil
+}
+
+// copyTree copies oldpath to newpath, parents before their children, copying
+// shadow entries and caching the size of each directory copied. Links are
+// copied rather than followed and uploads in progress are skipped. No
+// permissions are checked. Returns the bytes copied
+func (fs *Filesystem) copyTree(oldpath, newpath string) (int64, error) {
+	finfo, err := fs.chroot.Lstat(oldpath)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+
+	switch {
+	case finfo.Mode()&os.ModeSymlink != 0:
+		target, err := fs.chroot.Readlink(oldpath)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := fs.chroot.Symlink(target, newpath); err != nil {
+			return 0, err
+		}
+
+	case finfo.IsDir():
+		if err := fs.chroot.MkdirAll(newpath, fs.createPerms(true)); err != nil {
+			return 0, err
+		}
+
+		if err := fs.applyMode(newpath, true); err != nil {
+			return 0, err
+		}
+
+		files, err := fs.chroot.ReadDir(oldpath)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, f := range files {
+			if isUploadTemp(f.Name()) {
+				continue
+			}
+
+			n, err := fs.copyTree(filepath.Join(oldpath, f.Name()), filepath.Join(newpath, f.Name()))
+			size += n
+
+			if err != nil {
+				return size, err
+			}
+		}
+
+		if err := fs.shadow.SetSize(newpath, size); err != nil {
+			return size, err
+		}
+
+	case finfo.Mode().IsRegular():
+		n, err := fs.copyFile(oldpath, newpath)
+		if err != nil {
+			return n, err
+		}
+		size = n
+
+	default:
+		// devices, sockets and the like are left behind
+		return 0, nil
+	}
+
+	e, err := fs.shadow.GetEntry(oldpath)
+	if err == ErrNoPath {
+		return size, nil
+	}
+
+	if err != nil {
+		return size, err
+	}
+
+	return size, fs.shadow.SetEntry(newpath, e)
+}
+
+// copyFile copies the contents of the file at oldpath to a new file at
+// newpath, a partial copy is removed
+func (fs *Filesystem) copyFile(oldpath, newpath string) (int64, error) {
+	src, err := fs.chroot.Open(oldpath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := fs.chroot.OpenFile(newpath, os.O_RDWR|os.O_CREATE|os.O_EXCL, fs.createPerms(false))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(dst, src)
+	if err == nil {
+		err = fs.applyMode(newpath, false)
+	}
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		fs.chroot.Remove(newpath)
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,132 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/pkg/errors"
+)
+
+func TestCopyTree(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	owner := newTestUser("owner", "group")
+	staff := newTestUser("staff", "siteops")
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release/cd1", defaultPerms), nil)
+	checkErr(t, fs.chroot.MkdirAll("/archive", defaultPerms), nil)
+	createFile(t, fs, "/mp3/release/file.nfo", "NFO")
+	createFile(t, fs, "/mp3/release/cd1/track.mp3", "TRACK")
+	createFile(t, fs, uploadTempPath("/mp3/release/partial.mp3"), "PART")
+
+	for _, p := range []string{"/mp3/release", "/mp3/release/cd1", "/mp3/release/file.nfo", "/mp3/release/cd1/track.mp3"} {
+		setShadowOwner(t, fs, p, owner)
+	}
+
+	_, err := fs.CopyTree("/mp3/release", "/mp3/release/cd1/copy", staff)
+	checkErr(t, err, errors.New("can not copy or move in to itself"))
+
+	_, err = fs.CopyTree("/mp3/missing", "/archive/missing", staff)
+	checkErr(t, err, os.ErrNotExist)
+
+	n, err := fs.CopyTree("/mp3/release", "/archive/release", staff)
+	checkErr(t, err, nil)
+
+	if n != 8 {
+		t.Errorf("expected 8 bytes copied got %d", n)
+	}
+
+	_, err = fs.CopyTree("/mp3/release", "/archive/release", staff)
+	checkErr(t, err, os.ErrExist)
+
+	for _, p := range []string{"/archive/release", "/archive/release/cd1", "/archive/release/file.nfo", "/archive/release/cd1/track.mp3"} {
+		e, err := fs.shadow.GetEntry(p)
+		checkErr(t, err, nil)
+
+		if e.User != "owner" || e.Group != "group" {
+			t.Errorf("expected %s to be owned by owner:group got %s:%s", p, e.User, e.Group)
+		}
+	}
+
+	if _, err := fs.chroot.Lstat(uploadTempPath("/archive/release/partial.mp3")); err == nil {
+		t.Error("expected upload in progress to not be copied")
+	}
+
+	// the original is left alone
+	if _, err := fs.chroot.Lstat("/mp3/release/cd1/track.mp3"); err != nil {
+		t.Errorf("expected original to still exist: %s", err)
+	}
+
+	size, err := fs.dirSize("/archive/release")
+	checkErr(t, err, nil)
+
+	if size != 8 {
+		t.Errorf("expected copy to have a size of 8 got %d", size)
+	}
+}
+
+func TestMoveTreeAcrossMounts(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	checkErr(t, fs.chroot.MkdirAll("/archive", defaultPerms), nil)
+
+	archive := memfs.New()
+	checkErr(t, archive.MkdirAll("/", defaultPerms), nil)
+
+	mfs := NewMountFS(fs.chroot)
+	checkErr(t, mfs.Mount("/archive", archive), nil)
+	fs.chroot = mfs
+
+	owner := newTestUser("owner", "group")
+	staff := newTestUser("staff", "siteops")
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release", defaultPerms), nil)
+	createFile(t, fs, "/mp3/release/track.mp3", "TRACK")
+	setShadowOwner(t, fs, "/mp3/release", owner)
+	setShadowOwner(t, fs, "/mp3/release/track.mp3", owner)
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	checkErr(t, fs.MoveTree("/mp3/release", "/archive/release", staff), nil)
+
+	if _, err := fs.chroot.Lstat("/mp3/release"); !os.IsNotExist(err) {
+		t.Errorf("expected original to be removed got %v", err)
+	}
+
+	f, err := fs.chroot.Open("/archive/release/track.mp3")
+	checkErr(t, err, nil)
+
+	b, err := ioutil.ReadAll(f)
+	checkErr(t, err, nil)
+	f.Close()
+
+	if string(b) != "TRACK" {
+		t.Errorf("expected moved file to contain 'TRACK' got '%s'", string(b))
+	}
+
+	e, err := fs.shadow.GetEntry("/archive/release/track.mp3")
+	checkErr(t, err, nil)
+
+	if e.User != "owner" {
+		t.Errorf("expected moved file to be owned by owner got %s", e.User)
+	}
+
+	if _, err := fs.shadow.GetEntry("/mp3/release/track.mp3"); err != ErrNoPath {
+		t.Errorf("expected old shadow entry to be removed got %v", err)
+	}
+
+	if len(events) != 1 || events[0].Type != EventRename || events[0].NewPath != "/archive/release" || !events[0].Dir {
+		t.Errorf("expected a single rename event got %+v", events)
+	}
+}
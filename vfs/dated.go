@@ -0,0 +1,149 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// datedReplacer turns a dated dir format in to a time layout
+var datedReplacer = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "01",
+	"DD", "02",
+)
+
+// DatedDir is a section that gets a new directory every day, named using
+// Format where YYYY, YY, MM and DD are replaced with the date. If Link is
+// set it is kept pointing at the newest directory
+type DatedDir struct {
+	Path   string
+	Format string
+	Link   string
+	layout string
+}
+
+// NewDatedDir takes a line of text (i.e. from a config file) in the form of
+// `<path> <format> [link]`
+func NewDatedDir(line string) (DatedDir, error) {
+	var d DatedDir
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 || len(fields) > 3 {
+		return d, errors.New("dated requires `<path> <format> [link]`")
+	}
+
+	d.Path = filepath.Clean("/" + fields[0])
+	d.Format = fields[1]
+
+	if strings.Contains(d.Format, "/") {
+		return d, errors.New("format can't contain '/'")
+	}
+
+	d.layout = datedReplacer.Replace(d.Format)
+
+	if d.layout == d.Format {
+		return d, errors.Errorf("format '%s' contains no date", d.Format)
+	}
+
+	if len(fields) == 3 {
+		d.Link = filepath.Clean("/" + fields[2])
+	}
+
+	return d, nil
+}
+
+// Dir returns the path of the directory for t
+func (d DatedDir) Dir(t time.Time) string {
+	return filepath.Join(d.Path, t.Format(d.layout))
+}
+
+func (f *FilesystemOpts) SetDatedDirs(dirs []DatedDir) { f.datedDirs = dirs }
+
+// CreateDatedDirs creates the directory for t in each dated section if it
+// doesn't already exist and points any links at it. Directories and links
+// are owned by the DefaultUser and DefaultGroup. Returns the directories
+// created
+func (fs *Filesystem) CreateDatedDirs(t time.Time) ([]string, error) {
+	var created []string
+
+	for _, d := range fs.datedDirs {
+		dir := d.Dir(t)
+
+		if _, err := fs.chroot.Stat(dir); err != nil {
+			if !os.IsNotExist(err) {
+				return created, err
+			}
+
+			if err := fs.chroot.MkdirAll(dir, fs.createPerms(true)); err != nil {
+				return created, err
+			}
+
+			if err := fs.applyMode(dir, true); err != nil {
+				return created, err
+			}
+
+			if err := fs.shadow.Set(dir, fs.DefaultUser, fs.DefaultGroup); err != nil {
+				return created, err
+			}
+
+			if err := fs.shadow.SetSize(dir, 0); err != nil {
+				return created, err
+			}
+
+			created = append(created, dir)
+		}
+
+		if len(d.Link) == 0 {
+			continue
+		}
+
+		if err := fs.updateDatedLink(d.Link, dir); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
+
+// updateDatedLink points link at dir, replacing any existing link. Anything
+// other than a link at the path is left alone
+func (fs *Filesystem) updateDatedLink(link, dir string) error {
+	// keep the target relative where possible so the link also works
+	// on the host
+	target := dir
+	if rel, err := filepath.Rel(filepath.Dir(link), dir); err == nil {
+		target = rel
+	}
+
+	finfo, err := fs.chroot.Lstat(link)
+	if err == nil {
+		if finfo.Mode()&os.ModeSymlink == 0 {
+			return errors.Errorf("'%s' exists and is not a link", link)
+		}
+
+		current, err := fs.chroot.Readlink(link)
+		if err != nil {
+			return err
+		}
+
+		if current == target {
+			return nil
+		}
+
+		if err := fs.chroot.Remove(link); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.chroot.Symlink(target, link); err != nil {
+		return err
+	}
+
+	return fs.shadow.Set(link, fs.DefaultUser, fs.DefaultGroup)
+}
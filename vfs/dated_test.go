@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewDatedDir(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"/0day MMDD", nil},
+		{"/0day MMDD /today", nil},
+		{"/0day", errors.New("dated requires `<path> <format> [link]`")},
+		{"/0day today", errors.New("format 'today' contains no date")},
+		{"/0day YYYY/MM", errors.New("format can't contain '/'")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewDatedDir(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestCreateDatedDirs(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{"download /** *"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	var dirs []DatedDir
+	for _, l := range []string{"/0day MMDD /today", "/mp3 YYYY-MM-DD"} {
+		d, err := NewDatedDir(l)
+		checkErr(t, err, nil)
+		dirs = append(dirs, d)
+	}
+
+	fs.SetDatedDirs(dirs)
+
+	day := time.Date(2020, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+	created, err := fs.CreateDatedDirs(day)
+	checkErr(t, err, nil)
+
+	if len(created) != 2 || created[0] != "/0day/1015" || created[1] != "/mp3/2020-10-15" {
+		t.Fatalf("unexpected created: %v", created)
+	}
+
+	// nothing is created twice
+	created, err = fs.CreateDatedDirs(day)
+	checkErr(t, err, nil)
+
+	if len(created) != 0 {
+		t.Fatalf("expected nothing created got: %v", created)
+	}
+
+	user := newTestUser("user", "group")
+
+	createFile(t, fs, "/0day/1015/file", "FILE")
+
+	files, err := fs.ListDir("/today", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 1 || files[0].Name() != "file" {
+		t.Fatalf("unexpected listing through link: %+v", files)
+	}
+
+	// the link moves to the next day
+	_, err = fs.CreateDatedDirs(day.AddDate(0, 0, 1))
+	checkErr(t, err, nil)
+
+	files, err = fs.ListDir("/today", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 0 {
+		t.Fatalf("expected empty listing through link got: %+v", files)
+	}
+
+	owner, group, err := fs.shadow.Get("/0day/1016")
+	checkErr(t, err, nil)
+
+	if owner != "nobody" || group != "nogroup" {
+		t.Errorf("expected nobody/nogroup got %s/%s", owner, group)
+	}
+}
@@ -0,0 +1,89 @@
+package vfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/zipscript"
+)
+
+// EventType is an "enum" for the different changes made to the filesystem
+type EventType string
+
+const (
+	EventUpload     EventType = "upload"
+	EventMakeDir              = "makedir"
+	EventDeleteFile           = "deletefile"
+	EventDeleteDir            = "deletedir"
+	EventRename               = "rename"
+	// every file of a release has passed its sfv check, see zipscript
+	EventComplete = "complete"
+)
+
+// Event describes a change made to the filesystem. User and Group are empty
+// for changes made by the server itself, i.e. rotations
+type Event struct {
+	Type EventType
+	Path string
+
+	// where Path was renamed to
+	NewPath string
+
+	User  string
+	Group string
+
+	Dir bool
+
+	// set on the delete made by Wipe
+	Wipe bool
+
+	// bytes written and the checksum of the file for uploads, the bytes
+	// of the release for EventComplete
+	Size     int64
+	Checksum Checksum
+
+	// how long an upload took
+	Duration time.Duration
+
+	// the progress of the release an upload is in once checked by the
+	// zipscript, nil if the release has no sfv
+	Release *zipscript.Progress
+
+	Time time.Time
+}
+
+// Bus delivers Events to subscribers. Subscribers are called in the order they
+// subscribed on the goroutine making the change, anything slow should hand
+// the Event off to its own goroutine
+type Bus struct {
+	mtx         sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewBus returns a Bus without any subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds fn to be called for every Event published
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish sends the Event to every subscriber, setting its Time if it isn't
+// already set
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	for _, fn := range b.subscribers {
+		fn(e)
+	}
+}
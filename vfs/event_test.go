@@ -0,0 +1,68 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"upload /** *",
+		"delete /** *",
+		"rename /** *",
+		"makedir /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	var events []Event
+
+	fs.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	user := newTestUser("user", "group")
+
+	checkErr(t, fs.MakeDir("/dir", user), nil)
+
+	writer, err := fs.UploadFile("/dir/file", user)
+	checkErr(t, err, nil)
+	fmt.Fprint(writer, "HELLO")
+	checkErr(t, writer.Close(), nil)
+
+	// failed uploads aren't published
+	writer, err = fs.UploadFile("/dir/failed", user)
+	checkErr(t, err, nil)
+	writer.(*writeCloser).err = ErrNoSpace
+	checkErr(t, writer.Close(), nil)
+
+	checkErr(t, fs.RenameFile("/dir", "/renamed", user), nil)
+	checkErr(t, fs.DeleteFile("/renamed/file", user), nil)
+	checkErr(t, fs.DeleteDir("/renamed", user), nil)
+
+	var expected = []Event{
+		{Type: EventMakeDir, Path: "/dir", Dir: true},
+		{Type: EventUpload, Path: "/dir/file", Size: 5},
+		{Type: EventRename, Path: "/dir", NewPath: "/renamed", Dir: true},
+		{Type: EventDeleteFile, Path: "/renamed/file"},
+		{Type: EventDeleteDir, Path: "/renamed", Dir: true},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events got %d: %+v", len(expected), len(events), events)
+	}
+
+	for idx, e := range expected {
+		got := events[idx]
+
+		if got.Type != e.Type || got.Path != e.Path || got.NewPath != e.NewPath || got.Dir != e.Dir || got.Size != e.Size {
+			t.Errorf("expected event %d to be %+v got %+v", idx, e, got)
+		}
+
+		if got.User != "user" || got.Group != "group" || got.Time.IsZero() {
+			t.Errorf("unexpected user, group or time for event %d: %+v", idx, got)
+		}
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
 )
 
 // File represents objects in the filesytem, is essentially an os.FileInfo with shadow details
@@ -15,6 +18,15 @@ type FileInfo struct {
 	os.FileInfo
 	Owner string
 	Group string
+
+	// when the path was created through goftpd, zero if unknown
+	CreatedAt time.Time
+
+	// where a symbolic link points
+	LinkTarget string
+
+	// recorded on upload, zero if unknown
+	Checksum Checksum
 }
 
 type FileList []FileInfo
@@ -34,21 +46,96 @@ func (flist FileList) Short() []byte {
 func (flist FileList) Detailed() []byte {
 	var buf bytes.Buffer
 	for _, file := range flist {
-		fmt.Fprint(&buf, file.Mode().String())
+		mode := file.Mode().String()
+		if file.Mode()&os.ModeSymlink != 0 {
+			// clients expect ls style
+			mode = "l" + mode[1:]
+		}
+
+		fmt.Fprint(&buf, mode)
 		fmt.Fprintf(&buf, " 1 %s %s ", file.Owner, file.Group)
 		fmt.Fprint(&buf, lpad(strconv.FormatInt(file.Size(), 10), 12))
 		fmt.Fprint(&buf, file.ModTime().Format(" Jan _2 15:04 "))
-		fmt.Fprintf(&buf, "%s\r\n", file.Name())
+		if len(file.LinkTarget) > 0 {
+			fmt.Fprintf(&buf, "%s -> %s\r\n", file.Name(), file.LinkTarget)
+		} else {
+			fmt.Fprintf(&buf, "%s\r\n", file.Name())
+		}
 	}
 	return buf.Bytes()
 }
 
+// Machine returns the collection of files as MLSD lines
+func (flist FileList) Machine() []byte {
+	var buf bytes.Buffer
+	for _, file := range flist {
+		fmt.Fprintf(&buf, "%s %s\r\n", file.Facts(), file.Name())
+	}
+	return buf.Bytes()
+}
+
+// Facts returns the MLST facts for the file, checksums are only included
+// if recorded
+func (file FileInfo) Facts() string {
+	var b strings.Builder
+
+	if file.IsDir() {
+		b.WriteString("type=dir;")
+	} else {
+		b.WriteString("type=file;")
+	}
+
+	fmt.Fprintf(&b, "size=%d;", file.Size())
+	fmt.Fprintf(&b, "modify=%s;", file.ModTime().UTC().Format("20060102150405"))
+	fmt.Fprintf(&b, "UNIX.owner=%s;", file.Owner)
+	fmt.Fprintf(&b, "UNIX.group=%s;", file.Group)
+
+	if !file.Checksum.IsZero() {
+		fmt.Fprintf(&b, "X.CRC32=%08X;", file.Checksum.CRC32)
+		fmt.Fprintf(&b, "X.SHA256=%x;", file.Checksum.SHA256)
+	}
+
+	return b.String()
+}
+
 func (flist FileList) SortByName() {
 	sort.Slice(flist, func(i, j int) bool {
 		return flist[i].Name() < flist[j].Name()
 	})
 }
 
+// SortByModTime sorts the collection newest first, like `ls -t`
+func (flist FileList) SortByModTime() {
+	sort.SliceStable(flist, func(i, j int) bool {
+		return flist[i].ModTime().After(flist[j].ModTime())
+	})
+}
+
+// SortBySize sorts the collection largest first, like `ls -S`
+func (flist FileList) SortBySize() {
+	sort.SliceStable(flist, func(i, j int) bool {
+		return flist[i].Size() > flist[j].Size()
+	})
+}
+
+// Reverse reverses the order of the collection
+func (flist FileList) Reverse() {
+	for i, j := 0, len(flist)-1; i < j; i, j = i+1, j-1 {
+		flist[i], flist[j] = flist[j], flist[i]
+	}
+}
+
+// Filter returns the files with names matching g
+func (flist FileList) Filter(g glob.Glob) FileList {
+	var results FileList
+	for _, file := range flist {
+		if g.Match(file.Name()) {
+			results = append(results, file)
+		}
+	}
+	return results
+}
+
 func lpad(input string, length int) (result string) {
 	if len(input) < length {
 		result = strings.Repeat(" ", length-len(input)) + input
@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+// ErrFilenameNotAllowed is returned when an upload's name is refused by the
+// filename rules
+var ErrFilenameNotAllowed = errors.New("filename not allowed")
+
+// FilenameRule accepts or denies names matching its regexp when uploading
+// to paths matching its glob
+type FilenameRule struct {
+	accept bool
+	g      glob.Glob
+	re     *regexp.Regexp
+}
+
+// NewFilenameRule takes a line of text (i.e. from a config file) in the form
+// of `<accept|deny> <glob> <regexp>`
+func NewFilenameRule(line string) (FilenameRule, error) {
+	var rule FilenameRule
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 3 {
+		return rule, errors.New("rule requires minimum of 3 fields")
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "accept":
+		rule.accept = true
+	case "deny":
+	default:
+		return rule, errors.Errorf("unknown action '%s'", fields[0])
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[1]), '/')
+	if err != nil {
+		return rule, err
+	}
+
+	re, err := regexp.Compile(strings.Join(fields[2:], " "))
+	if err != nil {
+		return rule, err
+	}
+
+	rule.g = g
+	rule.re = re
+
+	return rule, nil
+}
+
+func (f *FilesystemOpts) SetFilenameRules(rules []FilenameRule) { f.filenameRules = rules }
+
+// checkFilename checks the name of path against MaxFilename and the filename
+// rules. Names with control characters are always refused, otherwise the
+// first rule with a glob matching path and a regexp matching the name decides.
+// Names not matching any rule are accepted
+func (fs *Filesystem) checkFilename(path string) error {
+	name := filepath.Base(path)
+
+	if fs.MaxFilename > 0 && len(name) > fs.MaxFilename {
+		return errors.WithMessagef(ErrFilenameNotAllowed, "name longer than %d characters", fs.MaxFilename)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return errors.WithMessage(ErrFilenameNotAllowed, "name contains control characters")
+		}
+	}
+
+	lower := strings.ToLower(path)
+
+	for _, r := range fs.filenameRules {
+		if !r.g.Match(lower) || !r.re.MatchString(name) {
+			continue
+		}
+
+		if r.accept {
+			return nil
+		}
+
+		return errors.WithMessagef(ErrFilenameNotAllowed, "'%s' is denied", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewFilenameRule(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{`deny /** (?i)\.exe$`, nil},
+		{`ACCEPT /mp3/** \.mp3$`, nil},
+		{`deny /**`, errors.New("rule requires minimum of 3 fields")},
+		{`block /** \.exe$`, errors.New("unknown action 'block'")},
+		{`deny /** (`, errors.New("missing closing )")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewFilenameRule(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestCheckFilename(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{"upload /** *"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	var rules []FilenameRule
+	for _, l := range []string{
+		`accept /incoming/** \.exe$`,
+		`deny /** (?i)\.exe$`,
+		`deny /** \.(rar|zip)\.[a-z]+$`,
+	} {
+		r, err := NewFilenameRule(l)
+		checkErr(t, err, nil)
+		rules = append(rules, r)
+	}
+
+	fs.SetFilenameRules(rules)
+	fs.MaxFilename = 16
+
+	var tests = []struct {
+		path    string
+		allowed bool
+	}{
+		{"/mp3/track.mp3", true},
+		{"/mp3/setup.EXE", false},
+		{"/incoming/setup.exe", true},
+		{"/mp3/file.rar.exe", false},
+		{"/mp3/file.zip.scr", false},
+		{"/mp3/a-very-long-filename.mp3", false},
+		{"/mp3/bell\a.mp3", false},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				err := fs.checkFilename(tt.path)
+				if tt.allowed {
+					checkErr(t, err, nil)
+					return
+				}
+
+				if !errors.Is(err, ErrFilenameNotAllowed) {
+					t.Errorf("expected ErrFilenameNotAllowed got: %v", err)
+				}
+			},
+		)
+	}
+
+	user := newTestUser("user", "group")
+
+	_, err := fs.UploadFile("/setup.exe", user)
+	if !errors.Is(err, ErrFilenameNotAllowed) {
+		t.Errorf("expected upload to be refused got: %v", err)
+	}
+
+	if _, err := fs.chroot.Stat(uploadTempPath("/setup.exe")); err == nil {
+		t.Error("expected nothing to be created")
+	}
+}
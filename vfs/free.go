@@ -0,0 +1,58 @@
+package vfs
+
+import (
+	"github.com/go-git/go-billy/v5"
+	"github.com/pkg/errors"
+)
+
+// ErrNoSpace is returned when an upload would leave less than the reserved
+// free space on the filesystem
+var ErrNoSpace = errors.New("insufficient disk space")
+
+// freeSpacer is implemented by filesystems that can report free space for
+// a path themselves, i.e. MountFS
+type freeSpacer interface {
+	FreeSpace(string) (uint64, error)
+}
+
+// freeSpace returns the bytes available on the host filesystem holding
+// the root of fs
+func freeSpace(fs billy.Filesystem) (uint64, error) {
+	if f, ok := fs.(freeSpacer); ok {
+		return f.FreeSpace("/")
+	}
+
+	return statfs(fs.Root())
+}
+
+// checkFreeSpace returns ErrNoSpace if the filesystem holding path has less
+// than MinFree megabytes available. Filesystems that can't report their free
+// space are never refused
+func (fs *Filesystem) checkFreeSpace(path string) error {
+	if fs.MinFree <= 0 {
+		return nil
+	}
+
+	free, err := fs.FreeSpace(path)
+	if err != nil {
+		return nil
+	}
+
+	if free < uint64(fs.MinFree)*1024*1024 {
+		return ErrNoSpace
+	}
+
+	return nil
+}
+
+// spaceChecker returns the check used by writeCloser during a transfer, or nil
+// if there is no reserve configured
+func (fs *Filesystem) spaceChecker(path string) func() error {
+	if fs.MinFree <= 0 {
+		return nil
+	}
+
+	return func() error {
+		return fs.checkFreeSpace(path)
+	}
+}
@@ -0,0 +1,81 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// freeFS reports a fixed amount of free space
+type freeFS struct {
+	billy.Filesystem
+	free uint64
+}
+
+func (f *freeFS) FreeSpace(string) (uint64, error) { return f.free, nil }
+
+func TestFreeSpaceGuard(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"upload /** *",
+		"resume /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	ffs := &freeFS{fs.chroot, 10 * 1024 * 1024}
+	fs.chroot = ffs
+	fs.MinFree = 5
+
+	user := newTestUser("user", "group")
+
+	writer, err := fs.UploadFile("/file", user)
+	checkErr(t, err, nil)
+
+	// disk fills up during the transfer
+	data := bytes.Repeat([]byte("x"), spaceCheckInterval)
+
+	_, err = writer.Write(data)
+	checkErr(t, err, nil)
+
+	ffs.free = 1024 * 1024
+
+	if _, err := writer.Write(data); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace got: %v", err)
+	}
+
+	checkErr(t, writer.Close(), nil)
+
+	// failed uploads aren't given an owner
+	if _, err := fs.shadow.GetEntry("/file"); err != ErrNoPath {
+		t.Fatalf("expected ErrNoPath got: %v", err)
+	}
+
+	// or kept
+	if _, err := fs.chroot.Stat("/file"); err == nil {
+		t.Fatal("expected failed upload to be removed")
+	}
+
+	createFile(t, fs, "/file", "PARTIAL")
+
+	if _, err := fs.UploadFile("/other", user); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace got: %v", err)
+	}
+
+	if _, err := fs.ResumeUploadFile("/file", user); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace got: %v", err)
+	}
+
+	// no reserve disables the check
+	fs.MinFree = 0
+
+	writer, err = fs.ResumeUploadFile("/file", user)
+	checkErr(t, err, nil)
+
+	_, err = io.Copy(writer, bytes.NewReader(data))
+	checkErr(t, err, nil)
+	checkErr(t, writer.Close(), nil)
+}
@@ -0,0 +1,46 @@
+package vfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// HostFile is implemented by files read straight from the host, giving
+// access to the *os.File so downloads can be sent with sendfile rather
+// than copied through the server
+type HostFile interface {
+	HostFile() *os.File
+}
+
+// Open opens the file for reading. Unlike osfs the file returned is a
+// HostFile
+func (o *OSFS) Open(filename string) (billy.File, error) {
+	f, err := os.Open(o.hostPath(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	return &hostFile{file: f, name: filename}, nil
+}
+
+// hostFile is a billy.File opened for reading from the host. The *os.File
+// isn't embedded so its WriteTo doesn't hide the file from io.Copy
+type hostFile struct {
+	file *os.File
+	name string
+}
+
+func (f *hostFile) HostFile() *os.File { return f.file }
+
+func (f *hostFile) Name() string                              { return f.name }
+func (f *hostFile) Read(p []byte) (int, error)                { return f.file.Read(p) }
+func (f *hostFile) ReadAt(p []byte, off int64) (int, error)   { return f.file.ReadAt(p, off) }
+func (f *hostFile) Write(p []byte) (int, error)               { return f.file.Write(p) }
+func (f *hostFile) Seek(off int64, whence int) (int64, error) { return f.file.Seek(off, whence) }
+func (f *hostFile) Truncate(size int64) error                 { return f.file.Truncate(size) }
+func (f *hostFile) Close() error                              { return f.file.Close() }
+
+// Lock isn't supported, files are only opened this way to be read
+func (f *hostFile) Lock() error   { return billy.ErrNotSupported }
+func (f *hostFile) Unlock() error { return billy.ErrNotSupported }
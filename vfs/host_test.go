@@ -0,0 +1,53 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSFSOpenHostFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goftpd-host")
+	checkErr(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	rootDir := filepath.Join(dir, "root")
+	archiveDir := filepath.Join(dir, "archive")
+
+	checkErr(t, os.MkdirAll(filepath.Join(rootDir, "site"), 0755), nil)
+	checkErr(t, os.MkdirAll(archiveDir, 0755), nil)
+	checkErr(t, ioutil.WriteFile(filepath.Join(archiveDir, "file"), []byte("contents"), 0644), nil)
+
+	mfs := NewMountFS(NewOSFS(rootDir))
+	checkErr(t, mfs.Mount("/site/archive", NewOSFS(archiveDir)), nil)
+
+	f, err := mfs.Open("/site/archive/file")
+	checkErr(t, err, nil)
+	defer f.Close()
+
+	hf, ok := f.(HostFile)
+	if !ok {
+		t.Fatalf("expected a HostFile got %T", f)
+	}
+
+	if got := hf.HostFile().Name(); got != filepath.Join(archiveDir, "file") {
+		t.Fatalf("expected host file '%s' got '%s'", filepath.Join(archiveDir, "file"), got)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	checkErr(t, err, nil)
+
+	if string(b) != "contents" {
+		t.Fatalf("expected 'contents' got '%s'", b)
+	}
+
+	// can't escape the root
+	f, err = NewOSFS(archiveDir).Open("../../file")
+	checkErr(t, err, nil)
+	f.Close()
+
+	if _, err := NewOSFS(archiveDir).Open("../root/site"); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist got %v", err)
+	}
+}
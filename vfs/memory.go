@@ -0,0 +1,210 @@
+package vfs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/goftpd/goftpd/acl"
+)
+
+// MemoryShadow is a Shadow kept in memory, it behaves the same as the
+// ShadowStore and is intended for tests
+type MemoryShadow struct {
+	mtx     sync.Mutex
+	entries map[string]ShadowEntry
+	sizes   map[string]int64
+}
+
+// NewMemoryShadow creates an empty MemoryShadow
+func NewMemoryShadow() *MemoryShadow {
+	return &MemoryShadow{
+		entries: make(map[string]ShadowEntry, 0),
+		sizes:   make(map[string]int64, 0),
+	}
+}
+
+// Hash the given path the same way as the ShadowStore
+func (s *MemoryShadow) Hash(path string) []byte {
+	return (&ShadowStore{}).Hash(path)
+}
+
+// Set a path with it's meta data. Overwrites the user and group of any
+// existing value, keeping its creation time and checksum
+func (s *MemoryShadow) Set(path, user, group string) error {
+	if err := checkOwner(user, group); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	key := strings.ToLower(path)
+
+	e := ShadowEntry{
+		User:      strings.ToLower(user),
+		Group:     strings.ToLower(group),
+		CreatedAt: time.Now().Truncate(time.Second),
+	}
+
+	if existing, ok := s.entries[key]; ok {
+		if !existing.CreatedAt.IsZero() {
+			e.CreatedAt = existing.CreatedAt
+		}
+		e.Checksum = existing.Checksum
+	}
+
+	s.entries[key] = e
+
+	return nil
+}
+
+// SetEntry sets the entry for a path, overwriting any existing value
+func (s *MemoryShadow) SetEntry(path string, e ShadowEntry) error {
+	if err := checkOwner(e.User, e.Group); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e.User = strings.ToLower(e.User)
+	e.Group = strings.ToLower(e.Group)
+
+	if !e.CreatedAt.IsZero() {
+		e.CreatedAt = e.CreatedAt.Truncate(time.Second)
+	}
+
+	s.entries[strings.ToLower(path)] = e
+
+	return nil
+}
+
+// Get tries to retrieve the user and group for a path
+func (s *MemoryShadow) Get(path string) (string, string, error) {
+	e, err := s.GetEntry(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.User, e.Group, nil
+}
+
+// GetEntry tries to retrieve the entry for a path
+func (s *MemoryShadow) GetEntry(path string) (ShadowEntry, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e, ok := s.entries[strings.ToLower(path)]
+	if !ok {
+		return ShadowEntry{}, ErrNoPath
+	}
+
+	return e, nil
+}
+
+// Remove deletes an entry and any cached size
+func (s *MemoryShadow) Remove(path string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.entries, strings.ToLower(path))
+	delete(s.sizes, strings.ToLower(path))
+
+	return nil
+}
+
+// Size returns the cached size of everything under a directory, ErrNoPath
+// is returned if it isn't cached
+func (s *MemoryShadow) Size(path string) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	size, ok := s.sizes[strings.ToLower(path)]
+	if !ok {
+		return 0, ErrNoPath
+	}
+
+	return size, nil
+}
+
+// SetSize caches the size of everything under a directory
+func (s *MemoryShadow) SetSize(path string, size int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sizes[strings.ToLower(path)] = size
+
+	return nil
+}
+
+// AdjustSize adds delta to the cached size of path and each of its parents,
+// directories without a cached size are left alone
+func (s *MemoryShadow) AdjustSize(path string, delta int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, p := range parents(path) {
+		key := strings.ToLower(p)
+
+		if size, ok := s.sizes[key]; ok {
+			s.sizes[key] = size + delta
+		}
+	}
+
+	return nil
+}
+
+// ReassignUser changes the owner of every entry owned by from to be owned by to
+func (s *MemoryShadow) ReassignUser(from, to string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	from = strings.ToLower(from)
+
+	for k, e := range s.entries {
+		if e.User == from {
+			e.User = strings.ToLower(to)
+			s.entries[k] = e
+		}
+	}
+
+	return nil
+}
+
+// ReassignGroup changes the group of every entry owned by from to be owned by to
+func (s *MemoryShadow) ReassignGroup(from, to string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	from = strings.ToLower(from)
+
+	for k, e := range s.entries {
+		if e.Group == from {
+			e.Group = strings.ToLower(to)
+			s.entries[k] = e
+		}
+	}
+
+	return nil
+}
+
+// Close does nothing, entries are kept so a closed MemoryShadow can still
+// be inspected
+func (s *MemoryShadow) Close() error {
+	return nil
+}
+
+// NewMemoryFilesystem creates a Filesystem backed entirely by memory, using
+// memfs for files and directories and a MemoryShadow for meta data. Intended
+// for testing anything that needs a VFS without touching the host disk
+func NewMemoryFilesystem(opts *FilesystemOpts, permissions *acl.Permissions) (*Filesystem, error) {
+	memory := memfs.New()
+
+	if err := memory.MkdirAll("/", defaultPerms); err != nil {
+		return nil, err
+	}
+
+	return NewFilesystem(opts, memory, NewMemoryShadow(), permissions)
+}
@@ -0,0 +1,85 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestMemoryShadow(t *testing.T) {
+	ss := NewMemoryShadow()
+	defer closeMemoryShadowStore(t, ss)
+
+	checkErr(t, ss.Set("/a", "user:0", "group"), errors.New("user can't contain ':'"))
+
+	checkErr(t, ss.Set("/A/B", "USER0", "group0"), nil)
+	checkErr(t, ss.Set("/a/c", "user1", "group0"), nil)
+
+	e, err := ss.GetEntry("/a/b")
+	checkErr(t, err, nil)
+
+	if e.User != "user0" || e.Group != "group0" || e.CreatedAt.IsZero() {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	// setting the owner keeps the creation time
+	checkErr(t, ss.Set("/a/b", "user1", "group1"), nil)
+
+	updated, err := ss.GetEntry("/a/b")
+	checkErr(t, err, nil)
+
+	if updated.User != "user1" || !updated.CreatedAt.Equal(e.CreatedAt) {
+		t.Errorf("unexpected entry: %+v", updated)
+	}
+
+	checkErr(t, ss.ReassignUser("USER1", "nobody"), nil)
+	checkErr(t, ss.ReassignGroup("group0", "nogroup"), nil)
+
+	user, group, err := ss.Get("/a/c")
+	checkErr(t, err, nil)
+
+	if user != "nobody" || group != "nogroup" {
+		t.Errorf("expected nobody:nogroup got %s:%s", user, group)
+	}
+
+	checkErr(t, ss.Remove("/a/c"), nil)
+
+	if _, _, err := ss.Get("/a/c"); err != ErrNoPath {
+		t.Errorf("expected ErrNoPath got: %v", err)
+	}
+}
+
+func TestMemoryFilesystem(t *testing.T) {
+	var rules []acl.Rule
+	for _, l := range []string{"upload /** *", "download /** *"} {
+		r, err := acl.NewRule(l)
+		checkErr(t, err, nil)
+		rules = append(rules, r)
+	}
+
+	perms, err := acl.NewPermissions(rules)
+	checkErr(t, err, nil)
+
+	fs, err := NewMemoryFilesystem(&FilesystemOpts{DefaultUser: "nobody", DefaultGroup: "nogroup"}, perms)
+	checkErr(t, err, nil)
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	writer, err := fs.UploadFile("/file", user)
+	checkErr(t, err, nil)
+
+	_, err = io.Copy(writer, strings.NewReader("HELLO"))
+	checkErr(t, err, nil)
+	checkErr(t, writer.Close(), nil)
+
+	files, err := fs.ListDir("/", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 1 || files[0].Name() != "file" || files[0].Owner != "user" {
+		t.Errorf("unexpected listing: %+v", files)
+	}
+}
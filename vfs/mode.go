@@ -0,0 +1,122 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/pkg/errors"
+)
+
+// modeChanger is implemented by filesystems that can set the mode and owner
+// of the files they hold, i.e. OSFS and MountFS
+type modeChanger interface {
+	Chmod(string, os.FileMode) error
+	Lchown(string, int, int) error
+}
+
+// OSFS is an osfs billy.Filesystem that can also change the mode and owner
+// of files on the host
+type OSFS struct {
+	billy.Filesystem
+}
+
+// NewOSFS creates an OSFS rooted at the host directory root
+func NewOSFS(root string) *OSFS {
+	return &OSFS{osfs.New(root)}
+}
+
+// hostPath returns where filename lives on the host, it can not escape root
+func (o *OSFS) hostPath(filename string) string {
+	return filepath.Join(o.Root(), filepath.Clean("/"+filename))
+}
+
+func (o *OSFS) Chmod(filename string, mode os.FileMode) error {
+	return os.Chmod(o.hostPath(filename), mode)
+}
+
+func (o *OSFS) Lchown(filename string, uid, gid int) error {
+	return os.Lchown(o.hostPath(filename), uid, gid)
+}
+
+// ParseMode parses an octal mode such as 0644
+func ParseMode(s string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, errors.Errorf("'%s' is not an octal mode", s)
+	}
+
+	if m > 07777 {
+		return 0, errors.Errorf("'%s' is not a valid mode", s)
+	}
+
+	mode := os.FileMode(m & 0777)
+
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	return mode, nil
+}
+
+// applyMode sets the configured mode and owner on a file or directory the
+// daemon created, rather than leaving it to the process umask. Does nothing
+// when nothing is configured or the filesystem can't change modes
+func (fs *Filesystem) applyMode(path string, dir bool) error {
+	mode := fs.fileMode
+	if dir {
+		mode = fs.dirMode
+	}
+
+	if mode == 0 && fs.UID <= 0 && fs.GID <= 0 {
+		return nil
+	}
+
+	c, ok := fs.chroot.(modeChanger)
+	if !ok {
+		return nil
+	}
+
+	if mode != 0 {
+		if err := c.Chmod(path, mode); err != nil && err != billy.ErrNotSupported {
+			return err
+		}
+	}
+
+	if fs.UID > 0 || fs.GID > 0 {
+		uid, gid := -1, -1
+		if fs.UID > 0 {
+			uid = fs.UID
+		}
+		if fs.GID > 0 {
+			gid = fs.GID
+		}
+
+		if err := c.Lchown(path, uid, gid); err != nil && err != billy.ErrNotSupported {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createPerms returns the permissions to create a file or directory with
+func (fs *Filesystem) createPerms(dir bool) os.FileMode {
+	if dir && fs.dirMode != 0 {
+		return fs.dirMode
+	}
+
+	if !dir && fs.fileMode != 0 {
+		return fs.fileMode
+	}
+
+	return defaultPerms
+}
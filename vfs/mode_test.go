@@ -0,0 +1,97 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestParseMode(t *testing.T) {
+	var tests = []struct {
+		s        string
+		expected os.FileMode
+		ok       bool
+	}{
+		{"0644", 0644, true},
+		{"755", 0755, true},
+		{"2775", 0775 | os.ModeSetgid, true},
+		{"0999", 0, false},
+		{"17777", 0, false},
+		{"rwx", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			mode, err := ParseMode(tt.s)
+			if tt.ok != (err == nil) {
+				t.Fatalf("expected ok %t got err %v", tt.ok, err)
+			}
+
+			if mode != tt.expected {
+				t.Fatalf("expected %s got %s", tt.expected, mode)
+			}
+		})
+	}
+}
+
+func TestApplyMode(t *testing.T) {
+	root := t.TempDir()
+
+	rules := []string{
+		"upload /** *",
+		"makedir /** *",
+	}
+
+	var perms []acl.Rule
+	for _, r := range rules {
+		rule, err := acl.NewRule(r)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		perms = append(perms, rule)
+	}
+
+	permissions, err := acl.NewPermissions(perms)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	opts := &FilesystemOpts{}
+	opts.SetModes(0604, 0705)
+
+	fs, err := NewFilesystem(opts, NewOSFS(root), NewMemoryShadow(), permissions)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	user := newTestUser("user", "group")
+
+	if err := fs.MakeDir("/dir", user); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	w, err := fs.UploadFile("/dir/file", user)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for path, expected := range map[string]os.FileMode{
+		"dir":      0705,
+		"dir/file": 0604,
+	} {
+		finfo, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		if finfo.Mode().Perm() != expected {
+			t.Errorf("expected %s to be %s got %s", path, expected, finfo.Mode().Perm())
+		}
+	}
+}
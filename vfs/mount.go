@@ -0,0 +1,269 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/pkg/errors"
+)
+
+// ErrCrossMount is returned when renaming between two mounts
+var ErrCrossMount = errors.New("can't rename across mounts")
+
+// mount is a filesystem mounted at a virtual path
+type mount struct {
+	path string
+	fs   billy.Filesystem
+}
+
+// MountFS is a billy.Filesystem made up of several filesystems mounted at
+// different virtual paths. Calls are routed to the mount with the longest
+// path containing the file, anything else goes to the root filesystem
+type MountFS struct {
+	root   billy.Filesystem
+	mounts []mount
+}
+
+// NewMountFS creates a MountFS with root mounted at /
+func NewMountFS(root billy.Filesystem) *MountFS {
+	return &MountFS{root: root}
+}
+
+// Mount mounts fs at path, the parent of path must already exist
+func (m *MountFS) Mount(path string, fs billy.Filesystem) error {
+	path = filepath.Clean("/" + path)
+
+	if path == "/" {
+		return errors.New("can't mount at /")
+	}
+
+	for _, mnt := range m.mounts {
+		if mnt.path == path {
+			return errors.Errorf("'%s' is already mounted", path)
+		}
+	}
+
+	finfo, err := m.Stat(filepath.Dir(path))
+	if err != nil {
+		return errors.WithMessagef(err, "parent of mount '%s'", path)
+	}
+
+	if !finfo.IsDir() {
+		return errors.Errorf("parent of mount '%s' is not a directory", path)
+	}
+
+	m.mounts = append(m.mounts, mount{path, fs})
+
+	// longest first so nested mounts win
+	sort.SliceStable(m.mounts, func(i, j int) bool {
+		return len(m.mounts[i].path) > len(m.mounts[j].path)
+	})
+
+	return nil
+}
+
+// route returns the filesystem the path belongs to, the path relative to it
+// and the mount path
+func (m *MountFS) route(path string) (billy.Filesystem, string, string) {
+	path = filepath.Clean("/" + path)
+
+	for _, mnt := range m.mounts {
+		if path == mnt.path {
+			return mnt.fs, "/", mnt.path
+		}
+
+		if strings.HasPrefix(path, mnt.path+"/") {
+			return mnt.fs, path[len(mnt.path):], mnt.path
+		}
+	}
+
+	return m.root, path, "/"
+}
+
+// isMountpoint checks to see if something is mounted at path
+func (m *MountFS) isMountpoint(path string) bool {
+	_, rel, mnt := m.route(path)
+	return rel == "/" && mnt != "/"
+}
+
+func (m *MountFS) Create(filename string) (billy.File, error) {
+	fs, rel, _ := m.route(filename)
+	return fs.Create(rel)
+}
+
+func (m *MountFS) Open(filename string) (billy.File, error) {
+	fs, rel, _ := m.route(filename)
+	return fs.Open(rel)
+}
+
+func (m *MountFS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fs, rel, _ := m.route(filename)
+	return fs.OpenFile(rel, flag, perm)
+}
+
+func (m *MountFS) Stat(filename string) (os.FileInfo, error) {
+	fs, rel, mnt := m.route(filename)
+
+	finfo, err := fs.Stat(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	// the root of a mount is named after its mount point
+	if rel == "/" && mnt != "/" {
+		return mountInfo{finfo, filepath.Base(mnt)}, nil
+	}
+
+	return finfo, nil
+}
+
+func (m *MountFS) Lstat(filename string) (os.FileInfo, error) {
+	fs, rel, mnt := m.route(filename)
+
+	finfo, err := fs.Lstat(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	if rel == "/" && mnt != "/" {
+		return mountInfo{finfo, filepath.Base(mnt)}, nil
+	}
+
+	return finfo, nil
+}
+
+// Rename renames within a mount, renaming between mounts returns ErrCrossMount
+func (m *MountFS) Rename(oldpath, newpath string) error {
+	if m.isMountpoint(oldpath) || m.isMountpoint(newpath) {
+		return errors.New("can't rename a mount point")
+	}
+
+	oldfs, oldrel, oldmnt := m.route(oldpath)
+	_, newrel, newmnt := m.route(newpath)
+
+	if oldmnt != newmnt {
+		return ErrCrossMount
+	}
+
+	return oldfs.Rename(oldrel, newrel)
+}
+
+func (m *MountFS) Remove(filename string) error {
+	if m.isMountpoint(filename) {
+		return errors.New("can't remove a mount point")
+	}
+
+	fs, rel, _ := m.route(filename)
+	return fs.Remove(rel)
+}
+
+func (m *MountFS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (m *MountFS) TempFile(dir, prefix string) (billy.File, error) {
+	fs, rel, _ := m.route(dir)
+	return fs.TempFile(rel, prefix)
+}
+
+// ReadDir lists the directory, mounts directly under it are included even
+// if there is no directory for them in the parent filesystem
+func (m *MountFS) ReadDir(path string) ([]os.FileInfo, error) {
+	fs, rel, _ := m.route(path)
+
+	files, err := fs.ReadDir(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	path = filepath.Clean("/" + path)
+
+	for _, mnt := range m.mounts {
+		if filepath.Dir(mnt.path) != path {
+			continue
+		}
+
+		finfo, err := m.Stat(mnt.path)
+		if err != nil {
+			continue
+		}
+
+		var found bool
+		for idx, f := range files {
+			if f.Name() == finfo.Name() {
+				files[idx] = finfo
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			files = append(files, finfo)
+		}
+	}
+
+	return files, nil
+}
+
+func (m *MountFS) MkdirAll(filename string, perm os.FileMode) error {
+	fs, rel, _ := m.route(filename)
+	return fs.MkdirAll(rel, perm)
+}
+
+func (m *MountFS) Chmod(filename string, mode os.FileMode) error {
+	fs, rel, _ := m.route(filename)
+
+	c, ok := fs.(modeChanger)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return c.Chmod(rel, mode)
+}
+
+func (m *MountFS) Lchown(filename string, uid, gid int) error {
+	fs, rel, _ := m.route(filename)
+
+	c, ok := fs.(modeChanger)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return c.Lchown(rel, uid, gid)
+}
+
+func (m *MountFS) Symlink(target, link string) error {
+	fs, rel, _ := m.route(link)
+	return fs.Symlink(target, rel)
+}
+
+func (m *MountFS) Readlink(link string) (string, error) {
+	fs, rel, _ := m.route(link)
+	return fs.Readlink(rel)
+}
+
+func (m *MountFS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(m, path), nil
+}
+
+func (m *MountFS) Root() string {
+	return m.root.Root()
+}
+
+// FreeSpace returns the bytes available to the mount the path belongs to
+func (m *MountFS) FreeSpace(path string) (uint64, error) {
+	fs, _, _ := m.route(path)
+	return freeSpace(fs)
+}
+
+// mountInfo renames the os.FileInfo of a mount's root
+type mountInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i mountInfo) Name() string { return i.name }
@@ -0,0 +1,124 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/pkg/errors"
+)
+
+func TestMountFS(t *testing.T) {
+	root := memfs.New()
+	archive := memfs.New()
+	nested := memfs.New()
+
+	checkErr(t, util.WriteFile(root, "/site/file", []byte("ROOT"), defaultPerms), nil)
+	checkErr(t, util.WriteFile(archive, "/old/file", []byte("ARCHIVE"), defaultPerms), nil)
+	checkErr(t, util.WriteFile(nested, "/file", []byte("NESTED"), defaultPerms), nil)
+
+	mfs := NewMountFS(root)
+
+	checkErr(t, mfs.Mount("/site/archive", archive), nil)
+	checkErr(t, mfs.Mount("/site/archive/old/nested", nested), nil)
+
+	checkErr(t, mfs.Mount("/site/archive", archive), errors.New("'/site/archive' is already mounted"))
+	checkErr(t, mfs.Mount("/missing/dir", archive), os.ErrNotExist)
+	checkErr(t, mfs.Mount("/", archive), errors.New("can't mount at /"))
+
+	var tests = []struct {
+		path     string
+		contents string
+	}{
+		{"/site/file", "ROOT"},
+		{"/site/archive/old/file", "ARCHIVE"},
+		{"/site/archive/old/nested/file", "NESTED"},
+	}
+
+	for _, tt := range tests {
+		f, err := mfs.Open(tt.path)
+		checkErr(t, err, nil)
+
+		b, err := ioutil.ReadAll(f)
+		checkErr(t, err, nil)
+		f.Close()
+
+		if string(b) != tt.contents {
+			t.Errorf("expected '%s' to contain '%s' got '%s'", tt.path, tt.contents, string(b))
+		}
+	}
+
+	// mount points are listed in their parent
+	files, err := mfs.ReadDir("/site")
+	checkErr(t, err, nil)
+
+	names := make(map[string]bool)
+	for _, f := range files {
+		names[f.Name()] = f.IsDir()
+	}
+
+	if len(names) != 2 || !names["archive"] {
+		t.Errorf("unexpected listing of /site: %v", names)
+	}
+
+	finfo, err := mfs.Stat("/site/archive/old/nested")
+	checkErr(t, err, nil)
+
+	if finfo.Name() != "nested" || !finfo.IsDir() {
+		t.Errorf("unexpected stat of mount point: %s %t", finfo.Name(), finfo.IsDir())
+	}
+
+	// writes go to the mount
+	checkErr(t, mfs.MkdirAll("/site/archive/new", defaultPerms), nil)
+
+	if _, err := archive.Stat("/new"); err != nil {
+		t.Errorf("expected directory to be created in archive: %s", err)
+	}
+
+	checkErr(t, mfs.Rename("/site/archive/old/file", "/site/archive/new/file"), nil)
+	checkErr(t, mfs.Rename("/site/file", "/site/archive/file"), ErrCrossMount)
+	checkErr(t, mfs.Rename("/site/archive", "/site/moved"), errors.New("can't rename a mount point"))
+	checkErr(t, mfs.Remove("/site/archive"), errors.New("can't remove a mount point"))
+}
+
+func TestFilesystemMounts(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"download /** *",
+		"upload /** *",
+		"rename /** *",
+		"makedir /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	archive := memfs.New()
+	checkErr(t, archive.MkdirAll("/", defaultPerms), nil)
+
+	mfs := NewMountFS(fs.chroot)
+	checkErr(t, mfs.Mount("/archive", archive), nil)
+
+	fs.chroot = mfs
+
+	user := newTestUser("user", "group")
+
+	checkErr(t, fs.MakeDir("/archive/dir", user), nil)
+
+	if _, err := archive.Stat("/dir"); err != nil {
+		t.Errorf("expected directory to be created in archive: %s", err)
+	}
+
+	files, err := fs.ListDir("/", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 1 || files[0].Name() != "archive" {
+		t.Errorf("expected archive in listing got %v", files)
+	}
+
+	createFile(t, fs, "/file", "FILE")
+
+	checkErr(t, fs.RenameFile("/file", "/archive/file", user), ErrCrossMount)
+}
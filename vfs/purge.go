@@ -0,0 +1,77 @@
+package vfs
+
+import (
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/pkg/errors"
+)
+
+// Purge removes everything matching the glob pattern, i.e. /tmp/*, along
+// with everything under it. No permissions are checked, it is meant for
+// jobs run by the server itself. Returns what was removed even if it failed
+// part way
+func (fs *Filesystem) Purge(pattern string) (Removed, error) {
+	var total Removed
+
+	matches, err := util.Glob(fs.chroot, filepath.Clean("/"+pattern))
+	if err != nil {
+		return total, err
+	}
+
+	for _, path := range matches {
+		if filepath.Clean("/"+path) == "/" {
+			return total, errors.New("refusing to purge the root")
+		}
+
+		removed, err := fs.Remove(path)
+
+		total.Files += removed.Files
+		total.Dirs += removed.Dirs
+		total.Bytes += removed.Bytes
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Remove removes the file or directory at path along with everything under
+// it. No permissions are checked, it is meant for the server itself, i.e.
+// deleting infected uploads. Returns what was removed even if it failed
+// part way
+func (fs *Filesystem) Remove(path string) (Removed, error) {
+	path = filepath.Clean("/" + path)
+
+	if path == "/" {
+		return Removed{}, errors.New("refusing to remove the root")
+	}
+
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return Removed{}, err
+	}
+
+	removed, err := fs.removeTree(path, nil)
+	if err != nil {
+		return removed, err
+	}
+
+	e := Event{Type: EventDeleteFile, Path: path, Wipe: true}
+
+	if finfo.IsDir() {
+		e.Type = EventDeleteDir
+		e.Dir = true
+	}
+
+	fs.events.Publish(e)
+
+	if !finfo.IsDir() {
+		// the file is gone whether or not the markers can be updated
+		fs.zipscriptDelete(path)
+	}
+
+	return removed, nil
+}
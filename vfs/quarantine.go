@@ -0,0 +1,63 @@
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// maxQuarantineNames is how many names are tried when a file of the same
+// name is already in quarantine
+const maxQuarantineNames = 100
+
+// Quarantine moves the file at path in to the dir, created if it doesn't
+// exist, and returns where it was moved to. A number is added to the name
+// if the dir already has a file of the same name. No permissions are
+// checked, it is meant for the server itself, i.e. isolating infected
+// uploads. Hiding the dir from users is left to the acl
+func (fs *Filesystem) Quarantine(path, dir string) (string, error) {
+	path = filepath.Clean("/" + path)
+	dir = filepath.Clean("/" + dir)
+
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if finfo.IsDir() {
+		return "", errors.New("can only quarantine files")
+	}
+
+	if err := fs.chroot.MkdirAll(dir, fs.createPerms(true)); err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(path)
+	dest := filepath.Join(dir, name)
+
+	for n := 1; ; n++ {
+		if _, err := fs.chroot.Lstat(dest); os.IsNotExist(err) {
+			break
+		}
+
+		if n == maxQuarantineNames {
+			return "", errors.Errorf("'%s' is already in quarantine", name)
+		}
+
+		dest = filepath.Join(dir, fmt.Sprintf("%s.%d", name, n))
+	}
+
+	if err := fs.moveTree(path, dest); err != nil {
+		return "", err
+	}
+
+	fs.events.Publish(Event{Type: EventRename, Path: path, NewPath: dest})
+
+	// the file has left the release whether or not the markers can be
+	// updated
+	fs.zipscriptDelete(path)
+
+	return dest, nil
+}
@@ -0,0 +1,53 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuarantine(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release", defaultPerms), nil)
+
+	createFile(t, fs, "/mp3/release/virus.exe", "AB")
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	dest, err := fs.Quarantine("/mp3/release/virus.exe", "/.quarantine")
+	checkErr(t, err, nil)
+
+	if dest != "/.quarantine/virus.exe" {
+		t.Errorf("unexpected dest: %s", dest)
+	}
+
+	if _, err := fs.chroot.Stat("/mp3/release/virus.exe"); err == nil {
+		t.Error("expected the file to be moved")
+	}
+
+	if _, err := fs.chroot.Stat(dest); err != nil {
+		t.Errorf("expected the file in quarantine: %s", err)
+	}
+
+	if len(events) != 1 || events[0].Type != EventRename || events[0].NewPath != dest {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// the same name again is numbered
+	createFile(t, fs, "/mp3/release/virus.exe", "C")
+
+	dest, err = fs.Quarantine("/mp3/release/virus.exe", "/.quarantine")
+	checkErr(t, err, nil)
+
+	if dest != "/.quarantine/virus.exe.1" {
+		t.Errorf("unexpected dest: %s", dest)
+	}
+
+	_, err = fs.Quarantine("/mp3/release", "/.quarantine")
+	checkErr(t, err, fmt.Errorf("can only quarantine files"))
+}
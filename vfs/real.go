@@ -0,0 +1,34 @@
+package vfs
+
+import (
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// realPather is implemented by filesystems that can work out where a path
+// is on the host themselves, i.e. MountFS
+type realPather interface {
+	RealPath(string) string
+}
+
+// realPath returns where path is on the host filesystem rooted at fs
+func realPath(fs billy.Filesystem, path string) string {
+	if r, ok := fs.(realPather); ok {
+		return r.RealPath(path)
+	}
+
+	return filepath.Join(fs.Root(), filepath.Clean("/"+path))
+}
+
+// RealPath returns where the path is on the host, i.e. to hand to external
+// programs. No permissions are checked and the path may not exist
+func (fs *Filesystem) RealPath(path string) string {
+	return realPath(fs.chroot, path)
+}
+
+// RealPath returns where the path is on the host of the mount it belongs to
+func (m *MountFS) RealPath(path string) string {
+	fs, rel, _ := m.route(path)
+	return realPath(fs, rel)
+}
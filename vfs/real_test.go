@@ -0,0 +1,42 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/osfs"
+)
+
+func TestRealPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goftpd-real")
+	checkErr(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	rootDir := filepath.Join(dir, "root")
+	archiveDir := filepath.Join(dir, "archive")
+
+	checkErr(t, os.MkdirAll(filepath.Join(rootDir, "site"), 0755), nil)
+	checkErr(t, os.MkdirAll(archiveDir, 0755), nil)
+
+	mfs := NewMountFS(osfs.New(rootDir))
+	checkErr(t, mfs.Mount("/site/archive", osfs.New(archiveDir)), nil)
+
+	var tests = []struct {
+		path     string
+		expected string
+	}{
+		{"/", rootDir},
+		{"/site/file", filepath.Join(rootDir, "site/file")},
+		{"/site/archive", archiveDir},
+		{"/site/archive/old/file", filepath.Join(archiveDir, "old/file")},
+		{"/site/../../escape", filepath.Join(rootDir, "escape")},
+	}
+
+	for _, tt := range tests {
+		if got := realPath(mfs, tt.path); got != tt.expected {
+			t.Errorf("expected '%s' to be at '%s' got '%s'", tt.path, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+// Rotation deletes directories in Path older than Days, or moves them in
+// to Dest if it is set
+type Rotation struct {
+	Path string
+	Days int
+	Dest string
+}
+
+// NewRotation takes a line of text (i.e. from a config file) in the form of
+// `<path> <days> [dest]`
+func NewRotation(line string) (Rotation, error) {
+	var r Rotation
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 || len(fields) > 3 {
+		return r, errors.New("rotate requires `<path> <days> [dest]`")
+	}
+
+	r.Path = filepath.Clean("/" + fields[0])
+
+	days, err := strconv.Atoi(fields[1])
+	if err != nil || days <= 0 {
+		return r, errors.Errorf("days must be a positive number, got '%s'", fields[1])
+	}
+
+	r.Days = days
+
+	if len(fields) == 3 {
+		r.Dest = filepath.Clean("/" + fields[2])
+
+		if r.Dest == r.Path {
+			return r, errors.New("dest can't be the same as path")
+		}
+	}
+
+	return r, nil
+}
+
+// Rotated describes a directory that was rotated, Dest is empty if it was
+// deleted. Err is set if rotating it failed
+type Rotated struct {
+	Path string
+	Dest string
+	Err  error
+}
+
+func (f *FilesystemOpts) SetRotations(rotations []Rotation) { f.rotations = rotations }
+
+// SetRotateExcludes sets the globs of directories that are never rotated
+func (f *FilesystemOpts) SetRotateExcludes(excludes []glob.Glob) { f.rotateExcludes = excludes }
+
+// Rotate deletes or moves the directories in each rotation that are older than
+// its days at now. A directory's age comes from its shadow creation time or
+// its modification time if that is unknown. Failing to rotate a directory
+// doesn't stop the others, check Err of each Rotated
+func (fs *Filesystem) Rotate(now time.Time) ([]Rotated, error) {
+	var results []Rotated
+
+	for _, r := range fs.rotations {
+		files, err := fs.chroot.ReadDir(r.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return results, err
+		}
+
+		cutoff := now.AddDate(0, 0, -r.Days)
+
+		for _, f := range files {
+			// links (i.e. today links) and files are left alone
+			if !f.IsDir() || f.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+
+			path := filepath.Join(r.Path, f.Name())
+
+			if fs.rotateExcluded(path) {
+				continue
+			}
+
+			created := f.ModTime()
+			if e, err := fs.shadow.GetEntry(path); err == nil && !e.CreatedAt.IsZero() {
+				created = e.CreatedAt
+			}
+
+			if !created.Before(cutoff) {
+				continue
+			}
+
+			result := Rotated{Path: path}
+
+			if len(r.Dest) > 0 {
+				result.Dest = filepath.Join(r.Dest, f.Name())
+
+				if err := fs.chroot.MkdirAll(r.Dest, fs.createPerms(true)); err != nil {
+					result.Err = err
+				} else {
+					result.Err = fs.moveTree(path, result.Dest)
+				}
+			} else {
+				_, result.Err = fs.removeTree(path, nil)
+			}
+
+			if result.Err == nil {
+				e := Event{Type: EventDeleteDir, Path: path, Dir: true}
+
+				if len(result.Dest) > 0 {
+					e.Type = EventRename
+					e.NewPath = result.Dest
+				}
+
+				fs.events.Publish(e)
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// rotateExcluded checks to see if path matches any of the rotate excludes
+func (fs *Filesystem) rotateExcluded(path string) bool {
+	path = strings.ToLower(path)
+
+	for _, g := range fs.rotateExcludes {
+		if g.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package vfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+func TestNewRotation(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"/0day 14", nil},
+		{"/mp3 30 /archive/mp3", nil},
+		{"/0day", errors.New("rotate requires `<path> <days> [dest]`")},
+		{"/0day never", errors.New("days must be a positive number, got 'never'")},
+		{"/0day 0", errors.New("days must be a positive number, got '0'")},
+		{"/0day 14 /0day/", errors.New("dest can't be the same as path")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewRotation(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{"download /** *"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	now := time.Now()
+
+	dirs := map[string]time.Time{
+		"/0day/old":   now.AddDate(0, 0, -20),
+		"/0day/new":   now.AddDate(0, 0, -2),
+		"/0day/_keep": now.AddDate(0, 0, -20),
+		"/mp3/old":    now.AddDate(0, 0, -40),
+	}
+
+	for dir, created := range dirs {
+		if err := fs.chroot.MkdirAll(dir, defaultPerms); err != nil {
+			t.Fatalf("unexpected err creating %s: %s", dir, err)
+		}
+
+		checkErr(t, fs.shadow.SetEntry(dir, ShadowEntry{User: "user", Group: "group", CreatedAt: created}), nil)
+	}
+
+	createFile(t, fs, "/0day/old/file", "FILE")
+	createFile(t, fs, "/mp3/old/track.mp3", "TRACK")
+	checkErr(t, fs.shadow.Set("/mp3/old/track.mp3", "user", "group"), nil)
+
+	var rotations []Rotation
+	for _, l := range []string{"/0day 14", "/mp3 30 /archive/mp3"} {
+		r, err := NewRotation(l)
+		checkErr(t, err, nil)
+		rotations = append(rotations, r)
+	}
+
+	fs.SetRotations(rotations)
+	fs.SetRotateExcludes([]glob.Glob{glob.MustCompile("/0day/_*", '/')})
+
+	rotated, err := fs.Rotate(now)
+	checkErr(t, err, nil)
+
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated got %+v", rotated)
+	}
+
+	for _, r := range rotated {
+		checkErr(t, r.Err, nil)
+	}
+
+	if rotated[0].Path != "/0day/old" || len(rotated[0].Dest) > 0 {
+		t.Errorf("expected /0day/old to be wiped got %+v", rotated[0])
+	}
+
+	if rotated[1].Path != "/mp3/old" || rotated[1].Dest != "/archive/mp3/old" {
+		t.Errorf("expected /mp3/old to be moved got %+v", rotated[1])
+	}
+
+	for _, p := range []string{"/0day/old", "/mp3/old"} {
+		if _, err := fs.chroot.Stat(p); err == nil {
+			t.Errorf("expected '%s' to be gone", p)
+		}
+	}
+
+	for _, p := range []string{"/0day/new", "/0day/_keep", "/archive/mp3/old/track.mp3"} {
+		if _, err := fs.chroot.Stat(p); err != nil {
+			t.Errorf("expected '%s' to exist: %s", p, err)
+		}
+	}
+
+	// shadow entries move with the directory
+	owner, _, err := fs.shadow.Get("/archive/mp3/old/track.mp3")
+	checkErr(t, err, nil)
+
+	if owner != "user" {
+		t.Errorf("expected moved owner to be user got '%s'", owner)
+	}
+
+	if _, err := fs.shadow.GetEntry("/0day/old/file"); err != ErrNoPath {
+		t.Errorf("expected wiped shadow entry to be removed got %v", err)
+	}
+}
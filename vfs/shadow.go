@@ -3,8 +3,12 @@ package vfs
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v2"
 	"github.com/pkg/errors"
@@ -14,17 +18,39 @@ import (
 // Error thrown when the requested path does not exist
 var ErrNoPath = errors.New("path does not exist")
 
+// sizes of directories are stored under their hash with this prefix
+var shadowSizePrefix = []byte("size:")
+
 // "constants" to the splitter used
 var shadowEntrySplitter = ":"
 var shadowEntrySplitterBytes = []byte(shadowEntrySplitter)
 
+// ShadowEntry is the meta data stored for a path
+type ShadowEntry struct {
+	User  string
+	Group string
+
+	// zero for entries stored before creation times were recorded
+	CreatedAt time.Time
+
+	// recorded for files uploaded or verified, zero otherwise
+	Checksum Checksum
+}
+
 // Shadow represents a shadow filesystem where meta data is
 // stored
 type Shadow interface {
 	Hash(string) []byte
 	Set(string, string, string) error
+	SetEntry(string, ShadowEntry) error
 	Get(string) (string, string, error)
+	GetEntry(string) (ShadowEntry, error)
 	Remove(string) error
+	Size(string) (int64, error)
+	SetSize(string, int64) error
+	AdjustSize(string, int64) error
+	ReassignUser(string, string) error
+	ReassignGroup(string, string) error
 	Close() error
 }
 
@@ -57,15 +83,24 @@ func (s *ShadowStore) Hash(path string) []byte {
 	return b
 }
 
-// createVal does some validation on the given user and group to make sure that
-// they can safely be placed in the store
-func (s *ShadowStore) createVal(user, group string) ([]byte, error) {
+// checkOwner makes sure the user and group can be stored in a Shadow
+func checkOwner(user, group string) error {
 	if strings.Contains(user, shadowEntrySplitter) {
-		return nil, errors.Errorf("user can't contain '%s'", shadowEntrySplitter)
+		return errors.Errorf("user can't contain '%s'", shadowEntrySplitter)
 	}
 
 	if strings.Contains(group, shadowEntrySplitter) {
-		return nil, errors.Errorf("group can't contain '%s'", shadowEntrySplitter)
+		return errors.Errorf("group can't contain '%s'", shadowEntrySplitter)
+	}
+
+	return nil
+}
+
+// createVal does some validation on the given user and group to make sure that
+// they can safely be placed in the store
+func (s *ShadowStore) createVal(user, group string) ([]byte, error) {
+	if err := checkOwner(user, group); err != nil {
+		return nil, err
 	}
 
 	val := []byte(strings.ToLower(fmt.Sprintf("%s%s%s", user, shadowEntrySplitter, group)))
@@ -73,35 +108,149 @@ func (s *ShadowStore) createVal(user, group string) ([]byte, error) {
 	return val, nil
 }
 
-// Set a path with it's meta data to the store. Overwrites any
-// existing value.
+// entryVal creates the value stored for the entry, user:group:created with
+// :crc32:sha256 appended if the entry has a checksum
+func (s *ShadowStore) entryVal(e ShadowEntry) ([]byte, error) {
+	val, err := s.createVal(e.User, e.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	var created int64
+	if !e.CreatedAt.IsZero() {
+		created = e.CreatedAt.Unix()
+	}
+
+	val = append(val, []byte(shadowEntrySplitter+strconv.FormatInt(created, 10))...)
+
+	if !e.Checksum.IsZero() {
+		val = append(val, []byte(fmt.Sprintf("%s%08x%s%x", shadowEntrySplitter, e.Checksum.CRC32, shadowEntrySplitter, e.Checksum.SHA256))...)
+	}
+
+	return val, nil
+}
+
+// parseVal parses a value created by entryVal, values without a creation
+// time or checksum are also accepted
+func (s *ShadowStore) parseVal(key, val []byte) (ShadowEntry, error) {
+	parts := bytes.Split(val, shadowEntrySplitterBytes)
+	if len(parts) != 2 && len(parts) != 3 && len(parts) != 5 {
+		return ShadowEntry{}, errors.Errorf("expected 2, 3 or 5 parts to key: '%x': '%s'", key, string(val))
+	}
+
+	e := ShadowEntry{
+		User:  string(parts[0]),
+		Group: string(parts[1]),
+	}
+
+	if len(parts) >= 3 {
+		created, err := strconv.ParseInt(string(parts[2]), 10, 64)
+		if err != nil {
+			return ShadowEntry{}, errors.Errorf("bad created time in key: '%x': '%s'", key, string(val))
+		}
+
+		if created > 0 {
+			e.CreatedAt = time.Unix(created, 0)
+		}
+	}
+
+	if len(parts) == 5 {
+		crc, err := strconv.ParseUint(string(parts[3]), 16, 32)
+		if err != nil {
+			return ShadowEntry{}, errors.Errorf("bad crc32 in key: '%x': '%s'", key, string(val))
+		}
+
+		sha, err := hex.DecodeString(string(parts[4]))
+		if err != nil {
+			return ShadowEntry{}, errors.Errorf("bad sha256 in key: '%x': '%s'", key, string(val))
+		}
+
+		e.Checksum = Checksum{uint32(crc), sha}
+	}
+
+	return e, nil
+}
+
+// Set a path with it's meta data to the store. Overwrites the user and
+// group of any existing value, keeping its creation time and checksum
 func (s *ShadowStore) Set(path, user, group string) error {
 	key := s.Hash(path)
-	val, err := s.createVal(user, group)
-	if err != nil {
+
+	if _, err := s.createVal(user, group); err != nil {
 		return err
 	}
 
-	err = s.store.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(key, val); err != nil {
+	e := ShadowEntry{
+		User:      user,
+		Group:     group,
+		CreatedAt: time.Now(),
+	}
+
+	return s.store.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
 			return err
 		}
 
-		return nil
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				existing, err := s.parseVal(key, val)
+				if err != nil {
+					// overwrite bad values
+					return nil
+				}
+
+				if !existing.CreatedAt.IsZero() {
+					e.CreatedAt = existing.CreatedAt
+				}
+				e.Checksum = existing.Checksum
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		val, err := s.entryVal(e)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(key, val)
 	})
+}
+
+// SetEntry sets the entry for a path, overwriting any existing value
+func (s *ShadowStore) SetEntry(path string, e ShadowEntry) error {
+	key := s.Hash(path)
 
+	val, err := s.entryVal(e)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return s.store.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, val)
+	})
 }
 
 // Get tries to retrieve the user and group for a path
 func (s *ShadowStore) Get(path string) (string, string, error) {
+	e, err := s.GetEntry(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.User, e.Group, nil
+}
+
+// GetEntry tries to retrieve the entry for a path
+func (s *ShadowStore) GetEntry(path string) (ShadowEntry, error) {
 	key := s.Hash(path)
 
-	var user, group string
+	var e ShadowEntry
 
 	err := s.store.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
@@ -109,38 +258,24 @@ func (s *ShadowStore) Get(path string) (string, string, error) {
 			return err
 		}
 
-		err = item.Value(func(val []byte) error {
-			parts := bytes.Split(val, shadowEntrySplitterBytes)
-			if len(parts) != 2 {
-				return errors.Errorf("expected 2 parts to key: '%x': '%s'", key, string(val))
-			}
-
-			user = string(parts[0])
-			group = string(parts[1])
-
-			return nil
-		})
-
-		if err != nil {
+		return item.Value(func(val []byte) error {
+			e, err = s.parseVal(key, val)
 			return err
-		}
-
-		return nil
+		})
 	})
 
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
-			return "", "", ErrNoPath
+			return ShadowEntry{}, ErrNoPath
 		}
 
-		// err has been set
-		return "", "", err
+		return ShadowEntry{}, err
 	}
 
-	return user, group, nil
+	return e, nil
 }
 
-// Remove deletes an entry from the store
+// Remove deletes an entry and any cached size from the store
 func (s *ShadowStore) Remove(path string) error {
 	key := s.Hash(path)
 
@@ -149,6 +284,10 @@ func (s *ShadowStore) Remove(path string) error {
 			return err
 		}
 
+		if err := txn.Delete(s.sizeKey(path)); err != nil {
+			return err
+		}
+
 		return nil
 	})
 
@@ -159,7 +298,219 @@ func (s *ShadowStore) Remove(path string) error {
 	return nil
 }
 
+// sizeKey is the key the size of a directory is stored under
+func (s *ShadowStore) sizeKey(path string) []byte {
+	return append(append([]byte{}, shadowSizePrefix...), s.Hash(path)...)
+}
+
+// Size returns the cached size of everything under a directory, ErrNoPath
+// is returned if it isn't cached
+func (s *ShadowStore) Size(path string) (int64, error) {
+	var size int64
+
+	err := s.store.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.sizeKey(path))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return errors.Errorf("bad size for '%s'", path)
+			}
+			size = int64(binary.LittleEndian.Uint64(val))
+			return nil
+		})
+	})
+
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, ErrNoPath
+		}
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// SetSize caches the size of everything under a directory
+func (s *ShadowStore) SetSize(path string, size int64) error {
+	return s.store.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.sizeKey(path), sizeVal(size))
+	})
+}
+
+// AdjustSize adds delta to the cached size of path and each of its parents.
+// Directories without a cached size are left alone so they are calculated
+// in full when next asked for. Retried on conflict as concurrent uploads
+// share parents
+func (s *ShadowStore) AdjustSize(path string, delta int64) error {
+	var err error
+
+	for i := 0; i < maxSizeRetries; i++ {
+		err = s.store.Update(func(txn *badger.Txn) error {
+			for _, p := range parents(path) {
+				key := s.sizeKey(p)
+
+				item, err := txn.Get(key)
+				if err != nil {
+					if err == badger.ErrKeyNotFound {
+						continue
+					}
+					return err
+				}
+
+				var size int64
+
+				err = item.Value(func(val []byte) error {
+					if len(val) == 8 {
+						size = int64(binary.LittleEndian.Uint64(val))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+
+				if err := txn.Set(key, sizeVal(size+delta)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+
+	return err
+}
+
+// ReassignUser changes the owner of every entry owned by from to be owned by to.
+// Satisfies the acl.Reconciler interface
+func (s *ShadowStore) ReassignUser(from, to string) error {
+	from = strings.ToLower(from)
+
+	return s.reassign(func(user, group string) (string, string, bool) {
+		if user != from {
+			return user, group, false
+		}
+		return to, group, true
+	})
+}
+
+// ReassignGroup changes the group of every entry owned by from to be owned by to.
+// Satisfies the acl.Reconciler interface
+func (s *ShadowStore) ReassignGroup(from, to string) error {
+	from = strings.ToLower(from)
+
+	return s.reassign(func(user, group string) (string, string, bool) {
+		if group != from {
+			return user, group, false
+		}
+		return user, to, true
+	})
+}
+
+// reassign walks every entry in the store and calls fn with the user and group,
+// if fn returns true the entry is updated with the returned user and group
+func (s *ShadowStore) reassign(fn func(string, string) (string, string, bool)) error {
+	type update struct {
+		key []byte
+		val []byte
+	}
+
+	var updates []update
+
+	err := s.store.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+
+			// cached sizes aren't entries
+			if bytes.HasPrefix(item.Key(), shadowSizePrefix) {
+				continue
+			}
+
+			err := item.Value(func(val []byte) error {
+				e, err := s.parseVal(item.Key(), val)
+				if err != nil {
+					return err
+				}
+
+				user, group, ok := fn(e.User, e.Group)
+				if !ok {
+					return nil
+				}
+
+				e.User = user
+				e.Group = group
+
+				newVal, err := s.entryVal(e)
+				if err != nil {
+					return err
+				}
+
+				updates = append(updates, update{item.KeyCopy(nil), newVal})
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// use a batch as there is potential for the updates to be
+	// too big for a single transaction
+	wb := s.store.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, u := range updates {
+		if err := wb.Set(u.key, u.val); err != nil {
+			return err
+		}
+	}
+
+	return wb.Flush()
+}
+
 // Close closes the underlying badger store
 func (s *ShadowStore) Close() error {
 	return s.store.Close()
 }
+
+// maximum number of times AdjustSize is retried on conflict
+const maxSizeRetries = 10
+
+// sizeVal encodes a size for storing
+func sizeVal(size int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(size))
+	return b
+}
+
+// parents returns path and each of its parents up to the root
+func parents(path string) []string {
+	path = filepath.Clean("/" + path)
+
+	paths := []string{path}
+
+	for path != "/" {
+		path = filepath.Dir(path)
+		paths = append(paths, path)
+	}
+
+	return paths
+}
@@ -3,6 +3,7 @@ package vfs
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v2"
 )
@@ -152,9 +153,195 @@ func TestShadowStoreBadValue(t *testing.T) {
 		t.Fatal("expected error on get")
 	}
 
-	expectedErr := fmt.Sprintf("expected 2 parts to key: '%x': '%s'", key, badValue)
+	expectedErr := fmt.Sprintf("expected 2, 3 or 5 parts to key: '%x': '%s'", key, badValue)
 
 	if err.Error() != expectedErr {
 		t.Fatalf("unexpected error: %s", err)
 	}
 }
+
+func TestShadowStoreReassign(t *testing.T) {
+	ss := newMemoryShadowStore(t)
+	defer closeMemoryShadowStore(t, ss)
+
+	var entries = []struct {
+		path  string
+		user  string
+		group string
+	}{
+		{"/a", "user0", "group0"},
+		{"/a/b", "user1", "group0"},
+		{"/a/c", "user0", "group1"},
+	}
+
+	for _, e := range entries {
+		if err := ss.Set(e.path, e.user, e.group); err != nil {
+			t.Fatalf("unexpected err adding %s:%s:%s: %s", e.path, e.user, e.group, err)
+		}
+	}
+
+	if err := ss.ReassignUser("USER0", "nobody"); err != nil {
+		t.Fatalf("unexpected err reassigning user: %s", err)
+	}
+
+	if err := ss.ReassignGroup("group0", "nogroup"); err != nil {
+		t.Fatalf("unexpected err reassigning group: %s", err)
+	}
+
+	var expected = []struct {
+		path  string
+		user  string
+		group string
+	}{
+		{"/a", "nobody", "nogroup"},
+		{"/a/b", "user1", "nogroup"},
+		{"/a/c", "nobody", "group1"},
+	}
+
+	for _, e := range expected {
+		user, group, err := ss.Get(e.path)
+		if err != nil {
+			t.Fatalf("unexpected err getting %s: %s", e.path, err)
+		}
+
+		if user != e.user {
+			t.Errorf("expected user for '%s' to be '%s' got '%s'", e.path, e.user, user)
+		}
+
+		if group != e.group {
+			t.Errorf("expected group for '%s' to be '%s' got '%s'", e.path, e.group, group)
+		}
+	}
+}
+
+func TestShadowStoreCreatedAt(t *testing.T) {
+	ss := newMemoryShadowStore(t)
+	defer closeMemoryShadowStore(t, ss)
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	err := ss.SetEntry("/a", ShadowEntry{User: "user", Group: "group", CreatedAt: created})
+	if err != nil {
+		t.Fatalf("unexpected err setting entry: %s", err)
+	}
+
+	// setting the owner keeps the creation time
+	if err := ss.Set("/a", "other", "group"); err != nil {
+		t.Fatalf("unexpected err setting: %s", err)
+	}
+
+	e, err := ss.GetEntry("/a")
+	if err != nil {
+		t.Fatalf("unexpected err getting entry: %s", err)
+	}
+
+	if e.User != "other" || !e.CreatedAt.Equal(created) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	// new entries are given the current time
+	if err := ss.Set("/b", "user", "group"); err != nil {
+		t.Fatalf("unexpected err setting: %s", err)
+	}
+
+	e, err = ss.GetEntry("/b")
+	if err != nil {
+		t.Fatalf("unexpected err getting entry: %s", err)
+	}
+
+	if time.Since(e.CreatedAt) > time.Minute {
+		t.Errorf("expected recent creation time got %s", e.CreatedAt)
+	}
+
+	// entries stored without a creation time are still readable
+	key := ss.Hash("/c")
+
+	err = ss.(*ShadowStore).store.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, []byte("user:group"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error for manual insert of key: %s", err)
+	}
+
+	e, err = ss.GetEntry("/c")
+	if err != nil {
+		t.Fatalf("unexpected err getting entry: %s", err)
+	}
+
+	if e.User != "user" || e.Group != "group" || !e.CreatedAt.IsZero() {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestShadowStoreChecksum(t *testing.T) {
+	ss := newMemoryShadowStore(t)
+	defer closeMemoryShadowStore(t, ss)
+
+	sum := Checksum{0xdeadbeef, []byte{1, 2, 3, 4}}
+
+	err := ss.SetEntry("/a", ShadowEntry{User: "user", Group: "group", Checksum: sum})
+	if err != nil {
+		t.Fatalf("unexpected err setting entry: %s", err)
+	}
+
+	// setting the owner keeps the checksum
+	if err := ss.Set("/a", "other", "group"); err != nil {
+		t.Fatalf("unexpected err setting: %s", err)
+	}
+
+	e, err := ss.GetEntry("/a")
+	if err != nil {
+		t.Fatalf("unexpected err getting entry: %s", err)
+	}
+
+	if e.User != "other" || !e.Checksum.Equal(sum) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestShadowStoreSize(t *testing.T) {
+	ss := newMemoryShadowStore(t)
+	defer closeMemoryShadowStore(t, ss)
+
+	if _, err := ss.Size("/a"); err != ErrNoPath {
+		t.Fatalf("expected ErrNoPath got: %v", err)
+	}
+
+	checkErr(t, ss.SetSize("/", 100), nil)
+	checkErr(t, ss.SetSize("/a/b", 10), nil)
+
+	// /a isn't cached so is left alone
+	checkErr(t, ss.AdjustSize("/a/b", 5), nil)
+
+	var expected = []struct {
+		path string
+		size int64
+	}{
+		{"/", 105},
+		{"/A/B", 15},
+	}
+
+	for _, e := range expected {
+		size, err := ss.Size(e.path)
+		checkErr(t, err, nil)
+
+		if size != e.size {
+			t.Errorf("expected size of '%s' to be %d got %d", e.path, e.size, size)
+		}
+	}
+
+	if _, err := ss.Size("/a"); err != ErrNoPath {
+		t.Errorf("expected /a to not be cached got: %v", err)
+	}
+
+	// sizes are skipped when reassigning
+	checkErr(t, ss.Set("/a/b", "user", "group"), nil)
+	checkErr(t, ss.ReassignUser("user", "nobody"), nil)
+
+	// and removed with their entry
+	checkErr(t, ss.Remove("/a/b"), nil)
+
+	if _, err := ss.Size("/a/b"); err != ErrNoPath {
+		t.Errorf("expected size to be removed got: %v", err)
+	}
+}
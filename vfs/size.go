@@ -0,0 +1,162 @@
+package vfs
+
+import (
+	"path/filepath"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// DirSize checks to see if the user has permission to read the directory and
+// returns the size in bytes of every file under it. Sizes are cached in the
+// shadow and kept up to date as files are uploaded and deleted, so the disk
+// is only walked the first time a directory is asked for
+func (fs *Filesystem) DirSize(path string, user *acl.User) (int64, error) {
+	resolved, err := fs.checkDownload(path, user)
+	if err != nil {
+		return 0, err
+	}
+
+	finfo, err := fs.chroot.Stat(resolved)
+	if err != nil {
+		return 0, err
+	}
+
+	if !finfo.IsDir() {
+		return 0, errors.New("not a directory.")
+	}
+
+	return fs.dirSize(resolved)
+}
+
+// dirSize returns the cached size of the directory, calculating and caching
+// it and every directory under it if it isn't cached. Links aren't followed
+func (fs *Filesystem) dirSize(path string) (int64, error) {
+	size, err := fs.shadow.Size(path)
+	if err == nil {
+		return size, nil
+	}
+
+	if err != ErrNoPath {
+		return 0, err
+	}
+
+	files, err := fs.chroot.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+
+	size = 0
+
+	for _, f := range files {
+		switch {
+		case f.IsDir():
+			n, err := fs.dirSize(filepath.Join(path, f.Name()))
+			if err != nil {
+				return 0, err
+			}
+			size += n
+
+		case f.Mode().IsRegular():
+			size += f.Size()
+		}
+	}
+
+	if err := fs.shadow.SetSize(path, size); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// RecalculateSizes replaces the cached sizes of the directory at path and
+// every directory under it with their size on disk, i.e. after files were
+// changed outside of the server. The cached sizes of its parents are
+// adjusted to match. No permissions are checked. Returns the size of the
+// directory
+func (fs *Filesystem) RecalculateSizes(path string) (int64, error) {
+	path = filepath.Clean("/" + path)
+
+	old, oldErr := fs.shadow.Size(path)
+
+	size, err := fs.recalculateSize(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if oldErr == nil && path != "/" {
+		if err := fs.adjustSize(path, size-old); err != nil {
+			return size, err
+		}
+	}
+
+	return size, nil
+}
+
+// recalculateSize works out and caches the size of the directory and every
+// directory under it ignoring what is cached. Links aren't followed
+func (fs *Filesystem) recalculateSize(path string) (int64, error) {
+	files, err := fs.chroot.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+
+	for _, f := range files {
+		switch {
+		case f.IsDir():
+			n, err := fs.recalculateSize(filepath.Join(path, f.Name()))
+			if err != nil {
+				return 0, err
+			}
+			size += n
+
+		case f.Mode().IsRegular():
+			size += f.Size()
+		}
+	}
+
+	if err := fs.shadow.SetSize(path, size); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// treeSize returns the size of path, the size of a file or everything under
+// a directory. Links have no size
+func (fs *Filesystem) treeSize(path string) (int64, error) {
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case finfo.IsDir():
+		return fs.dirSize(path)
+	case finfo.Mode().IsRegular():
+		return finfo.Size(), nil
+	}
+
+	return 0, nil
+}
+
+// adjustSize adds delta to the cached sizes of the directories holding path
+func (fs *Filesystem) adjustSize(path string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	return fs.shadow.AdjustSize(filepath.Dir(filepath.Clean("/"+path)), delta)
+}
+
+// fileSize returns the size of the file at path, zero if it doesn't exist
+func (fs *Filesystem) fileSize(path string) int64 {
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil || !finfo.Mode().IsRegular() {
+		return 0
+	}
+
+	return finfo.Size()
+}
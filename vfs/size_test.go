@@ -0,0 +1,122 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDirSize(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"download /** *",
+		"upload /** *",
+		"delete /** *",
+		"rename /** *",
+		"makedir /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	checkSize := func(path string, expected int64) {
+		t.Helper()
+
+		size, err := fs.DirSize(path, user)
+		checkErr(t, err, nil)
+
+		if size != expected {
+			t.Errorf("expected size of '%s' to be %d got %d", path, expected, size)
+		}
+	}
+
+	// existing files are walked the first time
+	if err := fs.chroot.MkdirAll("/mp3/album", defaultPerms); err != nil {
+		t.Fatalf("unexpected err creating dir: %s", err)
+	}
+
+	createFile(t, fs, "/mp3/album/01.mp3", "0123456789")
+	createFile(t, fs, "/mp3/album/02.mp3", "01234")
+
+	checkSize("/", 15)
+	checkSize("/mp3/album", 15)
+
+	// from then on they are cached, changes on disk aren't seen
+	createFile(t, fs, "/mp3/album/03.mp3", "012")
+
+	checkSize("/mp3", 15)
+
+	// uploads, deletes and renames are accounted for
+	checkErr(t, fs.MakeDir("/mp3/other", user), nil)
+
+	writer, err := fs.UploadFile("/mp3/other/01.mp3", user)
+	checkErr(t, err, nil)
+	fmt.Fprint(writer, "01234567890123456789")
+	checkErr(t, writer.Close(), nil)
+
+	checkSize("/", 35)
+	checkSize("/mp3/other", 20)
+
+	checkErr(t, fs.DeleteFile("/mp3/album/01.mp3", user), nil)
+
+	checkSize("/mp3", 25)
+	checkSize("/mp3/album", 5)
+
+	checkErr(t, fs.RenameFile("/mp3/other", "/archive", user), nil)
+
+	checkSize("/", 25)
+	checkSize("/mp3", 5)
+	checkSize("/archive", 20)
+
+	_, err = fs.DirSize("/archive/01.mp3", user)
+	checkErr(t, err, fmt.Errorf("not a directory."))
+}
+
+func TestRecalculateSizes(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"download /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	checkSize := func(path string, expected int64) {
+		t.Helper()
+
+		size, err := fs.DirSize(path, user)
+		checkErr(t, err, nil)
+
+		if size != expected {
+			t.Errorf("expected size of '%s' to be %d got %d", path, expected, size)
+		}
+	}
+
+	if err := fs.chroot.MkdirAll("/mp3/album", defaultPerms); err != nil {
+		t.Fatalf("unexpected err creating dir: %s", err)
+	}
+
+	createFile(t, fs, "/mp3/album/01.mp3", "0123456789")
+
+	checkSize("/", 10)
+
+	// changed on disk
+	createFile(t, fs, "/mp3/album/02.mp3", "01234")
+	checkErr(t, fs.chroot.Remove("/mp3/album/01.mp3"), nil)
+
+	checkSize("/mp3/album", 10)
+
+	size, err := fs.RecalculateSizes("/mp3/album")
+	checkErr(t, err, nil)
+
+	if size != 5 {
+		t.Errorf("expected recalculated size 5 got %d", size)
+	}
+
+	checkSize("/mp3/album", 5)
+	checkSize("/mp3", 5)
+	checkSize("/", 5)
+}
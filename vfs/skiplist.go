@@ -0,0 +1,88 @@
+package vfs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+)
+
+// SkipRule accepts or denies the names of directories or files matching its
+// regexp when they are created in paths matching its glob, i.e. a section.
+// Out of policy releases are refused rather than nuked later
+type SkipRule struct {
+	accept bool
+	dir    bool
+	g      glob.Glob
+	re     *regexp.Regexp
+}
+
+// NewSkipRule takes a line of text (i.e. from a config file) in the form of
+// `<accept|deny> <dir|file> <glob> <regexp>`
+func NewSkipRule(line string) (SkipRule, error) {
+	var rule SkipRule
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 4 {
+		return rule, errors.New("rule requires minimum of 4 fields")
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "accept":
+		rule.accept = true
+	case "deny":
+	default:
+		return rule, errors.Errorf("unknown action '%s'", fields[0])
+	}
+
+	switch strings.ToLower(fields[1]) {
+	case "dir":
+		rule.dir = true
+	case "file":
+	default:
+		return rule, errors.Errorf("unknown type '%s'", fields[1])
+	}
+
+	g, err := glob.Compile(strings.ToLower(fields[2]), '/')
+	if err != nil {
+		return rule, err
+	}
+
+	re, err := regexp.Compile(strings.Join(fields[3:], " "))
+	if err != nil {
+		return rule, err
+	}
+
+	rule.g = g
+	rule.re = re
+
+	return rule, nil
+}
+
+func (f *FilesystemOpts) SetSkiplist(rules []SkipRule) { f.skiplist = rules }
+
+// checkSkiplist checks the name of the directory or file being created at
+// path against the skiplist. The first rule of the same type with a glob
+// matching path and a regexp matching the name decides, names not matching
+// any rule are accepted
+func (fs *Filesystem) checkSkiplist(path string, dir bool) error {
+	name := filepath.Base(path)
+	lower := strings.ToLower(path)
+
+	for _, r := range fs.skiplist {
+		if r.dir != dir || !r.g.Match(lower) || !r.re.MatchString(name) {
+			continue
+		}
+
+		if r.accept {
+			return nil
+		}
+
+		return errors.WithMessagef(ErrFilenameNotAllowed, "'%s' is skiplisted", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewSkipRule(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{`deny dir /mp3/* (?i)^\(`, nil},
+		{`ACCEPT FILE /mp3/*/* \.mp3$`, nil},
+		{`deny dir /mp3/*`, errors.New("rule requires minimum of 4 fields")},
+		{`block dir /** .*`, errors.New("unknown action 'block'")},
+		{`deny link /** .*`, errors.New("unknown type 'link'")},
+		{`deny dir /** (`, errors.New("missing closing )")},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.input,
+			func(t *testing.T) {
+				_, err := NewSkipRule(tt.input)
+				checkErr(t, err, tt.err)
+			},
+		)
+	}
+}
+
+func TestCheckSkiplist(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{"upload /** *", "makedir /** *", "rename /** *"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	var rules []SkipRule
+	for _, l := range []string{
+		`accept dir /mp3/* ^[A-Za-z0-9][\w.()-]+-[A-Za-z0-9]+$`,
+		`deny dir /mp3/* .*`,
+		`deny dir /mp3/*/* (?i)^(sample|proof)$`,
+		`deny file /mp3/*/* (?i)\.(exe|flac)$`,
+	} {
+		r, err := NewSkipRule(l)
+		checkErr(t, err, nil)
+		rules = append(rules, r)
+	}
+
+	fs.SetSkiplist(rules)
+
+	var tests = []struct {
+		path    string
+		dir     bool
+		allowed bool
+	}{
+		{"/mp3/Artist-Album-2020-GRP", true, true},
+		{"/mp3/(no group)", true, false},
+		{"/mp3/_Artist-Album-GRP", true, false},
+		{"/mp3/Artist-Album-GRP/CD1", true, true},
+		{"/mp3/Artist-Album-GRP/Sample", true, false},
+		{"/mp3/Artist-Album-GRP/01-track.mp3", false, true},
+		{"/mp3/Artist-Album-GRP/01-track.FLAC", false, false},
+		// dir rules don't apply to files and the other way around
+		{"/mp3/sample", false, true},
+		{"/0day/_anything", true, true},
+	}
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				err := fs.checkSkiplist(tt.path, tt.dir)
+				if tt.allowed {
+					checkErr(t, err, nil)
+					return
+				}
+
+				if !errors.Is(err, ErrFilenameNotAllowed) {
+					t.Errorf("expected ErrFilenameNotAllowed got: %v", err)
+				}
+			},
+		)
+	}
+
+	user := newTestUser("user", "group")
+
+	checkErr(t, fs.MakeDir("/mp3", user), nil)
+
+	if err := fs.MakeDir("/mp3/no_group", user); !errors.Is(err, ErrFilenameNotAllowed) {
+		t.Errorf("expected mkdir to be refused got: %v", err)
+	}
+
+	if _, err := fs.chroot.Stat("/mp3/no_group"); err == nil {
+		t.Error("expected nothing to be created")
+	}
+
+	checkErr(t, fs.MakeDir("/mp3/Artist-Album-GRP", user), nil)
+
+	if _, err := fs.UploadFile("/mp3/Artist-Album-GRP/setup.exe", user); !errors.Is(err, ErrFilenameNotAllowed) {
+		t.Errorf("expected upload to be refused got: %v", err)
+	}
+
+	checkErr(t, fs.MakeDir("/incoming", user), nil)
+	checkErr(t, fs.MakeDir("/incoming/no_group", user), nil)
+
+	if err := fs.RenameFile("/incoming/no_group", "/mp3/no_group", user); !errors.Is(err, ErrFilenameNotAllowed) {
+		t.Errorf("expected rename to be refused got: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package vfs
+
+import (
+	"github.com/pkg/errors"
+)
+
+// statfs is not supported on windows
+func statfs(path string) (uint64, error) {
+	return 0, errors.New("free space is not supported on windows")
+}
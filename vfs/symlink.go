@@ -0,0 +1,132 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// ErrSymlinkLoop is returned when resolving a path follows too many links
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// maximum number of links followed when resolving a path
+const maxSymlinks = 16
+
+// CreateSymlink checks the user has permission to create a link (checking the
+// symlink scope) and creates link pointing at target. target is a virtual path,
+// relative targets are relative to the directory holding link and are kept
+// relative so the link survives its directory being moved. A `..` can't
+// climb above the root, the same as everywhere else in the filesystem
+func (fs *Filesystem) CreateSymlink(target, link string, user *acl.User) error {
+	link = filepath.Clean("/" + link)
+
+	abs := target
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(filepath.Dir(link), abs)
+	}
+	abs = filepath.Clean("/" + abs)
+
+	// check for jail
+	if !user.CanAccess(link) || !user.CanAccess(abs) {
+		return os.ErrNotExist
+	}
+
+	if !fs.permissions.Match(acl.PermissionScopeSymlink, link, user) {
+		return acl.ErrPermissionDenied
+	}
+
+	// check for private
+	for _, p := range []string{link, abs} {
+		if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, p, user); found && !match {
+			return os.ErrNotExist
+		}
+
+		if fs.hideRE != nil && fs.hideRE.MatchString(p) {
+			return os.ErrNotExist
+		}
+	}
+
+	// the target has to exist, resolving it also makes sure it doesn't loop
+	resolved, err := fs.resolve(abs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.chroot.Stat(resolved); err != nil {
+		return err
+	}
+
+	if _, err := fs.chroot.Lstat(link); err == nil {
+		return os.ErrExist
+	}
+
+	// relative targets are written from the checked abs, as written the host
+	// would follow any `..` that was clamped at the root out of it
+	if !filepath.IsAbs(target) {
+		if target, err = filepath.Rel(filepath.Dir(link), abs); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.chroot.Symlink(target, link); err != nil {
+		return err
+	}
+
+	return fs.shadow.Set(link, user.Name, user.PrimaryGroup)
+}
+
+// resolve follows any links in path returning the virtual path it points to.
+// Anything after the first part of path that doesn't exist is returned as is
+func (fs *Filesystem) resolve(path string) (string, error) {
+	parts := splitPath(path)
+	resolved := "/"
+
+	var followed int
+
+	for i := 0; i < len(parts); i++ {
+		next := filepath.Join(resolved, parts[i])
+
+		finfo, err := fs.chroot.Lstat(next)
+		if err != nil {
+			return filepath.Join(append([]string{next}, parts[i+1:]...)...), nil
+		}
+
+		if finfo.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		followed++
+		if followed > maxSymlinks {
+			return "", ErrSymlinkLoop
+		}
+
+		target, err := fs.chroot.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+
+		// start again from the target with what is left of path
+		parts = append(splitPath(target), parts[i+1:]...)
+		resolved = "/"
+		i = -1
+	}
+
+	return resolved, nil
+}
+
+// splitPath splits a cleaned absolute path into its parts
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+	if len(path) == 0 {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
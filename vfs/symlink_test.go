@@ -0,0 +1,89 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestCreateSymlink(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"symlink /** !-bad *",
+		"download /** *",
+		"makedir /** *",
+		"private /secret/** -admin",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	user := newTestUser("user", "group")
+
+	checkErr(t, fs.MakeDir("/mp3", user), nil)
+	checkErr(t, fs.MakeDir("/mp3/album", user), nil)
+	checkErr(t, fs.MakeDir("/secret", user), nil)
+	createFile(t, fs, "/secret/file", "SECRET")
+	createFile(t, fs, "/mp3/album/track.mp3", "TRACK")
+
+	checkErr(t, fs.CreateSymlink("/mp3/album", "/latest", user), nil)
+	checkErr(t, fs.CreateSymlink("album/track.mp3", "/mp3/track", user), nil)
+
+	// no permission, private, missing targets and existing links are refused
+	checkErr(t, fs.CreateSymlink("/mp3", "/other", newTestUser("bad", "nolinks")), acl.ErrPermissionDenied)
+	checkErr(t, fs.CreateSymlink("/secret/file", "/other", user), os.ErrNotExist)
+	checkErr(t, fs.CreateSymlink("/missing", "/other", user), os.ErrNotExist)
+	checkErr(t, fs.CreateSymlink("/mp3", "/latest", user), os.ErrExist)
+
+	// listing through a link lists the target
+	files, err := fs.ListDir("/latest", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 1 || files[0].Name() != "track.mp3" {
+		t.Fatalf("unexpected listing through link: %+v", files)
+	}
+
+	// links are shown with their target
+	files, err = fs.ListDir("/", user)
+	checkErr(t, err, nil)
+
+	if !strings.Contains(string(files.Detailed()), " latest -> /mp3/album\r\n") {
+		t.Errorf("expected link in listing got:\n%s", files.Detailed())
+	}
+
+	// relative links resolve from their directory
+	r, err := fs.DownloadFile("/mp3/track", user)
+	checkErr(t, err, nil)
+
+	b, err := ioutil.ReadAll(r)
+	checkErr(t, err, nil)
+	r.Close()
+
+	if string(b) != "TRACK" {
+		t.Errorf("expected TRACK got '%s'", b)
+	}
+
+	// a `..` above the root is clamped in the link written, not only when
+	// checking it
+	checkErr(t, fs.CreateSymlink("../../mp3/album", "/mp3/up", user), nil)
+
+	written, err := fs.chroot.Readlink("/mp3/up")
+	checkErr(t, err, nil)
+
+	if written != "album" {
+		t.Errorf("expected link to 'album' got '%s'", written)
+	}
+
+	checkErr(t, fs.CreateSymlink("../secret/file", "/mp3/secret", user), os.ErrNotExist)
+
+	// loops are caught
+	checkErr(t, fs.chroot.Symlink("/loop/b", "/loop/a"), nil)
+	checkErr(t, fs.chroot.Symlink("/loop/a", "/loop/b"), nil)
+
+	if _, err := fs.ListDir("/loop/a", user); err != ErrSymlinkLoop {
+		t.Errorf("expected ErrSymlinkLoop got: %v", err)
+	}
+}
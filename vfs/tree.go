@@ -0,0 +1,157 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// walk calls fn for path and everything under it, children before their
+// parent so trees can be removed as they are walked. Links are not followed
+func (fs *Filesystem) walk(path string, fn func(string, os.FileInfo) error) error {
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if finfo.IsDir() {
+		files, err := fs.chroot.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if err := fs.walk(filepath.Join(path, f.Name()), fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fn(path, finfo)
+}
+
+// removeProgressEvery is how many entries removeTree removes between calls
+// to its progress func
+const removeProgressEvery = 1000
+
+// Removed counts what removeTree has removed
+type Removed struct {
+	Files int64
+	Dirs  int64
+	Bytes int64
+}
+
+// removeTree deletes path and everything under it along with their shadow
+// entries, calling progress, if not nil, every removeProgressEvery entries.
+// No permissions are checked
+func (fs *Filesystem) removeTree(path string, progress func(Removed)) (Removed, error) {
+	var removed Removed
+
+	err := fs.walk(path, func(p string, finfo os.FileInfo) error {
+		if err := fs.chroot.Remove(p); err != nil {
+			return err
+		}
+
+		switch {
+		case finfo.IsDir():
+			removed.Dirs++
+		case finfo.Mode().IsRegular():
+			removed.Files++
+			removed.Bytes += finfo.Size()
+		default:
+			removed.Files++
+		}
+
+		if progress != nil && (removed.Files+removed.Dirs)%removeProgressEvery == 0 {
+			progress(removed)
+		}
+
+		return fs.shadow.Remove(p)
+	})
+
+	// account for whatever was removed even if it failed part way
+	if err := fs.adjustSize(path, -removed.Bytes); err != nil {
+		return removed, err
+	}
+
+	return removed, err
+}
+
+// moveTree renames oldpath to newpath moving the shadow entries of everything
+// under it, copying and removing when they are on different mounts. No
+// permissions are checked
+func (fs *Filesystem) moveTree(oldpath, newpath string) error {
+	if _, err := fs.chroot.Lstat(newpath); err == nil {
+		return os.ErrExist
+	}
+
+	var paths []string
+	var size int64
+
+	err := fs.walk(oldpath, func(p string, finfo os.FileInfo) error {
+		paths = append(paths, p)
+
+		if finfo.Mode().IsRegular() {
+			size += finfo.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := fs.chroot.Rename(oldpath, newpath); err != nil {
+		if err != ErrCrossMount {
+			return err
+		}
+
+		// different mounts can't be renamed between, copy then remove
+		size, err := fs.copyTree(oldpath, newpath)
+
+		if err := fs.adjustSize(newpath, size); err != nil {
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = fs.removeTree(oldpath, nil)
+		return err
+	}
+
+	if err := fs.adjustSize(oldpath, -size); err != nil {
+		return err
+	}
+
+	if err := fs.adjustSize(newpath, size); err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		e, err := fs.shadow.GetEntry(p)
+		if err != nil && err != ErrNoPath {
+			return err
+		}
+
+		// removing also clears any cached size left at the old path
+		if err := fs.shadow.Remove(p); err != nil {
+			return err
+		}
+
+		if err == ErrNoPath {
+			continue
+		}
+
+		rel, err := filepath.Rel(oldpath, p)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.shadow.SetEntry(filepath.Join(newpath, rel), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
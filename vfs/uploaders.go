@@ -0,0 +1,98 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goftpd/goftpd/acl"
+	"github.com/pkg/errors"
+)
+
+// Uploader is the total of the files owned by a user under a directory
+type Uploader struct {
+	User  string
+	Group string
+	Bytes int64
+	Files int64
+}
+
+// Uploaders returns who owns the files under the directory at path and how
+// much each of them uploaded, largest first. Ownership is taken from the
+// shadow entries, files without one belong to the DefaultUser. Only jail,
+// private and hidden paths are checked, who may ask is decided by the caller
+func (fs *Filesystem) Uploaders(path string, user *acl.User) ([]Uploader, error) {
+	path = filepath.Clean("/" + path)
+
+	// check for jail
+	if !user.CanAccess(path) {
+		return nil, os.ErrNotExist
+	}
+
+	// check for private
+	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return nil, os.ErrNotExist
+	}
+
+	if fs.hidden(path, user) {
+		return nil, os.ErrNotExist
+	}
+
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !finfo.IsDir() {
+		return nil, errors.New("not a directory.")
+	}
+
+	uploaders := make(map[string]*Uploader)
+
+	err = fs.walk(path, func(p string, finfo os.FileInfo) error {
+		if !finfo.Mode().IsRegular() || isUploadTemp(finfo.Name()) {
+			return nil
+		}
+
+		e, err := fs.shadow.GetEntry(p)
+		if err != nil && err != ErrNoPath {
+			return err
+		}
+
+		if err == ErrNoPath {
+			e.User = fs.DefaultUser
+			e.Group = fs.DefaultGroup
+		}
+
+		key := strings.ToLower(e.User)
+
+		u, ok := uploaders[key]
+		if !ok {
+			u = &Uploader{User: e.User, Group: e.Group}
+			uploaders[key] = u
+		}
+
+		u.Bytes += finfo.Size()
+		u.Files++
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Uploader, 0, len(uploaders))
+	for _, u := range uploaders {
+		results = append(results, *u)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Bytes == results[j].Bytes {
+			return results[i].User < results[j].User
+		}
+		return results[i].Bytes > results[j].Bytes
+	})
+
+	return results, nil
+}
@@ -0,0 +1,51 @@
+package vfs
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUploaders(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	alice := newTestUser("alice", "group")
+	bob := newTestUser("bob", "other")
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release/cd1", defaultPerms), nil)
+	createFile(t, fs, "/mp3/release/file.nfo", "NFO")
+	createFile(t, fs, "/mp3/release/cd1/track1.mp3", "TRACK")
+	createFile(t, fs, "/mp3/release/cd1/track2.mp3", "TRACK")
+	createFile(t, fs, "/mp3/release/unowned.sfv", "SFV")
+	createFile(t, fs, uploadTempPath("/mp3/release/partial.mp3"), "PART")
+
+	setShadowOwner(t, fs, "/mp3/release/file.nfo", bob)
+	setShadowOwner(t, fs, "/mp3/release/cd1/track1.mp3", alice)
+	setShadowOwner(t, fs, "/mp3/release/cd1/track2.mp3", alice)
+
+	_, err := fs.Uploaders("/mp3/release/file.nfo", alice)
+	checkErr(t, err, errors.New("not a directory."))
+
+	uploaders, err := fs.Uploaders("/mp3/release", alice)
+	checkErr(t, err, nil)
+
+	expected := []Uploader{
+		{User: "alice", Group: "group", Bytes: 10, Files: 2},
+		{User: "bob", Group: "other", Bytes: 3, Files: 1},
+		{User: "nobody", Group: "nogroup", Bytes: 3, Files: 1},
+	}
+
+	if len(uploaders) != len(expected) {
+		t.Fatalf("expected %d uploaders got %+v", len(expected), uploaders)
+	}
+
+	for i := range expected {
+		if uploaders[i] != expected[i] {
+			t.Errorf("expected %+v got %+v", expected[i], uploaders[i])
+		}
+	}
+}
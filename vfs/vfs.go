@@ -6,9 +6,13 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/gobwas/glob"
 	"github.com/goftpd/goftpd/acl"
+	"github.com/goftpd/goftpd/zipscript"
 	"github.com/pkg/errors"
 )
 
@@ -28,9 +32,32 @@ type VFS interface {
 	UploadFile(string, *acl.User) (io.WriteCloser, error)
 	ResumeUploadFile(string, *acl.User) (io.WriteCloser, error)
 	RenameFile(string, string, *acl.User) error
+	CopyTree(string, string, *acl.User) (int64, error)
+	MoveTree(string, string, *acl.User) error
 	DeleteFile(string, *acl.User) error
 	DeleteDir(string, *acl.User) error
+	Wipe(string, *acl.User, func(Removed)) (Removed, error)
 	ListDir(string, *acl.User) (FileList, error)
+	ChangeOwner(string, string, string, bool, *acl.User) (int, error)
+	CreateSymlink(string, string, *acl.User) error
+	StatFile(string, *acl.User) (FileInfo, error)
+	DirSize(string, *acl.User) (int64, error)
+	Uploaders(string, *acl.User) ([]Uploader, error)
+	Checksum(string, *acl.User) (Checksum, error)
+	VerifyChecksum(string, *acl.User) (Checksum, error)
+	FreeSpace(string) (uint64, error)
+	RealPath(string) string
+	RatioFree(string, *acl.User) bool
+	UploadFree(string, *acl.User) bool
+	HideInWho(string, *acl.User) bool
+	Allowed(acl.PermissionScope, string, *acl.User) bool
+	CreateDatedDirs(time.Time) ([]string, error)
+	Subscribe(func(Event))
+	Rotate(time.Time) ([]Rotated, error)
+	Purge(string) (Removed, error)
+	Remove(string) (Removed, error)
+	Quarantine(string, string) (string, error)
+	RecalculateSizes(string) (int64, error)
 }
 
 type FilesystemOpts struct {
@@ -38,17 +65,66 @@ type FilesystemOpts struct {
 	ShadowDB     string `goftpd:"shadow_db"`
 	DefaultUser  string `goftpd:"default_user"`
 	DefaultGroup string `goftpd:"default_group"`
+	HiddenUser   string `goftpd:"hidden_user"`
+	HiddenGroup  string `goftpd:"hidden_group"`
 	Hide         string `goftpd:"hide"`
+	MinFree      int    `goftpd:"min_free"`
+	MaxFilename  int    `goftpd:"max_filename"`
+	FileMode     string `goftpd:"file_mode"`
+	DirMode      string `goftpd:"dir_mode"`
+	UID          int    `goftpd:"uid"`
+	GID          int    `goftpd:"gid"`
 	hideRE       *regexp.Regexp
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+
+	filenameRules []FilenameRule
+	skiplist      []SkipRule
+	datedDirs     []DatedDir
+
+	rotations      []Rotation
+	rotateExcludes []glob.Glob
+
+	zipscript *zipscript.Zipscript
 }
 
 func (f *FilesystemOpts) SetHideRE(r *regexp.Regexp) { f.hideRE = r }
 
+// SetModes sets the modes applied to created files and directories, 0 leaves
+// them to the process umask
+func (f *FilesystemOpts) SetModes(file, dir os.FileMode) {
+	f.fileMode = file
+	f.dirMode = dir
+}
+
+// hiddenUser is shown in place of owners hidden by the hide_user scope,
+// defaults to DefaultUser
+func (f *FilesystemOpts) hiddenUser() string {
+	if len(f.HiddenUser) > 0 {
+		return f.HiddenUser
+	}
+	return f.DefaultUser
+}
+
+// hiddenGroup is shown in place of groups hidden by the hide_group scope,
+// defaults to DefaultGroup
+func (f *FilesystemOpts) hiddenGroup() string {
+	if len(f.HiddenGroup) > 0 {
+		return f.HiddenGroup
+	}
+	return f.DefaultGroup
+}
+
 type Filesystem struct {
 	*FilesystemOpts
 	chroot      billy.Filesystem
 	shadow      Shadow
 	permissions *acl.Permissions
+	events      *Bus
+
+	// held while the markers of a release are updated
+	zipscriptMtx *sync.Mutex
 }
 
 // NewFilesystem creates a new Filesystem with the given chroot (underlying fs) shadow (stores user/group meta data
@@ -59,6 +135,8 @@ func NewFilesystem(opts *FilesystemOpts, chroot billy.Filesystem, shadow Shadow,
 		chroot:         chroot,
 		shadow:         shadow,
 		permissions:    permissions,
+		events:         NewBus(),
+		zipscriptMtx:   &sync.Mutex{},
 	}
 
 	return &fs, nil
@@ -70,10 +148,10 @@ func (fs Filesystem) Join(current string, params []string) string {
 	path := strings.Join(params, " ")
 
 	if !strings.HasPrefix(path, "/") {
-		path = filepath.Join(current, path)
+		return filepath.Join(current, path)
 	}
 
-	return path
+	return filepath.Clean(path)
 }
 
 // Stop closes any underlying resources
@@ -86,6 +164,11 @@ func (fs *Filesystem) Stop() error {
 
 // MakeDir checks to see if the user has permission to create a new directory. Does so if allowed
 func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
+	// check for jail
+	if !user.CanAccess(path) {
+		return acl.ErrPermissionDenied
+	}
+
 	if !fs.permissions.Match(acl.PermissionScopeMakeDir, path, user) {
 		return acl.ErrPermissionDenied
 	}
@@ -99,6 +182,10 @@ func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
 	path = filepath.Clean(path)
 	dir := filepath.Dir(path)
 
+	if err := fs.checkSkiplist(path, true); err != nil {
+		return err
+	}
+
 	finfo, err := fs.chroot.Stat(dir)
 	if err != nil {
 		return err
@@ -108,7 +195,11 @@ func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
 		return errors.New("parent is not a directory")
 	}
 
-	if err := fs.chroot.MkdirAll(path, defaultPerms); err != nil {
+	if err := fs.chroot.MkdirAll(path, fs.createPerms(true)); err != nil {
+		return err
+	}
+
+	if err := fs.applyMode(path, true); err != nil {
 		return err
 	}
 
@@ -116,27 +207,23 @@ func (fs *Filesystem) MakeDir(path string, user *acl.User) error {
 		return err
 	}
 
+	// new directories are empty, this also clears any stale size left
+	// behind by a directory that used to be here
+	if err := fs.shadow.SetSize(path, 0); err != nil {
+		return err
+	}
+
+	fs.publish(EventMakeDir, path, user, true)
+
 	return nil
 }
 
 // DownloadFile checks to see if the user has permission to read the file (checking download
 // permissions from high level to low level). Returns an io.ReadCloser if allowed
 func (fs *Filesystem) DownloadFile(path string, user *acl.User) (ReadSeekCloser, error) {
-	if !fs.permissions.Match(acl.PermissionScopeDownload, path, user) {
-		return nil, acl.ErrPermissionDenied
-	}
-
-	// check for private
-	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
-		return nil, os.ErrNotExist
-	}
-
-	if fs.hideRE != nil {
-		if fs.hideRE.MatchString(path) {
-			// do not leak any information, just pretend
-			// it doesnt exist
-			return nil, os.ErrNotExist
-		}
+	path, err := fs.checkDownload(path, user)
+	if err != nil {
+		return nil, err
 	}
 
 	f, err := fs.chroot.Open(path)
@@ -151,6 +238,11 @@ func (fs *Filesystem) DownloadFile(path string, user *acl.User) (ReadSeekCloser,
 // permissions from high level to low level). Returns an io.Writer if allowed. Does not
 // truncate a file
 func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, error) {
+	// check for jail
+	if !user.CanAccess(path) {
+		return nil, acl.ErrPermissionDenied
+	}
+
 	if !fs.permissions.Match(acl.PermissionScopeUpload, path, user) {
 		return nil, acl.ErrPermissionDenied
 	}
@@ -160,15 +252,98 @@ func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, e
 		return nil, acl.ErrPermissionDenied
 	}
 
-	f, err := fs.chroot.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, defaultPerms)
+	// the temporary names are reserved for uploads in progress
+	if isUploadTemp(path) {
+		return nil, acl.ErrPermissionDenied
+	}
+
+	if err := fs.checkFilename(path); err != nil {
+		return nil, err
+	}
+
+	if err := fs.checkSkiplist(path, false); err != nil {
+		return nil, err
+	}
+
+	if err := fs.checkZipscriptUpload(path); err != nil {
+		return nil, err
+	}
+
+	if err := fs.checkFreeSpace(path); err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.chroot.Lstat(path); err == nil {
+		return nil, os.ErrExist
+	}
+
+	// write to a temporary file so partial uploads are never seen, it
+	// is renamed in to place once the upload completes
+	tmp := uploadTempPath(path)
+
+	f, err := fs.chroot.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, fs.createPerms(false))
 	if err != nil {
 		return nil, err
 	}
 
-	// wrap the file in our special Writer that allows us to manage the shadow fs
+	if err := fs.applyMode(tmp, false); err != nil {
+		f.Close()
+		fs.chroot.Remove(tmp)
+		return nil, err
+	}
+
+	started := time.Now()
+
+	// wrap the file in our special Writer that allows us to manage the shadow fs,
+	// the checksum is computed as the file is written
+	sum := newChecksummer()
+
 	writer := newWriteCloser(f, func() error {
-		return fs.shadow.Set(path, user.Name, user.PrimaryGroup)
+		if _, err := fs.chroot.Lstat(path); err == nil {
+			fs.chroot.Remove(tmp)
+			return os.ErrExist
+		}
+
+		if err := fs.chroot.Rename(tmp, path); err != nil {
+			fs.chroot.Remove(tmp)
+			return err
+		}
+
+		size := fs.fileSize(path)
+
+		if err := fs.adjustSize(path, size); err != nil {
+			return err
+		}
+
+		checksum := sum.Sum()
+
+		if err := fs.recordUpload(path, user, checksum); err != nil {
+			return err
+		}
+
+		progress, completed, err := fs.zipscriptUpload(path, size, checksum, true)
+		if err != nil {
+			return err
+		}
+
+		fs.publishUpload(Event{
+			Type:     EventUpload,
+			Path:     path,
+			User:     user.Name,
+			Group:    user.PrimaryGroup,
+			Size:     size,
+			Checksum: checksum,
+			Duration: time.Since(started),
+			Release:  progress,
+		}, completed)
+
+		return nil
 	})
+	writer.onCloseFailure = func() {
+		fs.chroot.Remove(tmp)
+	}
+	writer.checkSpace = fs.spaceChecker(path)
+	writer.sum = sum
 
 	return writer, nil
 }
@@ -177,6 +352,11 @@ func (fs *Filesystem) UploadFile(path string, user *acl.User) (io.WriteCloser, e
 // permissions from high level to low level). It also checks to see if they have resume writes.
 // Returns an io.Writer if allowed.
 func (fs *Filesystem) ResumeUploadFile(path string, user *acl.User) (io.WriteCloser, error) {
+	// check for jail
+	if !user.CanAccess(path) {
+		return nil, os.ErrNotExist
+	}
+
 	if !fs.permissions.Match(acl.PermissionScopeUpload, path, user) {
 		return nil, acl.ErrPermissionDenied
 	}
@@ -210,19 +390,81 @@ func (fs *Filesystem) ResumeUploadFile(path string, user *acl.User) (io.WriteClo
 		}
 	}
 
-	f, err := fs.chroot.OpenFile(path, os.O_RDWR|os.O_APPEND, defaultPerms)
+	if err := fs.checkFreeSpace(path); err != nil {
+		return nil, err
+	}
+
+	// move the file out of sight while it is appended to, it is moved
+	// back once the upload finishes whether it completed or not
+	tmp := uploadTempPath(path)
+	before := fs.fileSize(path)
+
+	if err := fs.chroot.Rename(path, tmp); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.chroot.OpenFile(tmp, os.O_RDWR|os.O_APPEND, defaultPerms)
 	if err != nil {
+		fs.chroot.Rename(tmp, path)
 		return nil, err
 	}
 
 	if _, err := f.Seek(0, os.SEEK_END); err != nil {
+		f.Close()
+		fs.chroot.Rename(tmp, path)
 		return nil, err
 	}
 
-	// wrap the file in our special Writer that allows us to manage the shadow fs
+	started := time.Now()
+
+	// wrap the file in our special Writer that allows us to manage the shadow fs,
+	// the file is re-read for its checksum as only part of it was written here
 	writer := newWriteCloser(f, func() error {
-		return fs.shadow.Set(path, user.Name, user.PrimaryGroup)
+		if err := fs.chroot.Rename(tmp, path); err != nil {
+			return err
+		}
+
+		written := fs.fileSize(path) - before
+
+		if err := fs.adjustSize(path, written); err != nil {
+			return err
+		}
+
+		sum, err := fs.checksumFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := fs.recordUpload(path, user, sum); err != nil {
+			return err
+		}
+
+		// a resumed file may still be incomplete so it isn't removed
+		// for failing its CRC
+		progress, completed, err := fs.zipscriptUpload(path, written, sum, false)
+		if err != nil {
+			return err
+		}
+
+		fs.publishUpload(Event{
+			Type:     EventUpload,
+			Path:     path,
+			User:     user.Name,
+			Group:    user.PrimaryGroup,
+			Size:     written,
+			Checksum: sum,
+			Duration: time.Since(started),
+			Release:  progress,
+		}, completed)
+
+		return nil
 	})
+	writer.onCloseFailure = func() {
+		if err := fs.chroot.Rename(tmp, path); err == nil {
+			fs.adjustSize(path, fs.fileSize(path)-before)
+		}
+	}
+	writer.checkSpace = fs.spaceChecker(path)
 
 	return writer, nil
 }
@@ -230,6 +472,11 @@ func (fs *Filesystem) ResumeUploadFile(path string, user *acl.User) (io.WriteClo
 // RenameFile checks to see if the user has permission to rename the file (checking rename and
 // renameown scopes).
 func (fs *Filesystem) RenameFile(oldpath, newpath string, user *acl.User) error {
+	// check for jail
+	if !user.CanAccess(oldpath) || !user.CanAccess(newpath) {
+		return os.ErrNotExist
+	}
+
 	// make sure that the user has permission to upload to the new path
 	if !fs.permissions.Match(acl.PermissionScopeUpload, newpath, user) {
 		return acl.ErrPermissionDenied
@@ -243,6 +490,10 @@ func (fs *Filesystem) RenameFile(oldpath, newpath string, user *acl.User) error
 		return os.ErrNotExist
 	}
 
+	if isUploadTemp(oldpath) || isUploadTemp(newpath) {
+		return os.ErrNotExist
+	}
+
 	if fs.hideRE != nil {
 		if fs.hideRE.MatchString(oldpath) || fs.hideRE.MatchString(newpath) {
 			// do not leak any information, just pretend
@@ -272,24 +523,90 @@ func (fs *Filesystem) RenameFile(oldpath, newpath string, user *acl.User) error
 		return errors.New("can not rename to self")
 	}
 
+	if err := fs.checkFilename(newpath); err != nil {
+		return err
+	}
+
+	oldinfo, err := fs.chroot.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.checkSkiplist(newpath, oldinfo.IsDir()); err != nil {
+		return err
+	}
+
+	size, err := fs.treeSize(oldpath)
+	if err != nil {
+		return err
+	}
+
 	if err := fs.chroot.Rename(oldpath, newpath); err != nil {
 		return err
 	}
 
+	if err := fs.adjustSize(oldpath, -size); err != nil {
+		return err
+	}
+
+	if err := fs.adjustSize(newpath, size); err != nil {
+		return err
+	}
+
+	finfo, err := fs.chroot.Lstat(newpath)
+	isDir := err == nil && finfo.IsDir()
+
+	if isDir {
+		if err := fs.shadow.SetSize(newpath, size); err != nil {
+			return err
+		}
+	}
+
+	// keep the creation time and checksum of the original
+	created := time.Now()
+	var sum Checksum
+	if e, err := fs.shadow.GetEntry(oldpath); err == nil {
+		if !e.CreatedAt.IsZero() {
+			created = e.CreatedAt
+		}
+		sum = e.Checksum
+	}
+
 	if err := fs.shadow.Remove(oldpath); err != nil {
 		return err
 	}
 
-	if err := fs.shadow.Set(newpath, user.Name, user.PrimaryGroup); err != nil {
+	entry := ShadowEntry{
+		User:      user.Name,
+		Group:     user.PrimaryGroup,
+		CreatedAt: created,
+		Checksum:  sum,
+	}
+
+	if err := fs.shadow.SetEntry(newpath, entry); err != nil {
 		return err
 	}
 
+	fs.events.Publish(Event{
+		Type:    EventRename,
+		Path:    oldpath,
+		NewPath: newpath,
+		User:    user.Name,
+		Group:   user.PrimaryGroup,
+		Dir:     isDir,
+	})
+
 	return nil
 }
 
 // DeleteFile checks to see if the user has permission to delete the file (checking delete and
 // deleteown scopes).
 func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
+	// check for jail
+	if !user.CanAccess(path) {
+		return os.ErrNotExist
+	}
+
 	if !fs.permissions.Match(acl.PermissionScopeDelete, path, user) {
 
 		// not allowed to globally delete, check if this is ours and we can delete our own
@@ -320,7 +637,8 @@ func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
 		}
 	}
 
-	finfo, err := fs.chroot.Stat(path)
+	// don't follow links so they can be deleted
+	finfo, err := fs.chroot.Lstat(path)
 	if err != nil {
 		return err
 	}
@@ -333,16 +651,32 @@ func (fs *Filesystem) DeleteFile(path string, user *acl.User) error {
 		return err
 	}
 
+	if finfo.Mode().IsRegular() {
+		if err := fs.adjustSize(path, -finfo.Size()); err != nil {
+			return err
+		}
+	}
+
 	if err := fs.shadow.Remove(path); err != nil {
 		return err
 	}
 
+	fs.publish(EventDeleteFile, path, user, false)
+
+	// the file is gone whether or not the markers can be updated
+	fs.zipscriptDelete(path)
+
 	return nil
 }
 
 // DeleteDir checks to see if the user has permission to delete the dir (checking delete and
 // deleteown scopes).
 func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
+	// check for jail
+	if !user.CanAccess(path) {
+		return os.ErrNotExist
+	}
+
 	if !fs.permissions.Match(acl.PermissionScopeDelete, path, user) {
 
 		// not allowed to globally delete, check if this is ours and we can delete our own
@@ -374,6 +708,13 @@ func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
 		return errors.New("can not delete file.")
 	}
 
+	// a release left with only its zipscript markers can be removed
+	if fs.zipscriptEnabled() && fs.onlyMarkers(path) {
+		if err := fs.removeMarkers(path); err != nil {
+			return err
+		}
+	}
+
 	if err := fs.chroot.Remove(path); err != nil {
 		return err
 	}
@@ -382,79 +723,244 @@ func (fs *Filesystem) DeleteDir(path string, user *acl.User) error {
 		return err
 	}
 
+	fs.publish(EventDeleteDir, path, user, true)
+
 	return nil
 }
 
 // ListDir checks to see if the user has permission to list the dir and then does so.
 // Has optimisation potential by being provided a FileList
 func (fs *Filesystem) ListDir(path string, user *acl.User) (FileList, error) {
-	if !fs.permissions.Match(acl.PermissionScopeDownload, path, user) {
-		return nil, acl.ErrPermissionDenied
+	path, err := fs.checkDownload(path, user)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.chroot.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var results FileList
+
+	for _, f := range files {
+		fullpath := filepath.Join(path, f.Name())
+
+		if fs.hidden(fullpath, user) {
+			continue
+		}
+
+		// check for private
+		if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, fullpath, user); found && !match {
+			continue
+		}
+
+		results = append(results, fs.fileInfo(fullpath, f, user))
+	}
+
+	return results, nil
+}
+
+// StatFile checks to see if the user has permission to read the path and returns its
+// details
+func (fs *Filesystem) StatFile(path string, user *acl.User) (FileInfo, error) {
+	resolved, err := fs.checkDownload(path, user)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	f, err := fs.chroot.Stat(resolved)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return fs.fileInfo(resolved, f, user), nil
+}
+
+// fileInfo adds the shadow details of the path to f, replacing the user and group
+// with the hidden placeholders if the hide scopes match
+func (fs *Filesystem) fileInfo(path string, f os.FileInfo, user *acl.User) FileInfo {
+	entry, err := fs.shadow.GetEntry(path)
+	if err != nil {
+		entry.User = fs.DefaultUser
+		entry.Group = fs.DefaultGroup
+	}
+
+	username, group := entry.User, entry.Group
+
+	// check if we have permission to see user and group, as it's hide, permissions are reversed
+	if fs.permissions.Match(acl.PermissionScopeHideUser, path, user) {
+		username = fs.hiddenUser()
+	}
+	if fs.permissions.Match(acl.PermissionScopeHideGroup, path, user) {
+		group = fs.hiddenGroup()
+	}
+
+	var target string
+	if f.Mode()&os.ModeSymlink != 0 {
+		target, _ = fs.chroot.Readlink(path)
+	}
+
+	return FileInfo{
+		FileInfo:   f,
+		Owner:      username,
+		Group:      group,
+		CreatedAt:  entry.CreatedAt,
+		LinkTarget: target,
+		Checksum:   entry.Checksum,
+	}
+}
+
+// ChangeOwner sets the shadow owner and group of the path, an empty owner or
+// group keeps the existing one. If recursive is set everything under a
+// directory is changed as well. Returns the number of paths changed. The
+// caller is responsible for checking the user is allowed to change owners
+func (fs *Filesystem) ChangeOwner(path, owner, group string, recursive bool, user *acl.User) (int, error) {
+	// check for jail
+	if !user.CanAccess(path) {
+		return 0, os.ErrNotExist
 	}
 
 	// check for private
 	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
-		return nil, os.ErrNotExist
+		return 0, os.ErrNotExist
 	}
 
 	if fs.hideRE != nil {
 		if fs.hideRE.MatchString(path) {
 			// do not leak any information, just pretend
 			// it doesnt exist
-			return nil, os.ErrNotExist
+			return 0, os.ErrNotExist
 		}
 	}
 
-	files, err := fs.chroot.ReadDir(path)
+	finfo, err := fs.chroot.Stat(path)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	var results FileList
+	if err := fs.changeOwner(path, owner, group); err != nil {
+		return 0, err
+	}
+
+	changed := 1
+
+	if !recursive || !finfo.IsDir() {
+		return changed, nil
+	}
+
+	files, err := fs.chroot.ReadDir(path)
+	if err != nil {
+		return changed, err
+	}
 
 	for _, f := range files {
-		fullpath := filepath.Join(path, f.Name())
+		n, err := fs.ChangeOwner(filepath.Join(path, f.Name()), owner, group, recursive, user)
+		changed += n
 
-		if fs.hideRE != nil {
-			if fs.hideRE.MatchString(fullpath) {
-				continue
-			}
+		if err != nil && err != os.ErrNotExist {
+			return changed, err
 		}
+	}
 
-		// check for private
-		if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, fullpath, user); found && !match {
-			continue
-		}
+	return changed, nil
+}
 
-		username, group, err := fs.shadow.Get(fullpath)
-		if err != nil {
-			username = fs.DefaultUser
-			group = fs.DefaultGroup
+// changeOwner updates the shadow entry for the path keeping its creation time,
+// paths with no entry start from the default user and group
+func (fs *Filesystem) changeOwner(path, owner, group string) error {
+	entry, err := fs.shadow.GetEntry(path)
+	if err != nil {
+		if err != ErrNoPath {
+			return err
 		}
 
-		// check if we have permission to see user and group, as it's hide, permissions are reversed
-		if fs.permissions.Match(acl.PermissionScopeHideUser, fullpath, user) {
-			username = fs.DefaultUser
-		}
-		if fs.permissions.Match(acl.PermissionScopeHideGroup, fullpath, user) {
-			group = fs.DefaultGroup
-		}
+		entry.User = fs.DefaultUser
+		entry.Group = fs.DefaultGroup
+	}
 
-		results = append(results, FileInfo{
-			FileInfo: f,
-			Owner:    username,
-			Group:    group,
-		})
+	if len(owner) > 0 {
+		entry.User = owner
 	}
 
-	return results, nil
+	if len(group) > 0 {
+		entry.Group = group
+	}
+
+	return fs.shadow.SetEntry(path, entry)
+}
+
+// FreeSpace returns the bytes available on the mount holding the path
+func (fs *Filesystem) FreeSpace(path string) (uint64, error) {
+	if f, ok := fs.chroot.(freeSpacer); ok {
+		return f.FreeSpace(path)
+	}
+
+	return freeSpace(fs.chroot)
+}
+
+// RatioFree checks to see if downloading the path should not consume the user's
+// credits
+func (fs *Filesystem) RatioFree(path string, user *acl.User) bool {
+	return fs.permissions.Match(acl.PermissionScopeRatioFree, path, user)
+}
+
+// UploadFree checks to see if uploading to the path should not award the user
+// any credits
+func (fs *Filesystem) UploadFree(path string, user *acl.User) bool {
+	return fs.permissions.Match(acl.PermissionScopeUploadFree, path, user)
+}
+
+// Allowed checks the acl rules of the scope for the path and user, i.e. for
+// scopes registered by plugins
+func (fs *Filesystem) Allowed(scope acl.PermissionScope, path string, user *acl.User) bool {
+	return fs.permissions.Match(scope, path, user)
+}
+
+// HideInWho checks to see if sessions in the path should be hidden from the
+// user in SITE WHO
+func (fs *Filesystem) HideInWho(path string, user *acl.User) bool {
+	return fs.permissions.Match(acl.PermissionScopeHideInWho, path, user)
 }
 
-// checkOwnership checks to see if a user is an owner of a given path. Returns bool
-// and an error
+// Subscribe adds fn to be called for every change made to the filesystem
+func (fs *Filesystem) Subscribe(fn func(Event)) {
+	fs.events.Subscribe(fn)
+}
+
+// publish sends an Event made by user for path to any subscribers
+func (fs *Filesystem) publish(t EventType, path string, user *acl.User, dir bool) {
+	fs.events.Publish(Event{
+		Type:  t,
+		Path:  path,
+		User:  user.Name,
+		Group: user.PrimaryGroup,
+		Dir:   dir,
+	})
+}
+
+// hidden checks to see if the path matches the `fs hide` regexp, is an upload
+// in progress or matches a hide rule for the user
+func (fs *Filesystem) hidden(path string, user *acl.User) bool {
+	if fs.hideRE != nil && fs.hideRE.MatchString(path) {
+		return true
+	}
+
+	if isUploadTemp(path) {
+		return true
+	}
+
+	return fs.permissions.Match(acl.PermissionScopeHide, path, user)
+}
+
+// checkOwnership checks to see if a user is an owner of a given path. Paths with
+// no shadow entry are owned by nobody. Returns bool and an error
 func (fs *Filesystem) checkOwnership(path string, user *acl.User) (bool, error) {
 	username, _, err := fs.shadow.Get(path)
 	if err != nil {
+		if err == ErrNoPath {
+			return false, nil
+		}
 		return false, err
 	}
 
@@ -3,9 +3,12 @@ package vfs
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gobwas/glob"
 	"github.com/goftpd/goftpd/acl"
 	"github.com/pkg/errors"
 )
@@ -203,6 +206,72 @@ func TestUploadFile(t *testing.T) {
 	}
 }
 
+func TestUploadFileAtomic(t *testing.T) {
+	user := newTestUser("user", "group")
+
+	fs := newMemoryFilesystem(t, []string{
+		"upload /** *",
+		"resume /** *",
+		"download /** *",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	checkListed := func(expected int) {
+		t.Helper()
+
+		files, err := fs.ListDir("/", user)
+		checkErr(t, err, nil)
+
+		if len(files) != expected {
+			t.Fatalf("expected %d files listed got %d", expected, len(files))
+		}
+	}
+
+	writer, err := fs.UploadFile("/file", user)
+	checkErr(t, err, nil)
+
+	fmt.Fprint(writer, "HELLO")
+
+	// in progress uploads can't be seen
+	checkListed(0)
+
+	_, err = fs.DownloadFile(uploadTempPath("/file"), user)
+	checkErr(t, err, os.ErrNotExist)
+
+	// or uploaded again
+	_, err = fs.UploadFile("/file", user)
+	checkErr(t, err, os.ErrExist)
+
+	checkErr(t, writer.Close(), nil)
+
+	checkListed(1)
+
+	writer, err = fs.ResumeUploadFile("/file", user)
+	checkErr(t, err, nil)
+
+	fmt.Fprint(writer, " WORLD")
+
+	checkListed(0)
+
+	checkErr(t, writer.Close(), nil)
+
+	checkListed(1)
+
+	f, err := fs.DownloadFile("/file", user)
+	checkErr(t, err, nil)
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	checkErr(t, err, nil)
+
+	if string(b) != "HELLO WORLD" {
+		t.Errorf("unexpected contents '%s'", b)
+	}
+}
+
 func TestResumeUploadFile(t *testing.T) {
 	var tests = []struct {
 		create  bool
@@ -470,6 +539,10 @@ func TestRenameFile(t *testing.T) {
 					if group != "nobody" {
 						t.Errorf("expected group to be nobody got: '%s'", group)
 					}
+
+					if e, _ := fs.shadow.GetEntry(tt.newpath); e.CreatedAt.IsZero() {
+						t.Error("expected creation time to be kept")
+					}
 				}
 			},
 		)
@@ -617,6 +690,49 @@ func TestListDirSortByName(t *testing.T) {
 	}
 
 }
+func TestListDirSortAndFilter(t *testing.T) {
+	owner := newTestUser("user", "group")
+	rules := []string{
+		"download /** *",
+	}
+
+	fs := newMemoryFilesystem(t, rules)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	createFile(t, fs, "/a.sfv", "SFV")
+	createFile(t, fs, "/b.rar", "RAR FILE CONTENTS")
+	createFile(t, fs, "/c.r00", "R00 FILE")
+
+	files, err := fs.ListDir("/", owner)
+	checkErr(t, err, nil)
+
+	names := func(flist FileList) string {
+		var b strings.Builder
+		for _, f := range flist {
+			b.WriteString(f.Name() + " ")
+		}
+		return strings.TrimSpace(b.String())
+	}
+
+	files.SortBySize()
+	if got := names(files); got != "b.rar c.r00 a.sfv" {
+		t.Errorf("unexpected size order: %s", got)
+	}
+
+	files.Reverse()
+	if got := names(files); got != "a.sfv c.r00 b.rar" {
+		t.Errorf("unexpected reverse order: %s", got)
+	}
+
+	files.SortByName()
+	if got := names(files.Filter(glob.MustCompile("*.r??"))); got != "b.rar c.r00" {
+		t.Errorf("unexpected filtered: %s", got)
+	}
+}
+
 func TestListDirNoPermission(t *testing.T) {
 	owner := newTestUser("user", "group")
 	rules := []string{
@@ -642,3 +758,247 @@ func TestListDirNoPermission(t *testing.T) {
 		t.Fatal("expected files to be nil")
 	}
 }
+
+func TestListDirHideOwner(t *testing.T) {
+	owner := newTestUser("user", "group")
+	admin := newTestUser("admin", "group")
+
+	rules := []string{
+		"download /** *",
+		"hide_user /** !-admin *",
+		"hide_group /** =staff",
+	}
+
+	fs := newMemoryFilesystem(t, rules)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	createFile(t, fs, "/file", "FILE")
+	setShadowOwner(t, fs, "/file", owner)
+
+	var tests = []struct {
+		user  *acl.User
+		owner string
+		group string
+	}{
+		{owner, "nobody", "group"},
+		{admin, "user", "group"},
+		{newTestUser("other", "staff"), "nobody", "nogroup"},
+	}
+
+	for _, tt := range tests {
+		files, err := fs.ListDir("/", tt.user)
+		checkErr(t, err, nil)
+
+		if len(files) != 1 {
+			t.Fatalf("expected 1 file found %d", len(files))
+		}
+
+		if files[0].Owner != tt.owner || files[0].Group != tt.group {
+			t.Errorf("expected %s/%s for %s got %s/%s", tt.owner, tt.group, tt.user.Name, files[0].Owner, files[0].Group)
+		}
+	}
+
+	fs.HiddenUser = "hidden"
+
+	files, err := fs.ListDir("/", owner)
+	checkErr(t, err, nil)
+
+	if files[0].Owner != "hidden" {
+		t.Errorf("expected placeholder 'hidden' got '%s'", files[0].Owner)
+	}
+}
+
+func TestHide(t *testing.T) {
+	user := newTestUser("user", "group")
+	admin := newTestUser("admin", "group")
+
+	rules := []string{
+		"download /** *",
+		"hide /**/.incomplete* !-admin *",
+	}
+
+	fs := newMemoryFilesystem(t, rules)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	if err := fs.chroot.MkdirAll("/dir", defaultPerms); err != nil {
+		t.Fatalf("unexpected err creating dir: %s", err)
+	}
+
+	createFile(t, fs, "/dir/file", "FILE")
+	createFile(t, fs, "/dir/.incomplete-file", "FILE")
+
+	files, err := fs.ListDir("/dir", user)
+	checkErr(t, err, nil)
+
+	if len(files) != 1 {
+		t.Errorf("expected 1 file for user found %d", len(files))
+	}
+
+	_, err = fs.DownloadFile("/dir/.incomplete-file", user)
+	checkErr(t, err, os.ErrNotExist)
+
+	files, err = fs.ListDir("/dir", admin)
+	checkErr(t, err, nil)
+
+	if len(files) != 2 {
+		t.Errorf("expected 2 files for admin found %d", len(files))
+	}
+
+	f, err := fs.DownloadFile("/dir/.incomplete-file", admin)
+	checkErr(t, err, nil)
+	f.Close()
+}
+
+func TestFreeLeech(t *testing.T) {
+	rules := []string{
+		"ratio_free /archive/** *",
+		"upload_free /archive/** *",
+		"ratio_free /incoming/** =staff",
+	}
+
+	var tests = []struct {
+		path   string
+		user   *acl.User
+		ratio  bool
+		upload bool
+	}{
+		{"/archive/some/file", newTestUser("user", "group"), true, true},
+		{"/incoming/some/file", newTestUser("user", "group"), false, false},
+		{"/incoming/some/file", newTestUser("user", "staff"), true, false},
+		{"/other/file", newTestUser("user", "staff"), false, false},
+	}
+
+	fs := newMemoryFilesystem(t, rules)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	for idx, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%d", idx),
+			func(t *testing.T) {
+				if got := fs.RatioFree(tt.path, tt.user); got != tt.ratio {
+					t.Errorf("expected ratio free to be %t got %t", tt.ratio, got)
+				}
+
+				if got := fs.UploadFree(tt.path, tt.user); got != tt.upload {
+					t.Errorf("expected upload free to be %t got %t", tt.upload, got)
+				}
+			},
+		)
+	}
+}
+
+func TestJail(t *testing.T) {
+	rules := []string{
+		"download /** *",
+		"upload /** *",
+		"makedir /** *",
+	}
+
+	fs := newMemoryFilesystem(t, rules)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	createFile(t, fs, "/home/user/file", "HOME")
+	createFile(t, fs, "/home/other/file", "OTHER")
+
+	user := newTestUser("user", "group")
+	user.HomeDir = "/home/user"
+	user.AddFlag(acl.FlagJail)
+
+	var tests = []struct {
+		path string
+		err  error
+	}{
+		{"/home/user", nil},
+		{"/home/user/file", nil},
+		{"/home/other/file", os.ErrNotExist},
+		{"/home/user/../other/file", os.ErrNotExist},
+		{"/home/userfile", os.ErrNotExist},
+		{"/", os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		_, err := fs.DownloadFile(fs.Join("/", []string{tt.path}), user)
+		if tt.err != nil {
+			checkErr(t, err, tt.err)
+		}
+		if tt.err == nil && err == os.ErrNotExist {
+			t.Errorf("expected '%s' to be accessible", tt.path)
+		}
+	}
+
+	checkErr(t, fs.MakeDir("/home/other/dir", user), acl.ErrPermissionDenied)
+	checkErr(t, fs.MakeDir(fs.Join("/home/user", []string{"dir"}), user), nil)
+
+	// without the flag the home does not restrict
+	user.RemoveFlag(acl.FlagJail)
+
+	_, err := fs.DownloadFile("/home/other/file", user)
+	checkErr(t, err, nil)
+}
+
+func TestChangeOwner(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{"private /dir/secret !*"})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	createFile(t, fs, "/dir/file", "FILE")
+	createFile(t, fs, "/dir/sub/file", "FILE")
+	createFile(t, fs, "/dir/secret", "SECRET")
+
+	owner := newTestUser("owner", "group")
+	setShadowOwner(t, fs, "/dir/file", owner)
+
+	user := newTestUser("user", "group")
+
+	n, err := fs.ChangeOwner("/dir/file", "new", "", false, user)
+	checkErr(t, err, nil)
+
+	if n != 1 {
+		t.Errorf("expected 1 path changed got %d", n)
+	}
+
+	username, group, err := fs.shadow.Get("/dir/file")
+	checkErr(t, err, nil)
+
+	if username != "new" || group != "group" {
+		t.Errorf("unexpected owner '%s:%s'", username, group)
+	}
+
+	// recursive skips private paths
+	n, err = fs.ChangeOwner("/dir", "", "other", true, user)
+	checkErr(t, err, nil)
+
+	if n != 4 {
+		t.Errorf("expected 4 paths changed got %d", n)
+	}
+
+	for _, p := range []string{"/dir", "/dir/file", "/dir/sub", "/dir/sub/file"} {
+		if _, group, _ := fs.shadow.Get(p); group != "other" {
+			t.Errorf("expected '%s' group to be other got '%s'", p, group)
+		}
+	}
+
+	if _, _, err := fs.shadow.Get("/dir/secret"); err != ErrNoPath {
+		t.Errorf("expected private path to be skipped got %v", err)
+	}
+
+	_, err = fs.ChangeOwner("/dir/secret", "new", "", false, user)
+	checkErr(t, err, os.ErrNotExist)
+
+	_, err = fs.ChangeOwner("/missing", "new", "", false, user)
+	checkErr(t, err, os.ErrNotExist)
+}
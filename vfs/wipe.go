@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+// Wipe removes the file or directory at path and everything under it
+// regardless of the delete scopes, the user needs to match the wipe scope
+// for the path instead. Progress, if not nil, is called periodically for
+// large trees. Returns what was removed even if it failed part way
+func (fs *Filesystem) Wipe(path string, user *acl.User, progress func(Removed)) (Removed, error) {
+	path = filepath.Clean("/" + path)
+
+	// check for jail
+	if !user.CanAccess(path) {
+		return Removed{}, os.ErrNotExist
+	}
+
+	// check for private
+	if match, found := fs.permissions.MatchNoDefault(acl.PermissionScopePrivate, path, user); found && !match {
+		return Removed{}, os.ErrNotExist
+	}
+
+	if fs.hidden(path, user) {
+		return Removed{}, os.ErrNotExist
+	}
+
+	if path == "/" || !fs.permissions.Match(acl.PermissionScopeWipe, path, user) {
+		return Removed{}, acl.ErrPermissionDenied
+	}
+
+	finfo, err := fs.chroot.Lstat(path)
+	if err != nil {
+		return Removed{}, err
+	}
+
+	removed, err := fs.removeTree(path, progress)
+	if err != nil {
+		return removed, err
+	}
+
+	e := Event{
+		Type:  EventDeleteFile,
+		Path:  path,
+		User:  user.Name,
+		Group: user.PrimaryGroup,
+		Wipe:  true,
+	}
+
+	if finfo.IsDir() {
+		e.Type = EventDeleteDir
+		e.Dir = true
+	}
+
+	fs.events.Publish(e)
+
+	return removed, nil
+}
@@ -0,0 +1,142 @@
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/goftpd/goftpd/acl"
+)
+
+func TestWipe(t *testing.T) {
+	fs := newMemoryFilesystem(t, []string{
+		"wipe /mp3/** -admin",
+	})
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	admin := newTestUser("admin", "siteops")
+	user := newTestUser("user", "group")
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release/cd1", defaultPerms), nil)
+
+	for i := 0; i < removeProgressEvery+10; i++ {
+		createFile(t, fs, fmt.Sprintf("/mp3/release/cd1/%04d.mp3", i), "A")
+	}
+
+	createFile(t, fs, "/mp3/release/file.nfo", "NFO")
+	setShadowOwner(t, fs, "/mp3/release/file.nfo", user)
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	_, err := fs.Wipe("/mp3/release", user, nil)
+	checkErr(t, err, acl.ErrPermissionDenied)
+
+	_, err = fs.Wipe("/", admin, nil)
+	checkErr(t, err, acl.ErrPermissionDenied)
+
+	var calls int
+
+	removed, err := fs.Wipe("/mp3/release", admin, func(Removed) { calls++ })
+	checkErr(t, err, nil)
+
+	if calls != 1 {
+		t.Errorf("expected 1 progress call got %d", calls)
+	}
+
+	expected := Removed{Files: removeProgressEvery + 11, Dirs: 2, Bytes: removeProgressEvery + 13}
+	if removed != expected {
+		t.Errorf("expected %+v got %+v", expected, removed)
+	}
+
+	if _, err := fs.chroot.Lstat("/mp3/release"); !os.IsNotExist(err) {
+		t.Errorf("expected /mp3/release to be removed got %v", err)
+	}
+
+	if _, err := fs.shadow.GetEntry("/mp3/release/file.nfo"); err != ErrNoPath {
+		t.Errorf("expected shadow entry to be removed got %v", err)
+	}
+
+	if len(events) != 1 || events[0].Type != EventDeleteDir || events[0].Path != "/mp3/release" || !events[0].Wipe {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	_, err = fs.Wipe("/mp3/release", admin, nil)
+	checkErr(t, err, os.ErrNotExist)
+}
+
+func TestPurge(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	checkErr(t, fs.chroot.MkdirAll("/tmp/one", defaultPerms), nil)
+	checkErr(t, fs.chroot.MkdirAll("/tmp/two", defaultPerms), nil)
+
+	createFile(t, fs, "/tmp/one/file", "AB")
+	createFile(t, fs, "/tmp/file", "C")
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	removed, err := fs.Purge("/tmp/*")
+	checkErr(t, err, nil)
+
+	expected := Removed{Files: 2, Dirs: 2, Bytes: 3}
+	if removed != expected {
+		t.Errorf("expected %+v got %+v", expected, removed)
+	}
+
+	files, err := fs.chroot.ReadDir("/tmp")
+	checkErr(t, err, nil)
+
+	if len(files) != 0 {
+		t.Errorf("expected /tmp to be empty got %d entries", len(files))
+	}
+
+	if len(events) != 3 || !events[0].Wipe || len(events[0].User) > 0 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	_, err = fs.Purge("/")
+	checkErr(t, err, fmt.Errorf("refusing to purge the root"))
+}
+
+func TestRemove(t *testing.T) {
+	fs := newMemoryFilesystem(t, nil)
+	if fs == nil {
+		t.Fatal("unexpected nil for fs")
+	}
+	defer stopMemoryFilesystem(t, fs)
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release", defaultPerms), nil)
+
+	createFile(t, fs, "/mp3/release/virus.exe", "AB")
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	removed, err := fs.Remove("/mp3/release/virus.exe")
+	checkErr(t, err, nil)
+
+	expected := Removed{Files: 1, Bytes: 2}
+	if removed != expected {
+		t.Errorf("expected %+v got %+v", expected, removed)
+	}
+
+	if _, err := fs.chroot.Stat("/mp3/release/virus.exe"); err == nil {
+		t.Error("expected the file to be removed")
+	}
+
+	if len(events) != 1 || events[0].Type != EventDeleteFile || events[0].Dir {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	_, err = fs.Remove("/")
+	checkErr(t, err, fmt.Errorf("refusing to remove the root"))
+}
@@ -1,6 +1,30 @@
 package vfs
 
-import "io"
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// how many bytes are written between free space checks
+const spaceCheckInterval = 4 * 1024 * 1024
+
+// uploads are written to a file named with this prefix in the same
+// directory and renamed in to place once complete
+const uploadTempPrefix = ".goftpd-upload-"
+
+// uploadTempPath returns the temporary path an upload to path is written to
+func uploadTempPath(path string) string {
+	return filepath.Join(filepath.Dir(path), uploadTempPrefix+filepath.Base(path))
+}
+
+// isUploadTemp checks to see if the path is an upload in progress
+func isUploadTemp(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), uploadTempPrefix)
+}
 
 // writer is a wrapper to the io.WriteCloser interface
 // that lets us call a callback on success. Relies on the caller
@@ -9,6 +33,17 @@ type writeCloser struct {
 	w              io.WriteCloser
 	err            error
 	onCloseSuccess func() error
+
+	// optional, called on close if any errors were made
+	onCloseFailure func()
+
+	// optional, called every spaceCheckInterval bytes to abort
+	// the write before the disk fills up
+	checkSpace func() error
+	unchecked  int64
+
+	// optional, everything written is also written to sum
+	sum io.Writer
 }
 
 // create a new writeCloser
@@ -21,26 +56,59 @@ func newWriteCloser(w io.WriteCloser, onCloseSuccess func() error) *writeCloser
 }
 
 // Writer wraps the underlying Write function and saves any errors.
+// Running out of disk space is returned as ErrNoSpace
 func (w *writeCloser) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if w.checkSpace != nil && w.unchecked >= spaceCheckInterval {
+		w.unchecked = 0
+
+		if err := w.checkSpace(); err != nil {
+			w.err = err
+			return 0, err
+		}
+	}
+
 	n, err := w.w.Write(p)
+	w.unchecked += int64(n)
+
+	if w.sum != nil {
+		w.sum.Write(p[:n])
+	}
+
 	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			err = ErrNoSpace
+		}
 		w.err = err
 	}
 	return n, err
 }
 
 // Close closes the underlying io.WriteCloser and if no errors were
-// made, it calls the onSuccess callback
+// made, it calls the onCloseSuccess callback, otherwise onCloseFailure
 func (w *writeCloser) Close() error {
 	if err := w.w.Close(); err != nil {
+		w.fail()
+		if errors.Is(err, syscall.ENOSPC) {
+			return ErrNoSpace
+		}
 		return err
 	}
 
-	if w.err == nil {
-		if err := w.onCloseSuccess(); err != nil {
-			return err
-		}
+	if w.err != nil {
+		w.fail()
+		return nil
 	}
 
-	return nil
+	return w.onCloseSuccess()
+}
+
+// fail calls the onCloseFailure callback if one is set
+func (w *writeCloser) fail() {
+	if w.onCloseFailure != nil {
+		w.onCloseFailure()
+	}
 }
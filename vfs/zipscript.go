@@ -0,0 +1,315 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goftpd/goftpd/zipscript"
+	"github.com/pkg/errors"
+)
+
+// SetZipscript turns on checking uploads against the sfv of their release,
+// see the zipscript package
+func (f *FilesystemOpts) SetZipscript(z *zipscript.Zipscript) { f.zipscript = z }
+
+func (fs *Filesystem) zipscriptEnabled() bool {
+	return fs.zipscript != nil && fs.zipscript.Enabled
+}
+
+// checkZipscriptUpload refuses an sfv uploaded to a release that already has
+// a different one
+func (fs *Filesystem) checkZipscriptUpload(path string) error {
+	if !fs.zipscriptEnabled() || !zipscript.IsSFV(path) {
+		return nil
+	}
+
+	name, err := fs.findSFV(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	if len(name) > 0 && !strings.EqualFold(name, filepath.Base(path)) {
+		return zipscript.ErrSFVExists
+	}
+
+	return nil
+}
+
+// zipscriptUpload checks a finished upload against the sfv of its release.
+// With refuse an upload that fails its CRC is removed and
+// zipscript.ErrBadCRC returned, as is an sfv that can't be parsed. The
+// markers of the release are then brought up to date and its progress
+// returned, nil if it has no sfv. completed is true when the upload
+// finished the release
+func (fs *Filesystem) zipscriptUpload(path string, size int64, sum Checksum, refuse bool) (progress *zipscript.Progress, completed bool, err error) {
+	if !fs.zipscriptEnabled() {
+		return nil, false, nil
+	}
+
+	fs.zipscriptMtx.Lock()
+	defer fs.zipscriptMtx.Unlock()
+
+	dir, name := filepath.Dir(path), filepath.Base(path)
+
+	sfv, err := fs.readSFV(dir)
+	if err != nil {
+		if refuse && zipscript.IsSFV(name) {
+			fs.removeUpload(path, size)
+			return nil, false, errors.WithMessage(err, "bad sfv")
+		}
+		return nil, false, nil
+	}
+
+	if sfv == nil {
+		return nil, false, nil
+	}
+
+	if crc, ok := sfv.CRC(name); ok && crc != sum.CRC32 && refuse {
+		fs.removeUpload(path, size)
+		fs.updateRelease(dir, sfv)
+		return nil, false, zipscript.ErrBadCRC
+	}
+
+	p, completed, err := fs.updateRelease(dir, sfv)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &p, completed, nil
+}
+
+// publishUpload publishes the EventUpload of a finished upload, followed by
+// an EventComplete if it completed its release
+func (fs *Filesystem) publishUpload(e Event, completed bool) {
+	fs.events.Publish(e)
+
+	if !completed {
+		return
+	}
+
+	fs.events.Publish(Event{
+		Type:    EventComplete,
+		Path:    filepath.Dir(e.Path),
+		User:    e.User,
+		Group:   e.Group,
+		Dir:     true,
+		Size:    e.Release.Bytes,
+		Release: e.Release,
+	})
+}
+
+// zipscriptDelete brings the markers of the release up to date after one of
+// its files is deleted, deleting the sfv removes them
+func (fs *Filesystem) zipscriptDelete(path string) error {
+	if !fs.zipscriptEnabled() {
+		return nil
+	}
+
+	fs.zipscriptMtx.Lock()
+	defer fs.zipscriptMtx.Unlock()
+
+	dir := filepath.Dir(path)
+
+	sfv, err := fs.readSFV(dir)
+	if err != nil {
+		return nil
+	}
+
+	if sfv == nil {
+		if zipscript.IsSFV(path) {
+			return fs.removeMarkers(dir)
+		}
+		return nil
+	}
+
+	_, _, err = fs.updateRelease(dir, sfv)
+
+	return err
+}
+
+// findSFV returns the name of the sfv in the directory, empty if there
+// isn't one
+func (fs *Filesystem) findSFV(dir string) (string, error) {
+	files, err := fs.chroot.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		if f.Mode().IsRegular() && zipscript.IsSFV(f.Name()) && !isUploadTemp(f.Name()) {
+			return f.Name(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// readSFV parses the sfv in the directory, nil if there isn't one
+func (fs *Filesystem) readSFV(dir string) (*zipscript.SFV, error) {
+	name, err := fs.findSFV(dir)
+	if err != nil || len(name) == 0 {
+		return nil, err
+	}
+
+	f, err := fs.chroot.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return zipscript.ParseSFV(f)
+}
+
+// updateRelease checks every file of the sfv, creating markers for those
+// missing and removing them for those present. Files that fail their CRC
+// are removed. The progress marker is replaced to show how far along the
+// release is, completed is true when this call finished the release
+func (fs *Filesystem) updateRelease(dir string, sfv *zipscript.SFV) (progress zipscript.Progress, completed bool, err error) {
+	files, err := fs.chroot.ReadDir(dir)
+	if err != nil {
+		return progress, false, err
+	}
+
+	present := make(map[string]os.FileInfo, len(files))
+
+	var markers []string
+	var wasComplete bool
+
+	for _, f := range files {
+		present[strings.ToLower(f.Name())] = f
+
+		if !f.IsDir() {
+			continue
+		}
+
+		switch {
+		case fs.zipscript.IsCompleteMarker(f.Name()):
+			wasComplete = true
+			markers = append(markers, f.Name())
+		case fs.zipscript.IsIncompleteMarker(f.Name()):
+			markers = append(markers, f.Name())
+		}
+	}
+
+	progress.Total = len(sfv.Files)
+
+	for _, name := range sfv.Files {
+		path := filepath.Join(dir, name)
+		missing := filepath.Join(dir, fs.zipscript.MissingMarker(name))
+
+		if f, ok := present[strings.ToLower(name)]; ok && f.Mode().IsRegular() {
+			crc, _ := sfv.CRC(name)
+
+			if fs.checkCRC(filepath.Join(dir, f.Name()), crc) {
+				progress.Done++
+				progress.Bytes += f.Size()
+
+				if _, ok := present[strings.ToLower(fs.zipscript.MissingMarker(name))]; ok {
+					fs.chroot.Remove(missing)
+				}
+
+				continue
+			}
+
+			fs.removeUpload(filepath.Join(dir, f.Name()), f.Size())
+		}
+
+		if _, ok := present[strings.ToLower(fs.zipscript.MissingMarker(name))]; !ok {
+			m, err := fs.chroot.OpenFile(missing, os.O_WRONLY|os.O_CREATE, fs.createPerms(false))
+			if err != nil {
+				return progress, false, errors.WithMessagef(err, "creating marker for '%s'", path)
+			}
+			m.Close()
+		}
+	}
+
+	marker := fs.zipscript.IncompleteMarker(progress)
+	if progress.Complete() {
+		marker = fs.zipscript.CompleteMarker(progress)
+	}
+
+	for _, m := range markers {
+		if m != marker {
+			fs.chroot.Remove(filepath.Join(dir, m))
+		}
+	}
+
+	if _, ok := present[strings.ToLower(marker)]; !ok {
+		if err := fs.chroot.MkdirAll(filepath.Join(dir, marker), fs.createPerms(true)); err != nil {
+			return progress, false, errors.WithMessage(err, "creating progress marker")
+		}
+	}
+
+	return progress, progress.Complete() && !wasComplete, nil
+}
+
+// checkCRC compares the CRC of the file with crc, using the checksum
+// recorded on upload when there is one
+func (fs *Filesystem) checkCRC(path string, crc uint32) bool {
+	e, err := fs.shadow.GetEntry(path)
+	if err == nil && !e.Checksum.IsZero() {
+		return e.Checksum.CRC32 == crc
+	}
+
+	sum, err := fs.checksumFile(path)
+	if err != nil {
+		return false
+	}
+
+	return sum.CRC32 == crc
+}
+
+// removeMarkers removes every zipscript marker in the directory
+func (fs *Filesystem) removeMarkers(dir string) error {
+	files, err := fs.chroot.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if fs.isMarker(f) {
+			if err := fs.chroot.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// onlyMarkers checks to see if the directory holds nothing but markers, so
+// they can be removed along with it
+func (fs *Filesystem) onlyMarkers(dir string) bool {
+	files, err := fs.chroot.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, f := range files {
+		if !fs.isMarker(f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isMarker checks to see if the file is a missing or progress marker
+func (fs *Filesystem) isMarker(f os.FileInfo) bool {
+	if f.IsDir() {
+		return fs.zipscript.IsIncompleteMarker(f.Name()) || fs.zipscript.IsCompleteMarker(f.Name())
+	}
+
+	return f.Size() == 0 && fs.zipscript.IsMissingMarker(f.Name())
+}
+
+// removeUpload removes a file refused by the zipscript
+func (fs *Filesystem) removeUpload(path string, size int64) {
+	if err := fs.chroot.Remove(path); err != nil {
+		return
+	}
+
+	fs.adjustSize(path, -size)
+	fs.shadow.Remove(path)
+}
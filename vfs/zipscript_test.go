@@ -0,0 +1,183 @@
+package vfs
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/goftpd/goftpd/zipscript"
+)
+
+func newZipscriptFilesystem(t *testing.T) *Filesystem {
+	t.Helper()
+
+	fs := newMemoryFilesystem(t, []string{
+		"upload /** *",
+		"download /** *",
+		"delete /** *",
+	})
+
+	z, err := zipscript.NewZipscript(&zipscript.ZipscriptOpts{
+		Enabled:    true,
+		Incomplete: "[%[done] of %[total]]",
+		Complete:   "[complete %[total]]",
+	})
+	checkErr(t, err, nil)
+
+	fs.SetZipscript(z)
+
+	checkErr(t, fs.chroot.MkdirAll("/mp3/release", defaultPerms), nil)
+
+	return fs
+}
+
+func upload(fs *Filesystem, path, contents string) error {
+	w, err := fs.UploadFile(path, newTestUser("user", "group"))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, contents); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func checkExists(t *testing.T, fs *Filesystem, path string, exists bool) {
+	t.Helper()
+
+	_, err := fs.chroot.Lstat(path)
+
+	if exists && err != nil {
+		t.Fatalf("expected %s to exist got %s", path, err)
+	}
+
+	if !exists && !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to exist got %v", path, err)
+	}
+}
+
+func TestZipscript(t *testing.T) {
+	fs := newZipscriptFilesystem(t)
+	defer stopMemoryFilesystem(t, fs)
+
+	var events []Event
+	fs.Subscribe(func(e Event) { events = append(events, e) })
+
+	// uploaded before the sfv, checked once it arrives
+	checkErr(t, upload(fs, "/mp3/release/01.mp3", "ONE"), nil)
+
+	sfv := fmt.Sprintf("; comment\n01.mp3 %08x\n02.mp3 %08X\n03.mp3 %08x\n",
+		crc32.ChecksumIEEE([]byte("ONE")),
+		crc32.ChecksumIEEE([]byte("TWO")),
+		crc32.ChecksumIEEE([]byte("THREE")),
+	)
+
+	checkErr(t, upload(fs, "/mp3/release/release.sfv", sfv), nil)
+
+	checkExists(t, fs, "/mp3/release/01.mp3-missing", false)
+	checkExists(t, fs, "/mp3/release/02.mp3-missing", true)
+	checkExists(t, fs, "/mp3/release/03.mp3-missing", true)
+	checkExists(t, fs, "/mp3/release/[1 of 3]", true)
+
+	// only one sfv per release
+	checkErr(t, upload(fs, "/mp3/release/other.sfv", sfv), zipscript.ErrSFVExists)
+
+	// a bad crc is refused and removed
+	err := upload(fs, "/mp3/release/02.mp3", "BAD")
+	if err != zipscript.ErrBadCRC {
+		t.Fatalf("expected bad crc got %v", err)
+	}
+
+	checkExists(t, fs, "/mp3/release/02.mp3", false)
+	checkExists(t, fs, "/mp3/release/02.mp3-missing", true)
+
+	checkErr(t, upload(fs, "/mp3/release/02.mp3", "TWO"), nil)
+
+	checkExists(t, fs, "/mp3/release/02.mp3-missing", false)
+	checkExists(t, fs, "/mp3/release/[1 of 3]", false)
+	checkExists(t, fs, "/mp3/release/[2 of 3]", true)
+
+	events = nil
+
+	checkErr(t, upload(fs, "/mp3/release/03.mp3", "THREE"), nil)
+
+	checkExists(t, fs, "/mp3/release/[2 of 3]", false)
+	checkExists(t, fs, "/mp3/release/[complete 3]", true)
+
+	if len(events) != 2 || events[0].Type != EventUpload || events[1].Type != EventComplete {
+		t.Fatalf("expected upload then complete events got %+v", events)
+	}
+
+	if events[1].Path != "/mp3/release" || events[1].Size != 11 || events[1].User != "user" {
+		t.Fatalf("unexpected complete event: %+v", events[1])
+	}
+
+	if p := events[0].Release; p == nil || p.Done != 3 || p.Total != 3 {
+		t.Fatalf("expected upload event to carry the release's progress got %+v", p)
+	}
+
+	// deleting a file makes the release incomplete again
+	checkErr(t, fs.DeleteFile("/mp3/release/03.mp3", newTestUser("user", "group")), nil)
+
+	checkExists(t, fs, "/mp3/release/[complete 3]", false)
+	checkExists(t, fs, "/mp3/release/[2 of 3]", true)
+	checkExists(t, fs, "/mp3/release/03.mp3-missing", true)
+
+	// deleting the sfv removes the markers, leaving a release that can
+	// be removed once emptied
+	for _, f := range []string{"01.mp3", "02.mp3", "release.sfv"} {
+		checkErr(t, fs.DeleteFile("/mp3/release/"+f, newTestUser("user", "group")), nil)
+	}
+
+	checkExists(t, fs, "/mp3/release/[2 of 3]", false)
+	checkExists(t, fs, "/mp3/release/03.mp3-missing", false)
+
+	checkErr(t, fs.DeleteDir("/mp3/release", newTestUser("user", "group")), nil)
+}
+
+func TestZipscriptDeleteDir(t *testing.T) {
+	fs := newZipscriptFilesystem(t)
+	defer stopMemoryFilesystem(t, fs)
+
+	checkErr(t, upload(fs, "/mp3/release/release.sfv", "01.mp3 00000000\n"), nil)
+	checkErr(t, fs.DeleteFile("/mp3/release/release.sfv", newTestUser("user", "group")), nil)
+
+	checkErr(t, upload(fs, "/mp3/release/release.sfv", "01.mp3 00000000\n"), nil)
+
+	// the sfv has to go before the release can be removed
+	if err := fs.DeleteDir("/mp3/release", newTestUser("user", "group")); err == nil {
+		t.Fatal("expected error deleting a release with files")
+	}
+
+	checkErr(t, fs.chroot.Remove("/mp3/release/release.sfv"), nil)
+	checkErr(t, fs.DeleteDir("/mp3/release", newTestUser("user", "group")), nil)
+}
+
+func TestZipscriptBadSFV(t *testing.T) {
+	fs := newZipscriptFilesystem(t)
+	defer stopMemoryFilesystem(t, fs)
+
+	if err := upload(fs, "/mp3/release/release.sfv", "01.mp3 nothex!\n"); err == nil {
+		t.Fatal("expected error uploading a bad sfv")
+	}
+
+	checkExists(t, fs, "/mp3/release/release.sfv", false)
+}
+
+func TestZipscriptDisabled(t *testing.T) {
+	fs := newZipscriptFilesystem(t)
+	defer stopMemoryFilesystem(t, fs)
+
+	fs.zipscript.Enabled = false
+
+	checkErr(t, upload(fs, "/mp3/release/release.sfv", "01.mp3 00000000\n"), nil)
+	checkErr(t, upload(fs, "/mp3/release/01.mp3", "ONE"), nil)
+
+	checkExists(t, fs, "/mp3/release/[1 of 1]", false)
+	checkExists(t, fs, "/mp3/release/[0 of 1]", false)
+}
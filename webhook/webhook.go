@@ -0,0 +1,288 @@
+// Package webhook POSTs JSON payloads describing server events to HTTP
+// endpoints, i.e. for custom dashboards
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goftpd/goftpd/logging"
+	"github.com/pkg/errors"
+)
+
+// events webhooks can be sent for
+const (
+	EventLogin  = "login"
+	EventUpload = "upload"
+	EventNuke   = "nuke"
+	// an upload refused as it would use the reserved free space
+	EventQuota = "quota"
+	// every file of a release has passed its sfv check
+	EventRace = "race"
+)
+
+var events = map[string]struct{}{
+	EventLogin:  {},
+	EventUpload: {},
+	EventNuke:   {},
+	EventQuota:  {},
+	EventRace:   {},
+}
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the body, signed
+// with the secret, prefixed with `sha256=`
+const SignatureHeader = "X-Goftpd-Signature"
+
+// defaults used when options are zero
+const (
+	defaultTimeout   = 10
+	defaultRetries   = 3
+	defaultQueueSize = 1000
+)
+
+// Payload is the JSON body POSTed for an event
+type Payload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	User  string    `json:"user,omitempty"`
+	Group string    `json:"group,omitempty"`
+	IP    string    `json:"ip,omitempty"`
+	Path  string    `json:"path,omitempty"`
+	Size  int64     `json:"size,omitempty"`
+	// event specific details, i.e. the reason of a nuke
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Hook is an endpoint sent the payloads of an event
+type Hook struct {
+	event string
+	url   string
+}
+
+// NewHook takes a line of text (i.e. from a config file) in the form of
+// `<event> <url>`
+func NewHook(line string) (Hook, error) {
+	var hook Hook
+
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 {
+		return hook, errors.New("hook requires 2 fields")
+	}
+
+	hook.event = strings.ToLower(fields[0])
+
+	if _, ok := events[hook.event]; !ok {
+		return hook, errors.Errorf("unknown event '%s'", fields[0])
+	}
+
+	if !strings.HasPrefix(fields[1], "http://") && !strings.HasPrefix(fields[1], "https://") {
+		return hook, errors.Errorf("url must be http or https: '%s'", fields[1])
+	}
+
+	hook.url = fields[1]
+
+	return hook, nil
+}
+
+// Dispatcher sends payloads to the hooks of their event
+type Dispatcher interface {
+	Send(Payload)
+	Close() error
+}
+
+type WebhookOpts struct {
+	// signs every payload when set
+	Secret string `goftpd:"secret"`
+	// seconds to wait for an endpoint to respond, zero uses 10
+	Timeout int `goftpd:"timeout"`
+	// attempts after the first fails, with a doubling delay between them.
+	// zero uses 3
+	Retries int `goftpd:"retries"`
+	// payloads waiting to be sent, more are dropped. zero uses 1000
+	QueueSize int `goftpd:"queue_size"`
+
+	logger logging.Logger
+}
+
+// SetLogger sets where failed deliveries are logged, defaults to
+// logging.Stderr
+func (o *WebhookOpts) SetLogger(l logging.Logger) { o.logger = l }
+
+// Logger returns where failed deliveries are logged
+func (o *WebhookOpts) Logger() logging.Logger {
+	if o == nil || o.logger == nil {
+		return logging.Stderr
+	}
+	return o.logger
+}
+
+// delivery is a payload on its way to a single hook
+type delivery struct {
+	url  string
+	body []byte
+}
+
+// HTTPDispatcher implements Dispatcher, payloads are queued and sent in
+// the background
+type HTTPDispatcher struct {
+	*WebhookOpts
+	hooks []Hook
+
+	client     *http.Client
+	retryDelay time.Duration
+
+	// held while queueing so the queue isn't closed underneath Send
+	mtx    sync.RWMutex
+	closed bool
+	queue  chan delivery
+	wg     sync.WaitGroup
+}
+
+// NewHTTPDispatcher takes in options and the hooks to send payloads to,
+// the background sender is started straight away
+func NewHTTPDispatcher(opts *WebhookOpts, hooks []Hook) *HTTPDispatcher {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if opts.Retries == 0 {
+		opts.Retries = defaultRetries
+	}
+
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	d := HTTPDispatcher{
+		WebhookOpts: opts,
+		hooks:       hooks,
+		client: &http.Client{
+			Timeout: time.Duration(opts.Timeout) * time.Second,
+		},
+		retryDelay: time.Second,
+		queue:      make(chan delivery, opts.QueueSize),
+	}
+
+	d.wg.Add(1)
+	go d.run()
+
+	return &d
+}
+
+// Send queues the payload for every hook of its event. If the queue is full
+// the payload is dropped and logged, once closed payloads are ignored
+func (d *HTTPDispatcher) Send(p Payload) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	if d.closed {
+		return
+	}
+
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+
+	var body []byte
+
+	for _, h := range d.hooks {
+		if h.event != p.Event {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			body, err = json.Marshal(p)
+			if err != nil {
+				d.Logger().Error("unable to encode webhook", logging.F("event", p.Event), logging.Err(err))
+				return
+			}
+		}
+
+		select {
+		case d.queue <- delivery{h.url, body}:
+		default:
+			d.Logger().Warn("webhook queue full, dropped payload", logging.F("event", p.Event), logging.F("url", h.url))
+		}
+	}
+}
+
+// run sends queued deliveries until the queue is closed
+func (d *HTTPDispatcher) run() {
+	defer d.wg.Done()
+
+	for dl := range d.queue {
+		delay := d.retryDelay
+
+		for attempt := 0; ; attempt++ {
+			err := d.post(dl)
+			if err == nil {
+				break
+			}
+
+			if attempt >= d.Retries {
+				d.Logger().Warn("webhook failed", logging.F("url", dl.url), logging.F("attempts", attempt+1), logging.Err(err))
+				break
+			}
+
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// post makes a single attempt at the delivery, anything other than a 2xx
+// response is a failure
+func (d *HTTPDispatcher) post(dl delivery) error {
+	req, err := http.NewRequest(http.MethodPost, dl.url, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(d.Secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(d.Secret, dl.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body using the secret,
+// endpoints compare it to the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close stops accepting payloads and waits for those queued to be sent
+func (d *HTTPDispatcher) Close() error {
+	d.mtx.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mtx.Unlock()
+
+	d.wg.Wait()
+	return nil
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewHook(t *testing.T) {
+	var tests = []struct {
+		line     string
+		expected error
+	}{
+		{"upload https://example.com/hook", nil},
+		{"LOGIN http://localhost:8080/", nil},
+		{"upload", errors.New("hook requires 2 fields")},
+		{"rename https://example.com/hook", errors.New("unknown event 'rename'")},
+		{"nuke ftp://example.com/hook", errors.New("url must be http or https: 'ftp://example.com/hook'")},
+	}
+
+	for _, tt := range tests {
+		_, err := NewHook(tt.line)
+		checkErr(t, err, tt.expected)
+	}
+}
+
+func TestHTTPDispatcher(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		attempts int
+		received []Payload
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		attempts++
+
+		// fail the first attempt to check retries
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		checkErr(t, err, nil)
+
+		if got := r.Header.Get(SignatureHeader); got != "sha256="+Sign("secret", body) {
+			t.Errorf("unexpected signature '%s'", got)
+		}
+
+		var p Payload
+		checkErr(t, json.Unmarshal(body, &p), nil)
+
+		received = append(received, p)
+	}))
+	defer srv.Close()
+
+	hook, err := NewHook("upload " + srv.URL)
+	checkErr(t, err, nil)
+
+	d := NewHTTPDispatcher(&WebhookOpts{Secret: "secret", Retries: 2}, []Hook{hook})
+	d.retryDelay = time.Millisecond
+
+	d.Send(Payload{Event: EventUpload, User: "user", Path: "/file", Size: 10})
+	d.Send(Payload{Event: EventLogin, User: "user"})
+
+	checkErr(t, d.Close(), nil)
+
+	// ignored once closed
+	d.Send(Payload{Event: EventUpload})
+
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts got %d", attempts)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 payload got %d", len(received))
+	}
+
+	if p := received[0]; p.Event != EventUpload || p.User != "user" || p.Path != "/file" || p.Size != 10 || p.Time.IsZero() {
+		t.Errorf("unexpected payload %+v", p)
+	}
+}
+
+func TestHTTPDispatcherGivesUp(t *testing.T) {
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	hook, err := NewHook("nuke " + srv.URL)
+	checkErr(t, err, nil)
+
+	d := NewHTTPDispatcher(&WebhookOpts{Retries: 3}, []Hook{hook})
+	d.retryDelay = time.Millisecond
+
+	d.Send(Payload{Event: EventNuke})
+
+	checkErr(t, d.Close(), nil)
+
+	if attempts != 4 {
+		t.Errorf("expected 4 attempts got %d", attempts)
+	}
+}
@@ -0,0 +1,223 @@
+// Package xferlog records every file transfer in wu-ftpd's xferlog format,
+// or as JSON, so that standard traffic analyzers can be used
+package xferlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// directions of a Transfer
+const (
+	Upload   = "upload"
+	Download = "download"
+)
+
+// formats records can be written in
+const (
+	FormatXferlog = "xferlog"
+	FormatJSON    = "json"
+)
+
+// logTimeFormat is ctime(3), which wu-ftpd uses for the current-time field
+const logTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// Transfer is a finished, or failed part way, transfer of a file
+type Transfer struct {
+	// when the transfer finished
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"-"`
+	IP       string        `json:"ip"`
+	Bytes    int64         `json:"bytes"`
+	Path     string        `json:"path"`
+	// false for ascii mode transfers
+	Binary    bool   `json:"binary"`
+	Direction string `json:"direction"`
+	Anonymous bool   `json:"anonymous"`
+	User      string `json:"user"`
+	// the ident response of the client, empty if there wasn't one
+	Ident    string `json:"ident,omitempty"`
+	Complete bool   `json:"complete"`
+}
+
+// seconds returns the duration in whole seconds, at least 1, as the
+// transfer-time field of xferlog is
+func (t Transfer) seconds() int64 {
+	s := int64(math.Round(t.Duration.Seconds()))
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// Xferlog returns the xferlog line of the Transfer, ending in a new
+// line:
+//
+//	<current-time> <transfer-time> <remote-host> <file-size> <filename>
+//	<transfer-type> <special-action-flag> <direction> <access-mode>
+//	<username> <service-name> <authentication-method>
+//	<authenticated-user-id> <completion-status>
+//
+// fields are separated by spaces so spaces in the filename are replaced
+// with underscores as wu-ftpd does
+func (t Transfer) Xferlog() string {
+	transferType := "a"
+	if t.Binary {
+		transferType = "b"
+	}
+
+	direction := "i"
+	if t.Direction == Download {
+		direction = "o"
+	}
+
+	accessMode := "r"
+	if t.Anonymous {
+		accessMode = "a"
+	}
+
+	// 1 is RFC 931 (ident) authentication
+	authMethod, authUser := "0", "*"
+	if len(t.Ident) > 0 {
+		authMethod, authUser = "1", t.Ident
+	}
+
+	status := "i"
+	if t.Complete {
+		status = "c"
+	}
+
+	return fmt.Sprintf("%s %d %s %d %s %s _ %s %s %s ftp %s %s %s\n",
+		t.Time.Format(logTimeFormat),
+		t.seconds(),
+		t.IP,
+		t.Bytes,
+		escape(t.Path),
+		transferType,
+		direction,
+		accessMode,
+		escape(t.User),
+		authMethod,
+		escape(authUser),
+		status,
+	)
+}
+
+// JSON returns the Transfer as a line of JSON, the duration is in
+// seconds
+func (t Transfer) JSON() ([]byte, error) {
+	b, err := json.Marshal(struct {
+		Transfer
+		Duration float64 `json:"duration"`
+	}{t, t.Duration.Seconds()})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// escape replaces whitespace so a field can't be split in to several
+func escape(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Log records transfers
+type Log interface {
+	Write(Transfer) error
+	Close() error
+}
+
+type XferlogOpts struct {
+	// the file to append to, nothing is written when empty
+	File string `goftpd:"file"`
+	// one of xferlog or json, defaults to xferlog
+	Format string `goftpd:"format"`
+}
+
+// FileLog implements Log appending to a file
+type FileLog struct {
+	*XferlogOpts
+
+	mtx sync.Mutex
+	w   io.WriteCloser
+}
+
+// NewFileLog opens the file in the options for appending, creating it if
+// needed
+func NewFileLog(opts *XferlogOpts) (*FileLog, error) {
+	switch strings.ToLower(opts.Format) {
+	case "":
+		opts.Format = FormatXferlog
+	case FormatXferlog, FormatJSON:
+		opts.Format = strings.ToLower(opts.Format)
+	default:
+		return nil, errors.Errorf("unknown xferlog format '%s'", opts.Format)
+	}
+
+	l := FileLog{
+		XferlogOpts: opts,
+	}
+
+	if len(opts.File) == 0 {
+		return &l, nil
+	}
+
+	f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "opening xferlog '%s'", opts.File)
+	}
+
+	l.w = f
+
+	return &l, nil
+}
+
+// Write appends the record of the Transfer
+func (l *FileLog) Write(t Transfer) error {
+	if l.w == nil {
+		return nil
+	}
+
+	var line []byte
+
+	if l.Format == FormatJSON {
+		var err error
+		if line, err = t.JSON(); err != nil {
+			return err
+		}
+	} else {
+		line = []byte(t.Xferlog())
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	_, err := l.w.Write(line)
+
+	return err
+}
+
+// Close closes the file
+func (l *FileLog) Close() error {
+	if l.w == nil {
+		return nil
+	}
+
+	return l.w.Close()
+}
@@ -0,0 +1,103 @@
+package xferlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var testTransfer = Transfer{
+	Time:      time.Date(2020, time.March, 1, 9, 5, 3, 0, time.UTC),
+	Duration:  2600 * time.Millisecond,
+	IP:        "10.0.0.1",
+	Bytes:     1048576,
+	Path:      "/mp3/Some Release/01.mp3",
+	Binary:    true,
+	Direction: Upload,
+	User:      "jawr",
+	Complete:  true,
+}
+
+func TestTransferXferlog(t *testing.T) {
+	var tests = []struct {
+		name     string
+		change   func(*Transfer)
+		expected string
+	}{
+		{
+			"upload",
+			func(*Transfer) {},
+			"Sun Mar  1 09:05:03 2020 3 10.0.0.1 1048576 /mp3/Some_Release/01.mp3 b _ i r jawr ftp 0 * c\n",
+		},
+		{
+			"incomplete anonymous ascii download with ident",
+			func(t *Transfer) {
+				t.Binary = false
+				t.Direction = Download
+				t.Anonymous = true
+				t.Ident = "someone"
+				t.Complete = false
+				t.Duration = 0
+			},
+			"Sun Mar  1 09:05:03 2020 1 10.0.0.1 1048576 /mp3/Some_Release/01.mp3 a _ o a jawr ftp 1 someone i\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := testTransfer
+			tt.change(&tr)
+
+			if got := tr.Xferlog(); got != tt.expected {
+				t.Fatalf("expected '%s' got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransferJSON(t *testing.T) {
+	b, err := testTransfer.JSON()
+	checkErr(t, err, nil)
+
+	var got map[string]interface{}
+	checkErr(t, json.Unmarshal(b, &got), nil)
+
+	if got["duration"] != 2.6 || got["direction"] != Upload || got["path"] != testTransfer.Path || got["complete"] != true {
+		t.Fatalf("unexpected json: %s", b)
+	}
+
+	if _, ok := got["ident"]; ok {
+		t.Fatalf("expected no ident: %s", b)
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	_, err := NewFileLog(&XferlogOpts{Format: "csv"})
+	checkErr(t, err, errors.New("unknown xferlog format 'csv'"))
+
+	dir, err := ioutil.TempDir("", "goftpd-xferlog")
+	checkErr(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "xferlog")
+
+	l, err := NewFileLog(&XferlogOpts{File: file})
+	checkErr(t, err, nil)
+
+	checkErr(t, l.Write(testTransfer), nil)
+	checkErr(t, l.Write(testTransfer), nil)
+	checkErr(t, l.Close(), nil)
+
+	b, err := ioutil.ReadFile(file)
+	checkErr(t, err, nil)
+
+	line := testTransfer.Xferlog()
+	if string(b) != line+line {
+		t.Fatalf("unexpected xferlog '%s'", b)
+	}
+}
@@ -0,0 +1,236 @@
+// Package zipscript checks releases against their .sfv file as they are
+// uploaded. Files that fail their CRC are refused, every file still to come
+// has a <name>-missing marker and the release holds a marker directory
+// showing its progress, replaced by a complete marker once every file has
+// passed. The filesystem does the work, see vfs.FilesystemOpts.SetZipscript
+package zipscript
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goftpd/goftpd/cookie"
+	"github.com/pkg/errors"
+)
+
+// ErrBadCRC is returned for an upload that doesn't match the CRC in the
+// release's sfv
+var ErrBadCRC = errors.New("file failed its CRC check")
+
+// ErrSFVExists is returned when an sfv is uploaded to a release that
+// already has one
+var ErrSFVExists = errors.New("release already has an sfv")
+
+// defaults used when options are empty
+const (
+	defaultIncomplete    = "[ %[percent]% complete - %[done] of %[total] files ]"
+	defaultComplete      = "[ complete - %[size] in %[total] files ]"
+	defaultMissingSuffix = "-missing"
+)
+
+// IsSFV checks to see if the path is an sfv file
+func IsSFV(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".sfv")
+}
+
+// SFV is a parsed sfv file
+type SFV struct {
+	// file names in the order listed
+	Files []string
+	// CRCs keyed by the lower cased file name
+	crcs map[string]uint32
+}
+
+// ParseSFV reads an sfv, each line is `<filename> <crc32 in hex>` and
+// lines starting with ; are comments
+func ParseSFV(r io.Reader) (*SFV, error) {
+	sfv := SFV{
+		crcs: make(map[string]uint32),
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	var n int
+
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			return nil, errors.Errorf("line %d: expected `<filename> <crc>`", n)
+		}
+
+		name := strings.TrimSpace(line[:i])
+
+		crc, err := strconv.ParseUint(line[i+1:], 16, 32)
+		if err != nil || len(line[i+1:]) != 8 {
+			return nil, errors.Errorf("line %d: bad crc '%s'", n, line[i+1:])
+		}
+
+		// names are only ever matched in the release itself
+		name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+
+		key := strings.ToLower(name)
+
+		if _, ok := sfv.crcs[key]; !ok {
+			sfv.Files = append(sfv.Files, name)
+		}
+
+		sfv.crcs[key] = uint32(crc)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &sfv, nil
+}
+
+// CRC returns the CRC of the file, names are matched ignoring case
+func (s *SFV) CRC(name string) (uint32, bool) {
+	crc, ok := s.crcs[strings.ToLower(name)]
+	return crc, ok
+}
+
+// Progress of a release
+type Progress struct {
+	// files that have passed their CRC check
+	Done  int
+	Total int
+	// bytes of the files done
+	Bytes int64
+}
+
+// Complete checks to see if every file has passed
+func (p Progress) Complete() bool {
+	return p.Total > 0 && p.Done == p.Total
+}
+
+// Percent returns how much of the release is done
+func (p Progress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Done * 100 / p.Total
+}
+
+// Cookies returns the cookies of the markers, %[done], %[total],
+// %[percent] and %[size]
+func (p Progress) Cookies() cookie.Cookies {
+	return cookie.Cookies{
+		"done":    strconv.Itoa(p.Done),
+		"total":   strconv.Itoa(p.Total),
+		"percent": strconv.Itoa(p.Percent()),
+		"size":    cookie.FormatBytes(p.Bytes),
+	}
+}
+
+type ZipscriptOpts struct {
+	Enabled bool `goftpd:"enabled"`
+	// name of the progress marker directory, cookies are expanded
+	Incomplete string `goftpd:"incomplete"`
+	// name of the marker directory of a finished release
+	Complete string `goftpd:"complete"`
+	// appended to the name of files still to come
+	MissingSuffix string `goftpd:"missing_suffix"`
+}
+
+// Zipscript names the markers of releases
+type Zipscript struct {
+	*ZipscriptOpts
+
+	incompleteRE *regexp.Regexp
+	completeRE   *regexp.Regexp
+}
+
+// NewZipscript returns a Zipscript using the options, filling in any
+// defaults. Fails if a marker would be a path
+func NewZipscript(opts *ZipscriptOpts) (*Zipscript, error) {
+	if len(opts.Incomplete) == 0 {
+		opts.Incomplete = defaultIncomplete
+	}
+
+	if len(opts.Complete) == 0 {
+		opts.Complete = defaultComplete
+	}
+
+	if len(opts.MissingSuffix) == 0 {
+		opts.MissingSuffix = defaultMissingSuffix
+	}
+
+	for _, s := range []string{opts.Incomplete, opts.Complete, opts.MissingSuffix} {
+		if strings.Contains(s, "/") {
+			return nil, errors.Errorf("zipscript marker '%s' can't contain '/'", s)
+		}
+	}
+
+	z := Zipscript{
+		ZipscriptOpts: opts,
+		incompleteRE:  formatRE(opts.Incomplete),
+		completeRE:    formatRE(opts.Complete),
+	}
+
+	return &z, nil
+}
+
+// IncompleteMarker returns the name of the progress marker
+func (z *Zipscript) IncompleteMarker(p Progress) string {
+	return p.Cookies().Expand(z.Incomplete)
+}
+
+// CompleteMarker returns the name of the marker of a finished release
+func (z *Zipscript) CompleteMarker(p Progress) string {
+	return p.Cookies().Expand(z.Complete)
+}
+
+// MissingMarker returns the name of the marker of a file still to come
+func (z *Zipscript) MissingMarker(name string) string {
+	return name + z.MissingSuffix
+}
+
+// IsMissingMarker checks to see if the name is a missing marker
+func (z *Zipscript) IsMissingMarker(name string) bool {
+	return strings.HasSuffix(name, z.MissingSuffix)
+}
+
+// IsIncompleteMarker checks to see if the name is a progress marker
+func (z *Zipscript) IsIncompleteMarker(name string) bool {
+	return z.incompleteRE.MatchString(name)
+}
+
+// IsCompleteMarker checks to see if the name is the marker of a finished
+// release
+func (z *Zipscript) IsCompleteMarker(name string) bool {
+	return z.completeRE.MatchString(name)
+}
+
+// cookieRE matches a cookie in a marker format
+var cookieRE = regexp.MustCompile(`%-?[0-9]*\[[a-z_]+\]`)
+
+// formatRE returns a regexp matching the names the format expands to
+func formatRE(format string) *regexp.Regexp {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	var last int
+	for _, m := range cookieRE.FindAllStringIndex(format, -1) {
+		b.WriteString(regexp.QuoteMeta(format[last:m[0]]))
+		b.WriteString(".*")
+		last = m[1]
+	}
+
+	b.WriteString(regexp.QuoteMeta(format[last:]))
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
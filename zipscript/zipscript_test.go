@@ -0,0 +1,111 @@
+package zipscript
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSFV(t *testing.T) {
+	sfv, err := ParseSFV(strings.NewReader(`; generated by something
+; with comments
+
+01-artist-title.mp3 0A1B2C3D
+sub\02-artist-title.mp3	deadbeef
+03 with spaces.mp3 00000001
+`))
+	checkErr(t, err, nil)
+
+	expected := []string{"01-artist-title.mp3", "02-artist-title.mp3", "03 with spaces.mp3"}
+
+	if strings.Join(sfv.Files, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected files %v got %v", expected, sfv.Files)
+	}
+
+	for name, crc := range map[string]uint32{
+		"01-ARTIST-TITLE.mp3": 0x0a1b2c3d,
+		"02-artist-title.mp3": 0xdeadbeef,
+		"03 with spaces.mp3":  1,
+	} {
+		got, ok := sfv.CRC(name)
+		if !ok || got != crc {
+			t.Fatalf("expected crc %08x for '%s' got %08x (%t)", crc, name, got, ok)
+		}
+	}
+
+	if _, ok := sfv.CRC("04.mp3"); ok {
+		t.Fatal("expected no crc for file not in the sfv")
+	}
+}
+
+func TestParseSFVBad(t *testing.T) {
+	for _, s := range []string{
+		"nocrc",
+		"file.mp3 xyz",
+		"file.mp3 0A1B2C3D4",
+		"file.mp3 1234",
+	} {
+		if _, err := ParseSFV(strings.NewReader(s)); err == nil {
+			t.Fatalf("expected error parsing '%s'", s)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	p := Progress{Done: 1, Total: 3, Bytes: 2048}
+
+	if p.Complete() || p.Percent() != 33 {
+		t.Fatalf("unexpected progress %+v: %t %d", p, p.Complete(), p.Percent())
+	}
+
+	p.Done = 3
+
+	if !p.Complete() || p.Percent() != 100 {
+		t.Fatalf("unexpected progress %+v: %t %d", p, p.Complete(), p.Percent())
+	}
+
+	if (Progress{}).Complete() {
+		t.Fatal("expected an empty sfv not to be complete")
+	}
+}
+
+func TestMarkers(t *testing.T) {
+	z, err := NewZipscript(&ZipscriptOpts{Enabled: true})
+	checkErr(t, err, nil)
+
+	p := Progress{Done: 1, Total: 4}
+
+	incomplete := z.IncompleteMarker(p)
+	if incomplete != "[ 25% complete - 1 of 4 files ]" {
+		t.Fatalf("unexpected incomplete marker '%s'", incomplete)
+	}
+
+	p.Done, p.Bytes = 4, 3*1024*1024
+
+	complete := z.CompleteMarker(p)
+	if complete != "[ complete - 3.0MB in 4 files ]" {
+		t.Fatalf("unexpected complete marker '%s'", complete)
+	}
+
+	if !z.IsIncompleteMarker(incomplete) || z.IsIncompleteMarker(complete) {
+		t.Fatal("expected to only match the incomplete marker")
+	}
+
+	if !z.IsCompleteMarker(complete) || z.IsCompleteMarker(incomplete) {
+		t.Fatal("expected to only match the complete marker")
+	}
+
+	if z.IsIncompleteMarker("[ release ]") || z.IsCompleteMarker("Sample") {
+		t.Fatal("expected not to match other directories")
+	}
+
+	missing := z.MissingMarker("01.mp3")
+	if missing != "01.mp3-missing" || !z.IsMissingMarker(missing) || z.IsMissingMarker("01.mp3") {
+		t.Fatalf("unexpected missing marker '%s'", missing)
+	}
+}
+
+func TestNewZipscriptBadMarker(t *testing.T) {
+	if _, err := NewZipscript(&ZipscriptOpts{Incomplete: "a/%[done]"}); err == nil {
+		t.Fatal("expected error for marker containing a '/'")
+	}
+}